@@ -0,0 +1,4 @@
+// Package adminpb 管理服务协议的 gRPC 生成代码
+package adminpb
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative admin/adminpb/admin.proto
@@ -0,0 +1,144 @@
+// Package admin 运行中实例的 gRPC 管理服务
+// 自动化工具和 `logai admin` 命令通过该服务查询状态、重新加载配置、静默日志模板、重放溢出队列和提交写入队列
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"log-ai-analyzer/admin/adminpb"
+)
+
+// Controller 运行中的分析服务提供给管理服务的操作
+type Controller interface {
+	// Status 返回实例的运行状态
+	Status() *adminpb.StatusResponse
+	// Reload 重新加载识别规则、抑制规则和密钥引用，返回已重新加载的内容和值发生变化的密钥配置项
+	Reload(ctx context.Context) (reloaded, changedSecrets []string, err error)
+	// Silence 静默日志模板，返回是否新建了规则（false 表示该模板已被静默）
+	Silence(templateID, reason, sample string) (bool, error)
+	// Replay 立即重放ES溢出队列，返回重放的文档数
+	Replay() (int64, error)
+	// Flush 立即提交各存储后端缓存的数据
+	Flush()
+}
+
+// Options 管理服务的监听地址和安全配置
+type Options struct {
+	Listen      string
+	Token       func() string // 访问令牌，每次请求时读取以支持密钥轮换，为nil或返回空时不校验
+	TLSCertFile string        // 服务端证书（PEM），为空时不启用TLS
+	TLSKeyFile  string
+}
+
+// Server AdminService 的服务端实现
+type Server struct {
+	adminpb.UnimplementedAdminServiceServer
+	ctl Controller
+}
+
+// NewServer 创建 AdminService 服务端
+func NewServer(ctl Controller) *Server {
+	return &Server{ctl: ctl}
+}
+
+// Status 返回实例的运行状态
+func (s *Server) Status(ctx context.Context, req *adminpb.StatusRequest) (*adminpb.StatusResponse, error) {
+	return s.ctl.Status(), nil
+}
+
+// ReloadConfig 重新加载识别规则、抑制规则和密钥引用
+func (s *Server) ReloadConfig(ctx context.Context, req *adminpb.ReloadConfigRequest) (*adminpb.ReloadConfigResponse, error) {
+	reloaded, changed, err := s.ctl.Reload(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Printf("🔄 通过管理服务重新加载配置: %s", strings.Join(reloaded, ", "))
+	return &adminpb.ReloadConfigResponse{Reloaded: reloaded, ChangedSecrets: changed}, nil
+}
+
+// CreateSilence 静默日志模板
+func (s *Server) CreateSilence(ctx context.Context, req *adminpb.CreateSilenceRequest) (*adminpb.CreateSilenceResponse, error) {
+	templateID := strings.TrimSpace(req.GetTemplateId())
+	if templateID == "" {
+		return nil, status.Error(codes.InvalidArgument, "template_id 不能为空")
+	}
+	created, err := s.ctl.Silence(templateID, req.GetReason(), req.GetSample())
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &adminpb.CreateSilenceResponse{Created: created}, nil
+}
+
+// TriggerReplay 立即重放ES溢出队列，集群不可用时返回 Unavailable
+func (s *Server) TriggerReplay(ctx context.Context, req *adminpb.TriggerReplayRequest) (*adminpb.TriggerReplayResponse, error) {
+	n, err := s.ctl.Replay()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &adminpb.TriggerReplayResponse{Documents: n}, nil
+}
+
+// FlushQueues 立即提交各存储后端缓存的数据
+func (s *Server) FlushQueues(ctx context.Context, req *adminpb.FlushQueuesRequest) (*adminpb.FlushQueuesResponse, error) {
+	s.ctl.Flush()
+	return &adminpb.FlushQueuesResponse{}, nil
+}
+
+// authInterceptor 校验调用方携带的访问令牌
+func authInterceptor(token func() string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		want := token()
+		if want == "" {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		var got string
+		if values := md.Get("authorization"); len(values) > 0 {
+			got = strings.TrimPrefix(values[0], "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "访问令牌无效")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Serve 在配置的地址上启动 AdminService，ctx 结束时优雅停止
+func Serve(ctx context.Context, opts Options, ctl Controller) error {
+	var serverOpts []grpc.ServerOption
+	if opts.Token != nil {
+		serverOpts = append(serverOpts, grpc.UnaryInterceptor(authInterceptor(opts.Token)))
+	}
+	if opts.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("加载管理服务TLS证书失败: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return fmt.Errorf("监听管理服务地址失败: %w", err)
+	}
+	server := grpc.NewServer(serverOpts...)
+	adminpb.RegisterAdminServiceServer(server, NewServer(ctl))
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	log.Printf("✅ gRPC 管理服务已启动, 地址: %s", opts.Listen)
+	return server.Serve(lis)
+}
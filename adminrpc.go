@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/admin/adminpb"
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/metrics"
+	"log-ai-analyzer/sink"
+)
+
+// adminController 实现 admin.Controller，将管理服务的请求转给运行中的各组件
+type adminController struct {
+	cfg           *config.Config
+	probe         *healthProbe
+	alertCache    *alert.AlertCache
+	suppressor    *alert.Suppressor
+	store         sink.Multi
+	esClient      *esclient.ESClient
+	tenantClients map[string]*esclient.ESClient
+	startedAt     time.Time
+}
+
+// Status 返回组件健康状态、积压事件数、持续中的告警数和溢出队列中的文档数
+func (c *adminController) Status() *adminpb.StatusResponse {
+	health, ready := c.probe.check(false)
+	if !ready {
+		health.Status = "unavailable"
+	}
+	resp := &adminpb.StatusResponse{
+		Instance:     c.cfg.InstanceID,
+		Labels:       c.cfg.InstanceLabels,
+		StartedAt:    c.startedAt.Unix(),
+		Ready:        ready,
+		Status:       health.Status,
+		ActiveAlerts: int64(len(c.alertCache.Active())),
+	}
+	for _, s := range health.Components {
+		resp.Components = append(resp.Components, &adminpb.ComponentStatus{Name: s.Name, Healthy: s.Healthy, Detail: s.Detail})
+	}
+	for _, p := range health.AI {
+		resp.Components = append(resp.Components, &adminpb.ComponentStatus{
+			Name:    fmt.Sprintf("ai/%s/%s", p.Provider, p.Model),
+			Healthy: p.Healthy,
+			Detail:  p.LastError,
+		})
+	}
+	if c.probe.backlog != nil {
+		resp.Backlog = int64(c.probe.backlog.Len())
+		resp.BacklogLimit = int64(c.probe.backlog.Limit())
+	}
+	if c.cfg.EnableES {
+		resp.SpillDocuments = c.esClient.SpillDocs()
+	}
+	for _, client := range c.tenantClients {
+		resp.SpillDocuments += client.SpillDocs()
+	}
+	return resp
+}
+
+// Reload 重新加载识别规则、抑制规则和密钥引用；其他配置项的变化需要重启后生效
+func (c *adminController) Reload(ctx context.Context) ([]string, []string, error) {
+	var reloaded []string
+	if c.cfg.HeuristicsFile != "" {
+		if err := reloadHeuristics(c.cfg.HeuristicsFile); err != nil {
+			return nil, nil, err
+		}
+		reloaded = append(reloaded, "heuristics")
+	}
+	c.suppressor.Refresh()
+	reloaded = append(reloaded, "suppressions")
+
+	changed, err := c.cfg.RefreshSecrets(ctx)
+	if err != nil {
+		metrics.SecretRefreshErrorCount.Inc()
+		return reloaded, changed, fmt.Errorf("重新解析密钥引用失败: %w", err)
+	}
+	reloaded = append(reloaded, "secrets")
+	if len(changed) > 0 {
+		log.Printf("🔑 密钥已更新: %s", strings.Join(changed, ", "))
+	}
+	return reloaded, changed, nil
+}
+
+// Silence 将日志模板写入噪声抑制规则文件，未配置 NOISE_SUPPRESSION_FILE 时返回错误
+func (c *adminController) Silence(templateID, reason, sample string) (bool, error) {
+	if c.cfg.NoiseSuppressionFile == "" {
+		return false, fmt.Errorf("未配置 NOISE_SUPPRESSION_FILE，无法静默日志模板")
+	}
+	if reason == "" {
+		reason = "通过 gRPC 管理服务手工添加"
+	}
+	added, err := alert.AddSuppressions(c.cfg.NoiseSuppressionFile, []alert.SuppressionRule{{
+		TemplateID: templateID,
+		Sample:     sample,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}})
+	if err != nil {
+		return false, err
+	}
+	if added == 0 {
+		return false, nil
+	}
+	c.suppressor.Refresh()
+	log.Printf("已静默日志模板 %s: %s", templateID, reason)
+	return true, nil
+}
+
+// Replay 立即重放主集群和各租户集群的溢出队列，未启用ES存储时返回错误
+func (c *adminController) Replay() (int64, error) {
+	if !c.cfg.EnableES {
+		return 0, fmt.Errorf("未启用ES存储")
+	}
+	total, err := c.esClient.ReplaySpill()
+	if err != nil {
+		return total, err
+	}
+	for name, client := range c.tenantClients {
+		n, err := client.ReplaySpill()
+		if err != nil {
+			return total, fmt.Errorf("租户 %s: %w", name, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Flush 立即提交各存储后端缓存的数据
+func (c *adminController) Flush() {
+	c.store.Flush()
+	log.Println("已通过 gRPC 管理服务提交存储队列")
+}
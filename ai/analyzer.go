@@ -1,152 +1,221 @@
 package ai
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"log"
 	"strings"
 	"time"
 
+	"log-ai-analyzer/collector"
 	"log-ai-analyzer/config"
+	"log-ai-analyzer/metrics"
 )
 
 // AnalyzeResult AI分析结果
 type AnalyzeResult struct {
-	Content string
-	Error   error
+	Content          string
+	PromptTokens     int
+	CompletionTokens int
+	Error            error
 }
 
-// Analyze 对日志内容进行AI分析
-func Analyze(cfg *config.Config, content string) (string, error) {
+// systemPrompt 内置的系统提示词，可通过 AI_SYSTEM_PROMPT_FILE 指定模板文件覆盖
+const systemPrompt = `
+你是一位资深的 Linux 系统工程师，擅长分析系统日志和故障排查。
+请你根据以下日志：
+1. 识别关键错误和潜在问题，按重要程度排序。
+2. 分析错误原因，并提供详细的技术解释。
+3. 给出专业的修复建议，包括具体的 Linux 命令、配置修改方案或优化建议。
+4. 如果是严重问题，请说明可能的影响和紧急处理措施。
+请按照专业系统工程师的方式进行分析，并输出清晰的报告格式。
+`
+
+// Analyze 对日志事件进行AI分析
+func Analyze(cfg *config.Config, event collector.LogEvent) (string, error) {
 	if strings.ToLower(cfg.AIEnable) != "true" {
-		return "AI 分析未启用", nil
+		return DisabledResult(event), nil
 	}
 
-	// 创建带超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	event = truncateEvent(event, cfg.AIMaxInputTokens)
+	// 两阶段分析：分级未达到阈值的事件不进行深度分析
+	if t, deep := triageEvent(cfg, event); !deep {
+		return skippedResult(event, t, cfg.AITriageThreshold), nil
+	}
+	system, user, err := buildPrompts(cfg, event)
+	if err != nil {
+		return "", err
+	}
+	historySystem, historyUser := historyContext(event)
+	system += historySystem
+	user += historyUser
+	relatedSystem, relatedUser := relatedContext(event)
+	system += relatedSystem
+	user += relatedUser
+
+	result, err := completeAnalysis(cfg, toolsFor(cfg, event), system, user)
+	if errors.Is(err, ErrBudgetExhausted) {
+		// 预算耗尽时降级为历史分析结果或规则摘要
+		return degradedResult(event, "AI预算已用尽"), nil
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		return degradedResult(event, "AI服务不可用"), nil
+	}
+	if errors.Is(err, ErrUnsafeResponse) {
+		log.Printf("AI回复未通过安全校验 [EventID: %s]: %v", event.EventID, err)
+		return unsafeResult(event), nil
+	}
+	if errors.Is(err, ErrInvalidResponse) {
+		log.Printf("AI回复未通过结构校验 [EventID: %s]: %v", event.EventID, err)
+		return invalidResult(event), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	results.put(event.TemplateID, result)
+	return result, nil
+}
 
-	resultChan := make(chan AnalyzeResult, 1)
+// complete 调用配置的AI后端完成一次分析，带超时与重试
+// 主后端失败（宕机、限额、超时等）时依次切换到备用后端
+// 配置了预算时先检查额度，完成后记录token用量
+func complete(cfg *config.Config, system, user string) (string, error) {
+	return completeTools(cfg, nil, system, user)
+}
 
-	// 在goroutine中执行AI分析，支持超时
-	go func() {
-		result, err := performAIAnalysis(cfg, content)
-		resultChan <- AnalyzeResult{Content: result, Error: err}
-	}()
+// completeTools 同 complete，tools 非nil且后端支持工具调用时允许模型查询更多上下文
+func completeTools(cfg *config.Config, tools *toolSet, system, user string) (string, error) {
+	// 日志内容不可信：过滤注入话术并包裹在随机分隔标签中
+	system, user, tag := guardPrompts(system, user)
 
-	select {
-	case <-ctx.Done():
-		return "", fmt.Errorf("AI分析超时")
-	case res := <-resultChan:
-		return res.Content, res.Error
+	res, _, err := callProviders(cfg, tools, system, user, tag)
+	if err != nil {
+		return "", err
 	}
+	if err := checkResponse(res.Content, tag); err != nil {
+		metrics.AIUnsafeResponseCount.Inc()
+		return "", err
+	}
+	return res.Content, nil
 }
 
-// performAIAnalysis 执行实际的AI分析请求
-func performAIAnalysis(cfg *config.Config, content string) (string, error) {
-	// 重试机制，最多尝试3次
-	var lastErr error
-	for attempt := 0; attempt < 3; attempt++ {
-		if attempt > 0 {
-			// 重试前等待一段时间
-			time.Sleep(time.Duration(attempt) * time.Second)
-		}
-
-		systemPrompt := `
-你是一位资深的 Linux 系统工程师，擅长分析系统日志和故障排查。
-请你根据以下日志：
-1. 识别关键错误和潜在问题，按重要程度排序。
-2. 分析错误原因，并提供详细的技术解释。
-3. 给出专业的修复建议，包括具体的 Linux 命令、配置修改方案或优化建议。
-4. 如果是严重问题，请说明可能的影响和紧急处理措施。
-请按照专业系统工程师的方式进行分析，并输出清晰的报告格式。
-`
+// Complete 使用配置的AI后端链完成一次请求，执行预算、健康检查、限流和故障切换，
+// 但不做提示词防护和回复校验。供 sidecar 服务端使用，防护和校验由发起请求的 agent 完成
+func Complete(cfg *config.Config, system, content string) (Completion, Provider, error) {
+	return callProviders(cfg, nil, system, content, "")
+}
 
-		data := map[string]interface{}{
-			"model": cfg.AIModel,
-			"messages": []map[string]string{
-				{"role": "system", "content": systemPrompt},
-				{"role": "user", "content": content},
-			},
-			"stream": true,
-			"temperature": 0.7, // 增加温度参数以获得更好的创造性
-		}
+// callProviders 按优先级依次调用可用的AI后端，返回第一个成功的回复及提供服务的后端
+func callProviders(cfg *config.Config, tools *toolSet, system, user, tag string) (Completion, Provider, error) {
+	if err := budget.Allow(); err != nil {
+		return Completion{}, nil, err
+	}
 
-		body, err := json.Marshal(data)
-		if err != nil {
-			lastErr = fmt.Errorf("序列化请求数据失败: %w", err)
-			continue
+	all, err := NewProviders(cfg)
+	if err != nil {
+		return Completion{}, nil, err
+	}
+	// 跳过健康检查判定为不可用的后端，全部不可用时立即返回，不再等待超时
+	var providers []Provider
+	for _, p := range all {
+		if health.healthy(p) {
+			providers = append(providers, p)
 		}
+	}
+	if len(providers) == 0 {
+		metrics.AIUnavailableSkipCount.Inc()
+		return Completion{}, nil, ErrAIUnavailable
+	}
 
-		req, err := http.NewRequest("POST", cfg.AIAPIURL, bytes.NewBuffer(body))
-		if err != nil {
-			lastErr = fmt.Errorf("创建HTTP请求失败: %w", err)
-			continue
+	var lastErr error
+	for i, provider := range providers {
+		if i > 0 {
+			log.Printf("AI后端 %s(%s) 不可用，切换到备用后端 %s(%s): %v",
+				providers[i-1].Name(), providers[i-1].ModelName(), provider.Name(), provider.ModelName(), lastErr)
 		}
-		req.Header.Set("Authorization", "Bearer "+cfg.AIAPIKey)
-		req.Header.Set("Content-Type", "application/json")
 
-		client := &http.Client{
-			Timeout: 30 * time.Second, // 设置HTTP客户端超时
+		res, err := completeWith(provider, tools, system, user, tag)
+		if errors.Is(err, ErrAIThrottled) {
+			// 限流是本服务自身的约束，与后端是否可用无关：不计入健康检查，切换到备用后端同样需要排队
+			return Completion{}, nil, err
 		}
-
-		resp, err := client.Do(req)
+		health.record(provider, err)
 		if err != nil {
-			lastErr = fmt.Errorf("发送HTTP请求失败: %w", err)
+			lastErr = err
 			continue
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("AI服务返回错误状态码: %d", resp.StatusCode)
-			continue
+		metrics.AIProviderServedCount.WithLabelValues(provider.Name(), provider.ModelName()).Inc()
+		// 后端未返回用量时按字符数估算
+		if res.PromptTokens == 0 && res.CompletionTokens == 0 {
+			res.PromptTokens = estimateTokens(system) + estimateTokens(user)
+			res.CompletionTokens = estimateTokens(res.Content)
 		}
+		budget.Record(res.PromptTokens, res.CompletionTokens)
+		return res, provider, nil
+	}
+	return Completion{}, nil, lastErr
+}
+
+// completeWith 使用单个后端完成一次分析，带超时与重试；超时按每次模型请求计算，
+// 从取得限流许可后开始计时，限流排队不计入分析超时
+func completeWith(provider Provider, tools *toolSet, system, user, tag string) (Completion, error) {
+	timeout := provider.Timeout()
+	if toolProvider, ok := provider.(ToolProvider); ok && tools != nil {
+		return tools.run(context.Background(), toolProvider, timeout, system, user, tag)
+	}
+	return performAIAnalysis(context.Background(), provider, timeout, system, user)
+}
 
-		result := []string{}
-		reader := bufio.NewReader(resp.Body)
+// performAIAnalysis 执行实际的AI分析请求，timeout 为单次请求的超时
+func performAIAnalysis(ctx context.Context, provider Provider, timeout time.Duration, system, content string) (Completion, error) {
+	return withRetry(ctx, timeout, func(ctx context.Context) (Completion, error) {
+		return provider.Complete(ctx, system, content)
+	})
+}
 
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
+// withRetry 执行一次模型请求，可重试的错误按退避策略重试；
+// 每次发出HTTP请求（含重试、故障切换和每一轮工具调用）前都向限流器申请许可，请求结束即归还，
+// 取得许可后才开始按 timeout 计时，排队时间不计入请求超时
+func withRetry(ctx context.Context, timeout time.Duration, request func(ctx context.Context) (Completion, error)) (Completion, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			if !isRetryable(lastErr) {
+				break
+			}
+			delay := retryDelay(lastErr, attempt)
+			if delay > maxRetryAfter {
+				break
+			}
+			// 剩余时间不足以等待时直接放弃
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
 				break
 			}
-			// 解析 JSON 数据
-			if strings.HasPrefix(line, "data: ") {
-				var payload map[string]interface{}
-				if err := json.Unmarshal([]byte(line[6:]), &payload); err == nil {
-					// 检查是否有 choices 字段
-					choices, ok := payload["choices"]
-					if !ok || choices == nil {
-						continue
-					}
-
-					choicesArray, ok := choices.([]interface{})
-					if !ok || len(choicesArray) == 0 {
-						continue
-					}
-
-					choice, ok := choicesArray[0].(map[string]interface{})
-					if !ok {
-						continue
-					}
-
-					delta, ok := choice["delta"].(map[string]interface{})
-					if !ok {
-						continue
-					}
-
-					if content, ok := delta["content"].(string); ok {
-						result = append(result, content)
-					}
-				}
+			if err := sleepContext(ctx, delay); err != nil {
+				break
 			}
 		}
 
-		return strings.Join(result, ""), nil
+		release, err := limiter.Acquire(ctx)
+		if err != nil {
+			// 排队超时不再重试，本次请求放弃
+			return Completion{}, err
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		result, err := request(reqCtx)
+		if err != nil && errors.Is(reqCtx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("AI分析超时: %w", err)
+		}
+		cancel()
+		release()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return result, nil
 	}
 
-	return "", lastErr
-}
\ No newline at end of file
+	return Completion{}, lastErr
+}
@@ -0,0 +1,183 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+)
+
+// batchPrompt 合并分析时追加到系统提示词后的说明
+const batchPrompt = `
+以下是同一主机在同一分钟内集中出现的 %d 个相关日志事件，它们很可能属于同一次故障。
+请不要逐条孤立分析，而是给出一份综合的事件分析：梳理事件之间的先后与因果关系，找出最根本的原因，
+说明整体影响范围，并给出统一的处理建议。
+`
+
+// eventBatch 等待合并分析的一批事件
+type eventBatch struct {
+	events []collector.LogEvent
+	done   chan struct{}
+	result string
+	err    error
+}
+
+// Batcher 将同一主机、同一分钟内集中到达的事件合并为一次AI调用
+// 每个调用方都会阻塞到所在批次分析完成，并得到同一份综合分析结果
+type Batcher struct {
+	cfg     *config.Config
+	window  time.Duration // 收集同批事件的等待时间
+	maxSize int           // 单批最多事件数，达到后立即分析
+
+	mu      sync.Mutex
+	pending map[string]*eventBatch
+}
+
+// NewBatcher 创建事件合并分析器，window<=0 时返回nil表示不合并
+func NewBatcher(cfg *config.Config, window time.Duration, maxSize int) *Batcher {
+	if window <= 0 {
+		return nil
+	}
+	if maxSize <= 0 {
+		maxSize = 10
+	}
+	return &Batcher{
+		cfg:     cfg,
+		window:  window,
+		maxSize: maxSize,
+		pending: make(map[string]*eventBatch),
+	}
+}
+
+// Analyze 对事件进行AI分析，同批的相关事件合并为一次调用
+func (b *Batcher) Analyze(event collector.LogEvent) (string, error) {
+	if b == nil {
+		return "", fmt.Errorf("未启用事件合并分析")
+	}
+	if strings.ToLower(b.cfg.AIEnable) != "true" {
+		return DisabledResult(event), nil
+	}
+
+	key := batchKey(event)
+
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &eventBatch{done: make(chan struct{})}
+		b.pending[key] = batch
+		time.AfterFunc(b.window, func() { b.flush(key, batch) })
+	}
+	batch.events = append(batch.events, event)
+	full := len(batch.events) >= b.maxSize
+	b.mu.Unlock()
+
+	if full {
+		b.flush(key, batch)
+	}
+
+	<-batch.done
+	return batch.result, batch.err
+}
+
+// flush 对一批事件执行分析，同一批次只会执行一次
+func (b *Batcher) flush(key string, batch *eventBatch) {
+	b.mu.Lock()
+	if b.pending[key] != batch {
+		// 已经因为达到批次上限被提前分析
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	events := batch.events
+	b.mu.Unlock()
+
+	if len(events) == 1 {
+		batch.result, batch.err = Analyze(b.cfg, events[0])
+	} else {
+		batch.result, batch.err = AnalyzeBatch(b.cfg, events)
+	}
+	close(batch.done)
+}
+
+// batchKey 相关事件按主机和分钟分组
+func batchKey(event collector.LogEvent) string {
+	minute := time.Now().Truncate(time.Minute)
+	if ts, err := time.Parse(time.RFC3339, event.Timestamp); err == nil {
+		minute = ts.Truncate(time.Minute)
+	}
+	return event.Host + "|" + minute.Format(time.RFC3339)
+}
+
+// AnalyzeBatch 将多个相关事件合并为一个提示词进行综合分析
+func AnalyzeBatch(cfg *config.Config, events []collector.LogEvent) (string, error) {
+	if len(events) == 0 {
+		return "", nil
+	}
+
+	// 两阶段分析：任一事件达到阈值时整批进行深度分析，其余事件作为关联上下文
+	deep := false
+	var first Triage
+	for i, event := range events {
+		t, ok := triageEvent(cfg, event)
+		if i == 0 {
+			first = t
+		}
+		if ok {
+			deep = true
+			break
+		}
+	}
+	if !deep {
+		return skippedResult(events[0], first, cfg.AITriageThreshold), nil
+	}
+
+	// 系统提示词按第一个事件路由，并追加合并分析说明
+	system, _, err := buildPrompts(cfg, events[0])
+	if err != nil {
+		return "", err
+	}
+	system += fmt.Sprintf(batchPrompt, len(events))
+
+	var user strings.Builder
+	// 合并分析时各事件平分token预算，同一请求在其他日志中的事件附在各事件之后
+	perEvent := cfg.AIMaxInputTokens / len(events)
+	var related string
+	for i, event := range events {
+		_, content, err := buildPrompts(cfg, truncateEvent(event, perEvent))
+		if err != nil {
+			return "", err
+		}
+		relatedSystem, relatedUser := relatedContext(event)
+		if relatedSystem != "" {
+			related = relatedSystem
+		}
+		fmt.Fprintf(&user, "### 事件 %d（%s 第 %d 行）\n%s%s\n\n", i+1, event.FilePath, event.LineNumber, content, relatedUser)
+	}
+	system += related
+	// 历史事件按第一个事件检索
+	historySystem, historyUser := historyContext(events[0])
+	system += historySystem
+	user.WriteString(historyUser)
+
+	result, err := completeAnalysis(cfg, toolsFor(cfg, events[0]), system, user.String())
+	if errors.Is(err, ErrBudgetExhausted) {
+		return degradedResult(events[0], "AI预算已用尽"), nil
+	}
+	if errors.Is(err, ErrAIUnavailable) {
+		return degradedResult(events[0], "AI服务不可用"), nil
+	}
+	if errors.Is(err, ErrUnsafeResponse) {
+		return unsafeResult(events[0]), nil
+	}
+	if errors.Is(err, ErrInvalidResponse) {
+		return invalidResult(events[0]), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("（综合分析了同一主机同一分钟内的 %d 个相关事件）\n%s", len(events), result), nil
+}
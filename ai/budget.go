@@ -0,0 +1,218 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// ErrBudgetExhausted AI调用预算已用尽
+var ErrBudgetExhausted = errors.New("AI调用预算已用尽")
+
+// BudgetLimits 预算上限，0表示不限制
+type BudgetLimits struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+	DailyCost     float64
+	MonthlyCost   float64
+
+	// 每千token价格，用于估算费用
+	PromptPricePer1K     float64
+	CompletionPricePer1K float64
+}
+
+// Budget 按自然日、自然月统计AI用量并执行上限控制
+type Budget struct {
+	limits BudgetLimits
+
+	mu            sync.Mutex
+	day           string
+	month         string
+	dailyTokens   int64
+	monthlyTokens int64
+	dailyCost     float64
+	monthlyCost   float64
+	exhausted     string // 已触发耗尽通知的周期，避免重复通知
+
+	onExhausted func(reason string)
+}
+
+// budget 当前生效的预算，nil表示不限制
+var budget *Budget
+
+// SetBudget 设置全局AI预算，onExhausted 在每个周期首次超出预算时调用
+func SetBudget(limits BudgetLimits, onExhausted func(reason string)) {
+	if limits.DailyTokens <= 0 && limits.MonthlyTokens <= 0 && limits.DailyCost <= 0 && limits.MonthlyCost <= 0 {
+		budget = nil
+		return
+	}
+	budget = &Budget{limits: limits, onExhausted: onExhausted}
+}
+
+// rollover 切换到新的统计周期，调用方需持有锁
+func (b *Budget) rollover(now time.Time) {
+	day := now.Format("2006-01-02")
+	month := now.Format("2006-01")
+	if b.month != month {
+		b.month = month
+		b.monthlyTokens = 0
+		b.monthlyCost = 0
+	}
+	if b.day != day {
+		b.day = day
+		b.dailyTokens = 0
+		b.dailyCost = 0
+	}
+}
+
+// Allow 检查是否还有剩余预算
+func (b *Budget) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	b.rollover(time.Now())
+
+	var reason, period string
+	switch {
+	case b.limits.DailyTokens > 0 && b.dailyTokens >= b.limits.DailyTokens:
+		reason, period = fmt.Sprintf("今日token用量 %d 已达上限 %d", b.dailyTokens, b.limits.DailyTokens), b.day
+	case b.limits.DailyCost > 0 && b.dailyCost >= b.limits.DailyCost:
+		reason, period = fmt.Sprintf("今日费用 %.2f 已达上限 %.2f", b.dailyCost, b.limits.DailyCost), b.day
+	case b.limits.MonthlyTokens > 0 && b.monthlyTokens >= b.limits.MonthlyTokens:
+		reason, period = fmt.Sprintf("本月token用量 %d 已达上限 %d", b.monthlyTokens, b.limits.MonthlyTokens), b.month
+	case b.limits.MonthlyCost > 0 && b.monthlyCost >= b.limits.MonthlyCost:
+		reason, period = fmt.Sprintf("本月费用 %.2f 已达上限 %.2f", b.monthlyCost, b.limits.MonthlyCost), b.month
+	default:
+		b.mu.Unlock()
+		return nil
+	}
+
+	notify := b.exhausted != period
+	b.exhausted = period
+	b.mu.Unlock()
+
+	metrics.AIBudgetExhaustedCount.Inc()
+	if notify && b.onExhausted != nil {
+		b.onExhausted(reason)
+	}
+	return fmt.Errorf("%w: %s", ErrBudgetExhausted, reason)
+}
+
+// Record 记录一次调用的token用量
+func (b *Budget) Record(promptTokens, completionTokens int) {
+	metrics.AITokensUsed.WithLabelValues("prompt").Add(float64(promptTokens))
+	metrics.AITokensUsed.WithLabelValues("completion").Add(float64(completionTokens))
+	if b == nil {
+		return
+	}
+
+	cost := float64(promptTokens)/1000*b.limits.PromptPricePer1K +
+		float64(completionTokens)/1000*b.limits.CompletionPricePer1K
+	metrics.AICostTotal.Add(cost)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollover(time.Now())
+	tokens := int64(promptTokens + completionTokens)
+	b.dailyTokens += tokens
+	b.monthlyTokens += tokens
+	b.dailyCost += cost
+	b.monthlyCost += cost
+}
+
+// estimateTokens 按字符数粗略估算token数量
+func estimateTokens(s string) int {
+	return utf8.RuneCountInString(s)/2 + 1
+}
+
+// 历史分析结果缓存的最大条目数
+const resultCacheSize = 1000
+
+// resultCache 按日志模板缓存最近一次AI分析结果，预算耗尽时复用
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]string
+	order   []string
+}
+
+var results = &resultCache{entries: make(map[string]string)}
+
+func (c *resultCache) put(templateID, result string) {
+	if templateID == "" || result == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[templateID]; !ok {
+		c.order = append(c.order, templateID)
+		if len(c.order) > resultCacheSize {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.entries[templateID] = result
+}
+
+func (c *resultCache) get(templateID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.entries[templateID]
+	return result, ok
+}
+
+// degradedResult 预算耗尽或AI不可用时的降级结果：优先复用同一模板的历史分析，否则生成规则摘要
+func degradedResult(event collector.LogEvent, reason string) string {
+	if cached, ok := results.get(event.TemplateID); ok {
+		return fmt.Sprintf("（%s，以下为同类日志的历史分析结果）\n%s", reason, cached)
+	}
+	return fmt.Sprintf("（%s，以下为规则摘要）\n%s", reason, heuristicSummary(event))
+}
+
+// PreliminaryResult 渐进式告警中AI分析完成前使用的规则摘要
+func PreliminaryResult(event collector.LogEvent) string {
+	return "（AI分析进行中，完成后将补发分析结果，以下为规则摘要）\n" + heuristicSummary(event)
+}
+
+// FailedResult AI分析失败时的结果，附带规则摘要
+func FailedResult(event collector.LogEvent, err error) string {
+	return fmt.Sprintf("AI分析失败: %v\n（以下为规则摘要）\n%s", err, heuristicSummary(event))
+}
+
+// DisabledResult AI分析未启用时的结果，附带规则摘要
+func DisabledResult(event collector.LogEvent) string {
+	return "（AI分析未启用，以下为规则摘要）\n" + heuristicSummary(event)
+}
+
+// heuristicSummary 基于标签和严重性生成的规则摘要，命中离线规则时附带已知原因和处理建议
+func heuristicSummary(event collector.LogEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "主机 %s 的 %s 出现异常，严重性评分 %d。\n", event.Host, event.FilePath, event.SeverityScore)
+	if len(event.Tags) > 0 {
+		fmt.Fprintf(&b, "命中关键词: %s。\n", strings.Join(uniqueStrings(event.Tags), "、"))
+	}
+	if len(event.RawLines) > 0 {
+		fmt.Fprintf(&b, "首行日志: %s\n", event.RawLines[0])
+	}
+	b.WriteString(ruleAnalysis(event))
+	return b.String()
+}
+
+func uniqueStrings(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	var result []string
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
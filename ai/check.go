@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"log-ai-analyzer/config"
+)
+
+// CheckPromptTemplate 检查提示词模板文件能否读取和解析，用于启动前的配置检查；
+// 服务运行时解析失败的模板只记录日志并使用内置模板
+func CheckPromptTemplate(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取提示词模板失败: %w", err)
+	}
+	if _, err := template.New(path).Funcs(promptFuncs).Parse(string(data)); err != nil {
+		return fmt.Errorf("解析提示词模板失败: %w", err)
+	}
+	return nil
+}
+
+// CheckPromptRoutes 检查提示词路由表及其引用的系统提示词模板
+func CheckPromptRoutes(path string) error {
+	routes, err := parsePromptRoutes(path)
+	if err != nil {
+		return err
+	}
+	for _, r := range routes {
+		if r.SystemPromptFile == "" {
+			continue
+		}
+		if err := CheckPromptTemplate(r.SystemPromptFile); err != nil {
+			return fmt.Errorf("路由 %s: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// CheckOfflineRules 检查离线规则文件能否解析，规则中的正则能否编译
+func CheckOfflineRules(path string) error {
+	_, err := parseOfflineRules(path)
+	return err
+}
+
+// Probe 探测主用和备用AI后端是否可用，不消耗token；调用前需先调用 ConfigureTransport
+func Probe(ctx context.Context, cfg *config.Config) ([]ProviderHealth, error) {
+	providers, err := NewProviders(cfg)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ProviderHealth, 0, len(providers))
+	for _, p := range providers {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := p.Ping(probeCtx)
+		cancel()
+		h := ProviderHealth{Provider: p.Name(), Model: p.ModelName(), Healthy: err == nil, CheckedAt: time.Now()}
+		if err != nil {
+			h.LastError = err.Error()
+		}
+		results = append(results, h)
+	}
+	return results, nil
+}
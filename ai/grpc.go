@@ -0,0 +1,177 @@
+package ai
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"log-ai-analyzer/sidecar/sidecarpb"
+)
+
+// GRPCProvider 将AI调用委托给 sidecar 或中心AI服务（AnalyzeService）
+// 服务端持有真正的API Key并统一执行限流和预算，APIKey 为访问服务端的令牌
+// URL 格式为 host:port（明文）或 grpcs://host:port（TLS，使用 AI_CA_FILE 等证书配置）
+// 采样参数由服务端决定，agent 只配置请求超时
+type GRPCProvider struct {
+	URL            string
+	APIKey         string
+	Model          string
+	RequestTimeout time.Duration
+}
+
+// NewGRPCProvider 创建 gRPC sidecar 后端，timeout<=0 时使用默认超时
+func NewGRPCProvider(url, apiKey, model string, timeout time.Duration) *GRPCProvider {
+	return &GRPCProvider{URL: url, APIKey: apiKey, Model: model, RequestTimeout: timeout}
+}
+
+// Name 返回后端名称
+func (p *GRPCProvider) Name() string {
+	return "grpc"
+}
+
+// ModelName 返回使用的模型名称，模型由服务端决定，未配置时返回 sidecar
+func (p *GRPCProvider) ModelName() string {
+	if p.Model == "" {
+		return "sidecar"
+	}
+	return p.Model
+}
+
+// Timeout 返回单次请求的超时时间，服务端可能排队或切换后端，默认2分钟
+func (p *GRPCProvider) Timeout() time.Duration {
+	if p.RequestTimeout > 0 {
+		return p.RequestTimeout
+	}
+	return 2 * time.Minute
+}
+
+var (
+	grpcConnsMu sync.Mutex
+	grpcConns   = make(map[string]*grpc.ClientConn)
+)
+
+// client 返回到服务端的客户端，同一地址共享一个连接
+func (p *GRPCProvider) client() (sidecarpb.AnalyzeServiceClient, error) {
+	grpcConnsMu.Lock()
+	defer grpcConnsMu.Unlock()
+
+	if conn, ok := grpcConns[p.URL]; ok {
+		return sidecarpb.NewAnalyzeServiceClient(conn), nil
+	}
+
+	target := strings.TrimPrefix(p.URL, "grpc://")
+	creds := insecure.NewCredentials()
+	if secure, ok := strings.CutPrefix(p.URL, "grpcs://"); ok {
+		target = secure
+		config := tlsConfig
+		if config == nil {
+			config = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		creds = credentials.NewTLS(config)
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("连接AI sidecar失败: %w", err)
+	}
+	grpcConns[p.URL] = conn
+	return sidecarpb.NewAnalyzeServiceClient(conn), nil
+}
+
+// withToken 在请求元数据中附带访问令牌
+func (p *GRPCProvider) withToken(ctx context.Context) context.Context {
+	if p.APIKey == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+p.APIKey)
+}
+
+// Complete 调用服务端的 Complete 方法
+func (p *GRPCProvider) Complete(ctx context.Context, systemPrompt, content string) (Completion, error) {
+	client, err := p.client()
+	if err != nil {
+		return Completion{}, err
+	}
+	agent, _ := os.Hostname()
+	resp, err := client.Complete(p.withToken(ctx), &sidecarpb.CompleteRequest{
+		SystemPrompt: systemPrompt,
+		Content:      content,
+		Agent:        agent,
+	})
+	if err != nil {
+		return Completion{}, fromGRPCError(err)
+	}
+	return Completion{
+		Content:          resp.GetContent(),
+		PromptTokens:     int(resp.GetPromptTokens()),
+		CompletionTokens: int(resp.GetCompletionTokens()),
+	}, nil
+}
+
+// Ping 调用服务端的 Ping 方法，服务端所有AI后端均不可用时同样视为不可用
+func (p *GRPCProvider) Ping(ctx context.Context) error {
+	client, err := p.client()
+	if err != nil {
+		return err
+	}
+	if _, err := client.Ping(p.withToken(ctx), &sidecarpb.PingRequest{}); err != nil {
+		return fromGRPCError(err)
+	}
+	return nil
+}
+
+// fromGRPCError 将 gRPC 错误转换为与HTTP后端一致的错误，沿用相同的重试和降级逻辑
+// 服务端预算耗尽或所有后端不可用时还原为 ErrBudgetExhausted / ErrAIUnavailable，由 agent 降级为规则摘要
+func fromGRPCError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.ResourceExhausted:
+		if strings.HasPrefix(st.Message(), ErrBudgetExhausted.Error()) {
+			return fmt.Errorf("%w（sidecar）: %s", ErrBudgetExhausted, st.Message())
+		}
+		return &StatusError{StatusCode: 429}
+	case codes.Unavailable:
+		if strings.HasPrefix(st.Message(), ErrAIUnavailable.Error()) {
+			return fmt.Errorf("%w（sidecar）", ErrAIUnavailable)
+		}
+		return &StatusError{StatusCode: 503}
+	case codes.DeadlineExceeded:
+		return &StatusError{StatusCode: 408}
+	case codes.Unauthenticated:
+		return &StatusError{StatusCode: 401}
+	case codes.PermissionDenied:
+		return &StatusError{StatusCode: 403}
+	case codes.InvalidArgument, codes.Unimplemented:
+		return &StatusError{StatusCode: 400}
+	default:
+		return &StatusError{StatusCode: 500}
+	}
+}
+
+// ToGRPCError 将服务端AI调用的错误转换为 gRPC 状态，供 sidecar 服务端使用
+func ToGRPCError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrBudgetExhausted):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, ErrAIUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
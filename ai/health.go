@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/metrics"
+)
+
+// ErrAIUnavailable 所有AI后端均不可用
+var ErrAIUnavailable = errors.New("AI服务不可用")
+
+// 连续失败多少次后将后端标记为不可用，恢复依赖周期性探测
+const unhealthyAfterFailures = 3
+
+// 单次探测的超时时间
+const probeTimeout = 5 * time.Second
+
+// ProviderHealth AI后端的健康状态
+type ProviderHealth struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Healthy   bool      `json:"healthy"`
+	LastError string    `json:"last_error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+
+	failures int
+}
+
+// HealthChecker 周期性探测AI后端，并根据实际调用结果被动标记故障
+// 后端不可用时直接跳过AI调用，避免每个事件都等待超时
+type HealthChecker struct {
+	cfg       *config.Config
+	providers []Provider
+
+	mu     sync.RWMutex
+	status map[string]*ProviderHealth
+}
+
+// health 当前生效的健康检查，nil表示不检查，所有后端均视为可用
+var health *HealthChecker
+
+// StartHealthCheck 启动AI后端健康检查，interval<=0 或未启用AI时不检查
+func StartHealthCheck(ctx context.Context, cfg *config.Config, interval time.Duration) error {
+	if interval <= 0 || strings.ToLower(cfg.AIEnable) != "true" {
+		return nil
+	}
+	providers, err := NewProviders(cfg)
+	if err != nil {
+		return err
+	}
+
+	h := &HealthChecker{cfg: cfg, providers: providers, status: make(map[string]*ProviderHealth)}
+	for _, p := range providers {
+		h.status[providerKey(p)] = &ProviderHealth{Provider: p.Name(), Model: p.ModelName(), Healthy: true}
+		metrics.AIBackendUp.WithLabelValues(p.Name(), p.ModelName()).Set(1)
+	}
+	health = h
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			h.probe(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+func providerKey(p Provider) string {
+	return p.Name() + "/" + p.ModelName()
+}
+
+// probe 探测所有后端，每次按当前配置创建后端，使轮换后的密钥生效
+func (h *HealthChecker) probe(ctx context.Context) {
+	providers, err := NewProviders(h.cfg)
+	if err != nil {
+		return
+	}
+	for _, p := range providers {
+		probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		err := p.Ping(probeCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		h.set(p, err, true)
+	}
+}
+
+// set 更新后端状态；probe 为true表示主动探测结果，立即生效，否则为实际调用结果，连续失败后才标记不可用
+func (h *HealthChecker) set(p Provider, err error, probe bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.status[providerKey(p)]
+	if !ok {
+		return
+	}
+	s.CheckedAt = time.Now()
+	wasHealthy := s.Healthy
+	if err == nil {
+		s.Healthy, s.LastError, s.failures = true, "", 0
+	} else {
+		s.LastError = err.Error()
+		s.failures++
+		if probe || s.failures >= unhealthyAfterFailures {
+			s.Healthy = false
+		}
+	}
+
+	if wasHealthy != s.Healthy {
+		if s.Healthy {
+			log.Printf("✅ AI后端 %s(%s) 已恢复", s.Provider, s.Model)
+		} else {
+			log.Printf("⚠️ AI后端 %s(%s) 不可用，暂停调用直到探测恢复: %s", s.Provider, s.Model, s.LastError)
+		}
+	}
+	up := 0.0
+	if s.Healthy {
+		up = 1
+	}
+	metrics.AIBackendUp.WithLabelValues(s.Provider, s.Model).Set(up)
+}
+
+// healthy 返回后端当前是否可用
+func (h *HealthChecker) healthy(p Provider) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.status[providerKey(p)]
+	return !ok || s.Healthy
+}
+
+// record 记录一次实际调用的结果
+func (h *HealthChecker) record(p Provider, err error) {
+	if h == nil {
+		return
+	}
+	h.set(p, err, false)
+}
+
+// HealthStatus 返回各AI后端的健康状态，未启用健康检查时返回nil
+func HealthStatus() []ProviderHealth {
+	if health == nil {
+		return nil
+	}
+	health.mu.RLock()
+	defer health.mu.RUnlock()
+	result := make([]ProviderHealth, 0, len(health.providers))
+	for _, p := range health.providers {
+		result = append(result, *health.status[providerKey(p)])
+	}
+	return result
+}
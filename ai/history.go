@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/esclient"
+)
+
+// 单条历史分析在提示词中保留的最大字符数
+const historyResultMaxRunes = 600
+
+// 检索历史事件的超时时间，超时后不带历史上下文继续分析
+const historySearchTimeout = 5 * time.Second
+
+// historyPrompt 附带历史事件时追加在系统提示词后的说明
+const historyPrompt = `
+用户消息末尾的"相似历史事件"是过去出现过的相似事件及当时的分析或处理记录，如果与本次问题相符，请引用日期和处理方式（例如"该问题曾于某日出现，当时的处理方式是……"）；如果不相符请忽略。
+`
+
+// historyHeader 用户提示词中历史事件部分的标题
+const historyHeader = "\n\n相似历史事件:\n"
+
+// HistorySearcher 检索相似历史事件
+type HistorySearcher interface {
+	SearchSimilar(ctx context.Context, templateID, content string, limit int) ([]esclient.LogEvent, error)
+}
+
+var (
+	history      HistorySearcher
+	historyLimit int
+)
+
+// SetHistory 设置历史事件检索，分析前将最相似的 limit 个历史事件附加到提示词中
+// searcher 为nil或 limit<=0 时不检索
+func SetHistory(searcher HistorySearcher, limit int) {
+	if searcher == nil || limit <= 0 {
+		history = nil
+		return
+	}
+	history, historyLimit = searcher, limit
+}
+
+// historyContext 检索与事件相似的历史事件，分别返回追加到系统提示词和用户提示词的片段
+// 没有可用的历史事件或检索失败时返回空字符串
+func historyContext(event collector.LogEvent) (string, string) {
+	if history == nil {
+		return "", ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), historySearchTimeout)
+	defer cancel()
+
+	incidents, err := history.SearchSimilar(ctx, event.TemplateID, event.RawText, historyLimit)
+	if err != nil {
+		log.Printf("检索相似历史事件失败，跳过历史上下文: %v", err)
+		return "", ""
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, incident := range incidents {
+		// 被评价为有误的分析不再作为参考，只保留运维人员的更正
+		if incident.Feedback == esclient.FeedbackWrong {
+			incident.AiResult = ""
+		}
+		if incident.OperatorNote == "" && !usefulResult(incident.AiResult) {
+			continue
+		}
+		n++
+		fmt.Fprintf(&b, "\n%d. %s 主机 %s：%s\n", n, incident.Timestamp.Format("2006-01-02"), incident.Host, firstLine(incident.Content))
+		if incident.OperatorNote != "" {
+			fmt.Fprintf(&b, "处理记录: %s\n", incident.OperatorNote)
+		}
+		if usefulResult(incident.AiResult) {
+			verified := ""
+			if incident.Feedback == esclient.FeedbackHelpful {
+				verified = "（经运维确认有效）"
+			}
+			fmt.Fprintf(&b, "当时的分析%s: %s\n", verified, truncateRunes(incident.AiResult, historyResultMaxRunes))
+		}
+	}
+	if n == 0 {
+		return "", ""
+	}
+	return historyPrompt, historyHeader + b.String()
+}
+
+// usefulResult 过滤掉未启用、失败或降级的分析结果
+func usefulResult(result string) bool {
+	return result != "" &&
+		!strings.HasPrefix(result, "（AI分析未启用") &&
+		!strings.HasPrefix(result, "AI分析失败") &&
+		!strings.HasPrefix(result, "（AI预算已用尽") &&
+		!strings.HasPrefix(result, "（AI服务不可用") &&
+		!strings.HasPrefix(result, "（AI分析结果未通过安全校验") &&
+		!strings.HasPrefix(result, "（AI分析进行中")
+}
+
+func firstLine(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	return line
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"log-ai-analyzer/config"
+)
+
+// translatePrompt 按渠道语言翻译分析结果使用的系统提示词
+const translatePrompt = `
+你是一名专业的技术翻译。请将用户提供的日志分析报告完整翻译为 %[1]s，
+保留原有的 markdown 格式、命令、配置、路径和日志原文，不要增删内容，只输出译文。
+Translate the report into %[1]s. Output only the translation.
+`
+
+// translations 翻译结果缓存，同一分析结果发送到多个相同语言的渠道时只翻译一次
+var translations = &resultCache{entries: make(map[string]string)}
+
+// languageInstruction 返回追加到系统提示词后的输出语言要求，未配置语言时返回空字符串
+func languageInstruction(lang string) string {
+	if lang == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n请使用 %[1]s 撰写全部输出内容。Write the entire response in %[1]s.\n", lang)
+}
+
+// Localize 将分析结果转换为渠道配置的输出语言
+// 渠道未单独配置语言或与全局语言相同时原样返回，翻译失败时也返回原文
+func Localize(cfg *config.Config, text, channel string) string {
+	lang := cfg.OutputLanguage(channel)
+	if text == "" || strings.ToLower(cfg.AIEnable) != "true" || strings.EqualFold(lang, cfg.AIOutputLanguage) {
+		return text
+	}
+
+	sum := sha1.Sum([]byte(text))
+	key := lang + ":" + hex.EncodeToString(sum[:])
+	if cached, ok := translations.get(key); ok {
+		return cached
+	}
+
+	translated, err := complete(cfg, fmt.Sprintf(translatePrompt, lang), text)
+	if err != nil {
+		log.Printf("翻译分析结果到 %s 失败，发送原文: %v", lang, err)
+		return text
+	}
+	translations.put(key, translated)
+	return translated
+}
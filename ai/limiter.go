@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/metrics"
+)
+
+// Limiter 所有工作协程共享的AI请求限流器
+// 同时限制并发中的请求数和每分钟请求数，超出时排队等待，排队超时则放弃本次分析
+type Limiter struct {
+	sem          chan struct{} // 并发槽位，nil表示不限制并发
+	rpm          int           // 每分钟请求数，0表示不限制
+	queueTimeout time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// ErrAIThrottled 限流排队超时，与后端是否可用无关，不计入健康检查也不触发故障切换
+var ErrAIThrottled = errors.New("AI请求限流排队超时")
+
+// limiter 当前生效的限流器，nil表示不限流
+var limiter *Limiter
+
+// SetLimiter 设置全局AI请求限流，maxInFlight 和 rpm 均为0时不限流
+func SetLimiter(maxInFlight, rpm int, queueTimeout time.Duration) {
+	if maxInFlight <= 0 && rpm <= 0 {
+		limiter = nil
+		return
+	}
+	l := &Limiter{rpm: rpm, queueTimeout: queueTimeout, tokens: float64(rpm), last: time.Now()}
+	if maxInFlight > 0 {
+		l.sem = make(chan struct{}, maxInFlight)
+	}
+	limiter = l
+}
+
+// Acquire 获取一次请求许可，返回的 release 必须在请求结束后调用；排队最长等待队列超时，
+// 超时返回 ErrAIThrottled，ctx 结束时返回 ctx 的错误
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	parent := ctx
+	if l.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.queueTimeout)
+		defer cancel()
+	}
+	defer func() {
+		metrics.AILimiterWaitDuration.Observe(time.Since(start).Seconds())
+		metrics.FlowThrottledSeconds.WithLabelValues("ai").Add(time.Since(start).Seconds())
+		if errors.Is(err, ErrAIThrottled) {
+			metrics.AILimiterTimeoutCount.Inc()
+		}
+	}()
+
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-ctx.Done():
+			if parent.Err() != nil {
+				return nil, parent.Err()
+			}
+			return nil, fmt.Errorf("%w: 等待AI并发槽位超过 %s", ErrAIThrottled, l.queueTimeout)
+		}
+	}
+	release = func() {
+		metrics.AIRequestsInFlight.Dec()
+		if l.sem != nil {
+			<-l.sem
+		}
+	}
+	metrics.AIRequestsInFlight.Inc()
+
+	if err := l.waitRate(ctx); err != nil {
+		release()
+		if parent.Err() != nil {
+			return nil, parent.Err()
+		}
+		return nil, err
+	}
+	return release, nil
+}
+
+// waitRate 按令牌桶等待每分钟请求数配额
+func (l *Limiter) waitRate(ctx context.Context) error {
+	if l.rpm <= 0 {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Minutes() * float64(l.rpm)
+		if l.tokens > float64(l.rpm) {
+			l.tokens = float64(l.rpm)
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - l.tokens) / float64(l.rpm) * float64(time.Minute))
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: 等待AI请求速率配额超过 %s", ErrAIThrottled, l.queueTimeout)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/config"
+)
+
+// 默认的 Ollama 服务地址
+const defaultOllamaURL = "http://localhost:11434"
+
+// OllamaProvider 本地 Ollama 后端，无需API Key，日志不会离开本机
+type OllamaProvider struct {
+	URL    string
+	Model  string
+	Params config.ModelParams
+}
+
+// NewOllamaProvider 创建 Ollama 后端，url为空时使用本机默认地址
+func NewOllamaProvider(url, model string, params config.ModelParams) *OllamaProvider {
+	if url == "" {
+		url = defaultOllamaURL
+	}
+	return &OllamaProvider{URL: strings.TrimRight(url, "/"), Model: model, Params: params}
+}
+
+// Name 返回后端名称
+func (p *OllamaProvider) Name() string {
+	return "ollama"
+}
+
+// ModelName 返回使用的模型名称
+func (p *OllamaProvider) ModelName() string {
+	return p.Model
+}
+
+// Timeout 返回单次请求的超时时间，本地模型推理较慢，默认2分钟
+func (p *OllamaProvider) Timeout() time.Duration {
+	if p.Params.Timeout > 0 {
+		return p.Params.Timeout
+	}
+	return 2 * time.Minute
+}
+
+// options 返回请求的采样参数，max_tokens 对应 Ollama 的 num_predict
+func (p *OllamaProvider) options() map[string]interface{} {
+	options := map[string]interface{}{
+		"temperature": p.Params.Temperature,
+	}
+	if p.Params.TopP > 0 {
+		options["top_p"] = p.Params.TopP
+	}
+	if p.Params.MaxTokens > 0 {
+		options["num_predict"] = p.Params.MaxTokens
+	}
+	return options
+}
+
+// ollamaChunk Ollama /api/chat 流式响应中的一行
+type ollamaChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	Error           string `json:"error"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+// Ping 请求 /api/tags 接口探测 Ollama 服务是否可用
+func (p *OllamaProvider) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL+"/api/tags", nil)
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	return nil
+}
+
+// Complete 调用 Ollama /api/chat 接口，流式响应为逐行JSON，关闭流式请求时使用 Chat
+func (p *OllamaProvider) Complete(ctx context.Context, systemPrompt, content string) (Completion, error) {
+	if !p.Params.Stream {
+		return p.Chat(ctx, []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: content},
+		}, nil)
+	}
+	data := map[string]interface{}{
+		"model": p.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemPrompt},
+			{"role": "user", "content": content},
+		},
+		"stream":  true,
+		"options": p.options(),
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Completion{}, fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL+"/api/chat", bytes.NewBuffer(body))
+	if err != nil {
+		return Completion{}, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// 本地模型推理较慢，超时时间由调用方的上下文控制
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Completion{}, newStatusError(resp)
+	}
+
+	var result strings.Builder
+	var completion Completion
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var chunk ollamaChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			return Completion{}, fmt.Errorf("Ollama返回错误: %s", chunk.Error)
+		}
+		result.WriteString(chunk.Message.Content)
+		if chunk.Done {
+			completion.PromptTokens = chunk.PromptEvalCount
+			completion.CompletionTokens = chunk.EvalCount
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Completion{}, fmt.Errorf("读取Ollama响应失败: %w", err)
+	}
+
+	completion.Content = result.String()
+	return completion, nil
+}
+
+// ollamaMessage Ollama /api/chat 接口的消息格式，工具调用参数为JSON对象
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+// ollamaToolCall Ollama 的工具调用格式
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// Chat 以非流式方式调用 Ollama /api/chat 接口，支持工具调用（需模型本身支持）
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message, tools []Tool) (Completion, error) {
+	msgs := make([]ollamaMessage, 0, len(messages))
+	for _, m := range messages {
+		msg := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, call := range m.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		msgs = append(msgs, msg)
+	}
+	data := map[string]interface{}{
+		"model":    p.Model,
+		"messages": msgs,
+		"stream":   false,
+		"options":  p.options(),
+	}
+	if len(tools) > 0 {
+		data["tools"] = toolDefinitions(tools)
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Completion{}, fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL+"/api/chat", bytes.NewBuffer(body))
+	if err != nil {
+		return Completion{}, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Completion{}, newStatusError(resp)
+	}
+
+	var result struct {
+		Message         ollamaMessage `json:"message"`
+		Error           string        `json:"error"`
+		PromptEvalCount int           `json:"prompt_eval_count"`
+		EvalCount       int           `json:"eval_count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Completion{}, fmt.Errorf("解析Ollama响应失败: %w", err)
+	}
+	if result.Error != "" {
+		return Completion{}, fmt.Errorf("Ollama返回错误: %s", result.Error)
+	}
+
+	completion := Completion{
+		Content:          result.Message.Content,
+		PromptTokens:     result.PromptEvalCount,
+		CompletionTokens: result.EvalCount,
+	}
+	// Ollama 的工具调用没有ID，按顺序生成
+	for i, tc := range result.Message.ToolCalls {
+		completion.ToolCalls = append(completion.ToolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+	return completion, nil
+}
@@ -0,0 +1,277 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/config"
+)
+
+// OpenAIProvider OpenAI 兼容的 Chat Completions 接口（DeepSeek、vLLM、LM Studio 等）
+// APIKey 为空时不发送 Authorization 头，可直接对接本地部署的兼容服务
+type OpenAIProvider struct {
+	URL    string
+	APIKey string
+	Model  string
+	Params config.ModelParams
+}
+
+// NewOpenAIProvider 创建 OpenAI 兼容后端
+func NewOpenAIProvider(url, apiKey, model string, params config.ModelParams) *OpenAIProvider {
+	return &OpenAIProvider{URL: url, APIKey: apiKey, Model: model, Params: params}
+}
+
+// Name 返回后端名称
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+// ModelName 返回使用的模型名称
+func (p *OpenAIProvider) ModelName() string {
+	return p.Model
+}
+
+// Timeout 返回单次请求的超时时间，默认30秒
+func (p *OpenAIProvider) Timeout() time.Duration {
+	if p.Params.Timeout > 0 {
+		return p.Params.Timeout
+	}
+	return 30 * time.Second
+}
+
+// setParams 在请求中设置采样参数，未配置的参数使用模型默认值
+func (p *OpenAIProvider) setParams(data map[string]interface{}) {
+	data["temperature"] = p.Params.Temperature
+	if p.Params.TopP > 0 {
+		data["top_p"] = p.Params.TopP
+	}
+	if p.Params.MaxTokens > 0 {
+		data["max_tokens"] = p.Params.MaxTokens
+	}
+}
+
+// Ping 请求 /models 接口探测服务是否可用
+// 未实现该接口的兼容服务返回404也视为可用，鉴权失败、限额和服务端错误视为不可用
+func (p *OpenAIProvider) Ping(ctx context.Context) error {
+	url := strings.TrimSuffix(strings.TrimRight(p.URL, "/"), "/chat/completions") + "/models"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return newStatusError(resp)
+	}
+	return nil
+}
+
+// Complete 以流式方式调用 Chat Completions 接口并拼接结果，关闭流式请求时使用 Chat
+func (p *OpenAIProvider) Complete(ctx context.Context, systemPrompt, content string) (Completion, error) {
+	if !p.Params.Stream {
+		return p.Chat(ctx, []Message{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: content},
+		}, nil)
+	}
+	data := map[string]interface{}{
+		"model": p.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": systemPrompt},
+			{"role": "user", "content": content},
+		},
+		"stream": true,
+		// 要求在流的最后返回token用量，用于预算控制
+		"stream_options": map[string]bool{"include_usage": true},
+	}
+	p.setParams(data)
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Completion{}, fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return Completion{}, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Completion{}, newStatusError(resp)
+	}
+
+	result := []string{}
+	var completion Completion
+	reader := bufio.NewReader(resp.Body)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		// 解析 JSON 数据
+		if strings.HasPrefix(line, "data: ") {
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(line[6:]), &payload); err == nil {
+				// 最后一个分片携带token用量
+				if usage, ok := payload["usage"].(map[string]interface{}); ok {
+					if n, ok := usage["prompt_tokens"].(float64); ok {
+						completion.PromptTokens = int(n)
+					}
+					if n, ok := usage["completion_tokens"].(float64); ok {
+						completion.CompletionTokens = int(n)
+					}
+				}
+
+				// 检查是否有 choices 字段
+				choices, ok := payload["choices"]
+				if !ok || choices == nil {
+					continue
+				}
+
+				choicesArray, ok := choices.([]interface{})
+				if !ok || len(choicesArray) == 0 {
+					continue
+				}
+
+				choice, ok := choicesArray[0].(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				delta, ok := choice["delta"].(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				if content, ok := delta["content"].(string); ok {
+					result = append(result, content)
+				}
+			}
+		}
+	}
+
+	completion.Content = strings.Join(result, "")
+	return completion, nil
+}
+
+// openAIMessage Chat Completions 接口的消息格式
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall Chat Completions 接口的工具调用格式，参数为JSON字符串
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// Chat 以非流式方式调用 Chat Completions 接口，支持工具调用
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []Tool) (Completion, error) {
+	msgs := make([]openAIMessage, 0, len(messages))
+	for _, m := range messages {
+		msg := openAIMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			tc := openAIToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = string(call.Arguments)
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		msgs = append(msgs, msg)
+	}
+	data := map[string]interface{}{
+		"model":    p.Model,
+		"messages": msgs,
+	}
+	p.setParams(data)
+	if len(tools) > 0 {
+		data["tools"] = toolDefinitions(tools)
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return Completion{}, fmt.Errorf("序列化请求数据失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return Completion{}, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Completion{}, fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Completion{}, newStatusError(resp)
+	}
+
+	var result struct {
+		Choices []struct {
+			Message openAIMessage `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Completion{}, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return Completion{}, fmt.Errorf("AI响应中没有 choices")
+	}
+
+	msg := result.Choices[0].Message
+	completion := Completion{
+		Content:          msg.Content,
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+	}
+	for _, tc := range msg.ToolCalls {
+		completion.ToolCalls = append(completion.ToolCalls, ToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: json.RawMessage(tc.Function.Arguments),
+		})
+	}
+	return completion, nil
+}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+)
+
+// defaultUserPrompt 默认的用户提示词模板，直接发送日志内容
+const defaultUserPrompt = `{{.RawText}}`
+
+// promptFile 从文件加载的提示词模板，文件修改后自动重新加载
+type promptFile struct {
+	path     string
+	mu       sync.Mutex
+	tmpl     *template.Template
+	modTime  time.Time
+	fallback *template.Template
+}
+
+// promptFuncs 提示词模板中可用的函数
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+var (
+	promptFilesMu sync.Mutex
+	promptFiles   = make(map[string]*promptFile)
+)
+
+// loadPromptFile 返回指定路径的提示词模板，同一路径共享一个实例
+func loadPromptFile(path, fallback string) *promptFile {
+	promptFilesMu.Lock()
+	defer promptFilesMu.Unlock()
+
+	key := path + "\x00" + fallback
+	if pf, ok := promptFiles[key]; ok {
+		return pf
+	}
+	pf := &promptFile{
+		path:     path,
+		fallback: template.Must(template.New("default").Funcs(promptFuncs).Parse(fallback)),
+	}
+	promptFiles[key] = pf
+	return pf
+}
+
+// template 返回当前生效的模板，文件有更新时重新解析
+// 文件不存在或解析失败时继续使用上一个有效版本，从未加载成功则使用内置默认模板
+func (pf *promptFile) template() *template.Template {
+	if pf.path == "" {
+		return pf.fallback
+	}
+
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	info, err := os.Stat(pf.path)
+	if err != nil {
+		if pf.tmpl == nil {
+			log.Printf("读取提示词模板失败，使用内置模板: %v", err)
+			pf.tmpl = pf.fallback
+		}
+		return pf.tmpl
+	}
+	if pf.tmpl != nil && !info.ModTime().After(pf.modTime) {
+		return pf.tmpl
+	}
+
+	data, err := os.ReadFile(pf.path)
+	if err == nil {
+		var tmpl *template.Template
+		if tmpl, err = template.New(pf.path).Funcs(promptFuncs).Parse(string(data)); err == nil {
+			if pf.tmpl != nil {
+				log.Printf("提示词模板已重新加载: %s", pf.path)
+			}
+			pf.tmpl = tmpl
+			pf.modTime = info.ModTime()
+			return pf.tmpl
+		}
+	}
+
+	log.Printf("加载提示词模板 %s 失败，继续使用之前的版本: %v", pf.path, err)
+	if pf.tmpl == nil {
+		pf.tmpl = pf.fallback
+	}
+	// 记录修改时间，避免每次调用都重复解析同一个错误版本
+	pf.modTime = info.ModTime()
+	return pf.tmpl
+}
+
+// render 使用事件字段渲染模板
+func (pf *promptFile) render(event collector.LogEvent) (string, error) {
+	var buf bytes.Buffer
+	if err := pf.template().Execute(&buf, event); err != nil {
+		return "", fmt.Errorf("渲染提示词模板失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// buildPrompts 生成系统提示词和用户提示词
+// 命中提示词路由时使用路由指定的系统提示词，否则使用 AI_SYSTEM_PROMPT_FILE 或内置提示词
+func buildPrompts(cfg *config.Config, event collector.LogEvent) (string, string, error) {
+	system, user, err := TrainingPrompts(cfg, event)
+	if err != nil {
+		return "", "", err
+	}
+	system += runbookContext(cfg, event)
+	system += languageInstruction(cfg.AIOutputLanguage)
+	return system, user, nil
+}
+
+// TrainingPrompts 生成用于导出微调数据的系统提示词和用户提示词
+// 与 buildPrompts 使用相同的模板和路由，但不包含运维手册、输出语言等随部署变化的附加内容
+func TrainingPrompts(cfg *config.Config, event collector.LogEvent) (string, string, error) {
+	systemFile := loadPromptFile(cfg.AISystemPromptFile, systemPrompt)
+	if route := routeEvent(cfg.AIPromptRoutesFile, event); route != nil {
+		systemFile = route.systemPromptFile()
+	}
+
+	system, err := systemFile.render(event)
+	if err != nil {
+		return "", "", err
+	}
+	user, err := loadPromptFile(cfg.AIUserPromptFile, defaultUserPrompt).render(event)
+	if err != nil {
+		return "", "", err
+	}
+	return system, user, nil
+}
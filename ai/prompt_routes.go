@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+)
+
+// PromptRoute 提示词路由规则，按标签、模板ID、文件路径为不同类型的日志选择专用提示词
+// 同一规则内不同类型的条件需同时满足，同类条件满足任意一个即可；规则按顺序匹配第一条
+type PromptRoute struct {
+	Name             string   `json:"name"`
+	Tags             []string `json:"tags,omitempty"`         // 事件包含任意一个标签（不区分大小写）
+	TemplateIDs      []string `json:"template_ids,omitempty"` // 事件模板ID为其中之一
+	Files            []string `json:"files,omitempty"`        // 文件路径或文件名匹配任意一个通配符
+	SystemPrompt     string   `json:"system_prompt,omitempty"`
+	SystemPromptFile string   `json:"system_prompt_file,omitempty"`
+}
+
+// matches 判断事件是否命中该路由
+func (r PromptRoute) matches(event collector.LogEvent) bool {
+	if len(r.Tags) == 0 && len(r.TemplateIDs) == 0 && len(r.Files) == 0 {
+		return false
+	}
+	if len(r.Tags) > 0 && !matchAnyTag(r.Tags, event.Tags) {
+		return false
+	}
+	if len(r.TemplateIDs) > 0 && !containsString(r.TemplateIDs, event.TemplateID) {
+		return false
+	}
+	if len(r.Files) > 0 && !matchAnyFile(r.Files, event.FilePath) {
+		return false
+	}
+	return true
+}
+
+// systemPromptFile 返回路由对应的系统提示词模板
+func (r PromptRoute) systemPromptFile() *promptFile {
+	fallback := r.SystemPrompt
+	if fallback == "" {
+		fallback = systemPrompt
+	}
+	return loadPromptFile(r.SystemPromptFile, fallback)
+}
+
+func matchAnyTag(want, tags []string) bool {
+	for _, w := range want {
+		for _, t := range tags {
+			if strings.EqualFold(w, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchAnyFile(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultPromptRoutes 内置的提示词路由：内核调用栈、Java异常、Nginx错误
+var defaultPromptRoutes = []PromptRoute{
+	{
+		Name: "kernel",
+		Tags: []string{"Blocked for more than", "hung_task_timeout_secs", "Segmentation fault", "core dumped", "OOM", "OutOfMemory"},
+		SystemPrompt: `
+你是一位资深的 Linux 内核工程师，擅长分析内核日志、Call Trace 和 hung task / OOM / 段错误等问题。
+请你根据以下内核日志：
+1. 解读 Call Trace 中的关键函数调用链，指出阻塞或崩溃发生的位置及所属子系统（调度、内存、文件系统、块设备、网络、驱动等）。
+2. 结合寄存器、进程名、阻塞时长等信息分析根本原因，区分硬件、驱动、内核缺陷与资源耗尽等情况。
+3. 给出排查命令（如 dmesg、/proc、sysrq、perf、crash 等）和修复或规避方案（内核参数、驱动/内核升级、资源调整）。
+4. 评估对业务的影响，并说明是否需要紧急处理（如重启节点、迁移业务）。
+请输出清晰的分析报告。
+`,
+	},
+	{
+		Name: "java",
+		Tags: []string{"Exception"},
+		SystemPrompt: `
+你是一位资深的 Java 后端与 JVM 专家，擅长分析异常堆栈和应用故障。
+请你根据以下日志：
+1. 找出最根本的异常（关注 Caused by 链的最底层），说明异常类型和抛出位置。
+2. 区分业务代码与框架/依赖库中的堆栈帧，定位最可能出问题的业务代码。
+3. 分析可能的原因（空指针、连接池耗尽、超时、序列化、类加载、内存溢出等），必要时结合 JVM 参数和 GC 情况。
+4. 给出具体的修复建议、排查命令（如 jstack、jmap、arthas）和预防措施。
+请输出清晰的分析报告。
+`,
+	},
+	{
+		Name:  "nginx",
+		Files: []string{"*nginx*"},
+		SystemPrompt: `
+你是一位资深的 Nginx 与 Web 网关运维专家。
+请你根据以下 Nginx 日志：
+1. 识别错误类型（upstream 超时/拒绝连接、4xx/5xx、worker 连接数耗尽、SSL 握手失败、权限或文件不存在等）。
+2. 分析涉及的 upstream、请求路径和客户端，判断问题出在网关本身还是后端服务。
+3. 给出具体的配置调整建议（如 proxy_*_timeout、worker_connections、keepalive、limit_req）和排查命令。
+4. 评估对用户请求的影响范围。
+请输出清晰的分析报告。
+`,
+	},
+}
+
+// promptRoutes 路由表文件，文件修改后自动重新加载
+type promptRoutes struct {
+	path    string
+	mu      sync.Mutex
+	routes  []PromptRoute
+	modTime time.Time
+	loaded  bool
+}
+
+var (
+	promptRoutesMu    sync.Mutex
+	promptRoutesFiles = make(map[string]*promptRoutes)
+)
+
+// loadPromptRoutes 返回指定路径的路由表，路径为空时使用内置路由
+func loadPromptRoutes(path string) []PromptRoute {
+	if path == "" {
+		return defaultPromptRoutes
+	}
+
+	promptRoutesMu.Lock()
+	pr, ok := promptRoutesFiles[path]
+	if !ok {
+		pr = &promptRoutes{path: path}
+		promptRoutesFiles[path] = pr
+	}
+	promptRoutesMu.Unlock()
+
+	return pr.get()
+}
+
+// get 返回当前的路由表，文件有更新时重新加载，加载失败时继续使用之前的版本
+func (pr *promptRoutes) get() []PromptRoute {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	info, err := os.Stat(pr.path)
+	if err == nil && pr.loaded && !info.ModTime().After(pr.modTime) {
+		return pr.routes
+	}
+	if err == nil {
+		pr.modTime = info.ModTime()
+		var routes []PromptRoute
+		if routes, err = parsePromptRoutes(pr.path); err == nil {
+			pr.routes = routes
+			pr.loaded = true
+			return pr.routes
+		}
+	}
+
+	log.Printf("加载提示词路由表 %s 失败: %v", pr.path, err)
+	if !pr.loaded {
+		pr.routes = defaultPromptRoutes
+		pr.loaded = true
+	}
+	return pr.routes
+}
+
+// parsePromptRoutes 解析JSON格式的路由表
+func parsePromptRoutes(path string) ([]PromptRoute, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var routes []PromptRoute
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("解析路由表失败: %w", err)
+	}
+	for _, r := range routes {
+		for _, pattern := range r.Files {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("路由 %s 的文件通配符 %q 无效: %w", r.Name, pattern, err)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// routeEvent 为事件选择提示词路由，没有命中时返回nil
+func routeEvent(routesPath string, event collector.LogEvent) *PromptRoute {
+	routes := loadPromptRoutes(routesPath)
+	for i := range routes {
+		if routes[i].matches(event) {
+			return &routes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/config"
+)
+
+// Completion 模型的一次完整回复及token用量
+type Completion struct {
+	Content          string
+	PromptTokens     int
+	CompletionTokens int
+	ToolCalls        []ToolCall // 模型请求的工具调用，仅 Chat 返回
+}
+
+// Message 多轮对话中的一条消息
+type Message struct {
+	Role       string // system、user、assistant、tool
+	Content    string
+	ToolCalls  []ToolCall // assistant 消息中模型请求的工具调用
+	ToolCallID string     // tool 消息对应的工具调用ID
+}
+
+// ToolCall 模型请求的一次工具调用
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// Provider AI分析后端
+type Provider interface {
+	// Name 返回后端名称
+	Name() string
+	// ModelName 返回使用的模型名称
+	ModelName() string
+	// Complete 发送系统提示词和日志内容，返回模型的完整回复
+	Complete(ctx context.Context, systemPrompt, content string) (Completion, error)
+	// Ping 探测后端是否可用，不消耗token
+	Ping(ctx context.Context) error
+	// Timeout 返回单次请求的超时时间
+	Timeout() time.Duration
+}
+
+// ToolProvider 支持工具调用（function calling）的AI后端
+type ToolProvider interface {
+	Provider
+	// Chat 发送多轮对话和可用工具，模型需要更多上下文时返回的 ToolCalls 非空
+	Chat(ctx context.Context, messages []Message, tools []Tool) (Completion, error)
+}
+
+// NewProvider 根据配置创建主AI后端
+func NewProvider(cfg *config.Config) (Provider, error) {
+	return newProvider(cfg.AIProvider, cfg.AIAPIURL, cfg.Secret("AI_API_KEY"), cfg.AIModel, cfg.AIModelParams)
+}
+
+// NewProviders 根据配置创建按优先级排列的AI后端链：主后端在前，备用后端在后
+// 主后端不可用或额度耗尽时依次切换到后面的后端
+func NewProviders(cfg *config.Config) ([]Provider, error) {
+	primary, err := NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+	providers := []Provider{primary}
+
+	if cfg.AIFallbackProvider != "" {
+		fallback, err := newProvider(cfg.AIFallbackProvider, cfg.AIFallbackAPIURL, cfg.Secret("AI_FALLBACK_API_KEY"), cfg.AIFallbackModel, cfg.AIFallbackParams)
+		if err != nil {
+			return nil, fmt.Errorf("创建备用AI后端失败: %w", err)
+		}
+		providers = append(providers, fallback)
+	}
+	return providers, nil
+}
+
+func newProvider(kind, url, apiKey, model string, params config.ModelParams) (Provider, error) {
+	switch strings.ToLower(kind) {
+	case "", "openai":
+		return NewOpenAIProvider(url, apiKey, model, params), nil
+	case "ollama":
+		return NewOllamaProvider(url, model, params), nil
+	case "grpc":
+		return NewGRPCProvider(url, apiKey, model, params.Timeout), nil
+	default:
+		return nil, fmt.Errorf("不支持的AI后端: %s", kind)
+	}
+}
@@ -0,0 +1,30 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+
+	"log-ai-analyzer/collector"
+)
+
+// relatedPrompt 附带同一请求的其他日志时追加在系统提示词后的说明
+const relatedPrompt = `
+用户消息末尾的"同一请求在其他日志中的事件"与本事件的 TraceID 相同，是同一个请求经过的其他服务（如网关、后端）记录的日志。请结合这些日志判断故障发生在请求链路的哪一环，说明根因所在的主机和日志文件。
+`
+
+// relatedHeader 用户提示词中关联事件部分的标题
+const relatedHeader = "\n\n同一请求在其他日志中的事件（TraceID: %s）:\n"
+
+// relatedContext 生成事件关联的同一请求其他日志的上下文，分别返回追加到系统提示词和用户提示词的片段
+func relatedContext(event collector.LogEvent) (string, string) {
+	if len(event.Related) == 0 {
+		return "", ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, relatedHeader, event.TraceID)
+	for i, r := range event.Related {
+		fmt.Fprintf(&b, "%d. %s 主机 %s %s 第 %d 行（严重性%d）：%s\n",
+			i+1, r.Timestamp, r.Host, r.FilePath, r.LineNumber, r.SeverityScore, r.Summary)
+	}
+	return relatedPrompt, b.String()
+}
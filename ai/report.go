@@ -0,0 +1,26 @@
+package ai
+
+import (
+	"strings"
+
+	"log-ai-analyzer/config"
+)
+
+// reportSystemPrompt 汇总报告使用的系统提示词
+const reportSystemPrompt = `
+你是一位资深的运维负责人，需要向管理层汇报一个值班周期内的系统日志异常情况。
+请根据提供的统计数据撰写一份简洁的中文汇总报告，包括：
+1. 总体概况：事件总量、高严重性事件数量、与上一周期相比的变化。
+2. 主要问题：按影响排序列出最重要的3-5个问题，说明涉及的主机和可能原因。
+3. 趋势：哪些问题在增加、哪些是新出现的、事件集中在哪些时段。
+4. 建议措施：给出需要优先处理的行动项。
+报告面向管理层阅读，避免冗长的技术细节，使用 markdown 格式，总长度不超过800字。
+`
+
+// SummarizeReport 根据周期统计数据生成AI汇总报告
+func SummarizeReport(cfg *config.Config, stats string) (string, error) {
+	if strings.ToLower(cfg.AIEnable) != "true" {
+		return "", nil
+	}
+	return complete(cfg, reportSystemPrompt+languageInstruction(cfg.AIOutputLanguage), stats)
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 重试策略参数
+const (
+	maxAttempts   = 3
+	baseBackoff   = time.Second
+	maxBackoff    = 20 * time.Second
+	maxRetryAfter = time.Minute // Retry-After 超过该值时不再等待
+)
+
+// StatusError AI服务返回的非200状态码
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // 服务端通过 Retry-After 要求的等待时间
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("AI服务返回错误状态码: %d", e.StatusCode)
+}
+
+// newStatusError 根据HTTP响应创建状态码错误，并解析 Retry-After
+func newStatusError(resp *http.Response) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// isRetryable 判断错误是否值得重试
+// 网络错误、429、408和5xx可以重试，其余4xx（如认证失败、请求格式错误）重试也不会成功
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// sidecar 服务端预算耗尽或后端全部不可用，重试也不会成功
+	if errors.Is(err, ErrBudgetExhausted) || errors.Is(err, ErrAIUnavailable) {
+		return false
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch statusErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusRequestTimeout:
+		return true
+	default:
+		return statusErr.StatusCode >= 500
+	}
+}
+
+// retryDelay 计算第attempt次重试前的等待时间
+// 服务端给出 Retry-After 时以其为准，否则使用带完全抖动的指数退避
+func retryDelay(err error, attempt int) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter
+	}
+	backoff := baseBackoff << uint(attempt-1)
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff))) + baseBackoff/2
+}
+
+// sleepContext 等待指定时间，上下文结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
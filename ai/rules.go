@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+)
+
+// 单个事件最多列出的离线规则数量
+const maxOfflineRules = 3
+
+// OfflineRule 离线分析规则：日志内容匹配 Pattern（正则，不区分大小写）时给出已知原因和处理建议
+// AI未启用或不可用时，告警仍能携带可执行的处理建议
+type OfflineRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Cause       string `json:"cause"`
+	Remediation string `json:"remediation"`
+
+	re *regexp.Regexp
+}
+
+// defaultOfflineRules 内置的离线规则：内存溢出、hung task、段错误、磁盘已满
+var defaultOfflineRules = mustCompileRules([]OfflineRule{
+	{
+		Name:    "oom",
+		Pattern: `out of memory|oom-killer|oom_reaper|killed process \d+|OutOfMemoryError`,
+		Cause:   "系统或容器内存耗尽，内核 OOM Killer 终止了占用内存最多的进程（Java 应用为堆内存溢出）。",
+		Remediation: "1. 执行 `dmesg -T | grep -i -A5 oom` 确认被终止的进程及其内存占用；\n" +
+			"2. 使用 `free -h`、`ps aux --sort=-rss | head` 检查当前内存使用，排查内存泄漏；\n" +
+			"3. 调整服务或容器的内存限制（cgroup memory.max、-Xmx），必要时扩容或限制并发。",
+	},
+	{
+		Name:    "hung_task",
+		Pattern: `blocked for more than \d+ seconds|hung_task_timeout_secs`,
+		Cause:   "进程长时间处于不可中断睡眠（D 状态），通常由磁盘/NFS IO 卡住、存储故障或内核锁竞争引起。",
+		Remediation: "1. 执行 `ps -eo pid,stat,wchan:32,cmd | awk '$2 ~ /D/'` 找出处于 D 状态的进程及等待的内核函数；\n" +
+			"2. 使用 `iostat -x 1`、`dmesg -T` 检查磁盘延迟和存储/网络文件系统错误；\n" +
+			"3. 存储恢复后进程通常自行恢复，长时间无法恢复时考虑迁移业务并重启节点。",
+	},
+	{
+		Name:    "segfault",
+		Pattern: `segfault at|segmentation fault|core dumped|general protection fault`,
+		Cause:   "进程访问了非法内存地址导致崩溃，常见于程序缺陷、依赖库版本不匹配或内存硬件故障。",
+		Remediation: "1. 根据日志中的进程名和 ip/sp 地址，结合 `addr2line` 或 core 文件（`coredumpctl gdb`）定位崩溃位置；\n" +
+			"2. 检查近期的程序和依赖库升级，必要时回滚；\n" +
+			"3. 同一节点多个不同进程反复段错误时，使用 `edac-util`、memtest 排查内存硬件故障。",
+	},
+	{
+		Name:    "disk_full",
+		Pattern: `no space left on device|disk full|filesystem full|ENOSPC`,
+		Cause:   "文件系统空间或 inode 已耗尽，写入失败可能导致服务异常或数据丢失。",
+		Remediation: "1. 执行 `df -h` 和 `df -i` 确认已满的分区及是否为 inode 耗尽；\n" +
+			"2. 使用 `du -xh --max-depth=1 <挂载点> | sort -h` 定位占用空间的目录，清理过期日志和临时文件；\n" +
+			"3. 检查 `lsof +L1` 中已删除但仍被占用的文件，并完善日志轮转（logrotate）配置或扩容。",
+	},
+})
+
+// mustCompileRules 编译内置规则的正则表达式
+func mustCompileRules(rules []OfflineRule) []OfflineRule {
+	if err := compileRules(rules); err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+// compileRules 编译规则的正则表达式
+func compileRules(rules []OfflineRule) error {
+	for i := range rules {
+		if rules[i].Pattern == "" {
+			return fmt.Errorf("规则 %s 缺少 pattern", rules[i].Name)
+		}
+		re, err := regexp.Compile("(?i)" + rules[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("规则 %s 的 pattern 无效: %w", rules[i].Name, err)
+		}
+		rules[i].re = re
+	}
+	return nil
+}
+
+// offlineRules 规则文件，文件修改后自动重新加载
+type offlineRules struct {
+	path    string
+	mu      sync.Mutex
+	rules   []OfflineRule
+	modTime time.Time
+	loaded  bool
+}
+
+var (
+	offlineRulesMu    sync.Mutex
+	offlineRulesFiles = make(map[string]*offlineRules)
+)
+
+// loadOfflineRules 返回生效的离线规则：规则文件中的规则优先，其后是内置规则
+func loadOfflineRules(path string) []OfflineRule {
+	if path == "" {
+		return defaultOfflineRules
+	}
+
+	offlineRulesMu.Lock()
+	or, ok := offlineRulesFiles[path]
+	if !ok {
+		or = &offlineRules{path: path}
+		offlineRulesFiles[path] = or
+	}
+	offlineRulesMu.Unlock()
+
+	return or.get()
+}
+
+// get 返回当前的规则，文件有更新时重新加载，加载失败时继续使用之前的版本
+func (or *offlineRules) get() []OfflineRule {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	info, err := os.Stat(or.path)
+	if err == nil && or.loaded && !info.ModTime().After(or.modTime) {
+		return or.rules
+	}
+	if err == nil {
+		or.modTime = info.ModTime()
+		var rules []OfflineRule
+		if rules, err = parseOfflineRules(or.path); err == nil {
+			or.rules = append(rules, defaultOfflineRules...)
+			or.loaded = true
+			return or.rules
+		}
+	}
+
+	log.Printf("加载离线分析规则 %s 失败: %v", or.path, err)
+	if !or.loaded {
+		or.rules = defaultOfflineRules
+		or.loaded = true
+	}
+	return or.rules
+}
+
+// parseOfflineRules 解析JSON格式的规则文件
+func parseOfflineRules(path string) ([]OfflineRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []OfflineRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解析规则文件失败: %w", err)
+	}
+	if err := compileRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// rulesFile 离线规则文件路径，为空时只使用内置规则
+var rulesFile string
+
+// SetOfflineRulesFile 设置离线分析规则文件
+func SetOfflineRulesFile(path string) {
+	rulesFile = path
+}
+
+// matchOfflineRules 返回事件命中的离线规则，同名规则只保留优先的一条
+func matchOfflineRules(event collector.LogEvent) []OfflineRule {
+	var matched []OfflineRule
+	seen := make(map[string]bool)
+	for _, rule := range loadOfflineRules(rulesFile) {
+		if seen[rule.Name] || !rule.re.MatchString(event.RawText) {
+			continue
+		}
+		seen[rule.Name] = true
+		matched = append(matched, rule)
+		if len(matched) >= maxOfflineRules {
+			break
+		}
+	}
+	return matched
+}
+
+// ruleAnalysis 根据命中的离线规则生成已知原因和处理建议，没有命中时返回空字符串
+func ruleAnalysis(event collector.LogEvent) string {
+	var b strings.Builder
+	for _, rule := range matchOfflineRules(event) {
+		fmt.Fprintf(&b, "\n**规则分析（%s）**\n**可能原因**: %s\n**处理建议**:\n%s\n", rule.Name, rule.Cause, rule.Remediation)
+	}
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package ai
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+)
+
+// 单个事件最多引用的运维手册数量
+const maxRunbooks = 2
+
+// 每份运维手册注入提示词的最大字符数
+const runbookExcerptRunes = 1500
+
+// 运维手册目录的重新扫描间隔
+const runbookReloadInterval = time.Minute
+
+// Runbook 运维手册，Markdown 文件开头的 front matter 声明适用的标签、模板ID和文件：
+//
+//	---
+//	title: 磁盘空间不足处理手册
+//	tags: No space left, disk full
+//	templates: 3f2a9c...
+//	files: *nginx*
+//	url: https://wiki.example.com/runbooks/disk-full
+//	---
+//
+// 事件命中任意一个标签、模板ID或文件通配符即视为匹配
+type Runbook struct {
+	Name        string // 文件名
+	Title       string
+	URL         string
+	Tags        []string
+	TemplateIDs []string
+	Files       []string
+	Body        string
+}
+
+// matches 判断事件是否适用该手册
+func (r Runbook) matches(event collector.LogEvent) bool {
+	return (len(r.Tags) > 0 && matchAnyTag(r.Tags, event.Tags)) ||
+		(len(r.TemplateIDs) > 0 && containsString(r.TemplateIDs, event.TemplateID)) ||
+		(len(r.Files) > 0 && matchAnyFile(r.Files, event.FilePath))
+}
+
+// runbookLibrary 运维手册目录，定期重新扫描以加载新增或修改的手册
+type runbookLibrary struct {
+	dir      string
+	mu       sync.Mutex
+	runbooks []Runbook
+	loadedAt time.Time
+}
+
+var (
+	runbookLibsMu sync.Mutex
+	runbookLibs   = make(map[string]*runbookLibrary)
+)
+
+// loadRunbooks 返回目录中的运维手册，目录为空时返回nil
+func loadRunbooks(dir string) []Runbook {
+	if dir == "" {
+		return nil
+	}
+
+	runbookLibsMu.Lock()
+	lib, ok := runbookLibs[dir]
+	if !ok {
+		lib = &runbookLibrary{dir: dir}
+		runbookLibs[dir] = lib
+	}
+	runbookLibsMu.Unlock()
+
+	return lib.get()
+}
+
+// get 返回当前的手册列表，超过扫描间隔时重新加载，加载失败时继续使用之前的版本
+func (lib *runbookLibrary) get() []Runbook {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	if time.Since(lib.loadedAt) < runbookReloadInterval {
+		return lib.runbooks
+	}
+	lib.loadedAt = time.Now()
+	runbooks, err := parseRunbookDir(lib.dir)
+	if err != nil {
+		log.Printf("加载运维手册目录 %s 失败: %v", lib.dir, err)
+		return lib.runbooks
+	}
+	lib.runbooks = runbooks
+	return lib.runbooks
+}
+
+// parseRunbookDir 解析目录下所有 .md 文件，按文件名排序
+func parseRunbookDir(dir string) ([]Runbook, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var runbooks []Runbook
+	for _, path := range paths {
+		r, err := parseRunbook(path)
+		if err != nil {
+			log.Printf("解析运维手册 %s 失败: %v", path, err)
+			continue
+		}
+		runbooks = append(runbooks, r)
+	}
+	return runbooks, nil
+}
+
+// parseRunbook 解析单个运维手册文件
+func parseRunbook(path string) (Runbook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Runbook{}, err
+	}
+	r := Runbook{Name: filepath.Base(path)}
+
+	body := string(data)
+	if rest, ok := strings.CutPrefix(body, "---\n"); ok {
+		header, content, found := strings.Cut(rest, "\n---")
+		if !found {
+			return Runbook{}, fmt.Errorf("front matter 缺少结束标记")
+		}
+		body = strings.TrimPrefix(content, "\n")
+		scanner := bufio.NewScanner(strings.NewReader(header))
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "title":
+				r.Title = value
+			case "url":
+				r.URL = value
+			case "tags":
+				r.Tags = splitList(value)
+			case "templates":
+				r.TemplateIDs = splitList(value)
+			case "files":
+				r.Files = splitList(value)
+			}
+		}
+	}
+	r.Body = strings.TrimSpace(body)
+
+	if r.Title == "" {
+		r.Title = strings.TrimSuffix(r.Name, ".md")
+		if heading, ok := strings.CutPrefix(firstLine(r.Body), "# "); ok {
+			r.Title = strings.TrimSpace(heading)
+		}
+	}
+	return r, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MatchRunbooks 返回适用于该事件的运维手册，未配置手册目录时返回nil
+// 未在 front matter 中声明 url 时按 RUNBOOK_BASE_URL + 文件名生成链接
+func MatchRunbooks(cfg *config.Config, event collector.LogEvent) []Runbook {
+	var matched []Runbook
+	for _, r := range loadRunbooks(cfg.RunbookDir) {
+		if !r.matches(event) {
+			continue
+		}
+		if r.URL == "" && cfg.RunbookBaseURL != "" {
+			r.URL = strings.TrimRight(cfg.RunbookBaseURL, "/") + "/" + r.Name
+		}
+		matched = append(matched, r)
+		if len(matched) == maxRunbooks {
+			break
+		}
+	}
+	return matched
+}
+
+// runbookContext 生成追加到系统提示词后的运维手册摘录，使修复建议与团队的标准处理流程一致
+func runbookContext(cfg *config.Config, event collector.LogEvent) string {
+	runbooks := MatchRunbooks(cfg, event)
+	if len(runbooks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n参考运维手册：以下是团队针对此类问题整理的标准处理流程，修复建议应优先遵循手册中的步骤，并在报告中注明引用的手册名称。\n")
+	for _, r := range runbooks {
+		fmt.Fprintf(&b, "\n### %s\n%s\n", r.Title, truncateRunes(r.Body, runbookExcerptRunes))
+	}
+	return b.String()
+}
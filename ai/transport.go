@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"log-ai-analyzer/config"
+)
+
+// httpClient 所有AI后端共享的HTTP客户端，复用连接池
+// 不设置整体超时，超时由调用方的上下文控制（云端30秒，本地模型2分钟）
+var httpClient = &http.Client{Transport: newTransport(nil, nil)}
+
+// tlsConfig 私有CA和客户端证书配置，gRPC sidecar 后端同样使用，nil表示系统默认
+var tlsConfig *tls.Config
+
+// ConfigureTransport 根据配置设置AI调用使用的代理和TLS选项
+// AI_PROXY_URL 为空时沿用 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量
+func ConfigureTransport(cfg *config.Config) error {
+	var proxy *url.URL
+	if cfg.AIProxyURL != "" {
+		u, err := url.Parse(cfg.AIProxyURL)
+		if err != nil {
+			return fmt.Errorf("解析AI代理地址失败: %w", err)
+		}
+		proxy = u
+	}
+
+	config, err := newTLSConfig(cfg.AICAFile, cfg.AIClientCertFile, cfg.AIClientKeyFile)
+	if err != nil {
+		return err
+	}
+	tlsConfig = config
+	httpClient = &http.Client{Transport: newTransport(proxy, config)}
+	return nil
+}
+
+// newTransport 创建AI调用的 Transport，本地地址（如 Ollama）不经过代理
+func newTransport(proxy *url.URL, tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 16
+	transport.ResponseHeaderTimeout = 2 * time.Minute
+	transport.TLSClientConfig = tlsConfig
+	if proxy != nil {
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if isLocalHost(req.URL.Hostname()) {
+				return nil, nil
+			}
+			return proxy, nil
+		}
+	}
+	return transport
+}
+
+// newTLSConfig 加载私有CA和客户端证书，均未配置时返回nil使用系统默认
+func newTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		// 在系统根证书的基础上追加私有CA，代理和公网服务的证书都能校验通过
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书文件中没有有效的PEM证书: %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
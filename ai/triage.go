@@ -0,0 +1,109 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/metrics"
+)
+
+// 分级请求的超时时间，分级模型应当足够快
+const triageTimeout = 20 * time.Second
+
+// triagePrompt 分级模型的系统提示词，只要求输出一行JSON以节省token
+const triagePrompt = `
+你是日志分级助手，只负责快速判断日志事件的严重程度，不做详细分析。
+严重程度为1到10的整数：1-3 为可忽略的噪音或预期内的告警，4-6 为需要关注但不紧急的问题，7-10 为影响服务的故障。
+只输出一行JSON，不要输出其他内容：{"severity": 整数, "category": "不超过10个字的问题分类"}
+`
+
+// triageJSONPattern 提取分级回复中的JSON对象
+var triageJSONPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// Triage 事件的分级结果
+type Triage struct {
+	Severity int    `json:"severity"`
+	Category string `json:"category"`
+	Source   string `json:"-"` // model 或 rules
+}
+
+// triageEvent 对事件分级，返回分级结果以及是否需要深度分析
+// 未配置阈值时所有事件都进行深度分析；分级模型不可用时按规则评分分级
+func triageEvent(cfg *config.Config, event collector.LogEvent) (Triage, bool) {
+	if cfg.AITriageThreshold <= 0 {
+		return Triage{}, true
+	}
+
+	t := Triage{Severity: min(max(event.SeverityScore, 1), 10), Source: "rules"}
+	if cfg.AITriageProvider != "" && cfg.AITriageProvider != "rules" {
+		if result, err := triageWithModel(cfg, event); err != nil {
+			log.Printf("分级模型调用失败，按规则评分分级 [EventID: %s]: %v", event.EventID, err)
+		} else {
+			t = result
+		}
+	}
+
+	deep := t.Severity >= cfg.AITriageThreshold
+	stage := "skipped"
+	if deep {
+		stage = "deep"
+	}
+	metrics.AITriageCount.WithLabelValues(t.Source, stage).Inc()
+	return t, deep
+}
+
+// triageWithModel 调用廉价模型分级，同样受预算和限流约束（限流在每次请求时申请）
+func triageWithModel(cfg *config.Config, event collector.LogEvent) (Triage, error) {
+	if err := budget.Allow(); err != nil {
+		return Triage{}, err
+	}
+	provider, err := newProvider(cfg.AITriageProvider, cfg.AITriageAPIURL, cfg.Secret("AI_TRIAGE_API_KEY"), cfg.AITriageModel, cfg.AITriageParams)
+	if err != nil {
+		return Triage{}, err
+	}
+
+	_, user, err := buildPrompts(cfg, truncateEvent(event, cfg.AIMaxInputTokens))
+	if err != nil {
+		return Triage{}, err
+	}
+	system, user, _ := guardPrompts(triagePrompt, user)
+
+	timeout := triageTimeout
+	if cfg.AITriageParams.Timeout > 0 {
+		timeout = cfg.AITriageParams.Timeout
+	}
+	res, err := performAIAnalysis(context.Background(), provider, timeout, system, user)
+	if err != nil {
+		return Triage{}, err
+	}
+	if res.PromptTokens == 0 && res.CompletionTokens == 0 {
+		res.PromptTokens = estimateTokens(system) + estimateTokens(user)
+		res.CompletionTokens = estimateTokens(res.Content)
+	}
+	budget.Record(res.PromptTokens, res.CompletionTokens)
+
+	t := Triage{Source: "model"}
+	if err := json.Unmarshal([]byte(triageJSONPattern.FindString(res.Content)), &t); err != nil {
+		return Triage{}, fmt.Errorf("解析分级结果失败: %w", err)
+	}
+	if t.Severity < 1 || t.Severity > 10 {
+		return Triage{}, fmt.Errorf("分级结果 severity %d 不在1-10之间", t.Severity)
+	}
+	return t, nil
+}
+
+// skippedResult 未达到深度分析阈值的事件只给出分级结论和规则摘要
+func skippedResult(event collector.LogEvent, t Triage, threshold int) string {
+	category := ""
+	if t.Category != "" {
+		category = "，分类: " + t.Category
+	}
+	return fmt.Sprintf("（分级评估严重程度 %d/10%s，低于深度分析阈值 %d，未进行AI深度分析，以下为规则摘要）\n%s",
+		t.Severity, category, threshold, heuristicSummary(event))
+}
@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// errorLinePattern 识别事件中第一条错误行
+var errorLinePattern = regexp.MustCompile(`(?i)(exception|error|fatal|panic|critical|call trace|failed|oom|segmentation fault)`)
+
+// 上下文行最多占用的预算比例，超长事件优先保留事件本身
+const contextBudgetRatio = 0.1
+
+// truncateEvent 将超出token预算的事件裁剪到预算以内
+// 依次保留：第一条错误行及其后续内容、最内层的 "Caused by" 根因及其栈帧、事件末尾，
+// 被省略的部分以标记代替，而不是直接发送超长内容导致模型拒绝请求
+func truncateEvent(event collector.LogEvent, maxTokens int) collector.LogEvent {
+	if maxTokens <= 0 {
+		return event
+	}
+	budget := maxTokens * 2 // 与 estimateTokens 一致，按每token两个字符估算
+	size := utf8.RuneCountInString(event.RawText) + utf8.RuneCountInString(strings.Join(event.ContextLines, "\n"))
+	if size <= budget {
+		return event
+	}
+
+	metrics.AIInputTruncatedCount.Inc()
+	contextBudget := int(float64(budget) * contextBudgetRatio)
+	event.ContextLines = truncateLines(event.ContextLines, contextBudget)
+	textBudget := budget - utf8.RuneCountInString(strings.Join(event.ContextLines, "\n"))
+	lines := truncateLines(strings.Split(event.RawText, "\n"), textBudget)
+	event.RawLines = lines
+	event.RawText = strings.Join(lines, "\n")
+	return event
+}
+
+// truncateLines 在字符预算内选出最有价值的行，保持原有顺序
+func truncateLines(lines []string, budget int) []string {
+	total := 0
+	for _, line := range lines {
+		total += utf8.RuneCountInString(line) + 1
+	}
+	if total <= budget {
+		return lines
+	}
+	if budget <= 0 || len(lines) == 0 {
+		return nil
+	}
+
+	errorIdx := 0
+	for i, line := range lines {
+		if errorLinePattern.MatchString(line) {
+			errorIdx = i
+			break
+		}
+	}
+	causeIdx := -1
+	for i := len(lines) - 1; i > errorIdx; i-- {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "Caused by") {
+			causeIdx = i
+			break
+		}
+	}
+
+	keep := make([]bool, len(lines))
+	used := 0
+	// take 从 start 开始按 step 方向选取行，直到用完 share 个字符
+	take := func(start, step, share int) {
+		limit := used + share
+		for i := start; i >= 0 && i < len(lines); i += step {
+			if keep[i] {
+				continue
+			}
+			n := utf8.RuneCountInString(lines[i]) + 1
+			if used+n > limit || used+n > budget {
+				return
+			}
+			keep[i] = true
+			used += n
+		}
+	}
+
+	// 第一条错误行必须保留，单行超出预算时截断该行
+	n := utf8.RuneCountInString(lines[errorIdx]) + 1
+	if n > budget {
+		return []string{truncateRunes(lines[errorIdx], budget)}
+	}
+	keep[errorIdx] = true
+	used = n
+
+	// 剩余预算按 错误行之后40%、根因30%、末尾30% 分配，未用完的部分留给末尾
+	remaining := budget - used
+	take(errorIdx+1, 1, remaining*4/10)
+	if causeIdx >= 0 {
+		take(causeIdx, 1, remaining*3/10)
+	}
+	take(len(lines)-1, -1, budget-used)
+
+	var result []string
+	omitted := 0
+	for i, line := range lines {
+		if !keep[i] {
+			omitted++
+			continue
+		}
+		if omitted > 0 {
+			result = append(result, fmt.Sprintf("... （省略 %d 行）...", omitted))
+			omitted = 0
+		}
+		result = append(result, line)
+	}
+	if omitted > 0 {
+		result = append(result, fmt.Sprintf("... （省略 %d 行）...", omitted))
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/metrics"
+)
+
+// ErrInvalidResponse 模型回复缺少结构化结论或取值不合理
+var ErrInvalidResponse = errors.New("AI回复未通过结构校验")
+
+// Analysis 模型在分析报告末尾给出的结构化结论
+type Analysis struct {
+	Severity    int      `json:"severity"`    // 问题严重程度，1-10
+	RootCause   string   `json:"root_cause"`  // 一句话根因
+	Remediation []string `json:"remediation"` // 修复步骤
+}
+
+// structuredInstruction 追加到系统提示词后的输出格式要求
+const structuredInstruction = "\n输出要求：在分析报告的最后附加一个 ```json 代码块给出结构化结论，格式如下：\n" +
+	"```json\n{\"severity\": 1到10的整数, \"root_cause\": \"一句话描述根本原因\", \"remediation\": [\"具体的修复步骤\"]}\n```\n"
+
+// correctionInstruction 回复未通过结构校验时重试使用的纠正说明，%s 为校验失败原因
+const correctionInstruction = "\n注意：上一次回复未通过格式校验（%s）。请重新分析，并严格按照输出要求在报告最后附加完整的 json 代码块。\n"
+
+// jsonBlockPattern 匹配回复中的 json 代码块
+var jsonBlockPattern = regexp.MustCompile("(?s)```(?:json)?\\s*(\\{.*?\\})\\s*```")
+
+// parseAnalysis 解析回复末尾的结构化结论并校验取值，返回去掉代码块后的报告正文
+func parseAnalysis(content string) (Analysis, string, error) {
+	var a Analysis
+	matches := jsonBlockPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return a, "", fmt.Errorf("%w: 缺少json结论", ErrInvalidResponse)
+	}
+	m := matches[len(matches)-1]
+	if err := json.Unmarshal([]byte(content[m[2]:m[3]]), &a); err != nil {
+		return a, "", fmt.Errorf("%w: json结论无法解析: %v", ErrInvalidResponse, err)
+	}
+	if a.Severity < 1 || a.Severity > 10 {
+		return a, "", fmt.Errorf("%w: severity %d 不在1-10之间", ErrInvalidResponse, a.Severity)
+	}
+	if strings.TrimSpace(a.RootCause) == "" {
+		return a, "", fmt.Errorf("%w: root_cause 为空", ErrInvalidResponse)
+	}
+	steps := a.Remediation[:0]
+	for _, step := range a.Remediation {
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) == 0 {
+		return a, "", fmt.Errorf("%w: remediation 为空", ErrInvalidResponse)
+	}
+	a.Remediation = steps
+
+	text := strings.TrimSpace(content[:m[0]] + content[m[1]:])
+	return a, text, nil
+}
+
+// renderAnalysis 将结构化结论渲染到报告正文之后；模型只输出了代码块时由结论生成正文
+func renderAnalysis(a Analysis, text string) string {
+	var b strings.Builder
+	if text != "" {
+		b.WriteString(text)
+		b.WriteString("\n\n")
+	}
+	fmt.Fprintf(&b, "**AI评估严重程度**: %d/10\n**根本原因**: %s", a.Severity, a.RootCause)
+	if text == "" {
+		b.WriteString("\n**修复建议**:")
+		for i, step := range a.Remediation {
+			fmt.Fprintf(&b, "\n%d. %s", i+1, step)
+		}
+	}
+	return b.String()
+}
+
+// completeAnalysis 调用模型完成事件分析并校验结构化结论
+// 校验失败时附带纠正说明重试一次，仍失败时返回 ErrInvalidResponse，由调用方降级为规则摘要
+func completeAnalysis(cfg *config.Config, tools *toolSet, system, user string) (string, error) {
+	if !cfg.AIResponseValidation {
+		return completeTools(cfg, tools, system, user)
+	}
+
+	system += structuredInstruction
+	result, err := completeTools(cfg, tools, system, user)
+	if err != nil {
+		return "", err
+	}
+	a, text, err := parseAnalysis(result)
+	if err == nil {
+		return renderAnalysis(a, text), nil
+	}
+
+	metrics.AIInvalidResponseCount.Inc()
+	log.Printf("AI回复未通过结构校验，使用纠正提示词重试: %v", err)
+	result, err = completeTools(cfg, tools, system+fmt.Sprintf(correctionInstruction, err), user)
+	if err != nil {
+		return "", err
+	}
+	a, text, err = parseAnalysis(result)
+	if err != nil {
+		metrics.AIInvalidResponseCount.Inc()
+		return "", err
+	}
+	return renderAnalysis(a, text), nil
+}
+
+// invalidResult 回复未通过结构校验时的降级结果
+func invalidResult(event collector.LogEvent) string {
+	return "（AI分析结果格式校验失败，以下为规则摘要）\n" + heuristicSummary(event)
+}
@@ -4,9 +4,12 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"hash/fnv"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -17,44 +20,104 @@ import (
 type AggregatedAlert struct {
 	EventID      string
 	Host         string
+	Tenant       string // 所属租户
 	Severity     int
 	Count        int
 	LastAlertAt  time.Time
 	FirstAlertAt time.Time
+	LastSentAt   time.Time // 最近一次发送告警的时间，用于计算重复告警的发送间隔
 	Content      string
 	AiResult     string
-	IsCellTrace  bool     // 标识是否为Cell Trace异常
-	FilePath     string   // 文件路径
-	ContextLines []string // 上下文行
-	TotalScore   int      // 累计严重性分数
+	IsCellTrace  bool                     // 标识是否为Cell Trace异常
+	FilePath     string                   // 文件路径
+	ContextLines []string                 // 上下文行
+	TotalScore   int                      // 累计严重性分数
+	TemplateID   string                   // 日志模板ID
+	Fingerprint  string                   // Alertmanager 兼容的告警指纹
+	Key          string                   // 告警缓存键
+	TicketID     string                   // 关联的工单ID
+	IncidentID   string                   // 所属的故障，取最近一次合并的事件所属的故障
+	LastEventID  string                   // 最近一次合并的事件ID，AI分析结果来自该事件
+	Runbooks     []RunbookLink            // 适用的运维手册
+	Timeline     []TimelineEntry          // 所属故障的时间线
+	Related      []collector.RelatedEvent // 最近一次合并的事件在其他日志文件中的关联事件
 }
 
-type AlertCache struct {
-	cache map[string]*AggregatedAlert
-	ttl   time.Duration
+// 缓存分片数，按告警键哈希分片以降低工作协程之间的锁竞争
+const cacheShardCount = 32
+
+type cacheShard struct {
+	items map[string]*AggregatedAlert
 	mu    sync.Mutex
 }
 
+type AlertCache struct {
+	shards          [cacheShardCount]*cacheShard
+	ttl             time.Duration
+	schedule        atomic.Pointer[SeveritySchedule] // 按时间段生效的最低告警严重性
+	index           *similarIndex                    // 相似告警索引
+	resend          ResendIntervals                  // 重复告警的发送间隔
+	maxContextLines int                              // 合并告警最多保留的上下文行数，0表示不限制
+}
+
+// ResendIntervals 已合并告警按严重性重复发送的间隔
+type ResendIntervals struct {
+	High   time.Duration // 严重性>=8，前3次之后
+	Medium time.Duration // 严重性>=5，前2次之后
+	Low    time.Duration // 其余，每10次之外
+}
+
+// DefaultResendIntervals 默认的重复告警发送间隔
+var DefaultResendIntervals = ResendIntervals{High: 5 * time.Minute, Medium: 10 * time.Minute, Low: 30 * time.Minute}
+
 func NewAlertCache(ttl time.Duration) *AlertCache {
-	return &AlertCache{
-		cache: make(map[string]*AggregatedAlert),
-		ttl:   ttl,
+	ac := &AlertCache{ttl: ttl, index: newSimilarIndex(), resend: DefaultResendIntervals, maxContextLines: 20}
+	for i := range ac.shards {
+		ac.shards[i] = &cacheShard{items: make(map[string]*AggregatedAlert)}
 	}
+	return ac
+}
+
+// SetSeveritySchedule 设置按时间段生效的告警阈值表
+func (ac *AlertCache) SetSeveritySchedule(schedule SeveritySchedule) {
+	ac.schedule.Store(&schedule)
+}
+
+// SetResendIntervals 设置重复告警的发送间隔，应在开始处理事件之前调用
+func (ac *AlertCache) SetResendIntervals(resend ResendIntervals) {
+	ac.resend = resend
+}
+
+// SetSimilarityThreshold 设置合并告警的内容相似度阈值（0-100），应在开始处理事件之前调用
+func (ac *AlertCache) SetSimilarityThreshold(threshold float64) {
+	ac.index.threshold = threshold
+}
+
+// SetMaxContextLines 设置合并告警最多保留的上下文行数，0表示不限制，应在开始处理事件之前调用
+func (ac *AlertCache) SetMaxContextLines(n int) {
+	ac.maxContextLines = n
+}
+
+// shardFor 返回告警键所在的分片
+func (ac *AlertCache) shardFor(key string) *cacheShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return ac.shards[h.Sum32()%cacheShardCount]
 }
 
 // generateAlertKey 生成告警的唯一键
 func generateAlertKey(event collector.LogEvent) string {
 	// 总是基于内容生成稳定的键，确保一致性
 	contentHash := getContentHash(event.RawText)
-	
+
 	// 结合主机名、文件路径和内容哈希生成键
 	baseKey := fmt.Sprintf("%s-%s-%s", event.Host, getFileName(event.FilePath), contentHash)
-	
+
 	// 如果是Cell Trace异常，添加特殊前缀
 	if event.IsCellTrace {
 		return fmt.Sprintf("cell-trace-%s", baseKey)
 	}
-	
+
 	return baseKey
 }
 
@@ -62,7 +125,7 @@ func generateAlertKey(event collector.LogEvent) string {
 func getContentHash(content string) string {
 	// 使用与collector中相同的标准化方法
 	normalized := normalizeContent(content)
-	
+
 	// 计算MD5哈希
 	hash := md5.Sum([]byte(normalized))
 	return hex.EncodeToString(hash[:])
@@ -73,17 +136,17 @@ func normalizeContent(content string) string {
 	// 移除时间戳
 	re := regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T\s]\d{2}:\d{2}:\d{2}[\s\d.:]*|\w{3} \d{1,2} \d{2}:\d{2}:\d{2}`)
 	content = re.ReplaceAllString(content, "")
-	
+
 	// 移除可能变化的数字（如PID、端口号等）
 	re = regexp.MustCompile(`\b\d+\b`)
 	content = re.ReplaceAllString(content, "NUMBER")
-	
+
 	// 转换为小写以提高一致性
 	content = strings.ToLower(content)
-	
+
 	// 移除多余的空白字符
 	content = regexp.MustCompile(`\s+`).ReplaceAllString(content, " ")
-	
+
 	return strings.TrimSpace(content)
 }
 
@@ -97,190 +160,189 @@ func getFileName(path string) string {
 
 // 告警合并策略
 func (ac *AlertCache) AddOrUpdate(event collector.LogEvent, aiResult string) (send bool, alert AggregatedAlert) {
-	ac.mu.Lock()
-	defer ac.mu.Unlock()
-
 	// 生成更智能的告警键
 	key := generateAlertKey(event)
+	scope := indexScope(event.Host, event.FilePath)
 
 	now := time.Now()
-	if agg, ok := ac.cache[key]; ok {
-		// 更新现有告警
-		agg.Count++
-		agg.LastAlertAt = now
-		agg.Severity = max(agg.Severity, event.SeverityScore)
-		agg.TotalScore += event.SeverityScore
-		agg.Content = event.RawText // 使用最新的内容
-		agg.AiResult = aiResult
 
-		// 合并上下文行（去重）
-		if len(event.ContextLines) > 0 {
-			agg.ContextLines = mergeContextLines(agg.ContextLines, event.ContextLines)
+	shard := ac.shardFor(key)
+	shard.mu.Lock()
+	if agg, ok := shard.items[key]; ok {
+		defer shard.mu.Unlock()
+		metrics.AlertCacheLookupCount.WithLabelValues("hit").Inc()
+		send = ac.merge(agg, key, event, aiResult, now)
+		return send, *agg
+	}
+	shard.mu.Unlock()
+
+	// 在创建新告警之前，检查是否存在相似度很高的事件（默认90%的阈值）
+	// 索引查找和编辑距离计算都不持有分片锁
+	if similarKey := ac.index.lookup(scope, event.RawText); similarKey != "" {
+		similarShard := ac.shardFor(similarKey)
+		similarShard.mu.Lock()
+		if agg, ok := similarShard.items[similarKey]; ok {
+			defer similarShard.mu.Unlock()
+			metrics.AlertCacheLookupCount.WithLabelValues("similar").Inc()
+			send = ac.merge(agg, similarKey, event, aiResult, now)
+			return send, *agg
 		}
+		// 相似告警在比较期间已被清理，按新告警处理
+		similarShard.mu.Unlock()
+	}
 
-		// 更智能的告警合并策略
-		// 对于高严重性问题(严重性>=8)，采用更积极的告警策略
-		if event.SeverityScore >= 8 {
-			// 高严重性问题：前3次立即发送，之后每5分钟发送一次
-			if agg.Count <= 3 {
-				send = true
-			} else {
-				// 检查是否距离上次发送已经超过5分钟
-				send = now.Sub(agg.LastAlertAt) >= 5*time.Minute
-			}
-		} else if event.SeverityScore >= 5 {
-			// 中等严重性问题：前2次立即发送，之后每10分钟发送一次
-			if agg.Count <= 2 {
-				send = true
-			} else {
-				// 检查是否距离上次发送已经超过10分钟
-				send = now.Sub(agg.LastAlertAt) >= 10*time.Minute
-			}
-		} else {
-			// 低严重性问题：每10次或每30分钟发送一次
-			send = (agg.Count%10 == 0) || (now.Sub(agg.LastAlertAt) >= 30*time.Minute)
-		}
-		
-		// 如果是新的Cell Trace异常，即使已有相同类型也要发送
-		if event.IsCellTrace && !agg.IsCellTrace {
-			agg.IsCellTrace = true
-			send = true
-		}
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if agg, ok := shard.items[key]; ok {
+		// 其他工作协程在锁外阶段已创建了同一告警
+		metrics.AlertCacheLookupCount.WithLabelValues("hit").Inc()
+		send = ac.merge(agg, key, event, aiResult, now)
+		return send, *agg
+	}
 
-		alert = *agg
-		
-		// 更新合并告警计数
-		if send {
-			metrics.AlertMergedCount.Inc()
-		}
-		
-		// 如果是Cell Trace异常，更新相关指标
-		if event.IsCellTrace {
-			metrics.CellTraceErrorCount.Inc()
-			metrics.CellTraceErrorSeverity.Observe(float64(event.SeverityScore))
-		}
-	} else {
-		// 在创建新告警之前，检查是否存在相似度很高的事件（基于90%的阈值）
-		var similarKey string
-		for k, agg := range ac.cache {
-			// 检查主机名和文件路径是否匹配
-			if agg.Host == event.Host && agg.FilePath == event.FilePath {
-				// 创建临时事件用于比较
-				tempEvent := collector.LogEvent{
-					RawText: agg.Content,
-					Host:    agg.Host,
-				}
-				
-				// 检查相似度
-				if isSimilarEnough(event, tempEvent) {
-					similarKey = k
-					break
-				}
-			}
-		}
+	// 创建新告警
+	metrics.AlertCacheLookupCount.WithLabelValues("miss").Inc()
+	agg := &AggregatedAlert{
+		EventID:      event.EventID,
+		Host:         event.Host,
+		Tenant:       event.Tenant,
+		Severity:     event.SeverityScore,
+		Count:        1,
+		LastAlertAt:  now,
+		FirstAlertAt: now,
+		Content:      event.RawText,
+		AiResult:     aiResult,
+		IsCellTrace:  event.IsCellTrace,
+		FilePath:     event.FilePath,
+		ContextLines: event.ContextLines,
+		TotalScore:   event.SeverityScore,
+		TemplateID:   event.TemplateID,
+		Fingerprint:  Fingerprint(event.Host, event.TemplateID, event.SeverityScore),
+		Key:          key,
+		LastEventID:  event.EventID,
+		IncidentID:   event.IncidentID,
+		Related:      event.Related,
+	}
+	shard.items[key] = agg
+	ac.index.put(scope, key, agg.Content)
 
-		// 如果找到了相似度很高的事件，则合并到该事件中
-		if similarKey != "" {
-			agg := ac.cache[similarKey]
-			// 更新现有告警
-			agg.Count++
-			agg.LastAlertAt = now
-			agg.Severity = max(agg.Severity, event.SeverityScore)
-			agg.TotalScore += event.SeverityScore
-			agg.Content = event.RawText // 使用最新的内容
-			agg.AiResult = aiResult
-
-			// 合并上下文行（去重）
-			if len(event.ContextLines) > 0 {
-				agg.ContextLines = mergeContextLines(agg.ContextLines, event.ContextLines)
-			}
+	send = ac.applySchedule(shouldSendNew(event), event.SeverityScore, now)
+	if send {
+		agg.LastSentAt = now
+	}
 
-			// 更智能的告警合并策略
-			// 对于高严重性问题(严重性>=8)，采用更积极的告警策略
-			if event.SeverityScore >= 8 {
-				// 高严重性问题：前3次立即发送，之后每5分钟发送一次
-				if agg.Count <= 3 {
-					send = true
-				} else {
-					// 检查是否距离上次发送已经超过5分钟
-					send = now.Sub(agg.LastAlertAt) >= 5*time.Minute
-				}
-			} else if event.SeverityScore >= 5 {
-				// 中等严重性问题：前2次立即发送，之后每10分钟发送一次
-				if agg.Count <= 2 {
-					send = true
-				} else {
-					// 检查是否距离上次发送已经超过10分钟
-					send = now.Sub(agg.LastAlertAt) >= 10*time.Minute
-				}
-			} else {
-				// 低严重性问题：每10次或每30分钟发送一次
-				send = (agg.Count%10 == 0) || (now.Sub(agg.LastAlertAt) >= 30*time.Minute)
-			}
-			
-			// 如果是新的Cell Trace异常，即使已有相同类型也要发送
-			if event.IsCellTrace && !agg.IsCellTrace {
-				agg.IsCellTrace = true
-				send = true
-			}
+	// 如果是Cell Trace异常，更新相关指标
+	if event.IsCellTrace {
+		metrics.CellTraceErrorCount.Inc()
+		metrics.CellTraceErrorSeverity.Observe(float64(event.SeverityScore))
+	}
+	return send, *agg
+}
 
-			alert = *agg
-			
-			// 更新合并告警计数
-			if send {
-				metrics.AlertMergedCount.Inc()
-			}
-			
-			// 如果是Cell Trace异常，更新相关指标
-			if event.IsCellTrace {
-				metrics.CellTraceErrorCount.Inc()
-				metrics.CellTraceErrorSeverity.Observe(float64(event.SeverityScore))
-			}
-			return
-		}
+// Occurrences 返回事件合并到告警后该告警的出现次数（含本事件），按告警键查找，尚无告警时为1；
+// 在写入事件之前调用，只作为统计参考，按相似度合并到其他告警的事件不计入
+func (ac *AlertCache) Occurrences(event collector.LogEvent) int {
+	key := generateAlertKey(event)
+	shard := ac.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if agg, ok := shard.items[key]; ok {
+		return agg.Count + 1
+	}
+	return 1
+}
 
-		// 创建新告警
-		ac.cache[key] = &AggregatedAlert{
-			EventID:      event.EventID,
-			Host:         event.Host,
-			Severity:     event.SeverityScore,
-			Count:        1,
-			LastAlertAt:  now,
-			FirstAlertAt: now,
-			Content:      event.RawText,
-			AiResult:     aiResult,
-			IsCellTrace:  event.IsCellTrace,
-			FilePath:     event.FilePath,
-			ContextLines: event.ContextLines,
-			TotalScore:   event.SeverityScore,
-		}
+// merge 将事件合并到已有告警中并返回是否需要发送，调用方需持有告警所在分片的锁
+func (ac *AlertCache) merge(agg *AggregatedAlert, key string, event collector.LogEvent, aiResult string, now time.Time) bool {
+	// 更新现有告警
+	agg.Count++
+	agg.LastAlertAt = now
+	if event.SeverityScore > agg.Severity {
+		// 指纹包含严重性标签，严重性升高后重新计算，与 Alertmanager 中当前标签集合的指纹保持一致
+		agg.Severity = event.SeverityScore
+		agg.Fingerprint = Fingerprint(agg.Host, agg.TemplateID, agg.Severity)
+	}
+	agg.TotalScore += event.SeverityScore
+	agg.Content = event.RawText // 使用最新的内容
+	agg.AiResult = aiResult
+	agg.LastEventID = event.EventID
+	if event.IncidentID != "" {
+		agg.IncidentID = event.IncidentID
+	}
+	if len(event.Related) > 0 {
+		agg.Related = event.Related
+	}
 
-		// 新告警的发送策略
-		// 根据严重性评分决定是否立即发送
-		if event.SeverityScore >= 8 {
-			// 高严重性异常立即发送
-			send = true
-		} else if event.SeverityScore >= 5 {
-			// 中等严重性异常立即发送
-			send = true
-		} else {
-			// 低严重性异常延迟发送，但如果是Cell Trace则发送
-			send = event.IsCellTrace
-		}
+	// 合并上下文行（去重）
+	if len(event.ContextLines) > 0 {
+		agg.ContextLines = mergeContextLines(agg.ContextLines, event.ContextLines, ac.maxContextLines)
+	}
+	ac.index.put(indexScope(agg.Host, agg.FilePath), key, agg.Content)
 
-		alert = *ac.cache[key]
-		
-		// 如果是Cell Trace异常，更新相关指标
-		if event.IsCellTrace {
-			metrics.CellTraceErrorCount.Inc()
-			metrics.CellTraceErrorSeverity.Observe(float64(event.SeverityScore))
-		}
+	send := ac.shouldSendMerged(agg, event, now)
+
+	// 如果是新的Cell Trace异常，即使已有相同类型也要发送
+	if event.IsCellTrace && !agg.IsCellTrace {
+		agg.IsCellTrace = true
+		send = true
+	}
+
+	send = ac.applySchedule(send, event.SeverityScore, now)
+	if send {
+		agg.LastSentAt = now
+		// 更新合并告警计数
+		metrics.AlertMergedCount.Inc()
+	}
+
+	// 如果是Cell Trace异常，更新相关指标
+	if event.IsCellTrace {
+		metrics.CellTraceErrorCount.Inc()
+		metrics.CellTraceErrorSeverity.Observe(float64(event.SeverityScore))
+	}
+	return send
+}
+
+// shouldSendNew 新告警的发送策略，根据严重性评分决定是否立即发送
+func shouldSendNew(event collector.LogEvent) bool {
+	// 高、中严重性异常立即发送
+	if event.SeverityScore >= 5 {
+		return true
+	}
+	// 低严重性异常延迟发送，但如果是Cell Trace则发送
+	return event.IsCellTrace
+}
+
+// shouldSendMerged 已合并告警的发送策略，重复告警的发送间隔从上次发送告警时算起
+// 对于高严重性问题(严重性>=8)，采用更积极的告警策略
+func (ac *AlertCache) shouldSendMerged(agg *AggregatedAlert, event collector.LogEvent, now time.Time) bool {
+	sinceLastSent := now.Sub(agg.LastSentAt)
+	switch {
+	case event.SeverityScore >= 8:
+		// 高严重性问题：前3次立即发送，之后按间隔发送（默认5分钟）
+		return agg.Count <= 3 || sinceLastSent >= ac.resend.High
+	case event.SeverityScore >= 5:
+		// 中等严重性问题：前2次立即发送，之后按间隔发送（默认10分钟）
+		return agg.Count <= 2 || sinceLastSent >= ac.resend.Medium
+	default:
+		// 低严重性问题：每10次或按间隔发送（默认30分钟）
+		return agg.Count%10 == 0 || sinceLastSent >= ac.resend.Low
 	}
-	return
+}
+
+// applySchedule 当前时间段配置了最低告警严重性时，低于阈值的事件只合并不发送
+func (ac *AlertCache) applySchedule(send bool, severity int, now time.Time) bool {
+	schedule := ac.schedule.Load()
+	if !send || schedule == nil {
+		return send
+	}
+	if minSeverity, ok := schedule.MinSeverity(now); ok && severity < minSeverity {
+		return false
+	}
+	return true
 }
 
 // 合并上下文行，去重并保持顺序
-func mergeContextLines(existing, new []string) []string {
+func mergeContextLines(existing, new []string, limit int) []string {
 	if len(new) == 0 {
 		return existing
 	}
@@ -309,8 +371,8 @@ func mergeContextLines(existing, new []string) []string {
 	}
 
 	// 限制上下文行数，避免过多
-	if len(result) > 20 {
-		return result[:20]
+	if limit > 0 && len(result) > limit {
+		return result[:limit]
 	}
 	return result
 }
@@ -338,11 +400,11 @@ func calculateSimilarity(s1, s2 string) float64 {
 	// 使用编辑距离算法计算相似度
 	distance := levenshteinDistance(s1, s2)
 	maxLen := max(len(s1), len(s2))
-	
+
 	if maxLen == 0 {
 		return 100.0
 	}
-	
+
 	// 计算相似度百分比
 	similarity := (1.0 - float64(distance)/float64(maxLen)) * 100.0
 	return similarity
@@ -409,26 +471,60 @@ func max(a, b int) int {
 	return b
 }
 
-// isSimilarEnough 检查两个日志事件是否足够相似（基于90%的阈值）
-func isSimilarEnough(event1, event2 collector.LogEvent) bool {
-	// 首先检查基本属性是否一致
-	if event1.Host != event2.Host {
-		return false
+// SetTicketID 将工单ID关联到告警
+func (ac *AlertCache) SetTicketID(key, ticketID string) {
+	shard := ac.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if agg, ok := shard.items[key]; ok {
+		agg.TicketID = ticketID
 	}
+}
 
-	// 计算内容相似度
-	similarity := calculateSimilarity(event1.RawText, event2.RawText)
-	return similarity >= 90.0
+// SetAiResult 回填告警的AI分析结果，仅当告警最近一次合并的仍是该事件时更新
+func (ac *AlertCache) SetAiResult(key, eventID, aiResult string) {
+	shard := ac.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if agg, ok := shard.items[key]; ok && agg.LastEventID == eventID {
+		agg.AiResult = aiResult
+	}
 }
 
-func (ac *AlertCache) Cleanup() {
-	ac.mu.Lock()
-	defer ac.mu.Unlock()
+// Active 返回缓存中未过期的告警，最近出现的在前
+func (ac *AlertCache) Active() []AggregatedAlert {
+	var active []AggregatedAlert
+	for _, shard := range ac.shards {
+		shard.mu.Lock()
+		for _, v := range shard.items {
+			active = append(active, *v)
+		}
+		shard.mu.Unlock()
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastAlertAt.After(active[j].LastAlertAt)
+	})
+	return active
+}
 
+// Cleanup 清理过期告警，返回已过期（视为已恢复）的告警，并更新告警缓存的大小指标
+func (ac *AlertCache) Cleanup() []AggregatedAlert {
+	var expired []AggregatedAlert
 	now := time.Now()
-	for k, v := range ac.cache {
-		if now.Sub(v.LastAlertAt) > ac.ttl {
-			delete(ac.cache, k)
+	entries := 0
+	for _, shard := range ac.shards {
+		shard.mu.Lock()
+		for k, v := range shard.items {
+			if now.Sub(v.LastAlertAt) > ac.ttl {
+				expired = append(expired, *v)
+				delete(shard.items, k)
+				ac.index.remove(indexScope(v.Host, v.FilePath), k)
+			}
 		}
+		entries += len(shard.items)
+		shard.mu.Unlock()
 	}
+	metrics.AlertCacheEntries.Set(float64(entries))
+	metrics.AlertCacheEvictionCount.Add(float64(len(expired)))
+	return expired
 }
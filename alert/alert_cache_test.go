@@ -0,0 +1,213 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"log-ai-analyzer/collector"
+)
+
+func TestShouldSendNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		severity  int
+		cellTrace bool
+		want      bool
+	}{
+		{"高严重性", 8, false, true},
+		{"中严重性下限", 5, false, true},
+		{"低严重性", 4, false, false},
+		{"低严重性 Cell Trace", 2, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := collector.LogEvent{SeverityScore: tt.severity, IsCellTrace: tt.cellTrace}
+			if got := shouldSendNew(event); got != tt.want {
+				t.Errorf("shouldSendNew() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldSendMerged(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		severity      int
+		count         int // 合并本事件后的出现次数
+		sinceLastSent time.Duration
+		want          bool
+	}{
+		{"高严重性前3次", 8, 3, 0, true},
+		{"高严重性第4次未到间隔", 8, 4, 5*time.Minute - time.Second, false},
+		{"高严重性第4次到达间隔", 8, 4, 5 * time.Minute, true},
+		{"中严重性前2次", 5, 2, 0, true},
+		{"中严重性第3次未到间隔", 5, 3, 10*time.Minute - time.Second, false},
+		{"中严重性第3次到达间隔", 5, 3, 10 * time.Minute, true},
+		{"低严重性第10次", 3, 10, 0, true},
+		{"低严重性未到间隔", 3, 9, 30*time.Minute - time.Second, false},
+		{"低严重性到达间隔", 3, 9, 30 * time.Minute, true},
+	}
+	ac := NewAlertCache(time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := &AggregatedAlert{Count: tt.count, LastSentAt: now.Add(-tt.sinceLastSent)}
+			event := collector.LogEvent{SeverityScore: tt.severity}
+			if got := ac.shouldSendMerged(agg, event, now); got != tt.want {
+				t.Errorf("shouldSendMerged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldSendMergedCustomIntervals(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ac := NewAlertCache(time.Hour)
+	ac.SetResendIntervals(ResendIntervals{High: time.Minute, Medium: 2 * time.Minute, Low: 3 * time.Minute})
+	tests := []struct {
+		severity int
+		since    time.Duration
+		want     bool
+	}{
+		{9, time.Minute, true},
+		{9, 59 * time.Second, false},
+		{6, 2 * time.Minute, true},
+		{6, time.Minute, false},
+		{1, 3 * time.Minute, true},
+		{1, 2 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		agg := &AggregatedAlert{Count: 5, LastSentAt: now.Add(-tt.since)}
+		event := collector.LogEvent{SeverityScore: tt.severity}
+		if got := ac.shouldSendMerged(agg, event, now); got != tt.want {
+			t.Errorf("severity %d since %v: shouldSendMerged() = %v, want %v", tt.severity, tt.since, got, tt.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lastSent := now.Add(-time.Minute)
+	tests := []struct {
+		name         string
+		agg          AggregatedAlert
+		event        collector.LogEvent
+		wantSend     bool
+		wantSeverity int
+	}{
+		{
+			name:         "低严重性升级为高严重性时按高严重性策略发送",
+			agg:          AggregatedAlert{Severity: 3, Count: 2, LastSentAt: lastSent},
+			event:        collector.LogEvent{SeverityScore: 8},
+			wantSend:     true,
+			wantSeverity: 8,
+		},
+		{
+			name:         "升级后超过前3次且未到间隔时不发送",
+			agg:          AggregatedAlert{Severity: 3, Count: 5, LastSentAt: lastSent},
+			event:        collector.LogEvent{SeverityScore: 9},
+			wantSend:     false,
+			wantSeverity: 9,
+		},
+		{
+			name:         "距上次发送超过重发间隔时发送",
+			agg:          AggregatedAlert{Severity: 8, Count: 10, LastAlertAt: now.Add(-time.Second), LastSentAt: now.Add(-5 * time.Minute)},
+			event:        collector.LogEvent{SeverityScore: 8},
+			wantSend:     true,
+			wantSeverity: 8,
+		},
+		{
+			name:         "低严重性事件不降低告警严重性",
+			agg:          AggregatedAlert{Severity: 8, Count: 4, LastSentAt: lastSent},
+			event:        collector.LogEvent{SeverityScore: 3},
+			wantSend:     false,
+			wantSeverity: 8,
+		},
+		{
+			name:         "首次出现 Cell Trace 时发送",
+			agg:          AggregatedAlert{Severity: 3, Count: 4, LastSentAt: lastSent},
+			event:        collector.LogEvent{SeverityScore: 3, IsCellTrace: true},
+			wantSend:     true,
+			wantSeverity: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac := NewAlertCache(time.Hour)
+			agg := tt.agg
+			count := agg.Count
+			send := ac.merge(&agg, "k1", tt.event, "", now)
+			if send != tt.wantSend {
+				t.Errorf("merge() = %v, want %v", send, tt.wantSend)
+			}
+			if agg.Severity != tt.wantSeverity {
+				t.Errorf("Severity = %d, want %d", agg.Severity, tt.wantSeverity)
+			}
+			if agg.Count != count+1 {
+				t.Errorf("Count = %d, want %d", agg.Count, count+1)
+			}
+			wantLastSent := lastSent
+			if tt.wantSend {
+				wantLastSent = now
+			}
+			if !agg.LastSentAt.Equal(wantLastSent) {
+				t.Errorf("LastSentAt = %v, want %v", agg.LastSentAt, wantLastSent)
+			}
+		})
+	}
+}
+
+func TestMergeFingerprint(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		severity     int
+		wantSeverity int
+	}{
+		{"严重性升高时重新计算指纹", 9, 9},
+		{"严重性不变时指纹不变", 5, 5},
+		{"严重性较低时指纹不变", 3, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ac := NewAlertCache(time.Hour)
+			agg := AggregatedAlert{Host: "host-a", TemplateID: "tpl", Severity: 5, Count: 1, Fingerprint: Fingerprint("host-a", "tpl", 5)}
+			ac.merge(&agg, "k1", collector.LogEvent{SeverityScore: tt.severity}, "", now)
+			if want := Fingerprint("host-a", "tpl", tt.wantSeverity); agg.Fingerprint != want {
+				t.Errorf("Fingerprint = %s, want %s", agg.Fingerprint, want)
+			}
+		})
+	}
+}
+
+func TestAddOrUpdate(t *testing.T) {
+	event := collector.LogEvent{
+		Host:          "host-a",
+		FilePath:      "/var/log/app.log",
+		RawText:       "ERROR failed to connect to database primary-db: connection refused",
+		SeverityScore: 6,
+	}
+	// 同一事件连续出现：中严重性首次和第2次立即发送，第3次在重发间隔内只合并
+	tests := []struct {
+		wantSend  bool
+		wantCount int
+	}{
+		{true, 1},
+		{true, 2},
+		{false, 3},
+	}
+	ac := NewAlertCache(time.Hour)
+	for i, tt := range tests {
+		send, agg := ac.AddOrUpdate(event, "")
+		if send != tt.wantSend || agg.Count != tt.wantCount {
+			t.Errorf("第%d次: AddOrUpdate() = (%v, Count %d), want (%v, Count %d)", i+1, send, agg.Count, tt.wantSend, tt.wantCount)
+		}
+	}
+
+	low := event
+	low.RawText = "WARN slow response from inventory service"
+	low.SeverityScore = 3
+	if send, agg := ac.AddOrUpdate(low, ""); send || agg.Count != 1 || !agg.LastSentAt.IsZero() {
+		t.Errorf("低严重性首次: AddOrUpdate() = (%v, Count %d, LastSentAt %v), want (false, Count 1, zero)", send, agg.Count, agg.LastSentAt)
+	}
+}
@@ -0,0 +1,131 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/metrics"
+)
+
+// ErrChannelOpen 告警渠道连续发送失败，熔断期间不再尝试发送
+var ErrChannelOpen = errors.New("告警渠道熔断中")
+
+// DeliveryOptions 告警发送的重试和熔断配置，对所有渠道生效，每个渠道单独计数
+type DeliveryOptions struct {
+	Retries         int           // 发送失败后的重试次数
+	RetryBackoff    time.Duration // 第一次重试前的等待时间，之后每次加倍
+	BreakerFailures int           // 连续发送失败多少次后熔断，0表示不熔断
+	BreakerCooldown time.Duration // 熔断后的冷却时间
+}
+
+var (
+	deliveryMu   sync.Mutex
+	deliveryOpts = DeliveryOptions{Retries: 2, RetryBackoff: time.Second, BreakerFailures: 5, BreakerCooldown: time.Minute}
+	breakers     = make(map[string]*channelBreaker)
+)
+
+// SetDeliveryOptions 设置告警发送的重试和熔断配置，应在发送告警之前调用
+func SetDeliveryOptions(opts DeliveryOptions) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	deliveryOpts = opts
+	breakers = make(map[string]*channelBreaker)
+}
+
+// channelBreaker 单个渠道的熔断器：连续失败达到阈值后在冷却时间内直接拒绝发送，
+// 冷却结束后放行一次试探，成功即恢复，失败则重新进入冷却
+type channelBreaker struct {
+	channel   string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// channelState 返回渠道的熔断器和当前的发送配置
+func channelState(channel string) (*channelBreaker, DeliveryOptions) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	b, ok := breakers[channel]
+	if !ok {
+		b = &channelBreaker{channel: channel, threshold: deliveryOpts.BreakerFailures, cooldown: deliveryOpts.BreakerCooldown}
+		breakers[channel] = b
+		metrics.AlertChannelCircuitOpen.WithLabelValues(channel).Set(0)
+	}
+	return b, deliveryOpts
+}
+
+// allow 判断当前是否可以尝试发送，未启用熔断时总是可以
+func (b *channelBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures < b.threshold || !time.Now().Before(b.openUntil)
+}
+
+// success 记录一次成功的发送，熔断中时恢复
+func (b *channelBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold > 0 && b.failures >= b.threshold {
+		log.Printf("✅ 告警渠道 %s 已恢复，解除熔断", b.channel)
+		metrics.AlertChannelCircuitOpen.WithLabelValues(b.channel).Set(0)
+	}
+	b.failures = 0
+}
+
+// failure 记录一次失败的发送（已用完重试），连续失败达到阈值时熔断
+func (b *channelBreaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			log.Printf("⚠️ 告警渠道 %s 连续发送失败 %d 次，熔断 %s: %v", b.channel, b.failures, b.cooldown, err)
+			metrics.AlertChannelCircuitOpen.WithLabelValues(b.channel).Set(1)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// Deliver 通过 channel 渠道（wechat、email 等）发送一条消息：失败时按退避间隔重试，
+// 并记录每次尝试的耗时、重试次数和渠道的熔断状态；渠道熔断中时不调用 send 直接返回 ErrChannelOpen
+func Deliver(channel string, send func() error) error {
+	breaker, opts := channelState(channel)
+	if !breaker.allow() {
+		metrics.AlertDeliveryCount.WithLabelValues(channel, "rejected").Inc()
+		return fmt.Errorf("%s: %w", channel, ErrChannelOpen)
+	}
+
+	var err error
+	backoff := opts.RetryBackoff
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 {
+			metrics.AlertDeliveryRetryCount.WithLabelValues(channel).Inc()
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		start := time.Now()
+		err = send()
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		metrics.AlertDeliveryDuration.WithLabelValues(channel, result).Observe(time.Since(start).Seconds())
+		if err == nil {
+			metrics.AlertDeliveryCount.WithLabelValues(channel, "success").Inc()
+			breaker.success()
+			return nil
+		}
+	}
+	metrics.AlertDeliveryCount.WithLabelValues(channel, "error").Inc()
+	breaker.failure(err)
+	return err
+}
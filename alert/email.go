@@ -0,0 +1,53 @@
+package alert
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPConfig 邮件发送配置
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+// SendEmail 通过SMTP发送纯文本邮件
+func SendEmail(cfg SMTPConfig, to []string, subject, body string) error {
+	if cfg.Host == "" || len(to) == 0 {
+		return fmt.Errorf("邮件发送未配置")
+	}
+	port := cfg.Port
+	if port == "" {
+		port = "25"
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.User
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64.StdEncoding.EncodeToString([]byte(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if cfg.User != "" {
+		auth = smtp.PlainAuth("", cfg.User, cfg.Password, cfg.Host)
+	}
+	if err := smtp.SendMail(net.JoinHostPort(cfg.Host, port), auth, from, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package alert
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// feedbackURL 本服务对外可访问的地址，为空时告警中不附带评价链接
+var feedbackURL string
+
+// SetFeedbackURL 设置生成AI分析评价链接使用的服务地址
+func SetFeedbackURL(base string) {
+	feedbackURL = strings.TrimRight(base, "/")
+}
+
+// feedbackLinks 生成评价AI分析结果的链接
+func feedbackLinks(alert AggregatedAlert) string {
+	eventID := alert.LastEventID
+	if eventID == "" {
+		eventID = alert.EventID
+	}
+	if feedbackURL == "" || eventID == "" || alert.AiResult == "" {
+		return ""
+	}
+	link := func(rating string) string {
+		return fmt.Sprintf("%s/api/feedback?event_id=%s&rating=%s", feedbackURL, url.QueryEscape(eventID), rating)
+	}
+	return fmt.Sprintf("\n**📝 分析评价:** [👍 有帮助](%s)　[👎 分析有误](%s)\n", link("helpful"), link("wrong"))
+}
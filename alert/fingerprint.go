@@ -0,0 +1,29 @@
+package alert
+
+import (
+	"strconv"
+
+	"github.com/prometheus/common/model"
+)
+
+// 告警标签名，与 Alertmanager 中的标签保持一致，便于双方的静默规则互相对应
+const (
+	LabelHost       = "host"
+	LabelTemplateID = "template_id"
+	LabelSeverity   = "severity"
+)
+
+// Labels 生成告警的标签集合
+func Labels(host, templateID string, severity int) model.LabelSet {
+	return model.LabelSet{
+		LabelHost:       model.LabelValue(host),
+		LabelTemplateID: model.LabelValue(templateID),
+		LabelSeverity:   model.LabelValue(strconv.Itoa(severity)),
+	}
+}
+
+// Fingerprint 按 Alertmanager 的方式计算告警指纹
+// 标签按名称排序后做 FNV-1a 哈希，结果与 Alertmanager 中同一标签集合的指纹完全相同
+func Fingerprint(host, templateID string, severity int) string {
+	return Labels(host, templateID, severity).Fingerprint().String()
+}
@@ -0,0 +1,358 @@
+package alert
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/atomicfile"
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 故障的状态
+const (
+	IncidentOpen     = "open"     // 刚发现的故障
+	IncidentUpdating = "updating" // 故障仍有新的事件并入
+	IncidentResolved = "resolved" // 超过关联窗口没有新事件，视为已恢复
+)
+
+// 故障中最多记录的主机、日志文件和日志模板数
+const incidentMaxMembers = 20
+
+// 故障时间线中最多记录的节点数，超过后不再记录，最早的传播过程最有助于定位根因
+const incidentMaxTimeline = 30
+
+// 保存故障归并状态的间隔，进程崩溃时最多丢失这段时间内的更新
+const incidentSaveInterval = 30 * time.Second
+
+// 时间线节点的类型
+const (
+	TimelineFirst      = "first"       // 故障的首个事件
+	TimelineEscalation = "escalation"  // 严重性升高
+	TimelineSpreadHost = "spread_host" // 扩散到新的主机
+	TimelineSpreadFile = "spread_file" // 扩散到同一主机上新的日志文件
+)
+
+// TimelineEntry 故障时间线上的一个节点，记录故障首次出现、严重性升高和扩散到新主机或文件的时刻
+type TimelineEntry struct {
+	At       time.Time
+	Kind     string
+	Host     string
+	FilePath string
+	EventID  string
+	Severity int
+	Summary  string // 事件的第一行
+}
+
+// Incident 故障：同一租户内同一主机或同一日志模板在关联窗口内持续出现的事件归为一个故障，
+// 处理人员面对的是一个故障而不是几十条事件
+type Incident struct {
+	ID          string
+	Tenant      string
+	Status      string
+	Title       string // 首个事件的第一行
+	Hosts       []string
+	Files       []string // 主机:文件路径
+	Templates   []string
+	EventCount  int
+	MaxSeverity int
+	OpenedAt    time.Time
+	UpdatedAt   time.Time
+	ResolvedAt  time.Time
+	LastEventID string
+	Timeline    []TimelineEntry // 按时间排列的关键节点
+}
+
+// IncidentTracker 将事件归并为故障并维护故障的生命周期
+type IncidentTracker struct {
+	window time.Duration
+
+	mu         sync.Mutex
+	incidents  map[string]*Incident // 故障ID → 未恢复的故障
+	byHost     map[string]string    // 租户+主机 → 故障ID
+	byTemplate map[string]string    // 租户+日志模板 → 故障ID
+}
+
+// NewIncidentTracker 创建故障跟踪器，window<=0 时返回nil表示不启用
+func NewIncidentTracker(window time.Duration) *IncidentTracker {
+	if window <= 0 {
+		return nil
+	}
+	return &IncidentTracker{
+		window:     window,
+		incidents:  make(map[string]*Incident),
+		byHost:     make(map[string]string),
+		byTemplate: make(map[string]string),
+	}
+}
+
+// incidentTemplate 返回用于关联的日志模板，速率异常事件与其原始模板关联
+func incidentTemplate(templateID string) string {
+	return strings.TrimPrefix(templateID, "anomaly-")
+}
+
+// addMember 向列表中加入不重复的成员，超过上限后不再加入，返回是否新加入
+func addMember(members *[]string, v string) bool {
+	if v == "" || len(*members) >= incidentMaxMembers {
+		return false
+	}
+	for _, m := range *members {
+		if m == v {
+			return false
+		}
+	}
+	*members = append(*members, v)
+	return true
+}
+
+// addTimeline 在故障时间线上记录一个节点，超过上限后不再记录
+func (inc *Incident) addTimeline(kind string, event collector.LogEvent, at time.Time) {
+	if len(inc.Timeline) >= incidentMaxTimeline {
+		return
+	}
+	inc.Timeline = append(inc.Timeline, TimelineEntry{
+		At:       at,
+		Kind:     kind,
+		Host:     event.Host,
+		FilePath: event.FilePath,
+		EventID:  event.EventID,
+		Severity: event.SeverityScore,
+		Summary:  firstLine(event.RawText),
+	})
+}
+
+// copyIncident 返回故障的副本，调用方可在锁外使用
+func copyIncident(inc *Incident) Incident {
+	c := *inc
+	c.Hosts = append([]string(nil), inc.Hosts...)
+	c.Files = append([]string(nil), inc.Files...)
+	c.Timeline = append([]TimelineEntry(nil), inc.Timeline...)
+	c.Templates = append([]string(nil), inc.Templates...)
+	return c
+}
+
+// Track 将事件归入故障并返回故障的当前状态：同一主机或同一日志模板在关联窗口内有未恢复的故障时并入，否则新建故障。
+// 首个事件、严重性升高以及扩散到新主机或新日志文件的事件记入故障时间线。t 为nil时返回空故障
+func (t *IncidentTracker) Track(event collector.LogEvent) Incident {
+	if t == nil {
+		return Incident{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	hostKey := event.Tenant + "\x00" + event.Host
+	templateKey := event.Tenant + "\x00" + incidentTemplate(event.TemplateID)
+	inc := t.active(t.byHost[hostKey], now)
+	if inc == nil && event.TemplateID != "" {
+		inc = t.active(t.byTemplate[templateKey], now)
+	}
+
+	var kind string
+	if inc == nil {
+		sum := md5.Sum([]byte(hostKey + "\x00" + templateKey))
+		inc = &Incident{
+			ID:       "inc-" + now.Format("20060102-150405") + "-" + hex.EncodeToString(sum[:3]),
+			Tenant:   event.Tenant,
+			Status:   IncidentOpen,
+			Title:    firstLine(event.RawText),
+			OpenedAt: now,
+		}
+		t.incidents[inc.ID] = inc
+		metrics.IncidentOpenedCount.Inc()
+		metrics.IncidentsOpen.Set(float64(len(t.incidents)))
+		kind = TimelineFirst
+	} else {
+		inc.Status = IncidentUpdating
+	}
+	newHost := addMember(&inc.Hosts, event.Host)
+	newFile := event.FilePath != "" && addMember(&inc.Files, event.Host+":"+event.FilePath)
+	addMember(&inc.Templates, incidentTemplate(event.TemplateID))
+	if kind == "" {
+		switch {
+		case newHost:
+			kind = TimelineSpreadHost
+		case newFile:
+			kind = TimelineSpreadFile
+		case event.SeverityScore > inc.MaxSeverity:
+			kind = TimelineEscalation
+		}
+	}
+	if kind != "" {
+		inc.addTimeline(kind, event, now)
+	}
+	inc.EventCount++
+	inc.MaxSeverity = max(inc.MaxSeverity, event.SeverityScore)
+	inc.UpdatedAt = now
+	inc.LastEventID = event.EventID
+
+	t.byHost[hostKey] = inc.ID
+	if event.TemplateID != "" {
+		t.byTemplate[templateKey] = inc.ID
+	}
+	return copyIncident(inc)
+}
+
+// timelineLabels 时间线节点类型的说明
+var timelineLabels = map[string]string{
+	TimelineFirst:      "首次出现",
+	TimelineEscalation: "严重性升高",
+	TimelineSpreadHost: "扩散到主机",
+	TimelineSpreadFile: "扩散到文件",
+}
+
+// timelineText 生成故障时间线的文本，每个节点一行
+func timelineText(timeline []TimelineEntry) string {
+	var b strings.Builder
+	for _, e := range timeline {
+		fmt.Fprintf(&b, "%s %s %s %s 严重性%d: %s\n",
+			e.At.Format("15:04:05"), timelineLabels[e.Kind], e.Host, e.FilePath, e.Severity, e.Summary)
+	}
+	return b.String()
+}
+
+// active 返回关联窗口内仍有事件的故障
+func (t *IncidentTracker) active(id string, now time.Time) *Incident {
+	inc, ok := t.incidents[id]
+	if !ok || now.Sub(inc.UpdatedAt) > t.window {
+		return nil
+	}
+	return inc
+}
+
+// Cleanup 将超过关联窗口没有新事件的故障标记为已恢复并返回，调用方负责更新存储的故障记录
+func (t *IncidentTracker) Cleanup() []Incident {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var resolved []Incident
+	for id, inc := range t.incidents {
+		if now.Sub(inc.UpdatedAt) <= t.window {
+			continue
+		}
+		inc.Status = IncidentResolved
+		inc.ResolvedAt = now
+		resolved = append(resolved, copyIncident(inc))
+		delete(t.incidents, id)
+	}
+	metrics.IncidentsOpen.Set(float64(len(t.incidents)))
+	for key, id := range t.byHost {
+		if _, ok := t.incidents[id]; !ok {
+			delete(t.byHost, key)
+		}
+	}
+	for key, id := range t.byTemplate {
+		if _, ok := t.incidents[id]; !ok {
+			delete(t.byTemplate, key)
+		}
+	}
+	return resolved
+}
+
+// incidentState 持久化的未恢复故障及主机、日志模板到故障的映射
+type incidentState struct {
+	Incidents  []Incident        `json:"incidents"`
+	ByHost     map[string]string `json:"by_host"`
+	ByTemplate map[string]string `json:"by_template"`
+}
+
+// Save 将未恢复的故障及归并映射写入 path，先写临时文件再替换，崩溃时不会留下不完整的状态文件
+func (t *IncidentTracker) Save(path string) error {
+	if t == nil || path == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	state := incidentState{ByHost: t.byHost, ByTemplate: t.byTemplate}
+	for _, inc := range t.incidents {
+		state.Incidents = append(state.Incidents, *inc)
+	}
+	data, err := json.Marshal(state)
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("编码故障归并状态失败: %w", err)
+	}
+
+	if err := atomicfile.WriteFile(path, data); err != nil {
+		return fmt.Errorf("保存故障归并状态失败: %w", err)
+	}
+	return nil
+}
+
+// Load 从 path 恢复上次保存的未恢复故障，返回恢复的故障数；状态文件不存在时不恢复。
+// 按当前的关联窗口判断是否过期：窗口内的故障继续归并新事件；已超过窗口的故障不再归并，
+// 由下次 Cleanup 标记为已恢复，存储中的故障记录随之更新
+func (t *IncidentTracker) Load(path string) (int, error) {
+	if t == nil || path == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取故障归并状态失败: %w", err)
+	}
+	var state incidentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("解析故障归并状态失败: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	loaded := 0
+	for _, inc := range state.Incidents {
+		if _, ok := t.incidents[inc.ID]; ok || inc.ID == "" {
+			continue
+		}
+		t.incidents[inc.ID] = &inc
+		loaded++
+	}
+	for key, id := range state.ByHost {
+		if _, ok := t.byHost[key]; !ok && t.active(id, now) != nil {
+			t.byHost[key] = id
+		}
+	}
+	for key, id := range state.ByTemplate {
+		if _, ok := t.byTemplate[key]; !ok && t.active(id, now) != nil {
+			t.byTemplate[key] = id
+		}
+	}
+	metrics.IncidentsOpen.Set(float64(len(t.incidents)))
+	return loaded, nil
+}
+
+// RunSave 定期将故障归并状态保存到 path，直到 ctx 结束，使进程崩溃重启后事件仍并入重启前的故障
+func (t *IncidentTracker) RunSave(ctx context.Context, path string) {
+	if t == nil || path == "" {
+		return
+	}
+	ticker := time.NewTicker(incidentSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Save(path); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+	}
+}
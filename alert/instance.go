@@ -0,0 +1,31 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// instance 告警消息和工单中显示的分析器实例
+var instance struct {
+	id     string
+	labels map[string]string
+}
+
+// SetInstance 设置告警消息和工单中显示的实例标识和静态标签，应在发送告警之前调用
+func SetInstance(id string, labels map[string]string) {
+	instance.id, instance.labels = id, labels
+}
+
+// instanceText 返回实例标识和按名称排序的标签，如 "logai-01 (env=prod, region=cn-east)"，未设置时返回空字符串
+func instanceText() string {
+	if len(instance.labels) == 0 {
+		return instance.id
+	}
+	pairs := make([]string, 0, len(instance.labels))
+	for name, value := range instance.labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.TrimSpace(fmt.Sprintf("%s (%s)", instance.id, strings.Join(pairs, ", ")))
+}
@@ -0,0 +1,281 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OnCallResolver 解析当前值班人员
+// 返回的ID用于在告警消息中@对应人员（企业微信为成员UserID）
+type OnCallResolver interface {
+	OnCall(now time.Time) ([]string, error)
+}
+
+// Rotation 简单的轮值表，从 Start 开始每个 Shift 轮换到下一位成员
+type Rotation struct {
+	Start   time.Time `json:"start"`
+	Shift   string    `json:"shift"`   // 每班时长，如 "24h"、"168h"
+	Members []string  `json:"members"` // 按轮值顺序排列的成员ID
+	Backup  []string  `json:"backup"`  // 始终一并通知的备岗人员（可选）
+}
+
+// RotationResolver 基于本地轮值文件的值班解析
+type RotationResolver struct {
+	rotation Rotation
+	shift    time.Duration
+}
+
+// NewRotationResolver 从JSON文件加载轮值表
+func NewRotationResolver(path string) (*RotationResolver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取轮值文件失败: %w", err)
+	}
+	var rotation Rotation
+	if err := json.Unmarshal(data, &rotation); err != nil {
+		return nil, fmt.Errorf("解析轮值文件失败: %w", err)
+	}
+	if len(rotation.Members) == 0 {
+		return nil, fmt.Errorf("轮值文件中没有成员")
+	}
+	shift, err := time.ParseDuration(rotation.Shift)
+	if err != nil || shift <= 0 {
+		return nil, fmt.Errorf("无效的轮值时长: %s", rotation.Shift)
+	}
+	return &RotationResolver{rotation: rotation, shift: shift}, nil
+}
+
+// OnCall 返回当前班次的值班人员
+func (r *RotationResolver) OnCall(now time.Time) ([]string, error) {
+	elapsed := now.Sub(r.rotation.Start)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	idx := int(elapsed/r.shift) % len(r.rotation.Members)
+	return append([]string{r.rotation.Members[idx]}, r.rotation.Backup...), nil
+}
+
+// PagerDutyResolver 通过 PagerDuty Schedules API 查询值班人员
+type PagerDutyResolver struct {
+	Token      func() string // 每次查询时读取，密钥轮换后无需重启
+	ScheduleID string
+	client     *http.Client
+}
+
+// NewPagerDutyResolver 创建 PagerDuty 值班解析器，token 返回当前的 API Token
+func NewPagerDutyResolver(token func() string, scheduleID string) *PagerDutyResolver {
+	return &PagerDutyResolver{
+		Token:      token,
+		ScheduleID: scheduleID,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// OnCall 查询 PagerDuty 当前值班人员，返回邮箱前缀作为成员ID
+func (p *PagerDutyResolver) OnCall(now time.Time) ([]string, error) {
+	query := url.Values{}
+	query.Set("schedule_ids[]", p.ScheduleID)
+	query.Set("include[]", "users")
+	query.Set("since", now.Format(time.RFC3339))
+	query.Set("until", now.Add(time.Minute).Format(time.RFC3339))
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.pagerduty.com/oncalls?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "Token token="+p.Token())
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+
+	var result struct {
+		OnCalls []struct {
+			User struct {
+				Email string `json:"email"`
+			} `json:"user"`
+		} `json:"oncalls"`
+	}
+	if err := doJSON(p.client, req, &result); err != nil {
+		return nil, fmt.Errorf("查询PagerDuty值班失败: %w", err)
+	}
+
+	var ids []string
+	for _, oc := range result.OnCalls {
+		ids = appendUnique(ids, emailToID(oc.User.Email))
+	}
+	return ids, nil
+}
+
+// OpsgenieResolver 通过 Opsgenie Schedule API 查询值班人员
+type OpsgenieResolver struct {
+	APIKey   func() string // 每次查询时读取，密钥轮换后无需重启
+	Schedule string        // 值班表名称或ID
+	client   *http.Client
+}
+
+// NewOpsgenieResolver 创建 Opsgenie 值班解析器，apiKey 返回当前的 API Key
+func NewOpsgenieResolver(apiKey func() string, schedule string) *OpsgenieResolver {
+	return &OpsgenieResolver{
+		APIKey:   apiKey,
+		Schedule: schedule,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Opsgenie 值班表ID的格式（UUID），其余按值班表名称查询
+var opsgenieIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// OnCall 查询 Opsgenie 当前值班人员，返回邮箱前缀作为成员ID
+func (o *OpsgenieResolver) OnCall(now time.Time) ([]string, error) {
+	query := url.Values{}
+	identifierType := "name"
+	if opsgenieIDPattern.MatchString(o.Schedule) {
+		identifierType = "id"
+	}
+	query.Set("scheduleIdentifierType", identifierType)
+	query.Set("flat", "true")
+	query.Set("date", now.Format(time.RFC3339))
+
+	endpoint := fmt.Sprintf("https://api.opsgenie.com/v2/schedules/%s/on-calls?%s", url.PathEscape(o.Schedule), query.Encode())
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "GenieKey "+o.APIKey())
+
+	var result struct {
+		Data struct {
+			OnCallRecipients []string `json:"onCallRecipients"`
+		} `json:"data"`
+	}
+	if err := doJSON(o.client, req, &result); err != nil {
+		return nil, fmt.Errorf("查询Opsgenie值班失败: %w", err)
+	}
+
+	var ids []string
+	for _, recipient := range result.Data.OnCallRecipients {
+		ids = appendUnique(ids, emailToID(recipient))
+	}
+	return ids, nil
+}
+
+// 值班查询失败后至少等待该时长再重试，避免外部API不可用时每条告警都等待一次超时
+const onCallRetryBackoff = time.Minute
+
+// CachedResolver 缓存值班查询结果，避免每条告警都请求外部API
+// 查询失败时继续使用上一次成功的结果，并在退避时间内不再重试；同一时刻只有一个查询，
+// 查询期间有缓存的调用方直接使用缓存，没有缓存的等待查询结果
+type CachedResolver struct {
+	resolver OnCallResolver
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	ids       []string
+	fetchedAt time.Time
+	err       error         // 最近一次查询失败的错误
+	retryAt   time.Time     // 查询失败后在该时间之前不再重试
+	fetching  chan struct{} // 正在进行的查询，完成时关闭
+}
+
+// NewCachedResolver 创建带缓存的值班解析器
+func NewCachedResolver(resolver OnCallResolver, ttl time.Duration) *CachedResolver {
+	return &CachedResolver{resolver: resolver, ttl: ttl}
+}
+
+// OnCall 返回缓存中的值班人员，缓存过期时重新查询；外部API请求不持有锁
+func (c *CachedResolver) OnCall(now time.Time) ([]string, error) {
+	c.mu.Lock()
+	if !c.fetchedAt.IsZero() && now.Sub(c.fetchedAt) < c.ttl {
+		defer c.mu.Unlock()
+		return c.ids, nil
+	}
+	if now.Before(c.retryAt) {
+		defer c.mu.Unlock()
+		return c.cachedLocked()
+	}
+	if done := c.fetching; done != nil {
+		if c.ids != nil {
+			defer c.mu.Unlock()
+			return c.ids, nil
+		}
+		c.mu.Unlock()
+		<-done
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.cachedLocked()
+	}
+	done := make(chan struct{})
+	c.fetching = done
+	c.mu.Unlock()
+
+	ids, err := c.resolver.OnCall(now)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetching = nil
+	close(done)
+	if err != nil {
+		c.err = err
+		backoff := onCallRetryBackoff
+		if c.ttl > 0 && c.ttl < backoff {
+			backoff = c.ttl
+		}
+		c.retryAt = now.Add(backoff)
+		return c.cachedLocked()
+	}
+	c.ids, c.fetchedAt, c.err, c.retryAt = ids, now, nil, time.Time{}
+	return ids, nil
+}
+
+// cachedLocked 返回上一次成功查询的结果，从未成功时返回最近一次查询的错误；调用方持有锁
+func (c *CachedResolver) cachedLocked() ([]string, error) {
+	if c.ids != nil || c.err == nil {
+		return c.ids, nil
+	}
+	return nil, c.err
+}
+
+// doJSON 发送请求并将JSON响应解析到out
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("返回错误状态码: %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+// emailToID 取邮箱前缀作为成员ID
+func emailToID(email string) string {
+	id, _, _ := strings.Cut(email, "@")
+	return id
+}
+
+func appendUnique(ids []string, id string) []string {
+	if id == "" {
+		return ids
+	}
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
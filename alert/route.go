@@ -0,0 +1,78 @@
+package alert
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"log-ai-analyzer/collector"
+)
+
+// Route 告警路由：满足所有配置条件的事件的告警发送到指定的企业微信机器人，并@指定的成员。
+// 没有条件的路由匹配所有事件，没有路由匹配时使用 AI_WECHAT_WEBHOOK
+type Route struct {
+	Name        string   // 路由名称，用于日志
+	Tenants     []string // 事件所属租户，任一匹配
+	Services    []string // 事件所属服务，任一匹配
+	Teams       []string // 负责团队，任一匹配
+	Hosts       []string // 主机名通配符，任一匹配
+	Files       []string // 日志文件路径通配符，任一匹配
+	MinSeverity int      // 最低严重性，0表示不限制
+	Webhook     string   // 企业微信机器人 Webhook 地址
+	Mentions    []string // 额外@的成员ID，与值班人员一起通知
+}
+
+// matches 判断事件是否满足路由的所有条件
+func (r *Route) matches(event collector.LogEvent) bool {
+	if event.SeverityScore < r.MinSeverity {
+		return false
+	}
+	if len(r.Tenants) > 0 && !slices.Contains(r.Tenants, event.Tenant) {
+		return false
+	}
+	if len(r.Services) > 0 && !slices.Contains(r.Services, event.Service) {
+		return false
+	}
+	if len(r.Teams) > 0 && !slices.Contains(r.Teams, event.Team) {
+		return false
+	}
+	if len(r.Hosts) > 0 && !matchGlob(r.Hosts, event.Host) {
+		return false
+	}
+	return len(r.Files) == 0 || matchGlob(r.Files, event.FilePath)
+}
+
+// matchGlob 判断 s 是否匹配任一通配符
+func matchGlob(globs []string, s string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 当前生效的告警路由，由 Kubernetes AlertRoute 资源等外部配置源更新
+var routes struct {
+	mu   sync.RWMutex
+	list []Route
+}
+
+// SetRoutes 替换告警路由，按顺序匹配，第一条命中的路由生效
+func SetRoutes(list []Route) {
+	routes.mu.Lock()
+	defer routes.mu.Unlock()
+	routes.list = list
+}
+
+// MatchRoute 返回事件命中的第一条告警路由，没有命中时返回 false
+func MatchRoute(event collector.LogEvent) (Route, bool) {
+	routes.mu.RLock()
+	defer routes.mu.RUnlock()
+	for i := range routes.list {
+		if routes.list[i].matches(event) {
+			return routes.list[i], true
+		}
+	}
+	return Route{}, false
+}
@@ -0,0 +1,28 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RunbookLink 告警关联的运维手册
+type RunbookLink struct {
+	Title string
+	URL   string
+}
+
+// runbookLinks 生成告警关联的运维手册链接
+func runbookLinks(alert AggregatedAlert) string {
+	if len(alert.Runbooks) == 0 {
+		return ""
+	}
+	links := make([]string, 0, len(alert.Runbooks))
+	for _, r := range alert.Runbooks {
+		if r.URL != "" {
+			links = append(links, fmt.Sprintf("[%s](%s)", r.Title, r.URL))
+		} else {
+			links = append(links, r.Title)
+		}
+	}
+	return fmt.Sprintf("\n**📖 运维手册:** %s\n", strings.Join(links, "　"))
+}
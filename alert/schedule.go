@@ -0,0 +1,146 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// SeverityRule 某个时间段内发送告警所需的最低严重性
+type SeverityRule struct {
+	Days        [7]bool // 生效的星期，下标为 time.Weekday
+	Start       int     // 开始时间（当天分钟数）
+	End         int     // 结束时间（当天分钟数），小于Start表示跨越午夜
+	MinSeverity int
+}
+
+// SeveritySchedule 按时间段划分的告警阈值表，按顺序匹配第一条规则
+type SeveritySchedule []SeverityRule
+
+// ParseSeveritySchedule 解析告警阈值时间表
+// 格式: "[星期范围@]HH:MM-HH:MM=最低严重性"，多条规则用逗号分隔，例如：
+//
+//	Mon-Fri@09:00-18:00=5,18:00-09:00=8
+func ParseSeveritySchedule(spec string) (SeveritySchedule, error) {
+	var schedule SeveritySchedule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		rule, err := parseSeverityRule(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的告警阈值规则 %q: %w", item, err)
+		}
+		schedule = append(schedule, rule)
+	}
+	return schedule, nil
+}
+
+func parseSeverityRule(item string) (SeverityRule, error) {
+	var rule SeverityRule
+
+	timeRange, severity, ok := strings.Cut(item, "=")
+	if !ok {
+		return rule, fmt.Errorf("缺少 =最低严重性")
+	}
+	minSeverity, err := strconv.Atoi(strings.TrimSpace(severity))
+	if err != nil || minSeverity < 0 || minSeverity > 10 {
+		return rule, fmt.Errorf("最低严重性必须是0-10之间的整数")
+	}
+	rule.MinSeverity = minSeverity
+
+	days := "mon-sun"
+	if d, t, ok := strings.Cut(timeRange, "@"); ok {
+		days, timeRange = d, t
+	}
+	if err := parseDays(strings.ToLower(strings.TrimSpace(days)), &rule.Days); err != nil {
+		return rule, err
+	}
+
+	start, end, ok := strings.Cut(timeRange, "-")
+	if !ok {
+		return rule, fmt.Errorf("时间段格式应为 HH:MM-HH:MM")
+	}
+	if rule.Start, err = parseClock(start); err != nil {
+		return rule, err
+	}
+	if rule.End, err = parseClock(end); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+// parseDays 解析星期范围，如 mon-fri、sat、sun
+func parseDays(spec string, days *[7]bool) error {
+	for _, part := range strings.Split(spec, "|") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, ok := weekdayNames[from]
+		if !ok {
+			return fmt.Errorf("无效的星期: %s", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdayNames[to]; !ok {
+				return fmt.Errorf("无效的星期: %s", to)
+			}
+		}
+		// 支持 sat-sun 或 fri-mon 这样跨周的范围
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock 将 HH:MM 解析为当天分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间: %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// MinSeverity 返回指定时刻生效的最低告警严重性，没有匹配的规则时返回false
+func (s SeveritySchedule) MinSeverity(t time.Time) (int, bool) {
+	minute := t.Hour()*60 + t.Minute()
+	for _, rule := range s {
+		if rule.matches(t.Weekday(), minute) {
+			return rule.MinSeverity, true
+		}
+	}
+	return 0, false
+}
+
+func (r SeverityRule) matches(day time.Weekday, minute int) bool {
+	if r.Start == r.End {
+		// 开始与结束相同表示全天
+		return r.Days[day]
+	}
+	if r.Start < r.End {
+		return r.Days[day] && minute >= r.Start && minute < r.End
+	}
+	// 跨越午夜的时间段，午夜之后的部分属于前一天的规则
+	if minute >= r.Start {
+		return r.Days[day]
+	}
+	if minute < r.End {
+		return r.Days[(day+6)%7]
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package alert
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// simhash 汉明距离阈值，不超过该距离的告警优先计算编辑距离
+// 64位指纹下16位的差异足以覆盖绝大多数编辑相似度达到阈值的内容，其余的由全量比较兜底
+const simhashMaxDistance = 16
+
+// indexEntry 相似度索引中的一条告警
+type indexEntry struct {
+	simhash uint64
+	content string
+}
+
+// similarIndex 按主机+文件分组并记录 simhash 的相似告警索引
+// 新事件先与同组、simhash 接近的告警计算编辑距离，通常第一个候选即命中；
+// 接近的告警都不相似时再与同组其余告警逐一比较，合并结果与逐一比较全部告警相同
+type similarIndex struct {
+	mu        sync.RWMutex
+	scopes    map[string]map[string]indexEntry // scope -> 告警键 -> 索引项
+	threshold float64                          // 编辑相似度（0-100）不低于该值时视为相似
+}
+
+func newSimilarIndex() *similarIndex {
+	return &similarIndex{scopes: make(map[string]map[string]indexEntry), threshold: 90}
+}
+
+// indexScope 相似告警只在同一主机、同一文件内合并
+func indexScope(host, filePath string) string {
+	return host + "|" + filePath
+}
+
+// put 添加或更新告警的索引项
+func (idx *similarIndex) put(scope, key, content string) {
+	entry := indexEntry{simhash: simhash(content), content: content}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	entries, ok := idx.scopes[scope]
+	if !ok {
+		entries = make(map[string]indexEntry)
+		idx.scopes[scope] = entries
+	}
+	entries[key] = entry
+}
+
+// remove 删除告警的索引项
+func (idx *similarIndex) remove(scope, key string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	entries := idx.scopes[scope]
+	delete(entries, key)
+	if len(entries) == 0 {
+		delete(idx.scopes, scope)
+	}
+}
+
+// lookup 返回与内容足够相似的告警键，没有时返回空字符串
+// 候选按 simhash 距离从近到远依次计算编辑距离，编辑距离计算在索引锁之外进行；
+// simhash 距离不超过 simhashMaxDistance 的候选都不相似时，继续比较距离更远的告警
+func (idx *similarIndex) lookup(scope, content string) string {
+	hash := simhash(content)
+
+	type candidate struct {
+		key      string
+		content  string
+		distance int
+	}
+	var near, far []candidate
+
+	idx.mu.RLock()
+	for key, entry := range idx.scopes[scope] {
+		c := candidate{key: key, content: entry.content, distance: bits.OnesCount64(hash ^ entry.simhash)}
+		if c.distance <= simhashMaxDistance {
+			near = append(near, c)
+		} else {
+			far = append(far, c)
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(near, func(i, j int) bool {
+		return near[i].distance < near[j].distance
+	})
+	for _, candidates := range [][]candidate{near, far} {
+		for _, c := range candidates {
+			if calculateSimilarity(content, c.content) >= idx.threshold {
+				return c.key
+			}
+		}
+	}
+	return ""
+}
+
+// simhash 计算内容的64位 simhash 指纹，相似内容的指纹汉明距离较小
+func simhash(content string) uint64 {
+	var weights [64]int
+	for _, token := range strings.Fields(normalizeContent(content)) {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for i, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
@@ -0,0 +1,105 @@
+package alert
+
+import "testing"
+
+func TestSimilarIndexLookup(t *testing.T) {
+	const (
+		base    = "ERROR failed to connect to database primary-db while executing query on table orders: connection refused"
+		similar = "ERROR failed to connect to database primary-db while executing query on table users: connection refused"
+		other   = "WARN cache eviction rate above threshold for region cn-north, consider increasing capacity"
+	)
+	scope := indexScope("host-a", "/var/log/app.log")
+
+	tests := []struct {
+		name    string
+		scope   string
+		content string
+		want    string
+	}{
+		{"相同内容", scope, base, "k1"},
+		{"内容相似", scope, similar, "k1"},
+		{"内容不同", scope, other, ""},
+		{"其他文件", indexScope("host-a", "/var/log/other.log"), base, ""},
+		{"其他主机", indexScope("host-b", "/var/log/app.log"), base, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newSimilarIndex()
+			idx.put(scope, "k1", base)
+			if got := idx.lookup(tt.scope, tt.content); got != tt.want {
+				t.Errorf("lookup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarIndexRemove(t *testing.T) {
+	const content = "ERROR request timeout after 30s calling payment service"
+	scope := indexScope("host-a", "/var/log/app.log")
+	idx := newSimilarIndex()
+	idx.put(scope, "k1", content)
+	idx.remove(scope, "k1")
+	if got := idx.lookup(scope, content); got != "" {
+		t.Errorf("lookup() after remove = %q, want empty", got)
+	}
+	if _, ok := idx.scopes[scope]; ok {
+		t.Error("empty scope was not deleted")
+	}
+}
+
+func TestSimilarIndexSimhashDistance(t *testing.T) {
+	const content = "ERROR request timeout after 30s calling payment service"
+	scope := indexScope("host-a", "/var/log/app.log")
+
+	// 索引项内容与查询相同，只改变 simhash 的差异位数：距离超过阈值的告警同样会被比较，合并结果不受指纹影响
+	tests := []struct {
+		name     string
+		distance int
+		want     string
+	}{
+		{"距离为0", 0, "k1"},
+		{"距离等于阈值", simhashMaxDistance, "k1"},
+		{"距离超过阈值时全量比较兜底", simhashMaxDistance + 1, "k1"},
+		{"指纹完全不同时全量比较兜底", 64, "k1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mask := uint64(1)<<uint(tt.distance) - 1 // 移位数为64时结果为0，mask 为全1
+			idx := newSimilarIndex()
+			idx.scopes[scope] = map[string]indexEntry{
+				"k1": {simhash: simhash(content) ^ mask, content: content},
+			}
+			if got := idx.lookup(scope, content); got != tt.want {
+				t.Errorf("lookup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarIndexPrefersWithinDistance(t *testing.T) {
+	const content = "ERROR request timeout after 30s calling payment service"
+	scope := indexScope("host-a", "/var/log/app.log")
+	hash := simhash(content)
+	idx := newSimilarIndex()
+	idx.scopes[scope] = map[string]indexEntry{
+		"beyond": {simhash: hash ^ (uint64(1)<<(simhashMaxDistance+1) - 1), content: content},
+		"within": {simhash: hash ^ (uint64(1)<<simhashMaxDistance - 1), content: content},
+	}
+	if got := idx.lookup(scope, content); got != "within" {
+		t.Errorf("lookup() = %q, want %q", got, "within")
+	}
+}
+
+func TestSimilarIndexPrefersCloserSimhash(t *testing.T) {
+	const content = "ERROR request timeout after 30s calling payment service"
+	scope := indexScope("host-a", "/var/log/app.log")
+	hash := simhash(content)
+	idx := newSimilarIndex()
+	idx.scopes[scope] = map[string]indexEntry{
+		"far":  {simhash: hash ^ 0xff, content: content},
+		"near": {simhash: hash ^ 0x1, content: content},
+	}
+	if got := idx.lookup(scope, content); got != "near" {
+		t.Errorf("lookup() = %q, want %q", got, "near")
+	}
+}
@@ -0,0 +1,175 @@
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 日志风暴告警中展示的模式数量
+const stormTopPatterns = 5
+
+// stormPattern 风暴期间某个日志模板的统计
+type stormPattern struct {
+	count  int
+	sample string
+}
+
+// hostStorm 单个主机的风暴检测状态
+type hostStorm struct {
+	events       []time.Time // 窗口内的事件时间
+	active       bool
+	startedAt    time.Time
+	lastNotified time.Time
+	total        int // 本次风暴累计事件数
+	maxSeverity  int
+	patterns     map[string]*stormPattern
+}
+
+// StormDetector 日志风暴检测
+// 单个主机在窗口内的事件数超过阈值时进入风暴状态，期间逐条告警被抑制，
+// 改为每个窗口最多发送一条"日志风暴"汇总告警；事件数回落到阈值一半以下时退出风暴状态
+type StormDetector struct {
+	threshold int
+	window    time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostStorm
+}
+
+// NewStormDetector 创建日志风暴检测器，threshold<=0 时返回nil表示不启用
+func NewStormDetector(threshold int, window time.Duration) *StormDetector {
+	if threshold <= 0 || window <= 0 {
+		return nil
+	}
+	return &StormDetector{
+		threshold: threshold,
+		window:    window,
+		hosts:     make(map[string]*hostStorm),
+	}
+}
+
+// Observe 记录一个事件
+// inStorm 表示该主机正处于日志风暴中，调用方应抑制逐条告警；
+// notify 非nil时表示需要发送风暴汇总告警
+func (d *StormDetector) Observe(event collector.LogEvent) (inStorm bool, notify *AggregatedAlert) {
+	if d == nil {
+		return false, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	hs, ok := d.hosts[event.Host]
+	if !ok {
+		hs = &hostStorm{}
+		d.hosts[event.Host] = hs
+	}
+
+	// 移出窗口外的事件
+	cutoff := now.Add(-d.window)
+	i := 0
+	for i < len(hs.events) && hs.events[i].Before(cutoff) {
+		i++
+	}
+	hs.events = append(hs.events[i:], now)
+
+	if hs.active && len(hs.events) < d.threshold/2 {
+		hs.active = false
+	}
+	if !hs.active {
+		if len(hs.events) < d.threshold {
+			return false, nil
+		}
+		hs.active = true
+		hs.startedAt = now
+		hs.lastNotified = time.Time{}
+		hs.total = len(hs.events) - 1
+		hs.maxSeverity = 0
+		hs.patterns = make(map[string]*stormPattern)
+		metrics.LogStormCount.Inc()
+	}
+
+	hs.total++
+	hs.maxSeverity = max(hs.maxSeverity, event.SeverityScore)
+	p, ok := hs.patterns[event.TemplateID]
+	if !ok {
+		p = &stormPattern{sample: firstLine(event.RawText)}
+		hs.patterns[event.TemplateID] = p
+	}
+	p.count++
+
+	// 每个窗口最多发送一次风暴汇总告警
+	if now.Sub(hs.lastNotified) < d.window {
+		return true, nil
+	}
+	hs.lastNotified = now
+	alert := d.buildAlert(event.Host, hs, now)
+	return true, &alert
+}
+
+// buildAlert 生成日志风暴汇总告警
+func (d *StormDetector) buildAlert(host string, hs *hostStorm, now time.Time) AggregatedAlert {
+	patterns := make([]stormPattern, 0, len(hs.patterns))
+	for _, p := range hs.patterns {
+		patterns = append(patterns, *p)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].count > patterns[j].count
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "主机 %s 发生日志风暴: 自 %s 起共 %d 个事件（阈值: %d 个/%s），涉及 %d 种模式\n",
+		host, hs.startedAt.Format("15:04:05"), hs.total, d.threshold, d.window, len(patterns))
+	b.WriteString("Top 模式:\n")
+	for i, p := range patterns {
+		if i >= stormTopPatterns {
+			break
+		}
+		fmt.Fprintf(&b, "%d. (%d次) %s\n", i+1, p.count, p.sample)
+	}
+
+	return AggregatedAlert{
+		EventID:      "storm-" + host,
+		Host:         host,
+		Severity:     hs.maxSeverity,
+		Count:        hs.total,
+		LastAlertAt:  now,
+		FirstAlertAt: hs.startedAt,
+		LastSentAt:   now,
+		Content:      strings.TrimRight(b.String(), "\n"),
+		AiResult:     "日志风暴期间暂停逐条告警与AI分析汇总，请优先排查该主机的磁盘、内存、网络等全局性问题",
+		TotalScore:   hs.maxSeverity,
+		TemplateID:   "log-storm",
+		Fingerprint:  Fingerprint(host, "log-storm", hs.maxSeverity),
+	}
+}
+
+// firstLine 返回内容的第一行
+func firstLine(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	return line
+}
+
+// Cleanup 清理已经平息的主机状态
+func (d *StormDetector) Cleanup() {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := time.Now().Add(-d.window)
+	for host, hs := range d.hosts {
+		if len(hs.events) == 0 || hs.events[len(hs.events)-1].Before(cutoff) {
+			delete(d.hosts, host)
+		}
+	}
+}
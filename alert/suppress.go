@@ -0,0 +1,221 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 检查抑制规则文件是否更新的最短间隔
+const suppressionReloadInterval = 30 * time.Second
+
+// SuppressionRule 噪声抑制规则：该日志模板的事件照常分析和存储，但不再发送告警。
+// 由汇总报告根据噪声统计自动生成，运维人员可删除规则恢复告警
+type SuppressionRule struct {
+	TemplateID string    `json:"template_id"`
+	Sample     string    `json:"sample"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LoadSuppressions 读取抑制规则文件，文件不存在时返回空
+func LoadSuppressions(path string) ([]SuppressionRule, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取抑制规则失败: %w", err)
+	}
+	var rules []SuppressionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("解析抑制规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// AddSuppressions 将规则加入抑制规则文件，已存在的模板保留原规则，返回新加入的规则数
+func AddSuppressions(path string, rules []SuppressionRule) (int, error) {
+	existing, err := LoadSuppressions(path)
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, r := range existing {
+		seen[r.TemplateID] = true
+	}
+	added := 0
+	for _, r := range rules {
+		if !seen[r.TemplateID] {
+			existing = append(existing, r)
+			seen[r.TemplateID] = true
+			added++
+		}
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err := saveSuppressions(path, existing); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// RemoveSuppression 从抑制规则文件中删除日志模板的规则，恢复该模板的告警，返回规则是否存在
+func RemoveSuppression(path, templateID string) (bool, error) {
+	existing, err := LoadSuppressions(path)
+	if err != nil {
+		return false, err
+	}
+	kept := existing[:0]
+	for _, r := range existing {
+		if r.TemplateID != templateID {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(existing) {
+		return false, nil
+	}
+	return true, saveSuppressions(path, kept)
+}
+
+// saveSuppressions 保存抑制规则文件，先写临时文件再替换，服务读取时不会读到不完整的文件
+func saveSuppressions(path string, rules []SuppressionRule) error {
+	if rules == nil {
+		rules = []SuppressionRule{}
+	}
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码抑制规则失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("保存抑制规则失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("保存抑制规则失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("保存抑制规则失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存抑制规则失败: %w", err)
+	}
+	return nil
+}
+
+// Suppressor 按抑制规则文件判断日志模板是否被抑制，文件更新后自动重新加载
+type Suppressor struct {
+	path string
+
+	mu        sync.Mutex
+	templates map[string]bool
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewSuppressor 创建噪声抑制器，path 为空时返回nil表示不抑制
+func NewSuppressor(path string) *Suppressor {
+	if path == "" {
+		return nil
+	}
+	return &Suppressor{path: path, templates: make(map[string]bool)}
+}
+
+// Silence 由外部配置源（如 Kubernetes Silence 资源）声明的静默：该日志模板的告警在到期前不发送
+type Silence struct {
+	TemplateID string
+	Reason     string
+	ExpiresAt  time.Time // 到期时间，零值表示不过期
+}
+
+// 当前生效的外部静默，与抑制规则文件同时生效
+var silences struct {
+	mu        sync.RWMutex
+	templates map[string]time.Time
+}
+
+// SetSilences 替换外部静默
+func SetSilences(list []Silence) {
+	templates := make(map[string]time.Time, len(list))
+	for _, s := range list {
+		templates[s.TemplateID] = s.ExpiresAt
+	}
+	silences.mu.Lock()
+	defer silences.mu.Unlock()
+	silences.templates = templates
+}
+
+// silenced 判断日志模板是否被未到期的外部静默覆盖
+func silenced(templateID string, now time.Time) bool {
+	silences.mu.RLock()
+	defer silences.mu.RUnlock()
+	expiresAt, ok := silences.templates[templateID]
+	return ok && (expiresAt.IsZero() || now.Before(expiresAt))
+}
+
+// Suppressed 判断该日志模板的告警是否被抑制规则或外部静默抑制，s 为nil时只判断外部静默
+func (s *Suppressor) Suppressed(templateID string) bool {
+	if templateID == "" {
+		return false
+	}
+	if silenced(templateID, time.Now()) {
+		return true
+	}
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload()
+	return s.templates[templateID]
+}
+
+// Refresh 下次判断时立即检查规则文件是否更新，用于规则文件由集中配置同步后尽快生效
+func (s *Suppressor) Refresh() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkedAt = time.Time{}
+}
+
+// reload 文件有更新时重新加载规则，读取失败时继续使用之前的规则
+func (s *Suppressor) reload() {
+	now := time.Now()
+	if now.Sub(s.checkedAt) < suppressionReloadInterval {
+		return
+	}
+	s.checkedAt = now
+
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.templates = make(map[string]bool)
+		s.modTime = time.Time{}
+		return
+	}
+	if err != nil || !info.ModTime().After(s.modTime) {
+		return
+	}
+	rules, err := LoadSuppressions(s.path)
+	if err != nil {
+		log.Printf("%v，继续使用之前的抑制规则", err)
+		return
+	}
+	templates := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		templates[r.TemplateID] = true
+	}
+	s.templates = templates
+	s.modTime = info.ModTime()
+	log.Printf("抑制规则已加载: %d 个日志模板", len(templates))
+}
@@ -0,0 +1,26 @@
+package alert
+
+import (
+	"os"
+	"time"
+)
+
+// BudgetExhaustedAlert 生成AI预算耗尽告警
+func BudgetExhaustedAlert(reason string) AggregatedAlert {
+	host, _ := os.Hostname()
+	now := time.Now()
+	return AggregatedAlert{
+		EventID:      "ai-budget-exhausted",
+		Host:         host,
+		Severity:     5,
+		Count:        1,
+		LastAlertAt:  now,
+		FirstAlertAt: now,
+		LastSentAt:   now,
+		Content:      "AI调用预算已用尽: " + reason,
+		AiResult:     "在预算周期结束前，AI分析将降级为同类日志的历史分析结果或规则摘要。如需恢复，请调整 AI_*_LIMIT 配置。",
+		TotalScore:   5,
+		TemplateID:   "ai-budget-exhausted",
+		Fingerprint:  Fingerprint(host, "ai-budget-exhausted", 5),
+	}
+}
@@ -0,0 +1,47 @@
+package alert
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// SyntheticAlert 生成用于验证告警渠道的模拟告警
+func SyntheticAlert() AggregatedAlert {
+	host, _ := os.Hostname()
+	now := time.Now()
+	return AggregatedAlert{
+		EventID:      "test-fire",
+		Host:         host,
+		Severity:     1,
+		Count:        1,
+		LastAlertAt:  now,
+		FirstAlertAt: now,
+		LastSentAt:   now,
+		Content:      "这是一条测试告警，用于验证告警渠道配置是否正确，无需处理。",
+		AiResult:     "测试告警，无需分析。",
+		TotalScore:   1,
+		TemplateID:   "test-fire",
+		Fingerprint:  Fingerprint(host, "test-fire", 1),
+	}
+}
+
+// TestFire 通过指定渠道发送一条模拟告警，返回渠道API的原始响应
+func TestFire(channel, webhook string) (string, error) {
+	if webhook == "" {
+		return "", fmt.Errorf("告警渠道 %s 未配置webhook", channel)
+	}
+
+	switch channel {
+	case "wechat":
+		msg := WeChatMessage{
+			MsgType: "markdown",
+			Markdown: Markdown{
+				Content: formatWeChatMessage(SyntheticAlert()),
+			},
+		}
+		return postWeChat(webhook, msg)
+	default:
+		return "", fmt.Errorf("不支持的告警渠道: %s", channel)
+	}
+}
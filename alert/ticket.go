@@ -0,0 +1,367 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ticketer 工单系统接口
+type Ticketer interface {
+	// Create 为告警创建工单，返回工单ID
+	Create(alert AggregatedAlert, link string) (string, error)
+	// Update 告警再次发送时更新工单
+	Update(id string, alert AggregatedAlert) error
+	// Resolve 告警恢复（缓存过期）时关闭工单
+	Resolve(id string, alert AggregatedAlert) error
+}
+
+// TicketManager 为高严重性告警自动创建、更新和关闭工单
+type TicketManager struct {
+	ticketer    Ticketer
+	minSeverity int
+	linkBase    string // 查看事件的Kibana地址
+
+	mu      sync.Mutex
+	tickets map[string]*ticketEntry // 告警键 -> 工单条目
+}
+
+// ticketEntry 单个告警的工单条目，mu 串行化同一告警的工单调用，不同告警之间互不阻塞
+type ticketEntry struct {
+	mu      sync.Mutex
+	id      string // 工单ID，创建成功前为空
+	removed bool   // 工单已关闭，条目已从 tickets 中移除
+}
+
+// NewTicketManager 创建工单管理器
+func NewTicketManager(ticketer Ticketer, minSeverity int, linkBase string) *TicketManager {
+	return &TicketManager{
+		ticketer:    ticketer,
+		minSeverity: minSeverity,
+		linkBase:    strings.TrimRight(linkBase, "/"),
+		tickets:     make(map[string]*ticketEntry),
+	}
+}
+
+// Sync 告警发送时同步工单：首次达到严重性阈值时创建工单，之后追加更新
+// 返回告警关联的工单ID，未达到阈值时返回空字符串
+func (m *TicketManager) Sync(alert AggregatedAlert) (string, error) {
+	if m == nil || alert.Severity < m.minSeverity {
+		return "", nil
+	}
+
+	// 只锁定该告警的条目，避免多个工作协程为同一告警重复创建工单，工单系统的HTTP调用不持有 m.mu
+	entry := m.lockEntry(alert.Key)
+	defer entry.mu.Unlock()
+
+	if entry.id != "" {
+		return entry.id, m.ticketer.Update(entry.id, alert)
+	}
+
+	id, err := m.ticketer.Create(alert, m.eventLink(alert))
+	if err != nil {
+		return "", err
+	}
+	entry.id = id
+	return id, nil
+}
+
+// Resolve 关闭已恢复告警的工单
+func (m *TicketManager) Resolve(alerts []AggregatedAlert) []error {
+	if m == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, alert := range alerts {
+		m.mu.Lock()
+		entry, ok := m.tickets[alert.Key]
+		m.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		entry.mu.Lock()
+		if entry.removed {
+			entry.mu.Unlock()
+			continue
+		}
+		if entry.id != "" {
+			if err := m.ticketer.Resolve(entry.id, alert); err != nil {
+				errs = append(errs, fmt.Errorf("关闭工单 %s 失败: %w", entry.id, err))
+				entry.mu.Unlock()
+				continue
+			}
+		}
+		entry.removed = true
+		m.mu.Lock()
+		delete(m.tickets, alert.Key)
+		m.mu.Unlock()
+		entry.mu.Unlock()
+	}
+	return errs
+}
+
+// lockEntry 返回告警键对应的工单条目并加锁，条目不存在时先占位；
+// 等待期间条目被 Resolve 移除时重新获取，工单关闭后再次告警会创建新工单
+func (m *TicketManager) lockEntry(key string) *ticketEntry {
+	for {
+		m.mu.Lock()
+		entry, ok := m.tickets[key]
+		if !ok {
+			entry = &ticketEntry{}
+			m.tickets[key] = entry
+		}
+		m.mu.Unlock()
+
+		entry.mu.Lock()
+		if !entry.removed {
+			return entry
+		}
+		entry.mu.Unlock()
+	}
+}
+
+// eventLink 生成在Kibana中查看该事件的链接
+func (m *TicketManager) eventLink(alert AggregatedAlert) string {
+	if m.linkBase == "" {
+		return ""
+	}
+	query := url.QueryEscape(fmt.Sprintf(`fingerprint:"%s"`, alert.Fingerprint))
+	return fmt.Sprintf("%s/app/discover#/?_a=(query:(language:kuery,query:'%s'))", m.linkBase, query)
+}
+
+// ticketDescription 生成工单正文
+func ticketDescription(alert AggregatedAlert, link string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "主机: %s\n文件: %s\n严重性: %d\n出现次数: %d\n首次出现: %s\n指纹: %s\n",
+		alert.Host, alert.FilePath, alert.Severity, alert.Count,
+		alert.FirstAlertAt.Format("2006-01-02 15:04:05"), alert.Fingerprint)
+	if alert.TemplateID != "" {
+		fmt.Fprintf(&b, "日志模板: %s\n", alert.TemplateID)
+	}
+	if text := instanceText(); text != "" {
+		fmt.Fprintf(&b, "实例: %s\n", text)
+	}
+	if link != "" {
+		fmt.Fprintf(&b, "事件链接: %s\n", link)
+	}
+	fmt.Fprintf(&b, "\n日志内容:\n%s\n", alert.Content)
+	if len(alert.ContextLines) > 0 {
+		fmt.Fprintf(&b, "\n上下文:\n%s\n", strings.Join(alert.ContextLines, "\n"))
+	}
+	fmt.Fprintf(&b, "\nAI 分析:\n%s\n", alert.AiResult)
+	if len(alert.Related) > 0 {
+		fmt.Fprintf(&b, "\n关联事件:\n%s", relatedText(alert.Related))
+	}
+	if len(alert.Timeline) > 1 {
+		fmt.Fprintf(&b, "\n故障 %s 时间线:\n%s", alert.IncidentID, timelineText(alert.Timeline))
+	}
+	return b.String()
+}
+
+// ticketSummary 生成工单标题
+func ticketSummary(alert AggregatedAlert) string {
+	summary := fmt.Sprintf("[LogAI][严重性%d] %s: %s", alert.Severity, alert.Host, firstLine(alert.Content))
+	if len([]rune(summary)) > 200 {
+		summary = string([]rune(summary)[:200])
+	}
+	return summary
+}
+
+// ticketHTTP 工单系统的HTTP调用封装
+type ticketHTTP struct {
+	baseURL string
+	user    string
+	token   func() string // 每次请求时读取，密钥轮换后无需重启
+	client  *http.Client
+	bearer  bool // Jira Cloud 使用 Basic 认证，Jira Server 的 PAT 使用 Bearer
+}
+
+func newTicketHTTP(baseURL, user string, token func() string) ticketHTTP {
+	return ticketHTTP{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		user:    user,
+		token:   token,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		bearer:  user == "",
+	}
+}
+
+// do 发送JSON请求，out非nil时解析响应
+func (t ticketHTTP) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, t.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if t.bearer {
+		req.Header.Set("Authorization", "Bearer "+t.token())
+	} else {
+		req.SetBasicAuth(t.user, t.token())
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("返回错误状态码: %d, 响应: %s", resp.StatusCode, respBody)
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("解析响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// JiraTicketer 通过 Jira REST API v2 管理工单
+type JiraTicketer struct {
+	http              ticketHTTP
+	project           string
+	issueType         string
+	resolveTransition string // 关闭工单使用的 transition ID
+}
+
+// NewJiraTicketer 创建 Jira 工单客户端，user为空时使用Bearer Token认证，token 返回当前的令牌
+func NewJiraTicketer(baseURL, user string, token func() string, project, issueType, resolveTransition string) *JiraTicketer {
+	if issueType == "" {
+		issueType = "Bug"
+	}
+	return &JiraTicketer{
+		http:              newTicketHTTP(baseURL, user, token),
+		project:           project,
+		issueType:         issueType,
+		resolveTransition: resolveTransition,
+	}
+}
+
+// Create 创建 Jira issue
+func (j *JiraTicketer) Create(alert AggregatedAlert, link string) (string, error) {
+	req := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.project},
+			"issuetype":   map[string]string{"name": j.issueType},
+			"summary":     ticketSummary(alert),
+			"description": ticketDescription(alert, link),
+			"labels":      []string{"logai", "fingerprint-" + alert.Fingerprint},
+		},
+	}
+	var resp struct {
+		Key string `json:"key"`
+	}
+	if err := j.http.do(http.MethodPost, "/rest/api/2/issue", req, &resp); err != nil {
+		return "", fmt.Errorf("创建Jira工单失败: %w", err)
+	}
+	return resp.Key, nil
+}
+
+// Update 在 Jira issue 下追加评论
+func (j *JiraTicketer) Update(id string, alert AggregatedAlert) error {
+	comment := map[string]string{
+		"body": fmt.Sprintf("告警再次触发，累计 %d 次，最高严重性 %d。\n\n最新日志:\n%s\n\nAI 分析:\n%s",
+			alert.Count, alert.Severity, alert.Content, alert.AiResult),
+	}
+	if err := j.http.do(http.MethodPost, "/rest/api/2/issue/"+id+"/comment", comment, nil); err != nil {
+		return fmt.Errorf("更新Jira工单失败: %w", err)
+	}
+	return nil
+}
+
+// Resolve 通过配置的 transition 关闭 Jira issue，未配置 transition 时只追加评论
+func (j *JiraTicketer) Resolve(id string, alert AggregatedAlert) error {
+	comment := map[string]string{
+		"body": fmt.Sprintf("告警已恢复：最后一次出现于 %s，累计 %d 次。", alert.LastAlertAt.Format("2006-01-02 15:04:05"), alert.Count),
+	}
+	if err := j.http.do(http.MethodPost, "/rest/api/2/issue/"+id+"/comment", comment, nil); err != nil {
+		return fmt.Errorf("更新Jira工单失败: %w", err)
+	}
+	if j.resolveTransition == "" {
+		return nil
+	}
+	transition := map[string]interface{}{
+		"transition": map[string]string{"id": j.resolveTransition},
+	}
+	if err := j.http.do(http.MethodPost, "/rest/api/2/issue/"+id+"/transitions", transition, nil); err != nil {
+		return fmt.Errorf("关闭Jira工单失败: %w", err)
+	}
+	return nil
+}
+
+// ServiceNowTicketer 通过 ServiceNow Table API 管理 incident
+type ServiceNowTicketer struct {
+	http ticketHTTP
+}
+
+// NewServiceNowTicketer 创建 ServiceNow 工单客户端，password 返回当前的密码
+func NewServiceNowTicketer(baseURL, user string, password func() string) *ServiceNowTicketer {
+	return &ServiceNowTicketer{http: newTicketHTTP(baseURL, user, password)}
+}
+
+// Create 创建 ServiceNow incident，返回 sys_id
+func (s *ServiceNowTicketer) Create(alert AggregatedAlert, link string) (string, error) {
+	req := map[string]string{
+		"short_description": ticketSummary(alert),
+		"description":       ticketDescription(alert, link),
+		"urgency":           "1",
+		"impact":            "1",
+		"correlation_id":    alert.Fingerprint,
+	}
+	var resp struct {
+		Result struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	if err := s.http.do(http.MethodPost, "/api/now/table/incident", req, &resp); err != nil {
+		return "", fmt.Errorf("创建ServiceNow工单失败: %w", err)
+	}
+	return resp.Result.SysID, nil
+}
+
+// Update 在 incident 中追加工作备注
+func (s *ServiceNowTicketer) Update(id string, alert AggregatedAlert) error {
+	req := map[string]string{
+		"work_notes": fmt.Sprintf("告警再次触发，累计 %d 次，最高严重性 %d。\n\n最新日志:\n%s\n\nAI 分析:\n%s",
+			alert.Count, alert.Severity, alert.Content, alert.AiResult),
+	}
+	if err := s.http.do(http.MethodPatch, "/api/now/table/incident/"+id, req, nil); err != nil {
+		return fmt.Errorf("更新ServiceNow工单失败: %w", err)
+	}
+	return nil
+}
+
+// Resolve 将 incident 置为已解决
+func (s *ServiceNowTicketer) Resolve(id string, alert AggregatedAlert) error {
+	req := map[string]string{
+		"state":       "6", // Resolved
+		"close_code":  "Solved (Permanently)",
+		"close_notes": fmt.Sprintf("告警已恢复：最后一次出现于 %s，累计 %d 次。", alert.LastAlertAt.Format("2006-01-02 15:04:05"), alert.Count),
+	}
+	if err := s.http.do(http.MethodPatch, "/api/now/table/incident/"+id, req, nil); err != nil {
+		return fmt.Errorf("关闭ServiceNow工单失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTicketer 记录调用次数的工单系统，Create 阻塞到 release 关闭
+type fakeTicketer struct {
+	mu       sync.Mutex
+	creates  map[string]int
+	updates  int
+	resolves int
+	release  chan struct{}
+}
+
+func (f *fakeTicketer) Create(alert AggregatedAlert, link string) (string, error) {
+	<-f.release
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.creates[alert.Key]++
+	return fmt.Sprintf("%s-%d", alert.Key, f.creates[alert.Key]), nil
+}
+
+func (f *fakeTicketer) Update(id string, alert AggregatedAlert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates++
+	return nil
+}
+
+func (f *fakeTicketer) Resolve(id string, alert AggregatedAlert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resolves++
+	return nil
+}
+
+func TestTicketManagerSyncCreatesOnce(t *testing.T) {
+	ticketer := &fakeTicketer{creates: make(map[string]int), release: make(chan struct{})}
+	m := NewTicketManager(ticketer, 5, "")
+	alert := AggregatedAlert{Key: "k1", Severity: 8}
+
+	var wg sync.WaitGroup
+	ids := make([]string, 5)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], _ = m.Sync(alert)
+		}(i)
+	}
+
+	// 同一告警的创建进行中时，其他告警的条目不被阻塞
+	done := make(chan struct{})
+	go func() {
+		m.Resolve([]AggregatedAlert{{Key: "k2"}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("工单创建期间其他告警被阻塞")
+	}
+
+	close(ticketer.release)
+	wg.Wait()
+
+	if got := ticketer.creates["k1"]; got != 1 {
+		t.Errorf("creates = %d, want 1", got)
+	}
+	if ticketer.updates != len(ids)-1 {
+		t.Errorf("updates = %d, want %d", ticketer.updates, len(ids)-1)
+	}
+	for _, id := range ids {
+		if id != "k1-1" {
+			t.Errorf("id = %s, want k1-1", id)
+		}
+	}
+}
+
+func TestTicketManagerResolve(t *testing.T) {
+	ticketer := &fakeTicketer{creates: make(map[string]int), release: make(chan struct{})}
+	close(ticketer.release)
+	m := NewTicketManager(ticketer, 5, "")
+	alert := AggregatedAlert{Key: "k1", Severity: 8}
+
+	if id, _ := m.Sync(AggregatedAlert{Key: "low", Severity: 3}); id != "" {
+		t.Errorf("低于阈值时 id = %s, want 空", id)
+	}
+	if id, _ := m.Sync(alert); id != "k1-1" {
+		t.Fatalf("id = %s, want k1-1", id)
+	}
+	if errs := m.Resolve([]AggregatedAlert{alert}); len(errs) != 0 {
+		t.Fatalf("Resolve errs = %v", errs)
+	}
+	if ticketer.resolves != 1 {
+		t.Errorf("resolves = %d, want 1", ticketer.resolves)
+	}
+	// 工单关闭后再次告警创建新工单
+	if id, _ := m.Sync(alert); id != "k1-2" {
+		t.Errorf("id = %s, want k1-2", id)
+	}
+}
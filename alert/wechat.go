@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
+
+	"log-ai-analyzer/collector"
 )
 
 type WeChatMessage struct {
@@ -23,61 +26,178 @@ type WeChatResponse struct {
 	ErrMsg  string `json:"errmsg"`
 }
 
-// SendWeChat 发送告警到企业微信
-func SendWeChat(webhook, content, aiResult string) error {
+// SendWeChat 发送告警到企业微信，mentions 为需要@的成员UserID
+func SendWeChat(webhook string, alert AggregatedAlert, mentions ...string) error {
 	msg := WeChatMessage{
 		MsgType: "markdown",
 		Markdown: Markdown{
-			Content: formatWeChatMessage(content, aiResult),
+			Content: formatWeChatMessage(alert) + formatMentions(mentions),
 		},
 	}
+	_, err := postWeChat(webhook, msg)
+	return err
+}
+
+// SendWeChatMarkdown 发送自定义 markdown 消息到企业微信，如汇总报告
+// 企业微信 markdown 消息最长4096字节，超出部分截断
+func SendWeChatMarkdown(webhook, content string) error {
+	const maxBytes = 4096
+	if len(content) > maxBytes {
+		content = strings.ToValidUTF8(content[:maxBytes-len("\n...")], "") + "\n..."
+	}
+	msg := WeChatMessage{
+		MsgType:  "markdown",
+		Markdown: Markdown{Content: content},
+	}
+	_, err := postWeChat(webhook, msg)
+	return err
+}
+
+// postWeChat 发送消息到企业微信webhook，返回原始响应内容
+func postWeChat(webhook string, msg WeChatMessage) (string, error) {
 	payload, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("序列化消息失败: %w", err)
+		return "", fmt.Errorf("序列化消息失败: %w", err)
 	}
 
 	resp, err := http.Post(webhook, "application/json", bytes.NewBuffer(payload))
 	if err != nil {
-		return fmt.Errorf("发送HTTP请求失败: %w", err)
+		return "", fmt.Errorf("发送HTTP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("读取响应失败: %w", err)
+		return "", fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	var r WeChatResponse
 	if err := json.Unmarshal(body, &r); err != nil {
-		return fmt.Errorf("解析响应失败: %w", err)
+		return string(body), fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	if r.ErrCode != 0 {
-		return fmt.Errorf("企业微信返回错误: %s (错误码: %d)", r.ErrMsg, r.ErrCode)
+		return string(body), fmt.Errorf("企业微信返回错误: %s (错误码: %d)", r.ErrMsg, r.ErrCode)
 	}
-	return nil
+	return string(body), nil
 }
 
 // formatWeChatMessage 格式化企业微信告警消息
-func formatWeChatMessage(content, aiResult string) string {
+func formatWeChatMessage(alert AggregatedAlert) string {
+	var ticket string
+	if text := instanceText(); text != "" {
+		ticket = fmt.Sprintf("> 实例: %s\n", text)
+	}
+	if alert.TicketID != "" {
+		ticket += fmt.Sprintf("> 工单: %s\n", alert.TicketID)
+	}
+	if alert.IncidentID != "" {
+		ticket += fmt.Sprintf("> 故障: %s\n", alert.IncidentID)
+	}
+	if alert.TemplateID != "" {
+		ticket += fmt.Sprintf("> 模板: %s\n", alert.TemplateID)
+	}
 	return fmt.Sprintf(
 		"### 🚨 **日志异常告警**\n"+
 			"> 时间: %s\n"+
+			"> 指纹: %s\n"+
+			"> 出现次数: %d\n"+
+			"%s"+
 			"**📜 日志内容:**\n``\n%s\n``\n"+
-			"**🤖 AI 分析:**\n\n%s\n",
+			"**🤖 AI 分析:**\n\n%s\n%s%s%s%s",
 		time.Now().Format("2006-01-02 15:04:05"),
-		content, aiResult,
+		alert.Fingerprint,
+		alert.Count,
+		ticket,
+		alert.Content, alert.AiResult,
+		relatedSection(alert),
+		timelineSection(alert),
+		runbookLinks(alert),
+		feedbackLinks(alert),
 	)
 }
 
+// relatedSection 生成其他日志文件中同一请求的关联事件，便于从网关日志追到后端日志
+func relatedSection(alert AggregatedAlert) string {
+	if len(alert.Related) == 0 {
+		return ""
+	}
+	return "\n**🔗 关联事件:**\n" + relatedText(alert.Related)
+}
+
+// relatedText 逐行列出关联事件的位置和第一行内容
+func relatedText(related []collector.RelatedEvent) string {
+	var b strings.Builder
+	for _, r := range related {
+		fmt.Fprintf(&b, "%s %s:%d 严重性%d: %s\n", r.Host, r.FilePath, r.LineNumber, r.SeverityScore, r.Summary)
+	}
+	return b.String()
+}
+
+// timelineSection 生成告警所属故障的时间线，只有首个事件时不显示
+func timelineSection(alert AggregatedAlert) string {
+	if len(alert.Timeline) < 2 {
+		return ""
+	}
+	return "\n**🕒 故障时间线:**\n" + timelineText(alert.Timeline)
+}
+
+// SendWeChatFollowUp 发送AI分析补充消息
+// 企业微信机器人不支持编辑已发送的消息，渐进式告警在AI分析完成后以跟进消息的形式补发
+func SendWeChatFollowUp(webhook string, alert AggregatedAlert) error {
+	var ticket string
+	if text := instanceText(); text != "" {
+		ticket = fmt.Sprintf("> 实例: %s\n", text)
+	}
+	if alert.TicketID != "" {
+		ticket += fmt.Sprintf("> 工单: %s\n", alert.TicketID)
+	}
+	if alert.IncidentID != "" {
+		ticket += fmt.Sprintf("> 故障: %s\n", alert.IncidentID)
+	}
+	msg := WeChatMessage{
+		MsgType: "markdown",
+		Markdown: Markdown{
+			Content: fmt.Sprintf(
+				"### 🤖 **AI 分析补充**\n"+
+					"> 指纹: %s\n"+
+					"> 告警: %s\n"+
+					"%s"+
+					"\n%s\n%s",
+				alert.Fingerprint,
+				firstLine(alert.Content),
+				ticket,
+				alert.AiResult,
+				feedbackLinks(alert),
+			),
+		},
+	}
+	_, err := postWeChat(webhook, msg)
+	return err
+}
+
+// formatMentions 生成企业微信 markdown 消息中的@成员语法
+func formatMentions(mentions []string) string {
+	if len(mentions) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n**📟 值班人员:**")
+	for _, id := range mentions {
+		fmt.Fprintf(&b, " <@%s>", id)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
 // SendDingTalk 发送到钉钉机器人（预留扩展）
-func SendDingTalk(webhook, content, aiResult string) error {
+func SendDingTalk(webhook string, alert AggregatedAlert) error {
 	// TODO: 实现钉钉机器人发送逻辑
 	return nil
 }
 
 // SendFeishu 发送到飞书机器人（预留扩展）
-func SendFeishu(webhook, content, aiResult string) error {
+func SendFeishu(webhook string, alert AggregatedAlert) error {
 	// TODO: 实现飞书机器人发送逻辑
 	return nil
 }
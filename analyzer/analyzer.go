@@ -0,0 +1,268 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 补算空闲统计周期时的最大次数，超过后基线已基本衰减为0
+const maxIdleBuckets = 1000
+
+// 超过该时间没有事件的模板不再跟踪
+const templateIdleTTL = 6 * time.Hour
+
+// 季节基线的周期
+const (
+	SeasonalityDaily  = "daily"  // 按一天中的小时分时段
+	SeasonalityWeekly = "weekly" // 按一周中的星期和小时分时段
+)
+
+// Options 速率异常检测参数
+type Options struct {
+	Interval  time.Duration // 统计周期，速率按每个周期的事件数计算
+	Alpha     float64       // EWMA 平滑系数，越大基线对近期变化越敏感
+	Threshold float64       // 当前周期事件数超过 基线均值+Threshold×标准差 时判定为异常
+	Ratio     float64       // 当前周期事件数还需超过基线均值的 Ratio 倍，避免基线较高时的正常波动被判定为异常，0表示不检查
+	MinCount  int           // 周期内事件数至少达到该值才判定为异常，避免低频模板误报
+	Warmup    int           // 模板至少经历多少个统计周期后基线才可信
+	// Seasonality 季节基线的周期（daily、weekly），为空表示不启用。启用后每个模板按时段另外维护基线，
+	// 时段基线可信后以它代替整体基线判定，凌晨批处理任务等每天固定时段出现的峰值不再判定为异常
+	Seasonality string
+}
+
+// slotBaseline 模板在某个时段（如每天02点）的速率基线
+type slotBaseline struct {
+	mean     float64
+	variance float64
+	buckets  int
+}
+
+// update 将一个周期的事件数纳入时段基线，纳入的周期较少时按算术平均计算，避免基线从0开始缓慢爬升
+func (s *slotBaseline) update(count int, alpha float64) {
+	alpha = max(alpha, 1/float64(s.buckets+1))
+	diff := float64(count) - s.mean
+	incr := alpha * diff
+	s.mean += incr
+	s.variance = (1 - alpha) * (s.variance + diff*incr)
+	s.buckets++
+}
+
+// templateRate 单个日志模板的速率基线
+type templateRate struct {
+	bucketStart time.Time      // 当前统计周期的起始时间
+	count       int            // 当前统计周期的事件数
+	flagged     bool           // 当前统计周期是否已上报过异常
+	mean        float64        // 每周期事件数的 EWMA 均值
+	variance    float64        // 每周期事件数的 EWMA 方差
+	buckets     int            // 已纳入基线的统计周期数
+	slots       []slotBaseline // 各时段的基线，未启用季节基线时为nil
+	lastSeen    time.Time
+	sample      collector.LogEvent // 最近一条事件，用于生成异常事件
+}
+
+// update 将一个周期的事件数纳入 EWMA 基线
+func (r *templateRate) update(count int, alpha float64) {
+	diff := float64(count) - r.mean
+	incr := alpha * diff
+	r.mean += incr
+	r.variance = (1 - alpha) * (r.variance + diff*incr)
+	r.buckets++
+}
+
+// SmartAnalyzer 不依赖大模型的统计分析
+// 按日志模板维护每个统计周期事件数的 EWMA 基线，事件量突增时生成"速率异常"事件，
+// 即使单条事件严重性较低或未启用AI分析也能发现问题
+type SmartAnalyzer struct {
+	opts Options
+
+	// 季节基线参数：时段数（daily 24、weekly 168，0表示不启用）、每个时段包含的统计周期数，
+	// 以及时段基线的平滑系数（按每个时段的周期数折算，相当于按天或按周平滑）
+	slotCount      int
+	bucketsPerSlot int
+	slotAlpha      float64
+
+	mu        sync.Mutex
+	templates map[string]*templateRate
+}
+
+// NewSmartAnalyzer 创建统计分析器，Threshold<=0 时返回nil表示不启用
+func NewSmartAnalyzer(opts Options) *SmartAnalyzer {
+	if opts.Threshold <= 0 || opts.Interval <= 0 {
+		return nil
+	}
+	if opts.Alpha <= 0 || opts.Alpha >= 1 {
+		opts.Alpha = 0.1
+	}
+	a := &SmartAnalyzer{
+		opts:      opts,
+		templates: make(map[string]*templateRate),
+	}
+	switch opts.Seasonality {
+	case SeasonalityDaily:
+		a.slotCount = 24
+	case SeasonalityWeekly:
+		a.slotCount = 7 * 24
+	}
+	if a.slotCount > 0 {
+		a.bucketsPerSlot = max(int(time.Hour/opts.Interval), 1)
+		a.slotAlpha = opts.Alpha / float64(a.bucketsPerSlot)
+	}
+	return a
+}
+
+// slot 返回时间所在的时段
+func (a *SmartAnalyzer) slot(t time.Time) int {
+	if a.slotCount == 7*24 {
+		return int(t.Weekday())*24 + t.Hour()
+	}
+	return t.Hour()
+}
+
+// slotName 返回时间所在时段的名称，如"02点"或"周六02点"
+func (a *SmartAnalyzer) slotName(t time.Time) string {
+	name := fmt.Sprintf("%02d点", t.Hour())
+	if a.slotCount == 7*24 {
+		name = "周" + []string{"日", "一", "二", "三", "四", "五", "六"}[t.Weekday()] + name
+	}
+	return name
+}
+
+// idleTTL 模板没有事件后继续跟踪的时间，启用季节基线时需保留到下一个季节周期，每天只出现一次的模板才不会丢失时段基线
+func (a *SmartAnalyzer) idleTTL() time.Duration {
+	return templateIdleTTL + time.Duration(a.slotCount)*time.Hour
+}
+
+// closeBucket 将 start 开始的统计周期的事件数纳入整体基线和所在时段的基线
+func (a *SmartAnalyzer) closeBucket(r *templateRate, start time.Time, count int) {
+	r.update(count, a.opts.Alpha)
+	if a.slotCount > 0 {
+		if r.slots == nil {
+			r.slots = make([]slotBaseline, a.slotCount)
+		}
+		r.slots[a.slot(start)].update(count, a.slotAlpha)
+	}
+}
+
+// baseline 返回判定 bucket 周期是否异常使用的基线，时段基线已覆盖一个完整时段时使用时段基线
+func (a *SmartAnalyzer) baseline(r *templateRate, bucket time.Time) (mean, variance float64, seasonal bool) {
+	if r.slots != nil {
+		if s := r.slots[a.slot(bucket)]; s.buckets >= a.bucketsPerSlot {
+			return s.mean, s.variance, true
+		}
+	}
+	return r.mean, r.variance, false
+}
+
+// Observe 记录一个事件，当该事件所属模板的速率超出基线时返回一个合成的异常事件
+// 每个模板在每个统计周期内最多上报一次异常
+func (a *SmartAnalyzer) Observe(event collector.LogEvent) *collector.LogEvent {
+	if a == nil || event.TemplateID == "" {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	bucket := now.Truncate(a.opts.Interval)
+	r, ok := a.templates[event.TemplateID]
+	if !ok {
+		r = &templateRate{bucketStart: bucket}
+		a.templates[event.TemplateID] = r
+	}
+
+	// 进入新的统计周期：将上一周期以及期间没有事件的空闲周期纳入基线；
+	// 启用季节基线时空闲周期最多补算一个完整的季节周期，使每个时段都纳入期间的0
+	if bucket.After(r.bucketStart) {
+		a.closeBucket(r, r.bucketStart, r.count)
+		idle := int(bucket.Sub(r.bucketStart)/a.opts.Interval) - 1
+		for i := 0; i < min(idle, max(maxIdleBuckets, a.slotCount*a.bucketsPerSlot)); i++ {
+			a.closeBucket(r, r.bucketStart.Add(time.Duration(i+1)*a.opts.Interval), 0)
+		}
+		r.bucketStart = bucket
+		r.count = 0
+		r.flagged = false
+	}
+
+	r.count++
+	r.lastSeen = now
+	r.sample = event
+
+	if r.flagged || r.buckets < a.opts.Warmup || r.count < a.opts.MinCount {
+		return nil
+	}
+	// 标准差至少按1计算，避免基线非常平稳时的微小波动被判定为异常
+	mean, variance, seasonal := a.baseline(r, bucket)
+	std := math.Max(math.Sqrt(variance), 1)
+	limit := math.Max(mean+a.opts.Threshold*std, a.opts.Ratio*mean)
+	if float64(r.count) <= limit {
+		return nil
+	}
+
+	r.flagged = true
+	metrics.AnomalyDetectedCount.Inc()
+	anomaly := a.anomalyEvent(r, mean, std, limit, seasonal)
+	return &anomaly
+}
+
+// anomalyEvent 生成速率异常事件，进入与普通事件相同的分析和告警流程
+func (a *SmartAnalyzer) anomalyEvent(r *templateRate, mean, std, limit float64, seasonal bool) collector.LogEvent {
+	severity := 6
+	if float64(r.count) > mean+2*a.opts.Threshold*std {
+		severity = 8
+	}
+
+	kind := "基线"
+	if seasonal {
+		kind = a.slotName(r.bucketStart) + "时段基线"
+	}
+	sample := r.sample
+	text := fmt.Sprintf("日志速率异常: 模板 %s 在当前 %s 周期内已出现 %d 次，%s %.1f±%.1f 次/周期（异常阈值 %.1f）%s\n示例日志: %s",
+		sample.TemplateID, a.opts.Interval, r.count, kind, mean, std, limit, spikeRatio(r.count, mean), sample.RawText)
+
+	return collector.LogEvent{
+		RawLines:      []string{text},
+		RawText:       text,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		Host:          sample.Host,
+		Tags:          []string{"anomaly"},
+		SeverityScore: severity,
+		EventID:       fmt.Sprintf("anomaly-%s-%d", sample.TemplateID, r.bucketStart.Unix()),
+		FilePath:      sample.FilePath,
+		LineNumber:    sample.LineNumber,
+		ContextLines:  sample.ContextLines,
+		TemplateID:    "anomaly-" + sample.TemplateID,
+	}
+}
+
+// spikeRatio 返回事件数相对基线均值的倍数说明，基线接近0时倍数没有意义，返回空串
+func spikeRatio(count int, mean float64) string {
+	if mean < 1 {
+		return ""
+	}
+	return fmt.Sprintf("，为基线的 %.1f 倍", float64(count)/mean)
+}
+
+// Cleanup 清理长时间没有事件的模板，并更新跟踪的模板数指标
+func (a *SmartAnalyzer) Cleanup() {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := time.Now().Add(-a.idleTTL())
+	for id, r := range a.templates {
+		if r.lastSeen.Before(cutoff) {
+			delete(a.templates, id)
+			metrics.AnalyzerEvictionCount.Inc()
+		}
+	}
+	metrics.AnalyzerTemplates.Set(float64(len(a.templates)))
+}
@@ -0,0 +1,240 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 序列规则的分组范围
+const (
+	SequenceScopeHost   = "host"   // 各步骤的事件来自同一主机
+	SequenceScopeTenant = "tenant" // 各步骤的事件来自同一租户的任意主机
+)
+
+// 每条规则最多同时跟踪的未完成序列数，达到上限时先清理已超时的序列，仍然超过则不再开始新的序列
+const sequenceMaxPending = 10000
+
+// SequenceStep 序列中的一个步骤，所有配置的条件都满足时事件匹配该步骤
+type SequenceStep struct {
+	Pattern string   `yaml:"pattern"`         // 日志内容正则（不区分大小写）
+	Tags    []string `yaml:"tags,omitempty"`  // 事件标签，任一匹配
+	Files   []string `yaml:"files,omitempty"` // 日志文件路径通配符，任一匹配
+
+	re *regexp.Regexp
+}
+
+// SequenceRule 序列规则：各步骤的事件按顺序在 Within 时间内出现时生成以规则名命名的复合告警，
+// 如"connection refused"之后5分钟内出现"failover initiated"
+type SequenceRule struct {
+	Name     string         `yaml:"name"`
+	Severity int            `yaml:"severity,omitempty"` // 复合告警的严重性，默认8
+	Within   time.Duration  `yaml:"within"`             // 从第一步到最后一步的最长时间
+	Scope    string         `yaml:"scope,omitempty"`    // host（默认）或 tenant
+	Steps    []SequenceStep `yaml:"steps"`
+}
+
+// SequenceConfig 序列规则文件
+type SequenceConfig struct {
+	Rules []SequenceRule `yaml:"rules"`
+}
+
+// LoadSequenceRules 加载YAML格式的序列规则文件
+func LoadSequenceRules(path string) (*SequenceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取序列规则失败: %w", err)
+	}
+	var c SequenceConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("解析序列规则失败: %w", err)
+	}
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Name == "" {
+			return nil, fmt.Errorf("序列规则 %d 缺少 name", i+1)
+		}
+		if len(r.Steps) < 2 {
+			return nil, fmt.Errorf("序列规则 %s 至少需要两个步骤", r.Name)
+		}
+		if r.Within <= 0 {
+			return nil, fmt.Errorf("序列规则 %s 缺少 within", r.Name)
+		}
+		switch r.Scope {
+		case "":
+			r.Scope = SequenceScopeHost
+		case SequenceScopeHost, SequenceScopeTenant:
+		default:
+			return nil, fmt.Errorf("序列规则 %s 的 scope 无效: %s", r.Name, r.Scope)
+		}
+		if r.Severity == 0 {
+			r.Severity = 8
+		}
+		for j := range r.Steps {
+			step := &r.Steps[j]
+			if step.Pattern == "" && len(step.Tags) == 0 && len(step.Files) == 0 {
+				return nil, fmt.Errorf("序列规则 %s 的步骤 %d 没有任何条件", r.Name, j+1)
+			}
+			for _, glob := range step.Files {
+				if _, err := filepath.Match(glob, ""); err != nil {
+					return nil, fmt.Errorf("序列规则 %s 的通配符 %q 无效: %w", r.Name, glob, err)
+				}
+			}
+			if step.Pattern != "" {
+				if step.re, err = regexp.Compile("(?i)" + step.Pattern); err != nil {
+					return nil, fmt.Errorf("序列规则 %s 的步骤 %d pattern 无效: %w", r.Name, j+1, err)
+				}
+			}
+		}
+	}
+	return &c, nil
+}
+
+// matches 判断事件是否满足步骤的所有条件
+func (s *SequenceStep) matches(event collector.LogEvent) bool {
+	if s.re != nil && !s.re.MatchString(event.RawText) {
+		return false
+	}
+	if len(s.Tags) > 0 && !hasAnyTag(s.Tags, event.Tags) {
+		return false
+	}
+	if len(s.Files) > 0 {
+		for _, glob := range s.Files {
+			if ok, _ := filepath.Match(glob, event.FilePath); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// hasAnyTag 判断事件是否带有任一标签
+func hasAnyTag(want, tags []string) bool {
+	for _, w := range want {
+		for _, t := range tags {
+			if strings.EqualFold(w, t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pendingSequence 已匹配部分步骤、尚未完成的序列
+type pendingSequence struct {
+	startedAt time.Time
+	matched   []collector.LogEvent // 已匹配各步骤的事件
+}
+
+// SequenceDetector 按序列规则检测按顺序出现的事件，序列完成时生成复合告警事件
+type SequenceDetector struct {
+	rules []SequenceRule
+
+	mu      sync.Mutex
+	pending []map[string]*pendingSequence // 与 rules 一一对应，分组键 → 未完成的序列
+}
+
+// NewSequenceDetector 创建序列检测器，c 为nil或没有规则时返回nil表示不启用
+func NewSequenceDetector(c *SequenceConfig) *SequenceDetector {
+	if c == nil || len(c.Rules) == 0 {
+		return nil
+	}
+	d := &SequenceDetector{rules: c.Rules, pending: make([]map[string]*pendingSequence, len(c.Rules))}
+	for i := range d.pending {
+		d.pending[i] = make(map[string]*pendingSequence)
+	}
+	return d
+}
+
+// Observe 记录一个事件，返回因该事件而完成的序列生成的复合告警事件
+func (d *SequenceDetector) Observe(event collector.LogEvent) []collector.LogEvent {
+	if d == nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var composites []collector.LogEvent
+	for i := range d.rules {
+		rule := &d.rules[i]
+		pending := d.pending[i]
+		key := event.Tenant
+		if rule.Scope == SequenceScopeHost {
+			key += "\x00" + event.Host
+		}
+
+		seq := pending[key]
+		if seq != nil && now.Sub(seq.startedAt) > rule.Within {
+			delete(pending, key)
+			seq = nil
+		}
+		if seq != nil && rule.Steps[len(seq.matched)].matches(event) {
+			seq.matched = append(seq.matched, event)
+			if len(seq.matched) == len(rule.Steps) {
+				delete(pending, key)
+				metrics.SequenceMatchedCount.WithLabelValues(rule.Name).Inc()
+				composites = append(composites, sequenceEvent(rule, seq))
+			}
+			continue
+		}
+		// 第一步的事件开始新的序列；只匹配了第一步时以最近一次出现为起点
+		if rule.Steps[0].matches(event) && (seq == nil || len(seq.matched) == 1) {
+			if seq == nil && len(pending) >= sequenceMaxPending {
+				d.expire(i, now)
+				if len(pending) >= sequenceMaxPending {
+					continue
+				}
+			}
+			pending[key] = &pendingSequence{startedAt: now, matched: []collector.LogEvent{event}}
+		}
+	}
+	return composites
+}
+
+// expire 清理第 i 条规则中已超时的未完成序列
+func (d *SequenceDetector) expire(i int, now time.Time) {
+	for key, seq := range d.pending[i] {
+		if now.Sub(seq.startedAt) > d.rules[i].Within {
+			delete(d.pending[i], key)
+		}
+	}
+}
+
+// sequenceEvent 生成复合告警事件，进入与普通事件相同的分析和告警流程
+func sequenceEvent(rule *SequenceRule, seq *pendingSequence) collector.LogEvent {
+	var b strings.Builder
+	fmt.Fprintf(&b, "序列规则命中: %s（%s 内按顺序出现 %d 个步骤）", rule.Name, rule.Within, len(rule.Steps))
+	for i, e := range seq.matched {
+		fmt.Fprintf(&b, "\n步骤%d %s %s %s: %s", i+1, e.Timestamp, e.Host, e.FilePath, firstLine(e.RawText))
+	}
+	text := b.String()
+
+	last := seq.matched[len(seq.matched)-1]
+	return collector.LogEvent{
+		RawLines:      strings.Split(text, "\n"),
+		RawText:       text,
+		Timestamp:     time.Now().Format(time.RFC3339),
+		Host:          last.Host,
+		Tags:          []string{"sequence"},
+		SeverityScore: rule.Severity,
+		EventID:       fmt.Sprintf("sequence-%s-%d", rule.Name, seq.startedAt.Unix()),
+		FilePath:      last.FilePath,
+		LineNumber:    last.LineNumber,
+		TemplateID:    "sequence-" + rule.Name,
+		Tenant:        last.Tenant,
+		Service:       last.Service,
+		Team:          last.Team,
+	}
+}
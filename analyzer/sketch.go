@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"container/heap"
+	"hash/fnv"
+)
+
+// countMinSketch 以固定内存估计每个键的出现次数，估计值只会偏大不会偏小，
+// 偏差与总次数成正比、与宽度成反比，取各行的最小值降低哈希冲突的影响
+type countMinSketch struct {
+	width  int
+	depth  int
+	counts []uint32 // depth 行 × width 列
+}
+
+// newCountMinSketch 创建 depth 行、每行 width 个计数器的 Count-Min Sketch
+func newCountMinSketch(width, depth int) *countMinSketch {
+	return &countMinSketch{width: width, depth: depth, counts: make([]uint32, width*depth)}
+}
+
+// hashes 返回键的两个哈希值，各行的列位置由两者组合得到
+func (s *countMinSketch) hashes(key string) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+// add 将键的次数加 n，返回加入后的估计次数
+func (s *countMinSketch) add(key string, n uint32) uint32 {
+	h1, h2 := s.hashes(key)
+	est := ^uint32(0)
+	for i := 0; i < s.depth; i++ {
+		idx := i*s.width + int((h1+uint32(i)*h2)%uint32(s.width))
+		s.counts[idx] += n
+		est = min(est, s.counts[idx])
+	}
+	return est
+}
+
+// estimate 返回键的估计次数
+func (s *countMinSketch) estimate(key string) uint32 {
+	h1, h2 := s.hashes(key)
+	est := ^uint32(0)
+	for i := 0; i < s.depth; i++ {
+		est = min(est, s.counts[i*s.width+int((h1+uint32(i)*h2)%uint32(s.width))])
+	}
+	return est
+}
+
+// merge 将同样大小的 other 的计数累加到 s
+func (s *countMinSketch) merge(other *countMinSketch) {
+	for i, c := range other.counts {
+		s.counts[i] += c
+	}
+}
+
+// topItem 高频日志模板及其估计次数和明细
+type topItem struct {
+	key    string
+	count  uint32
+	detail *templateCount
+	index  int // 在堆中的位置
+}
+
+// topK 保留估计次数最大的 k 个日志模板，以最小堆维护，新模板的次数超过堆顶时替换堆顶
+type topK struct {
+	k     int
+	items map[string]*topItem
+	heap  topHeap
+}
+
+// newTopK 创建最多保留 k 个模板的 topK
+func newTopK(k int) *topK {
+	return &topK{k: k, items: make(map[string]*topItem, k)}
+}
+
+// offer 以估计次数 count 更新模板，模板在 topK 中或能够进入 topK 时返回其明细，调用方在返回值上更新明细；
+// 否则返回nil
+func (t *topK) offer(key string, count uint32) *templateCount {
+	if item, ok := t.items[key]; ok {
+		item.count = count
+		heap.Fix(&t.heap, item.index)
+		return item.detail
+	}
+	if len(t.heap) >= t.k {
+		if t.heap[0].count >= count {
+			return nil
+		}
+		evicted := heap.Pop(&t.heap).(*topItem)
+		delete(t.items, evicted.key)
+	}
+	item := &topItem{key: key, count: count, detail: &templateCount{hosts: make(map[string]struct{})}}
+	heap.Push(&t.heap, item)
+	t.items[key] = item
+	return item.detail
+}
+
+// topHeap 按次数排列的最小堆
+type topHeap []*topItem
+
+func (h topHeap) Len() int           { return len(h) }
+func (h topHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h topHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *topHeap) Push(x any) {
+	item := x.(*topItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *topHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,258 @@
+package analyzer
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// SLO 燃烧率的分桶粒度，短窗口为长窗口的1/12（至少一个分桶）
+const (
+	sloBucket      = time.Minute
+	sloShortWindow = 12
+)
+
+// SLO 按日志模板统计的服务错误率目标，如"订单服务 HTTP 5xx 每小时不超过60次"。
+// 燃烧率为窗口内的错误数与预算（MaxPerHour 按窗口折算）之比，长、短两个窗口的燃烧率都达到 BurnRate 时告警：
+// 长窗口确认预算确实在被快速消耗，短窗口确认仍在持续，恢复后不再告警
+type SLO struct {
+	Name       string        `yaml:"name"`
+	Service    string        `yaml:"service,omitempty"`   // 只统计该服务的事件，为空时不限
+	Templates  []string      `yaml:"templates,omitempty"` // 日志模板ID，任一匹配
+	Pattern    string        `yaml:"pattern,omitempty"`   // 日志内容正则（不区分大小写）
+	MaxPerHour float64       `yaml:"max_per_hour"`        // 错误预算：每小时允许的事件数
+	BurnRate   float64       `yaml:"burn_rate,omitempty"` // 告警的燃烧率，默认2
+	Window     time.Duration `yaml:"window,omitempty"`    // 长窗口，默认1h
+	Severity   int           `yaml:"severity,omitempty"`  // 告警的严重性，默认8
+
+	re *regexp.Regexp
+}
+
+// SLOConfig SLO 规则文件
+type SLOConfig struct {
+	SLOs []SLO `yaml:"slos"`
+}
+
+// LoadSLOs 加载YAML格式的 SLO 规则文件
+func LoadSLOs(path string) (*SLOConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取SLO规则失败: %w", err)
+	}
+	var c SLOConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("解析SLO规则失败: %w", err)
+	}
+	for i := range c.SLOs {
+		s := &c.SLOs[i]
+		if s.Name == "" {
+			return nil, fmt.Errorf("SLO规则 %d 缺少 name", i+1)
+		}
+		if len(s.Templates) == 0 && s.Pattern == "" {
+			return nil, fmt.Errorf("SLO规则 %s 需要配置 templates 或 pattern", s.Name)
+		}
+		if s.MaxPerHour <= 0 {
+			return nil, fmt.Errorf("SLO规则 %s 的 max_per_hour 必须大于0", s.Name)
+		}
+		if s.BurnRate < 0 || s.Window < 0 {
+			return nil, fmt.Errorf("SLO规则 %s 的 burn_rate 和 window 不能为负数", s.Name)
+		}
+		if s.BurnRate == 0 {
+			s.BurnRate = 2
+		}
+		if s.Window == 0 {
+			s.Window = time.Hour
+		}
+		if s.Severity == 0 {
+			s.Severity = 8
+		}
+		if s.Pattern != "" {
+			if s.re, err = regexp.Compile("(?i)" + s.Pattern); err != nil {
+				return nil, fmt.Errorf("SLO规则 %s 的 pattern 无效: %w", s.Name, err)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// matches 判断事件是否计入该 SLO 的错误数
+func (s *SLO) matches(event collector.LogEvent) bool {
+	if s.Service != "" && s.Service != event.Service {
+		return false
+	}
+	if len(s.Templates) > 0 {
+		found := false
+		for _, id := range s.Templates {
+			if id == event.TemplateID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return s.re == nil || s.re.MatchString(event.RawText)
+}
+
+// shortWindow 返回短窗口
+func (s *SLO) shortWindow() time.Duration {
+	return max(s.Window/sloShortWindow, sloBucket)
+}
+
+// burnRate 返回窗口内 count 个错误对应的燃烧率
+func (s *SLO) burnRate(count int, window time.Duration) float64 {
+	return float64(count) / (s.MaxPerHour * window.Hours())
+}
+
+// sloBucketData 一分钟内的错误数
+type sloBucketData struct {
+	start time.Time
+	count int
+}
+
+// sloState 一个租户在某个 SLO 上的错误计数
+type sloState struct {
+	buckets   []sloBucketData // 按时间顺序，最多覆盖长窗口
+	alertedAt time.Time       // 上次告警时间，一个长窗口内只告警一次
+	last      collector.LogEvent
+}
+
+// count 返回 since 之后开始的分桶中的错误数
+func (st *sloState) count(since time.Time) int {
+	n := 0
+	for i := len(st.buckets) - 1; i >= 0 && st.buckets[i].start.After(since); i-- {
+		n += st.buckets[i].count
+	}
+	return n
+}
+
+// expire 丢弃超出窗口的分桶
+func (st *sloState) expire(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	i := 0
+	for i < len(st.buckets) && !st.buckets[i].start.After(cutoff) {
+		i++
+	}
+	st.buckets = st.buckets[i:]
+}
+
+// SLOTracker 按租户统计各 SLO 的错误数并计算燃烧率，错误预算消耗过快时生成告警事件
+type SLOTracker struct {
+	slos []SLO
+
+	mu     sync.Mutex
+	states []map[string]*sloState // 与 slos 一一对应，租户 → 错误计数
+}
+
+// NewSLOTracker 创建 SLO 跟踪器，c 为nil或没有规则时返回nil表示不启用
+func NewSLOTracker(c *SLOConfig) *SLOTracker {
+	if c == nil || len(c.SLOs) == 0 {
+		return nil
+	}
+	t := &SLOTracker{slos: c.SLOs, states: make([]map[string]*sloState, len(c.SLOs))}
+	for i := range t.states {
+		t.states[i] = make(map[string]*sloState)
+	}
+	return t
+}
+
+// Observe 记录一个事件，返回因该事件而触发的 SLO 燃烧率告警事件
+func (t *SLOTracker) Observe(event collector.LogEvent) []collector.LogEvent {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	start := now.Truncate(sloBucket)
+	var alerts []collector.LogEvent
+	for i := range t.slos {
+		slo := &t.slos[i]
+		if !slo.matches(event) {
+			continue
+		}
+		st := t.states[i][event.Tenant]
+		if st == nil {
+			st = &sloState{}
+			t.states[i][event.Tenant] = st
+		}
+		st.expire(now, slo.Window)
+		if n := len(st.buckets); n == 0 || st.buckets[n-1].start.Before(start) {
+			st.buckets = append(st.buckets, sloBucketData{start: start})
+		}
+		st.buckets[len(st.buckets)-1].count++
+		st.last = event
+
+		long := slo.burnRate(st.count(now.Add(-slo.Window)), slo.Window)
+		short := slo.burnRate(st.count(now.Add(-slo.shortWindow())), slo.shortWindow())
+		if long < slo.BurnRate || short < slo.BurnRate || now.Sub(st.alertedAt) < slo.Window {
+			continue
+		}
+		st.alertedAt = now
+		metrics.SLOBurnAlertCount.WithLabelValues(slo.Name).Inc()
+		alerts = append(alerts, sloEvent(slo, st, long, short, now))
+	}
+	return alerts
+}
+
+// UpdateMetrics 更新各 SLO 长窗口燃烧率指标（取各租户的最大值），没有新错误时燃烧率随窗口滑动逐渐下降
+func (t *SLOTracker) UpdateMetrics() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for i := range t.slos {
+		slo := &t.slos[i]
+		rate := 0.0
+		for tenant, st := range t.states[i] {
+			st.expire(now, slo.Window)
+			if len(st.buckets) == 0 {
+				delete(t.states[i], tenant)
+				continue
+			}
+			rate = max(rate, slo.burnRate(st.count(now.Add(-slo.Window)), slo.Window))
+		}
+		metrics.SLOBurnRate.WithLabelValues(slo.Name).Set(rate)
+	}
+}
+
+// sloEvent 生成 SLO 燃烧率告警事件，进入与普通事件相同的分析和告警流程
+func sloEvent(slo *SLO, st *sloState, long, short float64, now time.Time) collector.LogEvent {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SLO错误预算消耗过快: %s（预算 %g 次/小时）", slo.Name, slo.MaxPerHour)
+	fmt.Fprintf(&b, "\n最近 %s 燃烧率 %.1f，最近 %s 燃烧率 %.1f，告警阈值 %g", slo.Window, long, slo.shortWindow(), short, slo.BurnRate)
+	fmt.Fprintf(&b, "\n最近一次错误 %s %s %s: %s", st.last.Timestamp, st.last.Host, st.last.FilePath, firstLine(st.last.RawText))
+	text := b.String()
+
+	last := st.last
+	return collector.LogEvent{
+		RawLines:      strings.Split(text, "\n"),
+		RawText:       text,
+		Timestamp:     now.Format(time.RFC3339),
+		Host:          last.Host,
+		Tags:          []string{"slo"},
+		SeverityScore: slo.Severity,
+		EventID:       fmt.Sprintf("slo-%s-%d", slo.Name, now.Unix()),
+		FilePath:      last.FilePath,
+		LineNumber:    last.LineNumber,
+		TemplateID:    "slo-" + slo.Name,
+		Tenant:        last.Tenant,
+		Service:       last.Service,
+		Team:          last.Team,
+	}
+}
@@ -0,0 +1,155 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"log-ai-analyzer/atomicfile"
+	"log-ai-analyzer/collector"
+)
+
+// rateState 持久化的模板速率基线
+type rateState struct {
+	BucketStart time.Time          `json:"bucket_start"`
+	Count       int                `json:"count"`
+	Flagged     bool               `json:"flagged"`
+	Mean        float64            `json:"mean"`
+	Variance    float64            `json:"variance"`
+	Buckets     int                `json:"buckets"`
+	Slots       []slotState        `json:"slots,omitempty"`
+	LastSeen    time.Time          `json:"last_seen"`
+	Sample      collector.LogEvent `json:"sample"`
+}
+
+// slotState 持久化的时段基线
+type slotState struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Buckets  int     `json:"buckets"`
+}
+
+// analyzerState 状态文件的内容
+type analyzerState struct {
+	Interval    time.Duration         `json:"interval"`
+	Seasonality string                `json:"seasonality,omitempty"`
+	SavedAt     time.Time             `json:"saved_at"`
+	Templates   map[string]*rateState `json:"templates"`
+}
+
+// Save 将各模板的速率基线写入 path
+func (a *SmartAnalyzer) Save(path string) error {
+	if a == nil || path == "" {
+		return nil
+	}
+
+	a.mu.Lock()
+	state := analyzerState{
+		Interval:    a.opts.Interval,
+		Seasonality: a.opts.Seasonality,
+		SavedAt:     time.Now(),
+		Templates:   make(map[string]*rateState, len(a.templates)),
+	}
+	for id, r := range a.templates {
+		var slots []slotState
+		for _, s := range r.slots {
+			slots = append(slots, slotState{Mean: s.mean, Variance: s.variance, Buckets: s.buckets})
+		}
+		state.Templates[id] = &rateState{
+			BucketStart: r.bucketStart,
+			Count:       r.count,
+			Flagged:     r.flagged,
+			Mean:        r.mean,
+			Variance:    r.variance,
+			Buckets:     r.buckets,
+			Slots:       slots,
+			LastSeen:    r.lastSeen,
+			Sample:      r.sample,
+		}
+	}
+	a.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("编码速率统计状态失败: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, data); err != nil {
+		return fmt.Errorf("保存速率统计状态失败: %w", err)
+	}
+	return nil
+}
+
+// Load 从 path 恢复上次保存的速率基线，返回恢复的模板数；状态文件不存在时不恢复。
+// 超过空闲时间的模板不再恢复，停机期间的统计周期在模板下次出现时按空闲周期纳入基线；
+// 统计周期与保存时不同时基线不可比，全部丢弃；季节周期与保存时不同时只丢弃时段基线
+func (a *SmartAnalyzer) Load(path string) (int, error) {
+	if a == nil || path == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取速率统计状态失败: %w", err)
+	}
+	var state analyzerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return 0, fmt.Errorf("解析速率统计状态失败: %w", err)
+	}
+	if state.Interval != a.opts.Interval {
+		return 0, nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cutoff := time.Now().Add(-a.idleTTL())
+	loaded := 0
+	for id, s := range state.Templates {
+		if s.LastSeen.Before(cutoff) {
+			continue
+		}
+		var slots []slotBaseline
+		if state.Seasonality == a.opts.Seasonality && len(s.Slots) == a.slotCount {
+			for _, ss := range s.Slots {
+				slots = append(slots, slotBaseline{mean: ss.Mean, variance: ss.Variance, buckets: ss.Buckets})
+			}
+		}
+		a.templates[id] = &templateRate{
+			bucketStart: s.BucketStart,
+			count:       s.Count,
+			flagged:     s.Flagged,
+			mean:        s.Mean,
+			variance:    s.Variance,
+			buckets:     s.Buckets,
+			slots:       slots,
+			lastSeen:    s.LastSeen,
+			sample:      s.Sample,
+		}
+		loaded++
+	}
+	return loaded, nil
+}
+
+// RunSave 每个统计周期将速率基线保存到 path 一次，直到 ctx 结束，使进程反复崩溃重启时基线不必重新预热
+func (a *SmartAnalyzer) RunSave(ctx context.Context, path string) {
+	if a == nil || path == "" {
+		return
+	}
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.Save(path); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 事件统计的时间窗口和分桶粒度
+const (
+	statsWindow = time.Hour
+	statsBucket = time.Minute
+)
+
+// 导出为 Prometheus 指标的高频日志模板数
+const statsMetricTop = 10
+
+// 内存上限：每个分桶的 Count-Min Sketch 大小、跟踪的高频模板数以及记录的主机数，
+// 日志模板再多内存也不会增长，每个分桶约占 40KB
+const (
+	statsSketchWidth     = 2048
+	statsSketchDepth     = 4
+	statsTopK            = 100
+	statsMaxHosts        = 10000
+	statsMaxTemplateHost = 100
+)
+
+// 严重性分级，与告警级别一致：5分以上为警告，8分以上为严重
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// severityLevel 返回严重性分数所属的分级
+func severityLevel(score int) string {
+	switch {
+	case score >= 8:
+		return SeverityCritical
+	case score >= 5:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// FrequentEvent 时间窗口内的一类高频事件
+type FrequentEvent struct {
+	TemplateID  string    `json:"template_id"`
+	Count       int       `json:"count"`
+	Hosts       int       `json:"hosts"`
+	MaxSeverity int       `json:"max_severity"`
+	LastSeen    time.Time `json:"last_seen"`
+	Sample      string    `json:"sample"` // 最近一条事件的第一行
+}
+
+// EventStatistics 最近一段时间内的事件概况
+type EventStatistics struct {
+	Window    string          `json:"window"`
+	Total     int             `json:"total"`
+	Hosts     int             `json:"hosts"`
+	Severity  map[string]int  `json:"severity"` // info、warning、critical → 事件数
+	TopEvents []FrequentEvent `json:"top_events"`
+}
+
+// templateCount 一个分桶内某个高频日志模板的明细，次数由 Count-Min Sketch 估计
+type templateCount struct {
+	hosts       map[string]struct{}
+	maxSeverity int
+	lastSeen    time.Time
+	sample      string
+}
+
+// statsBucketData 一分钟内的事件统计：各模板的次数由 Count-Min Sketch 估计，只有高频模板保留明细
+type statsBucketData struct {
+	start    time.Time
+	severity map[string]int
+	hosts    map[string]struct{}
+	sketch   *countMinSketch
+	top      *topK
+}
+
+// EventStats 按分钟分桶统计最近一小时的事件：严重性分布和出现最多的日志模板，
+// 通过 /api/stats 接口和 Prometheus 指标展示当前的事件概况。
+// 模板次数以 Count-Min Sketch 估计，每个分桶只保留次数最多的模板明细，内存与日志模板数无关
+type EventStats struct {
+	mu      sync.Mutex
+	buckets []*statsBucketData // 按时间顺序，最多覆盖一个时间窗口
+}
+
+// NewEventStats 创建事件统计
+func NewEventStats() *EventStats {
+	return &EventStats{}
+}
+
+// Observe 记录一个事件
+func (s *EventStats) Observe(event collector.LogEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	start := now.Truncate(statsBucket)
+	s.expire(now)
+	if len(s.buckets) == 0 || s.buckets[len(s.buckets)-1].start.Before(start) {
+		s.buckets = append(s.buckets, &statsBucketData{
+			start:    start,
+			severity: make(map[string]int),
+			hosts:    make(map[string]struct{}),
+			sketch:   newCountMinSketch(statsSketchWidth, statsSketchDepth),
+			top:      newTopK(statsTopK),
+		})
+	}
+	b := s.buckets[len(s.buckets)-1]
+	b.severity[severityLevel(event.SeverityScore)]++
+	if len(b.hosts) < statsMaxHosts {
+		b.hosts[event.Host] = struct{}{}
+	}
+
+	id := event.TemplateID
+	if id == "" {
+		id = "unknown"
+	}
+	tc := b.top.offer(id, b.sketch.add(id, 1))
+	if tc == nil {
+		return
+	}
+	if len(tc.hosts) < statsMaxTemplateHost {
+		tc.hosts[event.Host] = struct{}{}
+	}
+	tc.maxSeverity = max(tc.maxSeverity, event.SeverityScore)
+	tc.lastSeen = now
+	tc.sample = firstLine(event.RawText)
+}
+
+// firstLine 返回内容的第一行
+func firstLine(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	return line
+}
+
+// expire 丢弃超出时间窗口的分桶
+func (s *EventStats) expire(now time.Time) {
+	cutoff := now.Add(-statsWindow)
+	i := 0
+	for i < len(s.buckets) && !s.buckets[i].start.After(cutoff) {
+		i++
+	}
+	s.buckets = s.buckets[i:]
+}
+
+// GetEventStatistics 返回最近一小时的事件概况，top 为返回的高频事件数（最多100个）。
+// 各分桶的 Sketch 合并后估计每个候选模板在整个窗口内的次数，候选模板为任一分桶中的高频模板；
+// 主机数最多统计10000个，每个模板最多统计100个主机
+func (s *EventStats) GetEventStatistics(top int) EventStatistics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+
+	stats := EventStatistics{
+		Window:    statsWindow.String(),
+		Severity:  map[string]int{SeverityInfo: 0, SeverityWarning: 0, SeverityCritical: 0},
+		TopEvents: []FrequentEvent{},
+	}
+	hosts := make(map[string]struct{})
+	sketch := newCountMinSketch(statsSketchWidth, statsSketchDepth)
+	merged := make(map[string]*FrequentEvent)
+	templateHosts := make(map[string]map[string]struct{})
+	for _, b := range s.buckets {
+		for level, n := range b.severity {
+			stats.Severity[level] += n
+			stats.Total += n
+		}
+		for h := range b.hosts {
+			if len(hosts) < statsMaxHosts {
+				hosts[h] = struct{}{}
+			}
+		}
+		sketch.merge(b.sketch)
+		for id, item := range b.top.items {
+			fe, ok := merged[id]
+			if !ok {
+				fe = &FrequentEvent{TemplateID: id}
+				merged[id] = fe
+				templateHosts[id] = make(map[string]struct{})
+			}
+			tc := item.detail
+			fe.MaxSeverity = max(fe.MaxSeverity, tc.maxSeverity)
+			if tc.lastSeen.After(fe.LastSeen) {
+				fe.LastSeen = tc.lastSeen
+				fe.Sample = tc.sample
+			}
+			for h := range tc.hosts {
+				if len(templateHosts[id]) < statsMaxTemplateHost {
+					templateHosts[id][h] = struct{}{}
+				}
+			}
+		}
+	}
+	stats.Hosts = len(hosts)
+
+	for id, fe := range merged {
+		fe.Count = int(sketch.estimate(id))
+		fe.Hosts = len(templateHosts[id])
+		stats.TopEvents = append(stats.TopEvents, *fe)
+	}
+	sort.Slice(stats.TopEvents, func(i, j int) bool {
+		if stats.TopEvents[i].Count != stats.TopEvents[j].Count {
+			return stats.TopEvents[i].Count > stats.TopEvents[j].Count
+		}
+		return stats.TopEvents[i].TemplateID < stats.TopEvents[j].TemplateID
+	})
+	if top = min(top, statsTopK); len(stats.TopEvents) > top {
+		stats.TopEvents = stats.TopEvents[:top]
+	}
+	return stats
+}
+
+// UpdateMetrics 将最近一小时的严重性分布和出现最多的日志模板更新到 Prometheus 指标
+func (s *EventStats) UpdateMetrics() {
+	stats := s.GetEventStatistics(statsMetricTop)
+	for level, n := range stats.Severity {
+		metrics.EventsLastHour.WithLabelValues(level).Set(float64(n))
+	}
+	metrics.TopTemplateEvents.Reset()
+	for _, fe := range stats.TopEvents {
+		metrics.TopTemplateEvents.WithLabelValues(fe.TemplateID).Set(float64(fe.Count))
+	}
+}
@@ -0,0 +1,189 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/atomicfile"
+	"log-ai-analyzer/collector"
+)
+
+// 内存上限：最多跟踪的 TraceID 数，以及每个 TraceID 最多记录的事件数
+const (
+	traceMaxIDs    = 10000
+	traceMaxEvents = 10
+)
+
+// 保存关联状态的间隔，进程崩溃时最多丢失这段时间内记录的事件
+const traceSaveInterval = 30 * time.Second
+
+// traceEntry 一个 TraceID 在关联窗口内出现过的事件
+type traceEntry struct {
+	key      string
+	events   []collector.RelatedEvent
+	lastSeen time.Time
+}
+
+// TraceCorrelator 按 TraceID/RequestID 关联不同日志文件中的事件，一个请求在网关日志和后端日志中的报错
+// 互相引用，AI分析时一并提供，运维人员可以从网关日志直接追到后端日志
+type TraceCorrelator struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*traceEntry
+	order   []*traceEntry // 按首次出现的顺序，用于淘汰过期和超出上限的 TraceID
+}
+
+// NewTraceCorrelator 创建关联器，window<=0 时返回nil表示不关联
+func NewTraceCorrelator(window time.Duration) *TraceCorrelator {
+	if window <= 0 {
+		return nil
+	}
+	return &TraceCorrelator{window: window, entries: make(map[string]*traceEntry)}
+}
+
+// Link 将关联窗口内同一租户、同一 TraceID 在其他日志文件或主机上出现过的事件记入 event.Related，并记录该事件。
+// 事件按采集顺序关联，后出现的事件引用先出现的事件
+func (c *TraceCorrelator) Link(event *collector.LogEvent) {
+	if c == nil || event.TraceID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.expire(now)
+
+	key := event.Tenant + "\x00" + event.TraceID
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &traceEntry{key: key}
+		c.entries[key] = entry
+		c.order = append(c.order, entry)
+	}
+	for _, r := range entry.events {
+		if r.Host != event.Host || r.FilePath != event.FilePath {
+			event.Related = append(event.Related, r)
+		}
+	}
+	if len(entry.events) < traceMaxEvents {
+		entry.events = append(entry.events, collector.RelatedEvent{
+			DocID:         event.DocumentID(),
+			Host:          event.Host,
+			FilePath:      event.FilePath,
+			LineNumber:    event.LineNumber,
+			Timestamp:     event.Timestamp,
+			SeverityScore: event.SeverityScore,
+			Summary:       firstLine(event.RawText),
+		})
+	}
+	entry.lastSeen = now
+}
+
+// expire 淘汰超过关联窗口没有新事件以及超出数量上限的 TraceID
+func (c *TraceCorrelator) expire(now time.Time) {
+	cutoff := now.Add(-c.window)
+	i := 0
+	for ; i < len(c.order); i++ {
+		e := c.order[i]
+		if len(c.order)-i <= traceMaxIDs && e.lastSeen.After(cutoff) {
+			break
+		}
+		delete(c.entries, e.key)
+	}
+	c.order = c.order[i:]
+}
+
+// traceEntryState 持久化的 TraceID 及其事件
+type traceEntryState struct {
+	Key      string                   `json:"key"`
+	Events   []collector.RelatedEvent `json:"events"`
+	LastSeen time.Time                `json:"last_seen"`
+}
+
+// Save 将关联窗口内的 TraceID 及其事件写入 path，按首次出现的顺序保存
+func (c *TraceCorrelator) Save(path string) error {
+	if c == nil || path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.expire(time.Now())
+	entries := make([]traceEntryState, 0, len(c.order))
+	for _, e := range c.order {
+		entries = append(entries, traceEntryState{Key: e.key, Events: e.events, LastSeen: e.lastSeen})
+	}
+	data, err := json.Marshal(entries)
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("编码请求关联状态失败: %w", err)
+	}
+	if err := atomicfile.WriteFile(path, data); err != nil {
+		return fmt.Errorf("保存请求关联状态失败: %w", err)
+	}
+	return nil
+}
+
+// Load 从 path 恢复上次保存的 TraceID 及其事件，返回恢复的 TraceID 数；状态文件不存在时不恢复。
+// 按当前的关联窗口判断是否过期，已超过窗口的 TraceID 不再恢复，重启后的事件仍能关联到重启前的事件
+func (c *TraceCorrelator) Load(path string) (int, error) {
+	if c == nil || path == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("读取请求关联状态失败: %w", err)
+	}
+	var entries []traceEntryState
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("解析请求关联状态失败: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cutoff := time.Now().Add(-c.window)
+	loaded := 0
+	for _, s := range entries {
+		if !s.LastSeen.After(cutoff) || c.entries[s.Key] != nil {
+			continue
+		}
+		if len(s.Events) > traceMaxEvents {
+			s.Events = s.Events[:traceMaxEvents]
+		}
+		entry := &traceEntry{key: s.Key, events: s.Events, lastSeen: s.LastSeen}
+		c.entries[s.Key] = entry
+		c.order = append(c.order, entry)
+		loaded++
+	}
+	c.expire(time.Now())
+	return loaded, nil
+}
+
+// RunSave 定期将关联状态保存到 path，直到 ctx 结束，使进程崩溃重启后关联上下文不丢失
+func (c *TraceCorrelator) RunSave(ctx context.Context, path string) {
+	if c == nil || path == "" {
+		return
+	}
+	ticker := time.NewTicker(traceSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Save(path); err != nil {
+				log.Printf("%v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/esclient"
+)
+
+// 查询接口最多返回的条数
+const maxAPIPageSize = 500
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// parseAPITime 解析查询参数中的时间：RFC3339 格式，或如 1h 的时长表示当前时间之前多久
+func parseAPITime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s", s)
+}
+
+// parseEventQuery 将查询参数解析为ES事件查询条件
+func parseEventQuery(r *http.Request, now time.Time) (esclient.EventQuery, error) {
+	params := r.URL.Query()
+	q := esclient.EventQuery{
+		EventID:    params.Get("id"),
+		Hosts:      params["host"],
+		Tenants:    params["tenant"],
+		Tags:       params["tag"],
+		TemplateID: params.Get("template"),
+		Text:       params.Get("q"),
+		Ascending:  params.Get("order") == "asc",
+	}
+	for name, dst := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := params.Get(name); v != "" {
+			t, err := parseAPITime(v, now)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = t
+		}
+	}
+	for name, dst := range map[string]*int{"min_severity": &q.MinSeverity, "size": &q.Size, "offset": &q.Offset} {
+		if v := params.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = n
+		}
+	}
+	if q.Size > maxAPIPageSize {
+		return q, fmt.Errorf("size must not exceed %d", maxAPIPageSize)
+	}
+	return q, nil
+}
+
+// eventsHandler 提供 GET /api/events 接口，从ES分页查询事件：
+// id、host、tenant、tag、template、q（全文检索）、from/to（RFC3339 或如 1h 的时长）、min_severity、size、offset、order=asc
+func eventsHandler(esClient *esclient.ESClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query, err := parseEventQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		page, err := esClient.SearchEvents(ctx, query)
+		if err != nil {
+			log.Printf("查询事件失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, page)
+	}
+}
+
+// alertsResponse /api/alerts 接口的响应
+type alertsResponse struct {
+	Total  int                 `json:"total"` // 符合条件的告警数
+	Alerts []esclient.AlertDoc `json:"alerts"`
+}
+
+// alertsHandler 提供 GET /api/alerts?host=&tenant=&min_severity=&limit= 接口，
+// 返回告警缓存中仍在持续（未超过告警TTL）的合并告警，最近出现的在前
+func alertsHandler(alertCache *alert.AlertCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		params := r.URL.Query()
+		minSeverity, limit := 0, 0
+		for name, dst := range map[string]*int{"min_severity": &minSeverity, "limit": &limit} {
+			if v := params.Get(name); v != "" {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 0 {
+					http.Error(w, "invalid "+name, http.StatusBadRequest)
+					return
+				}
+				*dst = n
+			}
+		}
+		host, tenant := params.Get("host"), params.Get("tenant")
+
+		resp := alertsResponse{Alerts: []esclient.AlertDoc{}}
+		for _, a := range alertCache.Active() {
+			if a.Severity < minSeverity || (host != "" && a.Host != host) || (tenant != "" && a.Tenant != tenant) {
+				continue
+			}
+			resp.Total++
+			if limit == 0 || len(resp.Alerts) < limit {
+				resp.Alerts = append(resp.Alerts, alertDoc(a, esclient.AlertActive))
+			}
+		}
+		writeJSON(w, resp)
+	}
+}
+
+// silenceRequest 新增静默规则的请求
+type silenceRequest struct {
+	TemplateID string `json:"template_id"`
+	Reason     string `json:"reason"`
+	Sample     string `json:"sample"`
+}
+
+// silencesHandler 提供 /api/silences 接口，管理噪声抑制规则（被静默的日志模板照常分析和存储，但不发送告警）：
+// GET 列出规则，POST 以JSON新增规则，DELETE ?template_id=ID 删除规则恢复告警；
+// 未配置 NOISE_SUPPRESSION_FILE 时只能查询，返回空列表
+func silencesHandler(path string, suppressor *alert.Suppressor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && path == "" {
+			http.Error(w, "NOISE_SUPPRESSION_FILE is not configured", http.StatusConflict)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			rules := []alert.SuppressionRule{}
+			if path != "" {
+				loaded, err := alert.LoadSuppressions(path)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				rules = append(rules, loaded...)
+			}
+			writeJSON(w, rules)
+		case http.MethodPost:
+			var req silenceRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			req.TemplateID = strings.TrimSpace(req.TemplateID)
+			if req.TemplateID == "" {
+				http.Error(w, "template_id is required", http.StatusBadRequest)
+				return
+			}
+			if req.Reason == "" {
+				req.Reason = "通过 /api/silences 手工添加"
+			}
+			added, err := alert.AddSuppressions(path, []alert.SuppressionRule{{
+				TemplateID: req.TemplateID,
+				Sample:     req.Sample,
+				Reason:     req.Reason,
+				CreatedAt:  time.Now(),
+			}})
+			if err != nil {
+				log.Printf("%v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			status := "exists"
+			if added > 0 {
+				suppressor.Refresh()
+				log.Printf("已静默日志模板 %s: %s", req.TemplateID, req.Reason)
+				status = "created"
+			}
+			writeJSON(w, map[string]string{"status": status, "template_id": req.TemplateID})
+		case http.MethodDelete:
+			templateID := r.URL.Query().Get("template_id")
+			if templateID == "" {
+				http.Error(w, "template_id is required", http.StatusBadRequest)
+				return
+			}
+			removed, err := alert.RemoveSuppression(path, templateID)
+			if err != nil {
+				log.Printf("%v", err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !removed {
+				http.Error(w, "silence not found", http.StatusNotFound)
+				return
+			}
+			suppressor.Refresh()
+			log.Printf("已取消静默日志模板 %s", templateID)
+			writeJSON(w, map[string]string{"status": "deleted", "template_id": templateID})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// apiHealthResponse /api/health 接口的响应
+type apiHealthResponse struct {
+	healthzResponse
+	Ready     bool      `json:"ready"` // 就绪检查是否通过，与 /readyz 一致
+	Instance  string    `json:"instance,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Backlog   int       `json:"backlog"` // 已采集尚未处理完的事件数
+}
+
+// apiHealthHandler 提供 GET /api/health 接口，返回各组件状态、实例标识、启动时间和积压事件数；
+// 与供探针使用的 /readyz 不同，检查未通过时仍返回200，由调用方根据 ready 字段判断
+func apiHealthHandler(p *healthProbe, instance string, startedAt time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		resp, ok := p.check(false)
+		if !ok {
+			resp.Status = "unavailable"
+		}
+		result := apiHealthResponse{healthzResponse: resp, Ready: ok, Instance: instance, StartedAt: startedAt}
+		if p.backlog != nil {
+			result.Backlog = p.backlog.Len()
+		}
+		writeJSON(w, result)
+	}
+}
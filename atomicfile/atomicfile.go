@@ -0,0 +1,29 @@
+// Package atomicfile 原子写入状态文件：先写同目录下的临时文件并落盘，再替换目标文件，
+// 进程崩溃或断电时不会留下不完整的文件
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile 将 data 原子写入 path，临时文件在失败时自动删除
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/processor"
+	"log-ai-analyzer/sink"
+	"log-ai-analyzer/tenant"
+)
+
+// backfillOptions 历史日志回填的参数
+type backfillOptions struct {
+	Files       []string  // 日志文件或目录，目录中的文件（包括 .gz）全部读取
+	From, To    time.Time // 只回填日志时间在 [From, To) 内的事件，零值表示不限制
+	MinSeverity int
+	NoAlert     bool // 只分析和写入存储，不合并告警也不发送告警
+	Workers     int
+}
+
+// backfillStats 历史日志回填的结果
+type backfillStats struct {
+	read       atomic.Int64 // 识别出的事件数
+	skipped    atomic.Int64 // 严重性或日志时间不满足条件而跳过的事件数
+	indexed    atomic.Int64
+	failed     atomic.Int64 // 写入存储失败的事件数
+	alerted    atomic.Int64
+	suppressed atomic.Int64 // 日志模板被静默而未发送的告警数
+}
+
+// parseBackfillTime 解析回填的时间范围：2006-01-02 格式的本地日期、RFC3339 格式，或如 24h 的时长表示当前时间之前多久
+func parseBackfillTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return parseAPITime(s, now)
+}
+
+// inTimeRange 判断事件的日志时间是否在 [from, to) 内；限制了时间范围时，无法识别日志时间的事件不在范围内
+func inTimeRange(event collector.LogEvent, from, to time.Time) bool {
+	if from.IsZero() && to.IsZero() {
+		return true
+	}
+	if event.LogTime.IsZero() {
+		return false
+	}
+	return !event.LogTime.Before(from) && (to.IsZero() || event.LogTime.Before(to))
+}
+
+// runBackfill 从头读取历史日志，按当前规则识别事件并逐条进行AI分析、写入存储后端，可选合并和发送告警；
+// 不读取也不更新采集偏移量，事件以日志中的时间写入，用于故障后的追溯分析和补录服务停止期间的日志
+func runBackfill(ctx context.Context, cfg *config.Config, tenants *tenant.Config, suppressor *alert.Suppressor, opts backfillOptions) (*backfillStats, error) {
+	files, err := collector.ExpandFiles(opts.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	// 回填与运行中的服务共用配置，不使用服务的溢出队列目录，ES不可用时直接失败
+	cfg.ESSpillDir = ""
+	var esClient *esclient.ESClient
+	if cfg.EnableES {
+		if esClient, err = connectESClient(cfg); err != nil {
+			return nil, fmt.Errorf("初始化ES客户端失败: %w", err)
+		}
+		esClient.SetTenantRetention(tenants.Retention())
+		setupES(cfg, esClient)
+		if err := configureESWrites(cfg, esClient, ""); err != nil {
+			return nil, err
+		}
+	}
+	tenantClients, err := newTenantClients(cfg, tenants)
+	if err != nil {
+		return nil, err
+	}
+	for name, c := range tenantClients {
+		connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := c.Connect(connectCtx)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("连接租户 %s 的ES集群失败: %w", name, err)
+		}
+		setupES(cfg, c)
+	}
+	store, err := newStore(cfg, esClient, tenantClients)
+	if err != nil {
+		return nil, err
+	}
+	if len(store) == 0 {
+		return nil, errors.New("未启用任何存储后端，回填的事件无处写入")
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Printf("关闭存储后端失败: %v", err)
+		}
+	}()
+
+	var alertCache *alert.AlertCache
+	if !opts.NoAlert {
+		alertCache = alert.NewAlertCache(cfg.AlertTTL)
+		alertCache.SetSimilarityThreshold(cfg.AlertSimilarity)
+		alertCache.SetMaxContextLines(cfg.AlertMaxContextLines)
+	}
+
+	stats := &backfillStats{}
+	events := make(chan *collector.LogEvent)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range events {
+				backfillEvent(cfg, store, alertCache, suppressor, event, stats)
+			}
+		}()
+	}
+
+	err = func() error {
+		defer close(events)
+		for _, path := range files {
+			read, err := collector.ReadFile(ctx, path, collector.DefaultConfig)
+			if err != nil {
+				if ctx.Err() != nil {
+					return errors.New("已中断")
+				}
+				return fmt.Errorf("读取文件 %s 失败: %w", path, err)
+			}
+			log.Printf("回填 %s: 识别出 %d 个事件", path, len(read))
+			for i := range read {
+				event := &read[i]
+				stats.read.Add(1)
+				if event.SeverityScore < opts.MinSeverity || !inTimeRange(*event, opts.From, opts.To) {
+					stats.skipped.Add(1)
+					continue
+				}
+				if !event.LogTime.IsZero() {
+					event.Timestamp = event.LogTime.Format(time.RFC3339)
+				}
+				tenants.Resolve(event)
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return errors.New("已中断")
+				}
+			}
+		}
+		return nil
+	}()
+	wg.Wait()
+	return stats, err
+}
+
+// backfillEvent 分析一个回填的事件并写入存储，alertCache 不为nil时合并告警并按规则发送
+func backfillEvent(cfg *config.Config, store sink.Multi, alertCache *alert.AlertCache, suppressor *alert.Suppressor, event *collector.LogEvent, stats *backfillStats) {
+	event.RawText = processor.MaskSensitiveInfo(event.RawText)
+
+	aiResult, err := ai.Analyze(cfg, *event)
+	if err != nil {
+		log.Printf("AI分析失败 [EventID: %s]: %v", event.EventID, err)
+		aiResult = ai.FailedResult(*event, err)
+	}
+	if !indexEvent(store, *event, aiResult) {
+		stats.failed.Add(1)
+		return
+	}
+	stats.indexed.Add(1)
+	if alertCache == nil {
+		return
+	}
+
+	send, merged := alertCache.AddOrUpdate(*event, aiResult)
+	storeAlert(store, merged, esclient.AlertActive)
+	if !send {
+		return
+	}
+	if suppressor.Suppressed(event.TemplateID) {
+		stats.suppressed.Add(1)
+		return
+	}
+	if !cfg.EnableAlert || cfg.WeChatWebhook == "" {
+		return
+	}
+	merged.AiResult = ai.Localize(cfg, merged.AiResult, "wechat")
+	err = alert.Deliver("wechat", func() error {
+		return alert.SendWeChat(cfg.Secret("AI_WECHAT_WEBHOOK"), merged)
+	})
+	if err != nil {
+		log.Printf("告警发送失败 [EventID: %s]: %v", event.EventID, err)
+		return
+	}
+	stats.alerted.Add(1)
+}
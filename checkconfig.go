@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/analyzer"
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/report"
+	"log-ai-analyzer/tenant"
+)
+
+// 配置检查项的结果
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkWarn = "warn"
+	checkSkip = "skip"
+)
+
+// checkResult 一项配置检查的结果
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // pass、fail、warn、skip
+	Detail string `json:"detail,omitempty"`
+}
+
+// configChecker 依次执行配置检查并记录结果
+type configChecker struct {
+	results []checkResult
+}
+
+// add 记录一项检查结果，err 为nil时通过
+func (c *configChecker) add(name string, err error) {
+	if err != nil {
+		c.results = append(c.results, checkResult{Name: name, Status: checkFail, Detail: err.Error()})
+		return
+	}
+	c.results = append(c.results, checkResult{Name: name, Status: checkPass})
+}
+
+// warn 记录一项不影响启动、但需要注意的检查结果
+func (c *configChecker) warn(name, detail string) {
+	c.results = append(c.results, checkResult{Name: name, Status: checkWarn, Detail: detail})
+}
+
+// skip 记录一项未执行的检查
+func (c *configChecker) skip(name, detail string) {
+	c.results = append(c.results, checkResult{Name: name, Status: checkSkip, Detail: detail})
+}
+
+// count 返回指定结果的检查项数
+func (c *configChecker) count(status string) int {
+	n := 0
+	for _, r := range c.results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// checkLogFiles 检查日志文件能否读取，文件尚不存在时只提示，服务启动后可能才创建
+func (c *configChecker) checkLogFiles(paths []string) {
+	for _, path := range paths {
+		name := "日志文件 " + path
+		f, err := os.Open(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			c.warn(name, "文件不存在")
+		case err != nil:
+			c.add(name, err)
+		default:
+			f.Close()
+			c.add(name, nil)
+		}
+	}
+}
+
+// checkRuleFiles 检查各规则文件和提示词模板能否解析，其中的正则和通配符能否编译
+func (c *configChecker) checkRuleFiles(cfg *config.Config) {
+	files := []struct {
+		name  string
+		path  string
+		check func(string) error
+	}{
+		{"识别规则", cfg.HeuristicsFile, func(p string) error { _, err := collector.LoadHeuristics(p); return err }},
+		{"租户规则", cfg.TenantRulesFile, func(p string) error { _, err := tenant.Load(p); return err }},
+		{"序列规则", cfg.SequenceRulesFile, func(p string) error { _, err := analyzer.LoadSequenceRules(p); return err }},
+		{"SLO规则", cfg.SLORulesFile, func(p string) error { _, err := analyzer.LoadSLOs(p); return err }},
+		{"噪声抑制规则", cfg.NoiseSuppressionFile, func(p string) error { _, err := alert.LoadSuppressions(p); return err }},
+		{"离线分析规则", cfg.AIOfflineRulesFile, ai.CheckOfflineRules},
+		{"系统提示词模板", cfg.AISystemPromptFile, ai.CheckPromptTemplate},
+		{"用户提示词模板", cfg.AIUserPromptFile, ai.CheckPromptTemplate},
+		{"提示词路由表", cfg.AIPromptRoutesFile, ai.CheckPromptRoutes},
+	}
+	for _, f := range files {
+		if f.path != "" {
+			c.add(f.name+" "+f.path, f.check(f.path))
+		}
+	}
+	if cfg.AlertSeveritySchedule != "" {
+		_, err := alert.ParseSeveritySchedule(cfg.AlertSeveritySchedule)
+		c.add("告警阈值时间表", err)
+	}
+	if cfg.CollectSchedule != "" {
+		_, err := collector.NewScheduler(cfg.LogFiles, cfg.CollectInterval, cfg.CollectSchedule)
+		c.add("采集时间表", err)
+	}
+	if cfg.ReportSchedule != "" {
+		_, err := report.ParseSchedule(cfg.ReportSchedule)
+		c.add("汇总报告时间表", err)
+	}
+	if cfg.OnCallSource != "" {
+		_, err := newOnCallResolver(cfg)
+		c.add("值班来源 "+cfg.OnCallSource, err)
+	}
+}
+
+// checkURL 检查地址是否为 http(s)://主机 的形式
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("地址需以 http:// 或 https:// 开头")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("地址缺少主机名")
+	}
+	return nil
+}
+
+// checkWeChatWebhook 检查企业微信机器人 webhook 的格式
+func checkWeChatWebhook(raw string) error {
+	if err := checkURL(raw); err != nil {
+		return err
+	}
+	u, _ := url.Parse(raw)
+	if u.Query().Get("key") == "" {
+		return fmt.Errorf("webhook 缺少 key 参数，应为 https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...")
+	}
+	return nil
+}
+
+// checkURLs 检查各地址配置的格式，gRPC 后端的地址为 host:port，不检查
+func (c *configChecker) checkURLs(cfg *config.Config) {
+	if cfg.WeChatWebhook != "" {
+		c.add("企业微信 webhook", checkWeChatWebhook(cfg.WeChatWebhook))
+	} else if cfg.EnableAlert {
+		c.warn("企业微信 webhook", "已启用告警但未配置 AI_WECHAT_WEBHOOK，告警不会发送")
+	}
+	urls := []struct {
+		name, provider, value string
+	}{
+		{"AI_API_URL", cfg.AIProvider, cfg.AIAPIURL},
+		{"AI_FALLBACK_API_URL", cfg.AIFallbackProvider, cfg.AIFallbackAPIURL},
+		{"AI_TRIAGE_API_URL", cfg.AITriageProvider, cfg.AITriageAPIURL},
+		{"AI_PROXY_URL", "", cfg.AIProxyURL},
+		{"TICKET_URL", "", cfg.TicketURL},
+		{"KIBANA_URL", "", cfg.KibanaURL},
+		{"FEEDBACK_BASE_URL", "", cfg.FeedbackBaseURL},
+		{"RUNBOOK_BASE_URL", "", cfg.RunbookBaseURL},
+		{"VICTORIALOGS_URL", "", cfg.VictoriaLogsURL},
+	}
+	for _, u := range urls {
+		if u.value != "" && strings.ToLower(u.provider) != "grpc" {
+			c.add(u.name, checkURL(u.value))
+		}
+	}
+	if cfg.EnableES {
+		for _, node := range cfg.ESNodes {
+			if node != "" {
+				c.add("ES节点 "+node, checkURL(node))
+			}
+		}
+	}
+}
+
+// checkConnectivity 连接ES并探测AI后端
+func (c *configChecker) checkConnectivity(cfg *config.Config) {
+	if cfg.EnableES {
+		_, err := connectESClient(cfg)
+		c.add("连接ES "+strings.Join(cfg.ESNodes, ","), err)
+	}
+	if strings.ToLower(cfg.AIEnable) != "true" {
+		return
+	}
+	if err := ai.ConfigureTransport(cfg); err != nil {
+		c.add("AI代理和TLS配置", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	backends, err := ai.Probe(ctx, cfg)
+	if err != nil {
+		c.add("AI后端", err)
+		return
+	}
+	for _, b := range backends {
+		name := fmt.Sprintf("AI后端 %s/%s", b.Provider, b.Model)
+		if b.Healthy {
+			c.add(name, nil)
+		} else {
+			c.add(name, errors.New(b.LastError))
+		}
+	}
+}
+
+// newCheckConfigCommand 创建 `logai check-config` 命令：加载并检查完整配置，输出逐项的检查结果，
+// 有检查项失败时退出码为1，可用于CI和发布前检查
+func newCheckConfigCommand() *cobra.Command {
+	var offline, strict, asJSON bool
+	cmd := &cobra.Command{
+		Use:   "check-config",
+		Short: "检查配置、规则文件、日志文件和ES/AI连通性，不启动服务",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			checker := &configChecker{}
+			cfg, err := config.Load()
+			checker.add("加载配置", err)
+			if err == nil {
+				checker.checkLogFiles(cfg.LogFiles)
+				checker.checkRuleFiles(cfg)
+				checker.checkURLs(cfg)
+				if offline {
+					checker.skip("ES和AI连通性", "--offline")
+				} else {
+					checker.checkConnectivity(cfg)
+				}
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				enc.Encode(checker.results)
+			} else {
+				printCheckResults(checker.results)
+			}
+
+			failed := checker.count(checkFail)
+			if strict {
+				failed += checker.count(checkWarn)
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d 项配置检查未通过", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&offline, "offline", false, "不检查ES和AI后端的连通性")
+	cmd.Flags().BoolVar(&strict, "strict", false, "有警告时也以失败退出")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "以JSON格式输出检查结果")
+	return cmd
+}
+
+// checkIcons 检查结果对应的图标
+var checkIcons = map[string]string{
+	checkPass: "✅",
+	checkFail: "❌",
+	checkWarn: "⚠️",
+	checkSkip: "⏭️",
+}
+
+// printCheckResults 以文本形式逐项输出检查结果
+func printCheckResults(results []checkResult) {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Detail != "" {
+			fmt.Printf("%s %s: %s\n", checkIcons[r.Status], r.Name, r.Detail)
+		} else {
+			fmt.Printf("%s %s\n", checkIcons[r.Status], r.Name)
+		}
+	}
+	fmt.Printf("\n通过 %d 项，失败 %d 项，警告 %d 项，跳过 %d 项\n",
+		counts[checkPass], counts[checkFail], counts[checkWarn], counts[checkSkip])
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/tenant"
+)
+
+// newRootCommand 创建命令行入口：不带子命令时与 `logai run` 相同，启动日志分析服务。
+// 每个配置项都有对应的全局参数（如 --es-nodes 对应 ES_NODES），参数优先于环境变量和 .env 文件
+func newRootCommand() *cobra.Command {
+	var envFile string
+	root := &cobra.Command{
+		Use:           "logai",
+		Short:         "日志采集、AI分析与告警服务",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if envFile != "" {
+				if err := godotenv.Load(envFile); err != nil {
+					return fmt.Errorf("加载配置文件 %s 失败: %w", envFile, err)
+				}
+			}
+			return applyConfigFlags(cmd.Flags())
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runServer(runOptions{}))
+		},
+	}
+	root.PersistentFlags().StringVar(&envFile, "env-file", "", "额外加载的 .env 格式配置文件，不覆盖已设置的环境变量")
+	for _, env := range config.EnvVars {
+		root.PersistentFlags().String(config.FlagName(env), "", "覆盖环境变量 "+env)
+	}
+
+	root.AddCommand(
+		newRunCommand(),
+		newCheckConfigCommand(),
+		newConfigCommand(),
+		newReplayCommand(),
+		newAlertCommand(),
+		newReportCommand(),
+		newTrendCommand(),
+		newSidecarCommand(),
+		newExportCommand(),
+		newProvisionCommand(),
+		newProvisionGrafanaCommand(),
+		newProvisionCRDCommand(),
+		newMigrateCommand(),
+		newAdminCommand(),
+	)
+	return root
+}
+
+// newRunCommand 创建 `logai run` 命令：启动日志分析服务；--once 时采集一轮后退出，
+// 发现高严重性事件时以退出码2退出，供 cron 和 CI 中的日志检查使用
+func newRunCommand() *cobra.Command {
+	var opts runOptions
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "启动日志分析服务",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runServer(opts))
+		},
+	}
+	cmd.Flags().BoolVar(&opts.Once, "once", false, "只采集一轮，处理完所有事件、提交存储和告警后退出，不启动指标和管理服务")
+	cmd.Flags().IntVar(&opts.FailSeverity, "fail-severity", 8, "单次运行时发现严重性不低于该值的事件则以退出码2退出")
+	return cmd
+}
+
+// applyConfigFlags 将命令行中设置的配置参数写入对应的环境变量，之后加载的配置以参数为准
+func applyConfigFlags(flags *pflag.FlagSet) error {
+	var err error
+	for _, env := range config.EnvVars {
+		f := flags.Lookup(config.FlagName(env))
+		if f == nil || !f.Changed {
+			continue
+		}
+		if e := os.Setenv(env, f.Value.String()); e != nil && err == nil {
+			err = fmt.Errorf("设置 %s 失败: %w", env, e)
+		}
+	}
+	return err
+}
+
+// replayedEvent 重放时输出的事件
+type replayedEvent struct {
+	Timestamp  string   `json:"timestamp"`
+	Host       string   `json:"host"`
+	FilePath   string   `json:"file_path"`
+	LineNumber int      `json:"line_number"`
+	Severity   int      `json:"severity_score"`
+	TemplateID string   `json:"template_id"`
+	Tenant     string   `json:"tenant,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Suppressed bool     `json:"suppressed,omitempty"` // 日志模板在噪声抑制规则中，不会告警
+	Content    string   `json:"content"`
+	AiResult   string   `json:"ai_result,omitempty"`
+}
+
+// newReplayCommand 创建 `logai replay` 命令：从头读取历史日志文件，按当前的采集规则、租户规则和噪声抑制规则
+// 输出识别出的事件，可选逐条进行AI分析；不更新采集偏移量，不写入存储，不发送告警，用于验证规则修改的效果。
+// 以 --file 指定日志时回填历史日志：事件经AI分析后写入存储后端，并按告警规则发送告警（--no-alert 时不发送）
+func newReplayCommand() *cobra.Command {
+	var minSeverity, workers int
+	var withAI, asJSON, noAlert bool
+	var files []string
+	var fromSpec, toSpec string
+	cmd := &cobra.Command{
+		Use:   "replay [日志文件或目录]...",
+		Short: "重放历史日志，输出识别出的事件；以 --file 指定时回填到存储后端",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(files) > 0 && len(args) > 0 {
+				return errors.New("回填的日志通过 --file 指定，不能同时以参数指定")
+			}
+			if len(files) == 0 && len(args) == 0 {
+				return errors.New("请指定要重放的日志文件或目录")
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			var from, to time.Time
+			now := time.Now()
+			if fromSpec != "" {
+				if from, err = parseBackfillTime(fromSpec, now); err != nil {
+					return err
+				}
+			}
+			if toSpec != "" {
+				if to, err = parseBackfillTime(toSpec, now); err != nil {
+					return err
+				}
+			}
+			var tenants *tenant.Config
+			if cfg.TenantRulesFile != "" {
+				if tenants, err = tenant.Load(cfg.TenantRulesFile); err != nil {
+					return err
+				}
+			}
+			collector.DefaultConfig.ContextLines = cfg.ContextLines
+			if cfg.HeuristicsFile != "" {
+				heuristics, err := collector.LoadHeuristics(cfg.HeuristicsFile)
+				if err != nil {
+					return err
+				}
+				collector.SetHeuristics(heuristics)
+			}
+			suppressor := alert.NewSuppressor(cfg.NoiseSuppressionFile)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if len(files) > 0 {
+				configureAI(cfg)
+				alert.SetInstance(cfg.InstanceID, cfg.InstanceLabels)
+				setAlertDelivery(cfg)
+				stats, err := runBackfill(ctx, cfg, tenants, suppressor, backfillOptions{
+					Files:       files,
+					From:        from,
+					To:          to,
+					MinSeverity: minSeverity,
+					NoAlert:     noAlert,
+					Workers:     max(workers, 1),
+				})
+				if stats != nil {
+					fmt.Printf("共识别 %d 个事件，跳过 %d 个，写入 %d 个，写入失败 %d 个，发送告警 %d 条，静默 %d 条\n",
+						stats.read.Load(), stats.skipped.Load(), stats.indexed.Load(), stats.failed.Load(), stats.alerted.Load(), stats.suppressed.Load())
+				}
+				return err
+			}
+
+			if withAI {
+				configureAI(cfg)
+			}
+			paths, err := collector.ExpandFiles(args)
+			if err != nil {
+				return err
+			}
+			var replayed []replayedEvent
+			severity := make(map[int]int)
+			for _, path := range paths {
+				events, err := collector.ReadFile(ctx, path, collector.DefaultConfig)
+				if err != nil {
+					return fmt.Errorf("读取文件 %s 失败: %w", path, err)
+				}
+				for i := range events {
+					event := &events[i]
+					if event.SeverityScore < minSeverity || !inTimeRange(*event, from, to) {
+						continue
+					}
+					tenants.Resolve(event)
+					r := replayedEvent{
+						Timestamp:  event.Timestamp,
+						Host:       event.Host,
+						FilePath:   event.FilePath,
+						LineNumber: event.LineNumber,
+						Severity:   event.SeverityScore,
+						TemplateID: event.TemplateID,
+						Tenant:     event.Tenant,
+						Tags:       event.Tags,
+						Suppressed: suppressor.Suppressed(event.TemplateID),
+						Content:    event.RawText,
+					}
+					if withAI {
+						if r.AiResult, err = ai.Analyze(cfg, *event); err != nil {
+							r.AiResult = ai.FailedResult(*event, err)
+						}
+					}
+					severity[event.SeverityScore]++
+					if !asJSON {
+						printReplayed(r)
+					}
+					replayed = append(replayed, r)
+				}
+				if ctx.Err() != nil {
+					return errors.New("已中断")
+				}
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(replayed)
+			}
+			scores := make([]int, 0, len(severity))
+			for score := range severity {
+				scores = append(scores, score)
+			}
+			sort.Sort(sort.Reverse(sort.IntSlice(scores)))
+			fmt.Printf("共 %d 个事件", len(replayed))
+			for _, score := range scores {
+				fmt.Printf("，严重性%d: %d", score, severity[score])
+			}
+			fmt.Println()
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&minSeverity, "min-severity", 0, "只输出严重性不低于该值的事件")
+	cmd.Flags().BoolVar(&withAI, "ai", false, "逐条进行AI分析（会调用AI接口）")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "以JSON格式输出")
+	cmd.Flags().StringArrayVar(&files, "file", nil, "回填的日志文件或目录（包括 .gz），可重复指定；事件经AI分析后写入存储后端")
+	cmd.Flags().StringVar(&fromSpec, "from", "", "只处理日志时间不早于该时间的事件：2006-01-02、RFC3339 或如 24h 的时长")
+	cmd.Flags().StringVar(&toSpec, "to", "", "只处理日志时间早于该时间的事件，格式同 --from")
+	cmd.Flags().BoolVar(&noAlert, "no-alert", false, "回填时只分析和写入存储，不合并也不发送告警")
+	cmd.Flags().IntVar(&workers, "workers", 4, "回填时并发分析和写入的协程数")
+	return cmd
+}
+
+// printReplayed 以文本形式输出重放的事件
+func printReplayed(r replayedEvent) {
+	suppressed := ""
+	if r.Suppressed {
+		suppressed = " [已抑制]"
+	}
+	line, _, _ := strings.Cut(r.Content, "\n")
+	fmt.Printf("[严重性%d] %s:%d 模板 %s%s\n  %s\n", r.Severity, r.FilePath, r.LineNumber, r.TemplateID, suppressed, line)
+	if r.AiResult != "" {
+		fmt.Printf("  AI分析: %s\n", strings.ReplaceAll(strings.TrimSpace(r.AiResult), "\n", "\n  "))
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,39 +14,6 @@ import (
 // 注意：文件读取和处理相关的函数已移到 processor.go 文件中
 // 包括：readFromFileWithContext, readFromFile, toLogEventWithContext, toLogEvent 等函数
 
-// 扩展关键词匹配，支持正则表达式
-var keywords = []string{"ERROR", "FAILED", "Failed", "Error", "fail", "error", "oom", "killed", "OOM", "KILLED", "Cell Trace", "Runtime Error", "Exception", "Panic", "Fatal", "Critical", "Timeout", "Connection refused", "OutOfMemory", "Segmentation fault", "core dumped", "Blocked for more than", "hung_task_timeout_secs"}
-
-// 严重性评分系统
-var severityMap = map[string]int{
-	"FATAL":                  10,
-	"CRITICAL":               9,
-	"ERROR":                  8,
-	"EXCEPTION":              7,
-	"PANIC":                  7,
-	"OOM":                    8,
-	"OUTOFMEMORY":            8,
-	"KILLED":                 6,
-	"FAILED":                 5,
-	"FAIL":                   4,
-	"CELL TRACE":             6,
-	"RUNTIME ERROR":          5,
-	"TIMEOUT":                4,
-	"CONNECTION REFUSED":     3,
-	"HUNG_TASK_TIMEOUT_SECS": 5,
-	"SEGMENTATION FAULT":     9,
-	"CORE DUMPED":            8,
-	"BLOCKED FOR MORE THAN":  8,
-}
-
-// Cell Trace 异常的特殊模式
-var cellTracePatterns = []*regexp.Regexp{
-	regexp.MustCompile(`(?i)cell\s+trace.*error`),
-	regexp.MustCompile(`(?i)cell\s+trace.*exception`),
-	regexp.MustCompile(`(?i)cell\s+trace.*failed`),
-	regexp.MustCompile(`(?i)trace\s+id:\s*[a-zA-Z0-9-]+.*error`),
-}
-
 const (
 	offsetFilePrefix = ".last_offset_"
 	offsetDirPath    = "./offsets" // 存储偏移量文件的目录
@@ -58,14 +24,36 @@ type LogEvent struct {
 	RawLines      []string
 	RawText       string
 	Timestamp     string
+	LogTime       time.Time // 日志行中的时间戳，即日志实际产生的时间，无法识别时为零值
 	Host          string
 	Tags          []string
 	SeverityScore int
 	EventID       string
-	FilePath      string   // 添加文件路径
-	LineNumber    int      // 添加行号
-	ContextLines  []string // 添加上下文行
-	IsCellTrace   bool     // 标识是否为Cell Trace异常
+	FilePath      string         // 添加文件路径
+	LineNumber    int            // 添加行号
+	Offset        int64          // 事件首行在文件中的字节偏移，与文件路径、主机名一起唯一标识一次出现
+	ContextLines  []string       // 添加上下文行
+	IsCellTrace   bool           // 标识是否为Cell Trace异常
+	TemplateID    string         // 日志模板ID（去除时间戳、数字等变量后的内容哈希）
+	Seq           uint64         // 写前日志中的序号，0表示未持久化
+	Tenant        string         // 所属租户，按租户规则识别
+	Service       string         // 所属服务
+	Team          string         // 负责团队
+	IncidentID    string         // 所属的故障，由故障跟踪器归并
+	TraceID       string         // 日志中的 TraceID 或 RequestID，没有时为空
+	Related       []RelatedEvent // 其他日志文件中同一 TraceID 的事件，由 TraceCorrelator 关联
+	Occurrences   int            // 所属告警在合并窗口内的出现次数（含本事件），由告警缓存统计
+}
+
+// RelatedEvent 与事件属于同一请求（TraceID 相同）的其他日志文件中的事件
+type RelatedEvent struct {
+	DocID         string
+	Host          string
+	FilePath      string
+	LineNumber    int
+	Timestamp     string
+	SeverityScore int
+	Summary       string // 事件的第一行
 }
 
 // 并行采集配置
@@ -74,6 +62,7 @@ type CollectorConfig struct {
 	ContextLines int           // 上下文行数
 	BufferSize   int           // 缓冲区大小
 	Timeout      time.Duration // 超时时间
+	MaxReadBytes int64         // 每个文件单次最多读取的字节数，0表示读到文件末尾
 }
 
 // 默认配置
@@ -82,6 +71,7 @@ var DefaultConfig = CollectorConfig{
 	ContextLines: 5,
 	BufferSize:   1000,
 	Timeout:      30 * time.Second,
+	MaxReadBytes: 16 << 20,
 }
 
 // 并行多日志文件采集，支持超时和错误处理
@@ -168,6 +158,20 @@ func ReadNewLogEventsWithConfig(filePaths []string, config CollectorConfig) ([]L
 	return allEvents, nil
 }
 
+// Lag 返回各文件中尚未读取的字节数，文件被截断或不存在时为0
+func Lag(filePaths []string) map[string]int64 {
+	lag := make(map[string]int64, len(filePaths))
+	for _, path := range filePaths {
+		lag[path] = 0
+		if info, err := os.Stat(path); err == nil {
+			if n := info.Size() - loadOffset(path); n > 0 {
+				lag[path] = n
+			}
+		}
+	}
+	return lag
+}
+
 // loadOffset loads the last read offset for a file
 func loadOffset(filePath string) int64 {
 	// 确保offset目录存在
@@ -218,17 +222,16 @@ func sanitizeFileName(path string) string {
 }
 
 // isLineMatch checks if a line matches any of the defined patterns
-func isLineMatch(line string) (bool, bool) {
+func (h *Heuristics) isLineMatch(line string) (bool, bool) {
 	// 检查是否为Cell Trace异常
-	for _, pattern := range cellTracePatterns {
-		if pattern.MatchString(line) {
-			return true, true
-		}
+	if h.isCellTrace(line) {
+		return true, true
 	}
 
 	// 检查普通关键词
-	for _, kw := range keywords {
-		if strings.Contains(strings.ToUpper(line), strings.ToUpper(kw)) {
+	upperLine := strings.ToUpper(line)
+	for _, kw := range h.upperKeywords {
+		if strings.Contains(upperLine, kw) {
 			return true, false
 		}
 	}
@@ -242,15 +245,14 @@ func isLineMatch(line string) (bool, bool) {
 }
 
 // shouldIncludeLine determines if a line should be included in the event context
-func shouldIncludeLine(line string, buffer []string) bool {
+func (h *Heuristics) shouldIncludeLine(line string, buffer []string) bool {
 	// 如果是空行，跳过
 	if strings.TrimSpace(line) == "" {
 		return false
 	}
 
 	// 如果包含堆栈跟踪相关的关键词
-	stackTraceKeywords := []string{"at ", "Caused by", "\t", "    ", "Exception in thread", "java.", "org.", "com.", "Traceback", "File \"", "line "}
-	for _, kw := range stackTraceKeywords {
+	for _, kw := range h.StackTraceKeywords {
 		if strings.Contains(line, kw) {
 			return true
 		}
@@ -263,8 +265,7 @@ func shouldIncludeLine(line string, buffer []string) bool {
 		// 如果第一个匹配行包含Call Trace关键词
 		if strings.Contains(firstLine, "Call Trace:") || strings.Contains(firstLine, "call trace") {
 			// 包含以下特征的行都应该收集
-			kernelTraceIndicators := []string{"<TASK>", "</TASK>", "+0x", "/", "RIP:", "RSP:", "RAX:", "RBX:", "RCX:", "RDX:", "RSI:", "RDI:", "RBP:", "R8:", "R9:", "R10:", "R11:", "R12:", "R13:", "R14:", "R15:"}
-			for _, indicator := range kernelTraceIndicators {
+			for _, indicator := range h.KernelTraceIndicators {
 				if strings.Contains(line, indicator) {
 					return true
 				}
@@ -286,14 +287,12 @@ func shouldIncludeLine(line string, buffer []string) bool {
 	// 如果是Cell Trace相关的后续行
 	if len(buffer) > 0 {
 		firstLine := buffer[0]
-		for _, pattern := range cellTracePatterns {
-			if pattern.MatchString(firstLine) {
-				// Cell Trace异常，收集更多上下文
-				if strings.Contains(strings.ToLower(line), "trace") ||
-					strings.Contains(strings.ToLower(line), "cell") ||
-					strings.Contains(line, ":") {
-					return true
-				}
+		if h.isCellTrace(firstLine) {
+			// Cell Trace异常，收集更多上下文
+			if strings.Contains(strings.ToLower(line), "trace") ||
+				strings.Contains(strings.ToLower(line), "cell") ||
+				strings.Contains(line, ":") {
+				return true
 			}
 		}
 	}
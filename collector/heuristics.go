@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Heuristics 日志事件的识别规则：触发事件的关键词、关键词的严重性评分、Cell Trace 模式，
+// 以及判断后续行属于同一事件的堆栈行和内核 Call Trace 行特征
+type Heuristics struct {
+	Keywords              []string       `yaml:"keywords,omitempty"`                // 行中包含任一关键词（不区分大小写）即触发事件
+	Severity              map[string]int `yaml:"severity,omitempty"`                // 关键词的严重性评分（1-10），在默认评分上覆盖，设为0表示不计分
+	CellTracePatterns     []string       `yaml:"cell_trace_patterns,omitempty"`     // Cell Trace 异常的正则（不区分大小写）
+	StackTraceKeywords    []string       `yaml:"stack_trace_keywords,omitempty"`    // 包含任一特征的后续行归入同一事件
+	KernelTraceIndicators []string       `yaml:"kernel_trace_indicators,omitempty"` // 内核 Call Trace 事件中包含任一特征的后续行归入同一事件
+
+	upperKeywords []string
+	cellTrace     []*regexp.Regexp
+}
+
+// FileHeuristics 只对匹配的日志文件生效的识别规则，未设置的项沿用全局规则
+type FileHeuristics struct {
+	Files      []string `yaml:"files"` // 日志文件路径通配符，任一匹配
+	Heuristics `yaml:",inline"`
+}
+
+// HeuristicsConfig 识别规则配置：全局规则和按日志文件的覆盖规则，未设置的项使用内置的默认值
+type HeuristicsConfig struct {
+	Heuristics `yaml:",inline"`
+	Overrides  []FileHeuristics `yaml:"overrides,omitempty"` // 按顺序匹配，使用第一条匹配的规则
+}
+
+// defaultHeuristics 内置的识别规则
+var defaultHeuristics = mustCompileHeuristics(Heuristics{
+	Keywords: []string{"ERROR", "FAILED", "Failed", "Error", "fail", "error", "oom", "killed", "OOM", "KILLED", "Cell Trace", "Runtime Error", "Exception", "Panic", "Fatal", "Critical", "Timeout", "Connection refused", "OutOfMemory", "Segmentation fault", "core dumped", "Blocked for more than", "hung_task_timeout_secs"},
+	Severity: map[string]int{
+		"FATAL":                  10,
+		"CRITICAL":               9,
+		"ERROR":                  8,
+		"EXCEPTION":              7,
+		"PANIC":                  7,
+		"OOM":                    8,
+		"OUTOFMEMORY":            8,
+		"KILLED":                 6,
+		"FAILED":                 5,
+		"FAIL":                   4,
+		"CELL TRACE":             6,
+		"RUNTIME ERROR":          5,
+		"TIMEOUT":                4,
+		"CONNECTION REFUSED":     3,
+		"HUNG_TASK_TIMEOUT_SECS": 5,
+		"SEGMENTATION FAULT":     9,
+		"CORE DUMPED":            8,
+		"BLOCKED FOR MORE THAN":  8,
+	},
+	CellTracePatterns: []string{
+		`cell\s+trace.*error`,
+		`cell\s+trace.*exception`,
+		`cell\s+trace.*failed`,
+		`trace\s+id:\s*[a-zA-Z0-9-]+.*error`,
+	},
+	StackTraceKeywords:    []string{"at ", "Caused by", "\t", "    ", "Exception in thread", "java.", "org.", "com.", "Traceback", "File \"", "line "},
+	KernelTraceIndicators: []string{"<TASK>", "</TASK>", "+0x", "/", "RIP:", "RSP:", "RAX:", "RBX:", "RCX:", "RDX:", "RSI:", "RDI:", "RBP:", "R8:", "R9:", "R10:", "R11:", "R12:", "R13:", "R14:", "R15:"},
+})
+
+// heuristics 当前生效的识别规则，nil表示使用内置规则
+var heuristics atomic.Pointer[HeuristicsConfig]
+
+// SetHeuristics 设置采集使用的识别规则，nil表示使用内置规则；运行中替换时从下一次读取文件开始生效
+func SetHeuristics(c *HeuristicsConfig) {
+	heuristics.Store(c)
+}
+
+// heuristicsFor 返回日志文件使用的识别规则
+func heuristicsFor(filePath string) *Heuristics {
+	c := heuristics.Load()
+	if c == nil {
+		return defaultHeuristics
+	}
+	for i := range c.Overrides {
+		o := &c.Overrides[i]
+		for _, glob := range o.Files {
+			if ok, _ := filepath.Match(glob, filePath); ok {
+				return &o.Heuristics
+			}
+		}
+	}
+	return &c.Heuristics
+}
+
+// LoadHeuristics 从YAML文件加载识别规则，未设置的项使用内置的默认值
+func LoadHeuristics(path string) (*HeuristicsConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取识别规则失败: %w", err)
+	}
+	var c HeuristicsConfig
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("解析识别规则失败: %w", err)
+	}
+	if c.Heuristics, err = c.Heuristics.merge(defaultHeuristics); err != nil {
+		return nil, err
+	}
+	for i := range c.Overrides {
+		o := &c.Overrides[i]
+		if len(o.Files) == 0 {
+			return nil, fmt.Errorf("识别规则覆盖 %d 缺少 files", i+1)
+		}
+		for _, glob := range o.Files {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("识别规则覆盖 %d 的通配符 %q 无效: %w", i+1, glob, err)
+			}
+		}
+		if o.Heuristics, err = o.Heuristics.merge(&c.Heuristics); err != nil {
+			return nil, fmt.Errorf("识别规则覆盖 %d: %w", i+1, err)
+		}
+	}
+	return &c, nil
+}
+
+// merge 以 base 补齐未设置的项并编译正则，评分在 base 的基础上覆盖
+func (h Heuristics) merge(base *Heuristics) (Heuristics, error) {
+	if len(h.Keywords) == 0 {
+		h.Keywords = base.Keywords
+	}
+	if len(h.CellTracePatterns) == 0 {
+		h.CellTracePatterns = base.CellTracePatterns
+	}
+	if len(h.StackTraceKeywords) == 0 {
+		h.StackTraceKeywords = base.StackTraceKeywords
+	}
+	if len(h.KernelTraceIndicators) == 0 {
+		h.KernelTraceIndicators = base.KernelTraceIndicators
+	}
+	severity := make(map[string]int, len(base.Severity)+len(h.Severity))
+	for kw, score := range base.Severity {
+		severity[kw] = score
+	}
+	for kw, score := range h.Severity {
+		if score < 0 || score > 10 {
+			return h, fmt.Errorf("关键词 %s 的严重性评分应为0到10: %d", kw, score)
+		}
+		if score == 0 {
+			delete(severity, strings.ToUpper(kw))
+			continue
+		}
+		severity[strings.ToUpper(kw)] = score
+	}
+	h.Severity = severity
+	return h, h.compile()
+}
+
+// compile 编译 Cell Trace 正则并预先转换关键词的大小写
+func (h *Heuristics) compile() error {
+	h.cellTrace = make([]*regexp.Regexp, 0, len(h.CellTracePatterns))
+	for _, p := range h.CellTracePatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return fmt.Errorf("Cell Trace 模式 %q 无效: %w", p, err)
+		}
+		h.cellTrace = append(h.cellTrace, re)
+	}
+	h.upperKeywords = make([]string, len(h.Keywords))
+	for i, kw := range h.Keywords {
+		h.upperKeywords[i] = strings.ToUpper(kw)
+	}
+	return nil
+}
+
+// mustCompileHeuristics 编译内置的识别规则
+func mustCompileHeuristics(h Heuristics) *Heuristics {
+	if err := h.compile(); err != nil {
+		panic(err)
+	}
+	return &h
+}
+
+// isCellTrace 判断文本是否匹配 Cell Trace 模式
+func (h *Heuristics) isCellTrace(text string) bool {
+	for _, pattern := range h.cellTrace {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// LinesBefore 返回文件中最后一次出现 line 的那一行之前的至多 n 行
+// 采集时的行号是相对本次读取偏移量的，因此按内容定位事件所在行
+func LinesBefore(filePath, line string, n int) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var window, found []string
+	matched := false
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if text == line {
+			found = append(found[:0], window...)
+			matched = true
+		}
+		window = append(window, text)
+		if len(window) > n {
+			window = window[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !matched {
+		return nil, fmt.Errorf("未在文件 %s 中找到事件所在行", filePath)
+	}
+	return found, nil
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// logTimeFormat 日志行中可识别的时间戳格式
+type logTimeFormat struct {
+	pattern *regexp.Regexp
+	layout  string
+	// normalize 把匹配到的时间戳转换为 layout 的格式，为nil时不转换
+	normalize *strings.Replacer
+	// noYear 为true表示时间戳不含年份（syslog 格式），按当前年份补全
+	noYear bool
+}
+
+// logTimeFormats 按顺序尝试的时间戳格式，不含时区的按本地时间解析
+var logTimeFormats = []logTimeFormat{
+	{pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`), layout: time.RFC3339Nano},
+	{pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}([.,]\d+)?`), layout: "2006-01-02 15:04:05.999999999", normalize: strings.NewReplacer(",", ".", "T", " ")},
+	{pattern: regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`), layout: time.Stamp, noYear: true},
+}
+
+// ExtractLogTime 解析事件首行中的时间戳，即日志实际产生的时间，无法识别时返回零值
+func ExtractLogTime(lines []string, now time.Time) time.Time {
+	if len(lines) == 0 {
+		return time.Time{}
+	}
+	for _, f := range logTimeFormats {
+		match := f.pattern.FindString(lines[0])
+		if match == "" {
+			continue
+		}
+		if f.normalize != nil {
+			match = f.normalize.Replace(match)
+		}
+		t, err := time.ParseInLocation(f.layout, match, time.Local)
+		if err != nil {
+			continue
+		}
+		if f.noYear {
+			t = t.AddDate(now.Year(), 0, 0)
+			// 跨年时12月底的日志在1月初才被采集
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
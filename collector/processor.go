@@ -2,11 +2,15 @@ package collector
 
 import (
 	"bufio"
+	"compress/gzip"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -14,58 +18,144 @@ import (
 
 // 带上下文的文件读取函数
 func readFromFileWithContext(ctx context.Context, filePath string, config CollectorConfig) ([]LogEvent, error) {
+	events, offset, err := readEvents(ctx, filePath, loadOffset(filePath), config)
+	if err != nil {
+		return events, err
+	}
+	saveOffset(filePath, offset)
+	return events, nil
+}
+
+// ReadFile 从头读取整个文件中的事件，不读取也不更新采集偏移量，用于重放历史日志；.gz 结尾的文件解压后读取
+func ReadFile(ctx context.Context, filePath string, config CollectorConfig) ([]LogEvent, error) {
+	config.MaxReadBytes = 0
+	if !strings.HasSuffix(filePath, ".gz") {
+		events, _, err := readEvents(ctx, filePath, 0, config)
+		return events, err
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("解压文件 %s 失败: %w", filePath, err)
+	}
+	defer gz.Close()
+	events, _, err := scanEvents(ctx, gz, filePath, 0, config)
+	return events, err
+}
+
+// ExpandFiles 将路径展开为要读取的文件列表：目录递归列出其中的文件（按路径排序，跳过隐藏文件），文件原样保留
+func ExpandFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p != path && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.Type().IsRegular() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readEvents 从 lastOffset 开始读取文件中的事件，返回读取到的位置
+func readEvents(ctx context.Context, filePath string, lastOffset int64, config CollectorConfig) ([]LogEvent, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
 
-	lastOffset := loadOffset(filePath)
 	_, err = file.Seek(lastOffset, io.SeekStart)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	return scanEvents(ctx, file, filePath, lastOffset, config)
+}
 
-	scanner := bufio.NewScanner(file)
+// scanEvents 从 r 中读取事件，lastOffset 为 r 的起始位置在文件中的偏移量，返回读取到的位置
+func scanEvents(ctx context.Context, r io.Reader, filePath string, lastOffset int64, config CollectorConfig) ([]LogEvent, int64, error) {
+	reader := bufio.NewReader(r)
 	var events []LogEvent
 	var allLines []string
 	var lineNumbers []int
+	var lineOffsets []int64
 	lineNum := 0
+	offset := lastOffset
 
-	// 首先读取所有行，用于上下文提取
-	for scanner.Scan() {
+	// 首先读取所有行，用于上下文提取；单次最多读取 MaxReadBytes，剩余的行留到下次读取
+	for config.MaxReadBytes <= 0 || offset-lastOffset < config.MaxReadBytes {
 		select {
 		case <-ctx.Done():
-			return events, ctx.Err()
+			return events, offset, ctx.Err()
 		default:
 		}
-		lineNum++
-		allLines = append(allLines, scanner.Text())
-		lineNumbers = append(lineNumbers, lineNum)
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			lineOffsets = append(lineOffsets, offset)
+			offset += int64(len(line))
+			lineNum++
+			allLines = append(allLines, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+			lineNumbers = append(lineNumbers, lineNum)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events, offset, err
+		}
 	}
 
 	// 查找匹配的行
+	h := heuristicsFor(filePath)
 	var buffer []string
 	var bufferLineNums []int
 	var matched bool
 	var matchStartLine int
+	var matchOffset int64
 
 	for i, line := range allLines {
-		isMatch, isCellTrace := isLineMatch(line)
+		isMatch, isCellTrace := h.isLineMatch(line)
 		if isMatch {
 			if len(buffer) > 0 && matched {
 				// 处理前一个事件
 				event := toLogEventWithContext(buffer, bufferLineNums, filePath, matchStartLine, allLines, config.ContextLines)
 				event.IsCellTrace = isCellTrace
+				event.Offset = matchOffset
 				events = append(events, event)
 			}
 			buffer = []string{line}
 			bufferLineNums = []int{lineNumbers[i]}
 			matchStartLine = lineNumbers[i]
+			matchOffset = lineOffsets[i]
 			matched = true
 		} else if matched {
 			// 继续收集相关行，特别是Cell Trace的完整堆栈
-			if shouldIncludeLine(line, buffer) {
+			if h.shouldIncludeLine(line, buffer) {
 				buffer = append(buffer, line)
 				bufferLineNums = append(bufferLineNums, lineNumbers[i])
 			}
@@ -75,13 +165,12 @@ func readFromFileWithContext(ctx context.Context, filePath string, config Collec
 	// 处理最后一个事件
 	if matched && len(buffer) > 0 {
 		event := toLogEventWithContext(buffer, bufferLineNums, filePath, matchStartLine, allLines, config.ContextLines)
-		_, event.IsCellTrace = isLineMatch(buffer[0])
+		_, event.IsCellTrace = h.isLineMatch(buffer[0])
+		event.Offset = matchOffset
 		events = append(events, event)
 	}
 
-	offset, _ := file.Seek(0, io.SeekCurrent)
-	saveOffset(filePath, offset)
-	return events, nil
+	return events, offset, nil
 }
 
 // 兼容性函数
@@ -94,9 +183,12 @@ func readFromFile(filePath string) ([]LogEvent, error) {
 func toLogEventWithContext(lines []string, lineNumbers []int, filePath string, startLine int, allLines []string, contextLines int) LogEvent {
 	host, _ := os.Hostname()
 	text := strings.Join(lines, "\n")
-	tags := extractTags(lines)
-	score := calculateSeverityScore(lines, tags)
+	h := heuristicsFor(filePath)
+	tags := h.extractTags(lines)
+	score := h.calculateSeverityScore(lines, tags)
 	eventID := ExtractEventID(lines)
+	traceID := ExtractTraceID(lines)
+	templateID := ExtractTemplateID(lines)
 
 	// 提取上下文行
 	contextBefore, contextAfter := extractContext(allLines, startLine-1, contextLines)
@@ -109,6 +201,7 @@ func toLogEventWithContext(lines []string, lineNumbers []int, filePath string, s
 		RawLines:      lines,
 		RawText:       text,
 		Timestamp:     time.Now().Format(time.RFC3339),
+		LogTime:       ExtractLogTime(lines, time.Now()),
 		Host:          host,
 		Tags:          tags,
 		SeverityScore: score,
@@ -117,6 +210,8 @@ func toLogEventWithContext(lines []string, lineNumbers []int, filePath string, s
 		LineNumber:    startLine,
 		ContextLines:  contextLinesResult,
 		IsCellTrace:   false, // 将在调用处设置
+		TemplateID:    templateID,
+		TraceID:       traceID,
 	}
 }
 
@@ -126,12 +221,13 @@ func toLogEvent(lines []string) LogEvent {
 }
 
 // extractTags extracts tags from log lines
-func extractTags(lines []string) []string {
+func (h *Heuristics) extractTags(lines []string) []string {
 	tags := []string{}
 	for _, line := range lines {
-		for _, kw := range keywords {
-			if strings.Contains(strings.ToUpper(line), strings.ToUpper(kw)) {
-				tags = append(tags, kw)
+		upperLine := strings.ToUpper(line)
+		for i, kw := range h.upperKeywords {
+			if strings.Contains(upperLine, kw) {
+				tags = append(tags, h.Keywords[i])
 			}
 		}
 	}
@@ -139,14 +235,14 @@ func extractTags(lines []string) []string {
 }
 
 // calculateSeverityScore calculates the severity score for log events
-func calculateSeverityScore(lines []string, tags []string) int {
+func (h *Heuristics) calculateSeverityScore(lines []string, tags []string) int {
 	score := 0
 	maxScore := 0
 
 	// 基于标签计算分数
 	for _, tag := range tags {
 		upperTag := strings.ToUpper(tag)
-		if s, ok := severityMap[upperTag]; ok {
+		if s, ok := h.Severity[upperTag]; ok {
 			score += s
 			if s > maxScore {
 				maxScore = s
@@ -159,11 +255,8 @@ func calculateSeverityScore(lines []string, tags []string) int {
 	upperText := strings.ToUpper(text)
 
 	// Cell Trace异常额外加分
-	for _, pattern := range cellTracePatterns {
-		if pattern.MatchString(text) {
-			score += 3
-			break
-		}
+	if h.isCellTrace(text) {
+		score += 3
 	}
 
 	// 包含堆栈跟踪加分
@@ -244,24 +337,25 @@ func extractContext(allLines []string, centerIndex, contextLines int) ([]string,
 	return before, after
 }
 
-// ExtractEventID extracts TraceID or RequestID from log lines
-func ExtractEventID(lines []string) string {
+// ExtractTraceID 提取日志中 "TraceID:" 或 "RequestID:" 后的请求标识，没有时返回空
+func ExtractTraceID(lines []string) string {
 	for _, line := range lines {
-		if strings.Contains(line, "TraceID:") {
-			parts := strings.Split(line, "TraceID:")
-			if len(parts) > 1 {
-				id := strings.Fields(parts[1])[0]
-				return id
-			}
-		}
-		if strings.Contains(line, "RequestID:") {
-			parts := strings.Split(line, "RequestID:")
-			if len(parts) > 1 {
-				id := strings.Fields(parts[1])[0]
-				return id
+		for _, marker := range []string{"TraceID:", "RequestID:"} {
+			if _, rest, ok := strings.Cut(line, marker); ok {
+				if fields := strings.Fields(rest); len(fields) > 0 {
+					return fields[0]
+				}
 			}
 		}
 	}
+	return ""
+}
+
+// ExtractEventID extracts TraceID or RequestID from log lines
+func ExtractEventID(lines []string) string {
+	if id := ExtractTraceID(lines); id != "" {
+		return id
+	}
 
 	// 如果没有找到明确的ID，基于日志内容生成一个稳定的哈希ID
 	if len(lines) > 0 {
@@ -274,6 +368,26 @@ func ExtractEventID(lines []string) string {
 	return ""
 }
 
+// DocumentID 返回事件本次出现的稳定ID（事件ID加主机、文件和偏移），重复采集或重放同一事件时ID不变，
+// 存储时作为文档ID去重；不是从日志文件采集的事件返回空
+func (e LogEvent) DocumentID() string {
+	if e.FilePath == "" {
+		return ""
+	}
+	hash := md5.Sum([]byte(fmt.Sprintf("%s|%s|%s|%d", e.EventID, e.Host, e.FilePath, e.Offset)))
+	return hex.EncodeToString(hash[:])
+}
+
+// ExtractTemplateID 提取日志模板ID
+// 与EventID不同，模板ID不受TraceID/RequestID影响，同一类日志始终得到相同的ID
+func ExtractTemplateID(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	content := removeTimestamps(strings.Join(lines, "\n"))
+	return generateStableID(content)[:16]
+}
+
 // removeTimestamps removes timestamps from log content
 func removeTimestamps(content string) string {
 	// 移除常见的时间戳格式
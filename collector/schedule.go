@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 日志文件的采集时间表：固定间隔，或 cron 表达式（分 时 日 月 周）
+type Schedule struct {
+	Interval time.Duration
+	cron     *cronExpr
+}
+
+// ParseSchedule 解析采集时间表：时长（如 1s、5m）为固定间隔，五个字段时为 cron 表达式（如 "*/10 * * * *"）
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.Contains(spec, " ") {
+		c, err := parseCron(spec)
+		if err != nil {
+			return Schedule{}, err
+		}
+		return Schedule{cron: c}, nil
+	}
+	d, err := time.ParseDuration(spec)
+	if err != nil || d <= 0 {
+		return Schedule{}, fmt.Errorf("无效的采集时间表 %q，应为时长（如 5m）或 cron 表达式", spec)
+	}
+	return Schedule{Interval: d}, nil
+}
+
+// Next 返回 t 之后的下一次采集时间
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.cron != nil {
+		return s.cron.next(t)
+	}
+	return t.Add(s.Interval)
+}
+
+// fileSchedule 对匹配的日志文件生效的采集时间表
+type fileSchedule struct {
+	glob     string
+	schedule Schedule
+}
+
+// Scheduler 按各日志文件的采集时间表决定每一轮需要读取的文件
+type Scheduler struct {
+	files    []string
+	defaults Schedule
+	rules    []fileSchedule
+	next     map[string]time.Time
+
+	overrides map[string]Schedule // 按文件指定的采集时间表，优先于规则
+}
+
+// NewScheduler 创建采集调度：未匹配任何规则的文件每隔 interval 采集一次；
+// rules 为分号分隔的 通配符=时间表，如 "/var/log/kern.log=1s;/data/archive/*=5m;/var/log/batch.log=0 2 * * *"，第一条匹配的规则生效
+func NewScheduler(files []string, interval time.Duration, rules string) (*Scheduler, error) {
+	s := &Scheduler{files: files, defaults: Schedule{Interval: interval}, next: make(map[string]time.Time)}
+	for _, item := range strings.Split(rules, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		glob, spec, ok := strings.Cut(item, "=")
+		glob = strings.TrimSpace(glob)
+		if !ok || glob == "" {
+			return nil, fmt.Errorf("无效的采集时间表规则 %q，格式应为 通配符=时间表", item)
+		}
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("采集时间表规则的通配符 %q 无效: %w", glob, err)
+		}
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.rules = append(s.rules, fileSchedule{glob: glob, schedule: schedule})
+	}
+	return s, nil
+}
+
+// SetFiles 替换需要采集的文件，schedules 为按文件指定的采集时间表，优先于规则；用于采集目标动态变化，
+// 新增的文件按首次采集处理。不能与 Due 并发调用
+func (s *Scheduler) SetFiles(files []string, schedules map[string]Schedule) {
+	s.files = files
+	s.overrides = schedules
+	for path := range s.next {
+		if !slices.Contains(files, path) {
+			delete(s.next, path)
+		}
+	}
+}
+
+// scheduleFor 返回日志文件使用的采集时间表
+func (s *Scheduler) scheduleFor(path string) Schedule {
+	if schedule, ok := s.overrides[path]; ok {
+		return schedule
+	}
+	for _, r := range s.rules {
+		if ok, _ := filepath.Match(r.glob, path); ok {
+			return r.schedule
+		}
+	}
+	return s.defaults
+}
+
+// Tick 返回主循环的检查间隔：不超过1秒，固定间隔更短时取最短的间隔
+func (s *Scheduler) Tick() time.Duration {
+	tick := time.Second
+	for _, path := range s.files {
+		if d := s.scheduleFor(path).Interval; d > 0 && d < tick {
+			tick = d
+		}
+	}
+	return tick
+}
+
+// Due 返回到期需要采集的文件，并安排它们的下一次采集；按固定间隔采集的文件首次调用时即到期，
+// 按 cron 表达式采集的文件在下一个匹配的时间到期
+func (s *Scheduler) Due(now time.Time) []string {
+	var due []string
+	for _, path := range s.files {
+		schedule := s.scheduleFor(path)
+		next, ok := s.next[path]
+		if !ok {
+			next = now
+			if schedule.cron != nil {
+				next = schedule.Next(now)
+			}
+		}
+		if now.Before(next) {
+			s.next[path] = next
+			continue
+		}
+		due = append(due, path)
+		s.next[path] = schedule.Next(now)
+	}
+	return due
+}
+
+// cronExpr 解析后的 cron 表达式，每个字段为允许取值的集合
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // 日、周字段为 * 时只按另一个字段匹配
+}
+
+// cronFields cron 各字段的名称和取值范围
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"分", 0, 59},
+	{"时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"周", 0, 7},
+}
+
+// parseCron 解析五个字段的 cron 表达式，支持 *、列表（,）、范围（-）和步长（/），周的0和7都表示周日
+func parseCron(spec string) (*cronExpr, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("无效的 cron 表达式 %q，应为 分 时 日 月 周 五个字段", spec)
+	}
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseCronField(part, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 的%s字段无效: %w", spec, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronExpr{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: parts[2] == "*", dowAny: parts[4] == "*",
+	}, nil
+}
+
+// parseCronField 解析 cron 的一个字段，返回允许取值的位集合
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长 %q", stepPart)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			n, err := strconv.Atoi(from)
+			if err != nil {
+				return 0, fmt.Errorf("无效的取值 %q", item)
+			}
+			lo, hi = n, n
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("无效的取值 %q", item)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("取值 %q 超出范围 %d-%d", item, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// matchDay 判断日期是否匹配日、周字段：两者都有限定时满足其一即可
+func (c *cronExpr) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// next 返回 t 之后第一个匹配的整分钟，五年内没有匹配时返回五年后
+func (c *cronExpr) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"log-ai-analyzer/admin/adminpb"
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/crd"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/feature"
+	"log-ai-analyzer/grafana"
+	"log-ai-analyzer/kibana"
+	"log-ai-analyzer/metrics"
+	"log-ai-analyzer/report"
+	"log-ai-analyzer/sidecar"
+	"log-ai-analyzer/training"
+)
+
+// loadConfig 加载配置，子命令共用
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("加载配置失败: %w", err)
+	}
+	feature.Init(cfg.FeatureFlags)
+	return cfg, nil
+}
+
+// loadConfigAndES 加载配置并连接ES，子命令在ES不可用时直接失败
+func loadConfigAndES() (*config.Config, *esclient.ESClient, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	esClient, err := connectESClient(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("初始化ES客户端失败: %w", err)
+	}
+	return cfg, esClient, nil
+}
+
+// channelTestResult 告警渠道测试结果
+type channelTestResult struct {
+	Channel  string `json:"channel"`
+	Response string `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// configuredChannels 返回已配置的告警渠道及其webhook
+func configuredChannels(cfg *config.Config) map[string]string {
+	channels := make(map[string]string)
+	if webhook := cfg.Secret("AI_WECHAT_WEBHOOK"); webhook != "" {
+		channels["wechat"] = webhook
+	}
+	return channels
+}
+
+// testChannels 向指定渠道发送测试告警，channel为空时测试所有已配置的渠道
+func testChannels(cfg *config.Config, channel string) []channelTestResult {
+	channels := configuredChannels(cfg)
+
+	var names []string
+	if channel != "" {
+		names = []string{channel}
+	} else {
+		for name := range channels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	results := make([]channelTestResult, 0, len(names))
+	for _, name := range names {
+		resp, err := alert.TestFire(name, channels[name])
+		result := channelTestResult{Channel: name, Response: resp}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// newAlertCommand 创建 `logai alert` 命令组
+func newAlertCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alert",
+		Short: "告警相关操作",
+	}
+	cmd.AddCommand(newAlertTestCommand())
+	return cmd
+}
+
+// newAlertTestCommand 创建 `logai alert test` 命令
+func newAlertTestCommand() *cobra.Command {
+	var channel string
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "通过告警渠道发送一条测试告警",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			alert.SetInstance(cfg.InstanceID, cfg.InstanceLabels)
+
+			results := testChannels(cfg, channel)
+			if len(results) == 0 {
+				return errors.New("没有已配置的告警渠道")
+			}
+
+			failed := 0
+			for _, r := range results {
+				if r.Error != "" {
+					fmt.Printf("❌ %s: %s\n", r.Channel, r.Error)
+					failed++
+				} else {
+					fmt.Printf("✅ %s: 发送成功\n", r.Channel)
+				}
+				if r.Response != "" {
+					fmt.Printf("   响应: %s\n", r.Response)
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d 个告警渠道发送失败", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&channel, "channel", "", "要测试的告警渠道（如 wechat），为空时测试所有已配置的渠道")
+	return cmd
+}
+
+// alertTestHandler 提供 POST /api/alert/test?channel=wechat 接口
+func alertTestHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(testChannels(cfg, r.URL.Query().Get("channel")))
+	}
+}
+
+// newConfigCommand 创建 `logai config` 命令
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "配置相关操作",
+	}
+	cmd.AddCommand(newConfigPrintCommand())
+	return cmd
+}
+
+// newConfigPrintCommand 创建 `logai config print` 命令：输出合并默认值、配置文件、环境变量和命令行参数后
+// 实际生效的配置，密钥类配置项和地址中的密码隐去
+func newConfigPrintCommand() *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "print",
+		Short: "输出实际生效的配置，密钥隐去",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			fields := cfg.Redacted()
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(fields)
+			}
+			width := 0
+			for _, f := range fields {
+				width = max(width, len(f.Name))
+			}
+			for _, f := range fields {
+				fmt.Printf("%-*s  %s\n", width, f.Name, f.Value)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "以JSON格式输出")
+	return cmd
+}
+
+// newReportCommand 创建 `logai report` 命令
+func newReportCommand() *cobra.Command {
+	var period time.Duration
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "立即生成并发送最近一段时间的汇总报告",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, esClient, err := loadConfigAndES()
+			if err != nil {
+				return err
+			}
+			setAlertDelivery(cfg)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			defer cancel()
+			to := time.Now()
+			r, err := report.NewGenerator(cfg, esClient).Generate(ctx, to.Add(-period), to)
+			if r != nil {
+				fmt.Println(r.Summary)
+			}
+			if err != nil {
+				return fmt.Errorf("生成汇总报告失败: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&period, "period", 24*time.Hour, "汇总的时间范围，截止到当前时间")
+	return cmd
+}
+
+// newSidecarCommand 创建 `logai sidecar` 命令：只运行AI分析服务，不采集日志
+func newSidecarCommand() *cobra.Command {
+	var listen string
+	cmd := &cobra.Command{
+		Use:   "sidecar",
+		Short: "以 gRPC AI sidecar 模式运行，供其他 agent 委托AI调用",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if listen != "" {
+				cfg.SidecarListen = listen
+			}
+			if strings.ToLower(cfg.AIProvider) == "grpc" {
+				return errors.New("sidecar 模式下 AI_PROVIDER 不能为 grpc")
+			}
+			configureAI(cfg)
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if err := ai.StartHealthCheck(ctx, cfg, cfg.AIHealthInterval); err != nil {
+				return fmt.Errorf("启动AI健康检查失败: %w", err)
+			}
+
+			go func() {
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", metrics.Handler(cfg.InstanceID, cfg.InstanceLabels))
+				probe := &healthProbe{requireAI: true}
+				mux.Handle("/healthz", healthzHandler(probe))
+				mux.Handle("/readyz", readyzHandler(probe))
+				if err := serveAdmin(cfg, mux); err != nil {
+					log.Printf("Failed to start metrics server: %v", err)
+				}
+			}()
+
+			if err := sidecar.Serve(ctx, cfg); err != nil {
+				return fmt.Errorf("AI sidecar 服务异常退出: %w", err)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&listen, "listen", "", "gRPC 监听地址，默认使用 SIDECAR_LISTEN")
+	return cmd
+}
+
+// newTrendCommand 创建 `logai trend` 命令：查询日志模板或标签的出现次数，与一周前同一时间段比较
+func newTrendCommand() *cobra.Command {
+	var template, tag, tenant, spec string
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   "trend",
+		Short: "查询日志模板或标签的出现次数及一周前同期对比",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if template == "" && tag == "" {
+				return errors.New("需要指定 --template 或 --tag")
+			}
+			windows, err := parseTrendWindows(spec)
+			if err != nil {
+				return err
+			}
+			_, esClient, err := loadConfigAndES()
+			if err != nil {
+				return err
+			}
+
+			query := esclient.TrendQuery{TemplateID: template, Tag: tag}
+			if tenant != "" {
+				query.Tenants = []string{tenant}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			trend, err := esClient.OccurrenceTrend(ctx, query, windows, time.Now())
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(trend)
+			}
+			fmt.Print(formatTrend(trend))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&template, "template", "", "日志模板ID")
+	cmd.Flags().StringVar(&tag, "tag", "", "事件标签")
+	cmd.Flags().StringVar(&tenant, "tenant", "", "只统计该租户的事件")
+	cmd.Flags().StringVar(&spec, "windows", defaultTrendWindows, "逗号分隔的时间窗口，截止到当前时间")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "以JSON格式输出")
+	return cmd
+}
+
+// newExportCommand 创建 `logai export` 命令：将运维人员认可或更正过的分析导出为微调训练数据
+func newExportCommand() *cobra.Command {
+	var since time.Duration
+	var output string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "导出已标注事件作为微调训练数据（JSONL）",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, esClient, err := loadConfigAndES()
+			if err != nil {
+				return err
+			}
+
+			out := os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return fmt.Errorf("创建输出文件失败: %w", err)
+				}
+				defer f.Close()
+				out = f
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			stats, err := training.NewExporter(cfg, esClient).Export(ctx, out, time.Now().Add(-since))
+			if err != nil {
+				return fmt.Errorf("导出训练数据失败: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "✅ 导出 %d 条训练样本（AI分析 %d 条，运维更正 %d 条），跳过 %d 条无可用答案的事件\n",
+				stats.Total(), stats.Helpful, stats.Corrected, stats.Skipped)
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&since, "since", 30*24*time.Hour, "导出的时间范围，截止到当前时间")
+	cmd.Flags().StringVar(&output, "output", "", "输出文件路径，为空时输出到标准输出")
+	return cmd
+}
+
+// provisionKibana 按ES配置在Kibana中创建事件索引模式、已保存的搜索和仪表板，返回创建的对象数
+func provisionKibana(ctx context.Context, cfg *config.Config) (int, error) {
+	client, err := kibana.NewClient(kibana.Options{URL: cfg.KibanaURL, APIKey: cfg.KibanaAPIKey, Space: cfg.KibanaSpace})
+	if err != nil {
+		return 0, err
+	}
+	target := kibana.Target{EventIndex: cfg.ESIndexAlias}
+	if target.EventIndex == "" {
+		target.EventIndex = cfg.ESIndex + "-*"
+	}
+	if cfg.ESAlertStore != "events" {
+		target.AlertIndex = cfg.ESAlertIndex
+	}
+	return client.Provision(ctx, target)
+}
+
+// newProvisionCommand 创建 `logai provision` 命令，可重复执行，已存在的对象覆盖为最新定义；
+// 保留 provision-kibana 作为别名。Kibana 地址使用全局参数 --kibana-url 或 KIBANA_URL
+func newProvisionCommand() *cobra.Command {
+	var space string
+	cmd := &cobra.Command{
+		Use:     "provision",
+		Aliases: []string{"provision-kibana"},
+		Short:   "在Kibana中创建索引模式、已保存的搜索和事件概览仪表板",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if space != "" {
+				cfg.KibanaSpace = space
+			}
+			if cfg.KibanaURL == "" {
+				return errors.New("未配置 Kibana 地址，请设置 KIBANA_URL 或使用 --kibana-url")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			n, err := provisionKibana(ctx, cfg)
+			if err != nil {
+				return fmt.Errorf("创建Kibana对象失败: %w", err)
+			}
+			fmt.Printf("✅ 已在Kibana中创建 %d 个对象，打开仪表板「LogAI 事件概览」查看\n", n)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&space, "space", "", "导入到的 Kibana 空间，默认使用 KIBANA_SPACE")
+	return cmd
+}
+
+// newProvisionGrafanaCommand 创建 `logai provision-grafana` 命令：生成基于导出指标的 Grafana 监控仪表板，
+// 配置了 Grafana 地址时通过接口创建（可重复执行，覆盖为最新定义），否则或指定 --output 时输出仪表板 JSON 用于手工导入
+func newProvisionGrafanaCommand() *cobra.Command {
+	var output, folder, datasource string
+	cmd := &cobra.Command{
+		Use:   "provision-grafana",
+		Short: "生成或在Grafana中创建事件、AI分析、ES写入和告警指标的监控仪表板",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if folder != "" {
+				cfg.GrafanaFolder = folder
+			}
+			if datasource != "" {
+				cfg.GrafanaDatasource = datasource
+			}
+
+			if output != "" || cfg.GrafanaURL == "" {
+				data, err := json.MarshalIndent(grafana.Dashboard(cfg.GrafanaDatasource), "", "  ")
+				if err != nil {
+					return fmt.Errorf("编码Grafana仪表板失败: %w", err)
+				}
+				data = append(data, '\n')
+				if output == "" || output == "-" {
+					_, err = os.Stdout.Write(data)
+					return err
+				}
+				if err := os.WriteFile(output, data, 0o644); err != nil {
+					return fmt.Errorf("写入仪表板文件失败: %w", err)
+				}
+				fmt.Fprintf(os.Stderr, "✅ 仪表板已写入 %s，可在 Grafana 的 Dashboards → Import 中导入\n", output)
+				return nil
+			}
+
+			client, err := grafana.NewClient(grafana.Options{
+				URL:        cfg.GrafanaURL,
+				Token:      cfg.GrafanaToken,
+				FolderUID:  cfg.GrafanaFolder,
+				Datasource: cfg.GrafanaDatasource,
+			})
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			link, err := client.Provision(ctx)
+			if err != nil {
+				return fmt.Errorf("创建Grafana仪表板失败: %w", err)
+			}
+			fmt.Printf("✅ 已在Grafana中创建仪表板「%s」: %s\n", grafana.DashboardTitle, link)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "", "将仪表板 JSON 写入文件（- 表示标准输出），不通过接口创建")
+	cmd.Flags().StringVar(&folder, "folder", "", "创建到的 Grafana 文件夹UID，默认使用 GRAFANA_FOLDER")
+	cmd.Flags().StringVar(&datasource, "datasource", "", "默认选中的 Prometheus 数据源名称，默认使用 GRAFANA_DATASOURCE")
+	return cmd
+}
+
+// newProvisionCRDCommand 创建 `logai provision-crd` 命令：输出 LogTarget、AlertRoute、Silence 的自定义资源定义
+// 和监听所需的 RBAC 清单，可直接 kubectl apply 或提交到 GitOps 仓库
+func newProvisionCRDCommand() *cobra.Command {
+	var output, namespace, serviceAccount string
+	cmd := &cobra.Command{
+		Use:   "provision-crd",
+		Short: "生成采集目标、告警路由和静默的 Kubernetes 自定义资源定义及权限清单",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := json.MarshalIndent(crd.Manifests(namespace, serviceAccount), "", "  ")
+			if err != nil {
+				return fmt.Errorf("编码 Kubernetes 清单失败: %w", err)
+			}
+			data = append(data, '\n')
+			if output == "" || output == "-" {
+				_, err = os.Stdout.Write(data)
+				return err
+			}
+			if err := os.WriteFile(output, data, 0o644); err != nil {
+				return fmt.Errorf("写入清单文件失败: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "✅ 清单已写入 %s，可使用 kubectl apply -f %s 安装\n", output, output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&output, "output", "", "将清单写入文件（- 表示标准输出）")
+	cmd.Flags().StringVar(&namespace, "namespace", "default", "分析器 ServiceAccount 所在的命名空间")
+	cmd.Flags().StringVar(&serviceAccount, "service-account", "log-ai-analyzer", "授予监听权限的 ServiceAccount")
+	return cmd
+}
+
+// newMigrateCommand 创建 `logai migrate` 命令：升级后新增了字段或修改了映射时，按当前模板重建包含旧版本文档的事件索引，
+// 使 Kibana 查询和聚合在新旧索引上一致；仍在写入的索引等滚动后再迁移
+func newMigrateCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "按当前的字段映射重建旧版本的事件索引",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.ESDataStream {
+				return errors.New("数据流模式下不支持迁移，新的映射在后备索引滚动后生效")
+			}
+			esClient, err := connectESClient(cfg)
+			if err != nil {
+				return fmt.Errorf("初始化ES客户端失败: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			outdated, err := esClient.OutdatedIndices(ctx, time.Now())
+			if err != nil {
+				return fmt.Errorf("查询需要迁移的索引失败: %w", err)
+			}
+			if len(outdated) == 0 {
+				fmt.Printf("✅ 所有事件索引都已是当前版本（schema_version %d）\n", esclient.SchemaVersion)
+				return nil
+			}
+			indices := make([]string, 0, len(outdated))
+			for index := range outdated {
+				indices = append(indices, index)
+			}
+			sort.Strings(indices)
+			for _, index := range indices {
+				fmt.Printf("%s: %d 个旧版本文档\n", index, outdated[index])
+			}
+			if dryRun {
+				return nil
+			}
+
+			// 先安装当前的索引模板，重建的索引按新的映射创建
+			setupES(cfg, esClient)
+			failed := 0
+			for _, index := range indices {
+				start := time.Now()
+				n, err := esClient.MigrateIndex(ctx, index)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "❌ 迁移索引 %s 失败，可重新执行继续迁移: %v\n", index, err)
+					failed++
+					if ctx.Err() != nil {
+						break
+					}
+					continue
+				}
+				fmt.Printf("✅ %s 已迁移到 schema_version %d，%d 个文档，耗时 %s\n", index, esclient.SchemaVersion, n, time.Since(start).Round(time.Second))
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d 个索引迁移失败", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "只列出需要迁移的索引，不执行迁移")
+	return cmd
+}
+
+// adminFlags `logai admin` 子命令共用的连接参数
+type adminFlags struct {
+	addr    string
+	caFile  string
+	timeout time.Duration
+}
+
+// call 连接运行中实例的 gRPC 管理服务并调用 fn；地址默认使用 ADMIN_GRPC_LISTEN，令牌使用 ADMIN_GRPC_TOKEN
+func (f *adminFlags) call(fn func(ctx context.Context, client adminpb.AdminServiceClient) error) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	addr := f.addr
+	if addr == "" {
+		if cfg.AdminGRPCListen == "" {
+			return errors.New("请通过 --addr 指定管理服务地址或配置 ADMIN_GRPC_LISTEN")
+		}
+		addr = cfg.AdminGRPCListen
+		if strings.HasPrefix(addr, ":") {
+			addr = "localhost" + addr
+		}
+		if cfg.AdminGRPCTLSCertFile != "" {
+			addr = "grpcs://" + addr
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if target, ok := strings.CutPrefix(addr, "grpcs://"); ok {
+		addr = target
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if f.caFile != "" {
+			pem, err := os.ReadFile(f.caFile)
+			if err != nil {
+				return fmt.Errorf("读取CA证书失败: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return fmt.Errorf("CA证书 %s 中没有有效的证书", f.caFile)
+			}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return fmt.Errorf("连接管理服务失败: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	if token := cfg.Secret("ADMIN_GRPC_TOKEN"); token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	return fn(ctx, adminpb.NewAdminServiceClient(conn))
+}
+
+// newAdminCommand 创建 `logai admin` 命令：通过 gRPC 管理服务控制运行中的实例
+func newAdminCommand() *cobra.Command {
+	flags := &adminFlags{}
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "通过 gRPC 管理服务控制运行中的实例",
+	}
+	cmd.PersistentFlags().StringVar(&flags.addr, "addr", "", "管理服务地址 host:port，TLS 使用 grpcs://host:port，默认使用 ADMIN_GRPC_LISTEN")
+	cmd.PersistentFlags().StringVar(&flags.caFile, "ca-file", "", "校验服务端证书的CA证书（PEM），为空时使用系统证书")
+	cmd.PersistentFlags().DurationVar(&flags.timeout, "timeout", 30*time.Second, "请求超时时间")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "status",
+			Short: "查看实例的运行状态",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return flags.call(func(ctx context.Context, client adminpb.AdminServiceClient) error {
+					resp, err := client.Status(ctx, &adminpb.StatusRequest{})
+					if err != nil {
+						return err
+					}
+					out, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(resp)
+					if err != nil {
+						return err
+					}
+					fmt.Println(string(out))
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "reload",
+			Short: "重新加载识别规则、抑制规则和密钥引用",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return flags.call(func(ctx context.Context, client adminpb.AdminServiceClient) error {
+					resp, err := client.ReloadConfig(ctx, &adminpb.ReloadConfigRequest{})
+					if err != nil {
+						return err
+					}
+					fmt.Printf("已重新加载: %s\n", strings.Join(resp.GetReloaded(), ", "))
+					if changed := resp.GetChangedSecrets(); len(changed) > 0 {
+						fmt.Printf("已更新的密钥: %s\n", strings.Join(changed, ", "))
+					}
+					return nil
+				})
+			},
+		},
+		newAdminSilenceCommand(flags),
+		&cobra.Command{
+			Use:   "replay",
+			Short: "立即重放ES溢出队列",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return flags.call(func(ctx context.Context, client adminpb.AdminServiceClient) error {
+					resp, err := client.TriggerReplay(ctx, &adminpb.TriggerReplayRequest{})
+					if err != nil {
+						return err
+					}
+					fmt.Printf("已重放 %d 个文档\n", resp.GetDocuments())
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "flush",
+			Short: "立即提交各存储后端缓存的数据",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return flags.call(func(ctx context.Context, client adminpb.AdminServiceClient) error {
+					if _, err := client.FlushQueues(ctx, &adminpb.FlushQueuesRequest{}); err != nil {
+						return err
+					}
+					fmt.Println("已提交存储队列")
+					return nil
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+// newAdminSilenceCommand 创建 `logai admin silence <template_id>` 命令
+func newAdminSilenceCommand(flags *adminFlags) *cobra.Command {
+	var reason, sample string
+	cmd := &cobra.Command{
+		Use:   "silence <template_id>",
+		Short: "静默日志模板，事件照常分析和存储但不发送告警",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return flags.call(func(ctx context.Context, client adminpb.AdminServiceClient) error {
+				resp, err := client.CreateSilence(ctx, &adminpb.CreateSilenceRequest{TemplateId: args[0], Reason: reason, Sample: sample})
+				if err != nil {
+					return err
+				}
+				if resp.GetCreated() {
+					fmt.Printf("已静默日志模板 %s\n", args[0])
+				} else {
+					fmt.Printf("日志模板 %s 已被静默\n", args[0])
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "静默原因")
+	cmd.Flags().StringVar(&sample, "sample", "", "日志样例，便于之后识别规则")
+	return cmd
+}
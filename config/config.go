@@ -2,47 +2,339 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"log-ai-analyzer/feature"
 )
 
+// ModelParams 模型请求参数，主后端、备用后端和分级后端分别配置
+type ModelParams struct {
+	Temperature float64       // 采样温度，生产环境可设为0等较低的值以获得确定性的分析结果
+	TopP        float64       // 核采样概率，0表示使用模型默认值
+	MaxTokens   int           // 最大输出token数，0表示使用模型默认值
+	Timeout     time.Duration // 单次请求超时，0表示使用后端默认值
+	Stream      bool          // 是否以流式方式请求
+}
+
 type Config struct {
-	LogFiles       []string
-	AIAPIURL       string
-	AIAPIKey       string
-	AIModel        string
-	AIEnable       string
-	WeChatWebhook  string
-	ESNodes        []string
-	ESIndex        string
-	MaxWorkers     int           // 工作池大小
-	AlertTTL       time.Duration // 告警缓存TTL
-	METRICS_PORT   string
-	LogLevel       string        // 日志级别
-	EnableCellTrace bool         // 是否启用Cell Trace检测
-	EnableAlert    bool          // 是否启用告警功能
-	EnableES       bool          // 是否启用ES存储功能
+	LogFiles           []string
+	AIAPIURL           string
+	AIAPIKey           string
+	AIModel            string
+	AIEnable           string
+	AIProvider         string        // AI后端: openai（默认，兼容OpenAI接口的服务）、ollama、grpc（委托给AI sidecar）
+	AISystemPromptFile string        // 系统提示词模板文件（text/template），修改后自动生效
+	AIUserPromptFile   string        // 用户提示词模板文件（text/template），修改后自动生效
+	AIPromptRoutesFile string        // 提示词路由表文件（JSON），按标签/模板ID/文件路径选择专用提示词
+	AIOfflineRulesFile string        // 离线分析规则文件（JSON），AI未启用或不可用时按规则给出已知原因和处理建议
+	AIBatchWindow      time.Duration // 同一主机同一分钟内的事件合并分析的等待时间，0表示不合并
+	AIBatchMaxSize     int           // 单次合并分析的最多事件数
+	AIModelParams      ModelParams   // 主后端的模型请求参数
+
+	// 备用AI后端，主后端不可用或额度耗尽时自动切换，如云端服务 -> 本地 Ollama
+	AIFallbackProvider string
+	AIFallbackAPIURL   string
+	AIFallbackAPIKey   string
+	AIFallbackModel    string
+	AIFallbackParams   ModelParams // 未单独配置的参数沿用主后端
+
+	// 两阶段分析：先用廉价模型或规则分级，严重程度达到阈值的事件才进行深度分析
+	AITriageProvider  string // 分级后端: rules（按规则评分）、openai、ollama
+	AITriageAPIURL    string
+	AITriageAPIKey    string
+	AITriageModel     string
+	AITriageParams    ModelParams // 未单独配置的参数沿用主后端
+	AITriageThreshold int         // 深度分析的最低严重程度（1-10），0表示不分级
+
+	AIHistoryLimit   int // 分析前从ES检索的相似历史事件数量，0表示不检索
+	AIMaxInputTokens int // 单个事件发送给模型的最大token数，超出时按错误行、根因、末尾裁剪，0表示不裁剪
+
+	AIResponseValidation bool // 要求模型输出结构化结论并校验，失败时重试一次，仍失败则降级为规则摘要
+
+	AITools         bool // 允许模型通过工具调用查询事件之前的日志、同主机近期事件和模板历史次数
+	AIToolMaxRounds int  // 单次分析最多进行的工具调用轮数
+
+	AIHealthInterval time.Duration // AI后端健康探测间隔，0表示不探测
+
+	// AI调用的代理与TLS配置
+	AIProxyURL       string // 代理地址，如 http://proxy.corp:3128，为空时使用 HTTP_PROXY/HTTPS_PROXY 环境变量
+	AICAFile         string // 私有CA证书（PEM），在系统根证书基础上追加
+	AIClientCertFile string // 客户端证书（PEM），用于双向TLS
+	AIClientKeyFile  string // 客户端私钥（PEM）
+
+	// AI sidecar 服务端配置（`logai sidecar`），agent 以 AI_PROVIDER=grpc 接入
+	SidecarListen      string // 监听地址
+	SidecarToken       string // agent 访问令牌，对应 agent 的 AI_API_KEY，为空时不校验
+	SidecarTLSCertFile string // 服务端证书（PEM），为空时不启用TLS
+	SidecarTLSKeyFile  string // 服务端私钥（PEM）
+
+	AIOutputLanguage   string            // AI分析结果的输出语言，如 English，为空时按提示词默认（中文）
+	AIChannelLanguages map[string]string // 各渠道单独配置的输出语言: wechat、email、ticket
+
+	// AI限流配置，0表示不限制
+	AIMaxInFlight    int           // 最大并发请求数
+	AIRequestsPerMin int           // 每分钟最大请求数
+	AIQueueTimeout   time.Duration // 排队等待超时时间
+
+	// AI预算配置，0表示不限制
+	AIDailyTokenLimit      int64
+	AIMonthlyTokenLimit    int64
+	AIDailyCostLimit       float64
+	AIMonthlyCostLimit     float64
+	AIPromptPricePer1K     float64 // 每千个输入token的价格
+	AICompletionPricePer1K float64 // 每千个输出token的价格
+	WeChatWebhook          string
+	ESNodes                []string
+	ESIndex                string
+	ESIndexTemplate        bool          // 启动时安装事件索引模板（显式字段映射）
+	ESIndexPattern         string        // 事件索引名称模板，支持 {prefix}、{date}、{host}、{tenant}
+	ESIndexGranularity     string        // 索引滚动粒度: daily、weekly、monthly
+	ESTenant               string        // 事件没有识别出租户时索引名称模板中 {tenant} 的取值
+	TenantRulesFile        string        // 租户规则文件（JSON），按主机、文件、标签和内容识别事件的租户并按租户路由存储
+	ESIndexAlias           string        // 查询事件使用的索引别名，为空时查询 <ES_INDEX>-*
+	ESDataStream           bool          // 写入数据流 <ES_INDEX>-events 而不是按名称模板生成的索引
+	ESWriteMode            string        // 同一事件重复写入时的处理: create（保留已有文档）、upsert（覆盖）
+	ESHotMinSeverity       int           // 严重性不低于该值的事件写入 <ES_INDEX>-hot-* 索引，0表示不启用
+	ESHotRetentionDays     int           // hot 索引的保留天数，0表示使用 ES_RETENTION_DAYS
+	ESNoiseMaxSeverity     int           // 严重性不超过该值的事件写入 <ES_INDEX>-noise-* 索引，0表示不启用
+	ESNoiseRetentionDays   int           // noise 索引的保留天数，0表示使用 ES_RETENTION_DAYS
+	ESRetentionDays        int           // 事件索引保留天数，0表示永久保留
+	ESRetentionMode        string        // 过期索引的清理方式: auto（优先ILM，不支持时定期删除）、ilm、delete
+	ESBulkActions          int           // ES批量写入每批最多的文档数，0表示逐条同步写入
+	ESBulkBytes            int           // ES批量写入每批最大的请求字节数
+	ESBulkFlushInterval    time.Duration // ES批量写入的最长等待时间
+	ESBulkWorkers          int           // ES批量写入并发提交的批次数
+	ESAlertStore           string        // ES中存储的内容: events（每个事件一个文档）、alerts（每个告警一个聚合文档）、both
+	ESAlertIndex           string        // 告警聚合文档写入的索引
+	PGDSN                  string        // PostgreSQL连接串，为空表示不写入PostgreSQL
+	PGMaxConns             int           // PostgreSQL最大连接数
+	OutputFile             string        // NDJSON输出文件，"-" 表示标准输出，为空表示不输出
+	OutputFileAlerts       bool          // NDJSON输出中同时包含合并告警
+	KafkaBrokers           []string      // Kafka broker 地址，为空表示不发布到Kafka
+	KafkaTopic             string        // 事件发布的主题
+	KafkaAlertTopic        string        // 合并告警发布的主题，为空表示不发布告警
+	KafkaTLS               bool          // 连接Kafka时使用TLS
+	KafkaSASLMechanism     string        // SASL认证方式: plain、scram-sha-256、scram-sha-512
+	KafkaUsername          string
+	KafkaPassword          string
+	VictoriaLogsURL        string // VictoriaLogs 地址，为空表示不写入
+	VictoriaLogsAccountID  string // VictoriaLogs 多租户的租户ID
+	VictoriaLogsProjectID  string
+	ESBreakerFailures      int           // ES连续写入失败多少次后熔断，0表示不熔断
+	ESBreakerCooldown      time.Duration // ES写入熔断后的冷却时间
+	ESSpillDir             string        // ES不可用时缓存文档的溢出队列目录，为空表示不缓存
+	ESSpillMaxBytes        int64         // 溢出队列最多占用的磁盘字节数
+	ESCompress             bool          // 使用gzip压缩ES请求体
+	ESRequestTimeout       time.Duration // 单个ES请求（含重试）的超时时间，0表示不限制
+	ESMaxIdleConns         int           // 每个ES节点保持的空闲长连接数
+	ESIdleConnTimeout      time.Duration // ES空闲长连接的保持时间
+	MaxWorkers             int           // 工作池大小，启用伸缩时为最大工作协程数
+	MinWorkers             int           // 工作池伸缩时的最小工作协程数，0表示不伸缩，固定为 MaxWorkers
+	WorkerScaleInterval    time.Duration // 工作池按积压调整工作协程数的检查间隔
+	EventQueueSize         int           // 优先级队列最多缓存的事件数
+	PriorityAging          time.Duration // 事件每等待该时长相当于严重性加1，防止低严重性事件饿死，0表示按到达顺序处理
+	MaxBacklogEvents       int           // 已采集未处理的事件达到该数量时暂停采集，0表示不暂停
+	FlowMaxInflightBytes   int64         // 已分发未处理完的事件估算占用的内存达到该字节数时暂停采集，0表示不限制
+	CollectInterval        time.Duration // 日志文件的默认采集间隔
+	CollectSchedule        string        // 按文件的采集时间表，分号分隔的 通配符=时长或cron表达式，如 "/data/archive/*=5m;/var/log/batch.log=0 2 * * *"
+	ContextLines           int           // 每个事件采集的上下文行数
+	EventChannelSize       int           // 采集端到优先级队列的事件通道容量
+	ShutdownGrace          time.Duration // 退出时停止采集后等待工作协程处理完已分发事件的最长时间
+	WALDir                 string        // 写前日志目录，为空表示不持久化采集到的事件
+	WALMaxBytes            int64         // 写前日志最多占用的磁盘字节数，0表示不限制
+	WALFullPolicy          string        // 写前日志写满时的处理策略: block、drop_oldest、drop_newest
+	EventQueueDir          string        // 磁盘事件队列目录，为空时采集端经内存通道将事件交给工作池
+	EventQueueMaxBytes     int64         // 磁盘事件队列最多占用的磁盘字节数，0表示不限制
+	AlertTTL               time.Duration // 告警缓存TTL
+	METRICS_PORT           string
+	LogLevel               string        // 日志级别
+	EnableCellTrace        bool          // 是否启用Cell Trace检测
+	HeuristicsFile         string        // 日志事件识别规则文件（YAML）：关键词、严重性评分、Cell Trace 模式、堆栈行特征，可按日志文件覆盖
+	EnableAlert            bool          // 是否启用告警功能
+	EnableES               bool          // 是否启用ES存储功能
+	AlertSeveritySchedule  string        // 按时间段生效的最低告警严重性，如 "Mon-Fri@09:00-18:00=5,18:00-09:00=8"
+	AlertProgressive       bool          // 渐进式告警：AI分析未及时完成时先发送规则摘要告警，分析完成后补发AI分析
+	AlertProgressiveDelay  time.Duration // 渐进式告警等待AI分析的最长时间
+	AlertResendHigh        time.Duration // 高严重性（>=8）告警前3次之后重复发送的间隔
+	AlertResendMedium      time.Duration // 中严重性（>=5）告警前2次之后重复发送的间隔
+	AlertResendLow         time.Duration // 低严重性告警每10次之外重复发送的间隔
+	AlertSimilarity        float64       // 内容相似度（0-100）不低于该值的事件合并为同一告警
+	AlertMaxContextLines   int           // 合并告警最多保留的上下文行数
+	AlertRetries           int           // 告警发送失败后的重试次数
+	AlertRetryBackoff      time.Duration // 告警第一次重试前的等待时间，之后每次加倍
+	AlertBreakerFailures   int           // 告警渠道连续发送失败多少次后熔断，0表示不熔断
+	AlertBreakerCooldown   time.Duration // 告警渠道熔断后的冷却时间
+
+	// 实验性功能开关，如 {"auto_suppression": false}，未配置的开关使用默认状态
+	FeatureFlags map[string]bool
+
+	// 实例配置：多个实例写入同一个ES或Prometheus时区分来源
+	InstanceID     string            // 实例标识，写入每个事件、告警和指标，默认为主机名
+	InstanceLabels map[string]string // 实例的静态标签，如 region=cn-east,env=prod，随实例标识一起写入
+
+	MetricsMaxLabelValues int // 指标的文件、主机标签各自最多记录的不同取值数，超过后归入 other
+	DebugErrorsSize       int // /debug/errors 保留的最近内部错误条数，0表示不记录
+
+	DropSummaryInterval time.Duration // 汇总输出丢弃事件警告日志的间隔
+
+	// 指标推送配置：无法被 Prometheus 抓取的实例主动推送指标
+	MetricsPushURL      string            // Pushgateway 或 remote_write 地址，为空时不推送
+	MetricsPushMode     string            // pushgateway 或 remote_write
+	MetricsPushInterval time.Duration     // 推送间隔
+	MetricsPushJob      string            // job 标签
+	MetricsPushLabels   map[string]string // 推送时附加的标签，Pushgateway 模式下作为分组标签
+	MetricsPushUsername string            // Basic 认证用户名
+	MetricsPushPassword string            // Basic 认证密码
+
+	// 指标和管理接口的访问控制：/metrics、/api/*、/debug/* 所在的 HTTP 服务
+	MetricsTLSCertFile  string   // 服务端证书（PEM），为空时不启用TLS
+	MetricsTLSKeyFile   string   // 服务端私钥（PEM）
+	MetricsAuthUsername string   // Basic 认证用户名，为空时不启用 Basic 认证
+	MetricsAuthPassword string   // Basic 认证密码
+	MetricsAuthToken    string   // Bearer 令牌，为空时不启用令牌认证
+	MetricsAllowCIDRs   []string // 允许访问的客户端地址（IP或CIDR），为空时不限制
+
+	// gRPC 管理服务：供自动化工具和 `logai admin` 命令控制运行中的实例
+	AdminGRPCListen      string // 监听地址，如 :9095，为空时不启动
+	AdminGRPCToken       string // 访问令牌，为空时不校验
+	AdminGRPCTLSCertFile string // 服务端证书（PEM），为空时不启用TLS
+	AdminGRPCTLSKeyFile  string // 服务端私钥（PEM）
+
+	// 多实例协调：多个实例采集同一批日志文件（如挂载同一个NFS目录）时，每个文件由一个实例认领后采集
+	Coordination          string        // etcd 或 kubernetes，为空表示不协调，每个实例采集所有文件
+	CoordinationURL       string        // etcd 的 HTTP 网关地址；kubernetes 时为 API Server 地址，为空时使用集群内配置
+	CoordinationPrefix    string        // etcd 键的前缀或 Lease 名称的前缀，默认 logai/coord/ 或 logai
+	CoordinationToken     string        // etcd 认证令牌或 Kubernetes 令牌，kubernetes 时为空使用 ServiceAccount 令牌
+	CoordinationNamespace string        // Lease 所在的命名空间，默认为 Pod 所在的命名空间
+	CoordinationLeaseTTL  time.Duration // 租约有效期，实例失联超过该时间后其文件由其他实例接管
+
+	// Kubernetes 自定义资源：采集目标（LogTarget）、告警路由（AlertRoute）和静默（Silence）由资源声明，变化后立即生效
+	CRDWatch     bool   // 是否监听自定义资源
+	CRDNamespace string // 监听的命名空间，默认为 Pod 所在的命名空间，* 表示所有命名空间
+	CRDAPIURL    string // API Server 地址，为空时使用集群内配置
+	CRDToken     string // 访问令牌，为空时使用 ServiceAccount 令牌
+
+	// 日志风暴配置
+	StormThreshold int           // 单个主机在窗口内的事件数超过该值时合并为一条风暴告警，0表示不启用
+	StormWindow    time.Duration // 风暴检测窗口
+
+	// 故障归并配置：同一主机或同一日志模板在窗口内持续出现的事件归为一个故障
+	IncidentWindow    time.Duration // 故障超过该时间没有新事件视为已恢复，0表示不归并
+	ESIncidentIndex   string        // 故障文档写入的索引
+	IncidentStateFile string        // 保存未恢复故障的状态文件，重启后恢复，为空表示不保存
+
+	// 请求关联配置：不同日志文件中 TraceID/RequestID 相同的事件互相关联
+	TraceWindow    time.Duration // 关联窗口，0表示不关联
+	TraceStateFile string        // 保存关联窗口内事件的状态文件，重启后恢复，为空表示不保存
+
+	SequenceRulesFile string // 序列规则文件（YAML），事件按顺序在规定时间内出现时生成复合告警
+	SLORulesFile      string // SLO 规则文件（YAML），按日志模板统计服务错误数，错误预算消耗过快时告警
+
+	// 速率异常检测配置（不依赖AI），按日志模板维护事件速率的 EWMA 基线
+	AnomalyThreshold   float64       // 超过 基线均值+阈值×标准差 时判定为异常，0表示不启用
+	AnomalyRatio       float64       // 还需超过基线均值的倍数，0表示不检查
+	AnomalyInterval    time.Duration // 统计周期
+	AnomalyAlpha       float64       // EWMA 平滑系数
+	AnomalyMinCount    int           // 周期内最少事件数
+	AnomalyWarmup      int           // 基线预热的统计周期数
+	AnomalySeasonality string        // 季节基线: daily、weekly，为空表示不启用
+	AnomalyStateFile   string        // 保存速率基线的状态文件，重启后恢复，为空表示不保存
+
+	// 工单配置
+	TicketSystem            string // 工单系统: jira、servicenow，为空表示不创建工单
+	TicketURL               string // 工单系统地址
+	TicketUser              string // 用户名（Jira Server 使用 PAT 时可为空）
+	TicketToken             string // API Token 或密码
+	TicketProject           string // Jira 项目Key
+	TicketIssueType         string // Jira 问题类型
+	TicketResolveTransition string // Jira 关闭工单的 transition ID
+	TicketMinSeverity       int    // 创建工单的最低严重性
+	KibanaURL               string // Kibana 地址，用于生成事件链接和创建仪表板
+	KibanaAPIKey            string // Kibana API Key，为空时使用地址中的 Basic 认证信息
+	KibanaSpace             string // 仪表板导入到的 Kibana 空间，为空时使用默认空间
+	KibanaProvision         bool   // 启动时在后台创建 Kibana 索引模式、已保存的搜索和仪表板
+	GrafanaURL              string // Grafana 地址，用于创建监控仪表板
+	GrafanaToken            string // Grafana 服务账号令牌，为空时使用地址中的 Basic 认证信息
+	GrafanaFolder           string // 仪表板创建到的 Grafana 文件夹UID，为空时使用 General 文件夹
+	GrafanaDatasource       string // 仪表板默认选中的 Prometheus 数据源名称，为空时使用默认数据源
+
+	// 值班配置
+	OnCallSource       string // 值班来源: rotation、pagerduty、opsgenie，为空表示不@值班人员
+	OnCallRotationFile string // 轮值文件路径（rotation）
+	OnCallToken        string // PagerDuty API Token 或 Opsgenie API Key
+	OnCallSchedule     string // PagerDuty 值班表ID 或 Opsgenie 值班表名称/ID
+
+	FeedbackBaseURL string // 本服务对外可访问的地址，用于在告警中生成AI分析评价链接
+
+	AlertTestAPI bool // 是否在指标端口提供 POST /api/alert/test 测试告警通道，默认关闭
+
+	// 运维手册配置
+	RunbookDir     string // 运维手册目录（Markdown），命中的手册摘录注入提示词并在告警中附带链接
+	RunbookBaseURL string // 手册未声明 url 时，链接为 RunbookBaseURL/文件名
+
+	// 汇总报告配置
+	ReportSchedule string   // 每天生成汇总报告的时间点，如 "08:00,20:00" 表示按两个班次汇总，为空表示不生成
+	ReportIndex    string   // 汇总报告写入的ES索引前缀
+	ReportTopN     int      // 报告中列出的主要问题数量
+	ReportEmailTo  []string // 汇总报告收件人
+
+	// 噪声抑制配置：持续出现、从未被处理、也从未出现在严重故障中的日志模板在汇总报告中建议抑制
+	NoiseSuppression     string // off（默认）、propose（只在报告中建议）、apply（自动写入抑制规则文件）
+	NoiseSuppressionFile string // 抑制规则文件，其中的日志模板不再发送告警
+	NoiseMinEvents       int    // 报告周期内事件数不少于该值的模板才判定为噪声
+
+	// 邮件配置
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// 密钥配置：密钥类配置项可以写成 file:/路径 或 vault:路径#字段 形式的引用
+	SecretRefreshInterval time.Duration // 重新解析密钥引用的间隔，用于密钥轮换，0表示只在启动时解析
+
+	// 集中配置：从 etcd 或 Consul 读取配置项和规则文件，规则文件变化时立即生效
+	ConfigSource       string // etcd、consul，为空表示不使用集中配置
+	ConfigSourceURL    string
+	ConfigSourcePrefix string // 键的前缀，默认 logai/
+	ConfigSourceToken  string // Consul ACL Token 或 etcd 认证令牌
+	ConfigSourceDir    string // files/ 下的规则文件同步到的本地目录
+
+	secrets *secretStore
 }
 
 // Load 加载配置
 func Load() (*Config, error) {
 	_ = godotenv.Load()
 
+	// 集中配置在本地配置之后生效，只补充本地未设置的配置项
+	remote := remoteOptionsFromEnv()
+	if err := loadRemoteConfig(remote); err != nil {
+		return nil, err
+	}
+
+	// 监听 Kubernetes 自定义资源时日志文件可以全部由 LogTarget 声明
 	logFilesEnv := os.Getenv("LOG_FILE_PATHS")
-	if logFilesEnv == "" {
+	crdWatch := strings.ToLower(os.Getenv("CRD_WATCH")) == "true"
+	if logFilesEnv == "" && !crdWatch {
 		return nil, fmt.Errorf("❌ 缺少必要环境变量 LOG_FILE_PATHS")
 	}
 
 	esNodes := strings.Split(os.Getenv("ES_NODES"), ",")
 	esIndex := os.Getenv("ES_INDEX")
-	if len(esNodes) == 0 || esIndex == "" {
+	// 未启用ES存储（如只使用PostgreSQL）时不要求ES配置
+	if esIndex == "" && strings.ToLower(os.Getenv("ENABLE_ES")) != "false" {
 		return nil, fmt.Errorf("❌ 缺少 Elasticsearch 配置: ES_NODES 或 ES_INDEX")
 	}
-	
+
 	// 验证ES节点URL格式
 	for i, node := range esNodes {
 		if node == "" {
@@ -59,25 +351,393 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		LogFiles:       strings.Split(logFilesEnv, ","),
-		AIAPIURL:       os.Getenv("AI_API_URL"),
-		AIAPIKey:       os.Getenv("AI_API_KEY"),
-		AIModel:        os.Getenv("AI_MODEL_NAME"),
-		AIEnable:       os.Getenv("AI_ENABLE"),
-		WeChatWebhook:  os.Getenv("AI_WECHAT_WEBHOOK"),
-		ESNodes:        esNodes,
-		ESIndex:        esIndex,
-		METRICS_PORT:   METRICS_PORT,
-		LogLevel:       "info", // 默认日志级别
-		EnableCellTrace: true,  // 默认启用Cell Trace检测
+		LogFiles:           strings.Split(logFilesEnv, ","),
+		AIAPIURL:           os.Getenv("AI_API_URL"),
+		AIAPIKey:           os.Getenv("AI_API_KEY"),
+		AIModel:            os.Getenv("AI_MODEL_NAME"),
+		AIEnable:           os.Getenv("AI_ENABLE"),
+		AIProvider:         strings.ToLower(os.Getenv("AI_PROVIDER")),
+		AISystemPromptFile: os.Getenv("AI_SYSTEM_PROMPT_FILE"),
+		AIUserPromptFile:   os.Getenv("AI_USER_PROMPT_FILE"),
+		AIPromptRoutesFile: os.Getenv("AI_PROMPT_ROUTES_FILE"),
+		AIOfflineRulesFile: os.Getenv("AI_OFFLINE_RULES_FILE"),
+		AIFallbackProvider: strings.ToLower(os.Getenv("AI_FALLBACK_PROVIDER")),
+		AIFallbackAPIURL:   os.Getenv("AI_FALLBACK_API_URL"),
+		AIFallbackAPIKey:   os.Getenv("AI_FALLBACK_API_KEY"),
+		AIFallbackModel:    os.Getenv("AI_FALLBACK_MODEL_NAME"),
+		AITriageProvider:   strings.ToLower(os.Getenv("AI_TRIAGE_PROVIDER")),
+		AITriageAPIURL:     os.Getenv("AI_TRIAGE_API_URL"),
+		AITriageAPIKey:     os.Getenv("AI_TRIAGE_API_KEY"),
+		AITriageModel:      os.Getenv("AI_TRIAGE_MODEL_NAME"),
+		AIOutputLanguage:   os.Getenv("AI_OUTPUT_LANGUAGE"),
+		AIProxyURL:         os.Getenv("AI_PROXY_URL"),
+		AICAFile:           os.Getenv("AI_CA_FILE"),
+		AIClientCertFile:   os.Getenv("AI_CLIENT_CERT_FILE"),
+		AIClientKeyFile:    os.Getenv("AI_CLIENT_KEY_FILE"),
+		SidecarListen:      os.Getenv("SIDECAR_LISTEN"),
+		SidecarToken:       os.Getenv("SIDECAR_TOKEN"),
+		SidecarTLSCertFile: os.Getenv("SIDECAR_TLS_CERT_FILE"),
+		SidecarTLSKeyFile:  os.Getenv("SIDECAR_TLS_KEY_FILE"),
+		WeChatWebhook:      os.Getenv("AI_WECHAT_WEBHOOK"),
+		ESNodes:            esNodes,
+		ESIndex:            esIndex,
+		METRICS_PORT:       METRICS_PORT,
+		LogLevel:           "info", // 默认日志级别
+		EnableCellTrace:    true,   // 默认启用Cell Trace检测
 	}
 
+	// 设置实例标识和静态标签，多个实例写入同一个ES或Prometheus时用于区分来源
+	cfg.InstanceID = os.Getenv("INSTANCE_ID")
+	if cfg.InstanceID == "" {
+		cfg.InstanceID, _ = os.Hostname()
+	}
+	cfg.InstanceLabels = parseLabels(os.Getenv("INSTANCE_LABELS"))
+
+	// 设置指标文件、主机标签的取值上限，防止日志文件或主机过多时指标序列无限增长
+	cfg.MetricsMaxLabelValues = 100
+	if v := os.Getenv("METRICS_MAX_LABEL_VALUES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MetricsMaxLabelValues = n
+		}
+	}
+
+	// 设置 /debug/errors 保留的最近内部错误条数
+	cfg.DebugErrorsSize = 100
+	if v := os.Getenv("DEBUG_ERRORS_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.DebugErrorsSize = n
+		}
+	}
+
+	// 设置丢弃事件的汇总间隔，期间有事件被丢弃时输出一条警告日志
+	cfg.DropSummaryInterval = parseDurationEnv("DROP_SUMMARY_INTERVAL", 5*time.Minute)
+
+	// 设置指标推送，短期运行或在防火墙后无法被抓取的实例主动推送指标
+	cfg.MetricsPushURL = os.Getenv("METRICS_PUSH_URL")
+	cfg.MetricsPushMode = strings.ToLower(os.Getenv("METRICS_PUSH_MODE"))
+	if cfg.MetricsPushMode == "" {
+		cfg.MetricsPushMode = "pushgateway"
+	}
+	cfg.MetricsPushInterval = parseDurationEnv("METRICS_PUSH_INTERVAL", 30*time.Second)
+	cfg.MetricsPushJob = os.Getenv("METRICS_PUSH_JOB")
+	if cfg.MetricsPushJob == "" {
+		cfg.MetricsPushJob = "log-ai-analyzer"
+	}
+	cfg.MetricsPushLabels = parseLabels(os.Getenv("METRICS_PUSH_LABELS"))
+	cfg.MetricsPushUsername = os.Getenv("METRICS_PUSH_USERNAME")
+	cfg.MetricsPushPassword = os.Getenv("METRICS_PUSH_PASSWORD")
+
+	// 设置指标和管理接口的TLS、认证和来源地址限制
+	cfg.MetricsTLSCertFile = os.Getenv("METRICS_TLS_CERT_FILE")
+	cfg.MetricsTLSKeyFile = os.Getenv("METRICS_TLS_KEY_FILE")
+	cfg.MetricsAuthUsername = os.Getenv("METRICS_AUTH_USERNAME")
+	cfg.MetricsAuthPassword = os.Getenv("METRICS_AUTH_PASSWORD")
+	cfg.MetricsAuthToken = os.Getenv("METRICS_AUTH_TOKEN")
+	for _, cidr := range strings.Split(os.Getenv("METRICS_ALLOW_CIDRS"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			cfg.MetricsAllowCIDRs = append(cfg.MetricsAllowCIDRs, cidr)
+		}
+	}
+
+	// 设置 gRPC 管理服务
+	cfg.AdminGRPCListen = os.Getenv("ADMIN_GRPC_LISTEN")
+	cfg.AdminGRPCToken = os.Getenv("ADMIN_GRPC_TOKEN")
+	cfg.AdminGRPCTLSCertFile = os.Getenv("ADMIN_GRPC_TLS_CERT_FILE")
+	cfg.AdminGRPCTLSKeyFile = os.Getenv("ADMIN_GRPC_TLS_KEY_FILE")
+
+	// 设置多实例协调，多个副本采集同一批日志文件时按文件分片认领，避免重复处理
+	cfg.Coordination = strings.ToLower(os.Getenv("COORDINATION"))
+	cfg.CoordinationURL = os.Getenv("COORDINATION_URL")
+	cfg.CoordinationPrefix = os.Getenv("COORDINATION_PREFIX")
+	cfg.CoordinationToken = os.Getenv("COORDINATION_TOKEN")
+	cfg.CoordinationNamespace = os.Getenv("COORDINATION_NAMESPACE")
+	cfg.CoordinationLeaseTTL = parseDurationEnv("COORDINATION_LEASE_TTL", 15*time.Second)
+	if cfg.CoordinationLeaseTTL < 3*time.Second {
+		cfg.CoordinationLeaseTTL = 15 * time.Second
+	}
+
+	// 设置 Kubernetes 自定义资源监听，采集目标、告警路由和静默可由 GitOps 声明式管理
+	cfg.CRDWatch = crdWatch
+	if logFilesEnv == "" {
+		cfg.LogFiles = nil
+	}
+	cfg.CRDNamespace = os.Getenv("CRD_NAMESPACE")
+	cfg.CRDAPIURL = os.Getenv("CRD_API_URL")
+	cfg.CRDToken = os.Getenv("CRD_TOKEN")
+
+	// 设置实验性功能开关，如 auto_suppression=off,ai_tools=on
+	cfg.FeatureFlags = parseFeatureFlags(os.Getenv("FEATURE_FLAGS"))
+
 	// 加载可选配置
 	if maxWorkersStr := os.Getenv("MAX_WORKERS"); maxWorkersStr != "" {
 		if maxWorkers, err := strconv.Atoi(maxWorkersStr); err == nil && maxWorkers > 0 {
 			cfg.MaxWorkers = maxWorkers
 		}
 	}
+	// 工作池伸缩：在 MIN_WORKERS 和 MAX_WORKERS 之间按积压的事件数和处理耗时调整，默认不伸缩
+	if v := os.Getenv("MIN_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MinWorkers = n
+		}
+	}
+	cfg.WorkerScaleInterval = 10 * time.Second
+	if v := os.Getenv("WORKER_SCALE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.WorkerScaleInterval = d
+		}
+	}
+
+	// 默认在启动时安装索引模板，ES_INDEX_TEMPLATE=false 时由运维自行管理映射
+	cfg.ESIndexTemplate = strings.ToLower(os.Getenv("ES_INDEX_TEMPLATE")) != "false"
+	cfg.ESDataStream = strings.ToLower(os.Getenv("ES_DATA_STREAM")) == "true"
+	cfg.ESWriteMode = strings.ToLower(os.Getenv("ES_WRITE_MODE"))
+	if cfg.ESWriteMode == "" {
+		cfg.ESWriteMode = "create"
+	}
+
+	// 设置索引命名，默认每天一个索引 <ES_INDEX>-2006.01.02
+	cfg.ESIndexPattern = os.Getenv("ES_INDEX_PATTERN")
+	if cfg.ESIndexPattern == "" {
+		cfg.ESIndexPattern = "{prefix}-{date}"
+	}
+	cfg.ESIndexGranularity = strings.ToLower(os.Getenv("ES_INDEX_GRANULARITY"))
+	if cfg.ESIndexGranularity == "" {
+		cfg.ESIndexGranularity = "daily"
+	}
+	cfg.ESTenant = os.Getenv("ES_TENANT")
+	cfg.TenantRulesFile = os.Getenv("TENANT_RULES_FILE")
+	cfg.ESIndexAlias = strings.ToLower(os.Getenv("ES_INDEX_ALIAS"))
+
+	// 设置索引保留期，默认永久保留
+	if v := os.Getenv("ES_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			cfg.ESRetentionDays = days
+		}
+	}
+	cfg.ESRetentionMode = strings.ToLower(os.Getenv("ES_RETENTION_MODE"))
+	if cfg.ESRetentionMode == "" {
+		cfg.ESRetentionMode = "auto"
+	}
+
+	// 设置按严重性分层的索引，默认不分层
+	if v := os.Getenv("ES_HOT_MIN_SEVERITY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ESHotMinSeverity = n
+		}
+	}
+	if v := os.Getenv("ES_HOT_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			cfg.ESHotRetentionDays = days
+		}
+	}
+	if v := os.Getenv("ES_NOISE_MAX_SEVERITY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ESNoiseMaxSeverity = n
+		}
+	}
+	if v := os.Getenv("ES_NOISE_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			cfg.ESNoiseRetentionDays = days
+		}
+	}
+
+	// 设置ES批量写入，默认每批最多500个文档或5MB，最多等待1秒
+	cfg.ESBulkActions = 500
+	if v := os.Getenv("ES_BULK_ACTIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ESBulkActions = n
+		}
+	}
+	cfg.ESBulkBytes = 5 << 20
+	if v := os.Getenv("ES_BULK_BYTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.ESBulkBytes = n
+		}
+	}
+	cfg.ESBulkFlushInterval = time.Second
+	if v := os.Getenv("ES_BULK_FLUSH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ESBulkFlushInterval = d
+		}
+	}
+	cfg.ESBulkWorkers = 1
+	if v := os.Getenv("ES_BULK_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.ESBulkWorkers = n
+		}
+	}
+
+	// 设置告警聚合文档，默认只存储事件文档
+	cfg.ESAlertStore = strings.ToLower(os.Getenv("ES_ALERT_STORE"))
+	if cfg.ESAlertStore == "" {
+		cfg.ESAlertStore = "events"
+	}
+	cfg.ESAlertIndex = strings.ToLower(os.Getenv("ES_ALERT_INDEX"))
+	if cfg.ESAlertIndex == "" {
+		cfg.ESAlertIndex = "logai-alerts"
+	}
+	cfg.ESIncidentIndex = strings.ToLower(os.Getenv("ES_INCIDENT_INDEX"))
+	if cfg.ESIncidentIndex == "" {
+		cfg.ESIncidentIndex = "logai-incidents"
+	}
+
+	// 设置PostgreSQL存储，配置连接串后与ES同时写入（或在 ENABLE_ES=false 时单独使用）
+	cfg.PGDSN = os.Getenv("PG_DSN")
+	cfg.PGMaxConns = 4
+	if v := os.Getenv("PG_MAX_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.PGMaxConns = n
+		}
+	}
+
+	// 设置NDJSON文件输出
+	cfg.OutputFile = os.Getenv("OUTPUT_FILE")
+	cfg.OutputFileAlerts = strings.ToLower(os.Getenv("OUTPUT_FILE_ALERTS")) == "true"
+
+	// 设置Kafka输出，配置broker后将处理后的事件发布到主题
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			cfg.KafkaBrokers = append(cfg.KafkaBrokers, broker)
+		}
+	}
+	cfg.KafkaTopic = os.Getenv("KAFKA_TOPIC")
+	if cfg.KafkaTopic == "" {
+		cfg.KafkaTopic = "logai-events"
+	}
+	cfg.KafkaAlertTopic = os.Getenv("KAFKA_ALERT_TOPIC")
+	cfg.KafkaTLS = strings.ToLower(os.Getenv("KAFKA_TLS")) == "true"
+	cfg.KafkaSASLMechanism = strings.ToLower(os.Getenv("KAFKA_SASL_MECHANISM"))
+	cfg.KafkaUsername = os.Getenv("KAFKA_USERNAME")
+	cfg.KafkaPassword = os.Getenv("KAFKA_PASSWORD")
+
+	// 设置VictoriaLogs输出
+	cfg.VictoriaLogsURL = os.Getenv("VICTORIALOGS_URL")
+	cfg.VictoriaLogsAccountID = os.Getenv("VICTORIALOGS_ACCOUNT_ID")
+	cfg.VictoriaLogsProjectID = os.Getenv("VICTORIALOGS_PROJECT_ID")
+
+	// 设置ES写入熔断，默认连续失败5次后熔断30秒；溢出队列默认最多占用1GB磁盘
+	cfg.ESBreakerFailures = 5
+	if v := os.Getenv("ES_BREAKER_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ESBreakerFailures = n
+		}
+	}
+	cfg.ESBreakerCooldown = 30 * time.Second
+	if v := os.Getenv("ES_BREAKER_COOLDOWN"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ESBreakerCooldown = d
+		}
+	}
+	cfg.ESSpillDir = os.Getenv("ES_SPILL_DIR")
+	cfg.ESSpillMaxBytes = 1 << 30
+	if v := os.Getenv("ES_SPILL_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			cfg.ESSpillMaxBytes = n
+		}
+	}
+
+	// 设置ES连接参数，默认压缩请求体，单个请求30秒超时，每个节点保持10个空闲长连接
+	cfg.ESCompress = strings.ToLower(os.Getenv("ES_COMPRESS")) != "false"
+	cfg.ESRequestTimeout = 30 * time.Second
+	if v := os.Getenv("ES_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.ESRequestTimeout = d
+		}
+	}
+	cfg.ESMaxIdleConns = 10
+	if v := os.Getenv("ES_MAX_IDLE_CONNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.ESMaxIdleConns = n
+		}
+	}
+	cfg.ESIdleConnTimeout = 90 * time.Second
+	if v := os.Getenv("ES_IDLE_CONN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ESIdleConnTimeout = d
+		}
+	}
+
+	// 设置优先级队列，默认最多缓存1000个事件，每等待30秒相当于严重性加1
+	cfg.EventQueueSize = 1000
+	if v := os.Getenv("EVENT_QUEUE_SIZE"); v != "" {
+		if size, err := strconv.Atoi(v); err == nil && size > 0 {
+			cfg.EventQueueSize = size
+		}
+	}
+	cfg.PriorityAging = 30 * time.Second
+	if v := os.Getenv("PRIORITY_AGING"); v != "" {
+		if aging, err := time.ParseDuration(v); err == nil && aging >= 0 {
+			cfg.PriorityAging = aging
+		}
+	}
+
+	// 设置采集背压，默认积压达到优先级队列容量时暂停采集
+	cfg.MaxBacklogEvents = cfg.EventQueueSize
+	if v := os.Getenv("MAX_BACKLOG_EVENTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.MaxBacklogEvents = n
+		}
+	}
+	// 设置端到端流量控制，默认内存中待处理的事件最多占用256MiB
+	cfg.FlowMaxInflightBytes = 256 << 20
+	if v := os.Getenv("FLOW_MAX_INFLIGHT_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.FlowMaxInflightBytes = n
+		}
+	}
+
+	// 设置采集间隔，默认每秒检查一次日志文件
+	cfg.CollectInterval = time.Second
+	if v := os.Getenv("COLLECT_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.CollectInterval = d
+		}
+	}
+	cfg.CollectSchedule = os.Getenv("COLLECT_SCHEDULE")
+
+	// 设置事件上下文行数，默认5行
+	cfg.ContextLines = 5
+	if v := os.Getenv("CONTEXT_LINES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ContextLines = n
+		}
+	}
+
+	// 设置事件通道容量和退出等待时间，默认100和2秒
+	cfg.EventChannelSize = 100
+	if v := os.Getenv("EVENT_CHANNEL_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.EventChannelSize = n
+		}
+	}
+	cfg.ShutdownGrace = 2 * time.Second
+	if v := os.Getenv("SHUTDOWN_GRACE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.ShutdownGrace = d
+		}
+	}
+
+	// 设置写前日志，配置目录后采集到的事件先持久化，投递完成前崩溃时在下次启动时重放
+	cfg.WALDir = os.Getenv("WAL_DIR")
+	cfg.WALMaxBytes = 1 << 30
+	if v := os.Getenv("WAL_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.WALMaxBytes = n
+		}
+	}
+	cfg.WALFullPolicy = strings.ToLower(os.Getenv("WAL_FULL_POLICY"))
+	if cfg.WALFullPolicy == "" {
+		cfg.WALFullPolicy = "block"
+	}
+
+	// 设置磁盘事件队列，配置目录后采集到的事件先追加到磁盘，突发的大量事件不阻塞采集
+	cfg.EventQueueDir = os.Getenv("EVENT_QUEUE_DIR")
+	cfg.EventQueueMaxBytes = 1 << 30
+	if v := os.Getenv("EVENT_QUEUE_MAX_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			cfg.EventQueueMaxBytes = n
+		}
+	}
 
 	if logLevel := os.Getenv("LOG_LEVEL"); logLevel != "" {
 		cfg.LogLevel = logLevel
@@ -86,6 +746,7 @@ func Load() (*Config, error) {
 	if enableCellTrace := os.Getenv("ENABLE_CELL_TRACE"); enableCellTrace != "" {
 		cfg.EnableCellTrace = strings.ToLower(enableCellTrace) == "true"
 	}
+	cfg.HeuristicsFile = os.Getenv("HEURISTICS_FILE")
 
 	// 设置是否启用告警功能，默认启用
 	cfg.EnableAlert = true
@@ -107,6 +768,310 @@ func Load() (*Config, error) {
 		}
 	}
 
+	cfg.AlertSeveritySchedule = os.Getenv("ALERT_SEVERITY_SCHEDULE")
+
+	// 设置渐进式告警，默认关闭，开启后最多等待AI分析3秒
+	cfg.AlertProgressive = strings.ToLower(os.Getenv("ALERT_PROGRESSIVE")) == "true"
+	cfg.AlertProgressiveDelay = 3 * time.Second
+	if delayStr := os.Getenv("ALERT_PROGRESSIVE_DELAY"); delayStr != "" {
+		if delay, err := time.ParseDuration(delayStr); err == nil && delay >= 0 {
+			cfg.AlertProgressiveDelay = delay
+		}
+	}
+
+	// 设置重复告警的发送间隔，默认高严重性5分钟、中严重性10分钟、低严重性30分钟
+	cfg.AlertResendHigh = parseDurationEnv("ALERT_RESEND_HIGH", 5*time.Minute)
+	cfg.AlertResendMedium = parseDurationEnv("ALERT_RESEND_MEDIUM", 10*time.Minute)
+	cfg.AlertResendLow = parseDurationEnv("ALERT_RESEND_LOW", 30*time.Minute)
+
+	// 设置告警合并的相似度阈值和上下文行数上限，默认90%和20行
+	cfg.AlertSimilarity = 90
+	if v := os.Getenv("ALERT_SIMILARITY"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f <= 100 {
+			cfg.AlertSimilarity = f
+		}
+	}
+	cfg.AlertMaxContextLines = 20
+	if v := os.Getenv("ALERT_MAX_CONTEXT_LINES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AlertMaxContextLines = n
+		}
+	}
+
+	// 设置告警发送的重试和熔断，默认失败后重试2次（间隔1秒起加倍），每个渠道连续失败5次后熔断1分钟
+	cfg.AlertRetries = 2
+	if v := os.Getenv("ALERT_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AlertRetries = n
+		}
+	}
+	cfg.AlertRetryBackoff = parseDurationEnv("ALERT_RETRY_BACKOFF", time.Second)
+	cfg.AlertBreakerFailures = 5
+	if v := os.Getenv("ALERT_BREAKER_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AlertBreakerFailures = n
+		}
+	}
+	cfg.AlertBreakerCooldown = parseDurationEnv("ALERT_BREAKER_COOLDOWN", time.Minute)
+
+	// 设置模型请求参数，默认温度0.7、流式请求，备用后端和分级后端未配置的参数沿用主后端
+	cfg.AIModelParams = loadModelParams("AI", ModelParams{Temperature: 0.7, Stream: true})
+	cfg.AIFallbackParams = loadModelParams("AI_FALLBACK", cfg.AIModelParams)
+	cfg.AITriageParams = loadModelParams("AI_TRIAGE", cfg.AIModelParams)
+
+	// 设置AI限流，默认最多4个并发请求，排队超过1分钟放弃
+	cfg.AIMaxInFlight = 4
+	if v := os.Getenv("AI_MAX_IN_FLIGHT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.AIMaxInFlight = n
+		}
+	}
+	cfg.AIRequestsPerMin = int(parseInt64Env("AI_REQUESTS_PER_MINUTE"))
+	cfg.AIQueueTimeout = time.Minute
+	if v := os.Getenv("AI_QUEUE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.AIQueueTimeout = d
+		}
+	}
+
+	// 设置AI预算
+	cfg.AIDailyTokenLimit = parseInt64Env("AI_DAILY_TOKEN_LIMIT")
+	cfg.AIMonthlyTokenLimit = parseInt64Env("AI_MONTHLY_TOKEN_LIMIT")
+	cfg.AIDailyCostLimit = parseFloatEnv("AI_DAILY_COST_LIMIT")
+	cfg.AIMonthlyCostLimit = parseFloatEnv("AI_MONTHLY_COST_LIMIT")
+	cfg.AIPromptPricePer1K = parseFloatEnv("AI_PROMPT_PRICE_PER_1K")
+	cfg.AICompletionPricePer1K = parseFloatEnv("AI_COMPLETION_PRICE_PER_1K")
+
+	// 设置AI合并分析，默认不合并
+	if windowStr := os.Getenv("AI_BATCH_WINDOW"); windowStr != "" {
+		if window, err := time.ParseDuration(windowStr); err == nil && window > 0 {
+			cfg.AIBatchWindow = window
+		}
+	}
+	cfg.AIBatchMaxSize = 10
+	if maxSizeStr := os.Getenv("AI_BATCH_MAX_SIZE"); maxSizeStr != "" {
+		if maxSize, err := strconv.Atoi(maxSizeStr); err == nil && maxSize > 0 {
+			cfg.AIBatchMaxSize = maxSize
+		}
+	}
+
+	// 设置各渠道的输出语言，如 AI_OUTPUT_LANGUAGE_WECHAT=中文
+	cfg.AIChannelLanguages = make(map[string]string)
+	for _, channel := range []string{"wechat", "email", "ticket"} {
+		if lang := os.Getenv("AI_OUTPUT_LANGUAGE_" + strings.ToUpper(channel)); lang != "" {
+			cfg.AIChannelLanguages[channel] = lang
+		}
+	}
+
+	// 设置历史事件检索，默认附加3个相似历史事件
+	cfg.AIHistoryLimit = 3
+	if limitStr := os.Getenv("AI_HISTORY_LIMIT"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit >= 0 {
+			cfg.AIHistoryLimit = limit
+		}
+	}
+
+	// 设置AI后端健康探测，默认每30秒一次
+	cfg.AIHealthInterval = 30 * time.Second
+	if intervalStr := os.Getenv("AI_HEALTH_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil && interval >= 0 {
+			cfg.AIHealthInterval = interval
+		}
+	}
+
+	// 设置单个事件的token预算，默认6000
+	cfg.AIMaxInputTokens = 6000
+	if v := os.Getenv("AI_MAX_INPUT_TOKENS"); v != "" {
+		if maxTokens, err := strconv.Atoi(v); err == nil && maxTokens >= 0 {
+			cfg.AIMaxInputTokens = maxTokens
+		}
+	}
+
+	// 默认校验AI回复的结构化结论，AI_RESPONSE_VALIDATION=false 时关闭
+	cfg.AIResponseValidation = strings.ToLower(os.Getenv("AI_RESPONSE_VALIDATION")) != "false"
+
+	if cfg.SidecarListen == "" {
+		cfg.SidecarListen = ":9090"
+	}
+
+	// 设置两阶段分析阈值，默认不分级
+	if v := os.Getenv("AI_TRIAGE_THRESHOLD"); v != "" {
+		if threshold, err := strconv.Atoi(v); err == nil && threshold >= 0 && threshold <= 10 {
+			cfg.AITriageThreshold = threshold
+		}
+	}
+
+	// 设置工具调用，默认关闭，需模型支持 function calling
+	cfg.AITools = strings.ToLower(os.Getenv("AI_TOOLS")) == "true"
+	cfg.AIToolMaxRounds = 3
+	if v := os.Getenv("AI_TOOL_MAX_ROUNDS"); v != "" {
+		if rounds, err := strconv.Atoi(v); err == nil && rounds >= 0 {
+			cfg.AIToolMaxRounds = rounds
+		}
+	}
+
+	// 设置日志风暴检测，默认1分钟内超过500个事件
+	cfg.StormThreshold = 500
+	if thresholdStr := os.Getenv("STORM_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil && threshold >= 0 {
+			cfg.StormThreshold = threshold
+		}
+	}
+	cfg.StormWindow = time.Minute
+	if windowStr := os.Getenv("STORM_WINDOW"); windowStr != "" {
+		if window, err := time.ParseDuration(windowStr); err == nil && window > 0 {
+			cfg.StormWindow = window
+		}
+	}
+
+	// 设置故障归并，默认15分钟没有新事件的故障视为已恢复
+	cfg.IncidentWindow = 15 * time.Minute
+	if v := os.Getenv("INCIDENT_WINDOW"); v != "" {
+		if window, err := time.ParseDuration(v); err == nil && window >= 0 {
+			cfg.IncidentWindow = window
+		}
+	}
+	cfg.IncidentStateFile = os.Getenv("INCIDENT_STATE_FILE")
+
+	cfg.SequenceRulesFile = os.Getenv("SEQUENCE_RULES_FILE")
+	cfg.SLORulesFile = os.Getenv("SLO_RULES_FILE")
+
+	// 设置请求关联，默认关联5分钟内同一 TraceID 的事件
+	cfg.TraceWindow = 5 * time.Minute
+	if v := os.Getenv("TRACE_CORRELATION_WINDOW"); v != "" {
+		if window, err := time.ParseDuration(v); err == nil && window >= 0 {
+			cfg.TraceWindow = window
+		}
+	}
+	cfg.TraceStateFile = os.Getenv("TRACE_STATE_FILE")
+
+	// 设置速率异常检测，默认按分钟统计，超过基线4个标准差、达到基线3倍且至少20次时告警
+	cfg.AnomalyThreshold = 4
+	if v := os.Getenv("ANOMALY_THRESHOLD"); v != "" {
+		if threshold, err := strconv.ParseFloat(v, 64); err == nil && threshold >= 0 {
+			cfg.AnomalyThreshold = threshold
+		}
+	}
+	cfg.AnomalyRatio = 3
+	if v := os.Getenv("ANOMALY_RATIO"); v != "" {
+		if ratio, err := strconv.ParseFloat(v, 64); err == nil && ratio >= 0 {
+			cfg.AnomalyRatio = ratio
+		}
+	}
+	cfg.AnomalyInterval = time.Minute
+	if intervalStr := os.Getenv("ANOMALY_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil && interval > 0 {
+			cfg.AnomalyInterval = interval
+		}
+	}
+	cfg.AnomalyAlpha = 0.1
+	if v := os.Getenv("ANOMALY_ALPHA"); v != "" {
+		if alpha, err := strconv.ParseFloat(v, 64); err == nil && alpha > 0 && alpha < 1 {
+			cfg.AnomalyAlpha = alpha
+		}
+	}
+	cfg.AnomalyMinCount = 20
+	if v := os.Getenv("ANOMALY_MIN_COUNT"); v != "" {
+		if minCount, err := strconv.Atoi(v); err == nil && minCount > 0 {
+			cfg.AnomalyMinCount = minCount
+		}
+	}
+	cfg.AnomalyWarmup = 10
+	if v := os.Getenv("ANOMALY_WARMUP"); v != "" {
+		if warmup, err := strconv.Atoi(v); err == nil && warmup >= 0 {
+			cfg.AnomalyWarmup = warmup
+		}
+	}
+	cfg.AnomalySeasonality = strings.ToLower(os.Getenv("ANOMALY_SEASONALITY"))
+	cfg.AnomalyStateFile = os.Getenv("ANOMALY_STATE_FILE")
+
+	cfg.TicketSystem = strings.ToLower(os.Getenv("TICKET_SYSTEM"))
+	cfg.TicketURL = os.Getenv("TICKET_URL")
+	cfg.TicketUser = os.Getenv("TICKET_USER")
+	cfg.TicketToken = os.Getenv("TICKET_TOKEN")
+	cfg.TicketProject = os.Getenv("TICKET_PROJECT")
+	cfg.TicketIssueType = os.Getenv("TICKET_ISSUE_TYPE")
+	cfg.TicketResolveTransition = os.Getenv("TICKET_RESOLVE_TRANSITION")
+	cfg.KibanaURL = os.Getenv("KIBANA_URL")
+	cfg.KibanaAPIKey = os.Getenv("KIBANA_API_KEY")
+	cfg.KibanaSpace = os.Getenv("KIBANA_SPACE")
+	cfg.KibanaProvision = strings.ToLower(os.Getenv("KIBANA_PROVISION")) == "true"
+	cfg.GrafanaURL = os.Getenv("GRAFANA_URL")
+	cfg.GrafanaToken = os.Getenv("GRAFANA_TOKEN")
+	cfg.GrafanaFolder = os.Getenv("GRAFANA_FOLDER")
+	cfg.GrafanaDatasource = os.Getenv("GRAFANA_DATASOURCE")
+	cfg.FeedbackBaseURL = os.Getenv("FEEDBACK_BASE_URL")
+	cfg.AlertTestAPI = strings.ToLower(os.Getenv("ALERT_TEST_API")) == "true"
+	cfg.RunbookDir = os.Getenv("RUNBOOK_DIR")
+	cfg.RunbookBaseURL = os.Getenv("RUNBOOK_BASE_URL")
+
+	// 设置汇总报告
+	cfg.ReportSchedule = os.Getenv("REPORT_SCHEDULE")
+	cfg.ReportIndex = os.Getenv("REPORT_INDEX")
+	if cfg.ReportIndex == "" {
+		cfg.ReportIndex = "logai-reports"
+	}
+	cfg.ReportTopN = 10
+	if topStr := os.Getenv("REPORT_TOP_N"); topStr != "" {
+		if top, err := strconv.Atoi(topStr); err == nil && top > 0 {
+			cfg.ReportTopN = top
+		}
+	}
+	if emailTo := os.Getenv("REPORT_EMAIL_TO"); emailTo != "" {
+		for _, addr := range strings.Split(emailTo, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				cfg.ReportEmailTo = append(cfg.ReportEmailTo, addr)
+			}
+		}
+	}
+
+	// 设置噪声抑制，默认不启用
+	cfg.NoiseSuppression = strings.ToLower(os.Getenv("NOISE_SUPPRESSION"))
+	if cfg.NoiseSuppression == "" {
+		cfg.NoiseSuppression = "off"
+	}
+	cfg.NoiseSuppressionFile = os.Getenv("NOISE_SUPPRESSION_FILE")
+	cfg.NoiseMinEvents = 500
+	if v := os.Getenv("NOISE_MIN_EVENTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.NoiseMinEvents = n
+		}
+	}
+
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.SMTPPort = os.Getenv("SMTP_PORT")
+	cfg.SMTPUser = os.Getenv("SMTP_USER")
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	cfg.SMTPFrom = os.Getenv("SMTP_FROM")
+	cfg.TicketMinSeverity = 9
+	if minSeverityStr := os.Getenv("TICKET_MIN_SEVERITY"); minSeverityStr != "" {
+		if minSeverity, err := strconv.Atoi(minSeverityStr); err == nil && minSeverity > 0 {
+			cfg.TicketMinSeverity = minSeverity
+		}
+	}
+
+	cfg.OnCallSource = strings.ToLower(os.Getenv("ONCALL_SOURCE"))
+	cfg.OnCallRotationFile = os.Getenv("ONCALL_ROTATION_FILE")
+	cfg.OnCallToken = os.Getenv("ONCALL_TOKEN")
+	cfg.OnCallSchedule = os.Getenv("ONCALL_SCHEDULE")
+
+	cfg.ConfigSource = remote.Kind
+	cfg.ConfigSourceURL = remote.URL
+	cfg.ConfigSourcePrefix = remote.Prefix
+	cfg.ConfigSourceToken = remote.Token
+	cfg.ConfigSourceDir = remote.Dir
+
+	// 解析密钥引用，默认每5分钟重新解析一次
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+	cfg.SecretRefreshInterval = 5 * time.Minute
+	if v := os.Getenv("SECRET_REFRESH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.SecretRefreshInterval = d
+		}
+	}
+
 	// 验证必要配置
 	if err := cfg.validate(); err != nil {
 		return nil, err
@@ -115,9 +1080,105 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// loadModelParams 读取 <prefix>_TEMPERATURE、<prefix>_TOP_P、<prefix>_MAX_TOKENS、<prefix>_TIMEOUT、<prefix>_STREAM，
+// 未设置或无效时使用 defaults 中的值
+func loadModelParams(prefix string, defaults ModelParams) ModelParams {
+	params := defaults
+	if v := os.Getenv(prefix + "_TEMPERATURE"); v != "" {
+		if t, err := strconv.ParseFloat(v, 64); err == nil && t >= 0 && t <= 2 {
+			params.Temperature = t
+		}
+	}
+	if v := os.Getenv(prefix + "_TOP_P"); v != "" {
+		if p, err := strconv.ParseFloat(v, 64); err == nil && p >= 0 && p <= 1 {
+			params.TopP = p
+		}
+	}
+	if v := os.Getenv(prefix + "_MAX_TOKENS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			params.MaxTokens = n
+		}
+	}
+	if v := os.Getenv(prefix + "_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			params.Timeout = d
+		}
+	}
+	if v := os.Getenv(prefix + "_STREAM"); v != "" {
+		params.Stream = strings.ToLower(v) == "true"
+	}
+	return params
+}
+
+// parseInt64Env 读取非负整数环境变量，未设置或无效时返回0
+func parseInt64Env(key string) int64 {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// parseFloatEnv 读取非负浮点数环境变量，未设置或无效时返回0
+func parseFloatEnv(key string) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+	}
+	return 0
+}
+
+// labelNamePattern 标签名需同时满足 Prometheus 标签名和ES字段名的要求
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseLabels 解析逗号分隔的 名称=值 标签，忽略名称无效、以 __ 开头或与 instance_id 重名的项
+func parseLabels(v string) map[string]string {
+	labels := make(map[string]string)
+	for _, item := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		name = strings.TrimSpace(name)
+		if !ok || !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") || name == "instance_id" {
+			continue
+		}
+		labels[name] = strings.TrimSpace(value)
+	}
+	return labels
+}
+
+// parseFeatureFlags 解析逗号分隔的 名称=开关 功能开关，开关为 on/off 或 true/false，无效的开关值被忽略
+func parseFeatureFlags(v string) map[string]bool {
+	flags := make(map[string]bool)
+	for _, item := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			flags[name] = true
+		case "off", "false", "0":
+			flags[name] = false
+		}
+	}
+	return flags
+}
+
+// parseDurationEnv 读取正时长环境变量，未设置或无效时返回默认值
+func parseDurationEnv(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return def
+}
+
 // validate 验证配置的有效性
 func (c *Config) validate() error {
-	if len(c.LogFiles) == 0 {
+	if len(c.LogFiles) == 0 && !c.CRDWatch {
 		return fmt.Errorf("日志文件路径不能为空")
 	}
 
@@ -129,17 +1190,66 @@ func (c *Config) validate() error {
 		}
 	}
 
+	if c.AlertMaxContextLines > 0 && c.AlertMaxContextLines < c.ContextLines {
+		fmt.Printf("警告: ALERT_MAX_CONTEXT_LINES(%d) 小于 CONTEXT_LINES(%d)，合并告警的上下文会被截断\n", c.AlertMaxContextLines, c.ContextLines)
+	}
+
 	// 如果启用了AI分析，验证必要配置
+	// Ollama 等本地后端不需要 API Key，地址也有默认值
 	if strings.ToLower(c.AIEnable) == "true" {
-		if c.AIAPIURL == "" {
-			return fmt.Errorf("启用AI分析时必须配置 AI_API_URL")
-		}
-		if c.AIAPIKey == "" {
-			return fmt.Errorf("启用AI分析时必须配置 AI_API_KEY")
+		switch c.AIProvider {
+		case "", "openai":
+			if c.AIAPIURL == "" {
+				return fmt.Errorf("启用AI分析时必须配置 AI_API_URL")
+			}
+			if c.AIAPIKey == "" && !isLocalURL(c.AIAPIURL) {
+				return fmt.Errorf("启用AI分析时必须配置 AI_API_KEY")
+			}
+		case "ollama":
+		case "grpc":
+			if c.AIAPIURL == "" {
+				return fmt.Errorf("AI后端为 grpc 时必须配置 AI_API_URL（sidecar 地址）")
+			}
+		default:
+			return fmt.Errorf("不支持的AI后端: %s", c.AIProvider)
 		}
-		if c.AIModel == "" {
+		// grpc 后端的模型由 sidecar 决定
+		if c.AIModel == "" && c.AIProvider != "grpc" {
 			return fmt.Errorf("启用AI分析时必须配置 AI_MODEL_NAME")
 		}
+
+		switch c.AIFallbackProvider {
+		case "":
+		case "openai":
+			if c.AIFallbackAPIURL == "" {
+				return fmt.Errorf("备用AI后端为 openai 时必须配置 AI_FALLBACK_API_URL")
+			}
+		case "ollama":
+		case "grpc":
+			if c.AIFallbackAPIURL == "" {
+				return fmt.Errorf("备用AI后端为 grpc 时必须配置 AI_FALLBACK_API_URL")
+			}
+		default:
+			return fmt.Errorf("不支持的备用AI后端: %s", c.AIFallbackProvider)
+		}
+		if c.AIFallbackProvider != "" && c.AIFallbackProvider != "grpc" && c.AIFallbackModel == "" {
+			return fmt.Errorf("配置备用AI后端时必须配置 AI_FALLBACK_MODEL_NAME")
+		}
+
+		switch c.AITriageProvider {
+		case "", "rules":
+		case "openai":
+			if c.AITriageAPIURL == "" {
+				return fmt.Errorf("分级后端为 openai 时必须配置 AI_TRIAGE_API_URL")
+			}
+			fallthrough
+		case "ollama":
+			if c.AITriageModel == "" {
+				return fmt.Errorf("配置分级模型时必须配置 AI_TRIAGE_MODEL_NAME")
+			}
+		default:
+			return fmt.Errorf("不支持的分级后端: %s", c.AITriageProvider)
+		}
 	}
 
 	// 验证企业微信webhook
@@ -147,5 +1257,202 @@ func (c *Config) validate() error {
 		return fmt.Errorf("企业微信webhook地址必须是有效的URL")
 	}
 
+	// 验证sidecar证书配置
+	if (c.SidecarTLSCertFile == "") != (c.SidecarTLSKeyFile == "") {
+		return fmt.Errorf("SIDECAR_TLS_CERT_FILE 和 SIDECAR_TLS_KEY_FILE 必须同时配置")
+	}
+
+	// 验证AI客户端证书配置
+	if (c.AIClientCertFile == "") != (c.AIClientKeyFile == "") {
+		return fmt.Errorf("AI_CLIENT_CERT_FILE 和 AI_CLIENT_KEY_FILE 必须同时配置")
+	}
+
+	// 验证索引保留配置
+	switch c.ESRetentionMode {
+	case "auto", "ilm", "delete":
+	default:
+		return fmt.Errorf("不支持的索引清理方式: %s", c.ESRetentionMode)
+	}
+	if c.ESRetentionMode == "ilm" && c.ESRetentionDays > 0 && !c.ESIndexTemplate {
+		return fmt.Errorf("ES_RETENTION_MODE=ilm 需要启用 ES_INDEX_TEMPLATE，以便为新索引关联ILM策略")
+	}
+	switch c.ESIndexGranularity {
+	case "daily", "weekly", "monthly":
+	default:
+		return fmt.Errorf("不支持的索引粒度: %s", c.ESIndexGranularity)
+	}
+	if strings.Contains(c.ESIndexPattern, "{tenant}") && c.ESTenant == "" && c.TenantRulesFile == "" {
+		return fmt.Errorf("ES_INDEX_PATTERN 包含 {tenant} 时必须配置 ES_TENANT 或 TENANT_RULES_FILE")
+	}
+	switch c.ESAlertStore {
+	case "events", "alerts", "both":
+	default:
+		return fmt.Errorf("不支持的ES存储方式: %s", c.ESAlertStore)
+	}
+	switch c.WALFullPolicy {
+	case "block", "drop_oldest", "drop_newest":
+	default:
+		return fmt.Errorf("不支持的写前日志写满策略: %s", c.WALFullPolicy)
+	}
+	switch c.KafkaSASLMechanism {
+	case "", "plain", "scram-sha-256", "scram-sha-512":
+	default:
+		return fmt.Errorf("不支持的Kafka SASL认证方式: %s", c.KafkaSASLMechanism)
+	}
+	if c.ESDataStream && !c.ESIndexTemplate {
+		return fmt.Errorf("ES_DATA_STREAM=true 需要启用 ES_INDEX_TEMPLATE，数据流由索引模板声明")
+	}
+	if c.ESDataStream && c.ESRetentionMode == "delete" {
+		return fmt.Errorf("ES_DATA_STREAM=true 时不支持 ES_RETENTION_MODE=delete，数据流的保留期由ILM策略管理")
+	}
+	if c.KibanaProvision && c.KibanaURL == "" {
+		return fmt.Errorf("KIBANA_PROVISION=true 时必须配置 KIBANA_URL")
+	}
+	switch c.ESWriteMode {
+	case "create", "upsert":
+	default:
+		return fmt.Errorf("不支持的ES写入模式: %s", c.ESWriteMode)
+	}
+	if c.ESHotMinSeverity > 0 && c.ESNoiseMaxSeverity >= c.ESHotMinSeverity {
+		return fmt.Errorf("ES_NOISE_MAX_SEVERITY 必须小于 ES_HOT_MIN_SEVERITY")
+	}
+	if c.ESDataStream && (c.ESHotMinSeverity > 0 || c.ESNoiseMaxSeverity > 0) {
+		return fmt.Errorf("ES_DATA_STREAM=true 时不支持按严重性分层写入索引")
+	}
+	if c.ESDataStream && c.ESWriteMode == "upsert" {
+		return fmt.Errorf("ES_DATA_STREAM=true 时不支持 ES_WRITE_MODE=upsert，数据流只接受 create 操作")
+	}
+
+	// 验证速率异常检测的季节基线
+	switch c.AnomalySeasonality {
+	case "", "daily", "weekly":
+	default:
+		return fmt.Errorf("不支持的季节基线周期: %s", c.AnomalySeasonality)
+	}
+
+	// 验证汇总报告邮件配置
+	if len(c.ReportEmailTo) > 0 && c.SMTPHost == "" {
+		return fmt.Errorf("配置了 REPORT_EMAIL_TO 时必须配置 SMTP_HOST")
+	}
+
+	// 验证指标推送配置
+	switch c.MetricsPushMode {
+	case "pushgateway", "remote_write":
+	default:
+		return fmt.Errorf("不支持的指标推送方式: %s", c.MetricsPushMode)
+	}
+	if c.MetricsPushURL != "" && !strings.HasPrefix(c.MetricsPushURL, "http") {
+		return fmt.Errorf("METRICS_PUSH_URL 必须是有效的URL")
+	}
+
+	// 验证指标和管理接口的访问控制，配置错误时拒绝启动，避免接口在未受保护的情况下对外开放
+	if (c.MetricsTLSCertFile == "") != (c.MetricsTLSKeyFile == "") {
+		return fmt.Errorf("METRICS_TLS_CERT_FILE 和 METRICS_TLS_KEY_FILE 必须同时配置")
+	}
+	if c.MetricsAuthUsername != "" && c.MetricsAuthPassword == "" {
+		return fmt.Errorf("配置 METRICS_AUTH_USERNAME 时必须配置 METRICS_AUTH_PASSWORD")
+	}
+	for _, cidr := range c.MetricsAllowCIDRs {
+		if _, err := ParseAllowedPrefix(cidr); err != nil {
+			return err
+		}
+	}
+	if (c.AdminGRPCTLSCertFile == "") != (c.AdminGRPCTLSKeyFile == "") {
+		return fmt.Errorf("ADMIN_GRPC_TLS_CERT_FILE 和 ADMIN_GRPC_TLS_KEY_FILE 必须同时配置")
+	}
+
+	// 验证多实例协调配置
+	switch c.Coordination {
+	case "", "kubernetes":
+	case "etcd":
+		if c.CoordinationURL == "" {
+			return fmt.Errorf("COORDINATION=etcd 时必须配置 COORDINATION_URL")
+		}
+	default:
+		return fmt.Errorf("不支持的协调后端: %s", c.Coordination)
+	}
+
+	// 验证功能开关名称，避免拼写错误的开关被静默忽略
+	for name := range c.FeatureFlags {
+		if !feature.Known(name) {
+			return fmt.Errorf("未知的功能开关: %s", name)
+		}
+	}
+
+	// 验证噪声抑制配置
+	switch c.NoiseSuppression {
+	case "off", "propose":
+	case "apply":
+		if c.NoiseSuppressionFile == "" {
+			return fmt.Errorf("NOISE_SUPPRESSION=apply 时必须配置 NOISE_SUPPRESSION_FILE")
+		}
+	default:
+		return fmt.Errorf("不支持的噪声抑制模式: %s", c.NoiseSuppression)
+	}
+
+	// 验证工单配置
+	switch c.TicketSystem {
+	case "":
+	case "jira":
+		if c.TicketURL == "" || c.TicketToken == "" || c.TicketProject == "" {
+			return fmt.Errorf("TICKET_SYSTEM=jira 时必须配置 TICKET_URL、TICKET_TOKEN 和 TICKET_PROJECT")
+		}
+	case "servicenow":
+		if c.TicketURL == "" || c.TicketUser == "" || c.TicketToken == "" {
+			return fmt.Errorf("TICKET_SYSTEM=servicenow 时必须配置 TICKET_URL、TICKET_USER 和 TICKET_TOKEN")
+		}
+	default:
+		return fmt.Errorf("不支持的工单系统: %s", c.TicketSystem)
+	}
+
+	// 验证值班配置
+	switch c.OnCallSource {
+	case "":
+	case "rotation":
+		if c.OnCallRotationFile == "" {
+			return fmt.Errorf("ONCALL_SOURCE=rotation 时必须配置 ONCALL_ROTATION_FILE")
+		}
+	case "pagerduty", "opsgenie":
+		if c.OnCallToken == "" || c.OnCallSchedule == "" {
+			return fmt.Errorf("ONCALL_SOURCE=%s 时必须配置 ONCALL_TOKEN 和 ONCALL_SCHEDULE", c.OnCallSource)
+		}
+	default:
+		return fmt.Errorf("不支持的值班来源: %s", c.OnCallSource)
+	}
+
 	return nil
 }
+
+// OutputLanguage 返回渠道的AI输出语言，渠道未单独配置时使用全局配置
+func (c *Config) OutputLanguage(channel string) string {
+	if lang, ok := c.AIChannelLanguages[channel]; ok {
+		return lang
+	}
+	return c.AIOutputLanguage
+}
+
+// isLocalURL 判断地址是否指向本机，本机部署的OpenAI兼容服务通常不需要API Key
+func isLocalURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// ParseAllowedPrefix 解析允许访问的客户端地址，单个IP视为只包含该地址的网段
+func ParseAllowedPrefix(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("无效的地址段 %s: %w", v, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("无效的地址 %s: %w", v, err)
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
@@ -0,0 +1,227 @@
+package config
+
+import "strings"
+
+// EnvVars 所有配置项对应的环境变量，按 Load 中读取的顺序排列；
+// 命令行为每个环境变量提供同名参数（小写、下划线换成连字符），如 --es-nodes 覆盖 ES_NODES
+var EnvVars = []string{
+	"LOG_FILE_PATHS",
+	"ES_NODES",
+	"ES_INDEX",
+	"ENABLE_ES",
+	"METRICS_PORT",
+	"METRICS_MAX_LABEL_VALUES",
+	"DEBUG_ERRORS_SIZE",
+	"DROP_SUMMARY_INTERVAL",
+	"METRICS_PUSH_URL",
+	"METRICS_PUSH_MODE",
+	"METRICS_PUSH_INTERVAL",
+	"METRICS_PUSH_JOB",
+	"METRICS_PUSH_LABELS",
+	"METRICS_PUSH_USERNAME",
+	"METRICS_PUSH_PASSWORD",
+	"METRICS_TLS_CERT_FILE",
+	"METRICS_TLS_KEY_FILE",
+	"METRICS_AUTH_USERNAME",
+	"METRICS_AUTH_PASSWORD",
+	"METRICS_AUTH_TOKEN",
+	"METRICS_ALLOW_CIDRS",
+	"ADMIN_GRPC_LISTEN",
+	"ADMIN_GRPC_TOKEN",
+	"ADMIN_GRPC_TLS_CERT_FILE",
+	"ADMIN_GRPC_TLS_KEY_FILE",
+	"COORDINATION",
+	"COORDINATION_URL",
+	"COORDINATION_PREFIX",
+	"COORDINATION_TOKEN",
+	"COORDINATION_NAMESPACE",
+	"COORDINATION_LEASE_TTL",
+	"CRD_WATCH",
+	"CRD_NAMESPACE",
+	"CRD_API_URL",
+	"CRD_TOKEN",
+	"INSTANCE_ID",
+	"INSTANCE_LABELS",
+	"FEATURE_FLAGS",
+	"AI_API_URL",
+	"AI_API_KEY",
+	"AI_MODEL_NAME",
+	"AI_ENABLE",
+	"AI_PROVIDER",
+	"AI_SYSTEM_PROMPT_FILE",
+	"AI_USER_PROMPT_FILE",
+	"AI_PROMPT_ROUTES_FILE",
+	"AI_OFFLINE_RULES_FILE",
+	"AI_FALLBACK_PROVIDER",
+	"AI_FALLBACK_API_URL",
+	"AI_FALLBACK_API_KEY",
+	"AI_FALLBACK_MODEL_NAME",
+	"AI_TRIAGE_PROVIDER",
+	"AI_TRIAGE_API_URL",
+	"AI_TRIAGE_API_KEY",
+	"AI_TRIAGE_MODEL_NAME",
+	"AI_OUTPUT_LANGUAGE",
+	"AI_PROXY_URL",
+	"AI_CA_FILE",
+	"AI_CLIENT_CERT_FILE",
+	"AI_CLIENT_KEY_FILE",
+	"SIDECAR_LISTEN",
+	"SIDECAR_TOKEN",
+	"SIDECAR_TLS_CERT_FILE",
+	"SIDECAR_TLS_KEY_FILE",
+	"AI_WECHAT_WEBHOOK",
+	"MAX_WORKERS",
+	"MIN_WORKERS",
+	"WORKER_SCALE_INTERVAL",
+	"ES_INDEX_TEMPLATE",
+	"ES_DATA_STREAM",
+	"ES_WRITE_MODE",
+	"ES_INDEX_PATTERN",
+	"ES_INDEX_GRANULARITY",
+	"ES_TENANT",
+	"TENANT_RULES_FILE",
+	"ES_INDEX_ALIAS",
+	"ES_RETENTION_DAYS",
+	"ES_RETENTION_MODE",
+	"ES_HOT_MIN_SEVERITY",
+	"ES_HOT_RETENTION_DAYS",
+	"ES_NOISE_MAX_SEVERITY",
+	"ES_NOISE_RETENTION_DAYS",
+	"ES_BULK_ACTIONS",
+	"ES_BULK_BYTES",
+	"ES_BULK_FLUSH_INTERVAL",
+	"ES_BULK_WORKERS",
+	"ES_ALERT_STORE",
+	"ES_ALERT_INDEX",
+	"ES_INCIDENT_INDEX",
+	"PG_DSN",
+	"PG_MAX_CONNS",
+	"OUTPUT_FILE",
+	"OUTPUT_FILE_ALERTS",
+	"KAFKA_BROKERS",
+	"KAFKA_TOPIC",
+	"KAFKA_ALERT_TOPIC",
+	"KAFKA_TLS",
+	"KAFKA_SASL_MECHANISM",
+	"KAFKA_USERNAME",
+	"KAFKA_PASSWORD",
+	"VICTORIALOGS_URL",
+	"VICTORIALOGS_ACCOUNT_ID",
+	"VICTORIALOGS_PROJECT_ID",
+	"ES_BREAKER_FAILURES",
+	"ES_BREAKER_COOLDOWN",
+	"ES_SPILL_DIR",
+	"ES_SPILL_MAX_BYTES",
+	"ES_COMPRESS",
+	"ES_REQUEST_TIMEOUT",
+	"ES_MAX_IDLE_CONNS",
+	"ES_IDLE_CONN_TIMEOUT",
+	"EVENT_QUEUE_SIZE",
+	"PRIORITY_AGING",
+	"MAX_BACKLOG_EVENTS",
+	"FLOW_MAX_INFLIGHT_BYTES",
+	"COLLECT_INTERVAL",
+	"COLLECT_SCHEDULE",
+	"CONTEXT_LINES",
+	"EVENT_CHANNEL_SIZE",
+	"SHUTDOWN_GRACE",
+	"WAL_DIR",
+	"WAL_MAX_BYTES",
+	"WAL_FULL_POLICY",
+	"EVENT_QUEUE_DIR",
+	"EVENT_QUEUE_MAX_BYTES",
+	"LOG_LEVEL",
+	"ENABLE_CELL_TRACE",
+	"HEURISTICS_FILE",
+	"ENABLE_ALERT",
+	"ALERT_TTL",
+	"ALERT_SEVERITY_SCHEDULE",
+	"ALERT_PROGRESSIVE",
+	"ALERT_PROGRESSIVE_DELAY",
+	"ALERT_RESEND_HIGH",
+	"ALERT_RESEND_MEDIUM",
+	"ALERT_RESEND_LOW",
+	"ALERT_SIMILARITY",
+	"ALERT_MAX_CONTEXT_LINES",
+	"ALERT_RETRIES",
+	"ALERT_RETRY_BACKOFF",
+	"ALERT_BREAKER_FAILURES",
+	"ALERT_BREAKER_COOLDOWN",
+	"AI_MAX_IN_FLIGHT",
+	"AI_QUEUE_TIMEOUT",
+	"AI_BATCH_WINDOW",
+	"AI_BATCH_MAX_SIZE",
+	"AI_HISTORY_LIMIT",
+	"AI_HEALTH_INTERVAL",
+	"AI_MAX_INPUT_TOKENS",
+	"AI_RESPONSE_VALIDATION",
+	"AI_TRIAGE_THRESHOLD",
+	"AI_TOOLS",
+	"AI_TOOL_MAX_ROUNDS",
+	"STORM_THRESHOLD",
+	"STORM_WINDOW",
+	"INCIDENT_WINDOW",
+	"INCIDENT_STATE_FILE",
+	"SEQUENCE_RULES_FILE",
+	"SLO_RULES_FILE",
+	"TRACE_CORRELATION_WINDOW",
+	"TRACE_STATE_FILE",
+	"ANOMALY_THRESHOLD",
+	"ANOMALY_RATIO",
+	"ANOMALY_INTERVAL",
+	"ANOMALY_ALPHA",
+	"ANOMALY_MIN_COUNT",
+	"ANOMALY_WARMUP",
+	"ANOMALY_SEASONALITY",
+	"ANOMALY_STATE_FILE",
+	"TICKET_SYSTEM",
+	"TICKET_URL",
+	"TICKET_USER",
+	"TICKET_TOKEN",
+	"TICKET_PROJECT",
+	"TICKET_ISSUE_TYPE",
+	"TICKET_RESOLVE_TRANSITION",
+	"KIBANA_URL",
+	"KIBANA_API_KEY",
+	"KIBANA_SPACE",
+	"KIBANA_PROVISION",
+	"GRAFANA_URL",
+	"GRAFANA_TOKEN",
+	"GRAFANA_FOLDER",
+	"GRAFANA_DATASOURCE",
+	"FEEDBACK_BASE_URL",
+	"ALERT_TEST_API",
+	"RUNBOOK_DIR",
+	"RUNBOOK_BASE_URL",
+	"REPORT_SCHEDULE",
+	"REPORT_INDEX",
+	"REPORT_TOP_N",
+	"REPORT_EMAIL_TO",
+	"NOISE_SUPPRESSION",
+	"NOISE_SUPPRESSION_FILE",
+	"NOISE_MIN_EVENTS",
+	"SMTP_HOST",
+	"SMTP_PORT",
+	"SMTP_USER",
+	"SMTP_PASSWORD",
+	"SMTP_FROM",
+	"TICKET_MIN_SEVERITY",
+	"ONCALL_SOURCE",
+	"ONCALL_ROTATION_FILE",
+	"ONCALL_TOKEN",
+	"ONCALL_SCHEDULE",
+	"SECRET_REFRESH_INTERVAL",
+	"VAULT_ADDR",
+	"VAULT_TOKEN",
+	"VAULT_NAMESPACE",
+	"CONFIG_SOURCE",
+	"CONFIG_SOURCE_URL",
+	"CONFIG_SOURCE_PREFIX",
+	"CONFIG_SOURCE_TOKEN",
+	"CONFIG_SOURCE_DIR",
+}
+
+// FlagName 返回环境变量对应的命令行参数名，如 ES_NODES → es-nodes
+func FlagName(env string) string {
+	return strings.ReplaceAll(strings.ToLower(env), "_", "-")
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 密钥类配置项在输出中的替代值
+const redactedValue = "******"
+
+// Field 一个配置项的名称及实际生效的值
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Redacted 返回所有配置项及实际生效的值（默认值、.env 文件、环境变量和命令行参数合并后），用于排查问题：
+// 密钥类配置项显示为 ******，使用密钥引用时显示引用本身；地址中的密码同样隐去
+func (c *Config) Redacted() []Field {
+	secrets := make(map[uintptr]string)
+	for env, field := range c.secretFields() {
+		secrets[reflect.ValueOf(field).Pointer()] = env
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	fields := make([]Field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		f := v.Field(i)
+		value := formatField(f)
+		if env, ok := secrets[f.Addr().Pointer()]; ok {
+			value = c.redactedSecret(env, f.String())
+		}
+		fields = append(fields, Field{Name: t.Field(i).Name, Value: value})
+	}
+	return fields
+}
+
+// redactedSecret 返回密钥配置项在输出中显示的值
+func (c *Config) redactedSecret(env, value string) string {
+	if c.secrets != nil {
+		if ref, ok := c.secrets.refs[env]; ok {
+			return ref
+		}
+	}
+	if value == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// formatField 将配置项的值格式化为字符串，地址中的密码隐去
+func formatField(v reflect.Value) string {
+	switch val := v.Interface().(type) {
+	case string:
+		return redactURL(val)
+	case time.Duration:
+		return val.String()
+	case []string:
+		parts := make([]string, len(val))
+		for i, s := range val {
+			parts[i] = redactURL(s)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprintf("%+v", val)
+	}
+}
+
+// redactURL 隐去地址中的密码，不是地址或不含密码时原样返回
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	return u.Redacted()
+}
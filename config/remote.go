@@ -0,0 +1,77 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/remoteconfig"
+)
+
+// 启动时读取集中配置的超时时间
+const remoteLoadTimeout = 10 * time.Second
+
+// remoteOptionsFromEnv 读取集中配置源的连接参数，前缀默认 logai/，规则文件默认同步到 ./remote-config
+func remoteOptionsFromEnv() remoteconfig.Options {
+	opts := remoteconfig.Options{
+		Kind:   strings.ToLower(os.Getenv("CONFIG_SOURCE")),
+		URL:    os.Getenv("CONFIG_SOURCE_URL"),
+		Prefix: os.Getenv("CONFIG_SOURCE_PREFIX"),
+		Token:  os.Getenv("CONFIG_SOURCE_TOKEN"),
+		Dir:    os.Getenv("CONFIG_SOURCE_DIR"),
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "logai/"
+	}
+	if opts.Dir == "" {
+		opts.Dir = "./remote-config"
+	}
+	return opts
+}
+
+// loadRemoteConfig 读取集中配置：env/ 下的配置项在本地（命令行参数、环境变量、.env 文件）未设置时生效，
+// files/ 下的规则文件同步到本地目录；未配置 CONFIG_SOURCE 时不做任何事
+func loadRemoteConfig(opts remoteconfig.Options) error {
+	if opts.Kind == "" {
+		return nil
+	}
+	token, err := ResolveSecret(context.Background(), opts.Token)
+	if err != nil {
+		return fmt.Errorf("解析 CONFIG_SOURCE_TOKEN 的密钥引用失败: %w", err)
+	}
+	opts.Token = token
+	source, err := remoteconfig.New(opts)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), remoteLoadTimeout)
+	defer cancel()
+	snap, err := source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("读取集中配置失败: %w", err)
+	}
+	for env, value := range snap.Env() {
+		if !slices.Contains(EnvVars, env) || strings.HasPrefix(env, "CONFIG_SOURCE") {
+			fmt.Printf("警告: 忽略集中配置中的未知配置项: %s\n", env)
+			continue
+		}
+		if _, ok := os.LookupEnv(env); !ok {
+			os.Setenv(env, value)
+		}
+	}
+	return nil
+}
+
+// RemoteConfigOptions 返回集中配置源的连接参数，用于监听配置变化
+func (c *Config) RemoteConfigOptions() remoteconfig.Options {
+	return remoteconfig.Options{
+		Kind:   c.ConfigSource,
+		URL:    c.ConfigSourceURL,
+		Prefix: c.ConfigSourcePrefix,
+		Token:  c.Secret("CONFIG_SOURCE_TOKEN"),
+		Dir:    c.ConfigSourceDir,
+	}
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 密钥引用的前缀：配置项的值为 file:/run/secrets/wechat 或 vault:kv/logai#ai_api_key 时，
+// 加载配置时从文件或 Vault 读取实际的值，避免密钥以明文出现在环境变量中
+const (
+	secretFilePrefix  = "file:"
+	secretVaultPrefix = "vault:"
+)
+
+// 读取 Vault 的超时时间
+const vaultTimeout = 10 * time.Second
+
+// SecretEnvVars 可以使用密钥引用的配置项
+var SecretEnvVars = []string{
+	"AI_API_KEY",
+	"AI_FALLBACK_API_KEY",
+	"AI_TRIAGE_API_KEY",
+	"SIDECAR_TOKEN",
+	"AI_WECHAT_WEBHOOK",
+	"PG_DSN",
+	"KAFKA_PASSWORD",
+	"TICKET_TOKEN",
+	"KIBANA_API_KEY",
+	"ONCALL_TOKEN",
+	"SMTP_PASSWORD",
+	"CONFIG_SOURCE_TOKEN",
+	"METRICS_PUSH_PASSWORD",
+	"GRAFANA_TOKEN",
+	"METRICS_AUTH_PASSWORD",
+	"METRICS_AUTH_TOKEN",
+	"ADMIN_GRPC_TOKEN",
+	"COORDINATION_TOKEN",
+	"CRD_TOKEN",
+}
+
+// secretStore 密钥配置项当前的值及其引用，定期重新解析以支持密钥轮换
+type secretStore struct {
+	mu     sync.RWMutex
+	refs   map[string]string // 环境变量 -> 密钥引用，只包含使用引用的配置项
+	values map[string]string // 环境变量 -> 当前的值
+}
+
+// secretFields 返回各密钥配置项对应的字段
+func (c *Config) secretFields() map[string]*string {
+	return map[string]*string{
+		"AI_API_KEY":            &c.AIAPIKey,
+		"AI_FALLBACK_API_KEY":   &c.AIFallbackAPIKey,
+		"AI_TRIAGE_API_KEY":     &c.AITriageAPIKey,
+		"SIDECAR_TOKEN":         &c.SidecarToken,
+		"AI_WECHAT_WEBHOOK":     &c.WeChatWebhook,
+		"PG_DSN":                &c.PGDSN,
+		"KAFKA_PASSWORD":        &c.KafkaPassword,
+		"TICKET_TOKEN":          &c.TicketToken,
+		"KIBANA_API_KEY":        &c.KibanaAPIKey,
+		"ONCALL_TOKEN":          &c.OnCallToken,
+		"SMTP_PASSWORD":         &c.SMTPPassword,
+		"CONFIG_SOURCE_TOKEN":   &c.ConfigSourceToken,
+		"METRICS_PUSH_PASSWORD": &c.MetricsPushPassword,
+		"GRAFANA_TOKEN":         &c.GrafanaToken,
+		"METRICS_AUTH_PASSWORD": &c.MetricsAuthPassword,
+		"METRICS_AUTH_TOKEN":    &c.MetricsAuthToken,
+		"ADMIN_GRPC_TOKEN":      &c.AdminGRPCToken,
+		"COORDINATION_TOKEN":    &c.CoordinationToken,
+		"CRD_TOKEN":             &c.CRDToken,
+	}
+}
+
+// resolveSecrets 解析各密钥配置项中的引用，将字段替换为实际的值；任一引用解析失败时返回错误
+func (c *Config) resolveSecrets() error {
+	c.secrets = &secretStore{refs: make(map[string]string), values: make(map[string]string)}
+	fields := c.secretFields()
+	for _, env := range SecretEnvVars {
+		field := fields[env]
+		if IsSecretRef(*field) {
+			value, err := ResolveSecret(context.Background(), *field)
+			if err != nil {
+				return fmt.Errorf("解析 %s 的密钥引用失败: %w", env, err)
+			}
+			c.secrets.refs[env] = *field
+			*field = value
+		}
+		c.secrets.values[env] = *field
+	}
+	return nil
+}
+
+// Secret 返回密钥配置项当前的值，如 Secret("AI_API_KEY")；使用密钥引用时为最近一次解析的值，
+// 运行中每次使用密钥时应通过 Secret 读取，以便密钥轮换后无需重启
+func (c *Config) Secret(env string) string {
+	if c.secrets == nil {
+		if field, ok := c.secretFields()[env]; ok {
+			return *field
+		}
+		return ""
+	}
+	c.secrets.mu.RLock()
+	defer c.secrets.mu.RUnlock()
+	return c.secrets.values[env]
+}
+
+// HasSecretRefs 返回是否有配置项使用了密钥引用
+func (c *Config) HasSecretRefs() bool {
+	return c.secrets != nil && len(c.secrets.refs) > 0
+}
+
+// RefreshSecrets 重新解析所有密钥引用，返回值发生变化的配置项；
+// 解析失败的配置项继续使用之前的值
+func (c *Config) RefreshSecrets(ctx context.Context) ([]string, error) {
+	if !c.HasSecretRefs() {
+		return nil, nil
+	}
+	var changed []string
+	var errs []error
+	for _, env := range SecretEnvVars {
+		ref, ok := c.secrets.refs[env]
+		if !ok {
+			continue
+		}
+		value, err := ResolveSecret(ctx, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", env, err))
+			continue
+		}
+		c.secrets.mu.Lock()
+		if c.secrets.values[env] != value {
+			c.secrets.values[env] = value
+			changed = append(changed, env)
+		}
+		c.secrets.mu.Unlock()
+	}
+	return changed, errors.Join(errs...)
+}
+
+// IsSecretRef 判断配置值是否为密钥引用
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretFilePrefix) || strings.HasPrefix(value, secretVaultPrefix)
+}
+
+// ResolveSecret 解析密钥引用，不是引用的值原样返回：
+//   - file:/run/secrets/wechat 读取文件内容，去掉末尾的换行
+//   - vault:kv/logai#ai_api_key 读取 Vault 中 kv/logai 的 ai_api_key 字段，
+//     Vault 地址和令牌取自 VAULT_ADDR、VAULT_TOKEN（令牌本身也可以是 file: 引用）
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretFilePrefix):
+		return readSecretFile(strings.TrimPrefix(value, secretFilePrefix))
+	case strings.HasPrefix(value, secretVaultPrefix):
+		return readVaultSecret(ctx, strings.TrimPrefix(value, secretVaultPrefix))
+	default:
+		return value, nil
+	}
+}
+
+// readSecretFile 读取密钥文件，去掉末尾的换行
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// readVaultSecret 读取 Vault KV 引擎中的密钥，ref 为 路径#字段；
+// 先按 KV v2 读取（挂载点后插入 data/），不存在时再按 KV v1 读取
+func readVaultSecret(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("Vault 密钥引用应为 vault:路径#字段: %s", ref)
+	}
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("使用 Vault 密钥引用时必须配置 VAULT_ADDR")
+	}
+	token, err := ResolveSecret(ctx, os.Getenv("VAULT_TOKEN"))
+	if err != nil {
+		return "", fmt.Errorf("读取 VAULT_TOKEN 失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, vaultTimeout)
+	defer cancel()
+	mount, rest, _ := strings.Cut(path, "/")
+	data, err := getVaultData(ctx, addr+"/v1/"+mount+"/data/"+rest, token)
+	if err == nil {
+		var v2 struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(data, &v2); err != nil {
+			return "", fmt.Errorf("解析 Vault 响应失败: %w", err)
+		}
+		return vaultField(v2.Data, path, field)
+	}
+	if !errors.Is(err, errVaultNotFound) {
+		return "", err
+	}
+	data, err = getVaultData(ctx, addr+"/v1/"+path, token)
+	if err != nil {
+		return "", err
+	}
+	var v1 map[string]any
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return "", fmt.Errorf("解析 Vault 响应失败: %w", err)
+	}
+	return vaultField(v1, path, field)
+}
+
+// errVaultNotFound Vault 中不存在该路径
+var errVaultNotFound = errors.New("Vault 中不存在该路径")
+
+// getVaultData 请求 Vault 接口，返回响应中的 data 字段
+func getVaultData(ctx context.Context, url, token string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Vault 请求失败: %w", err)
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Vault 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errVaultNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("Vault 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析 Vault 响应失败: %w", err)
+	}
+	return result.Data, nil
+}
+
+// vaultField 取出密钥中的字段，字段须为字符串
+func vaultField(data map[string]any, path, field string) (string, error) {
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("Vault 路径 %s 中没有字段 %s", path, field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("Vault 路径 %s 的字段 %s 不是字符串", path, field)
+	}
+	return s, nil
+}
@@ -0,0 +1,267 @@
+// Package coord 协调多个实例对同一批日志文件的采集：多个副本挂载同一个日志目录（如NFS）时，
+// 每个日志文件作为一个分片，由一个实例认领后采集，实例失联后其分片由其他实例接管
+package coord
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/etcd"
+	"log-ai-analyzer/metrics"
+)
+
+// 退出时释放分片和成员租约的超时时间
+const resignTimeout = 5 * time.Second
+
+// Options 协调后端的连接参数
+type Options struct {
+	Kind      string        // etcd 或 kubernetes
+	URL       string        // etcd 为 HTTP 网关地址，如 http://etcd:2379；kubernetes 为 API Server 地址，为空时使用集群内配置
+	Prefix    string        // etcd 键的前缀或 Lease 对象名称的前缀
+	Token     func() string // etcd 认证令牌或 Kubernetes 令牌，返回空时 kubernetes 使用 ServiceAccount 令牌
+	Namespace string        // Lease 所在的命名空间，为空时使用 Pod 所在的命名空间
+	Identity  string        // 本实例的标识，各实例必须不同
+	TTL       time.Duration // 租约有效期，实例失联超过该时间后其分片由其他实例接管
+}
+
+// backend 协调后端：实例以带有效期的租约登记为成员，并以租约认领分片
+type backend interface {
+	// heartbeat 登记或续约本实例的成员租约，返回 false 表示之前的租约已过期，本实例认领的分片可能已被其他实例接管
+	heartbeat(ctx context.Context) (bool, error)
+	// members 返回租约未过期的实例标识
+	members(ctx context.Context) ([]string, error)
+	// acquire 认领或续约分片，分片由其他实例持有时返回 false
+	acquire(ctx context.Context, shard string) (bool, error)
+	// release 释放本实例持有的分片
+	release(ctx context.Context, shard string) error
+	// resign 注销本实例的成员租约
+	resign(ctx context.Context) error
+}
+
+// Coordinator 按租约认领日志文件分片：各实例按存活成员对每个文件做一致的哈希分配，
+// 只认领分配给自己的文件；成员变化时释放不再分配给自己的文件，由新的负责实例认领
+type Coordinator struct {
+	backend  backend
+	identity string
+	ttl      time.Duration
+
+	mu    sync.RWMutex         // 采集期间持有读锁，释放分片时持有写锁，保证释放后本实例不再读取该文件
+	owned map[string]time.Time // 认领的文件 -> 租约在此之前一定有效，续约失败时到期后不再采集该文件
+	files []string             // 参与分配的日志文件
+}
+
+// New 创建文件分片协调器，Kind 为空时返回nil表示不协调，每个实例采集所有文件
+func New(opts Options, files []string) (*Coordinator, error) {
+	if opts.Kind == "" {
+		return nil, nil
+	}
+	if opts.Identity == "" {
+		return nil, fmt.Errorf("多实例协调需要实例标识")
+	}
+	if opts.Token == nil {
+		opts.Token = func() string { return "" }
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	var b backend
+	switch opts.Kind {
+	case "etcd":
+		if opts.URL == "" {
+			return nil, fmt.Errorf("使用 etcd 协调时必须配置地址")
+		}
+		if opts.Prefix == "" {
+			opts.Prefix = "logai/coord/"
+		}
+		b = &etcdBackend{client: etcd.NewClient(opts.URL, opts.Token, opts.TTL/3), prefix: opts.Prefix, identity: opts.Identity, ttl: opts.TTL}
+	case "kubernetes":
+		if opts.Prefix == "" {
+			opts.Prefix = "logai"
+		}
+		k, err := newKubeBackend(opts)
+		if err != nil {
+			return nil, err
+		}
+		b = k
+	default:
+		return nil, fmt.Errorf("不支持的协调后端: %s", opts.Kind)
+	}
+	return &Coordinator{backend: b, identity: opts.Identity, ttl: opts.TTL, files: files, owned: make(map[string]time.Time)}, nil
+}
+
+// Run 定期续约并按存活成员重新分配文件，直到 ctx 取消；退出时释放认领的文件并注销成员租约，其他实例立即接管
+func (c *Coordinator) Run(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	interval := c.ttl / 3
+	for {
+		if err := c.rebalance(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("多实例协调失败: %v", err)
+			metrics.CoordinationErrorCount.Inc()
+		}
+		select {
+		case <-ctx.Done():
+			c.resign()
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// rebalance 续约成员租约，按当前存活成员计算应由本实例采集的文件，释放多余的文件并认领缺少的文件
+func (c *Coordinator) rebalance(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.ttl/3)
+	defer cancel()
+	renewed := time.Now()
+	alive, err := c.backend.heartbeat(ctx)
+	if err != nil {
+		return err
+	}
+	if !alive {
+		c.mu.Lock()
+		if len(c.owned) > 0 {
+			log.Printf("⚠️ 成员租约已过期，放弃认领的 %d 个日志文件", len(c.owned))
+		}
+		c.owned = make(map[string]time.Time)
+		c.mu.Unlock()
+	}
+	members, err := c.backend.members(ctx)
+	if err != nil {
+		return err
+	}
+	// 刚登记的成员可能还未出现在列表中
+	if !slices.Contains(members, c.identity) {
+		members = append(members, c.identity)
+	}
+	metrics.CoordinationMembers.Set(float64(len(members)))
+
+	c.mu.RLock()
+	files := c.files
+	c.mu.RUnlock()
+	want := make(map[string]bool)
+	for _, file := range files {
+		if Owner(file, members) == c.identity {
+			want[file] = true
+		}
+	}
+
+	// 先释放不再分配给本实例的文件，写锁保证释放时没有正在进行的采集
+	var firstErr error
+	c.mu.Lock()
+	for file := range c.owned {
+		if want[file] {
+			continue
+		}
+		// 释放失败时不再采集该文件，下一轮重试释放
+		if err := c.backend.release(ctx, shardID(file)); err != nil {
+			c.owned[file] = time.Time{}
+			firstErr = err
+			continue
+		}
+		delete(c.owned, file)
+		log.Printf("日志文件 %s 已交给其他实例采集", file)
+	}
+	c.mu.Unlock()
+
+	for _, file := range files {
+		if !want[file] {
+			continue
+		}
+		// 续约出错时保留原有效期，到期前仍可采集，之后暂停采集直到认领成功
+		ok, err := c.backend.acquire(ctx, shardID(file))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		c.mu.Lock()
+		if _, held := c.owned[file]; ok && !held {
+			log.Printf("✅ 已认领日志文件 %s", file)
+		}
+		if ok {
+			c.owned[file] = renewed.Add(c.ttl * 2 / 3)
+		} else {
+			// 分片仍由之前的实例持有，等它释放或租约过期后再认领
+			delete(c.owned, file)
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.RLock()
+	metrics.CoordinationOwnedFiles.Set(float64(len(c.owned)))
+	c.mu.RUnlock()
+	return firstErr
+}
+
+// resign 释放认领的文件并注销成员租约
+func (c *Coordinator) resign() {
+	ctx, cancel := context.WithTimeout(context.Background(), resignTimeout)
+	defer cancel()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for file := range c.owned {
+		if err := c.backend.release(ctx, shardID(file)); err != nil {
+			log.Printf("释放日志文件 %s 失败: %v", file, err)
+		}
+	}
+	c.owned = make(map[string]time.Time)
+	metrics.CoordinationOwnedFiles.Set(0)
+	if err := c.backend.resign(ctx); err != nil {
+		log.Printf("注销成员租约失败: %v", err)
+	}
+}
+
+// SetFiles 替换参与分配的日志文件，采集目标动态变化时调用，下一轮续约时重新分配
+func (c *Coordinator) SetFiles(files []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = files
+}
+
+// Hold 返回 files 中由本实例认领的文件，并在调用 done 之前保持认领，期间不会把这些文件交给其他实例；
+// 租约未能按时续约的文件不返回。协调器为nil时原样返回 files
+func (c *Coordinator) Hold(files []string) (owned []string, done func()) {
+	if c == nil {
+		return files, func() {}
+	}
+	c.mu.RLock()
+	now := time.Now()
+	for _, file := range files {
+		if now.Before(c.owned[file]) {
+			owned = append(owned, file)
+		}
+	}
+	return owned, c.mu.RUnlock
+}
+
+// Owner 按最高随机权重（rendezvous）哈希返回负责采集 file 的实例：
+// 成员变化时只有涉及的实例的文件需要重新分配，各实例在成员列表一致时得到相同的结果
+func Owner(file string, members []string) string {
+	var owner string
+	var best uint64
+	for _, m := range members {
+		sum := sha1.Sum([]byte(m + "\x00" + file))
+		score := binary.BigEndian.Uint64(sum[:8])
+		if owner == "" || score > best || (score == best && m < owner) {
+			owner, best = m, score
+		}
+	}
+	return owner
+}
+
+// shardID 返回文件分片的标识，用作 etcd 键名和 Lease 对象名称的一部分
+func shardID(file string) string {
+	sum := sha1.Sum([]byte(file))
+	return hex.EncodeToString(sum[:8])
+}
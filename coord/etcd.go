@@ -0,0 +1,147 @@
+package coord
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/etcd"
+)
+
+// etcdBackend 通过 etcd v3 的 HTTP/JSON 网关协调：本实例的成员键和认领的分片键都绑定到同一个租约，
+// 实例失联时租约过期，这些键一起删除，其他实例随即可以认领
+type etcdBackend struct {
+	client   *etcd.Client
+	prefix   string
+	identity string
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	lease string // 当前租约的ID，为空表示尚未申请
+}
+
+// heartbeat 续约租约，租约已过期时申请新的租约并重新登记成员键
+func (e *etcdBackend) heartbeat(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lease != "" {
+		var r struct {
+			Result struct {
+				TTL string `json:"TTL"`
+			} `json:"result"`
+		}
+		if err := e.client.Do(ctx, "/v3/lease/keepalive", map[string]string{"ID": e.lease}, &r); err != nil {
+			return false, err
+		}
+		if ttl, _ := strconv.ParseInt(r.Result.TTL, 10, 64); ttl > 0 {
+			return true, nil
+		}
+	}
+	// 首次启动或租约已过期：申请新的租约
+	expired := e.lease != ""
+	var grant struct {
+		ID string `json:"ID"`
+	}
+	if err := e.client.Do(ctx, "/v3/lease/grant", map[string]string{"TTL": strconv.Itoa(int(e.ttl.Seconds()))}, &grant); err != nil {
+		return false, err
+	}
+	e.lease = grant.ID
+	put := map[string]string{"key": etcd.Encode(e.memberKey()), "value": etcd.Encode(e.identity), "lease": e.lease}
+	if err := e.client.Do(ctx, "/v3/kv/put", put, nil); err != nil {
+		return false, err
+	}
+	return !expired, nil
+}
+
+// members 返回登记了成员键的实例
+func (e *etcdBackend) members(ctx context.Context) ([]string, error) {
+	prefix := e.prefix + "members/"
+	body := map[string]string{"key": etcd.Encode(prefix), "range_end": etcd.Encode(etcd.PrefixEnd(prefix))}
+	var r struct {
+		Kvs []etcd.KV `json:"kvs"`
+	}
+	if err := e.client.Do(ctx, "/v3/kv/range", body, &r); err != nil {
+		return nil, err
+	}
+	members := make([]string, 0, len(r.Kvs))
+	for _, kv := range r.Kvs {
+		value, err := etcd.Decode(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("解析 etcd 成员键失败: %w", err)
+		}
+		members = append(members, value)
+	}
+	return members, nil
+}
+
+// acquire 分片键不存在时以本实例的租约创建，已存在时判断是否由本实例的当前租约持有
+func (e *etcdBackend) acquire(ctx context.Context, shard string) (bool, error) {
+	e.mu.Lock()
+	lease := e.lease
+	e.mu.Unlock()
+	if lease == "" {
+		return false, nil
+	}
+	key := etcd.Encode(e.shardKey(shard))
+	body := map[string]any{
+		"compare": []map[string]string{{"key": key, "target": "CREATE", "result": "EQUAL", "create_revision": "0"}},
+		"success": []map[string]any{{"request_put": map[string]string{"key": key, "value": etcd.Encode(e.identity), "lease": lease}}},
+		"failure": []map[string]any{{"request_range": map[string]string{"key": key}}},
+	}
+	var r struct {
+		Succeeded bool `json:"succeeded"`
+		Responses []struct {
+			ResponseRange struct {
+				Kvs []etcd.KV `json:"kvs"`
+			} `json:"response_range"`
+		} `json:"responses"`
+	}
+	if err := e.client.Do(ctx, "/v3/kv/txn", body, &r); err != nil {
+		return false, err
+	}
+	if r.Succeeded {
+		return true, nil
+	}
+	for _, resp := range r.Responses {
+		for _, kv := range resp.ResponseRange.Kvs {
+			if kv.Value == etcd.Encode(e.identity) && kv.Lease == lease {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// release 分片键由本实例持有时删除
+func (e *etcdBackend) release(ctx context.Context, shard string) error {
+	key := etcd.Encode(e.shardKey(shard))
+	body := map[string]any{
+		"compare": []map[string]string{{"key": key, "target": "VALUE", "result": "EQUAL", "value": etcd.Encode(e.identity)}},
+		"success": []map[string]any{{"request_delete_range": map[string]string{"key": key}}},
+	}
+	return e.client.Do(ctx, "/v3/kv/txn", body, nil)
+}
+
+// resign 撤销租约，成员键和仍持有的分片键随之删除
+func (e *etcdBackend) resign(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.lease == "" {
+		return nil
+	}
+	err := e.client.Do(ctx, "/v3/lease/revoke", map[string]string{"ID": e.lease}, nil)
+	e.lease = ""
+	return err
+}
+
+// memberKey 返回本实例的成员键
+func (e *etcdBackend) memberKey() string {
+	return e.prefix + "members/" + e.identity
+}
+
+// shardKey 返回分片键
+func (e *etcdBackend) shardKey(shard string) string {
+	return e.prefix + "shards/" + shard
+}
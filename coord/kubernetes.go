@@ -0,0 +1,205 @@
+package coord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/kube"
+)
+
+// Lease 接口的路径
+const leasesPath = "/apis/coordination.k8s.io/v1/namespaces/%s/leases"
+
+// Lease 的 renewTime、acquireTime 为微秒精度的 RFC3339 时间
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// 成员 Lease 的标签，用于按前缀列出存活的实例
+const (
+	labelGroup = "log-ai-analyzer/group"
+	labelRole  = "log-ai-analyzer/role"
+)
+
+// kubeLease coordination.k8s.io/v1 Lease 对象中用到的字段
+type kubeLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace,omitempty"`
+		ResourceVersion string            `json:"resourceVersion,omitempty"`
+		Labels          map[string]string `json:"labels,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// expired 判断 Lease 是否无人持有或已超过有效期未续约
+func (l *kubeLease) expired(now time.Time) bool {
+	if l.Spec.HolderIdentity == "" {
+		return true
+	}
+	renewed, err := time.Parse(time.RFC3339Nano, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(l.Spec.LeaseDurationSeconds) * time.Second))
+}
+
+// kubeBackend 通过 Kubernetes 的 Lease 对象协调：每个实例一个成员 Lease，每个文件分片一个 Lease，
+// 持有者按有效期续约，超过有效期未续约的 Lease 可以被其他实例接管
+type kubeBackend struct {
+	client    *kube.Client
+	namespace string
+	prefix    string
+	identity  string
+	ttl       time.Duration
+
+	mu          sync.Mutex
+	lastRenewed time.Time // 成员 Lease 最近一次续约成功的时间
+}
+
+// newKubeBackend 创建 Kubernetes Lease 协调后端，未配置地址、令牌或命名空间时使用集群内 ServiceAccount 的配置
+func newKubeBackend(opts Options) (*kubeBackend, error) {
+	client, err := kube.NewClient(opts.URL, opts.Token)
+	if err != nil {
+		return nil, err
+	}
+	k := &kubeBackend{client: client, namespace: opts.Namespace, prefix: opts.Prefix, identity: opts.Identity, ttl: opts.TTL}
+	if k.namespace == "" {
+		if k.namespace, err = kube.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// heartbeat 续约本实例的成员 Lease，距上次续约成功已超过有效期时返回 false
+func (k *kubeBackend) heartbeat(ctx context.Context) (bool, error) {
+	now := time.Now()
+	ok, err := k.renew(ctx, k.memberName(), map[string]string{labelGroup: k.prefix, labelRole: "member"})
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, fmt.Errorf("成员 Lease %s 由其他实例持有，请检查 INSTANCE_ID 是否重复", k.memberName())
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	alive := k.lastRenewed.IsZero() || now.Sub(k.lastRenewed) < k.ttl
+	k.lastRenewed = now
+	return alive, nil
+}
+
+// members 列出同一前缀下未过期的成员 Lease 的持有者
+func (k *kubeBackend) members(ctx context.Context) ([]string, error) {
+	selector := fmt.Sprintf("%s=%s,%s=member", labelGroup, k.prefix, labelRole)
+	var list struct {
+		Items []kubeLease `json:"items"`
+	}
+	path := fmt.Sprintf(leasesPath, k.namespace) + "?labelSelector=" + url.QueryEscape(selector)
+	if err := k.client.Do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var members []string
+	for i := range list.Items {
+		if !list.Items[i].expired(now) {
+			members = append(members, list.Items[i].Spec.HolderIdentity)
+		}
+	}
+	return members, nil
+}
+
+// acquire 认领或续约分片 Lease
+func (k *kubeBackend) acquire(ctx context.Context, shard string) (bool, error) {
+	return k.renew(ctx, k.prefix+"-shard-"+shard, map[string]string{labelGroup: k.prefix, labelRole: "shard"})
+}
+
+// release 分片 Lease 由本实例持有时删除
+func (k *kubeBackend) release(ctx context.Context, shard string) error {
+	return k.remove(ctx, k.prefix+"-shard-"+shard)
+}
+
+// resign 删除本实例的成员 Lease
+func (k *kubeBackend) resign(ctx context.Context) error {
+	return k.remove(ctx, k.memberName())
+}
+
+// memberName 返回本实例的成员 Lease 名称，实例标识可能含有 Lease 名称不允许的字符，取其哈希
+func (k *kubeBackend) memberName() string {
+	return k.prefix + "-member-" + shardID(k.identity)
+}
+
+// renew 创建 Lease，或在 Lease 由本实例持有、已过期时以本实例续约；Lease 由其他实例持有时返回 false
+func (k *kubeBackend) renew(ctx context.Context, name string, labels map[string]string) (bool, error) {
+	now := time.Now()
+	path := fmt.Sprintf(leasesPath, k.namespace)
+	var lease kubeLease
+	err := k.client.Do(ctx, http.MethodGet, path+"/"+name, nil, &lease)
+	if errors.Is(err, kube.ErrNotFound) {
+		lease = kubeLease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		lease.Metadata.Name = name
+		lease.Metadata.Labels = labels
+		lease.Spec.HolderIdentity = k.identity
+		lease.Spec.LeaseDurationSeconds = int(k.ttl.Seconds())
+		lease.Spec.AcquireTime = now.UTC().Format(microTime)
+		lease.Spec.RenewTime = lease.Spec.AcquireTime
+		err = k.client.Do(ctx, http.MethodPost, path, &lease, nil)
+		if errors.Is(err, kube.ErrConflict) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if lease.Spec.HolderIdentity != k.identity && !lease.expired(now) {
+		return false, nil
+	}
+	if lease.Spec.HolderIdentity != k.identity {
+		lease.Spec.HolderIdentity = k.identity
+		lease.Spec.AcquireTime = now.UTC().Format(microTime)
+		lease.Spec.LeaseTransitions++
+	}
+	lease.Spec.LeaseDurationSeconds = int(k.ttl.Seconds())
+	lease.Spec.RenewTime = now.UTC().Format(microTime)
+	// 带着读取时的 resourceVersion 更新，期间被其他实例修改时返回冲突
+	err = k.client.Do(ctx, http.MethodPut, path+"/"+name, &lease, nil)
+	if errors.Is(err, kube.ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// remove Lease 由本实例持有时删除，删除前 Lease 被其他实例修改时不删除
+func (k *kubeBackend) remove(ctx context.Context, name string) error {
+	path := fmt.Sprintf(leasesPath, k.namespace) + "/" + name
+	var lease kubeLease
+	err := k.client.Do(ctx, http.MethodGet, path, nil, &lease)
+	if errors.Is(err, kube.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity != k.identity {
+		return nil
+	}
+	body := map[string]any{"preconditions": map[string]string{"resourceVersion": lease.Metadata.ResourceVersion}}
+	err = k.client.Do(ctx, http.MethodDelete, path, body, nil)
+	if errors.Is(err, kube.ErrNotFound) || errors.Is(err, kube.ErrConflict) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,428 @@
+// Package crd 监听 Kubernetes 自定义资源（logai.io/v1alpha1 的 LogTarget、AlertRoute、Silence），
+// 使采集目标、告警路由和静默可以像其他 Kubernetes 资源一样由 GitOps 声明式管理，资源变化后立即生效
+package crd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/kube"
+	"log-ai-analyzer/metrics"
+)
+
+// 自定义资源的 API 组和版本
+const (
+	Group   = "logai.io"
+	Version = "v1alpha1"
+)
+
+// watch 请求在服务端保持的最长时间，到期后重新发起，期间重新解析引用的 Secret
+const watchTimeout = 5 * time.Minute
+
+// 列出或监听失败后重试的间隔
+const retryInterval = 5 * time.Second
+
+// resource 监听的自定义资源
+type resource struct {
+	kind   string
+	plural string
+}
+
+// 监听的自定义资源
+var resources = []resource{
+	{kind: "LogTarget", plural: "logtargets"},
+	{kind: "AlertRoute", plural: "alertroutes"},
+	{kind: "Silence", plural: "silences"},
+}
+
+// objectMeta 自定义资源的元数据
+type objectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// logTarget LogTarget 资源：需要采集的日志文件
+type logTarget struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Paths    []string `json:"paths"`
+		Schedule string   `json:"schedule,omitempty"` // 采集时间表：时长或 cron 表达式，为空时使用 COLLECT_INTERVAL
+	} `json:"spec"`
+}
+
+// alertRoute AlertRoute 资源：满足条件的告警发送到指定的企业微信机器人
+type alertRoute struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		Match struct {
+			Tenants     []string `json:"tenants,omitempty"`
+			Services    []string `json:"services,omitempty"`
+			Teams       []string `json:"teams,omitempty"`
+			Hosts       []string `json:"hosts,omitempty"`
+			Files       []string `json:"files,omitempty"`
+			MinSeverity int      `json:"minSeverity,omitempty"`
+		} `json:"match"`
+		Webhook          string     `json:"webhook,omitempty"`
+		WebhookSecretRef *secretRef `json:"webhookSecretRef,omitempty"`
+		Mentions         []string   `json:"mentions,omitempty"`
+	} `json:"spec"`
+}
+
+// secretRef 同一命名空间中 Secret 的一个键
+type secretRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// silence Silence 资源：日志模板的告警在到期前不发送
+type silence struct {
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		TemplateID string    `json:"templateID"`
+		Reason     string    `json:"reason,omitempty"`
+		ExpiresAt  time.Time `json:"expiresAt,omitempty"`
+	} `json:"spec"`
+}
+
+// Target 一个 LogTarget 声明的采集目标
+type Target struct {
+	Name     string // 命名空间/名称
+	Paths    []string
+	Schedule string
+}
+
+// State 自定义资源当前声明的配置
+type State struct {
+	Targets  []Target
+	Routes   []alert.Route // 按命名空间/名称排序
+	Silences []alert.Silence
+}
+
+// Options 自定义资源监听的连接参数
+type Options struct {
+	APIURL    string        // API Server 地址，为空时使用集群内配置
+	Token     func() string // 访问令牌，返回空时使用 ServiceAccount 令牌
+	Namespace string        // 监听的命名空间，为空时使用 Pod 所在的命名空间，* 表示所有命名空间
+}
+
+// Watcher 监听自定义资源，资源变化时重新生成配置
+type Watcher struct {
+	client    *kube.Client
+	namespace string // 为空表示所有命名空间
+
+	mu      sync.Mutex
+	objects map[string]map[string]json.RawMessage // 资源复数名 -> 命名空间/名称 -> 对象
+
+	notifyMu sync.Mutex // 各资源的监听协程依次生成配置并通知，后生成的配置不会先于之前的配置送达
+}
+
+// New 创建自定义资源监听
+func New(opts Options) (*Watcher, error) {
+	client, err := kube.NewClient(opts.APIURL, opts.Token)
+	if err != nil {
+		return nil, err
+	}
+	namespace := opts.Namespace
+	switch namespace {
+	case "*":
+		namespace = ""
+	case "":
+		if namespace, err = kube.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	return &Watcher{client: client, namespace: namespace, objects: make(map[string]map[string]json.RawMessage)}, nil
+}
+
+// path 返回资源的列表和监听路径
+func (w *Watcher) path(res resource) string {
+	if w.namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/%s", Group, Version, res.plural)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, w.namespace, res.plural)
+}
+
+// Load 列出所有自定义资源并返回当前声明的配置，启动时调用，之后由 Run 监听变化
+func (w *Watcher) Load(ctx context.Context) (State, map[string]string, error) {
+	versions := make(map[string]string, len(resources))
+	for _, res := range resources {
+		rv, err := w.list(ctx, res)
+		if err != nil {
+			return State{}, nil, err
+		}
+		versions[res.plural] = rv
+	}
+	return w.state(ctx), versions, nil
+}
+
+// Run 从 Load 返回的资源版本开始监听各自定义资源，直到 ctx 取消；每次资源变化时以新的配置调用 onChange
+func (w *Watcher) Run(ctx context.Context, versions map[string]string, onChange func(State)) {
+	var wg sync.WaitGroup
+	for _, res := range resources {
+		wg.Add(1)
+		go func(res resource) {
+			defer wg.Done()
+			w.watch(ctx, res, versions[res.plural], onChange)
+		}(res)
+	}
+	wg.Wait()
+}
+
+// watch 监听一种自定义资源；资源版本过期时重新列出，连接断开时从最后的资源版本继续
+func (w *Watcher) watch(ctx context.Context, res resource, rv string, onChange func(State)) {
+	for ctx.Err() == nil {
+		var err error
+		if rv == "" {
+			if rv, err = w.list(ctx, res); err != nil {
+				w.fail(ctx, res, err)
+				continue
+			}
+			w.notify(ctx, onChange)
+		}
+		rv, err = w.stream(ctx, res, rv, onChange)
+		if errors.Is(err, kube.ErrGone) {
+			rv = ""
+			continue
+		}
+		if err != nil {
+			w.fail(ctx, res, err)
+			continue
+		}
+		// 连接按 watchTimeout 正常结束，重新生成配置以更新引用的 Secret
+		w.notify(ctx, onChange)
+	}
+}
+
+// fail 记录监听失败并等待重试
+func (w *Watcher) fail(ctx context.Context, res resource, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	log.Printf("监听 %s 资源失败: %v", res.kind, err)
+	metrics.CRDWatchErrorCount.Inc()
+	select {
+	case <-ctx.Done():
+	case <-time.After(retryInterval):
+	}
+}
+
+// list 列出一种自定义资源，替换保存的对象，返回列表的资源版本
+func (w *Watcher) list(ctx context.Context, res resource) (string, error) {
+	var list struct {
+		Metadata objectMeta        `json:"metadata"`
+		Items    []json.RawMessage `json:"items"`
+	}
+	if err := w.client.Do(ctx, http.MethodGet, w.path(res), nil, &list); err != nil {
+		if errors.Is(err, kube.ErrNotFound) {
+			return "", fmt.Errorf("未安装 %s.%s 自定义资源定义", res.plural, Group)
+		}
+		return "", fmt.Errorf("列出 %s 资源失败: %w", res.kind, err)
+	}
+	objects := make(map[string]json.RawMessage, len(list.Items))
+	for _, item := range list.Items {
+		var obj struct {
+			Metadata objectMeta `json:"metadata"`
+		}
+		if err := json.Unmarshal(item, &obj); err != nil {
+			return "", fmt.Errorf("解析 %s 资源失败: %w", res.kind, err)
+		}
+		objects[obj.Metadata.Namespace+"/"+obj.Metadata.Name] = item
+	}
+	w.mu.Lock()
+	w.objects[res.plural] = objects
+	w.mu.Unlock()
+	metrics.CRDResources.WithLabelValues(res.kind).Set(float64(len(objects)))
+	return list.Metadata.ResourceVersion, nil
+}
+
+// stream 从资源版本 rv 开始监听，逐条应用变更事件，返回最后的资源版本
+func (w *Watcher) stream(ctx context.Context, res resource, rv string, onChange func(State)) (string, error) {
+	query := url.Values{}
+	query.Set("watch", "1")
+	query.Set("resourceVersion", rv)
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", fmt.Sprint(int(watchTimeout.Seconds())))
+	body, err := w.client.Stream(ctx, w.path(res)+"?"+query.Encode())
+	if err != nil {
+		return rv, err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return rv, ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return rv, nil
+			}
+			return rv, fmt.Errorf("读取 %s 资源变更失败: %w", res.kind, err)
+		}
+		var obj struct {
+			Metadata objectMeta `json:"metadata"`
+			Code     int        `json:"code"` // ERROR 事件的对象为 Status
+			Message  string     `json:"message"`
+		}
+		if err := json.Unmarshal(event.Object, &obj); err != nil {
+			return rv, fmt.Errorf("解析 %s 资源变更失败: %w", res.kind, err)
+		}
+		switch event.Type {
+		case "ERROR":
+			if obj.Code == 410 {
+				return rv, kube.ErrGone
+			}
+			return rv, fmt.Errorf("监听 %s 资源出错: %s", res.kind, obj.Message)
+		case "BOOKMARK":
+			rv = obj.Metadata.ResourceVersion
+			continue
+		}
+		rv = obj.Metadata.ResourceVersion
+		key := obj.Metadata.Namespace + "/" + obj.Metadata.Name
+		w.mu.Lock()
+		if event.Type == "DELETED" {
+			delete(w.objects[res.plural], key)
+		} else {
+			w.objects[res.plural][key] = event.Object
+		}
+		n := len(w.objects[res.plural])
+		w.mu.Unlock()
+		metrics.CRDResources.WithLabelValues(res.kind).Set(float64(n))
+		log.Printf("🔄 %s %s 已%s", res.kind, key, eventAction(event.Type))
+		w.notify(ctx, onChange)
+	}
+}
+
+// notify 根据当前保存的对象生成配置并调用 onChange
+func (w *Watcher) notify(ctx context.Context, onChange func(State)) {
+	w.notifyMu.Lock()
+	defer w.notifyMu.Unlock()
+	onChange(w.state(ctx))
+}
+
+// eventAction 返回变更事件类型的说明
+func eventAction(eventType string) string {
+	switch eventType {
+	case "ADDED":
+		return "创建"
+	case "DELETED":
+		return "删除"
+	}
+	return "更新"
+}
+
+// sortedObjects 返回一种资源按命名空间/名称排序的对象
+func (w *Watcher) sortedObjects(plural string) []json.RawMessage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	keys := make([]string, 0, len(w.objects[plural]))
+	for key := range w.objects[plural] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	objects := make([]json.RawMessage, len(keys))
+	for i, key := range keys {
+		objects[i] = w.objects[plural][key]
+	}
+	return objects
+}
+
+// state 根据保存的对象生成配置，无效的资源记录日志后跳过
+func (w *Watcher) state(ctx context.Context) State {
+	var state State
+	for _, raw := range w.sortedObjects("logtargets") {
+		var t logTarget
+		if err := json.Unmarshal(raw, &t); err != nil || len(t.Spec.Paths) == 0 {
+			log.Printf("忽略无效的 LogTarget %s: 缺少 paths 或格式错误", t.Metadata.Name)
+			continue
+		}
+		state.Targets = append(state.Targets, Target{
+			Name:     t.Metadata.Namespace + "/" + t.Metadata.Name,
+			Paths:    t.Spec.Paths,
+			Schedule: t.Spec.Schedule,
+		})
+	}
+	for _, raw := range w.sortedObjects("alertroutes") {
+		var r alertRoute
+		if err := json.Unmarshal(raw, &r); err != nil {
+			log.Printf("忽略无效的 AlertRoute: %v", err)
+			continue
+		}
+		name := r.Metadata.Namespace + "/" + r.Metadata.Name
+		webhook := r.Spec.Webhook
+		if r.Spec.WebhookSecretRef != nil {
+			value, err := w.secret(ctx, r.Metadata.Namespace, *r.Spec.WebhookSecretRef)
+			if err != nil {
+				log.Printf("忽略 AlertRoute %s: %v", name, err)
+				continue
+			}
+			webhook = value
+		}
+		if webhook == "" {
+			log.Printf("忽略 AlertRoute %s: 缺少 webhook 或 webhookSecretRef", name)
+			continue
+		}
+		m := r.Spec.Match
+		state.Routes = append(state.Routes, alert.Route{
+			Name:        name,
+			Tenants:     m.Tenants,
+			Services:    m.Services,
+			Teams:       m.Teams,
+			Hosts:       m.Hosts,
+			Files:       m.Files,
+			MinSeverity: m.MinSeverity,
+			Webhook:     webhook,
+			Mentions:    r.Spec.Mentions,
+		})
+	}
+	for _, raw := range w.sortedObjects("silences") {
+		var s silence
+		if err := json.Unmarshal(raw, &s); err != nil || s.Spec.TemplateID == "" {
+			log.Printf("忽略无效的 Silence %s: 缺少 templateID 或格式错误", s.Metadata.Name)
+			continue
+		}
+		state.Silences = append(state.Silences, alert.Silence{
+			TemplateID: s.Spec.TemplateID,
+			Reason:     s.Spec.Reason,
+			ExpiresAt:  s.Spec.ExpiresAt,
+		})
+	}
+	return state
+}
+
+// secret 读取 Secret 中的一个键
+func (w *Watcher) secret(ctx context.Context, namespace string, ref secretRef) (string, error) {
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", namespace, ref.Name)
+	if err := w.client.Do(ctx, http.MethodGet, path, nil, &secret); err != nil {
+		return "", fmt.Errorf("读取 Secret %s 失败: %w", ref.Name, err)
+	}
+	encoded, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("Secret %s 中没有键 %s", ref.Name, ref.Key)
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("解析 Secret %s 失败: %w", ref.Name, err)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
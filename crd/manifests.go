@@ -0,0 +1,148 @@
+package crd
+
+import "strings"
+
+// object Kubernetes 清单中的一个对象
+type object = map[string]any
+
+// Manifests 返回安装自定义资源定义和监听所需权限的 Kubernetes 清单（kind: List），可直接 kubectl apply；
+// 权限授予 namespace 中名为 serviceAccount 的 ServiceAccount
+func Manifests(namespace, serviceAccount string) object {
+	items := make([]any, 0, len(resources)+2)
+	for _, res := range resources {
+		items = append(items, definition(res))
+	}
+	items = append(items,
+		object{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRole",
+			"metadata":   object{"name": "log-ai-analyzer-crd"},
+			"rules": []any{
+				object{"apiGroups": []string{Group}, "resources": plurals(), "verbs": []string{"get", "list", "watch"}},
+				// AlertRoute 的 webhookSecretRef 引用同一命名空间中的 Secret
+				object{"apiGroups": []string{""}, "resources": []string{"secrets"}, "verbs": []string{"get"}},
+			},
+		},
+		object{
+			"apiVersion": "rbac.authorization.k8s.io/v1",
+			"kind":       "ClusterRoleBinding",
+			"metadata":   object{"name": "log-ai-analyzer-crd"},
+			"roleRef":    object{"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": "log-ai-analyzer-crd"},
+			"subjects":   []any{object{"kind": "ServiceAccount", "name": serviceAccount, "namespace": namespace}},
+		},
+	)
+	return object{"apiVersion": "v1", "kind": "List", "items": items}
+}
+
+// plurals 返回所有自定义资源的复数名
+func plurals() []string {
+	names := make([]string, len(resources))
+	for i, res := range resources {
+		names[i] = res.plural
+	}
+	return names
+}
+
+// definition 返回自定义资源的 CustomResourceDefinition
+func definition(res resource) object {
+	return object{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   object{"name": res.plural + "." + Group},
+		"spec": object{
+			"group": Group,
+			"scope": "Namespaced",
+			"names": object{
+				"kind":     res.kind,
+				"listKind": res.kind + "List",
+				"plural":   res.plural,
+				"singular": strings.ToLower(res.kind),
+			},
+			"versions": []any{object{
+				"name":    Version,
+				"served":  true,
+				"storage": true,
+				"schema": object{"openAPIV3Schema": object{
+					"type": "object",
+					"properties": object{
+						"spec": specSchema(res.kind),
+					},
+				}},
+				"additionalPrinterColumns": printerColumns(res.kind),
+			}},
+		},
+	}
+}
+
+// specSchema 返回自定义资源 spec 的 OpenAPI 结构
+func specSchema(kind string) object {
+	str := object{"type": "string"}
+	strs := object{"type": "array", "items": str}
+	switch kind {
+	case "LogTarget":
+		return object{
+			"type":     "object",
+			"required": []string{"paths"},
+			"properties": object{
+				"paths":    object{"type": "array", "minItems": 1, "items": str, "description": "需要采集的日志文件路径"},
+				"schedule": object{"type": "string", "description": "采集时间表：时长（如 5m）或 cron 表达式，为空时使用 COLLECT_INTERVAL"},
+			},
+		}
+	case "AlertRoute":
+		return object{
+			"type": "object",
+			"properties": object{
+				"match": object{
+					"type": "object",
+					"properties": object{
+						"tenants":     strs,
+						"services":    strs,
+						"teams":       strs,
+						"hosts":       object{"type": "array", "items": str, "description": "主机名通配符"},
+						"files":       object{"type": "array", "items": str, "description": "日志文件路径通配符"},
+						"minSeverity": object{"type": "integer", "minimum": 0, "maximum": 10},
+					},
+				},
+				"webhook": object{"type": "string", "description": "企业微信机器人 Webhook 地址"},
+				"webhookSecretRef": object{
+					"type":       "object",
+					"required":   []string{"name", "key"},
+					"properties": object{"name": str, "key": str},
+				},
+				"mentions": object{"type": "array", "items": str, "description": "额外@的成员ID"},
+			},
+		}
+	default:
+		return object{
+			"type":     "object",
+			"required": []string{"templateID"},
+			"properties": object{
+				"templateID": str,
+				"reason":     str,
+				"expiresAt":  object{"type": "string", "format": "date-time", "description": "到期时间，为空时一直生效"},
+			},
+		}
+	}
+}
+
+// printerColumns 返回 kubectl get 显示的列
+func printerColumns(kind string) []any {
+	switch kind {
+	case "LogTarget":
+		return []any{
+			object{"name": "Schedule", "type": "string", "jsonPath": ".spec.schedule"},
+			object{"name": "Paths", "type": "string", "jsonPath": ".spec.paths"},
+		}
+	case "AlertRoute":
+		return []any{
+			object{"name": "Services", "type": "string", "jsonPath": ".spec.match.services"},
+			object{"name": "MinSeverity", "type": "integer", "jsonPath": ".spec.match.minSeverity"},
+		}
+	default:
+		return []any{
+			object{"name": "Template", "type": "string", "jsonPath": ".spec.templateID"},
+			object{"name": "Expires", "type": "date", "jsonPath": ".spec.expiresAt"},
+			object{"name": "Reason", "type": "string", "jsonPath": ".spec.reason"},
+		}
+	}
+}
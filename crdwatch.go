@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"slices"
+
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/crd"
+)
+
+// logTargets 需要采集的日志文件，以及 LogTarget 资源为文件指定的采集时间表
+type logTargets struct {
+	files     []string
+	schedules map[string]collector.Schedule
+}
+
+// mergeTargets 合并 LOG_FILE_PATHS 和 LogTarget 资源声明的日志文件，同一文件只采集一次；
+// 多个 LogTarget 声明同一文件时第一个的时间表生效，时间表无效时记录日志并按 COLLECT_SCHEDULE 采集
+func mergeTargets(base []string, targets []crd.Target) logTargets {
+	merged := logTargets{files: slices.Clone(base), schedules: make(map[string]collector.Schedule)}
+	for _, target := range targets {
+		var schedule collector.Schedule
+		hasSchedule := false
+		if target.Schedule != "" {
+			s, err := collector.ParseSchedule(target.Schedule)
+			if err != nil {
+				log.Printf("LogTarget %s 的采集时间表无效，使用默认时间表: %v", target.Name, err)
+			} else {
+				schedule, hasSchedule = s, true
+			}
+		}
+		for _, path := range target.Paths {
+			if !slices.Contains(merged.files, path) {
+				merged.files = append(merged.files, path)
+			}
+			if _, ok := merged.schedules[path]; hasSchedule && !ok {
+				merged.schedules[path] = schedule
+			}
+		}
+	}
+	return merged
+}
+
+// applyCRDState 使自定义资源声明的告警路由和静默立即生效，返回合并后的采集目标
+func applyCRDState(cfg *config.Config, state crd.State) logTargets {
+	alert.SetRoutes(state.Routes)
+	alert.SetSilences(state.Silences)
+	return mergeTargets(cfg.LogFiles, state.Targets)
+}
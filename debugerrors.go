@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"log-ai-analyzer/diag"
+)
+
+// debugErrorsResponse /debug/errors 接口的响应
+type debugErrorsResponse struct {
+	Total  uint64       `json:"total"` // 启动以来记录的错误总数，超过保留条数的较早错误已被覆盖
+	Errors []diag.Entry `json:"errors"`
+}
+
+// debugErrorsHandler 提供 GET /debug/errors?component=ai 接口，返回最近的内部错误（最新的在前），
+// component 按组件名前缀过滤，如 sink 匹配所有存储后端
+func debugErrorsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		entries, total := diag.Recent()
+		resp := debugErrorsResponse{Total: total, Errors: entries}
+		if component := r.URL.Query().Get("component"); component != "" {
+			resp.Errors = resp.Errors[:0]
+			for _, e := range entries {
+				if strings.HasPrefix(e.Component, component) {
+					resp.Errors = append(resp.Errors, e)
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,77 @@
+// Package diag 在内存中保留最近的内部错误（采集失败、AI分析失败、存储写入失败等），
+// 通过 /debug/errors 查看，排查偶发问题时无需翻找进程自身的标准输出
+package diag
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultCapacity 默认保留的错误条数
+const defaultCapacity = 100
+
+// Entry 一条内部错误
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Component string    `json:"component"` // 出错的组件，如 collector、ai、sink:elasticsearch
+	Message   string    `json:"message"`
+}
+
+// ring 环形缓冲区，写满后覆盖最早的错误
+type ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+	total   uint64
+}
+
+var errs = &ring{entries: make([]Entry, defaultCapacity)}
+
+// SetCapacity 设置保留的错误条数并清空已记录的错误，n<=0 时不记录
+func SetCapacity(n int) {
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+	errs.entries = make([]Entry, max(n, 0))
+	errs.next, errs.full, errs.total = 0, false, 0
+}
+
+// Record 记录一条内部错误，err 为nil时忽略
+func Record(component string, err error) {
+	if err == nil {
+		return
+	}
+	Recordf(component, "%v", err)
+}
+
+// Recordf 按格式记录一条内部错误
+func Recordf(component, format string, args ...interface{}) {
+	entry := Entry{Time: time.Now(), Component: component, Message: fmt.Sprintf(format, args...)}
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+	if len(errs.entries) == 0 {
+		return
+	}
+	errs.total++
+	errs.entries[errs.next] = entry
+	errs.next++
+	if errs.next == len(errs.entries) {
+		errs.next, errs.full = 0, true
+	}
+}
+
+// Recent 返回保留的错误（最新的在前）以及启动以来记录的错误总数
+func Recent() ([]Entry, uint64) {
+	errs.mu.Lock()
+	defer errs.mu.Unlock()
+	n := errs.next
+	if errs.full {
+		n = len(errs.entries)
+	}
+	result := make([]Entry, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, errs.entries[(errs.next-i+len(errs.entries))%len(errs.entries)])
+	}
+	return result, errs.total
+}
@@ -0,0 +1,66 @@
+package esclient
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"log-ai-analyzer/metrics"
+)
+
+// 告警聚合文档的状态
+const (
+	AlertActive   = "active"   // 告警仍在持续
+	AlertResolved = "resolved" // 超过合并窗口没有新事件，视为已恢复
+)
+
+// AlertDoc 告警聚合文档：同一告警的所有事件合并为一个文档，Kibana 中每个持续的问题只显示一行
+type AlertDoc struct {
+	Key         string         `json:"alert_key"`
+	Host        string         `json:"host"`
+	Tenant      string         `json:"tenant,omitempty"`
+	FilePath    string         `json:"file_path,omitempty"`
+	TemplateID  string         `json:"template_id,omitempty"`
+	Fingerprint string         `json:"fingerprint,omitempty"`
+	Severity    int            `json:"severity_score"` // 合并事件中的最高严重性
+	Count       int            `json:"count"`          // 合并的事件数
+	FirstSeen   time.Time      `json:"first_seen"`
+	LastSeen    time.Time      `json:"@timestamp"` // 最近一次出现的时间，兼容 Kibana 时间字段
+	Content     string         `json:"content"`    // 最近一次事件的日志内容
+	AiResult    string         `json:"ai_result"`  // 最近一次事件的AI分析
+	LastEventID string         `json:"last_event_id"`
+	TicketID    string         `json:"ticket_id,omitempty"`
+	IncidentID  string         `json:"incident_id,omitempty"`    // 告警所属的故障
+	Related     []RelatedEvent `json:"related_events,omitempty"` // 最近一次事件在其他日志文件中的关联事件
+	Status      string         `json:"status"`
+
+	Instance string            `json:"instance,omitempty"` // 产生该告警的分析器实例
+	Labels   map[string]string `json:"labels,omitempty"`   // 实例的静态标签
+}
+
+// alertDocID 由告警键生成文档ID，告警键包含文件路径，长度不固定
+func alertDocID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// UpsertAlert 以告警键为文档ID写入告警聚合文档，覆盖该告警之前的文档。
+// 文档以写入时间为外部版本号，批量写入乱序或重放旧文档时不会覆盖更新的状态
+func (e *ESClient) UpsertAlert(index string, doc AlertDoc) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		metrics.ESWriteErrorCount.WithLabelValues("alert", metrics.SeverityBucket(doc.Severity)).Inc()
+		return fmt.Errorf("编码告警文档失败: %w", err)
+	}
+	return e.write(bulkItem{
+		Index:    index,
+		OpType:   "index",
+		ID:       alertDocID(doc.Key),
+		Version:  time.Now().UnixNano(),
+		Doc:      data,
+		Kind:     "alert",
+		Severity: doc.Severity,
+	})
+}
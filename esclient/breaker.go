@@ -0,0 +1,95 @@
+package esclient
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/metrics"
+)
+
+// ErrCircuitOpen ES连续写入失败，熔断期间不再尝试写入
+var ErrCircuitOpen = errors.New("ES写入熔断中")
+
+// circuitBreaker 写入熔断器：连续失败达到阈值后在冷却时间内直接拒绝写入，
+// 冷却结束后放行请求试探，成功即恢复，失败则重新进入冷却
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// SetCircuitBreaker 启用写入熔断，failures<=0 时不熔断
+func (e *ESClient) SetCircuitBreaker(failures int, cooldown time.Duration) {
+	if failures <= 0 {
+		e.breaker = nil
+		return
+	}
+	e.breaker = &circuitBreaker{threshold: failures, cooldown: cooldown}
+}
+
+// CircuitOpen 判断写入是否处于熔断中
+func (e *ESClient) CircuitOpen() bool {
+	return !e.breaker.allow()
+}
+
+// allow 判断当前是否可以尝试写入，未启用熔断时总是可以
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures < b.threshold || !time.Now().Before(b.openUntil)
+}
+
+// success 记录一次成功的写入，熔断中时恢复
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= b.threshold {
+		log.Printf("✅ ES写入已恢复，解除熔断")
+		metrics.ESCircuitOpen.Set(0)
+	}
+	b.failures = 0
+}
+
+// failure 记录一次失败的写入，连续失败达到阈值时熔断
+func (b *circuitBreaker) failure(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			log.Printf("⚠️ ES连续写入失败 %d 次，熔断 %s: %v", b.failures, b.cooldown, err)
+			metrics.ESCircuitOpen.Set(1)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// outageError 判断写入错误是否说明集群不可用：网络错误、429和5xx，
+// 其余4xx（如映射冲突）是单个文档的问题，不计入熔断也不值得重放
+func outageError(err error) bool {
+	var re *ResponseError
+	if !errors.As(err, &re) {
+		return true
+	}
+	return outageStatus(re.StatusCode)
+}
+
+// outageStatus 判断单个请求或文档的状态码是否说明集群不可用
+func outageStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
@@ -0,0 +1,322 @@
+package esclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"log-ai-analyzer/diag"
+	"log-ai-analyzer/metrics"
+)
+
+// 单个文档因ES限流等原因写入失败时的最多重试次数
+const bulkItemRetries = 3
+
+// BulkOptions 批量写入配置，达到条数、字节数或刷新间隔任一条件时提交一批
+type BulkOptions struct {
+	Actions       int           // 每批最多的文档数
+	Bytes         int           // 每批最大的请求字节数，0表示不限制
+	FlushInterval time.Duration // 未达到批量大小时的最长等待时间
+	Workers       int           // 并发提交的批次数
+}
+
+// bulkWriter 批量写入器，由后台工作协程按批提交
+type bulkWriter struct {
+	es     *ESClient
+	opts   BulkOptions
+	queued atomic.Int64
+
+	mu      sync.Mutex
+	items   []bulkItem
+	size    int
+	closed  bool
+	batches chan []bulkItem
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// StartBulk 启用批量写入，之后 IndexLog 只将文档加入队列，由后台按批提交
+// 单个文档的写入失败在提交后记录日志和指标，ES限流（429）等可重试的失败会自动退避重试
+func (e *ESClient) StartBulk(opts BulkOptions) error {
+	if opts.Actions <= 0 {
+		return fmt.Errorf("批量写入条数必须大于0")
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	w := &bulkWriter{
+		es:      e,
+		opts:    opts,
+		batches: make(chan []bulkItem, opts.Workers),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		w.wg.Add(1)
+		go w.work()
+	}
+	go w.tick()
+	e.bulk = w
+	return nil
+}
+
+// Flush 立即提交批量写入队列中积累的文档，不等待刷新间隔；未启用批量写入时直接返回
+func (e *ESClient) Flush() {
+	if e.bulk == nil {
+		return
+	}
+	w := e.bulk
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.flushLocked()
+	}
+}
+
+// Close 提交队列中剩余的文档并停止批量写入，集群不可用时剩余文档转入溢出队列
+func (e *ESClient) Close() error {
+	defer e.spill.close()
+	if e.bulk == nil {
+		return nil
+	}
+	w := e.bulk
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.flushLocked()
+	w.closed = true
+	close(w.batches)
+	close(w.done)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	if n := w.queued.Load(); n > 0 {
+		return fmt.Errorf("提交剩余ES文档失败: %d 个文档未写入", n)
+	}
+	return nil
+}
+
+// bulkItem 一个待写入的文档，同时是溢出队列中每行的格式
+type bulkItem struct {
+	Index   string          `json:"index"`
+	OpType  string          `json:"op_type"`
+	ID      string          `json:"id,omitempty"`      // 文档ID，为空时由ES生成
+	Version int64           `json:"version,omitempty"` // 外部版本号，大于0时以该版本覆盖同ID的旧文档
+	Doc     json.RawMessage `json:"doc"`
+
+	Kind     string `json:"kind,omitempty"`     // 文档类型: event、alert、incident，只用于统计写入结果
+	Severity int    `json:"severity,omitempty"` // 文档的严重性，只用于统计写入结果
+}
+
+// newBulkItem 编码待写入的事件
+func newBulkItem(index, opType string, event LogEvent) (bulkItem, error) {
+	doc, err := json.Marshal(event)
+	if err != nil {
+		return bulkItem{}, err
+	}
+	return bulkItem{Index: index, OpType: opType, ID: event.DocID, Doc: doc, Kind: "event", Severity: event.SeverityScore}, nil
+}
+
+// countWrites 按文档类型和严重性分级累计写入结果，升级前溢出的文档没有类型，记为 unknown
+func countWrites(counter *prometheus.CounterVec, items ...bulkItem) {
+	for _, item := range items {
+		kind := item.Kind
+		if kind == "" {
+			kind = "unknown"
+		}
+		counter.WithLabelValues(kind, metrics.SeverityBucket(item.Severity)).Inc()
+	}
+}
+
+// countDropped 将丢弃文档中的事件计入 events_dropped_total，告警和故障文档不计入
+func countDropped(reason string, items ...bulkItem) {
+	n := 0
+	for _, item := range items {
+		if item.Kind == "event" {
+			n++
+		}
+	}
+	metrics.DropEvents(reason, n)
+}
+
+// encode 返回批量请求中的 action 行和文档行
+func (item bulkItem) encode() []byte {
+	meta := object{"_index": item.Index}
+	if item.ID != "" {
+		meta["_id"] = item.ID
+	}
+	if item.Version > 0 {
+		meta["version"] = item.Version
+		meta["version_type"] = "external"
+	}
+	action, _ := json.Marshal(object{item.OpType: meta})
+	line := make([]byte, 0, len(action)+len(item.Doc)+2)
+	return append(append(append(append(line, action...), '\n'), item.Doc...), '\n')
+}
+
+// add 将文档加入批量写入队列，opType 为 index 或 create（数据流），批量写入已停止时返回false
+func (w *bulkWriter) add(item bulkItem) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	w.items = append(w.items, item)
+	w.size += len(item.Doc)
+	metrics.ESBulkQueuedDocs.Set(float64(w.queued.Add(1)))
+	if len(w.items) >= w.opts.Actions || (w.opts.Bytes > 0 && w.size >= w.opts.Bytes) {
+		w.flushLocked()
+	}
+	return true
+}
+
+// flushLocked 将当前积累的文档交给工作协程，工作协程都在提交时阻塞，对写入方形成背压
+func (w *bulkWriter) flushLocked() {
+	if len(w.items) == 0 {
+		return
+	}
+	start := time.Now()
+	w.batches <- w.items
+	metrics.FlowThrottledSeconds.WithLabelValues("sink").Add(time.Since(start).Seconds())
+	w.items = nil
+	w.size = 0
+}
+
+// tick 按刷新间隔提交未达到批量大小的文档
+func (w *bulkWriter) tick() {
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed {
+				w.flushLocked()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+func (w *bulkWriter) work() {
+	defer w.wg.Done()
+	for batch := range w.batches {
+		w.commit(batch)
+	}
+}
+
+// bulkResponse 批量写入的响应，每个文档一项
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Index  string `json:"_index"`
+		Status int    `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// retryableStatus 可以重试的文档写入状态：限流和暂时不可用
+func retryableStatus(status int) bool {
+	return status == 429 || status == 503
+}
+
+// conflictIgnored 判断文档是否因已有更新的版本或同ID的文档已存在（重复写入同一事件）而被拒绝，这种情况不算写入失败
+func conflictIgnored(status int, item bulkItem) bool {
+	if status != http.StatusConflict {
+		return false
+	}
+	if item.Version == 0 && item.OpType == "create" && item.ID != "" {
+		metrics.ESDuplicateCount.Inc()
+		return true
+	}
+	return item.Version > 0
+}
+
+// commit 提交一批文档并按文档统计结果，可重试的失败文档退避后重新提交；
+// 集群不可用、熔断中或重试次数用尽的文档转入溢出队列
+func (w *bulkWriter) commit(batch []bulkItem) {
+	metrics.ESBulkBatchSize.Observe(float64(len(batch)))
+	start := time.Now()
+	defer func() {
+		metrics.ESWriteDuration.Observe(time.Since(start).Seconds())
+		metrics.ESBulkQueuedDocs.Set(float64(w.queued.Add(-int64(len(batch)))))
+	}()
+	if !w.es.breaker.allow() {
+		w.es.spillItems(batch, ErrCircuitOpen)
+		return
+	}
+
+	pending := batch
+	var exhausted, succeeded, failed []bulkItem
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+		}
+		var body bytes.Buffer
+		for _, item := range pending {
+			body.Write(item.encode())
+		}
+		var resp bulkResponse
+		err := w.es.do(context.Background(), esapi.BulkRequest{Body: &body}, &resp)
+		if err != nil && outageError(err) {
+			w.es.breaker.failure(err)
+			w.es.spillItems(pending, err)
+			break
+		}
+		if err != nil {
+			log.Printf("ES批量写入失败，丢弃 %d 个文档: %v", len(pending), err)
+			diag.Recordf("elasticsearch", "ES批量写入失败，丢弃 %d 个文档: %v", len(pending), err)
+			failed = append(failed, pending...)
+			break
+		}
+		w.es.breaker.success()
+
+		var retry []bulkItem
+		for i, item := range resp.Items {
+			if i >= len(pending) {
+				break
+			}
+			for _, result := range item {
+				switch {
+				case result.Error == nil, conflictIgnored(result.Status, pending[i]):
+					succeeded = append(succeeded, pending[i])
+				case retryableStatus(result.Status):
+					if attempt < bulkItemRetries {
+						retry = append(retry, pending[i])
+					} else {
+						exhausted = append(exhausted, pending[i])
+					}
+				default:
+					failed = append(failed, pending[i])
+					log.Printf("ES文档写入失败 [索引: %s, 状态: %d]: %s: %s", result.Index, result.Status, result.Error.Type, result.Error.Reason)
+					diag.Recordf("elasticsearch", "ES文档写入失败 [索引: %s, 状态: %d]: %s: %s", result.Index, result.Status, result.Error.Type, result.Error.Reason)
+				}
+			}
+		}
+		pending = retry
+	}
+	if len(exhausted) > 0 {
+		w.es.spillItems(exhausted, fmt.Errorf("重试 %d 次后仍被ES拒绝", bulkItemRetries))
+	}
+	countWrites(metrics.ESWriteErrorCount, failed...)
+	countDropped(metrics.DropESRejected, failed...)
+	countWrites(metrics.ESWriteSuccessCount, succeeded...)
+}
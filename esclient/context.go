@@ -0,0 +1,77 @@
+package esclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RecentEvents 查询某台主机 since 之后的事件，按时间倒序，keyword 非空时只返回内容匹配的事件
+func (e *ESClient) RecentEvents(ctx context.Context, host, keyword string, since time.Time, limit int) ([]LogEvent, error) {
+	query := object{
+		"filter": []object{
+			{"term": object{"host.keyword": host}},
+			{"range": object{"@timestamp": object{"gte": since}}},
+		},
+	}
+	if keyword != "" {
+		query["must"] = []object{{"match": object{"content": keyword}}}
+	}
+
+	result, err := e.search(ctx, object{
+		"query": object{"bool": query},
+		"sort":  []object{{"@timestamp": object{"order": "desc"}}},
+		"size":  limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询ES近期事件失败: %w", err)
+	}
+	return result.events(), nil
+}
+
+// histogramBuckets 日期直方图聚合的分桶
+type histogramBuckets struct {
+	Buckets []struct {
+		Key      int64 `json:"key"`
+		DocCount int64 `json:"doc_count"`
+	} `json:"buckets"`
+}
+
+// TemplateOccurrences 统计某个日志模板 since 之后每天出现的次数
+func (e *ESClient) TemplateOccurrences(ctx context.Context, templateID string, since time.Time) ([]TermCount, error) {
+	result, err := e.search(ctx, object{
+		"query": object{"bool": object{
+			"filter": []object{
+				{"term": object{"template_id.keyword": templateID}},
+				{"range": object{"@timestamp": object{"gte": since}}},
+			},
+		}},
+		"size": 0,
+		"aggs": object{
+			"daily": object{"date_histogram": object{
+				"field":             "@timestamp",
+				"calendar_interval": "1d",
+				"min_doc_count":     0,
+			}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计模板出现次数失败: %w", err)
+	}
+
+	var aggs struct {
+		Daily histogramBuckets `json:"daily"`
+	}
+	if len(result.Aggregations) > 0 {
+		if err := json.Unmarshal(result.Aggregations, &aggs); err != nil {
+			return nil, fmt.Errorf("解析聚合结果失败: %w", err)
+		}
+	}
+	var counts []TermCount
+	for _, bucket := range aggs.Daily.Buckets {
+		key := time.UnixMilli(bucket.Key).Format("2006-01-02")
+		counts = append(counts, TermCount{Key: key, Count: bucket.DocCount})
+	}
+	return counts, nil
+}
@@ -1,55 +1,533 @@
 package esclient
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/olivere/elastic/v7"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"log-ai-analyzer/metrics"
 )
 
-// ESClient 封装了 elastic.Client 和索引前缀
+// ESClient 封装了官方 Elasticsearch 客户端和索引前缀
 type ESClient struct {
-	client *elastic.Client
-	index  string
+	client  *elasticsearch.Client
+	index   string
+	version Version     // 启动时探测到的集群版本
+	bulk    *bulkWriter // 启用批量写入后不为nil
+
+	lifecyclePolicy string // 新索引关联的ILM策略，为空表示不关联
+	dataStream      string // 写入的数据流名称，为空表示按 namer 写入索引
+	upsert          bool   // 同ID的事件文档覆盖旧文档，为false时只创建、已存在的文档跳过
+	namer           *indexNamer
+	breaker         *circuitBreaker // 为nil时不熔断
+	spill           *spillQueue     // 为nil时不缓存写入失败的文档
+	requestTimeout  time.Duration   // 单个请求（含重试）的超时时间，0表示不限制
+	online          atomic.Bool     // 已连接集群并完成初始化，之前写入的文档直接放入溢出队列
+	tenantRetention map[string]int  // 按租户设置的索引保留天数
+	tiers           SeverityTiers   // 按严重性分层写入的索引
 }
 
-// NewESClient 支持多个节点初始化
-func NewESClient(nodes []string, indexPrefix string) (*ESClient, error) {
-	client, err := elastic.NewClient(
-		elastic.SetURL(nodes...),
-		elastic.SetSniff(false), // 关闭 sniff，适配容器或多节点
-	)
+// Version 集群版本
+type Version struct {
+	Number string
+	Major  int
+	Minor  int
+}
+
+// AtLeast 判断集群版本是否不低于 major.minor
+func (v Version) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// 请求重试的退避时间
+const (
+	retryBaseBackoff = 200 * time.Millisecond
+	retryMaxBackoff  = 5 * time.Second
+)
+
+// ClientOptions ES连接参数，零值字段使用默认值
+type ClientOptions struct {
+	Compress        bool          // 使用gzip压缩请求体，跨机房写入较大的多行事件时可明显减少带宽
+	RequestTimeout  time.Duration // 单个请求（含重试）的超时时间，0表示不限制
+	MaxIdleConns    int           // 每个节点保持的空闲长连接数，默认10
+	IdleConnTimeout time.Duration // 空闲长连接的保持时间，默认90秒
+}
+
+// transport 创建按连接参数调优的 HTTP Transport，保留默认的代理和拨号设置
+func (o ClientOptions) transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = 10
+	if o.MaxIdleConns > 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdleConns
+	}
+	if t.MaxIdleConns < t.MaxIdleConnsPerHost {
+		t.MaxIdleConns = t.MaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = o.IdleConnTimeout
+	}
+	return t
+}
+
+// object JSON对象，用于构造查询和请求体
+type object = map[string]interface{}
+
+// NewESClient 支持多个节点初始化，按连接参数配置压缩和长连接；创建时不连接集群，需调用 Connect 或 RunConnect
+// 支持 Elasticsearch 7.14 及以上（含 8.x）版本
+func NewESClient(nodes []string, indexPrefix string, opts ClientOptions) (*ESClient, error) {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{
+		Addresses:           nodes,
+		RetryOnStatus:       []int{502, 503, 504, 429},
+		MaxRetries:          3,
+		RetryBackoff:        retryBackoff,
+		CompressRequestBody: opts.Compress,
+		PoolCompressor:      opts.Compress,
+		Transport:           opts.transport(),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("创建ES客户端失败: %w", err)
 	}
 
+	namer, err := newIndexNamer(indexPrefix, IndexNaming{})
+	if err != nil {
+		return nil, err
+	}
 	return &ESClient{
-		client: client,
-		index:  indexPrefix,
+		client:         client,
+		index:          indexPrefix,
+		namer:          namer,
+		requestTimeout: opts.RequestTimeout,
 	}, nil
 }
 
+// Connect 探测集群版本，成功后开始写入
+func (e *ESClient) Connect(ctx context.Context) error {
+	version, err := e.detectVersion(ctx)
+	if err != nil {
+		return err
+	}
+	e.version = version
+	e.online.Store(true)
+	return nil
+}
+
+// RunConnect 在后台重试连接集群，直到成功或 ctx 结束。
+// 连接成功后先执行 setup（如安装索引模板），再开始写入；之前写入的文档在溢出队列中等待重放
+func (e *ESClient) RunConnect(ctx context.Context, setup func()) {
+	for attempt := 1; ; attempt++ {
+		probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		version, err := e.detectVersion(probeCtx)
+		cancel()
+		if err == nil {
+			e.version = version
+			setup()
+			e.online.Store(true)
+			log.Printf("✅ Elasticsearch已连接，集群版本: %s", version.Number)
+			return
+		}
+		wait := connectBackoff(attempt)
+		log.Printf("⚠️ %v，%s 后重试", err, wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// connectBackoff 第 attempt 次连接失败后的等待时间，从1秒开始翻倍，最长1分钟
+func connectBackoff(attempt int) time.Duration {
+	if attempt > 7 {
+		return time.Minute
+	}
+	return time.Second << uint(attempt-1)
+}
+
+// Online 返回是否已连接集群
+func (e *ESClient) Online() bool {
+	return e.online.Load()
+}
+
+// retryBackoff 请求失败后第 attempt 次重试前的等待时间，指数增长
+func retryBackoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	backoff := retryBaseBackoff << uint(attempt-1)
+	if backoff > retryMaxBackoff {
+		backoff = retryMaxBackoff
+	}
+	return backoff
+}
+
+// Version 返回启动时探测到的集群版本
+func (e *ESClient) Version() Version {
+	return e.version
+}
+
+// detectVersion 请求集群信息，解析版本号
+func (e *ESClient) detectVersion(ctx context.Context) (Version, error) {
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := e.do(ctx, esapi.InfoRequest{}, &info); err != nil {
+		return Version{}, fmt.Errorf("探测ES集群版本失败: %w", err)
+	}
+	v := Version{Number: info.Version.Number}
+	parts := strings.SplitN(v.Number, ".", 3)
+	v.Major, _ = strconv.Atoi(parts[0])
+	if len(parts) > 1 {
+		v.Minor, _ = strconv.Atoi(parts[1])
+	}
+	if !v.AtLeast(7, 14) {
+		return v, fmt.Errorf("不支持的ES版本 %s，需要 7.14 及以上版本", v.Number)
+	}
+	return v, nil
+}
+
+// do 执行请求，响应状态码表示错误时返回包含错误原因的错误，out 不为nil时解析响应体
+func (e *ESClient) do(ctx context.Context, req esapi.Request, out interface{}) error {
+	if e.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.requestTimeout)
+		defer cancel()
+	}
+	res, err := req.Do(ctx, e.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return responseError(res)
+	}
+	if out == nil {
+		io.Copy(io.Discard, res.Body)
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析ES响应失败: %w", err)
+	}
+	return nil
+}
+
+// ResponseError ES返回的错误响应
+type ResponseError struct {
+	StatusCode int
+	Type       string
+	Reason     string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("ES返回状态码 %d", e.StatusCode)
+	}
+	return fmt.Sprintf("ES返回状态码 %d: %s: %s", e.StatusCode, e.Type, e.Reason)
+}
+
+// responseError 解析错误响应中的错误类型和原因
+func responseError(res *esapi.Response) error {
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	json.NewDecoder(res.Body).Decode(&body)
+	return &ResponseError{StatusCode: res.StatusCode, Type: body.Error.Type, Reason: body.Error.Reason}
+}
+
+// jsonBody 将请求体序列化为JSON
+func jsonBody(v interface{}) io.Reader {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// 请求体均由本包构造，序列化失败属于程序错误
+		panic(fmt.Sprintf("序列化ES请求失败: %v", err))
+	}
+	return bytes.NewReader(data)
+}
+
 // LogEvent 为结构化日志模型，支持 AI 分析与告警分数
 type LogEvent struct {
-	EventID       string    `json:"event_id"`   // 可用于日志聚合或唯一识别
-	Timestamp     time.Time `json:"@timestamp"` // 兼容 Kibana 时间字段
-	Host          string    `json:"host"`
-	Tags          []string  `json:"tags,omitempty"`
-	Content       string    `json:"content"`
-	SeverityScore int       `json:"severity_score"` // 日志异常等级打分
-	AiResult      string    `json:"ai_result"`      // AI 分析内容摘要
+	EventID       string         `json:"event_id"`              // 可用于日志聚合或唯一识别
+	DocID         string         `json:"-"`                     // 文档ID，标识事件的一次出现，为空时由ES生成
+	IncidentID    string         `json:"incident_id,omitempty"` // 事件所属的故障
+	Timestamp     time.Time      `json:"@timestamp"`            // 兼容 Kibana 时间字段
+	Host          string         `json:"host"`
+	Tenant        string         `json:"tenant,omitempty"`
+	Service       string         `json:"service,omitempty"`
+	Team          string         `json:"team,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	Content       string         `json:"content"`
+	SeverityScore int            `json:"severity_score"` // 日志异常等级打分
+	AiResult      string         `json:"ai_result"`      // AI 分析内容摘要
+	TemplateID    string         `json:"template_id,omitempty"`
+	Fingerprint   string         `json:"fingerprint,omitempty"`      // Alertmanager 兼容的告警指纹
+	OperatorNote  string         `json:"operator_note,omitempty"`    // 运维人员补充的处理记录
+	Feedback      string         `json:"feedback,omitempty"`         // 运维人员对AI分析的评价: helpful、wrong
+	SchemaVersion int            `json:"schema_version,omitempty"`   // 文档结构版本，写入ES时设置为 SchemaVersion
+	TraceID       string         `json:"trace_id,omitempty"`         // 日志中的 TraceID 或 RequestID
+	Related       []RelatedEvent `json:"related_events,omitempty"`   // 其他日志文件中同一请求的事件
+	Occurrences   int            `json:"occurrence_count,omitempty"` // 所属告警在合并窗口内的出现次数（含本事件）
+
+	Instance string            `json:"instance,omitempty"` // 处理该事件的分析器实例
+	Labels   map[string]string `json:"labels,omitempty"`   // 实例的静态标签
 }
 
-// IndexLog 将日志事件写入 ES（每日索引）
+// RelatedEvent 与事件属于同一请求（TraceID 相同）的其他日志文件中的事件
+type RelatedEvent struct {
+	DocID         string `json:"doc_id,omitempty"` // 该事件的文档ID
+	Host          string `json:"host"`
+	FilePath      string `json:"file_path"`
+	LineNumber    int    `json:"line_number"`
+	SeverityScore int    `json:"severity_score"`
+	Summary       string `json:"summary"`
+}
+
+// AI分析评价
+const (
+	FeedbackHelpful = "helpful"
+	FeedbackWrong   = "wrong"
+)
+
+// SetIndexNaming 设置事件索引的名称模板、滚动粒度和查询别名，需要在 EnsureIndexTemplate 之前调用
+func (e *ESClient) SetIndexNaming(naming IndexNaming) error {
+	namer, err := newIndexNamer(e.index, naming)
+	if err != nil {
+		return err
+	}
+	e.namer = namer
+	return nil
+}
+
+// searchIndex 返回查询事件使用的索引：配置了别名时查询别名，否则查询 <前缀>-*
+func (e *ESClient) searchIndex() []string {
+	if e.namer.naming.Alias != "" {
+		return []string{e.namer.naming.Alias}
+	}
+	return []string{e.index + "-*"}
+}
+
+// UseDataStream 改为写入数据流 <索引前缀>-events，由ES按 @timestamp 管理后备索引，
+// 需要在 EnsureIndexTemplate 之前调用，使安装的模板声明数据流
+func (e *ESClient) UseDataStream() {
+	e.dataStream = e.index + "-events"
+}
+
+// DataStream 返回写入的数据流名称，写入普通索引时为空
+func (e *ESClient) DataStream() string {
+	return e.dataStream
+}
+
+// SetSeverityTiers 按严重性将事件写入 <前缀>-hot-... 或 <前缀>-noise-... 索引，分层设置的保留天数覆盖默认保留期；
+// 写入数据流时不分层
+func (e *ESClient) SetSeverityTiers(tiers SeverityTiers) {
+	e.tiers = tiers
+}
+
+// UseUpsert 事件文档改为覆盖写入：同一事件重复写入时以最后一次为准（如补充了AI分析结果），
+// 默认只创建，已存在的文档保持不变；数据流只接受 create 操作，不支持覆盖写入
+func (e *ESClient) UseUpsert() {
+	e.upsert = true
+}
+
+// writeTarget 返回写入目标和操作类型，数据流只接受 create 操作
+func (e *ESClient) writeTarget(event LogEvent, now time.Time) (target, opType string) {
+	if e.dataStream != "" {
+		return e.dataStream, "create"
+	}
+	target = e.namer.name(e.index, e.tiers.tier(event.SeverityScore), event, now)
+	if e.upsert || event.DocID == "" {
+		return target, "index"
+	}
+	return target, "create"
+}
+
+// IndexLog 将日志事件写入 ES（按名称模板生成的索引或数据流），启用批量写入时只加入队列，批量写入停止后改为同步写入。
+// 以事件的 DocID 作为文档ID，崩溃后重新采集或重放同一事件不会产生重复文档。
+// 集群不可用或熔断中时文档转入溢出队列，待集群恢复后重放
 func (e *ESClient) IndexLog(event LogEvent) error {
-	indexName := fmt.Sprintf("%s-%s", e.index, time.Now().Format("2006.01.02"))
-	_, err := e.client.Index().
-		Index(indexName).
-		BodyJson(event).
-		Do(context.Background())
+	event.SchemaVersion = SchemaVersion
+	target, opType := e.writeTarget(event, time.Now())
+	item, err := newBulkItem(target, opType, event)
 	if err != nil {
+		metrics.ESWriteErrorCount.WithLabelValues("event", metrics.SeverityBucket(event.SeverityScore)).Inc()
+		return fmt.Errorf("编码ES文档失败: %w", err)
+	}
+	return e.write(item)
+}
+
+// write 写入一个文档，IndexLog 和 UpsertAlert 共用
+func (e *ESClient) write(item bulkItem) error {
+	if !e.online.Load() {
+		return e.spillFailed(item, ErrOffline)
+	}
+	if e.bulk != nil && e.bulk.add(item) {
+		return nil
+	}
+	if !e.breaker.allow() {
+		return e.spillFailed(item, ErrCircuitOpen)
+	}
+	req := esapi.IndexRequest{
+		Index:  item.Index,
+		OpType: item.OpType,
+		Body:   bytes.NewReader(item.Doc),
+	}
+	req.DocumentID = item.ID
+	if item.Version > 0 {
+		version := int(item.Version)
+		req.Version, req.VersionType = &version, "external"
+	}
+	start := time.Now()
+	err := e.do(context.Background(), req, nil)
+	var re *ResponseError
+	if errors.As(err, &re) && conflictIgnored(re.StatusCode, item) {
+		err = nil
+	}
+	if err != nil && outageError(err) {
+		e.breaker.failure(err)
+		return e.spillFailed(item, err)
+	}
+	if err != nil {
+		countWrites(metrics.ESWriteErrorCount, item)
 		return fmt.Errorf("写入ES失败: %w", err)
 	}
+	e.breaker.success()
+	metrics.ESWriteDuration.Observe(time.Since(start).Seconds())
+	countWrites(metrics.ESWriteSuccessCount, item)
+	return nil
+}
+
+// spillFailed 将同步写入失败的文档放入溢出队列，放入成功时不返回错误
+func (e *ESClient) spillFailed(item bulkItem, cause error) error {
+	if e.spill == nil {
+		countWrites(metrics.ESWriteErrorCount, item)
+		return fmt.Errorf("写入ES失败: %w", cause)
+	}
+	if e.spillItems([]bulkItem{item}, cause) > 0 {
+		return fmt.Errorf("写入ES失败且溢出队列已满: %w", cause)
+	}
+	return nil
+}
+
+// searchResponse 检索结果，聚合结果由各调用方按需解析
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	Hits     struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Source json.RawMessage `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations json.RawMessage `json:"aggregations"`
+}
+
+// events 解析命中的事件，无法解析的文档被忽略
+func (r *searchResponse) events() []LogEvent {
+	events := make([]LogEvent, 0, len(r.Hits.Hits))
+	for _, hit := range r.Hits.Hits {
+		var event LogEvent
+		if err := json.Unmarshal(hit.Source, &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// search 在所有事件索引中检索
+func (e *ESClient) search(ctx context.Context, body object) (*searchResponse, error) {
+	var result searchResponse
+	err := e.do(ctx, esapi.SearchRequest{
+		Index: e.searchIndex(),
+		Body:  jsonBody(body),
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SearchSimilar 检索与给定日志最相似、且已有AI分析或处理记录的历史事件
+// 同一日志模板的事件优先，其次按内容相似度排序
+func (e *ESClient) SearchSimilar(ctx context.Context, templateID, content string, limit int) ([]LogEvent, error) {
+	should := []object{
+		{"more_like_this": object{
+			"fields":        []string{"content"},
+			"like":          content,
+			"min_term_freq": 1,
+			"min_doc_freq":  1,
+		}},
+		// 被评价为有帮助的分析优先
+		{"term": object{"feedback.keyword": object{"value": FeedbackHelpful, "boost": 3}}},
+	}
+	if templateID != "" {
+		should = append(should, object{"term": object{"template_id.keyword": object{"value": templateID, "boost": 5}}})
+	}
+	query := object{"bool": object{
+		"should": should,
+		"filter": []object{{"bool": object{
+			"should": []object{
+				{"exists": object{"field": "operator_note"}},
+				{"exists": object{"field": "ai_result"}},
+			},
+			"minimum_should_match": 1,
+		}}},
+		"minimum_should_match": 1,
+	}}
+
+	result, err := e.search(ctx, object{"query": query, "size": limit})
+	if err != nil {
+		return nil, fmt.Errorf("查询ES历史事件失败: %w", err)
+	}
+	return result.events(), nil
+}
+
+// ErrEventNotFound 未找到指定的事件
+var ErrEventNotFound = errors.New("未找到事件")
+
+// ErrOffline 尚未连接ES集群
+var ErrOffline = errors.New("尚未连接ES")
+
+// AddFeedback 记录运维人员对事件AI分析的评价，note 非空时同时保存为处理记录
+func (e *ESClient) AddFeedback(ctx context.Context, eventID, feedback, note string) error {
+	source := "ctx._source.feedback = params.feedback; " +
+		"if (params.note != '') { ctx._source.operator_note = params.note; }"
+	refresh := true
+	var result struct {
+		Updated int64 `json:"updated"`
+	}
+	err := e.do(ctx, esapi.UpdateByQueryRequest{
+		Index:   e.searchIndex(),
+		Refresh: &refresh,
+		Body: jsonBody(object{
+			"query": object{"term": object{"event_id.keyword": eventID}},
+			"script": object{
+				"source": source,
+				"params": object{"feedback": feedback, "note": note},
+			},
+		}),
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("保存AI分析评价失败: %w", err)
+	}
+	if result.Updated == 0 {
+		return fmt.Errorf("%w: %s", ErrEventNotFound, eventID)
+	}
 	return nil
 }
@@ -0,0 +1,69 @@
+package esclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// 遍历已评价事件时每批读取的文档数和 scroll 上下文的保留时间
+const (
+	scanBatchSize = 500
+	scanKeepAlive = time.Minute
+)
+
+// ScanLabeled 按时间顺序遍历 since 之后被运维人员评价或补充了处理记录的事件
+// 使用 scroll 分批读取，fn 返回错误时停止遍历
+func (e *ESClient) ScanLabeled(ctx context.Context, since time.Time, fn func(LogEvent) error) error {
+	query := object{"bool": object{
+		"filter": []object{
+			{"range": object{"@timestamp": object{"gte": since}}},
+			{"bool": object{
+				"should": []object{
+					{"exists": object{"field": "feedback"}},
+					{"exists": object{"field": "operator_note"}},
+				},
+				"minimum_should_match": 1,
+			}},
+		},
+	}}
+
+	var result searchResponse
+	err := e.do(ctx, esapi.SearchRequest{
+		Index:  e.searchIndex(),
+		Scroll: scanKeepAlive,
+		Body: jsonBody(object{
+			"query": query,
+			"sort":  []object{{"@timestamp": object{"order": "asc"}}},
+			"size":  scanBatchSize,
+		}),
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("遍历ES已评价事件失败: %w", err)
+	}
+	defer func() {
+		if result.ScrollID != "" {
+			e.do(context.Background(), esapi.ClearScrollRequest{ScrollID: []string{result.ScrollID}}, nil)
+		}
+	}()
+
+	for len(result.Hits.Hits) > 0 {
+		for _, event := range result.events() {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		scrollID := result.ScrollID
+		result = searchResponse{}
+		err := e.do(ctx, esapi.ScrollRequest{
+			Body: jsonBody(object{"scroll": fmt.Sprintf("%dms", scanKeepAlive.Milliseconds()), "scroll_id": scrollID}),
+		}, &result)
+		if err != nil {
+			result.ScrollID = scrollID
+			return fmt.Errorf("遍历ES已评价事件失败: %w", err)
+		}
+	}
+	return nil
+}
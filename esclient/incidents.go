@@ -0,0 +1,60 @@
+package esclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"log-ai-analyzer/metrics"
+)
+
+// IncidentDoc 故障文档：关联的事件归为一个故障，每个故障只保存一个文档，随新事件和恢复更新
+type IncidentDoc struct {
+	ID          string     `json:"incident_id"`
+	Tenant      string     `json:"tenant,omitempty"`
+	Status      string     `json:"status"` // open、updating、resolved
+	Title       string     `json:"title"`
+	Hosts       []string   `json:"hosts"`
+	Files       []string   `json:"files,omitempty"` // 主机:文件路径
+	Templates   []string   `json:"templates,omitempty"`
+	EventCount  int        `json:"event_count"`
+	MaxSeverity int        `json:"severity_score"`
+	OpenedAt    time.Time  `json:"opened_at"`
+	UpdatedAt   time.Time  `json:"@timestamp"` // 最近一个事件的时间，兼容 Kibana 时间字段
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	LastEventID string     `json:"last_event_id"`
+
+	Instance string            `json:"instance,omitempty"` // 跟踪该故障的分析器实例
+	Labels   map[string]string `json:"labels,omitempty"`   // 实例的静态标签
+
+	Timeline []IncidentTimelineEntry `json:"timeline,omitempty"`
+}
+
+// IncidentTimelineEntry 故障时间线上的一个节点：首次出现、严重性升高或扩散到新的主机、文件
+type IncidentTimelineEntry struct {
+	At       time.Time `json:"at"`
+	Kind     string    `json:"kind"` // first、escalation、spread_host、spread_file
+	Host     string    `json:"host"`
+	FilePath string    `json:"file_path,omitempty"`
+	EventID  string    `json:"event_id"`
+	Severity int       `json:"severity_score"`
+	Summary  string    `json:"summary"`
+}
+
+// UpsertIncident 以故障ID为文档ID写入故障文档，覆盖该故障之前的文档，与 UpsertAlert 一样以写入时间为外部版本号
+func (e *ESClient) UpsertIncident(index string, doc IncidentDoc) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		metrics.ESWriteErrorCount.WithLabelValues("incident", metrics.SeverityBucket(doc.MaxSeverity)).Inc()
+		return fmt.Errorf("编码故障文档失败: %w", err)
+	}
+	return e.write(bulkItem{
+		Index:    index,
+		OpType:   "index",
+		ID:       doc.ID,
+		Version:  time.Now().UnixNano(),
+		Doc:      data,
+		Kind:     "incident",
+		Severity: doc.MaxSeverity,
+	})
+}
@@ -0,0 +1,222 @@
+package esclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// 查询重建索引任务进度的间隔
+const reindexPollInterval = 5 * time.Second
+
+// migrateTempPrefix 迁移时暂存文档的索引名称前缀，不匹配事件索引模式，查询时不会重复命中
+const migrateTempPrefix = "migrate-"
+
+// OutdatedIndices 返回包含旧版本文档（schema_version 低于 SchemaVersion 或缺失）的事件索引及旧文档数，
+// 仍在写入的索引（覆盖 now 的索引）不包含在内；上次迁移中止、只剩临时索引的索引同样返回，需要继续迁移
+func (e *ESClient) OutdatedIndices(ctx context.Context, now time.Time) (map[string]int64, error) {
+	indices, err := e.eventIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	outdated := make(map[string]int64)
+
+	var temps []struct {
+		Index string `json:"index"`
+		Docs  string `json:"docs.count"`
+	}
+	err = e.do(ctx, esapi.CatIndicesRequest{
+		Index:  []string{migrateTempPrefix + e.index + "-*"},
+		Format: "json",
+		H:      []string{"index", "docs.count"},
+	}, &temps)
+	if err != nil {
+		return nil, fmt.Errorf("查询迁移临时索引失败: %w", err)
+	}
+	for _, t := range temps {
+		outdated[strings.TrimPrefix(t.Index, migrateTempPrefix)], _ = strconv.ParseInt(t.Docs, 10, 64)
+	}
+
+	for index, end := range indices {
+		if _, ok := outdated[index]; ok || end.After(now) {
+			continue
+		}
+		count, err := e.outdatedCount(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			outdated[index] = count
+		}
+	}
+	return outdated, nil
+}
+
+// outdatedCount 统计索引中的旧版本文档数
+func (e *ESClient) outdatedCount(ctx context.Context, index string) (int64, error) {
+	var result struct {
+		Count int64 `json:"count"`
+	}
+	err := e.do(ctx, esapi.CountRequest{
+		Index: []string{index},
+		Body: jsonBody(object{"query": object{"bool": object{
+			"must_not": []object{{"range": object{"schema_version": object{"gte": SchemaVersion}}}},
+		}}}),
+	}, &result)
+	if err != nil {
+		return 0, fmt.Errorf("统计索引 %s 的旧版本文档失败: %w", index, err)
+	}
+	return result.Count, nil
+}
+
+// MigrateIndex 按当前索引模板和文档版本重建事件索引，返回本次复制回原索引的文档数。
+// 文档先复制到临时索引，删除原索引后以原名称重建（新索引使用当前模板的映射）并复制回来，同时设置 schema_version；
+// 中途失败时可以重新执行，从中止的步骤继续，临时索引中的文档不会丢失。
+// 重建期间该索引的数据暂时查询不到，不能用于仍在写入的索引
+func (e *ESClient) MigrateIndex(ctx context.Context, index string) (int64, error) {
+	if e.dataStream != "" {
+		return 0, fmt.Errorf("数据流的后备索引不支持重建")
+	}
+	temp := migrateTempPrefix + index
+	tempExists, err := e.indexExists(ctx, temp)
+	if err != nil {
+		return 0, err
+	}
+	exists, err := e.indexExists(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+	if exists && tempExists {
+		// 原索引中仍有旧版本文档说明上次迁移在复制到临时索引时中止，临时索引可能不完整，重新复制；
+		// 否则是在复制回原索引时中止，继续复制即可（已存在的文档跳过）
+		count, err := e.outdatedCount(ctx, index)
+		if err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			if err := e.deleteIndex(ctx, temp); err != nil {
+				return 0, err
+			}
+			tempExists = false
+		}
+	}
+	switch {
+	case !exists && !tempExists:
+		return 0, fmt.Errorf("索引 %s 不存在", index)
+	case !tempExists:
+		// 临时索引只保存 _source，不建立字段映射，避免与旧文档的字段类型冲突
+		err := e.do(ctx, esapi.IndicesCreateRequest{
+			Index: temp,
+			Body:  jsonBody(object{"settings": object{"number_of_replicas": 0}, "mappings": object{"dynamic": false}}),
+		}, nil)
+		if err != nil {
+			return 0, fmt.Errorf("创建临时索引 %s 失败: %w", temp, err)
+		}
+		if _, err := e.reindex(ctx, object{"source": object{"index": index}, "dest": object{"index": temp}}); err != nil {
+			return 0, err
+		}
+		if err := e.deleteIndex(ctx, index); err != nil {
+			return 0, err
+		}
+	}
+
+	// 以原名称重建，新索引由ES按当前模板创建
+	created, err := e.reindex(ctx, object{
+		"source":    object{"index": temp},
+		"dest":      object{"index": index, "op_type": "create"},
+		"conflicts": "proceed",
+		"script": object{
+			"lang":   "painless",
+			"source": "ctx._source.schema_version = params.version",
+			"params": object{"version": SchemaVersion},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, e.deleteIndex(ctx, temp)
+}
+
+// reindexTask 重建索引任务的状态
+type reindexTask struct {
+	Completed bool `json:"completed"`
+	Response  struct {
+		Total    int64 `json:"total"`
+		Created  int64 `json:"created"`
+		Failures []struct {
+			Index string `json:"index"`
+			Cause struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"cause"`
+		} `json:"failures"`
+	} `json:"response"`
+	Error *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// reindex 以后台任务执行 _reindex 并等待完成，避免大索引超过单个请求的超时时间，返回创建的文档数
+func (e *ESClient) reindex(ctx context.Context, body object) (int64, error) {
+	wait, refresh := false, true
+	var started struct {
+		Task string `json:"task"`
+	}
+	err := e.do(ctx, esapi.ReindexRequest{Body: jsonBody(body), WaitForCompletion: &wait, Refresh: &refresh}, &started)
+	if err != nil {
+		return 0, fmt.Errorf("启动重建索引任务失败: %w", err)
+	}
+
+	ticker := time.NewTicker(reindexPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("等待重建索引任务 %s 中止: %w", started.Task, ctx.Err())
+		case <-ticker.C:
+		}
+		var task reindexTask
+		if err := e.do(ctx, esapi.TasksGetRequest{TaskID: started.Task}, &task); err != nil {
+			return 0, fmt.Errorf("查询重建索引任务 %s 失败: %w", started.Task, err)
+		}
+		if !task.Completed {
+			continue
+		}
+		if task.Error != nil {
+			return 0, fmt.Errorf("重建索引失败: %s: %s", task.Error.Type, task.Error.Reason)
+		}
+		if n := len(task.Response.Failures); n > 0 {
+			f := task.Response.Failures[0]
+			return 0, fmt.Errorf("重建索引时 %d 个文档写入失败，如 %s: %s", n, f.Cause.Type, f.Cause.Reason)
+		}
+		return task.Response.Created, nil
+	}
+}
+
+// indexExists 判断索引是否存在
+func (e *ESClient) indexExists(ctx context.Context, index string) (bool, error) {
+	err := e.do(ctx, esapi.IndicesExistsRequest{Index: []string{index}}, nil)
+	var re *ResponseError
+	if errors.As(err, &re) && re.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查询索引 %s 失败: %w", index, err)
+	}
+	return true, nil
+}
+
+// deleteIndex 删除索引
+func (e *ESClient) deleteIndex(ctx context.Context, index string) error {
+	if err := e.do(ctx, esapi.IndicesDeleteRequest{Index: []string{index}}, nil); err != nil {
+		return fmt.Errorf("删除索引 %s 失败: %w", index, err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package esclient
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 索引滚动粒度
+const (
+	GranularityDaily   = "daily"
+	GranularityWeekly  = "weekly"
+	GranularityMonthly = "monthly"
+)
+
+// DefaultIndexPattern 默认的索引名称模板：<前缀>-<日期>
+const DefaultIndexPattern = "{prefix}-{date}"
+
+// 索引名称模板支持的占位符
+var indexPlaceholders = regexp.MustCompile(`\{[a-z]+\}`)
+
+// 索引名称中不允许出现的字符
+var invalidIndexChars = regexp.MustCompile(`[^a-z0-9._+-]+`)
+
+// 严重性分层的索引名称片段，分层的事件写入 <前缀>-<分层>-... 索引
+const (
+	TierHot   = "hot"
+	TierNoise = "noise"
+)
+
+// SeverityTiers 按严重性将事件写入不同的索引并分别设置保留期：高严重性事件长期保留，低严重性的噪声短期保留
+type SeverityTiers struct {
+	HotMinSeverity     int // 严重性不低于该值的事件写入 hot 索引，0表示不启用
+	HotRetentionDays   int // hot 索引的保留天数，0表示使用默认保留期
+	NoiseMaxSeverity   int // 严重性不超过该值的事件写入 noise 索引，0表示不启用
+	NoiseRetentionDays int // noise 索引的保留天数，0表示使用默认保留期
+}
+
+// tier 返回该严重性的事件所属的分层，不属于任何分层时返回空字符串
+func (t SeverityTiers) tier(severity int) string {
+	switch {
+	case t.HotMinSeverity > 0 && severity >= t.HotMinSeverity:
+		return TierHot
+	case t.NoiseMaxSeverity > 0 && severity <= t.NoiseMaxSeverity:
+		return TierNoise
+	}
+	return ""
+}
+
+// retentionDays 返回分层单独设置的保留天数
+func (t SeverityTiers) retentionDays(tier string) (int, bool) {
+	switch {
+	case tier == TierHot && t.HotRetentionDays > 0:
+		return t.HotRetentionDays, true
+	case tier == TierNoise && t.NoiseRetentionDays > 0:
+		return t.NoiseRetentionDays, true
+	}
+	return 0, false
+}
+
+// IndexNaming 事件索引的命名方式
+type IndexNaming struct {
+	Pattern     string // 名称模板，支持 {prefix}、{date}、{host}、{tenant}，必须以 "{prefix}-" 开头并包含 {date}
+	Granularity string // {date} 的粒度: daily（2006.01.02）、weekly（2006-w01，ISO周）、monthly（2006.01）
+	Tenant      string // 事件没有租户时 {tenant} 的取值
+	Alias       string // 查询使用的索引别名，为空时查询 <前缀>-*
+}
+
+// indexNamer 根据命名方式生成索引名称，并从索引名称中解析日期
+type indexNamer struct {
+	naming  IndexNaming
+	matcher *regexp.Regexp // 匹配事件索引名称，date 分组为日期，tenant 分组为租户，tier 分组为严重性分层
+}
+
+// newIndexNamer 校验名称模板并生成解析索引名称的正则表达式
+func newIndexNamer(prefix string, naming IndexNaming) (*indexNamer, error) {
+	if naming.Pattern == "" {
+		naming.Pattern = DefaultIndexPattern
+	}
+	if naming.Granularity == "" {
+		naming.Granularity = GranularityDaily
+	}
+	datePattern, ok := map[string]string{
+		GranularityDaily:   `\d{4}\.\d{2}\.\d{2}`,
+		GranularityWeekly:  `\d{4}-w\d{2}`,
+		GranularityMonthly: `\d{4}\.\d{2}`,
+	}[naming.Granularity]
+	if !ok {
+		return nil, fmt.Errorf("不支持的索引粒度: %s", naming.Granularity)
+	}
+	if !strings.HasPrefix(naming.Pattern, "{prefix}-") || !strings.Contains(naming.Pattern, "{date}") {
+		return nil, fmt.Errorf("索引名称模板必须以 {prefix}- 开头并包含 {date}: %s", naming.Pattern)
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	rest := naming.Pattern
+	for {
+		loc := indexPlaceholders.FindStringIndex(rest)
+		if loc == nil {
+			expr.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		expr.WriteString(regexp.QuoteMeta(rest[:loc[0]]))
+		switch placeholder := rest[loc[0]:loc[1]]; placeholder {
+		case "{prefix}":
+			expr.WriteString(regexp.QuoteMeta(prefix) + "(?:-(?P<tier>" + TierHot + "|" + TierNoise + "))?")
+		case "{date}":
+			expr.WriteString("(?P<date>" + datePattern + ")")
+		case "{host}":
+			expr.WriteString(`[a-z0-9._+-]+?`)
+		case "{tenant}":
+			expr.WriteString(`(?P<tenant>[a-z0-9._+-]+?)`)
+		default:
+			return nil, fmt.Errorf("索引名称模板包含不支持的占位符: %s", placeholder)
+		}
+		rest = rest[loc[1]:]
+	}
+	expr.WriteString("$")
+	matcher, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("索引名称模板无效: %w", err)
+	}
+	return &indexNamer{naming: naming, matcher: matcher}, nil
+}
+
+// indexNameValue 将主机名、租户等转为合法的索引名称片段：小写，非法字符替换为下划线
+func indexNameValue(s string) string {
+	s = invalidIndexChars.ReplaceAllString(strings.ToLower(s), "_")
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// formatDate 按粒度格式化日期
+func (n *indexNamer) formatDate(t time.Time) string {
+	switch n.naming.Granularity {
+	case GranularityWeekly:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-w%02d", year, week)
+	case GranularityMonthly:
+		return t.Format("2006.01")
+	default:
+		return t.Format(indexDateLayout)
+	}
+}
+
+// name 返回事件写入的索引名称，{tenant} 优先取事件所属的租户；tier 不为空时前缀之后加入分层
+func (n *indexNamer) name(prefix, tier string, event LogEvent, now time.Time) string {
+	if tier != "" {
+		prefix += "-" + tier
+	}
+	tenant := event.Tenant
+	if tenant == "" {
+		tenant = n.naming.Tenant
+	}
+	return strings.NewReplacer(
+		"{prefix}", prefix,
+		"{date}", n.formatDate(now),
+		"{host}", indexNameValue(event.Host),
+		"{tenant}", indexNameValue(tenant),
+	).Replace(n.naming.Pattern)
+}
+
+// field 返回事件索引名称中 group 分组（tenant、tier）的值，名称模板不包含该分组或索引不含该片段时返回空字符串
+func (n *indexNamer) field(index, group string) string {
+	i := n.matcher.SubexpIndex(group)
+	if i < 0 {
+		return ""
+	}
+	if m := n.matcher.FindStringSubmatch(index); m != nil {
+		return m[i]
+	}
+	return ""
+}
+
+// period 解析事件索引名称中的日期，返回索引覆盖的时间范围 [start, end)，不是事件索引时返回false
+func (n *indexNamer) period(index string) (start, end time.Time, ok bool) {
+	m := n.matcher.FindStringSubmatch(index)
+	if m == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	date := m[n.matcher.SubexpIndex("date")]
+	var err error
+	switch n.naming.Granularity {
+	case GranularityWeekly:
+		start, err = parseISOWeek(date)
+		end = start.AddDate(0, 0, 7)
+	case GranularityMonthly:
+		start, err = time.ParseInLocation("2006.01", date, time.Local)
+		end = start.AddDate(0, 1, 0)
+	default:
+		start, err = time.ParseInLocation(indexDateLayout, date, time.Local)
+		end = start.AddDate(0, 0, 1)
+	}
+	return start, end, err == nil
+}
+
+// parseISOWeek 解析 2006-w01 格式的ISO周，返回该周周一零点
+func parseISOWeek(s string) (time.Time, error) {
+	year, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return time.Time{}, err
+	}
+	week, err := strconv.Atoi(s[6:])
+	if err != nil || week < 1 || week > 53 {
+		return time.Time{}, fmt.Errorf("无效的ISO周: %s", s)
+	}
+	// 1月4日总在第1周内
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.Local)
+	monday := jan4.AddDate(0, 0, -((int(jan4.Weekday()) + 6) % 7))
+	return monday.AddDate(0, 0, (week-1)*7), nil
+}
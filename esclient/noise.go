@@ -0,0 +1,164 @@
+package esclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// 噪声模板的判定条件：周期内至少在该比例的小时里出现过，且最高严重性低于严重告警
+const (
+	noiseActiveRatio   = 0.8
+	noiseMaxSeverity   = 8
+	noiseTemplateLimit = 500
+)
+
+// NoiseCandidate 疑似噪声的日志模板：持续出现，但从未被运维人员评价或记录处理，也从未出现在严重故障中
+type NoiseCandidate struct {
+	TemplateID  string `json:"template_id"`
+	Count       int64  `json:"count"`
+	ActiveHours int    `json:"active_hours"` // 出现过事件的小时数
+	Hours       int    `json:"hours"`        // 统计周期的小时数
+	MaxSeverity int    `json:"max_severity"`
+	Sample      string `json:"sample"`
+}
+
+// NoiseCandidates 统计 [from, to) 内的噪声模板：事件数不少于 minEvents、至少80%的小时里出现过、最高严重性低于8、
+// 没有任何事件被评价或记录处理；incidentIndex 不为空时排除出现在严重性>=8故障中的模板。
+// 速率异常、序列规则、SLO等合成事件的模板不参与统计
+func (e *ESClient) NoiseCandidates(ctx context.Context, from, to time.Time, incidentIndex string, minEvents int64) ([]NoiseCandidate, error) {
+	result, err := e.search(ctx, object{
+		"query": object{"range": object{"@timestamp": object{"gte": from, "lt": to}}},
+		"size":  0,
+		"aggs": object{
+			"templates": object{
+				"terms": object{"field": "template_id.keyword", "size": noiseTemplateLimit, "min_doc_count": minEvents},
+				"aggs": object{
+					"max_severity": object{"max": object{"field": "severity_score"}},
+					"acknowledged": object{"filter": object{"bool": object{
+						"should": []object{
+							{"exists": object{"field": "feedback"}},
+							{"exists": object{"field": "operator_note"}},
+						},
+						"minimum_should_match": 1,
+					}}},
+					"hourly": object{"date_histogram": object{
+						"field":          "@timestamp",
+						"fixed_interval": "1h",
+						"min_doc_count":  1,
+					}},
+					"sample": object{"top_hits": object{
+						"size":    1,
+						"_source": object{"includes": []string{"content"}},
+					}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计噪声模板失败: %w", err)
+	}
+
+	var aggs struct {
+		Templates struct {
+			Buckets []struct {
+				Key         string `json:"key"`
+				DocCount    int64  `json:"doc_count"`
+				MaxSeverity struct {
+					Value *float64 `json:"value"`
+				} `json:"max_severity"`
+				Acknowledged struct {
+					DocCount int64 `json:"doc_count"`
+				} `json:"acknowledged"`
+				Hourly histogramBuckets `json:"hourly"`
+				Sample searchResponse   `json:"sample"`
+			} `json:"buckets"`
+		} `json:"templates"`
+	}
+	if len(result.Aggregations) > 0 {
+		if err := json.Unmarshal(result.Aggregations, &aggs); err != nil {
+			return nil, fmt.Errorf("解析聚合结果失败: %w", err)
+		}
+	}
+
+	serious, err := e.incidentTemplates(ctx, from, to, incidentIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	hours := int(math.Ceil(to.Sub(from).Hours()))
+	var candidates []NoiseCandidate
+	for _, bucket := range aggs.Templates.Buckets {
+		if strings.HasPrefix(bucket.Key, "anomaly-") || strings.HasPrefix(bucket.Key, "sequence-") ||
+			strings.HasPrefix(bucket.Key, "slo-") {
+			continue
+		}
+		if bucket.Acknowledged.DocCount > 0 || serious[bucket.Key] {
+			continue
+		}
+		if bucket.MaxSeverity.Value != nil && int(*bucket.MaxSeverity.Value) >= noiseMaxSeverity {
+			continue
+		}
+		active := len(bucket.Hourly.Buckets)
+		if float64(active) < noiseActiveRatio*float64(hours) {
+			continue
+		}
+		c := NoiseCandidate{TemplateID: bucket.Key, Count: bucket.DocCount, ActiveHours: active, Hours: hours}
+		if bucket.MaxSeverity.Value != nil {
+			c.MaxSeverity = int(*bucket.MaxSeverity.Value)
+		}
+		if samples := bucket.Sample.events(); len(samples) > 0 {
+			c.Sample = samples[0].Content
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// incidentTemplates 返回 [from, to) 内严重性>=8的故障涉及的日志模板，故障索引不存在时返回空
+func (e *ESClient) incidentTemplates(ctx context.Context, from, to time.Time, incidentIndex string) (map[string]bool, error) {
+	templates := make(map[string]bool)
+	if incidentIndex == "" {
+		return templates, nil
+	}
+	ignore := true
+	var result searchResponse
+	err := e.do(ctx, esapi.SearchRequest{
+		Index:             []string{incidentIndex},
+		IgnoreUnavailable: &ignore,
+		Body: jsonBody(object{
+			"query": object{"bool": object{"filter": []object{
+				{"range": object{"@timestamp": object{"gte": from}}},
+				{"range": object{"opened_at": object{"lt": to}}},
+				{"range": object{"severity_score": object{"gte": noiseMaxSeverity}}},
+			}}},
+			"size": 0,
+			"aggs": object{"templates": object{"terms": object{"field": "templates.keyword", "size": 10000}}},
+		}),
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("统计故障涉及的日志模板失败: %w", err)
+	}
+
+	var aggs struct {
+		Templates struct {
+			Buckets []struct {
+				Key string `json:"key"`
+			} `json:"buckets"`
+		} `json:"templates"`
+	}
+	if len(result.Aggregations) > 0 {
+		if err := json.Unmarshal(result.Aggregations, &aggs); err != nil {
+			return nil, fmt.Errorf("解析聚合结果失败: %w", err)
+		}
+	}
+	for _, bucket := range aggs.Templates.Buckets {
+		templates[bucket.Key] = true
+	}
+	return templates, nil
+}
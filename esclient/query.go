@@ -0,0 +1,119 @@
+package esclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 查询结果的默认条数
+const defaultQuerySize = 20
+
+// 分页查询最多能翻到的位置，与ES默认的 index.max_result_window 一致
+const maxQueryWindow = 10000
+
+// EventQuery 事件查询条件，零值字段不作为条件
+type EventQuery struct {
+	EventID     string
+	Hosts       []string // 任一主机
+	Tenants     []string // 任一租户
+	Tags        []string // 任一标签
+	TemplateID  string
+	Text        string    // 全文检索日志内容、AI分析和处理记录
+	From        time.Time // 起始时间（含）
+	To          time.Time // 截止时间（不含）
+	MinSeverity int       // 最低严重性分数
+	Size        int       // 返回条数，默认20
+	Offset      int       // 跳过的条数，Offset+Size 不能超过10000
+	Ascending   bool      // 按时间正序，默认倒序
+}
+
+// EventPage 一页查询结果
+type EventPage struct {
+	Total  int64      `json:"total"` // 符合条件的事件总数
+	Events []LogEvent `json:"events"`
+}
+
+// build 生成查询条件，全文检索按相关度计分，其余条件只过滤不计分
+func (q EventQuery) build() object {
+	filter := []object{}
+	if q.EventID != "" {
+		filter = append(filter, object{"term": object{"event_id.keyword": q.EventID}})
+	}
+	if len(q.Hosts) > 0 {
+		filter = append(filter, object{"terms": object{"host.keyword": q.Hosts}})
+	}
+	if len(q.Tenants) > 0 {
+		filter = append(filter, object{"terms": object{"tenant.keyword": q.Tenants}})
+	}
+	if len(q.Tags) > 0 {
+		filter = append(filter, object{"terms": object{"tags.keyword": q.Tags}})
+	}
+	if q.TemplateID != "" {
+		filter = append(filter, object{"term": object{"template_id.keyword": q.TemplateID}})
+	}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		r := object{}
+		if !q.From.IsZero() {
+			r["gte"] = q.From
+		}
+		if !q.To.IsZero() {
+			r["lt"] = q.To
+		}
+		filter = append(filter, object{"range": object{"@timestamp": r}})
+	}
+	if q.MinSeverity > 0 {
+		filter = append(filter, object{"range": object{"severity_score": object{"gte": q.MinSeverity}}})
+	}
+
+	query := object{"filter": filter}
+	if q.Text != "" {
+		query["must"] = []object{{"multi_match": object{
+			"query":  q.Text,
+			"fields": []string{"content^2", "ai_result", "operator_note"},
+		}}}
+	}
+	return object{"bool": query}
+}
+
+// SearchEvents 按条件分页查询事件，有全文检索条件时按相关度排序，否则按时间排序
+func (e *ESClient) SearchEvents(ctx context.Context, q EventQuery) (*EventPage, error) {
+	if q.Size <= 0 {
+		q.Size = defaultQuerySize
+	}
+	if q.Offset < 0 || q.Offset+q.Size > maxQueryWindow {
+		return nil, fmt.Errorf("查询范围超出限制: offset+size 不能超过 %d", maxQueryWindow)
+	}
+	order := "desc"
+	if q.Ascending {
+		order = "asc"
+	}
+	sort := []object{{"@timestamp": object{"order": order}}}
+	if q.Text != "" {
+		sort = append([]object{{"_score": object{"order": "desc"}}}, sort...)
+	}
+
+	result, err := e.search(ctx, object{
+		"query":            q.build(),
+		"sort":             sort,
+		"from":             q.Offset,
+		"size":             q.Size,
+		"track_total_hits": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("查询ES事件失败: %w", err)
+	}
+	return &EventPage{Total: result.Hits.Total.Value, Events: result.events()}, nil
+}
+
+// GetEvent 按事件ID查询事件，不存在时返回 ErrEventNotFound
+func (e *ESClient) GetEvent(ctx context.Context, eventID string) (*LogEvent, error) {
+	page, err := e.SearchEvents(ctx, EventQuery{EventID: eventID, Size: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(page.Events) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrEventNotFound, eventID)
+	}
+	return &page.Events[0], nil
+}
@@ -0,0 +1,142 @@
+package esclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// TemplateStat 统计周期内某个日志模板的事件统计
+type TemplateStat struct {
+	TemplateID  string `json:"template_id"`
+	Count       int64  `json:"count"`
+	MaxSeverity int    `json:"max_severity"`
+	Hosts       int64  `json:"hosts"`
+	Sample      string `json:"sample"` // 严重性最高的一条日志内容
+}
+
+// TermCount 分组计数
+type TermCount struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// PeriodStats 统计周期内的事件汇总
+type PeriodStats struct {
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Total        int64          `json:"total"`
+	HighSeverity int64          `json:"high_severity"` // 严重性>=8的事件数
+	TopTemplates []TemplateStat `json:"top_templates"`
+	TopHosts     []TermCount    `json:"top_hosts"`
+	Hourly       []TermCount    `json:"hourly"` // 按小时的事件数
+}
+
+// Report 定期生成的事件汇总报告
+type Report struct {
+	Timestamp time.Time    `json:"@timestamp"`
+	From      time.Time    `json:"from"`
+	To        time.Time    `json:"to"`
+	Summary   string       `json:"summary"` // AI撰写的汇总报告
+	Stats     *PeriodStats `json:"stats"`
+
+	NoiseProposals []NoiseCandidate `json:"noise_proposals,omitempty"` // 建议抑制的噪声模板
+}
+
+// PeriodStats 统计 [from, to) 内的事件，top 为返回的模板和主机数量
+func (e *ESClient) PeriodStats(ctx context.Context, from, to time.Time, top int) (*PeriodStats, error) {
+	templates := object{
+		"terms": object{"field": "template_id.keyword", "size": top},
+		"aggs": object{
+			"max_severity": object{"max": object{"field": "severity_score"}},
+			"hosts":        object{"cardinality": object{"field": "host.keyword"}},
+			"sample": object{"top_hits": object{
+				"size":    1,
+				"sort":    []object{{"severity_score": object{"order": "desc"}}},
+				"_source": object{"includes": []string{"content"}},
+			}},
+		},
+	}
+
+	result, err := e.search(ctx, object{
+		"query":            object{"range": object{"@timestamp": object{"gte": from, "lt": to}}},
+		"size":             0,
+		"track_total_hits": true,
+		"aggs": object{
+			"templates": templates,
+			"hosts":     object{"terms": object{"field": "host.keyword", "size": top}},
+			"high":      object{"filter": object{"range": object{"severity_score": object{"gte": 8}}}},
+			"hourly": object{"date_histogram": object{
+				"field":          "@timestamp",
+				"fixed_interval": "1h",
+				"min_doc_count":  0,
+			}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计ES事件失败: %w", err)
+	}
+
+	var aggs struct {
+		High struct {
+			DocCount int64 `json:"doc_count"`
+		} `json:"high"`
+		Templates struct {
+			Buckets []struct {
+				Key         string `json:"key"`
+				DocCount    int64  `json:"doc_count"`
+				MaxSeverity struct {
+					Value *float64 `json:"value"`
+				} `json:"max_severity"`
+				Hosts struct {
+					Value int64 `json:"value"`
+				} `json:"hosts"`
+				Sample searchResponse `json:"sample"`
+			} `json:"buckets"`
+		} `json:"templates"`
+		Hosts struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"hosts"`
+		Hourly histogramBuckets `json:"hourly"`
+	}
+	if len(result.Aggregations) > 0 {
+		if err := json.Unmarshal(result.Aggregations, &aggs); err != nil {
+			return nil, fmt.Errorf("解析聚合结果失败: %w", err)
+		}
+	}
+
+	stats := &PeriodStats{From: from, To: to, Total: result.Hits.Total.Value, HighSeverity: aggs.High.DocCount}
+	for _, bucket := range aggs.Templates.Buckets {
+		stat := TemplateStat{TemplateID: bucket.Key, Count: bucket.DocCount, Hosts: bucket.Hosts.Value}
+		if bucket.MaxSeverity.Value != nil {
+			stat.MaxSeverity = int(*bucket.MaxSeverity.Value)
+		}
+		if samples := bucket.Sample.events(); len(samples) > 0 {
+			stat.Sample = samples[0].Content
+		}
+		stats.TopTemplates = append(stats.TopTemplates, stat)
+	}
+	for _, bucket := range aggs.Hosts.Buckets {
+		stats.TopHosts = append(stats.TopHosts, TermCount{Key: bucket.Key, Count: bucket.DocCount})
+	}
+	for _, bucket := range aggs.Hourly.Buckets {
+		key := time.UnixMilli(bucket.Key).Format("01-02 15:00")
+		stats.Hourly = append(stats.Hourly, TermCount{Key: key, Count: bucket.DocCount})
+	}
+	return stats, nil
+}
+
+// IndexReport 将汇总报告写入报告索引（按月）
+func (e *ESClient) IndexReport(ctx context.Context, index string, report Report) error {
+	indexName := fmt.Sprintf("%s-%s", index, report.To.Format("2006.01"))
+	if err := e.do(ctx, esapi.IndexRequest{Index: indexName, Body: jsonBody(report)}, nil); err != nil {
+		return fmt.Errorf("写入汇总报告失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package esclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"log-ai-analyzer/metrics"
+)
+
+// 按天滚动的索引名称中的日期格式
+const indexDateLayout = "2006.01.02"
+
+// 清理过期索引的执行间隔
+const retentionCheckInterval = time.Hour
+
+// 数据流后备索引的滚动条件
+const (
+	rolloverMaxAge       = "1d"
+	rolloverMaxShardSize = "50gb"
+)
+
+// lifecyclePolicyName 事件索引的ILM策略名称
+func (e *ESClient) lifecyclePolicyName() string {
+	return e.index + "-retention"
+}
+
+// SetupILM 创建保留 days 天后删除的ILM策略（days 为0时不删除），并关联到已有的事件索引或数据流，
+// 之后安装的索引模板也会为新索引关联该策略；写入数据流时策略同时负责按天或分片大小滚动后备索引。
+// 集群不支持ILM时返回错误
+func (e *ESClient) SetupILM(ctx context.Context, days int) error {
+	phases := object{}
+	if e.dataStream != "" {
+		phases["hot"] = object{
+			"actions": object{
+				"rollover": object{"max_age": rolloverMaxAge, "max_primary_shard_size": rolloverMaxShardSize},
+			},
+		}
+	}
+	if days > 0 {
+		phases["delete"] = object{
+			"min_age": fmt.Sprintf("%dd", days),
+			"actions": object{"delete": object{}},
+		}
+	}
+	policy := object{"policy": object{"phases": phases}}
+	name := e.lifecyclePolicyName()
+	if err := e.do(ctx, esapi.ILMPutLifecycleRequest{Policy: name, Body: jsonBody(policy)}, nil); err != nil {
+		return fmt.Errorf("创建ILM策略失败: %w", err)
+	}
+	e.lifecyclePolicy = name
+	if e.dataStream != "" {
+		return e.attachDataStreamPolicy(ctx, name)
+	}
+
+	indices, err := e.eventIndices(ctx)
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(indices))
+	for index := range indices {
+		names = append(names, index)
+	}
+	err = e.do(ctx, esapi.IndicesPutSettingsRequest{
+		Index: names,
+		Body:  jsonBody(object{"index.lifecycle.name": name}),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("为已有索引关联ILM策略失败: %w", err)
+	}
+	return nil
+}
+
+// attachDataStreamPolicy 为已存在的数据流的后备索引关联ILM策略，数据流尚未创建时由模板关联
+func (e *ESClient) attachDataStreamPolicy(ctx context.Context, name string) error {
+	err := e.do(ctx, esapi.IndicesPutSettingsRequest{
+		Index: []string{e.dataStream},
+		Body:  jsonBody(object{"index.lifecycle.name": name}),
+	}, nil)
+	var re *ResponseError
+	if errors.As(err, &re) && re.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("为数据流关联ILM策略失败: %w", err)
+	}
+	return nil
+}
+
+// eventIndices 返回事件索引及其覆盖时间段的结束时间，名称不符合索引名称模板的索引（如报告索引）不包含在内
+func (e *ESClient) eventIndices(ctx context.Context) (map[string]time.Time, error) {
+	var rows []struct {
+		Index string `json:"index"`
+	}
+	err := e.do(ctx, esapi.CatIndicesRequest{
+		Index:  []string{e.index + "-*"},
+		Format: "json",
+		H:      []string{"index"},
+	}, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("查询ES索引列表失败: %w", err)
+	}
+	indices := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		if _, end, ok := e.namer.period(row.Index); ok {
+			indices[row.Index] = end
+		}
+	}
+	return indices, nil
+}
+
+// SetTenantRetention 按租户设置事件索引的保留天数，覆盖 DeleteExpiredIndices 的 days 参数，
+// 只对名称模板包含 {tenant} 的索引生效
+func (e *ESClient) SetTenantRetention(days map[string]int) {
+	e.tenantRetention = make(map[string]int, len(days))
+	for tenant, d := range days {
+		e.tenantRetention[indexNameValue(tenant)] = d
+	}
+}
+
+// HasIndexRetention 返回是否有租户或严重性分层单独设置了保留期
+func (e *ESClient) HasIndexRetention() bool {
+	_, hot := e.tiers.retentionDays(TierHot)
+	_, noise := e.tiers.retentionDays(TierNoise)
+	return len(e.tenantRetention) > 0 || hot || noise
+}
+
+// retentionDays 返回事件索引的保留天数：优先使用严重性分层的设置，其次是所属租户的设置
+func (e *ESClient) retentionDays(index string, days int) int {
+	if d, ok := e.tiers.retentionDays(e.namer.field(index, "tier")); ok {
+		return d
+	}
+	if d, ok := e.tenantRetention[e.namer.field(index, "tenant")]; ok {
+		return d
+	}
+	return days
+}
+
+// DeleteExpiredIndices 删除全部事件都早于 now 前 days 天（或所属分层、租户的保留天数）的索引，供不支持ILM的集群使用，
+// 保留天数为0的索引不删除
+func (e *ESClient) DeleteExpiredIndices(ctx context.Context, days int, now time.Time) ([]string, error) {
+	indices, err := e.eventIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	var expired []string
+	for index, end := range indices {
+		keep := e.retentionDays(index, days)
+		if keep > 0 && !end.After(today.AddDate(0, 0, -keep)) {
+			expired = append(expired, index)
+		}
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	if err := e.do(ctx, esapi.IndicesDeleteRequest{Index: expired}, nil); err != nil {
+		return nil, fmt.Errorf("删除过期索引失败: %w", err)
+	}
+	metrics.ESIndicesDeletedCount.Add(float64(len(expired)))
+	return expired, nil
+}
+
+// RunRetention 每小时删除一次过期的事件索引，直到 ctx 结束
+func (e *ESClient) RunRetention(ctx context.Context, days int) {
+	ticker := time.NewTicker(retentionCheckInterval)
+	defer ticker.Stop()
+	for {
+		deleted, err := e.DeleteExpiredIndices(ctx, days, time.Now())
+		if err != nil {
+			log.Printf("清理过期ES索引失败: %v", err)
+		} else if len(deleted) > 0 {
+			log.Printf("已删除超过保留期的ES索引: %s", strings.Join(deleted, ", "))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package esclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+
+	"log-ai-analyzer/diag"
+	"log-ai-analyzer/metrics"
+)
+
+// 溢出队列单个分段文件的最大字节数，超过后写入新的分段
+const spillSegmentBytes = 16 << 20
+
+// 重放溢出队列时每批提交的文档数
+const spillReplayBatch = 500
+
+// 检查溢出队列是否需要重放的间隔
+const spillReplayInterval = 10 * time.Second
+
+// spillQueue 磁盘溢出队列：ES不可用时写入失败的文档按行追加到分段文件，集群恢复后按顺序重放
+type spillQueue struct {
+	dir      string
+	maxBytes int64
+
+	replayMu sync.Mutex // 定期重放和手动触发的重放不同时进行
+
+	mu       sync.Mutex
+	file     *os.File // 正在写入的分段，重放前关闭，之后的文档写入新的分段
+	fileSize int64
+	size     int64 // 队列在磁盘上的总字节数
+	docs     int64 // 队列中的文档数
+}
+
+// EnableSpill 启用磁盘溢出队列，dir 中残留的文档（如上次停止时未重放完的）会在之后重放
+func (e *ESClient) EnableSpill(dir string, maxBytes int64) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建ES溢出队列目录失败: %w", err)
+	}
+	q := &spillQueue{dir: dir, maxBytes: maxBytes}
+	segments, err := q.segments()
+	if err != nil {
+		return err
+	}
+	for _, path := range segments {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("读取ES溢出队列失败: %w", err)
+		}
+		lines, err := countLines(path)
+		if err != nil {
+			return fmt.Errorf("读取ES溢出队列失败: %w", err)
+		}
+		q.size += info.Size()
+		q.docs += lines
+	}
+	q.updateMetrics()
+	e.spill = q
+	return nil
+}
+
+// countLines 统计分段文件中的文档数
+func countLines(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return int64(bytes.Count(data, []byte{'\n'})), nil
+}
+
+// segments 返回按写入顺序排列的分段文件
+func (q *spillQueue) segments() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(q.dir, "spill-*.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("读取ES溢出队列失败: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (q *spillQueue) updateMetrics() {
+	metrics.ESSpillQueueDocs.Set(float64(q.docs))
+	metrics.ESSpillQueueBytes.Set(float64(q.size))
+}
+
+// push 将文档追加到队列，返回因队列已满或写盘失败而丢弃的文档
+func (q *spillQueue) push(items []bulkItem) []bulkItem {
+	if q == nil {
+		return items
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var dropped []bulkItem
+	for i, item := range items {
+		line, err := json.Marshal(item)
+		if err != nil {
+			dropped = append(dropped, item)
+			continue
+		}
+		line = append(line, '\n')
+		if q.maxBytes > 0 && q.size+int64(len(line)) > q.maxBytes {
+			dropped = append(dropped, items[i:]...)
+			break
+		}
+		if err := q.write(line); err != nil {
+			log.Printf("写入ES溢出队列失败: %v", err)
+			dropped = append(dropped, items[i:]...)
+			break
+		}
+		q.size += int64(len(line))
+		q.docs++
+	}
+	q.updateMetrics()
+	metrics.ESSpillDroppedCount.Add(float64(len(dropped)))
+	return dropped
+}
+
+// write 追加一行到当前分段，分段不存在或已写满时创建新的分段
+func (q *spillQueue) write(line []byte) error {
+	if q.file != nil && q.fileSize+int64(len(line)) > spillSegmentBytes {
+		q.closeSegment()
+	}
+	if q.file == nil {
+		name := fmt.Sprintf("spill-%020d.jsonl", time.Now().UnixNano())
+		f, err := os.OpenFile(filepath.Join(q.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		q.file, q.fileSize = f, 0
+	}
+	n, err := q.file.Write(line)
+	q.fileSize += int64(n)
+	return err
+}
+
+func (q *spillQueue) closeSegment() {
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+}
+
+// close 关闭正在写入的分段
+func (q *spillQueue) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeSegment()
+}
+
+// replay 按顺序重放所有分段，send 成功后删除分段；send 失败时停止，分段保留到下次重放
+func (q *spillQueue) replay(send func([]bulkItem) error) error {
+	q.replayMu.Lock()
+	defer q.replayMu.Unlock()
+	q.mu.Lock()
+	q.closeSegment()
+	segments, err := q.segments()
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range segments {
+		if err := q.replaySegment(path, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaySegment 分批重放一个分段，分段中途失败时整个分段下次重新重放，已写入的文档由文档ID去重
+func (q *spillQueue) replaySegment(path string, send func([]bulkItem) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取ES溢出队列失败: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), spillSegmentBytes)
+	var batch []bulkItem
+	var lines int64
+	for scanner.Scan() {
+		lines++
+		var item bulkItem
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			log.Printf("跳过ES溢出队列中无法解析的文档 [%s]: %v", filepath.Base(path), err)
+			continue
+		}
+		batch = append(batch, item)
+		if len(batch) >= spillReplayBatch {
+			if err := send(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取ES溢出队列失败: %w", err)
+	}
+	if len(batch) > 0 {
+		if err := send(batch); err != nil {
+			return err
+		}
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("读取ES溢出队列失败: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("删除已重放的ES溢出队列分段失败: %w", err)
+	}
+	q.mu.Lock()
+	q.size -= info.Size()
+	q.docs -= lines
+	q.updateMetrics()
+	q.mu.Unlock()
+	return nil
+}
+
+// spillItems 将因 cause 写入失败的文档放入溢出队列，未启用溢出队列或队列已满时计为写入失败，返回丢弃的文档数
+func (e *ESClient) spillItems(items []bulkItem, cause error) int {
+	dropped := e.spill.push(items)
+	if len(dropped) > 0 {
+		log.Printf("ES写入失败，丢弃 %d 个文档: %v", len(dropped), cause)
+		diag.Recordf("elasticsearch", "ES写入失败，丢弃 %d 个文档: %v", len(dropped), cause)
+		countWrites(metrics.ESWriteErrorCount, dropped...)
+		countDropped(metrics.DropESSpillFull, dropped...)
+	}
+	return len(dropped)
+}
+
+// replayBatch 重放一批溢出的文档：文档带有稳定的文档ID，已存在（上次重放已写入）的文档视为成功；
+// 集群仍不可用时返回错误，其余写入失败的文档记录日志后丢弃
+func (e *ESClient) replayBatch(items []bulkItem) error {
+	var body bytes.Buffer
+	for _, item := range items {
+		body.Write(item.encode())
+	}
+	var resp bulkResponse
+	if err := e.do(context.Background(), esapi.BulkRequest{Body: &body}, &resp); err != nil {
+		e.breaker.failure(err)
+		return fmt.Errorf("重放ES溢出队列失败: %w", err)
+	}
+
+	var succeeded, failed []bulkItem
+	var outage bool
+	for i, item := range resp.Items {
+		if i >= len(items) {
+			break
+		}
+		for _, result := range item {
+			switch {
+			case result.Error == nil, result.Status == 409:
+				succeeded = append(succeeded, items[i])
+			case outageStatus(result.Status):
+				outage = true
+			default:
+				failed = append(failed, items[i])
+				log.Printf("ES溢出文档写入失败 [索引: %s, 状态: %d]: %s: %s", result.Index, result.Status, result.Error.Type, result.Error.Reason)
+			}
+		}
+	}
+	countWrites(metrics.ESWriteSuccessCount, succeeded...)
+	countWrites(metrics.ESWriteErrorCount, failed...)
+	countDropped(metrics.DropESRejected, failed...)
+	metrics.ESSpillReplayedCount.Add(float64(len(succeeded)))
+	if outage {
+		err := fmt.Errorf("部分文档被ES拒绝（限流或不可用）")
+		e.breaker.failure(err)
+		return fmt.Errorf("重放ES溢出队列失败: %w", err)
+	}
+	e.breaker.success()
+	return nil
+}
+
+// RunSpillReplay 定期检查溢出队列，熔断解除后按顺序重放，直到 ctx 结束；未启用溢出队列时直接返回
+func (e *ESClient) RunSpillReplay(ctx context.Context) {
+	if e.spill == nil {
+		return
+	}
+	ticker := time.NewTicker(spillReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !e.online.Load() || !e.breaker.allow() {
+			continue
+		}
+		if _, err := e.ReplaySpill(); err != nil {
+			log.Printf("%v，%s 后重试", err, spillReplayInterval)
+		}
+	}
+}
+
+// SpillDocs 返回溢出队列中等待重放的文档数，未启用溢出队列时为0
+func (e *ESClient) SpillDocs() int64 {
+	if e.spill == nil {
+		return 0
+	}
+	e.spill.mu.Lock()
+	defer e.spill.mu.Unlock()
+	return e.spill.docs
+}
+
+// ReplaySpill 立即按顺序重放溢出队列，返回重放前队列中的文档数；集群未连接或写入熔断中时返回错误
+func (e *ESClient) ReplaySpill() (int64, error) {
+	pending := e.SpillDocs()
+	if pending == 0 {
+		return 0, nil
+	}
+	if !e.online.Load() {
+		return 0, fmt.Errorf("ES尚未连接，暂不重放溢出队列")
+	}
+	if !e.breaker.allow() {
+		return 0, ErrCircuitOpen
+	}
+	if err := e.spill.replay(e.replayBatch); err != nil {
+		return 0, err
+	}
+	log.Printf("✅ ES溢出队列中的 %d 个文档已重放", pending)
+	return pending, nil
+}
@@ -0,0 +1,121 @@
+package esclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// SchemaVersion 事件文档的结构版本，增删字段或修改映射时递增，索引模板使用同一版本号；
+// 旧版本的索引可通过 MigrateIndex 按当前映射重建
+const SchemaVersion = 8
+
+// 索引模板优先级，高于默认的内置模板
+const indexTemplatePriority = 200
+
+// keywordField 精确匹配字段，附带 .keyword 子字段，
+// 使按 .keyword 查询和聚合的语句同时适用于安装模板之前按动态映射创建的历史索引
+func keywordField() object {
+	return object{
+		"type": "keyword",
+		"fields": object{
+			"keyword": object{"type": "keyword"},
+		},
+	}
+}
+
+// indexTemplate 事件索引的模板，显式声明字段类型，避免动态映射把主机、标签等识别为 text 导致 Kibana 无法聚合
+// 启用ILM时同时为新索引关联保留策略；写入数据流时模板只匹配数据流，并声明 data_stream 由ES自动创建
+func (e *ESClient) indexTemplate() object {
+	template := object{
+		"mappings": object{
+			// 实例的静态标签名称不固定，统一映射为 keyword
+			"dynamic_templates": []object{
+				{"labels": object{"path_match": "labels.*", "mapping": keywordField()}},
+			},
+			"properties": object{
+				"@timestamp":       object{"type": "date"},
+				"event_id":         keywordField(),
+				"host":             keywordField(),
+				"tenant":           keywordField(),
+				"service":          keywordField(),
+				"team":             keywordField(),
+				"tags":             keywordField(),
+				"template_id":      keywordField(),
+				"fingerprint":      keywordField(),
+				"incident_id":      keywordField(),
+				"trace_id":         keywordField(),
+				"instance":         keywordField(),
+				"feedback":         keywordField(),
+				"severity_score":   object{"type": "integer"},
+				"schema_version":   object{"type": "integer"},
+				"occurrence_count": object{"type": "integer"},
+				"content": object{
+					"type": "text",
+					"fields": object{
+						"keyword": object{"type": "keyword", "ignore_above": 1024},
+					},
+				},
+				"ai_result":     object{"type": "text"},
+				"operator_note": object{"type": "text"},
+				"related_events": object{
+					"properties": object{
+						"doc_id":         keywordField(),
+						"host":           keywordField(),
+						"file_path":      keywordField(),
+						"line_number":    object{"type": "integer"},
+						"severity_score": object{"type": "integer"},
+						"summary":        object{"type": "text"},
+					},
+				},
+			},
+		},
+	}
+	if e.namer.naming.Alias != "" {
+		template["aliases"] = object{e.namer.naming.Alias: object{}}
+	}
+	if e.lifecyclePolicy != "" {
+		template["settings"] = object{"index.lifecycle.name": e.lifecyclePolicy}
+	}
+	body := object{
+		"index_patterns": []string{e.IndexPattern()},
+		"version":        SchemaVersion,
+		"priority":       indexTemplatePriority,
+		"template":       template,
+	}
+	if e.dataStream != "" {
+		body["data_stream"] = object{}
+	}
+	return body
+}
+
+// IndexPattern 返回索引模板匹配的名称模式，名称模板总以 <前缀>- 开头
+func (e *ESClient) IndexPattern() string {
+	if e.dataStream != "" {
+		return e.dataStream
+	}
+	return e.index + "-*"
+}
+
+// EnsureIndexTemplate 安装或更新事件索引模板（composable index template），只对之后新建的索引或数据流后备索引生效；
+// 配置了查询别名时同时为已有的索引添加别名
+func (e *ESClient) EnsureIndexTemplate(ctx context.Context) error {
+	err := e.do(ctx, esapi.IndicesPutIndexTemplateRequest{
+		Name: e.index,
+		Body: jsonBody(e.indexTemplate()),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("安装ES索引模板失败: %w", err)
+	}
+	if alias := e.namer.naming.Alias; alias != "" {
+		err := e.do(ctx, esapi.IndicesPutAliasRequest{Index: []string{e.IndexPattern()}, Name: alias}, nil)
+		var re *ResponseError
+		if err != nil && !(errors.As(err, &re) && re.StatusCode == http.StatusNotFound) {
+			return fmt.Errorf("为已有索引添加别名 %s 失败: %w", alias, err)
+		}
+	}
+	return nil
+}
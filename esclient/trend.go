@@ -0,0 +1,116 @@
+package esclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 同比的间隔：与一周前同一时间段比较，避开工作日和周末的差异
+const trendCompareOffset = 7 * 24 * time.Hour
+
+// TrendQuery 出现次数统计的条件，TemplateID 和 Tag 至少设置一个
+type TrendQuery struct {
+	TemplateID string
+	Tag        string
+	Tenants    []string // 任一租户，为空时不限
+}
+
+// WindowCount 一个时间窗口内的出现次数及一周前同一时间段的次数
+type WindowCount struct {
+	Window        string   `json:"window"`
+	Count         int64    `json:"count"`
+	LastWeek      int64    `json:"last_week"`
+	ChangePercent *float64 `json:"change_percent,omitempty"` // 环比一周前的变化百分比，一周前没有出现时为空
+}
+
+// OccurrenceTrend 日志模板或标签的出现次数和同比趋势，用于判断问题是新出现的还是一直存在
+type OccurrenceTrend struct {
+	TemplateID string        `json:"template_id,omitempty"`
+	Tag        string        `json:"tag,omitempty"`
+	FirstSeen  *time.Time    `json:"first_seen,omitempty"` // 索引中最早的事件，索引保留期之前的事件不计入
+	LastSeen   *time.Time    `json:"last_seen,omitempty"`
+	New        bool          `json:"new"` // 首次出现在一周之内，没有同比基准
+	Windows    []WindowCount `json:"windows"`
+}
+
+// OccurrenceTrend 统计截止到 now 的各时间窗口内的出现次数，并与一周前同一时间段比较
+func (e *ESClient) OccurrenceTrend(ctx context.Context, q TrendQuery, windows []time.Duration, now time.Time) (*OccurrenceTrend, error) {
+	if q.TemplateID == "" && q.Tag == "" {
+		return nil, fmt.Errorf("需要指定日志模板或标签")
+	}
+	filter := []object{}
+	if q.TemplateID != "" {
+		filter = append(filter, object{"term": object{"template_id.keyword": q.TemplateID}})
+	}
+	if q.Tag != "" {
+		filter = append(filter, object{"term": object{"tags.keyword": q.Tag}})
+	}
+	if len(q.Tenants) > 0 {
+		filter = append(filter, object{"terms": object{"tenant.keyword": q.Tenants}})
+	}
+
+	var ranges []object
+	for _, w := range windows {
+		ranges = append(ranges,
+			object{"key": "current-" + w.String(), "from": now.Add(-w), "to": now},
+			object{"key": "previous-" + w.String(), "from": now.Add(-trendCompareOffset - w), "to": now.Add(-trendCompareOffset)},
+		)
+	}
+	result, err := e.search(ctx, object{
+		"query": object{"bool": object{"filter": filter}},
+		"size":  0,
+		"aggs": object{
+			"first_seen": object{"min": object{"field": "@timestamp"}},
+			"last_seen":  object{"max": object{"field": "@timestamp"}},
+			"windows":    object{"date_range": object{"field": "@timestamp", "ranges": ranges, "keyed": true}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("统计出现次数失败: %w", err)
+	}
+
+	var aggs struct {
+		FirstSeen struct {
+			Value *float64 `json:"value"`
+		} `json:"first_seen"`
+		LastSeen struct {
+			Value *float64 `json:"value"`
+		} `json:"last_seen"`
+		Windows struct {
+			Buckets map[string]struct {
+				DocCount int64 `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"windows"`
+	}
+	if len(result.Aggregations) > 0 {
+		if err := json.Unmarshal(result.Aggregations, &aggs); err != nil {
+			return nil, fmt.Errorf("解析聚合结果失败: %w", err)
+		}
+	}
+
+	trend := &OccurrenceTrend{TemplateID: q.TemplateID, Tag: q.Tag, Windows: make([]WindowCount, 0, len(windows))}
+	if v := aggs.FirstSeen.Value; v != nil {
+		t := time.UnixMilli(int64(*v))
+		trend.FirstSeen = &t
+		trend.New = now.Sub(t) < trendCompareOffset
+	}
+	if v := aggs.LastSeen.Value; v != nil {
+		t := time.UnixMilli(int64(*v))
+		trend.LastSeen = &t
+	}
+	for _, w := range windows {
+		c := WindowCount{
+			Window:   w.String(),
+			Count:    aggs.Windows.Buckets["current-"+w.String()].DocCount,
+			LastWeek: aggs.Windows.Buckets["previous-"+w.String()].DocCount,
+		}
+		if c.LastWeek > 0 {
+			change := float64(c.Count-c.LastWeek) / float64(c.LastWeek) * 100
+			c.ChangePercent = &change
+		}
+		trend.Windows = append(trend.Windows, c)
+	}
+	return trend, nil
+}
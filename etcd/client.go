@@ -0,0 +1,112 @@
+// Package etcd 访问 etcd v3 HTTP/JSON 网关的最小客户端，供多实例协调和集中配置使用；
+// 网关要求键和值以 base64 编码，int64 以字符串返回
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KV 响应中的键值，Key 和 Value 为 base64 编码
+type KV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Lease string `json:"lease"`
+}
+
+// Client etcd v3 HTTP/JSON 网关客户端
+type Client struct {
+	url    string
+	token  func() string
+	client *http.Client
+}
+
+// NewClient 创建客户端，token 返回空时不发送认证头；timeout 为0时不限制单个请求的耗时，由调用方的 ctx 控制，
+// watch 请求可以长时间保持
+func NewClient(url string, token func() string, timeout time.Duration) *Client {
+	if token == nil {
+		token = func() string { return "" }
+	}
+	return &Client{url: strings.TrimRight(url, "/"), token: token, client: &http.Client{Timeout: timeout}}
+}
+
+// Do 发送请求，result 不为nil时解析响应；keepalive 等接口的响应为 JSON 流，只解析第一条
+func (c *Client) Do(ctx context.Context, path string, body, result any) error {
+	resp, err := c.send(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("解析 etcd 响应失败: %w", err)
+	}
+	return nil
+}
+
+// Stream 发送请求并返回响应体，用于 watch 等逐行返回 JSON 的接口，调用方负责关闭
+func (c *Client) Stream(ctx context.Context, path string, body any) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// send 向网关发送 JSON 请求并检查状态码
+func (c *Client) send(ctx context.Context, path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("编码 etcd 请求失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建 etcd 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := c.token(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 etcd 失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// Encode 将键或值编码为网关要求的 base64
+func Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// Decode 解码响应中 base64 编码的键或值
+func Decode(s string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	return string(data), err
+}
+
+// PrefixEnd 返回前缀范围查询的结束键：前缀最后一个字节加1
+func PrefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return "\x00"
+}
@@ -0,0 +1,116 @@
+// Package feature 实验性功能的开关：按部署在配置中开启或关闭，运行中可通过 /api/features 临时切换，无需单独构建
+package feature
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"log-ai-analyzer/metrics"
+)
+
+// Flag 实验性功能的名称
+type Flag string
+
+// 已注册的实验性功能
+const (
+	AutoSuppression Flag = "auto_suppression" // NOISE_SUPPRESSION=apply 时自动写入抑制规则文件，关闭时只在报告中建议
+	AITools         Flag = "ai_tools"         // AI_TOOLS=true 时允许模型通过工具调用查询更多上下文
+)
+
+// definition 功能开关的说明和默认状态
+type definition struct {
+	description string
+	enabled     bool
+}
+
+// registry 已注册的功能开关，默认开启的功能在对应的配置项开启后才会生效
+var registry = map[Flag]definition{
+	AutoSuppression: {description: "噪声抑制 apply 模式自动写入抑制规则文件", enabled: true},
+	AITools:         {description: "AI分析时允许模型调用工具查询近期事件和模板历史", enabled: true},
+}
+
+// State 功能开关的当前状态
+type State struct {
+	Name        Flag   `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+}
+
+var (
+	mu      sync.RWMutex
+	enabled = defaults()
+)
+
+// defaults 返回各功能开关的默认状态
+func defaults() map[Flag]bool {
+	flags := make(map[Flag]bool, len(registry))
+	for name, def := range registry {
+		flags[name] = def.enabled
+	}
+	return flags
+}
+
+// Known 判断功能开关是否已注册
+func Known(name string) bool {
+	_, ok := registry[Flag(name)]
+	return ok
+}
+
+// Init 以配置中的开关覆盖默认状态，未注册的开关被忽略
+func Init(overrides map[string]bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = defaults()
+	for name, on := range overrides {
+		if Known(name) {
+			enabled[Flag(name)] = on
+		}
+	}
+	for name, on := range enabled {
+		setGauge(name, on)
+	}
+}
+
+// Enabled 判断功能是否开启
+func Enabled(f Flag) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled[f]
+}
+
+// Set 在运行中开启或关闭功能，重启后恢复为配置中的状态
+func Set(name string, on bool) error {
+	if !Known(name) {
+		return fmt.Errorf("未知的功能开关: %s", name)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	enabled[Flag(name)] = on
+	setGauge(Flag(name), on)
+	return nil
+}
+
+// List 返回按名称排序的所有功能开关及其当前状态
+func List() []State {
+	mu.RLock()
+	defer mu.RUnlock()
+	states := make([]State, 0, len(registry))
+	for name, def := range registry {
+		states = append(states, State{Name: name, Description: def.description, Enabled: enabled[name], Default: def.enabled})
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+// setGauge 更新功能开关状态指标
+func setGauge(name Flag, on bool) {
+	v := 0.0
+	if on {
+		v = 1
+	}
+	metrics.FeatureFlagEnabled.WithLabelValues(string(name)).Set(v)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"log-ai-analyzer/feature"
+)
+
+// featureRequest 切换功能开关的请求
+type featureRequest struct {
+	Name    string `json:"name"`
+	Enabled *bool  `json:"enabled"`
+}
+
+// featuresHandler 提供 /api/features 接口：GET 返回所有实验性功能的开关状态，
+// POST 以 name、enabled 临时开启或关闭功能，重启后恢复为 FEATURE_FLAGS 中的状态
+func featuresHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			var req featureRequest
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "invalid request body", http.StatusBadRequest)
+					return
+				}
+			} else {
+				req.Name = r.FormValue("name")
+				if v, err := strconv.ParseBool(r.FormValue("enabled")); err == nil {
+					req.Enabled = &v
+				}
+			}
+			if req.Name == "" || req.Enabled == nil {
+				http.Error(w, "name and enabled are required", http.StatusBadRequest)
+				return
+			}
+			if err := feature.Set(req.Name, *req.Enabled); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			log.Printf("功能开关已切换: %s=%t", req.Name, *req.Enabled)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(feature.List())
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/metrics"
+)
+
+// feedbackRequest AI分析评价请求
+type feedbackRequest struct {
+	EventID string `json:"event_id"`
+	Rating  string `json:"rating"` // helpful、wrong
+	Note    string `json:"note"`   // 更正说明或处理记录
+}
+
+// feedbackHandler 提供 /api/feedback 接口
+// GET 用于告警消息中的评价链接，记录评价后返回填写更正说明的页面；
+// POST 接受 JSON 或表单提交的评价和更正说明
+func feedbackHandler(esClient *esclient.ESClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req feedbackRequest
+		switch r.Method {
+		case http.MethodGet:
+			req.EventID = r.URL.Query().Get("event_id")
+			req.Rating = r.URL.Query().Get("rating")
+		case http.MethodPost:
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					http.Error(w, "invalid request body", http.StatusBadRequest)
+					return
+				}
+			} else {
+				req.EventID = r.FormValue("event_id")
+				req.Rating = r.FormValue("rating")
+				req.Note = strings.TrimSpace(r.FormValue("note"))
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if req.EventID == "" {
+			http.Error(w, "event_id is required", http.StatusBadRequest)
+			return
+		}
+		if req.Rating != esclient.FeedbackHelpful && req.Rating != esclient.FeedbackWrong {
+			http.Error(w, "rating must be helpful or wrong", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		if err := esClient.AddFeedback(ctx, req.EventID, req.Rating, req.Note); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, esclient.ErrEventNotFound) {
+				status = http.StatusNotFound
+			}
+			log.Printf("保存AI分析评价失败 [EventID: %s]: %v", req.EventID, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		metrics.AIFeedbackCount.WithLabelValues(req.Rating).Inc()
+		log.Printf("收到AI分析评价 [EventID: %s]: %s", req.EventID, req.Rating)
+
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, feedbackPage(req))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}
+}
+
+// feedbackPage 评价成功后展示的页面，可继续补充更正说明
+func feedbackPage(req feedbackRequest) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>AI分析评价</title></head>
+<body>
+<p>已记录评价，感谢反馈。</p>
+<form method="post" action="/api/feedback">
+<input type="hidden" name="event_id" value="%s">
+<input type="hidden" name="rating" value="%s">
+<p>补充更正说明或实际处理方式（可选），将作为后续同类问题分析的参考：</p>
+<textarea name="note" rows="6" cols="60"></textarea><br>
+<button type="submit">提交</button>
+</form>
+</body></html>
+`, html.EscapeString(req.EventID), html.EscapeString(req.Rating))
+}
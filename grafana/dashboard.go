@@ -0,0 +1,236 @@
+package grafana
+
+import (
+	"fmt"
+)
+
+// 仪表板的固定UID，重复创建时覆盖同一仪表板
+const DashboardUID = "logai-metrics"
+
+// 仪表板的标题
+const DashboardTitle = "LogAI 运行监控"
+
+// 仪表板的格式版本，导入时由 Grafana 迁移到当前版本
+const schemaVersion = 39
+
+// 面板按24列排列，每行两个面板
+const (
+	gridWidth   = 24
+	panelHeight = 8
+)
+
+type object = map[string]any
+
+// datasourceRef 面板使用的数据源，由仪表板变量 datasource 选择
+var datasourceRef = object{"type": "prometheus", "uid": "${datasource}"}
+
+// query 面板中的一条 PromQL 查询
+type query struct {
+	expr   string
+	legend string
+}
+
+// panel 面板定义
+type panel struct {
+	title   string
+	kind    string // timeseries、stat 或 piechart
+	unit    string // Grafana 的单位，如 s、short、currencyUSD
+	queries []query
+}
+
+// row 一组面板，以可折叠的行分隔
+type row struct {
+	title  string
+	panels []panel
+}
+
+// instanceFilter 按仪表板变量 instance_id 过滤的标签选择器，选择全部实例时也匹配未设置实例标识的指标
+const instanceFilter = `{instance_id=~"$instance_id"}`
+
+// rows 仪表板的内容：事件采集、严重性、AI分析、ES写入和告警
+func rows() []row {
+	return []row{
+		{title: "事件采集", panels: []panel{
+			{title: "事件采集速率（按严重性）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (severity) (rate(log_events_collected_total%s[5m]))", instanceFilter), "{{severity}}"},
+			}},
+			{title: "事件采集速率最高的日志文件", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("topk(10, sum by (file) (rate(log_events_collected_total%s[5m])))", instanceFilter), "{{file}}"},
+			}},
+			{title: "端到端处理延迟 P95", kind: "timeseries", unit: "s", queries: []query{
+				{fmt.Sprintf("histogram_quantile(0.95, sum by (le, stage) (rate(event_processing_lag_seconds_bucket%s[5m])))", instanceFilter), "{{stage}}"},
+			}},
+			{title: "待处理积压", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(pipeline_backlog_events%s)", instanceFilter), "积压事件"},
+				{fmt.Sprintf("sum(event_queue_length%s)", instanceFilter), "优先级队列"},
+				{fmt.Sprintf("sum(disk_queue_events%s)", instanceFilter), "磁盘事件队列"},
+				{fmt.Sprintf("max(collector_paused%s)", instanceFilter), "采集暂停"},
+			}},
+			{title: "优先级队列等待时间 P95（按严重性）", kind: "timeseries", unit: "s", queries: []query{
+				{fmt.Sprintf("histogram_quantile(0.95, sum by (le, severity) (rate(event_queue_wait_seconds_bucket%s[5m])))", instanceFilter), "{{severity}}"},
+			}},
+			{title: "工作池", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(worker_pool_size%s)", instanceFilter), "工作协程"},
+				{fmt.Sprintf("sum(worker_pool_busy%s)", instanceFilter), "处理中"},
+			}},
+			{title: "流量控制：各环节每秒等待下游的时间（多个协程同时等待时累加）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (stage) (rate(flow_throttled_seconds_total%s[5m]))", instanceFilter), "{{stage}}"},
+			}},
+			{title: "多实例协调：各实例认领的文件数", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (instance_id) (coordination_owned_files%s)", instanceFilter), "{{instance_id}}"},
+				{fmt.Sprintf("max(coordination_members%s)", instanceFilter), "存活实例"},
+			}},
+			{title: "丢弃事件（每小时，按原因）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (reason) (increase(events_dropped_total%s[1h]))", instanceFilter), "{{reason}}"},
+			}},
+		}},
+		{title: "严重性分布", panels: []panel{
+			{title: "最近一小时严重性分布", kind: "piechart", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (severity) (events_last_hour%s)", instanceFilter), "{{severity}}"},
+			}},
+			{title: "最近一小时出现最多的日志模板", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("topk(10, sum by (template) (top_template_events_last_hour%s))", instanceFilter), "{{template}}"},
+			}},
+		}},
+		{title: "AI分析", panels: []panel{
+			{title: "AI分析耗时", kind: "timeseries", unit: "s", queries: []query{
+				{fmt.Sprintf("histogram_quantile(0.5, sum by (le) (rate(ai_analysis_duration_seconds_bucket%s[5m])))", instanceFilter), "P50"},
+				{fmt.Sprintf("histogram_quantile(0.95, sum by (le) (rate(ai_analysis_duration_seconds_bucket%s[5m])))", instanceFilter), "P95"},
+			}},
+			{title: "AI分析错误率（按严重性）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (severity) (rate(ai_analysis_errors_total%s[5m]))", instanceFilter), "{{severity}}"},
+				{fmt.Sprintf("sum(rate(ai_unavailable_skips_total%s[5m]))", instanceFilter), "后端不可用跳过"},
+			}},
+			{title: "AI费用（每小时）", kind: "timeseries", unit: "currencyUSD", queries: []query{
+				{fmt.Sprintf("sum(increase(ai_cost_total%s[1h]))", instanceFilter), "费用"},
+			}},
+			{title: "AI token 用量（每小时）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (type) (increase(ai_tokens_used_total%s[1h]))", instanceFilter), "{{type}}"},
+			}},
+			{title: "AI后端可用性", kind: "stat", unit: "short", queries: []query{
+				{fmt.Sprintf("min by (provider, model) (ai_backend_up%s)", instanceFilter), "{{provider}}/{{model}}"},
+			}},
+			{title: "AI预算耗尽降级次数（每小时）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(increase(ai_budget_exhausted_total%s[1h]))", instanceFilter), "降级次数"},
+			}},
+		}},
+		{title: "ES写入", panels: []panel{
+			{title: "ES写入速率（按文档类型）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (kind) (rate(es_write_success_total%s[5m]))", instanceFilter), "成功 {{kind}}"},
+				{fmt.Sprintf("sum by (kind) (rate(es_write_errors_total%s[5m]))", instanceFilter), "失败 {{kind}}"},
+			}},
+			{title: "ES写入耗时 P95", kind: "timeseries", unit: "s", queries: []query{
+				{fmt.Sprintf("histogram_quantile(0.95, sum by (le) (rate(es_write_duration_seconds_bucket%s[5m])))", instanceFilter), "P95"},
+			}},
+			{title: "ES待写入和溢出队列", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(es_bulk_queued_documents%s)", instanceFilter), "批量写入队列"},
+				{fmt.Sprintf("sum(es_spill_queue_documents%s)", instanceFilter), "溢出队列"},
+				{fmt.Sprintf("sum(increase(es_spill_dropped_total%s[5m]))", instanceFilter), "丢弃"},
+			}},
+			{title: "ES写入熔断", kind: "stat", unit: "short", queries: []query{
+				{fmt.Sprintf("max(es_circuit_breaker_open%s)", instanceFilter), "熔断"},
+			}},
+		}},
+		{title: "告警", panels: []panel{
+			{title: "告警发送量（每小时，按渠道和严重性）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (channel, severity) (increase(alerts_sent_total%s[1h]))", instanceFilter), "{{channel}} {{severity}}"},
+			}},
+			{title: "告警发送失败、合并和跳过（每小时）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(increase(alert_send_errors_total%s[1h]))", instanceFilter), "发送失败"},
+				{fmt.Sprintf("sum(increase(alerts_merged_total%s[1h]))", instanceFilter), "合并"},
+				{fmt.Sprintf("sum(increase(alerts_skip_total%s[1h]))", instanceFilter), "跳过"},
+				{fmt.Sprintf("sum(increase(alerts_noise_suppressed_total%s[1h]))", instanceFilter), "噪声抑制"},
+			}},
+			{title: "告警渠道发送耗时 P95", kind: "timeseries", unit: "s", queries: []query{
+				{fmt.Sprintf("histogram_quantile(0.95, sum by (le, channel) (rate(alert_delivery_duration_seconds_bucket%s[5m])))", instanceFilter), "{{channel}}"},
+			}},
+			{title: "告警渠道重试和熔断", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum by (channel) (increase(alert_delivery_retries_total%s[1h]))", instanceFilter), "重试 {{channel}}"},
+				{fmt.Sprintf("max by (channel) (alert_channel_circuit_open%s)", instanceFilter), "熔断 {{channel}}"},
+			}},
+			{title: "未恢复的故障", kind: "stat", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(incidents_open%s)", instanceFilter), "故障"},
+			}},
+			{title: "告警缓存和异常检测模板数", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(alert_cache_entries%s)", instanceFilter), "告警缓存"},
+				{fmt.Sprintf("sum(analyzer_templates%s)", instanceFilter), "异常检测模板"},
+			}},
+			{title: "告警合并命中率", kind: "timeseries", unit: "percentunit", queries: []query{
+				{fmt.Sprintf(`sum(rate(alert_cache_lookups_total{result!="miss",instance_id=~"$instance_id"}[5m])) / sum(rate(alert_cache_lookups_total%s[5m]))`, instanceFilter), "命中率"},
+			}},
+			{title: "日志风暴和速率异常（每小时）", kind: "timeseries", unit: "short", queries: []query{
+				{fmt.Sprintf("sum(increase(log_storms_total%s[1h]))", instanceFilter), "日志风暴"},
+				{fmt.Sprintf("sum(increase(log_rate_anomalies_total%s[1h]))", instanceFilter), "速率异常"},
+			}},
+		}},
+	}
+}
+
+// Dashboard 返回 Grafana 仪表板的 JSON 模型；datasource 为默认选中的 Prometheus 数据源名称，为空时使用 Grafana 的默认数据源
+func Dashboard(datasource string) object {
+	var panels []object
+	id, y := 1, 0
+	for _, r := range rows() {
+		panels = append(panels, object{
+			"id": id, "type": "row", "title": r.title, "collapsed": false,
+			"gridPos": object{"x": 0, "y": y, "w": gridWidth, "h": 1},
+		})
+		id, y = id+1, y+1
+		for i, p := range r.panels {
+			panels = append(panels, p.model(id, i%2*gridWidth/2, y+i/2*panelHeight, gridWidth/2))
+			id++
+		}
+		y += (len(r.panels) + 1) / 2 * panelHeight
+	}
+
+	current := object{}
+	if datasource != "" {
+		current = object{"text": datasource, "value": datasource}
+	}
+	return object{
+		"uid":           DashboardUID,
+		"title":         DashboardTitle,
+		"description":   "LogAI 日志分析服务自身的事件采集、严重性分布、AI分析耗时与费用、ES写入和告警指标",
+		"tags":          []string{"logai"},
+		"timezone":      "browser",
+		"schemaVersion": schemaVersion,
+		"editable":      true,
+		"refresh":       "1m",
+		"time":          object{"from": "now-24h", "to": "now"},
+		"templating": object{"list": []object{
+			{
+				"name": "datasource", "label": "数据源", "type": "datasource", "query": "prometheus",
+				"current": current, "hide": 0,
+			},
+			{
+				"name": "instance_id", "label": "实例", "type": "query", "datasource": datasourceRef,
+				"query":      object{"query": "label_values(log_events_collected_total, instance_id)", "refId": "instance_id"},
+				"definition": "label_values(log_events_collected_total, instance_id)",
+				"includeAll": true, "multi": true, "allValue": ".*", "refresh": 2, "sort": 1,
+				"current": object{"text": "All", "value": "$__all"},
+			},
+		}},
+		"panels": panels,
+	}
+}
+
+// model 返回面板的 JSON 模型
+func (p panel) model(id, x, y, w int) object {
+	targets := make([]object, 0, len(p.queries))
+	for i, q := range p.queries {
+		targets = append(targets, object{
+			"refId": string(rune('A' + i)), "datasource": datasourceRef,
+			"expr": q.expr, "legendFormat": q.legend, "range": true,
+		})
+	}
+	return object{
+		"id":          id,
+		"type":        p.kind,
+		"title":       p.title,
+		"datasource":  datasourceRef,
+		"gridPos":     object{"x": x, "y": y, "w": w, "h": panelHeight},
+		"fieldConfig": object{"defaults": object{"unit": p.unit}, "overrides": []any{}},
+		"options":     object{"legend": object{"displayMode": "list", "placement": "bottom", "showLegend": true}},
+		"targets":     targets,
+	}
+}
@@ -0,0 +1,100 @@
+// Package grafana 生成覆盖事件采集、严重性分布、AI分析耗时与费用、ES写入和告警的 Grafana 仪表板，
+// 可输出为 JSON 手工导入，也可通过 Grafana 接口直接创建；仪表板使用固定UID，重复执行时覆盖为最新定义
+package grafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 请求超时时间
+const requestTimeout = 30 * time.Second
+
+// Options Grafana 连接配置
+type Options struct {
+	URL        string // Grafana 地址，如 http://grafana:3000，可在地址中携带 Basic 认证信息
+	Token      string // 服务账号令牌或 API Key，配置后使用 Bearer 认证
+	FolderUID  string // 创建到的文件夹UID，为空时使用 General 文件夹
+	Datasource string // 默认选中的 Prometheus 数据源名称，为空时使用 Grafana 的默认数据源
+}
+
+// Client Grafana 接口客户端
+type Client struct {
+	base   string
+	opts   Options
+	client *http.Client
+}
+
+// NewClient 创建 Grafana 客户端
+func NewClient(opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(opts.URL, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("Grafana 地址无效: %s", opts.URL)
+	}
+	return &Client{
+		base:   u.String(),
+		opts:   opts,
+		client: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// saveResponse 保存仪表板接口的响应
+type saveResponse struct {
+	UID     string `json:"uid"`
+	URL     string `json:"url"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Provision 创建或覆盖仪表板，返回仪表板的完整地址
+func (c *Client) Provision(ctx context.Context) (string, error) {
+	body, err := json.Marshal(object{
+		"dashboard": Dashboard(c.opts.Datasource),
+		"folderUid": c.opts.FolderUID,
+		"overwrite": true,
+		"message":   "logai provision-grafana",
+	})
+	if err != nil {
+		return "", fmt.Errorf("编码Grafana仪表板失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+"/api/dashboards/db", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求Grafana失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var result saveResponse
+	json.Unmarshal(data, &result)
+	if resp.StatusCode/100 != 2 {
+		msg := result.Message
+		if msg == "" {
+			msg = strings.TrimSpace(string(data))
+		}
+		if len(msg) > 512 {
+			msg = msg[:512]
+		}
+		return "", fmt.Errorf("Grafana 返回状态码 %d: %s", resp.StatusCode, msg)
+	}
+	// 返回的路径已包含 Grafana 的子路径
+	u, _ := url.Parse(c.base)
+	return u.Scheme + "://" + u.Host + result.URL, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/queue"
+)
+
+// 采集循环至少允许停滞的时间，采集间隔较短时避免因偶尔的慢速处理而判定为卡死
+const minStallTimeout = time.Minute
+
+// healthProbe 汇总各组件的状态，供 /healthz 和 /readyz 使用；
+// 字段为nil或零值表示不检查该组件，sidecar 模式下只检查AI后端
+type healthProbe struct {
+	es      *esclient.ESClient
+	backlog *queue.Backlog
+	// requireAI 为true时所有AI后端不可用即视为未就绪，sidecar 模式下AI是唯一的服务
+	requireAI bool
+
+	stallTimeout time.Duration // 采集循环超过该时间未运行视为卡死，0表示不检查
+	lastTick     atomic.Int64  // 采集循环最近一次运行的时间（UnixNano）
+}
+
+// newHealthProbe 创建健康检查，采集循环超过三个采集间隔（至少1分钟）未运行时视为卡死
+func newHealthProbe(es *esclient.ESClient, backlog *queue.Backlog, tick time.Duration) *healthProbe {
+	p := &healthProbe{es: es, backlog: backlog}
+	if tick > 0 {
+		p.stallTimeout = max(3*tick, minStallTimeout)
+	}
+	p.Tick()
+	return p
+}
+
+// Tick 记录采集循环运行了一次
+func (p *healthProbe) Tick() {
+	p.lastTick.Store(time.Now().UnixNano())
+}
+
+// componentStatus 单个组件的状态
+type componentStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// healthzResponse /healthz 和 /readyz 接口的响应
+type healthzResponse struct {
+	Status     string              `json:"status"` // ok、degraded（AI后端不可用，事件以规则摘要处理）或 unavailable（检查未通过）
+	AI         []ai.ProviderHealth `json:"ai,omitempty"`
+	Components []componentStatus   `json:"components,omitempty"`
+}
+
+// collector 返回采集循环的状态，循环卡死时进程需要重启
+func (p *healthProbe) collector() componentStatus {
+	last := time.Unix(0, p.lastTick.Load())
+	s := componentStatus{Name: "collector", Healthy: true, Detail: fmt.Sprintf("最近运行于 %s", last.Format(time.RFC3339))}
+	if p.stallTimeout > 0 && time.Since(last) > p.stallTimeout {
+		s.Healthy = false
+		s.Detail = fmt.Sprintf("采集循环已 %s 未运行", time.Since(last).Truncate(time.Second))
+	}
+	return s
+}
+
+// queue 返回待处理事件积压的状态，积压达到上限时采集暂停
+func (p *healthProbe) queue() componentStatus {
+	s := componentStatus{Name: "queue", Healthy: !p.backlog.Full(), Detail: fmt.Sprintf("积压 %d 个事件", p.backlog.Len())}
+	if limit := p.backlog.Limit(); limit > 0 {
+		s.Detail = fmt.Sprintf("积压 %d/%d 个事件", p.backlog.Len(), limit)
+	}
+	return s
+}
+
+// elasticsearch 返回ES的状态，未连接或写入熔断中时不可用
+func (p *healthProbe) elasticsearch() componentStatus {
+	s := componentStatus{Name: "elasticsearch", Healthy: true}
+	switch {
+	case !p.es.Online():
+		s.Healthy, s.Detail = false, "未连接"
+	case p.es.CircuitOpen():
+		s.Healthy, s.Detail = false, "写入熔断中"
+	}
+	return s
+}
+
+// aiHealthy 判断是否有可用的AI后端，未启用健康检查时视为可用
+func aiHealthy(providers []ai.ProviderHealth) bool {
+	if len(providers) == 0 {
+		return true
+	}
+	for _, p := range providers {
+		if p.Healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// check 返回各组件的状态和是否健康；liveness 为true时只检查重启进程才能恢复的采集循环，
+// 否则同时检查ES、AI后端和积压，决定是否接收流量
+func (p *healthProbe) check(liveness bool) (healthzResponse, bool) {
+	resp := healthzResponse{Status: "ok", AI: ai.HealthStatus()}
+	ok := true
+	if !aiHealthy(resp.AI) {
+		resp.Status = "degraded"
+		ok = liveness || !p.requireAI
+	}
+
+	if p.stallTimeout > 0 {
+		resp.Components = append(resp.Components, p.collector())
+	}
+	if !liveness && p.es != nil {
+		resp.Components = append(resp.Components, p.elasticsearch())
+	}
+	if !liveness && p.backlog != nil {
+		resp.Components = append(resp.Components, p.queue())
+	}
+	for _, c := range resp.Components {
+		if !c.Healthy {
+			ok = false
+		}
+	}
+	return resp, ok
+}
+
+// writeHealth 写入健康检查结果，不健康时返回503
+func writeHealth(w http.ResponseWriter, resp healthzResponse, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		resp.Status = "unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// healthzHandler 提供 GET /healthz 存活检查接口，采集循环卡死时返回503，适合 Kubernetes livenessProbe；
+// AI后端不可用不影响日志采集和告警，因此仍返回200，通过 status 区分是否降级
+func healthzHandler(p *healthProbe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := p.check(true)
+		writeHealth(w, resp, ok)
+	}
+}
+
+// readyzHandler 提供 GET /readyz 就绪检查接口，ES不可用、积压达到上限或采集循环卡死时返回503，
+// 适合 Kubernetes readinessProbe 和负载均衡器的健康检查
+func readyzHandler(p *healthProbe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp, ok := p.check(false)
+		writeHealth(w, resp, ok)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"log-ai-analyzer/config"
+)
+
+// probePaths 健康检查接口：Kubernetes 探针和负载均衡器通常无法携带认证信息，因此不需要认证也不限制来源地址
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// adminGuard 指标和管理接口的访问控制：来源地址限制、Basic 认证或 Bearer 令牌
+type adminGuard struct {
+	cfg     *config.Config
+	allowed []netip.Prefix
+	next    http.Handler
+}
+
+// protectAdmin 为指标和管理接口加上访问控制，未配置认证和来源地址限制时原样返回
+func protectAdmin(cfg *config.Config, next http.Handler) http.Handler {
+	g := &adminGuard{cfg: cfg, next: next}
+	for _, cidr := range cfg.MetricsAllowCIDRs {
+		// 配置校验时已检查格式
+		if prefix, err := config.ParseAllowedPrefix(cidr); err == nil {
+			g.allowed = append(g.allowed, prefix)
+		}
+	}
+	if len(g.allowed) == 0 && cfg.MetricsAuthUsername == "" && cfg.Secret("METRICS_AUTH_TOKEN") == "" {
+		return next
+	}
+	return g
+}
+
+// ServeHTTP 检查来源地址和认证信息，通过后交给下一个处理器
+func (g *adminGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if probePaths[r.URL.Path] {
+		g.next.ServeHTTP(w, r)
+		return
+	}
+	if !g.allowedAddr(r.RemoteAddr) {
+		log.Printf("拒绝来自 %s 的管理接口请求: 地址不在 METRICS_ALLOW_CIDRS 中 [%s %s]", r.RemoteAddr, r.Method, r.URL.Path)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !g.authorized(r) {
+		if g.cfg.MetricsAuthUsername != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="logai"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	g.next.ServeHTTP(w, r)
+}
+
+// allowedAddr 判断客户端地址是否允许访问，未配置地址限制时都允许；只使用连接的对端地址，不信任 X-Forwarded-For
+func (g *adminGuard) allowedAddr(remote string) bool {
+	if len(g.allowed) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorized 判断请求是否携带了有效的 Basic 认证或 Bearer 令牌，未配置认证时都允许；
+// 每次请求时读取密钥的当前值，以支持密钥轮换
+func (g *adminGuard) authorized(r *http.Request) bool {
+	username := g.cfg.MetricsAuthUsername
+	token := g.cfg.Secret("METRICS_AUTH_TOKEN")
+	if username == "" && token == "" {
+		return true
+	}
+	if token != "" {
+		if got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(got, token) {
+			return true
+		}
+	}
+	if username != "" {
+		if user, pass, ok := r.BasicAuth(); ok && secureEqual(user, username) && secureEqual(pass, g.cfg.Secret("METRICS_AUTH_PASSWORD")) {
+			return true
+		}
+	}
+	return false
+}
+
+// secureEqual 以固定时间比较两个字符串，避免通过响应时间猜测密钥
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// serveAdmin 启动指标和管理接口的 HTTP 服务，配置了证书时使用 HTTPS
+func serveAdmin(cfg *config.Config, handler http.Handler) error {
+	addr := ":" + cfg.METRICS_PORT
+	handler = protectAdmin(cfg, handler)
+	if cfg.MetricsTLSCertFile != "" {
+		return http.ListenAndServeTLS(addr, cfg.MetricsTLSCertFile, cfg.MetricsTLSKeyFile, handler)
+	}
+	return http.ListenAndServe(addr, handler)
+}
@@ -0,0 +1,128 @@
+// Package kibana 通过 Kibana 保存对象导入接口创建事件索引模式、已保存的搜索和概览仪表板，
+// 新部署无需手工配置即可在 Kibana 中查看事件；对象使用固定ID，重复执行时覆盖为最新定义
+package kibana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 请求超时时间
+const requestTimeout = 30 * time.Second
+
+// Options Kibana 连接配置
+type Options struct {
+	URL    string // Kibana 地址，如 http://kibana:5601，可在地址中携带 Basic 认证信息
+	APIKey string // API Key（Base64编码），配置后使用 ApiKey 认证
+	Space  string // 导入到的空间，为空时使用默认空间
+}
+
+// Target 创建的对象使用的索引
+type Target struct {
+	EventIndex string // 事件索引模式，如 logai-* 或查询别名
+	AlertIndex string // 告警聚合索引，为空时不创建告警相关的对象
+}
+
+// Client Kibana 接口客户端
+type Client struct {
+	base   string
+	opts   Options
+	client *http.Client
+}
+
+// NewClient 创建 Kibana 客户端
+func NewClient(opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(opts.URL, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("Kibana 地址无效: %s", opts.URL)
+	}
+	if opts.Space != "" && opts.Space != "default" {
+		u.Path += "/s/" + url.PathEscape(opts.Space)
+	}
+	return &Client{
+		base:   u.String(),
+		opts:   opts,
+		client: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// importResponse 保存对象导入接口的响应
+type importResponse struct {
+	Success      bool `json:"success"`
+	SuccessCount int  `json:"successCount"`
+	Errors       []struct {
+		ID    string `json:"id"`
+		Type  string `json:"type"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"errors"`
+}
+
+// Provision 导入索引模式、已保存的搜索、可视化和仪表板，返回导入成功的对象数
+func (c *Client) Provision(ctx context.Context, target Target) (int, error) {
+	var ndjson bytes.Buffer
+	for _, obj := range savedObjects(target) {
+		line, err := json.Marshal(obj)
+		if err != nil {
+			return 0, fmt.Errorf("编码Kibana对象失败: %w", err)
+		}
+		ndjson.Write(append(line, '\n'))
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "logai.ndjson")
+	if err != nil {
+		return 0, err
+	}
+	part.Write(ndjson.Bytes())
+	form.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.base+"/api/saved_objects/_import?overwrite=true", &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("kbn-xsrf", "true")
+	if c.opts.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.opts.APIKey)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求Kibana失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 512 {
+			msg = msg[:512]
+		}
+		return 0, fmt.Errorf("Kibana 返回状态码 %d: %s", resp.StatusCode, msg)
+	}
+
+	var result importResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("解析Kibana响应失败: %w", err)
+	}
+	if !result.Success {
+		var failed []string
+		for _, e := range result.Errors {
+			failed = append(failed, fmt.Sprintf("%s/%s: %s %s", e.Type, e.ID, e.Error.Type, e.Error.Message))
+		}
+		return result.SuccessCount, fmt.Errorf("%d 个Kibana对象导入失败: %s", len(result.Errors), strings.Join(failed, "; "))
+	}
+	return result.SuccessCount, nil
+}
@@ -0,0 +1,241 @@
+package kibana
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 保存对象的固定ID，重复导入时覆盖同一对象
+const (
+	eventsPatternID = "logai-events"
+	alertsPatternID = "logai-alerts"
+	dashboardID     = "logai-overview"
+)
+
+// 严重性分段，与告警级别一致：5分以上为警告，8分以上为严重
+var severityRanges = []object{{"from": 0, "to": 5}, {"from": 5, "to": 8}, {"from": 8}}
+
+// 面板的格式版本，导入时由 Kibana 迁移到当前版本
+const panelVersion = "7.10.0"
+
+type object = map[string]any
+
+// savedObject 导入文件中的一个保存对象
+type savedObject struct {
+	Type       string      `json:"type"`
+	ID         string      `json:"id"`
+	Attributes object      `json:"attributes"`
+	References []reference `json:"references"`
+}
+
+// reference 保存对象对其他对象的引用
+type reference struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonString 将值编码为 JSON 字符串，Kibana 的 visState、panelsJSON 等属性以字符串保存
+func jsonString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("序列化Kibana对象失败: %v", err))
+	}
+	return string(data)
+}
+
+// searchSource 返回使用 patternID 索引模式、按 query 过滤的搜索源和对应的引用
+func searchSource(query, patternID string) (object, []reference) {
+	const refName = "kibanaSavedObjectMeta.searchSourceJSON.index"
+	source := object{
+		"query":        object{"query": query, "language": "kuery"},
+		"filter":       []any{},
+		"indexRefName": refName,
+	}
+	return object{"searchSourceJSON": jsonString(source)},
+		[]reference{{Name: refName, Type: "index-pattern", ID: patternID}}
+}
+
+// indexPattern 返回以 @timestamp 为时间字段的索引模式
+func indexPattern(id, title string) savedObject {
+	return savedObject{
+		Type:       "index-pattern",
+		ID:         id,
+		Attributes: object{"title": title, "timeFieldName": "@timestamp"},
+		References: []reference{},
+	}
+}
+
+// search 返回已保存的搜索，按时间倒序显示 columns 列
+func search(id, title, query, patternID string, columns []string) savedObject {
+	meta, refs := searchSource(query, patternID)
+	return savedObject{
+		Type: "search",
+		ID:   id,
+		Attributes: object{
+			"title":                 title,
+			"description":           "",
+			"columns":               columns,
+			"sort":                  [][]string{{"@timestamp", "desc"}},
+			"kibanaSavedObjectMeta": meta,
+		},
+		References: refs,
+	}
+}
+
+// visualization 返回基于事件索引模式的可视化
+func visualization(id, title, visType string, params object, aggs []object) savedObject {
+	meta, refs := searchSource("", eventsPatternID)
+	for i, agg := range aggs {
+		agg["id"] = fmt.Sprint(i + 1)
+		agg["enabled"] = true
+		if agg["params"] == nil {
+			agg["params"] = object{}
+		}
+	}
+	return savedObject{
+		Type: "visualization",
+		ID:   id,
+		Attributes: object{
+			"title":                 title,
+			"description":           "",
+			"visState":              jsonString(object{"title": title, "type": visType, "params": params, "aggs": aggs}),
+			"uiStateJSON":           "{}",
+			"kibanaSavedObjectMeta": meta,
+		},
+		References: refs,
+	}
+}
+
+// 可视化的参数
+var (
+	histogramParams = object{
+		"type": "histogram",
+		"grid": object{"categoryLines": false},
+		"categoryAxes": []object{{
+			"id": "CategoryAxis-1", "type": "category", "position": "bottom", "show": true,
+			"scale":  object{"type": "linear"},
+			"labels": object{"show": true, "filter": true, "truncate": 100},
+			"title":  object{},
+		}},
+		"valueAxes": []object{{
+			"id": "ValueAxis-1", "name": "LeftAxis-1", "type": "value", "position": "left", "show": true,
+			"scale":  object{"type": "linear", "mode": "normal"},
+			"labels": object{"show": true, "rotate": 0, "filter": false, "truncate": 100},
+			"title":  object{"text": "事件数"},
+		}},
+		"seriesParams": []object{{
+			"show": true, "type": "histogram", "mode": "stacked", "valueAxis": "ValueAxis-1",
+			"data":                   object{"label": "事件数", "id": "1"},
+			"drawLinesBetweenPoints": true, "showCircles": true,
+		}},
+		"addTooltip": true, "addLegend": true, "legendPosition": "right", "times": []any{}, "addTimeMarker": false,
+	}
+	pieParams = object{
+		"type": "pie", "addTooltip": true, "addLegend": true, "legendPosition": "right", "isDonut": true,
+		"labels": object{"show": false, "values": true, "last_level": true, "truncate": 100},
+	}
+	tableParams = object{
+		"perPage": 10, "showPartialRows": false, "showMetricsAtAllLevels": false,
+		"showTotal": false, "totalFunc": "sum", "percentageCol": "",
+	}
+)
+
+// 常用的聚合
+func countAgg() object {
+	return object{"type": "count", "schema": "metric"}
+}
+
+func maxSeverityAgg() object {
+	return object{"type": "max", "schema": "metric", "params": object{"field": "severity_score", "customLabel": "最高严重性"}}
+}
+
+func severityAgg(schema string) object {
+	return object{"type": "range", "schema": schema, "params": object{"field": "severity_score", "ranges": severityRanges, "customLabel": "严重性"}}
+}
+
+func termsAgg(field, label string) object {
+	return object{"type": "terms", "schema": "bucket", "params": object{
+		"field": field, "size": 10, "order": "desc", "orderBy": "1", "customLabel": label,
+	}}
+}
+
+// dashboard 返回依次排列 panels 的仪表板，每个面板占半行或整行
+func dashboard(title string, panels []savedObject) savedObject {
+	var panelsJSON []object
+	var refs []reference
+	x, y := 0, 0
+	for i, p := range panels {
+		w := 24
+		if p.Type == "search" || i == 0 {
+			w = 48
+		}
+		if x+w > 48 {
+			x, y = 0, y+15
+		}
+		ref := fmt.Sprintf("panel_%d", i)
+		panelsJSON = append(panelsJSON, object{
+			"version":          panelVersion,
+			"gridData":         object{"x": x, "y": y, "w": w, "h": 15, "i": fmt.Sprint(i + 1)},
+			"panelIndex":       fmt.Sprint(i + 1),
+			"embeddableConfig": object{},
+			"panelRefName":     ref,
+		})
+		refs = append(refs, reference{Name: ref, Type: p.Type, ID: p.ID})
+		if x += w; x >= 48 {
+			x, y = 0, y+15
+		}
+	}
+	return savedObject{
+		Type: "dashboard",
+		ID:   dashboardID,
+		Attributes: object{
+			"title":           title,
+			"description":     "日志事件的数量、严重性分布、主机和日志模板排行",
+			"panelsJSON":      jsonString(panelsJSON),
+			"optionsJSON":     jsonString(object{"useMargins": true, "hidePanelTitles": false}),
+			"timeRestore":     true,
+			"timeFrom":        "now-24h",
+			"timeTo":          "now",
+			"refreshInterval": object{"pause": false, "value": 60000},
+			"kibanaSavedObjectMeta": object{"searchSourceJSON": jsonString(object{
+				"query": object{"query": "", "language": "kuery"}, "filter": []any{},
+			})},
+		},
+		References: refs,
+	}
+}
+
+// savedObjects 返回需要导入的所有对象，被引用的对象排在前面
+func savedObjects(target Target) []savedObject {
+	eventColumns := []string{"host", "tags", "severity_score", "ai_result"}
+	highSeverity := search("logai-high-severity", "LogAI 严重事件", "severity_score >= 8", eventsPatternID, eventColumns)
+	panels := []savedObject{
+		visualization("logai-events-over-time", "LogAI 事件趋势", "histogram", histogramParams,
+			[]object{countAgg(), {"type": "date_histogram", "schema": "segment", "params": object{
+				"field": "@timestamp", "interval": "auto", "min_doc_count": 1, "extended_bounds": object{},
+			}}, severityAgg("group")}),
+		visualization("logai-severity", "LogAI 严重性分布", "pie", pieParams,
+			[]object{countAgg(), severityAgg("segment")}),
+		visualization("logai-top-hosts", "LogAI 事件最多的主机", "table", tableParams,
+			[]object{countAgg(), termsAgg("host", "主机"), maxSeverityAgg()}),
+		visualization("logai-top-templates", "LogAI 出现最多的日志模板", "table", tableParams,
+			[]object{countAgg(), termsAgg("template_id", "日志模板"), maxSeverityAgg()}),
+		highSeverity,
+	}
+
+	objects := []savedObject{indexPattern(eventsPatternID, target.EventIndex)}
+	objects = append(objects, panels...)
+	objects = append(objects,
+		search("logai-wrong-analysis", "LogAI 被标记为错误的AI分析", `feedback : "wrong"`, eventsPatternID,
+			[]string{"host", "content", "ai_result", "operator_note"}),
+	)
+	if target.AlertIndex != "" {
+		objects = append(objects,
+			indexPattern(alertsPatternID, target.AlertIndex),
+			search("logai-active-alerts", "LogAI 持续中的告警", `status : "active"`, alertsPatternID,
+				[]string{"host", "count", "severity_score", "first_seen", "ai_result"}),
+		)
+	}
+	return append(objects, dashboard("LogAI 事件概览", panels))
+}
@@ -0,0 +1,143 @@
+// Package kube 访问 Kubernetes API Server 的最小客户端：集群内运行时使用 ServiceAccount 的地址、令牌和CA证书，
+// 供多实例协调的 Lease 和自定义资源监听使用
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// 集群内 ServiceAccount 的令牌、CA 证书和命名空间所在的目录
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// API Server 返回的常见错误
+var (
+	ErrNotFound = errors.New("Kubernetes 对象不存在")
+	ErrConflict = errors.New("Kubernetes 对象已被修改")
+	ErrGone     = errors.New("Kubernetes 资源版本已过期")
+)
+
+// Client Kubernetes API Server 客户端
+type Client struct {
+	url    string
+	token  func() string
+	client *http.Client
+}
+
+// NewClient 创建客户端，apiURL 为空时使用集群内的 API Server 地址，token 返回空时使用 ServiceAccount 令牌；
+// 请求的超时由调用方的 ctx 控制，监听请求可以长时间保持
+func NewClient(apiURL string, token func() string) (*Client, error) {
+	if apiURL == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("不在 Kubernetes 集群内运行，必须配置 API Server 地址")
+		}
+		apiURL = "https://" + net.JoinHostPort(host, port)
+	}
+	if token == nil {
+		token = func() string { return "" }
+	}
+	c := &Client{url: strings.TrimRight(apiURL, "/")}
+	// 令牌为空时每次请求重新读取 ServiceAccount 令牌，令牌会定期轮换
+	c.token = func() string {
+		if t := token(); t != "" {
+			return t
+		}
+		data, _ := os.ReadFile(serviceAccountDir + "/token")
+		return strings.TrimSpace(string(data))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if ca, err := os.ReadFile(serviceAccountDir + "/ca.crt"); err == nil {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	c.client = &http.Client{Transport: transport}
+	return c, nil
+}
+
+// Namespace 返回 Pod 所在的命名空间
+func Namespace() (string, error) {
+	data, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return "", fmt.Errorf("读取 Pod 所在的命名空间失败: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Do 发送请求，result 不为nil时解析响应；对象不存在时返回 ErrNotFound，冲突时返回 ErrConflict
+func (c *Client) Do(ctx context.Context, method, path string, body, result any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if result == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("解析 Kubernetes 响应失败: %w", err)
+	}
+	return nil
+}
+
+// Stream 发送 GET 请求并返回响应体，用于 watch 等逐行返回 JSON 的接口，调用方负责关闭
+func (c *Client) Stream(ctx context.Context, path string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// send 发送请求并检查状态码
+func (c *Client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("编码 Kubernetes 请求失败: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kubernetes 请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := c.token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Kubernetes API 失败: %w", err)
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusConflict:
+		return nil, ErrConflict
+	case http.StatusGone:
+		return nil, ErrGone
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, fmt.Errorf("Kubernetes API 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
@@ -6,22 +6,55 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log-ai-analyzer/admin"
 	"log-ai-analyzer/ai"
 	"log-ai-analyzer/alert"
+	"log-ai-analyzer/analyzer"
 	"log-ai-analyzer/collector"
 	"log-ai-analyzer/config"
+	"log-ai-analyzer/coord"
+	"log-ai-analyzer/crd"
+	"log-ai-analyzer/diag"
 	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/feature"
 	"log-ai-analyzer/metrics"
 	"log-ai-analyzer/processor"
+	"log-ai-analyzer/queue"
+	"log-ai-analyzer/remoteconfig"
+	"log-ai-analyzer/report"
+	"log-ai-analyzer/sink"
+	"log-ai-analyzer/tenant"
 	"net/http"
 )
 
 func main() {
+	// 子命令和配置参数见 newRootCommand，如 `logai alert test --channel wechat`
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runOptions `logai run` 的运行方式
+type runOptions struct {
+	Once         bool // 只采集一轮，处理完所有事件并提交存储后退出，供 cron 和 CI 日志检查使用
+	FailSeverity int  // 单次运行时发现严重性不低于该值的事件则以退出码2退出
+}
+
+// 单次运行的退出码：发现了高严重性事件
+const exitHighSeverity = 2
+
+// runServer 启动日志分析服务，运行到收到退出信号为止；单次运行时采集一轮后退出，返回进程的退出码
+func runServer(opts runOptions) int {
+	startedAt := time.Now()
+
 	// 1. 加载配置
 	cfg, err := config.Load()
 	if err != nil {
@@ -35,21 +68,263 @@ func main() {
 	// 打印系统信息
 	log.Printf("系统启动中... Go版本: %s, CPU核心数: %d", runtime.Version(), runtime.NumCPU())
 
-	// 2. 初始化ES客户端
-	esClient, err := esclient.NewESClient(cfg.ESNodes, cfg.ESIndex)
+	// 实验性功能开关，运行中可通过 /api/features 切换
+	feature.Init(cfg.FeatureFlags)
+
+	// 告警消息和工单中显示实例，多个实例共用告警渠道时可区分来源
+	alert.SetInstance(cfg.InstanceID, cfg.InstanceLabels)
+	setAlertDelivery(cfg)
+
+	// 限制指标文件、主机标签的取值数量
+	metrics.SetMaxLabelValues(cfg.MetricsMaxLabelValues)
+
+	// 保留最近的内部错误，通过 /debug/errors 查看
+	diag.SetCapacity(cfg.DebugErrorsSize)
+
+	// 按租户规则识别事件的租户、服务和团队，存储时按租户路由
+	var tenants *tenant.Config
+	if cfg.TenantRulesFile != "" {
+		if tenants, err = tenant.Load(cfg.TenantRulesFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("✅ 租户规则已加载: %d 条规则", len(tenants.Rules))
+	}
+
+	// 日志事件识别规则，未配置时使用内置的关键词和评分
+	collector.DefaultConfig.ContextLines = cfg.ContextLines
+	if cfg.HeuristicsFile != "" {
+		heuristics, err := collector.LoadHeuristics(cfg.HeuristicsFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		collector.SetHeuristics(heuristics)
+		log.Printf("✅ 识别规则已加载: %d 个关键词, %d 条按文件覆盖的规则", len(heuristics.Keywords), len(heuristics.Overrides))
+	}
+
+	// 2. 初始化ES客户端，未启用ES存储时不连接ES
+	var esClient *esclient.ESClient
+	// ES启动时不可用不影响服务启动，在后台重连，连接后再配置索引保留期和模板
+	esOffline := false
+	deleteExpired := false
+	if cfg.EnableES {
+		var err error
+		if esClient, err = newESClient(cfg, cfg.ESNodes); err != nil {
+			log.Fatalf("初始化ES客户端失败: %v", err)
+		}
+		esClient.SetTenantRetention(tenants.Retention())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = esClient.Connect(ctx)
+		cancel()
+		if err != nil {
+			esOffline = true
+			log.Printf("⚠️ 连接ES失败: %v，将在后台重试，连接前写入的文档放入溢出队列", err)
+			if cfg.ESSpillDir == "" {
+				log.Printf("⚠️ 未配置 ES_SPILL_DIR，连接ES前的事件无法写入ES")
+			}
+		} else {
+			log.Printf("✅ Elasticsearch客户端初始化成功，集群版本: %s", esClient.Version().Number)
+			deleteExpired = setupES(cfg, esClient)
+		}
+		if err := configureESWrites(cfg, esClient, cfg.ESSpillDir); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if cfg.ESSpillDir != "" {
+			log.Printf("✅ ES溢出队列已启用, 目录: %s", cfg.ESSpillDir)
+		}
+		if cfg.ESBulkActions > 0 {
+			log.Printf("✅ ES批量写入已启用, 每批最多 %d 个文档, 刷新间隔: %v", cfg.ESBulkActions, cfg.ESBulkFlushInterval)
+		}
+	}
+
+	// 单独配置了ES集群的租户写入各自的集群，在后台连接
+	tenantClients, err := newTenantClients(cfg, tenants)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// 事件和告警的存储后端
+	store, err := newStore(cfg, esClient, tenantClients)
 	if err != nil {
-		log.Fatalf("初始化ES客户端失败: %v", err)
+		log.Fatalf("%v", err)
+	}
+
+	// 写前日志：采集到的事件先持久化，所有存储后端写入完成后确认
+	var wal *queue.WAL
+	if cfg.WALDir != "" {
+		w, err := queue.OpenWAL(cfg.WALDir, cfg.WALMaxBytes, cfg.WALFullPolicy)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		wal = w
+		log.Printf("✅ 写前日志已启用: %s", cfg.WALDir)
+	}
+
+	// 磁盘事件队列：采集到的事件追加到磁盘后立即返回，由读取协程交给工作池；单次运行时不使用
+	var diskQueue *queue.DiskQueue
+	if cfg.EventQueueDir != "" && !opts.Once {
+		q, err := queue.OpenDiskQueue(cfg.EventQueueDir, cfg.EventQueueMaxBytes)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		diskQueue = q
+		log.Printf("✅ 磁盘事件队列已启用: %s, 待处理事件: %d", cfg.EventQueueDir, q.Len())
 	}
-	log.Println("✅ Elasticsearch客户端初始化成功")
 
 	// 3. 初始化告警缓存
 	alertCache := alert.NewAlertCache(cfg.AlertTTL)
+	if cfg.AlertSeveritySchedule != "" {
+		schedule, err := alert.ParseSeveritySchedule(cfg.AlertSeveritySchedule)
+		if err != nil {
+			log.Fatalf("解析告警阈值时间表失败: %v", err)
+		}
+		alertCache.SetSeveritySchedule(schedule)
+	}
+	alertCache.SetResendIntervals(alert.ResendIntervals{High: cfg.AlertResendHigh, Medium: cfg.AlertResendMedium, Low: cfg.AlertResendLow})
+	alertCache.SetSimilarityThreshold(cfg.AlertSimilarity)
+	alertCache.SetMaxContextLines(cfg.AlertMaxContextLines)
 	log.Println("✅ 告警缓存初始化成功")
 
+	storm := alert.NewStormDetector(cfg.StormThreshold, cfg.StormWindow)
+	incidents := alert.NewIncidentTracker(cfg.IncidentWindow)
+	// 恢复重启前未恢复的故障，重启后的事件仍并入同一故障
+	if n, err := incidents.Load(cfg.IncidentStateFile); err != nil {
+		log.Printf("⚠️ %v", err)
+	} else if n > 0 {
+		log.Printf("✅ 已恢复 %d 个未恢复的故障", n)
+	}
+	suppressor := alert.NewSuppressor(cfg.NoiseSuppressionFile)
+
+	// 按日志模板统计事件速率，量级异常时生成异常事件，不依赖AI
+	smart := analyzer.NewSmartAnalyzer(analyzer.Options{
+		Interval:    cfg.AnomalyInterval,
+		Alpha:       cfg.AnomalyAlpha,
+		Threshold:   cfg.AnomalyThreshold,
+		Ratio:       cfg.AnomalyRatio,
+		MinCount:    cfg.AnomalyMinCount,
+		Warmup:      cfg.AnomalyWarmup,
+		Seasonality: cfg.AnomalySeasonality,
+	})
+	// 恢复上次保存的速率基线，崩溃重启后不必重新预热
+	if n, err := smart.Load(cfg.AnomalyStateFile); err != nil {
+		log.Printf("⚠️ %v，速率基线重新预热", err)
+	} else if n > 0 {
+		log.Printf("✅ 已恢复 %d 个日志模板的速率基线", n)
+	}
+
+	// 最近一小时的事件概况，通过 /api/stats 和 Prometheus 指标展示
+	stats := analyzer.NewEventStats()
+	// 按 TraceID 关联不同日志文件中同一请求的事件
+	traces := analyzer.NewTraceCorrelator(cfg.TraceWindow)
+	if n, err := traces.Load(cfg.TraceStateFile); err != nil {
+		log.Printf("⚠️ %v", err)
+	} else if n > 0 {
+		log.Printf("✅ 已恢复 %d 个 TraceID 的关联事件", n)
+	}
+
+	// 序列规则：事件按顺序在规定时间内出现时生成复合告警
+	var sequenceRules *analyzer.SequenceConfig
+	if cfg.SequenceRulesFile != "" {
+		if sequenceRules, err = analyzer.LoadSequenceRules(cfg.SequenceRulesFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("✅ 序列规则已加载: %d 条规则", len(sequenceRules.Rules))
+	}
+	sequences := analyzer.NewSequenceDetector(sequenceRules)
+
+	// SLO：服务错误数按错误预算计算燃烧率，消耗过快时生成告警
+	var sloRules *analyzer.SLOConfig
+	if cfg.SLORulesFile != "" {
+		if sloRules, err = analyzer.LoadSLOs(cfg.SLORulesFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("✅ SLO规则已加载: %d 个SLO", len(sloRules.SLOs))
+	}
+	slos := analyzer.NewSLOTracker(sloRules)
+
+	configureAI(cfg)
+	// AI未启用或不可用时按离线规则给出已知原因和处理建议
+	ai.SetOfflineRulesFile(cfg.AIOfflineRulesFile)
+	batcher := ai.NewBatcher(cfg, cfg.AIBatchWindow, cfg.AIBatchMaxSize)
+
+	// 从ES检索相似历史事件作为分析上下文，告警中附带AI分析评价链接
+	if cfg.EnableES {
+		ai.SetHistory(esClient, cfg.AIHistoryLimit)
+		ai.SetContextStore(esClient)
+		alert.SetFeedbackURL(cfg.FeedbackBaseURL)
+	}
+
+	tickets := newTicketManager(cfg)
+	if tickets != nil {
+		log.Printf("✅ 工单集成已启用, 系统: %s, 最低严重性: %d", cfg.TicketSystem, cfg.TicketMinSeverity)
+	}
+
+	onCall, err := newOnCallResolver(cfg)
+	if err != nil {
+		log.Fatalf("初始化值班解析失败: %v", err)
+	}
+	if onCall != nil {
+		log.Printf("✅ 值班解析初始化成功, 来源: %s", cfg.OnCallSource)
+	}
+
 	// 4. 设置优雅退出
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// 定期重新解析密钥引用，密钥轮换后无需重启
+	go refreshSecrets(ctx, cfg)
+
+	// 监听集中配置，规则文件变化后立即生效
+	source, err := remoteconfig.New(cfg.RemoteConfigOptions())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if source != nil {
+		log.Printf("✅ 集中配置已启用: %s %s%s", cfg.ConfigSource, cfg.ConfigSourceURL, cfg.ConfigSourcePrefix)
+		go source.Watch(ctx, func(files, env []string) {
+			applyRemoteChanges(cfg, suppressor, files, env)
+		})
+	}
+
+	// AI后端健康检查，后端不可用时跳过AI调用
+	if err := ai.StartHealthCheck(ctx, cfg, cfg.AIHealthInterval); err != nil {
+		log.Fatalf("启动AI健康检查失败: %v", err)
+	}
+
+	if deleteExpired {
+		go esClient.RunRetention(ctx, cfg.ESRetentionDays)
+	}
+	if esOffline {
+		go esClient.RunConnect(ctx, func() {
+			if setupES(cfg, esClient) {
+				go esClient.RunRetention(ctx, cfg.ESRetentionDays)
+			}
+		})
+	}
+	for _, c := range tenantClients {
+		go c.RunConnect(ctx, func() {
+			if setupES(cfg, c) {
+				go c.RunRetention(ctx, cfg.ESRetentionDays)
+			}
+		})
+		go c.RunSpillReplay(ctx)
+	}
+	if cfg.EnableES {
+		go esClient.RunSpillReplay(ctx)
+	}
+	go smart.RunSave(ctx, cfg.AnomalyStateFile)
+	go traces.RunSave(ctx, cfg.TraceStateFile)
+	go incidents.RunSave(ctx, cfg.IncidentStateFile)
+	if cfg.KibanaProvision {
+		go func() {
+			n, err := provisionKibana(ctx, cfg)
+			if err != nil {
+				log.Printf("⚠️ 创建Kibana仪表板失败，可稍后执行 logai provision-kibana 重试: %v", err)
+				return
+			}
+			log.Printf("✅ Kibana索引模式和仪表板创建成功，共 %d 个对象", n)
+		}()
+	}
+
 	// 处理退出信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -66,145 +341,977 @@ func main() {
 		workerCount = cfg.MaxWorkers
 	}
 
-	log.Printf("启动工作池，工作协程数: %d", workerCount)
+	if cfg.MinWorkers > 0 && cfg.MinWorkers < workerCount {
+		log.Printf("启动工作池，工作协程数: %d~%d，按积压每 %v 调整", cfg.MinWorkers, workerCount, cfg.WorkerScaleInterval)
+	} else {
+		log.Printf("启动工作池，工作协程数: %d", workerCount)
+	}
 
-	// 创建事件处理通道
-	eventChan := make(chan *collector.LogEvent, 100)
+	// 创建事件处理通道，积压时经优先级队列按严重性和等待时间分发给工作协程
+	eventChan := make(chan *collector.LogEvent, cfg.EventChannelSize)
+	workChan := make(chan *collector.LogEvent)
+	// 退出时先停止采集并关闭事件通道，优先级队列和工作协程处理完已分发的事件后再停止，最多等待 SHUTDOWN_GRACE
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	go queue.NewPriorityQueue(cfg.EventQueueSize, cfg.PriorityAging).Run(workerCtx, eventChan, workChan)
 
-	// 启动工作池
-	for i := 0; i < workerCount; i++ {
-		go worker(ctx, cfg, esClient, alertCache, eventChan, i)
+	// 启动工作池，inflight 记录已分发尚未处理完的事件，单次运行时等待其处理完成后退出
+	backlog := queue.NewBacklog(cfg.MaxBacklogEvents)
+	// flow 为事件从分发到处理完成占用的内存额度，AI分析或存储写入变慢时额度不再释放，采集端随之减速直至暂停
+	flow := queue.NewFlow(cfg.FlowMaxInflightBytes)
+	var inflight sync.WaitGroup
+	// abandon 退出时未能交给工作池的事件不再等待处理；没有写前日志时这些事件计入退出时丢弃的积压
+	abandon := func(events []collector.LogEvent) {
+		for i := range events {
+			flow.Release(queue.EventCost(&events[i]))
+			inflight.Done()
+		}
 	}
+	var pool *queue.WorkerPool
+	// 工作协程在 Run 中启动，此时 pool 已赋值
+	pool = queue.NewWorkerPool(cfg.MinWorkers, workerCount, cfg.WorkerScaleInterval, backlog, func(id int, stop <-chan struct{}) {
+		worker(workerCtx, cfg, store, wal, backlog, flow, pool, &inflight, alertCache, storm, incidents, suppressor, batcher, tickets, onCall, workChan, stop, id)
+	})
+	go pool.Run(ctx)
 
-	// 启动 Prometheus 指标服务
-	port := cfg.METRICS_PORT
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		err := http.ListenAndServe(":"+port, nil)
+	// 重放上次停止时未投递完成的事件
+	if replay := wal.Replay(); len(replay) > 0 {
+		log.Printf("从写前日志重放 %d 个未投递完成的事件", len(replay))
+		backlog.Add(len(replay))
+		flow.Acquire(replay)
+		inflight.Add(len(replay))
+	replayLoop:
+		for i := range replay {
+			select {
+			case eventChan <- &replay[i]:
+			case <-ctx.Done():
+				abandon(replay[i:])
+				break replayLoop
+			}
+		}
+	}
+
+	// 从磁盘事件队列逐批读取事件交给工作池，交出后提交读取位置；
+	// 写前日志在交出前写入，处理完成前崩溃的事件由写前日志重放，尚未读取的事件留在磁盘事件队列中
+	pumpDone := make(chan struct{})
+	if diskQueue != nil {
+		go func() {
+			defer close(pumpDone)
+			for {
+				// 内存中待处理的事件达到上限时等待处理完成，事件留在磁盘上
+				if flow.Wait(ctx, "queue") != nil {
+					return
+				}
+				events, err := diskQueue.Next(ctx, 100)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					log.Printf("%v", err)
+					diag.Record("queue", err)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second):
+					}
+					continue
+				}
+				if err := wal.Append(ctx, events); err != nil {
+					log.Printf("事件持久化失败: %v", err)
+				}
+				backlog.Add(len(events))
+				flow.Acquire(events)
+				inflight.Add(len(events))
+				for i := range events {
+					select {
+					case eventChan <- &events[i]:
+					case <-ctx.Done():
+						abandon(events[i:])
+						return
+					}
+				}
+				if err := diskQueue.Commit(); err != nil {
+					log.Printf("%v", err)
+					diag.Record("queue", err)
+				}
+			}
+		}()
+	} else {
+		close(pumpDone)
+	}
+
+	// 按时间表生成汇总报告
+	if cfg.ReportSchedule != "" && cfg.EnableES {
+		schedule, err := report.ParseSchedule(cfg.ReportSchedule)
 		if err != nil {
-			log.Printf("Failed to start metrics server: %v", err)
+			log.Fatalf("解析汇总报告时间表失败: %v", err)
 		}
-	}()
+		go report.NewScheduler(schedule, report.NewGenerator(cfg, esClient)).Run(ctx)
+		log.Printf("✅ 汇总报告已启用, 时间表: %s", cfg.ReportSchedule)
+	}
+
+	// 采集时间表，健康检查按采集间隔判断采集循环是否停滞
+	scheduler, err := collector.NewScheduler(cfg.LogFiles, cfg.CollectInterval, cfg.CollectSchedule)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	probe := newHealthProbe(esClient, backlog, scheduler.Tick())
+
+	// Kubernetes 自定义资源：LogTarget 增加采集的日志文件，AlertRoute 和 Silence 立即生效；
+	// 采集目标变化后交给主循环更新采集时间表和多实例协调，单次运行时只在启动时读取一次
+	logFiles := cfg.LogFiles
+	targetUpdates := make(chan logTargets, 1)
+	if cfg.CRDWatch {
+		watcher, err := crd.New(crd.Options{
+			APIURL:    cfg.CRDAPIURL,
+			Token:     func() string { return cfg.Secret("CRD_TOKEN") },
+			Namespace: cfg.CRDNamespace,
+		})
+		if err != nil {
+			log.Fatalf("初始化自定义资源监听失败: %v", err)
+		}
+		state, versions, err := watcher.Load(ctx)
+		if err != nil {
+			log.Fatalf("读取自定义资源失败: %v", err)
+		}
+		targets := applyCRDState(cfg, state)
+		scheduler.SetFiles(targets.files, targets.schedules)
+		logFiles = targets.files
+		log.Printf("✅ 已加载自定义资源: %d 个采集目标, %d 条告警路由, %d 条静默", len(state.Targets), len(state.Routes), len(state.Silences))
+		if !opts.Once {
+			// 只有监听协程发送，丢弃主循环尚未处理的旧目标后发送最新的目标
+			go watcher.Run(ctx, versions, func(state crd.State) {
+				targets := applyCRDState(cfg, state)
+				select {
+				case <-targetUpdates:
+				default:
+				}
+				targetUpdates <- targets
+			})
+		}
+	}
+
+	// 多实例协调：多个副本采集同一批日志文件时，只采集本实例认领的文件，单次运行时不协调
+	var coordinator *coord.Coordinator
+	if !opts.Once {
+		coordinator, err = coord.New(coord.Options{
+			Kind:      cfg.Coordination,
+			URL:       cfg.CoordinationURL,
+			Prefix:    cfg.CoordinationPrefix,
+			Token:     func() string { return cfg.Secret("COORDINATION_TOKEN") },
+			Namespace: cfg.CoordinationNamespace,
+			Identity:  cfg.InstanceID,
+			TTL:       cfg.CoordinationLeaseTTL,
+		}, logFiles)
+		if err != nil {
+			log.Fatalf("初始化多实例协调失败: %v", err)
+		}
+	}
+	if coordinator != nil {
+		go coordinator.Run(ctx)
+		log.Printf("✅ 多实例协调已启用: %s, 实例: %s, 租约有效期: %v", cfg.Coordination, cfg.InstanceID, cfg.CoordinationLeaseTTL)
+	}
+
+	// 启动 Prometheus 指标服务和健康检查接口，单次运行时不监听端口，避免与同一主机上运行的服务冲突
+	port := cfg.METRICS_PORT
+	if !opts.Once {
+		go func() {
+			http.Handle("/metrics", metrics.Handler(cfg.InstanceID, cfg.InstanceLabels))
+			http.Handle("/healthz", healthzHandler(probe))
+			http.Handle("/readyz", readyzHandler(probe))
+			http.Handle("/api/stats", statsHandler(stats))
+			http.Handle("/api/health", apiHealthHandler(probe, cfg.InstanceID, startedAt))
+			http.Handle("/api/alerts", alertsHandler(alertCache))
+			http.Handle("/api/silences", silencesHandler(cfg.NoiseSuppressionFile, suppressor))
+			http.Handle("/api/features", featuresHandler())
+			http.Handle("/debug/errors", debugErrorsHandler())
+			// 测试接口会真实发送告警，需显式开启
+			if cfg.AlertTestAPI {
+				http.Handle("/api/alert/test", alertTestHandler(cfg))
+			}
+			if cfg.EnableES {
+				http.Handle("/api/feedback", feedbackHandler(esClient))
+				http.Handle("/api/trend", trendHandler(esClient))
+				http.Handle("/api/events", eventsHandler(esClient))
+			}
+			err := serveAdmin(cfg, http.DefaultServeMux)
+			if err != nil {
+				log.Printf("Failed to start metrics server: %v", err)
+			}
+		}()
+		log.Printf("✅ Prometheus 指标服务已启动, 端口: %s", port)
+	}
+
+	// gRPC 管理服务，供自动化工具和 logai admin 命令控制运行中的实例
+	if cfg.AdminGRPCListen != "" && !opts.Once {
+		ctl := &adminController{
+			cfg:           cfg,
+			probe:         probe,
+			alertCache:    alertCache,
+			suppressor:    suppressor,
+			store:         store,
+			esClient:      esClient,
+			tenantClients: tenantClients,
+			startedAt:     startedAt,
+		}
+		go func() {
+			err := admin.Serve(ctx, admin.Options{
+				Listen:      cfg.AdminGRPCListen,
+				Token:       func() string { return cfg.Secret("ADMIN_GRPC_TOKEN") },
+				TLSCertFile: cfg.AdminGRPCTLSCertFile,
+				TLSKeyFile:  cfg.AdminGRPCTLSKeyFile,
+			}, ctl)
+			if err != nil {
+				log.Printf("启动 gRPC 管理服务失败: %v", err)
+			}
+		}()
+	}
+
+	// 主动推送指标，短期运行或无法被抓取的实例使用
+	pusher := metrics.NewPusher(metrics.PushOptions{
+		URL:            cfg.MetricsPushURL,
+		Mode:           cfg.MetricsPushMode,
+		Interval:       cfg.MetricsPushInterval,
+		Job:            cfg.MetricsPushJob,
+		Instance:       cfg.InstanceID,
+		InstanceLabels: cfg.InstanceLabels,
+		Labels:         cfg.MetricsPushLabels,
+		Username:       cfg.MetricsPushUsername,
+		Password:       func() string { return cfg.Secret("METRICS_PUSH_PASSWORD") },
+	})
+	if pusher != nil {
+		go pusher.Run(ctx)
+		log.Printf("✅ 指标推送已启用, 方式: %s, 间隔: %s", cfg.MetricsPushMode, cfg.MetricsPushInterval)
+	}
+
+	go metrics.RunDropSummary(ctx, cfg.DropSummaryInterval)
 
 	log.Println("✅ 日志分析服务已启动...")
-	log.Printf("✅ Prometheus 指标服务已启动, 端口: %s", port)
 
-	// 主循环
-	ticker := time.NewTicker(time.Second)
+	// shutdown 在采集停止（ctx 已取消）后调用：关闭事件通道，等待工作池处理完已分发的事件，最多等待 grace，
+	// 之后停止工作协程，提交存储、关闭写前日志并保存状态，返回提交存储时的错误
+	shutdown := func(grace time.Duration) error {
+		// 磁盘事件队列的读取协程退出后才能关闭事件通道
+		<-pumpDone
+		close(eventChan)
+		drained := make(chan struct{})
+		go func() {
+			inflight.Wait()
+			close(drained)
+		}()
+		timer := time.NewTimer(grace)
+		select {
+		case <-drained:
+		case <-timer.C:
+			log.Printf("⚠️ 等待工作池处理已分发的事件超过 %v", grace)
+		}
+		timer.Stop()
+		cancelWorkers()
+		// 启用写前日志时未处理完的事件在下次启动时重放
+		if wal == nil && backlog.Len() > 0 {
+			log.Printf("⚠️ 退出时仍有 %d 个事件未处理完，已丢弃", backlog.Len())
+			metrics.DropEvents(metrics.DropShutdown, backlog.Len())
+		}
+		closeErr := store.Close()
+		if closeErr != nil {
+			log.Printf("%v", closeErr)
+		}
+		if err := wal.Close(); err != nil {
+			log.Printf("%v", err)
+		}
+		if err := diskQueue.Close(); err != nil {
+			log.Printf("%v", err)
+		}
+		if err := smart.Save(cfg.AnomalyStateFile); err != nil {
+			log.Printf("%v", err)
+		}
+		if err := traces.Save(cfg.TraceStateFile); err != nil {
+			log.Printf("%v", err)
+		}
+		if err := incidents.Save(cfg.IncidentStateFile); err != nil {
+			log.Printf("%v", err)
+		}
+		// 退出前推送最后一次指标，短期运行的实例不丢失最后一个推送间隔内的数据
+		pusher.Push(context.Background())
+		return closeErr
+	}
+
+	// dispatch 识别采集到的事件的速率异常、租户、序列规则和SLO，持久化后分发给工作池，返回分发的全部事件；
+	// 分发过程中退出时返回false
+	dispatch := func(events []collector.LogEvent) ([]collector.LogEvent, bool) {
+		for _, e := range events {
+			metrics.LogEventsCollectedCount.WithLabelValues(metrics.FileLabel(e.FilePath), metrics.HostLabel(e.Host), metrics.SeverityBucket(e.SeverityScore)).Inc()
+		}
+		log.Printf("发现 %d 个新的日志事件", len(events))
+
+		// 速率异常事件与普通事件进入同一处理流程
+		for _, event := range events {
+			if anomaly := smart.Observe(event); anomaly != nil {
+				log.Printf("⚠️ 检测到日志速率异常 [模板: %s]", event.TemplateID)
+				events = append(events, *anomaly)
+			}
+		}
+		for i := range events {
+			tenants.Resolve(&events[i])
+			traces.Link(&events[i])
+			stats.Observe(events[i])
+		}
+		// 序列规则按租户分组，在识别租户之后检测；复合告警沿用最后一步事件的租户
+		for _, event := range events {
+			for _, composite := range sequences.Observe(event) {
+				log.Printf("⚠️ 序列规则命中 [规则: %s, Host: %s]", strings.TrimPrefix(composite.TemplateID, "sequence-"), composite.Host)
+				events = append(events, composite)
+			}
+		}
+		// SLO 只统计采集到的事件，不统计速率异常和序列规则生成的事件
+		for _, event := range events {
+			if strings.HasPrefix(event.TemplateID, "anomaly-") || strings.HasPrefix(event.TemplateID, "sequence-") {
+				continue
+			}
+			for _, burn := range slos.Observe(event) {
+				log.Printf("⚠️ SLO错误预算消耗过快 [SLO: %s]", strings.TrimPrefix(burn.TemplateID, "slo-"))
+				events = append(events, burn)
+			}
+		}
+
+		// 启用磁盘事件队列时追加到磁盘后立即返回，不等待工作池空闲
+		if diskQueue != nil {
+			if err := diskQueue.Push(events); err != nil {
+				log.Printf("⚠️ 事件写入磁盘事件队列失败，已丢弃 %d 个事件: %v", len(events), err)
+				diag.Record("queue", err)
+				metrics.DropEvents(metrics.DropQueueFull, len(events))
+			}
+			return events, true
+		}
+
+		// 持久化后再分发，写入写前日志失败的事件照常处理，但崩溃后不会重放
+		if err := wal.Append(ctx, events); err != nil {
+			log.Printf("事件持久化失败: %v", err)
+		}
+
+		// 发送事件到处理通道，工作池忙且优先级队列已满时阻塞
+		backlog.Add(len(events))
+		flow.Acquire(events)
+		inflight.Add(len(events))
+		start := time.Now()
+		defer func() {
+			metrics.FlowThrottledSeconds.WithLabelValues("dispatch").Add(time.Since(start).Seconds())
+		}()
+		for i := range events {
+			event := events[i]
+			select {
+			case eventChan <- &event:
+				// 事件已发送到处理通道
+			case <-ctx.Done():
+				abandon(events[i:])
+				return events, false
+			}
+		}
+		return events, true
+	}
+
+	// 单次运行：不按采集时间表，读取所有文件中新的日志一次，等待事件处理完成后退出
+	if opts.Once {
+		return runOnce(ctx, cancel, opts, cfg, logFiles, &inflight, dispatch, shutdown)
+	}
+
+	// 主循环：每轮只读取按采集时间表到期的文件
+	ticker := time.NewTicker(scheduler.Tick())
 	defer ticker.Stop()
+	paused := false
+	var pausedSince time.Time
 
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("正在等待所有任务完成...")
-			close(eventChan)
-			// 等待一段时间确保所有任务完成
-			time.Sleep(2 * time.Second)
+			shutdown(cfg.ShutdownGrace)
 			log.Println("服务已优雅退出")
-			return
+			return 0
+		case targets := <-targetUpdates:
+			scheduler.SetFiles(targets.files, targets.schedules)
+			coordinator.SetFiles(targets.files)
+			logFiles = targets.files
+			ticker.Reset(scheduler.Tick())
+			log.Printf("采集目标已更新，共 %d 个日志文件", len(logFiles))
 		case <-ticker.C:
-			// 采集新的日志事件
-			events, err := collector.ReadNewLogEvents(cfg.LogFiles)
+			probe.Tick()
+			for path, lag := range collector.Lag(logFiles) {
+				metrics.CollectorLagBytes.WithLabelValues(path).Set(float64(lag))
+			}
+			stats.UpdateMetrics()
+			slos.UpdateMetrics()
+			// 下游积压或待处理事件占用的内存达到上限时暂停采集，偏移量不前进，日志留在文件中等积压消化后再读取；
+			// 启用磁盘事件队列时积压留在磁盘上，采集不暂停
+			if diskQueue == nil && (backlog.Full() || flow.Exhausted()) {
+				if !paused {
+					if backlog.Full() {
+						log.Printf("⚠️ 待处理事件积压达到 %d 个，暂停采集", cfg.MaxBacklogEvents)
+					} else {
+						log.Printf("⚠️ 待处理事件占用的内存达到 %d 字节，暂停采集", cfg.FlowMaxInflightBytes)
+					}
+					metrics.CollectorPaused.Set(1)
+					paused = true
+					pausedSince = time.Now()
+				}
+				metrics.FlowThrottledSeconds.WithLabelValues("collect").Add(time.Since(pausedSince).Seconds())
+				pausedSince = time.Now()
+				cleanupExpired(store, alertCache, tickets, storm, incidents, smart)
+				continue
+			}
+			if paused {
+				log.Println("事件积压已消化，恢复采集")
+				metrics.CollectorPaused.Set(0)
+				metrics.FlowThrottledSeconds.WithLabelValues("collect").Add(time.Since(pausedSince).Seconds())
+				paused = false
+			}
+
+			// 采集到期文件中新的日志事件
+			// 启用多实例协调时只读取本实例认领的文件，读取并保存偏移量之前不会把文件交给其他实例
+			due, release := coordinator.Hold(scheduler.Due(time.Now()))
+			if len(due) == 0 {
+				release()
+				cleanupExpired(store, alertCache, tickets, storm, incidents, smart)
+				continue
+			}
+			// 每个文件本轮读取的字节数不超过剩余的内存额度，下游变慢时采集随之减速
+			collectConfig := collector.DefaultConfig
+			if diskQueue == nil {
+				collectConfig.MaxReadBytes = flow.ReadBytes(len(due), collectConfig.MaxReadBytes)
+			}
+			events, err := collector.ReadNewLogEventsWithConfig(due, collectConfig)
+			release()
 			if err != nil {
 				log.Printf("日志采集失败: %v", err)
+				diag.Record("collector", err)
 				metrics.LogCollectErrorCount.Inc()
+				// 超时前已读取的事件偏移量已保存，不会再次采集
+				metrics.DropEvents(metrics.DropCollectError, len(events))
 				continue
 			}
 
 			if len(events) > 0 {
-				metrics.LogEventsCollectedCount.Add(float64(len(events)))
-				log.Printf("发现 %d 个新的日志事件", len(events))
+				if _, ok := dispatch(events); !ok {
+					// 分发过程中收到退出信号，与正常退出一样处理完已分发的事件并提交存储、保存状态
+					log.Println("正在等待所有任务完成...")
+					shutdown(cfg.ShutdownGrace)
+					log.Println("服务已优雅退出")
+					return 0
+				}
+			}
 
-				// 发送事件到处理通道
-				for _, event := range events {
-					select {
-					case eventChan <- &event:
-						// 事件已发送到处理通道
-					case <-ctx.Done():
-						return
-					}
+			cleanupExpired(store, alertCache, tickets, storm, incidents, smart)
+		}
+	}
+}
+
+// runOnce 读取所有日志文件中新的日志一次并分发处理，等待处理完成后提交存储并退出；
+// 返回退出码：采集或提交存储失败时为1，发现严重性不低于 FailSeverity 的事件时为2，否则为0
+func runOnce(ctx context.Context, cancel context.CancelFunc, opts runOptions, cfg *config.Config, files []string, inflight *sync.WaitGroup,
+	dispatch func([]collector.LogEvent) ([]collector.LogEvent, bool), shutdown func(time.Duration) error) int {
+	code := 0
+	// 单次运行读到文件末尾，不限制每个文件单次读取的字节数
+	collectConfig := collector.DefaultConfig
+	collectConfig.MaxReadBytes = 0
+	events, err := collector.ReadNewLogEventsWithConfig(files, collectConfig)
+	if err != nil {
+		log.Printf("日志采集失败: %v", err)
+		metrics.LogCollectErrorCount.Inc()
+		metrics.DropEvents(metrics.DropCollectError, len(events))
+		code = 1
+		events = nil
+	}
+
+	high := 0
+	var dispatched []collector.LogEvent
+	if len(events) > 0 {
+		var ok bool
+		dispatched, ok = dispatch(events)
+		for _, event := range dispatched {
+			if event.SeverityScore >= opts.FailSeverity {
+				high++
+			}
+		}
+		if ok {
+			// 等待工作池处理完所有事件（AI分析、写入存储和发送告警），收到退出信号时不再等待
+			done := make(chan struct{})
+			go func() {
+				inflight.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				code = 1
+			}
+		}
+	}
+	cancel()
+	if err := shutdown(0); err != nil {
+		code = 1
+	}
+
+	log.Printf("单次运行完成: 处理 %d 个事件，其中严重性不低于 %d 的 %d 个", len(dispatched), opts.FailSeverity, high)
+	if code == 0 && high > 0 {
+		code = exitHighSeverity
+	}
+	return code
+}
+
+// cleanupExpired 清理过期的合并告警记录（关闭工单、写入恢复状态）、已恢复的故障以及风暴检测和速率统计的过期数据
+func cleanupExpired(store sink.Multi, alertCache *alert.AlertCache, tickets *alert.TicketManager, storm *alert.StormDetector, incidents *alert.IncidentTracker, smart *analyzer.SmartAnalyzer) {
+	if expired := alertCache.Cleanup(); len(expired) > 0 {
+		if tickets != nil {
+			go func() {
+				for _, err := range tickets.Resolve(expired) {
+					log.Printf("工单关闭失败: %v", err)
 				}
+			}()
+		}
+		for _, a := range expired {
+			storeAlert(store, a, esclient.AlertResolved)
+		}
+	}
+	for _, inc := range incidents.Cleanup() {
+		storeIncident(store, inc)
+	}
+	storm.Cleanup()
+	smart.Cleanup()
+}
+
+// newESClient 创建ES客户端并按配置设置索引命名和数据流，服务模式和命令行子命令共用；创建时不连接集群
+func newESClient(cfg *config.Config, nodes []string) (*esclient.ESClient, error) {
+	esClient, err := esclient.NewESClient(nodes, cfg.ESIndex, esclient.ClientOptions{
+		Compress:        cfg.ESCompress,
+		RequestTimeout:  cfg.ESRequestTimeout,
+		MaxIdleConns:    cfg.ESMaxIdleConns,
+		IdleConnTimeout: cfg.ESIdleConnTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = esClient.SetIndexNaming(esclient.IndexNaming{
+		Pattern:     cfg.ESIndexPattern,
+		Granularity: cfg.ESIndexGranularity,
+		Tenant:      cfg.ESTenant,
+		Alias:       cfg.ESIndexAlias,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ESDataStream {
+		esClient.UseDataStream()
+	}
+	if cfg.ESWriteMode == "upsert" {
+		esClient.UseUpsert()
+	}
+	esClient.SetSeverityTiers(esclient.SeverityTiers{
+		HotMinSeverity:     cfg.ESHotMinSeverity,
+		HotRetentionDays:   cfg.ESHotRetentionDays,
+		NoiseMaxSeverity:   cfg.ESNoiseMaxSeverity,
+		NoiseRetentionDays: cfg.ESNoiseRetentionDays,
+	})
+	return esClient, nil
+}
+
+// connectESClient 创建ES客户端并连接集群，子命令在ES不可用时直接失败
+func connectESClient(cfg *config.Config) (*esclient.ESClient, error) {
+	esClient, err := newESClient(cfg, cfg.ESNodes)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := esClient.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return esClient, nil
+}
+
+// configureESWrites 配置ES写入的熔断、溢出队列和批量写入，spillDir 为空时不启用溢出队列
+func configureESWrites(cfg *config.Config, esClient *esclient.ESClient, spillDir string) error {
+	// ES不可用时熔断，写入失败的文档先缓存到磁盘，集群恢复后重放
+	esClient.SetCircuitBreaker(cfg.ESBreakerFailures, cfg.ESBreakerCooldown)
+	if spillDir != "" {
+		if err := esClient.EnableSpill(spillDir, cfg.ESSpillMaxBytes); err != nil {
+			return err
+		}
+	}
+	if cfg.ESBulkActions > 0 {
+		return esClient.StartBulk(esclient.BulkOptions{
+			Actions:       cfg.ESBulkActions,
+			Bytes:         cfg.ESBulkBytes,
+			FlushInterval: cfg.ESBulkFlushInterval,
+			Workers:       cfg.ESBulkWorkers,
+		})
+	}
+	return nil
+}
+
+// newTenantClients 为单独配置了ES集群的租户创建ES客户端，创建时不连接集群
+func newTenantClients(cfg *config.Config, tenants *tenant.Config) (map[string]*esclient.ESClient, error) {
+	tenantClients := make(map[string]*esclient.ESClient)
+	if !cfg.EnableES || tenants == nil {
+		return tenantClients, nil
+	}
+	for name, settings := range tenants.Tenants {
+		if len(settings.ESNodes) == 0 {
+			continue
+		}
+		c, err := newESClient(cfg, settings.ESNodes)
+		if err != nil {
+			return nil, fmt.Errorf("初始化租户 %s 的ES客户端失败: %w", name, err)
+		}
+		c.SetTenantRetention(tenants.Retention())
+		spillDir := ""
+		if cfg.ESSpillDir != "" {
+			spillDir = filepath.Join(cfg.ESSpillDir, "tenant-"+name)
+		}
+		if err := configureESWrites(cfg, c, spillDir); err != nil {
+			return nil, err
+		}
+		tenantClients[name] = c
+		log.Printf("✅ 租户 %s 写入独立的ES集群: %s", name, strings.Join(settings.ESNodes, ","))
+	}
+	return tenantClients, nil
+}
+
+// newStore 按配置创建事件和告警的存储后端，服务模式和历史日志回填共用
+func newStore(cfg *config.Config, esClient *esclient.ESClient, tenantClients map[string]*esclient.ESClient) (sink.Multi, error) {
+	var store sink.Multi
+	if cfg.EnableES {
+		var es sink.Sink = sink.NewESSink(esClient, cfg.ESAlertIndex, cfg.ESIncidentIndex, cfg.ESAlertStore)
+		if len(tenantClients) > 0 {
+			routes := make(map[string]sink.Sink, len(tenantClients))
+			for name, c := range tenantClients {
+				routes[name] = sink.NewESSink(c, cfg.ESAlertIndex, cfg.ESIncidentIndex, cfg.ESAlertStore)
+			}
+			es = sink.NewRouter(es, routes)
+		}
+		store = append(store, es)
+	}
+	if cfg.PGDSN != "" {
+		pg, err := sink.NewPostgres(func() string { return cfg.Secret("PG_DSN") }, cfg.PGMaxConns)
+		if err != nil {
+			return nil, err
+		}
+		store = append(store, pg)
+		log.Println("✅ PostgreSQL存储已启用")
+	}
+	if cfg.OutputFile != "" {
+		f, err := sink.NewFile(cfg.OutputFile, cfg.OutputFileAlerts)
+		if err != nil {
+			return nil, err
+		}
+		store = append(store, f)
+		log.Printf("✅ NDJSON输出已启用: %s", cfg.OutputFile)
+	}
+	if len(cfg.KafkaBrokers) > 0 {
+		k, err := sink.NewKafka(sink.KafkaOptions{
+			Brokers:       cfg.KafkaBrokers,
+			Topic:         cfg.KafkaTopic,
+			AlertTopic:    cfg.KafkaAlertTopic,
+			TLS:           cfg.KafkaTLS,
+			SASLMechanism: cfg.KafkaSASLMechanism,
+			Username:      cfg.KafkaUsername,
+			Password:      func() string { return cfg.Secret("KAFKA_PASSWORD") },
+		})
+		if err != nil {
+			return nil, err
+		}
+		store = append(store, k)
+		log.Printf("✅ Kafka输出已启用, 主题: %s", cfg.KafkaTopic)
+	}
+	if cfg.VictoriaLogsURL != "" {
+		v, err := sink.NewVictoriaLogs(sink.VictoriaLogsOptions{
+			URL:       cfg.VictoriaLogsURL,
+			AccountID: cfg.VictoriaLogsAccountID,
+			ProjectID: cfg.VictoriaLogsProjectID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		store = append(store, v)
+		log.Println("✅ VictoriaLogs输出已启用")
+	}
+	// 写入的事件、告警和故障标注实例，多个实例写入同一个后端时可区分来源
+	for i := range store {
+		store[i] = sink.WithInstance(store[i], cfg.InstanceID, cfg.InstanceLabels)
+	}
+	return store, nil
+}
+
+// setupES 连接ES后配置索引保留期和索引模板，返回是否需要由本服务定期删除过期索引
+func setupES(cfg *config.Config, esClient *esclient.ESClient) bool {
+	// 索引保留期：优先使用ILM策略，需要定期删除时在服务启动后运行清理任务；数据流还需要ILM策略滚动后备索引
+	deleteExpired := false
+	if cfg.ESRetentionDays > 0 || cfg.ESDataStream || esClient.HasIndexRetention() {
+		deleteExpired = setupRetention(cfg, esClient)
+	}
+	if cfg.ESIndexTemplate {
+		// 模板安装失败（如账号没有管理模板的权限）时沿用动态映射，不影响写入
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		// 数据流依赖模板创建，模板安装失败时无法写入
+		if err := esClient.EnsureIndexTemplate(ctx); err != nil && cfg.ESDataStream {
+			log.Fatalf("%v，无法创建数据流 %s", err, esClient.DataStream())
+		} else if err != nil {
+			log.Printf("⚠️ %v，新索引将使用动态映射", err)
+		} else {
+			log.Printf("✅ ES索引模板已安装, 匹配: %s", esClient.IndexPattern())
+		}
+	}
+	return deleteExpired
+}
+
+// setupRetention 为事件索引配置保留期，返回是否需要由本服务定期删除过期索引
+// auto 模式下优先创建ILM策略（需安装索引模板以关联新索引），集群不支持ILM时改为定期删除
+func setupRetention(cfg *config.Config, esClient *esclient.ESClient) bool {
+	// ILM策略无法区分租户和严重性分层，单独设置了保留期时由本服务按索引删除
+	if esClient.HasIndexRetention() && cfg.ESRetentionMode != "ilm" && !cfg.ESDataStream {
+		log.Printf("✅ ES索引默认保留 %d 天（0表示永久），部分租户或严重性分层单独设置了保留期，过期索引由本服务定期删除", cfg.ESRetentionDays)
+		return true
+	}
+	if esClient.HasIndexRetention() {
+		log.Printf("⚠️ ILM策略和数据流不区分租户和严重性分层，单独设置的保留期不生效")
+	}
+	if cfg.ESRetentionMode == "delete" || (cfg.ESRetentionMode == "auto" && !cfg.ESIndexTemplate) {
+		log.Printf("✅ ES索引保留 %d 天，过期索引由本服务定期删除", cfg.ESRetentionDays)
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := esClient.SetupILM(ctx, cfg.ESRetentionDays); err != nil {
+		if cfg.ESRetentionMode == "ilm" {
+			log.Fatalf("配置ES索引保留策略失败: %v", err)
+		}
+		// 数据流的后备索引没有日期后缀，无法由本服务按日期删除
+		if esClient.DataStream() != "" {
+			log.Printf("⚠️ %v，数据流 %s 的后备索引不会自动滚动和清理", err, esClient.DataStream())
+			return false
+		}
+		log.Printf("⚠️ %v，过期索引改由本服务定期删除", err)
+		return true
+	}
+	if cfg.ESRetentionDays > 0 {
+		log.Printf("✅ ES索引保留 %d 天，已配置ILM策略", cfg.ESRetentionDays)
+	} else {
+		log.Printf("✅ 已配置ILM策略，数据流 %s 的后备索引按天滚动", esClient.DataStream())
+	}
+	return false
+}
+
+// configureAI 初始化AI调用共享的传输层、限流和预算，服务模式与 sidecar 模式共用
+func configureAI(cfg *config.Config) {
+	// AI调用的代理和TLS配置
+	if err := ai.ConfigureTransport(cfg); err != nil {
+		log.Fatalf("初始化AI HTTP客户端失败: %v", err)
+	}
+
+	// AI请求限流，所有工作协程共享
+	ai.SetLimiter(cfg.AIMaxInFlight, cfg.AIRequestsPerMin, cfg.AIQueueTimeout)
+
+	// AI预算，超出后降级为历史分析或规则摘要，并发送一次预算耗尽告警
+	ai.SetBudget(ai.BudgetLimits{
+		DailyTokens:          cfg.AIDailyTokenLimit,
+		MonthlyTokens:        cfg.AIMonthlyTokenLimit,
+		DailyCost:            cfg.AIDailyCostLimit,
+		MonthlyCost:          cfg.AIMonthlyCostLimit,
+		PromptPricePer1K:     cfg.AIPromptPricePer1K,
+		CompletionPricePer1K: cfg.AICompletionPricePer1K,
+	}, func(reason string) {
+		log.Printf("⚠️ AI预算已用尽: %s", reason)
+		if cfg.EnableAlert && cfg.WeChatWebhook != "" {
+			err := alert.Deliver("wechat", func() error {
+				return alert.SendWeChat(cfg.Secret("AI_WECHAT_WEBHOOK"), alert.BudgetExhaustedAlert(reason))
+			})
+			if err != nil {
+				log.Printf("预算耗尽告警发送失败: %v", err)
 			}
+		}
+	})
+}
 
-			// 清理过期的合并告警记录
-			alertCache.Cleanup()
+// refreshSecrets 按 SECRET_REFRESH_INTERVAL 定期重新解析密钥引用，解析失败时继续使用之前的值
+func refreshSecrets(ctx context.Context, cfg *config.Config) {
+	if cfg.SecretRefreshInterval <= 0 || !cfg.HasSecretRefs() {
+		return
+	}
+	ticker := time.NewTicker(cfg.SecretRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := cfg.RefreshSecrets(ctx)
+		if err != nil {
+			metrics.SecretRefreshErrorCount.Inc()
+			log.Printf("重新解析密钥引用失败，继续使用之前的值: %v", err)
+			diag.Record("secrets", err)
+		}
+		if len(changed) > 0 {
+			log.Printf("🔑 密钥已更新: %s", strings.Join(changed, ", "))
 		}
 	}
 }
 
+// applyRemoteChanges 处理集中配置的变化：识别规则文件变化时重新加载，抑制规则立即重新检查，
+// 配置项的变化需要重启后生效
+func applyRemoteChanges(cfg *config.Config, suppressor *alert.Suppressor, files, env []string) {
+	for _, name := range files {
+		path := filepath.Join(cfg.ConfigSourceDir, name)
+		log.Printf("🔄 集中配置的规则文件已更新: %s", path)
+		if cfg.HeuristicsFile != "" && sameFile(path, cfg.HeuristicsFile) {
+			if err := reloadHeuristics(cfg.HeuristicsFile); err != nil {
+				log.Printf("%v，继续使用之前的识别规则", err)
+			}
+		}
+	}
+	if len(files) > 0 {
+		suppressor.Refresh()
+	}
+	for _, name := range env {
+		log.Printf("⚠️ 集中配置中的配置项 %s 已变更，重启后生效", name)
+	}
+}
+
+// reloadHeuristics 重新加载识别规则文件，加载失败时继续使用之前的识别规则
+func reloadHeuristics(path string) error {
+	heuristics, err := collector.LoadHeuristics(path)
+	if err != nil {
+		return err
+	}
+	collector.SetHeuristics(heuristics)
+	log.Printf("✅ 识别规则已重新加载: %d 个关键词, %d 条按文件覆盖的规则", len(heuristics.Keywords), len(heuristics.Overrides))
+	return nil
+}
+
+// sameFile 判断两个路径是否指向同一个文件
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
 // worker 工作协程处理日志事件
-func worker(ctx context.Context, cfg *config.Config, esClient *esclient.ESClient, alertCache *alert.AlertCache, eventChan <-chan *collector.LogEvent, workerID int) {
+// 工作池缩容时 stop 被关闭，处理完当前事件后退出
+func worker(ctx context.Context, cfg *config.Config, store sink.Multi, wal *queue.WAL, backlog *queue.Backlog, flow *queue.Flow, pool *queue.WorkerPool, inflight *sync.WaitGroup, alertCache *alert.AlertCache, storm *alert.StormDetector, incidents *alert.IncidentTracker, suppressor *alert.Suppressor, batcher *ai.Batcher, tickets *alert.TicketManager, onCall alert.OnCallResolver, eventChan <-chan *collector.LogEvent, stop <-chan struct{}, workerID int) {
 	for {
 		select {
 		case <-ctx.Done():
 			log.Printf("工作协程 #%d 正在退出...", workerID)
 			return
-		case event := <-eventChan:
+		case <-stop:
+			log.Printf("工作池缩容，工作协程 #%d 退出", workerID)
+			return
+		case event, ok := <-eventChan:
+			if !ok {
+				// 退出时事件通道关闭且已处理完
+				return
+			}
 			if event == nil {
 				continue
 			}
+			backlog.Done()
+			started, cost := pool.Begin(), queue.EventCost(event)
 
 			log.Printf("工作协程 #%d 开始处理事件 [EventID: %s]", workerID, event.EventID)
 
 			// 1. 数据脱敏
 			event.RawText = processor.MaskSensitiveInfo(event.RawText)
 
-			// 2. AI分析
-			start := time.Now()
-			aiResult, err := ai.Analyze(cfg, event.RawText)
-			metrics.AIAnalysisDuration.Observe(time.Since(start).Seconds())
-			if err != nil {
-				log.Printf("AI分析失败 [EventID: %s]: %v", event.EventID, err)
-				metrics.AIAnalysisErrorCount.Inc()
-				// 即使AI分析失败，也继续处理其他步骤
-				aiResult = fmt.Sprintf("AI分析失败: %v", err)
+			// 将事件归入故障，同一主机或同一日志模板持续出现的事件共用一个故障
+			incident := incidents.Track(*event)
+			if incident.ID != "" {
+				event.IncidentID = incident.ID
+				storeIncident(store, incident)
 			}
 
-			// 3. 写入ES
-			if cfg.EnableES {
-				start = time.Now()
-				timestamp, err := time.Parse(time.RFC3339, event.Timestamp)
-				if err != nil {
-					// 如果RFC3339格式解析失败，尝试其他格式
-					timestamp = time.Now()
-				}
+			// 所属告警的出现次数随事件写入ES，便于在 Kibana 中按次数筛选
+			event.Occurrences = alertCache.Occurrences(*event)
 
-				if err := esClient.IndexLog(esclient.LogEvent{
-					EventID:       event.EventID,
-					Timestamp:     timestamp,
-					Host:          event.Host,
-					Tags:          event.Tags,
-					Content:       event.RawText,
-					SeverityScore: event.SeverityScore,
-					AiResult:      aiResult,
-				}); err != nil {
-					log.Printf("ES写入失败 [EventID: %s]: %v", event.EventID, err)
-					metrics.ESWriteErrorCount.Inc()
-					metrics.EventProcessErrorCount.Inc()
-					continue
-				}
-				metrics.ESWriteDuration.Observe(time.Since(start).Seconds())
-				metrics.ESWriteSuccessCount.Inc()
-			} else {
-				log.Printf("ES存储功能已禁用，跳过写入 [EventID: %s]", event.EventID)
-				// 即使禁用了ES，也认为事件处理成功
-				metrics.EventProcessSuccessCount.Inc()
+			// 2. AI分析，渐进式告警模式下分析未及时完成时先使用规则摘要
+			aiResult, pending := analyzeEvent(cfg, batcher, *event)
+
+			// 3. 写入ES，AI分析仍在进行时等分析完成后再写入；写入失败的事件不确认，下次启动时重放
+			if pending == nil && !indexEvent(store, *event, aiResult) {
+				pool.Done(started)
+				flow.Release(cost)
+				inflight.Done()
+				continue
 			}
 
 			// 4. 告警合并策略
 			send, merged := alertCache.AddOrUpdate(*event, aiResult)
+			storeAlert(store, merged, esclient.AlertActive)
+			for _, r := range ai.MatchRunbooks(cfg, *event) {
+				merged.Runbooks = append(merged.Runbooks, alert.RunbookLink{Title: r.Title, URL: r.URL})
+			}
+
+			// 日志风暴期间以汇总告警代替逐条告警
+			if inStorm, stormAlert := storm.Observe(*event); inStorm {
+				if stormAlert != nil {
+					log.Printf("检测到日志风暴 [Host: %s], 发送汇总告警", event.Host)
+					send, merged = true, *stormAlert
+					merged.IncidentID = event.IncidentID
+				} else if send {
+					send = false
+					metrics.AlertStormSuppressedCount.Inc()
+				}
+			}
+			// 被抑制的噪声模板照常分析和存储，但不发送告警
+			if send && suppressor.Suppressed(event.TemplateID) {
+				send = false
+				metrics.NoiseSuppressedCount.Inc()
+			}
+
+			// 告警正文附带所属故障的时间线
+			merged.Timeline = incident.Timeline
+
+			// 高严重性告警同步创建或更新工单，AI分析仍在进行时等分析完成后再同步
+			if send && merged.Key != "" && pending == nil {
+				merged.TicketID = syncTicket(cfg, alertCache, tickets, merged)
+			}
+
+			// 命中告警路由时发送到路由指定的企业微信机器人，并@路由指定的成员
+			webhook := cfg.Secret("AI_WECHAT_WEBHOOK")
+			var routeMentions []string
+			if route, ok := alert.MatchRoute(*event); ok {
+				webhook, routeMentions = route.Webhook, route.Mentions
+			}
+
+			sent := false
 			if send {
 				// 检查是否启用告警功能
 				if cfg.EnableAlert {
-					if cfg.WeChatWebhook != "" {
-						if err := alert.SendWeChat(cfg.WeChatWebhook, merged.Content, merged.AiResult); err != nil {
+					if webhook != "" {
+						var mentions []string
+						if onCall != nil {
+							var err error
+							if mentions, err = onCall.OnCall(time.Now()); err != nil {
+								log.Printf("查询值班人员失败 [EventID: %s]: %v", event.EventID, err)
+							}
+						}
+						mentions = append(mentions, routeMentions...)
+						wechatAlert := merged
+						wechatAlert.AiResult = ai.Localize(cfg, merged.AiResult, "wechat")
+						err := alert.Deliver("wechat", func() error {
+							return alert.SendWeChat(webhook, wechatAlert, mentions...)
+						})
+						if err != nil {
 							log.Printf("告警发送失败 [EventID: %s]: %v", event.EventID, err)
-							metrics.AlertSendErrorCount.Inc()
+							diag.Recordf("alert", "告警发送失败 [EventID: %s]: %v", event.EventID, err)
+							metrics.AlertSendErrorCount.WithLabelValues("wechat", metrics.SeverityBucket(merged.Severity)).Inc()
 							metrics.EventProcessErrorCount.Inc()
 						} else {
 							log.Printf("告警发送成功 [EventID: %s]", event.EventID)
-							metrics.AlertSentCount.Inc()
+							observeLag(*event, "alerted")
+							metrics.AlertSentCount.WithLabelValues("wechat", metrics.SeverityBucket(merged.Severity)).Inc()
 							metrics.EventProcessSuccessCount.Inc()
+							sent = true
 						}
 					} else {
 						log.Printf("跳过告警发送，未配置Webhook [EventID: %s]", event.EventID)
@@ -221,7 +1328,277 @@ func worker(ctx context.Context, cfg *config.Config, esClient *esclient.ESClient
 				metrics.EventProcessSuccessCount.Inc()
 			}
 
+			// 5. 渐进式告警：AI分析完成后回填结果、写入ES、同步工单并补发分析
+			delivered := true
+			if pending != nil {
+				aiResult = <-pending
+				delivered = indexEvent(store, *event, aiResult)
+				if merged.Key != "" {
+					alertCache.SetAiResult(merged.Key, event.EventID, aiResult)
+					merged.AiResult = aiResult
+					merged.LastEventID = event.EventID
+					if send {
+						merged.TicketID = syncTicket(cfg, alertCache, tickets, merged)
+					}
+					storeAlert(store, merged, esclient.AlertActive)
+					if sent {
+						followUp := merged
+						followUp.AiResult = ai.Localize(cfg, aiResult, "wechat")
+						err := alert.Deliver("wechat", func() error {
+							return alert.SendWeChatFollowUp(webhook, followUp)
+						})
+						if err != nil {
+							log.Printf("AI分析补充消息发送失败 [EventID: %s]: %v", event.EventID, err)
+							diag.Recordf("alert", "AI分析补充消息发送失败 [EventID: %s]: %v", event.EventID, err)
+							metrics.AlertSendErrorCount.WithLabelValues("wechat", metrics.SeverityBucket(merged.Severity)).Inc()
+						}
+					}
+				}
+			}
+
+			if delivered {
+				wal.Ack(event.Seq)
+			}
+			pool.Done(started)
+			flow.Release(cost)
+			inflight.Done()
 			log.Printf("工作协程 #%d 完成处理事件 [EventID: %s]", workerID, event.EventID)
 		}
 	}
 }
+
+// analyzeEvent 对事件进行AI分析
+// 启用渐进式告警且分析未在等待时间内完成时，返回规则摘要和一个在分析完成后接收最终结果的通道
+func analyzeEvent(cfg *config.Config, batcher *ai.Batcher, event collector.LogEvent) (string, <-chan string) {
+	analyze := func() string {
+		start := time.Now()
+		var aiResult string
+		var err error
+		if batcher != nil {
+			aiResult, err = batcher.Analyze(event)
+		} else {
+			aiResult, err = ai.Analyze(cfg, event)
+		}
+		metrics.AIAnalysisDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			log.Printf("AI分析失败 [EventID: %s]: %v", event.EventID, err)
+			diag.Recordf("ai", "AI分析失败 [EventID: %s]: %v", event.EventID, err)
+			metrics.AIAnalysisErrorCount.WithLabelValues(metrics.FileLabel(event.FilePath), metrics.SeverityBucket(event.SeverityScore)).Inc()
+			// 即使AI分析失败，也继续处理其他步骤
+			aiResult = ai.FailedResult(event, err)
+		}
+		return aiResult
+	}
+
+	if !cfg.AlertProgressive || strings.ToLower(cfg.AIEnable) != "true" {
+		return analyze(), nil
+	}
+
+	done := make(chan string, 1)
+	go func() {
+		done <- analyze()
+	}()
+	timer := time.NewTimer(cfg.AlertProgressiveDelay)
+	defer timer.Stop()
+	select {
+	case aiResult := <-done:
+		return aiResult, nil
+	case <-timer.C:
+		return ai.PreliminaryResult(event), done
+	}
+}
+
+// indexEvent 将事件写入存储后端，写入失败时返回false
+func indexEvent(store sink.Multi, event collector.LogEvent, aiResult string) bool {
+	if len(store) == 0 {
+		log.Printf("存储功能已禁用，跳过写入 [EventID: %s]", event.EventID)
+		// 即使禁用了存储，也认为事件处理成功
+		metrics.EventProcessSuccessCount.Inc()
+		return true
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, event.Timestamp)
+	if err != nil {
+		// 如果RFC3339格式解析失败，尝试其他格式
+		timestamp = time.Now()
+	}
+
+	if err := store.WriteEvent(esclient.LogEvent{
+		EventID:       event.EventID,
+		DocID:         event.DocumentID(),
+		Timestamp:     timestamp,
+		Host:          event.Host,
+		Tenant:        event.Tenant,
+		Service:       event.Service,
+		Team:          event.Team,
+		Tags:          event.Tags,
+		Content:       event.RawText,
+		SeverityScore: event.SeverityScore,
+		AiResult:      aiResult,
+		TemplateID:    event.TemplateID,
+		Fingerprint:   alert.Fingerprint(event.Host, event.TemplateID, event.SeverityScore),
+		IncidentID:    event.IncidentID,
+		TraceID:       event.TraceID,
+		Related:       relatedEvents(event.Related),
+		Occurrences:   event.Occurrences,
+	}); err != nil {
+		log.Printf("事件写入失败 [EventID: %s]: %v", event.EventID, err)
+		metrics.EventProcessErrorCount.Inc()
+		return false
+	}
+	observeLag(event, "indexed")
+	return true
+}
+
+// setAlertDelivery 按配置设置告警渠道的重试和熔断
+func setAlertDelivery(cfg *config.Config) {
+	alert.SetDeliveryOptions(alert.DeliveryOptions{
+		Retries:         cfg.AlertRetries,
+		RetryBackoff:    cfg.AlertRetryBackoff,
+		BreakerFailures: cfg.AlertBreakerFailures,
+		BreakerCooldown: cfg.AlertBreakerCooldown,
+	})
+}
+
+// observeLag 记录日志产生到事件处理完成某个阶段（indexed/alerted）的延迟，日志中没有可识别的时间戳时不记录
+func observeLag(event collector.LogEvent, stage string) {
+	if event.LogTime.IsZero() {
+		return
+	}
+	lag := max(time.Since(event.LogTime), 0)
+	metrics.EventProcessingLag.WithLabelValues(metrics.FileLabel(event.FilePath), stage).Observe(lag.Seconds())
+}
+
+// relatedEvents 转换关联事件为写入存储的格式
+func relatedEvents(related []collector.RelatedEvent) []esclient.RelatedEvent {
+	var docs []esclient.RelatedEvent
+	for _, r := range related {
+		docs = append(docs, esclient.RelatedEvent{
+			DocID:         r.DocID,
+			Host:          r.Host,
+			FilePath:      r.FilePath,
+			LineNumber:    r.LineNumber,
+			SeverityScore: r.SeverityScore,
+			Summary:       r.Summary,
+		})
+	}
+	return docs
+}
+
+// storeAlert 将合并告警写入存储后端的告警聚合记录
+func storeAlert(store sink.Multi, a alert.AggregatedAlert, status string) {
+	if err := store.WriteAlert(alertDoc(a, status)); err != nil {
+		log.Printf("告警记录写入失败 [Key: %s]: %v", a.Key, err)
+	}
+}
+
+// alertDoc 将合并告警转换为告警聚合文档
+func alertDoc(a alert.AggregatedAlert, status string) esclient.AlertDoc {
+	return esclient.AlertDoc{
+		Key:         a.Key,
+		Host:        a.Host,
+		Tenant:      a.Tenant,
+		FilePath:    a.FilePath,
+		TemplateID:  a.TemplateID,
+		Fingerprint: a.Fingerprint,
+		Severity:    a.Severity,
+		Count:       a.Count,
+		FirstSeen:   a.FirstAlertAt,
+		LastSeen:    a.LastAlertAt,
+		Content:     a.Content,
+		AiResult:    a.AiResult,
+		LastEventID: a.LastEventID,
+		TicketID:    a.TicketID,
+		IncidentID:  a.IncidentID,
+		Related:     relatedEvents(a.Related),
+		Status:      status,
+	}
+}
+
+// storeIncident 将故障的当前状态写入存储后端
+func storeIncident(store sink.Multi, inc alert.Incident) {
+	doc := esclient.IncidentDoc{
+		ID:          inc.ID,
+		Tenant:      inc.Tenant,
+		Status:      inc.Status,
+		Title:       inc.Title,
+		Hosts:       inc.Hosts,
+		Files:       inc.Files,
+		Templates:   inc.Templates,
+		EventCount:  inc.EventCount,
+		MaxSeverity: inc.MaxSeverity,
+		OpenedAt:    inc.OpenedAt,
+		UpdatedAt:   inc.UpdatedAt,
+		LastEventID: inc.LastEventID,
+	}
+	if !inc.ResolvedAt.IsZero() {
+		doc.ResolvedAt = &inc.ResolvedAt
+	}
+	for _, e := range inc.Timeline {
+		doc.Timeline = append(doc.Timeline, esclient.IncidentTimelineEntry{
+			At:       e.At,
+			Kind:     e.Kind,
+			Host:     e.Host,
+			FilePath: e.FilePath,
+			EventID:  e.EventID,
+			Severity: e.Severity,
+			Summary:  e.Summary,
+		})
+	}
+	if err := store.WriteIncident(doc); err != nil {
+		log.Printf("故障记录写入失败 [Incident: %s]: %v", inc.ID, err)
+	}
+}
+
+// syncTicket 为告警创建或更新工单，返回关联的工单ID
+func syncTicket(cfg *config.Config, alertCache *alert.AlertCache, tickets *alert.TicketManager, merged alert.AggregatedAlert) string {
+	ticketAlert := merged
+	ticketAlert.AiResult = ai.Localize(cfg, merged.AiResult, "ticket")
+	ticketID, err := tickets.Sync(ticketAlert)
+	if err != nil {
+		log.Printf("工单同步失败 [EventID: %s]: %v", merged.LastEventID, err)
+		return merged.TicketID
+	}
+	if ticketID != "" {
+		alertCache.SetTicketID(merged.Key, ticketID)
+		return ticketID
+	}
+	return merged.TicketID
+}
+
+// newOnCallResolver 根据配置创建值班解析器，未配置值班来源时返回nil
+func newOnCallResolver(cfg *config.Config) (alert.OnCallResolver, error) {
+	var resolver alert.OnCallResolver
+	switch cfg.OnCallSource {
+	case "":
+		return nil, nil
+	case "rotation":
+		r, err := alert.NewRotationResolver(cfg.OnCallRotationFile)
+		if err != nil {
+			return nil, err
+		}
+		resolver = r
+	case "pagerduty":
+		resolver = alert.NewPagerDutyResolver(func() string { return cfg.Secret("ONCALL_TOKEN") }, cfg.OnCallSchedule)
+	case "opsgenie":
+		resolver = alert.NewOpsgenieResolver(func() string { return cfg.Secret("ONCALL_TOKEN") }, cfg.OnCallSchedule)
+	default:
+		return nil, fmt.Errorf("不支持的值班来源: %s", cfg.OnCallSource)
+	}
+	return alert.NewCachedResolver(resolver, time.Minute), nil
+}
+
+// newTicketManager 根据配置创建工单管理器，未配置工单系统时返回nil
+func newTicketManager(cfg *config.Config) *alert.TicketManager {
+	var ticketer alert.Ticketer
+	token := func() string { return cfg.Secret("TICKET_TOKEN") }
+	switch cfg.TicketSystem {
+	case "jira":
+		ticketer = alert.NewJiraTicketer(cfg.TicketURL, cfg.TicketUser, token, cfg.TicketProject, cfg.TicketIssueType, cfg.TicketResolveTransition)
+	case "servicenow":
+		ticketer = alert.NewServiceNowTicketer(cfg.TicketURL, cfg.TicketUser, token)
+	default:
+		return nil
+	}
+	return alert.NewTicketManager(ticketer, cfg.TicketMinSeverity, cfg.KibanaURL)
+}
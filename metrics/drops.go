@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件丢弃原因，作为 events_dropped_total 的 reason 标签
+const (
+	DropCollectError = "collect_error" // 采集超时，已读取并保存偏移量的事件未进入处理流程
+	DropSinkError    = "sink_error"    // 写入存储后端失败
+	DropESRejected   = "es_rejected"   // 被ES拒绝（如字段映射冲突）或批量请求出错
+	DropESSpillFull  = "es_spill_full" // ES不可用且溢出队列未启用、已满或写盘失败
+	DropShutdown     = "shutdown"      // 退出时仍未处理完，且未启用写前日志
+	DropQueueFull    = "queue_full"    // 磁盘事件队列已满或写盘失败
+)
+
+// pendingDrops 上次汇总以来各原因丢弃的事件数
+var (
+	dropMu       sync.Mutex
+	pendingDrops = make(map[string]int)
+)
+
+// DropEvents 记录因 reason 丢弃的 n 个事件
+func DropEvents(reason string, n int) {
+	if n <= 0 {
+		return
+	}
+	EventsDroppedCount.WithLabelValues(reason).Add(float64(n))
+	dropMu.Lock()
+	pendingDrops[reason] += n
+	dropMu.Unlock()
+}
+
+// RunDropSummary 每隔 interval 汇总一次期间丢弃的事件并输出警告日志，没有丢弃时不输出，直到 ctx 结束
+func RunDropSummary(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if summary, total := takeDropSummary(); total > 0 {
+				log.Printf("⚠️ 最近 %s 内丢弃了 %d 个事件: %s", interval, total, summary)
+			}
+		}
+	}
+}
+
+// takeDropSummary 返回上次汇总以来按原因统计的丢弃数并清零
+func takeDropSummary() (string, int) {
+	dropMu.Lock()
+	drops := pendingDrops
+	pendingDrops = make(map[string]int)
+	dropMu.Unlock()
+
+	reasons := make([]string, 0, len(drops))
+	total := 0
+	for reason, n := range drops {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, n))
+		total += n
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", "), total
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// Handler 返回 /metrics 的处理器，每个指标附加 instance_id 和实例的静态标签；
+// 不使用 instance 作为标签名，避免与 Prometheus 抓取时添加的 instance 标签冲突
+func Handler(instance string, labels map[string]string) http.Handler {
+	extra := make(map[string]string, len(labels)+1)
+	for name, value := range labels {
+		extra[name] = value
+	}
+	if instance != "" {
+		extra["instance_id"] = instance
+	}
+	if len(extra) == 0 {
+		return promhttp.Handler()
+	}
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(labeledGatherer(extra), promhttp.HandlerOpts{}))
+}
+
+// labeledGatherer 返回在默认注册表的每个指标上附加 extra 标签的 Gatherer
+func labeledGatherer(extra map[string]string) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := prometheus.DefaultGatherer.Gather()
+		for _, family := range families {
+			for _, m := range family.Metric {
+				m.Label = withLabels(m.Label, extra)
+			}
+		}
+		return families, err
+	})
+}
+
+// withLabels 在指标原有标签之后追加实例标签并按名称排序，指标已有同名标签时保留原值
+func withLabels(pairs []*dto.LabelPair, extra map[string]string) []*dto.LabelPair {
+	existing := make(map[string]bool, len(pairs))
+	for _, p := range pairs {
+		existing[p.GetName()] = true
+	}
+	for name, value := range extra {
+		if !existing[name] {
+			pairs = append(pairs, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].GetName() < pairs[j].GetName()
+	})
+	return pairs
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// OtherLabel 标签的不同取值超过上限后，新出现的取值统一记为该值
+const OtherLabel = "other"
+
+// maxLabelValues 文件、主机标签各自最多记录的不同取值数，防止日志文件或主机过多时指标序列无限增长
+var maxLabelValues atomic.Int64
+
+func init() {
+	maxLabelValues.Store(100)
+}
+
+// SetMaxLabelValues 设置文件、主机标签最多记录的不同取值数，应在采集开始之前调用
+func SetMaxLabelValues(n int) {
+	maxLabelValues.Store(int64(n))
+}
+
+// labelGuard 记录标签已出现的取值，超过上限后新取值归入 OtherLabel
+type labelGuard struct {
+	mu     sync.Mutex
+	values map[string]struct{}
+}
+
+var (
+	fileLabels = &labelGuard{values: make(map[string]struct{})}
+	hostLabels = &labelGuard{values: make(map[string]struct{})}
+)
+
+// value 返回标签取值，已记录的取值原样返回，达到上限后的新取值返回 OtherLabel
+func (g *labelGuard) value(v string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.values[v]; ok {
+		return v
+	}
+	if int64(len(g.values)) >= maxLabelValues.Load() {
+		return OtherLabel
+	}
+	g.values[v] = struct{}{}
+	return v
+}
+
+// FileLabel 返回日志文件标签的取值
+func FileLabel(path string) string {
+	return fileLabels.value(path)
+}
+
+// HostLabel 返回主机标签的取值
+func HostLabel(host string) string {
+	return hostLabels.value(host)
+}
+
+// SeverityBucket 返回严重性分级标签的取值，与 /api/stats 的分级一致：8分以上为 critical，5分以上为 warning，其余为 info
+func SeverityBucket(score int) string {
+	switch {
+	case score >= 8:
+		return "critical"
+	case score >= 5:
+		return "warning"
+	default:
+		return "info"
+	}
+}
@@ -7,10 +7,10 @@ import (
 
 var (
 	// 日志采集相关指标
-	LogEventsCollectedCount = promauto.NewCounter(prometheus.CounterOpts{
+	LogEventsCollectedCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "log_events_collected_total",
-		Help: "采集的日志事件总数",
-	})
+		Help: "采集的日志事件总数（按日志文件、主机和严重性分级）",
+	}, []string{"file", "host", "severity"})
 
 	LogCollectErrorCount = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "log_collect_errors_total",
@@ -18,10 +18,10 @@ var (
 	})
 
 	// AI分析相关指标
-	AIAnalysisErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+	AIAnalysisErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "ai_analysis_errors_total",
-		Help: "AI分析错误次数",
-	})
+		Help: "AI分析错误次数（按日志文件和严重性分级）",
+	}, []string{"file", "severity"})
 
 	AIAnalysisDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "ai_analysis_duration_seconds",
@@ -29,11 +29,223 @@ var (
 		Buckets: prometheus.DefBuckets,
 	})
 
+	AITokensUsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_tokens_used_total",
+		Help: "AI调用消耗的token数",
+	}, []string{"type"})
+
+	AICostTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_cost_total",
+		Help: "AI调用估算费用",
+	})
+
+	AIBudgetExhaustedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_budget_exhausted_total",
+		Help: "因预算耗尽而降级的AI分析次数",
+	})
+
+	EventQueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "event_queue_length",
+		Help: "优先级队列中等待处理的事件数（按严重性分级）",
+	}, []string{"severity"})
+
+	EventQueueWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "event_queue_wait_seconds",
+		Help:    "事件在优先级队列中的等待时间分布（按严重性分级）",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"severity"})
+
+	PipelineBacklogEvents = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pipeline_backlog_events",
+		Help: "已采集但尚未被工作协程取走的事件数",
+	})
+
+	WorkerPoolSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_pool_size",
+		Help: "当前的工作协程数",
+	})
+
+	WorkerPoolBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "worker_pool_busy",
+		Help: "正在处理事件的工作协程数",
+	})
+
+	FlowInflightBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "flow_inflight_bytes",
+		Help: "已分发但尚未处理完（写入存储、发出告警）的事件估算占用的内存字节数",
+	})
+
+	FlowThrottledSeconds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "flow_throttled_seconds_total",
+		Help: "处理流水线各环节因下游变慢而等待的累计时间（按环节 collect、queue、dispatch、ai、sink）",
+	}, []string{"stage"})
+
+	EventProcessingLag = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "event_processing_lag_seconds",
+		Help:    "日志产生（日志行中的时间戳）到事件写入存储或告警发出的延迟（按日志文件和阶段 indexed/alerted）",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"file", "stage"})
+
+	CollectorPaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "collector_paused",
+		Help: "采集是否因下游积压而暂停（1表示暂停）",
+	})
+
+	CollectorLagBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "collector_lag_bytes",
+		Help: "日志文件中尚未读取的字节数",
+	}, []string{"file"})
+
+	WALPendingEvents = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wal_pending_events",
+		Help: "写前日志中尚未投递完成的事件数",
+	})
+
+	WALBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "wal_bytes",
+		Help: "写前日志占用的磁盘字节数",
+	})
+
+	WALReplayedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "wal_replayed_total",
+		Help: "启动时从写前日志重放的事件数",
+	})
+
+	WALDroppedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "wal_dropped_total",
+		Help: "写前日志已满或写盘失败时未能持久化或被丢弃的事件数",
+	}, []string{"reason"})
+
+	DiskQueueEvents = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "disk_queue_events",
+		Help: "磁盘事件队列中尚未交给工作池的事件数",
+	})
+
+	DiskQueueBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "disk_queue_bytes",
+		Help: "磁盘事件队列占用的磁盘字节数",
+	})
+
+	// 多实例协调相关指标
+	CoordinationOwnedFiles = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "coordination_owned_files",
+		Help: "本实例认领并负责采集的日志文件数",
+	})
+
+	CoordinationMembers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "coordination_members",
+		Help: "参与协调的存活实例数",
+	})
+
+	CoordinationErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "coordination_errors_total",
+		Help: "续约或认领文件分片失败的次数",
+	})
+
+	// Kubernetes 自定义资源相关指标
+	CRDResources = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crd_resources",
+		Help: "监听到的 Kubernetes 自定义资源数（按类型）",
+	}, []string{"kind"})
+
+	CRDWatchErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "crd_watch_errors_total",
+		Help: "列出或监听 Kubernetes 自定义资源失败的次数",
+	})
+
+	EventsDroppedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_dropped_total",
+		Help: "未能完成处理或写入而丢弃的事件数",
+	}, []string{"reason"})
+
+	AILimiterWaitDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ai_limiter_wait_seconds",
+		Help:    "AI请求在限流器中的排队时间分布",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	AILimiterTimeoutCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_limiter_timeouts_total",
+		Help: "AI请求排队超时次数",
+	})
+
+	AIRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ai_requests_in_flight",
+		Help: "正在进行中的AI请求数",
+	})
+
+	AIPromptInjectionCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_prompt_injection_filtered_total",
+		Help: "日志内容中被过滤的可疑注入指令数",
+	})
+
+	AIUnsafeResponseCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_unsafe_responses_total",
+		Help: "未通过安全校验的AI回复数",
+	})
+
+	AIToolCallCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_tool_calls_total",
+		Help: "AI分析过程中模型发起的工具调用次数",
+	}, []string{"tool", "status"})
+
+	AITriageCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_triage_total",
+		Help: "两阶段分析的分级结果数（按分级来源和是否进行深度分析）",
+	}, []string{"source", "stage"})
+
+	AIInvalidResponseCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_invalid_responses_total",
+		Help: "缺少结构化结论或取值不合理的AI回复数",
+	})
+
+	SidecarRequestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sidecar_requests_total",
+		Help: "AI sidecar 服务端处理的请求数（按 agent 和结果）",
+	}, []string{"agent", "status"})
+
+	AIBackendUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ai_backend_up",
+		Help: "AI后端是否可用（1可用，0不可用）",
+	}, []string{"provider", "model"})
+
+	AIUnavailableSkipCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_unavailable_skips_total",
+		Help: "因AI后端不可用而跳过的AI调用次数",
+	})
+
+	SecretRefreshErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "secret_refresh_errors_total",
+		Help: "重新解析密钥引用失败的次数",
+	})
+
+	MetricsPushErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "metrics_push_errors_total",
+		Help: "推送指标到 Pushgateway 或 remote_write 地址失败的次数",
+	})
+
+	AIInputTruncatedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ai_input_truncated_total",
+		Help: "超出token预算而被裁剪的事件数",
+	})
+
+	// AI后端链中实际完成分析的后端
+	AIProviderServedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_provider_served_total",
+		Help: "各AI后端完成的分析次数",
+	}, []string{"provider", "model"})
+
+	// 运维人员对AI分析结果的评价
+	AIFeedbackCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ai_feedback_total",
+		Help: "运维人员对AI分析结果的评价次数",
+	}, []string{"rating"})
+
 	// ES写入相关指标
-	ESWriteErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+	ESWriteErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "es_write_errors_total",
-		Help: "ES写入错误次数",
-	})
+		Help: "ES写入错误次数（按文档类型和严重性分级）",
+	}, []string{"kind", "severity"})
 
 	ESWriteDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Name:    "es_write_duration_seconds",
@@ -41,32 +253,186 @@ var (
 		Buckets: prometheus.DefBuckets,
 	})
 
-	ESWriteSuccessCount = promauto.NewCounter(prometheus.CounterOpts{
+	ESWriteSuccessCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "es_write_success_total",
-		Help: "ES写入成功次数",
+		Help: "ES写入成功次数（按文档类型和严重性分级）",
+	}, []string{"kind", "severity"})
+
+	ESBulkBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "es_bulk_batch_size",
+		Help:    "ES批量写入每批的文档数分布",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 7),
+	})
+
+	ESBulkQueuedDocs = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "es_bulk_queued_documents",
+		Help: "等待批量写入ES的文档数",
+	})
+
+	ESCircuitOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "es_circuit_breaker_open",
+		Help: "ES写入是否处于熔断状态（1熔断，0正常）",
 	})
 
+	ESSpillQueueDocs = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "es_spill_queue_documents",
+		Help: "ES溢出队列中等待重放的文档数",
+	})
+
+	ESSpillQueueBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "es_spill_queue_bytes",
+		Help: "ES溢出队列占用的磁盘字节数",
+	})
+
+	ESDuplicateCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "es_duplicate_docs_total",
+		Help: "因同ID的文档已存在而跳过的重复写入数",
+	})
+
+	ESSpillReplayedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "es_spill_replayed_total",
+		Help: "从溢出队列重放写入ES的文档数",
+	})
+
+	ESSpillDroppedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "es_spill_dropped_total",
+		Help: "因溢出队列已满或写盘失败而丢弃的文档数",
+	})
+
+	ESIndicesDeletedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "es_indices_deleted_total",
+		Help: "超过保留期被删除的ES索引数",
+	})
+
+	// 存储后端写入失败次数（按后端和写入类型）
+	StorageWriteErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_write_errors_total",
+		Help: "存储后端写入失败次数",
+	}, []string{"sink", "kind"})
+
 	// 告警相关指标
-	AlertSentCount = promauto.NewCounter(prometheus.CounterOpts{
+	AlertSentCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "alerts_sent_total",
-		Help: "发送的告警总数",
-	})
+		Help: "发送的告警总数（按渠道和严重性分级）",
+	}, []string{"channel", "severity"})
 
-	AlertSendErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+	AlertSendErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "alert_send_errors_total",
-		Help: "告警发送错误次数",
-	})
+		Help: "告警发送错误次数（按渠道和严重性分级）",
+	}, []string{"channel", "severity"})
+
+	AlertDeliveryCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alert_deliveries_total",
+		Help: "告警渠道的发送结果（success、error 用完重试后仍失败、rejected 熔断中未发送）",
+	}, []string{"channel", "result"})
+
+	AlertDeliveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "alert_delivery_duration_seconds",
+		Help:    "告警渠道单次发送尝试的耗时分布（按渠道和结果）",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"channel", "result"})
+
+	AlertDeliveryRetryCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alert_delivery_retries_total",
+		Help: "告警渠道发送失败后的重试次数",
+	}, []string{"channel"})
+
+	AlertChannelCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "alert_channel_circuit_open",
+		Help: "告警渠道是否处于熔断状态（1熔断，0正常）",
+	}, []string{"channel"})
 
 	AlertMergedCount = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "alerts_merged_total",
 		Help: "合并的告警总数",
 	})
 
+	AlertCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "alert_cache_entries",
+		Help: "告警缓存中未过期的合并告警数",
+	})
+
+	AlertCacheEvictionCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alert_cache_evictions_total",
+		Help: "超过告警TTL被移出告警缓存的告警数",
+	})
+
+	// 事件查找告警缓存的结果：hit 按告警键合并、similar 按内容相似度合并、miss 新建告警
+	AlertCacheLookupCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alert_cache_lookups_total",
+		Help: "事件查找告警缓存的次数（按结果 hit、similar、miss）",
+	}, []string{"result"})
+
 	AlertSkipCount = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "alerts_skip_total",
 		Help: "跳过的告警次数",
 	})
 
+	LogStormCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "log_storms_total",
+		Help: "检测到的日志风暴次数",
+	})
+
+	AnomalyDetectedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "log_rate_anomalies_total",
+		Help: "检测到的日志模板速率异常次数",
+	})
+
+	AnalyzerTemplates = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "analyzer_templates",
+		Help: "速率异常检测正在跟踪的日志模板数",
+	})
+
+	AnalyzerEvictionCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "analyzer_template_evictions_total",
+		Help: "长时间没有事件被移出速率异常检测的日志模板数",
+	})
+
+	IncidentOpenedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "incidents_opened_total",
+		Help: "新建的故障数",
+	})
+
+	IncidentsOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "incidents_open",
+		Help: "当前未恢复的故障数",
+	})
+
+	SequenceMatchedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sequence_rules_matched_total",
+		Help: "序列规则命中次数（按规则）",
+	}, []string{"rule"})
+
+	SLOBurnRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "slo_burn_rate",
+		Help: "SLO 长窗口内的错误预算燃烧率（按SLO，取各租户最大值）",
+	}, []string{"slo"})
+
+	SLOBurnAlertCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "slo_burn_alerts_total",
+		Help: "SLO 错误预算消耗过快的告警次数（按SLO）",
+	}, []string{"slo"})
+
+	EventsLastHour = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "events_last_hour",
+		Help: "最近一小时采集的事件数（按严重性分级 info/warning/critical）",
+	}, []string{"severity"})
+
+	TopTemplateEvents = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "top_template_events_last_hour",
+		Help: "最近一小时出现最多的日志模板及其事件数，只保留前10个",
+	}, []string{"template"})
+
+	NoiseSuppressedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alerts_noise_suppressed_total",
+		Help: "因日志模板在噪声抑制规则中而未发送的告警数",
+	})
+
+	AlertStormSuppressedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alerts_storm_suppressed_total",
+		Help: "日志风暴期间被抑制的告警次数",
+	})
+
 	// Cell Trace相关指标
 	CellTraceErrorCount = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "cell_trace_errors_total",
@@ -89,4 +455,10 @@ var (
 		Name: "event_process_errors_total",
 		Help: "事件处理错误次数",
 	})
+
+	// 功能开关相关指标
+	FeatureFlagEnabled = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "feature_flag_enabled",
+		Help: "实验性功能开关的当前状态（1开启，0关闭）",
+	}, []string{"name"})
 )
@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"log-ai-analyzer/diag"
+)
+
+// 指标推送方式
+const (
+	PushGateway     = "pushgateway"
+	PushRemoteWrite = "remote_write"
+)
+
+// 单次推送的超时时间
+const pushTimeout = 10 * time.Second
+
+// PushOptions 主动推送指标的配置，用于无法被 Prometheus 抓取的短期或防火墙后的实例
+type PushOptions struct {
+	URL      string
+	Mode     string        // PushGateway 或 PushRemoteWrite
+	Interval time.Duration // 推送间隔
+	Job      string        // job 标签
+
+	Instance       string            // 实例标识，作为 instance_id 标签
+	InstanceLabels map[string]string // 实例的静态标签
+	Labels         map[string]string // 推送时附加的标签，Pushgateway 模式下作为分组标签
+
+	Username string
+	Password func() string // 每次推送时读取，支持密钥轮换
+}
+
+// Pusher 定期将默认注册表中的指标推送到 Pushgateway 或 remote_write 地址
+type Pusher struct {
+	opts   PushOptions
+	client *http.Client
+}
+
+// NewPusher 创建指标推送，opts.URL 为空时返回nil
+func NewPusher(opts PushOptions) *Pusher {
+	if opts.URL == "" {
+		return nil
+	}
+	return &Pusher{opts: opts, client: &http.Client{Timeout: pushTimeout}}
+}
+
+// Run 按推送间隔推送指标，直到 ctx 结束；退出前的最后一次推送由调用方通过 Push 完成
+func (p *Pusher) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Push(ctx)
+		}
+	}
+}
+
+// Push 推送一次指标，失败时记录日志并返回错误
+func (p *Pusher) Push(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+
+	var err error
+	if p.opts.Mode == PushRemoteWrite {
+		err = p.remoteWrite(ctx)
+	} else {
+		err = p.pushGateway(ctx)
+	}
+	if err != nil {
+		MetricsPushErrorCount.Inc()
+		log.Printf("推送指标失败: %v", err)
+		diag.Record("metrics", err)
+	}
+	return err
+}
+
+// password 返回当前的 Basic 认证密码
+func (p *Pusher) password() string {
+	if p.opts.Password == nil {
+		return ""
+	}
+	return p.opts.Password()
+}
+
+// pushGateway 以 PUT 替换 Pushgateway 中本实例分组的全部指标。
+// 分组标签为推送标签和 instance_id，Pushgateway 不允许指标自身带有分组标签，因此实例标签中与分组标签同名的项不再附加
+func (p *Pusher) pushGateway(ctx context.Context) error {
+	grouping := make(map[string]string, len(p.opts.Labels)+1)
+	for name, value := range p.opts.Labels {
+		grouping[name] = value
+	}
+	if p.opts.Instance != "" {
+		grouping["instance_id"] = p.opts.Instance
+	}
+	extra := make(map[string]string, len(p.opts.InstanceLabels))
+	for name, value := range p.opts.InstanceLabels {
+		if _, ok := grouping[name]; !ok {
+			extra[name] = value
+		}
+	}
+
+	pusher := push.New(p.opts.URL, p.opts.Job).Gatherer(labeledGatherer(extra)).Client(p.client)
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if p.opts.Username != "" {
+		pusher = pusher.BasicAuth(p.opts.Username, p.password())
+	}
+	if err := pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("推送到Pushgateway失败: %w", err)
+	}
+	return nil
+}
+
+// remoteWrite 按 Prometheus remote_write 协议（protobuf + snappy）发送当前的指标，
+// 每个指标附加 job、instance_id、实例标签和推送标签
+func (p *Pusher) remoteWrite(ctx context.Context) error {
+	extra := make(map[string]string, len(p.opts.InstanceLabels)+len(p.opts.Labels)+2)
+	for name, value := range p.opts.InstanceLabels {
+		extra[name] = value
+	}
+	for name, value := range p.opts.Labels {
+		extra[name] = value
+	}
+	if p.opts.Instance != "" {
+		extra["instance_id"] = p.opts.Instance
+	}
+	extra["job"] = p.opts.Job
+
+	families, err := labeledGatherer(extra).Gather()
+	if err != nil {
+		return fmt.Errorf("收集指标失败: %w", err)
+	}
+	body := snappy.Encode(nil, encodeWriteRequest(families, time.Now().UnixMilli()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建remote_write请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if p.opts.Username != "" {
+		req.SetBasicAuth(p.opts.Username, p.password())
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("remote_write请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote_write返回状态码 %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// sample 一条时间序列的标签和当前值
+type sample struct {
+	labels []*dto.LabelPair
+	value  float64
+}
+
+// encodeWriteRequest 将指标编码为 remote_write 的 WriteRequest，
+// 直方图和摘要按 Prometheus 的约定展开为 _bucket/_sum/_count 和分位数序列
+func encodeWriteRequest(families []*dto.MetricFamily, now int64) []byte {
+	var buf []byte
+	for _, family := range families {
+		for _, m := range family.Metric {
+			ts := now
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			for _, s := range expand(family.GetName(), family.GetType(), m) {
+				buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+				buf = protowire.AppendBytes(buf, encodeSeries(s, ts))
+			}
+		}
+	}
+	return buf
+}
+
+// expand 将一个指标展开为一条或多条时间序列
+func expand(name string, typ dto.MetricType, m *dto.Metric) []sample {
+	series := func(suffix string, value float64, extra ...*dto.LabelPair) sample {
+		labels := append([]*dto.LabelPair{labelPair("__name__", name+suffix)}, m.Label...)
+		return sample{labels: append(labels, extra...), value: value}
+	}
+	switch typ {
+	case dto.MetricType_COUNTER:
+		return []sample{series("", m.GetCounter().GetValue())}
+	case dto.MetricType_GAUGE:
+		return []sample{series("", m.GetGauge().GetValue())}
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		result := []sample{series("_sum", s.GetSampleSum()), series("_count", float64(s.GetSampleCount()))}
+		for _, q := range s.Quantile {
+			result = append(result, series("", q.GetValue(), labelPair("quantile", formatFloat(q.GetQuantile()))))
+		}
+		return result
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		result := []sample{series("_sum", h.GetSampleSum()), series("_count", float64(h.GetSampleCount()))}
+		for _, b := range h.Bucket {
+			result = append(result, series("_bucket", float64(b.GetCumulativeCount()), labelPair("le", formatFloat(b.GetUpperBound()))))
+		}
+		return append(result, series("_bucket", float64(h.GetSampleCount()), labelPair("le", "+Inf")))
+	default:
+		return []sample{series("", m.GetUntyped().GetValue())}
+	}
+}
+
+// encodeSeries 编码一条 TimeSeries：标签按名称排序，只包含一个样本
+func encodeSeries(s sample, ts int64) []byte {
+	sort.Slice(s.labels, func(i, j int) bool {
+		return s.labels[i].GetName() < s.labels[j].GetName()
+	})
+	var buf []byte
+	for _, l := range s.labels {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, l.GetName())
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, l.GetValue())
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, label)
+	}
+	var point []byte
+	point = protowire.AppendTag(point, 1, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(s.value))
+	point = protowire.AppendTag(point, 2, protowire.VarintType)
+	point = protowire.AppendVarint(point, uint64(ts))
+	buf = protowire.AppendTag(buf, 2, protowire.BytesType)
+	return protowire.AppendBytes(buf, point)
+}
+
+// labelPair 创建一个标签
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: &name, Value: &value}
+}
+
+// formatFloat 按 Prometheus 文本格式输出 le 和 quantile 标签的值
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,44 @@
+package queue
+
+import (
+	"sync/atomic"
+
+	"log-ai-analyzer/metrics"
+)
+
+// Backlog 统计已采集但尚未被工作协程取走的事件数。
+// 存储后端变慢时工作协程处理变慢，积压达到上限后采集端暂停读取，偏移量不再前进，日志留在文件中而不是堆积在内存里
+type Backlog struct {
+	limit int64
+	n     atomic.Int64
+}
+
+// NewBacklog 创建积压计数，limit<=0 时不限制
+func NewBacklog(limit int) *Backlog {
+	return &Backlog{limit: int64(limit)}
+}
+
+// Add 记录新分发的 n 个事件
+func (b *Backlog) Add(n int) {
+	metrics.PipelineBacklogEvents.Set(float64(b.n.Add(int64(n))))
+}
+
+// Done 记录一个事件已被工作协程取走
+func (b *Backlog) Done() {
+	metrics.PipelineBacklogEvents.Set(float64(b.n.Add(-1)))
+}
+
+// Len 返回当前积压的事件数
+func (b *Backlog) Len() int {
+	return int(b.n.Load())
+}
+
+// Limit 返回积压上限，<=0 表示不限制
+func (b *Backlog) Limit() int {
+	return int(b.limit)
+}
+
+// Full 判断积压是否已达到上限
+func (b *Backlog) Full() bool {
+	return b.limit > 0 && b.n.Load() >= b.limit
+}
@@ -0,0 +1,375 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 磁盘队列单个分段文件的最大字节数，超过后写入新的分段
+const diskSegmentBytes = 8 << 20
+
+// ErrDiskQueueFull 磁盘队列已满，新事件未能入队
+var ErrDiskQueueFull = errors.New("磁盘事件队列已满")
+
+// errDiskQueueClosed 磁盘队列已关闭
+var errDiskQueueClosed = errors.New("磁盘事件队列已关闭")
+
+// diskSegment 磁盘队列的一个分段：事件按行写入 queue-<编号>.log
+type diskSegment struct {
+	id    uint64
+	bytes int64
+}
+
+// DiskQueue 位于采集和工作池之间的磁盘事件队列：采集到的事件追加到分段文件后立即返回，突发的大量事件不会阻塞采集；
+// 读取位置在事件交给工作池后提交并保存到 cursor 文件，进程崩溃时尚未交给工作池的事件在下次启动时继续处理
+type DiskQueue struct {
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+
+	mu       sync.Mutex
+	notify   chan struct{} // 有新事件入队时通知读取端
+	segments []*diskSegment
+	head     *os.File // 正在写入的分段，打开后第一次入队时创建
+	size     int64    // 分段占用的磁盘字节数
+	unread   int      // 尚未读取的事件数
+	closed   bool
+
+	// 读取位置：Next 读取后前进，Commit 时保存
+	readSeg  uint64
+	readOff  int64
+	readFile *os.File
+}
+
+// OpenDiskQueue 打开 dir 中的磁盘队列，从上次提交的读取位置继续，maxBytes<=0 表示不限制大小
+func OpenDiskQueue(dir string, maxBytes int64) (*DiskQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建磁盘事件队列目录失败: %w", err)
+	}
+	q := &DiskQueue{dir: dir, maxBytes: maxBytes, segmentBytes: diskSegmentBytes, notify: make(chan struct{}, 1)}
+	if maxBytes > 0 && maxBytes/4 < q.segmentBytes {
+		q.segmentBytes = maxBytes/4 + 1
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "queue-*.log"))
+	if err != nil {
+		return nil, fmt.Errorf("读取磁盘事件队列失败: %w", err)
+	}
+	for _, path := range paths {
+		id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "queue-"), ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取磁盘事件队列失败: %w", err)
+		}
+		q.segments = append(q.segments, &diskSegment{id: id, bytes: info.Size()})
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].id < q.segments[j].id })
+
+	q.readSeg, q.readOff = q.loadCursor()
+	// 删除已经读完的分段，读取位置所在的分段不存在时从最早的分段开始
+	for len(q.segments) > 0 && q.segments[0].id < q.readSeg {
+		q.removeFirst()
+	}
+	if len(q.segments) == 0 {
+		q.readOff = 0
+	} else if q.segments[0].id > q.readSeg {
+		q.readSeg, q.readOff = q.segments[0].id, 0
+	}
+	for _, seg := range q.segments {
+		q.size += seg.bytes
+		offset := int64(0)
+		if seg.id == q.readSeg {
+			offset = q.readOff
+		}
+		n, err := countLines(q.path(seg.id), offset)
+		if err != nil {
+			return nil, fmt.Errorf("读取磁盘事件队列失败: %w", err)
+		}
+		q.unread += n
+	}
+	q.updateMetrics()
+	return q, nil
+}
+
+// path 返回分段文件的路径
+func (q *DiskQueue) path(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("queue-%020d.log", id))
+}
+
+// loadCursor 读取上次提交的读取位置，格式为 "<分段编号> <偏移量>"
+func (q *DiskQueue) loadCursor() (uint64, int64) {
+	data, err := os.ReadFile(filepath.Join(q.dir, "cursor"))
+	if err != nil {
+		return 0, 0
+	}
+	seg, off, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	id, err1 := strconv.ParseUint(seg, 10, 64)
+	offset, err2 := strconv.ParseInt(off, 10, 64)
+	if err1 != nil || err2 != nil || offset < 0 {
+		return 0, 0
+	}
+	return id, offset
+}
+
+// countLines 统计文件中 offset 之后完整的行数
+func countLines(path string, offset int64) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n := 0
+	reader := bufio.NewReader(f)
+	for {
+		_, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Push 将事件追加到队列，超过大小上限时返回 ErrDiskQueueFull，此时事件均未入队
+func (q *DiskQueue) Push(events []collector.LogEvent) error {
+	if q == nil || len(events) == 0 {
+		return nil
+	}
+	var data []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("编码事件失败: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errDiskQueueClosed
+	}
+	if q.maxBytes > 0 && q.size+int64(len(data)) > q.maxBytes {
+		return ErrDiskQueueFull
+	}
+	seg, err := q.writable(int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("写入磁盘事件队列失败: %w", err)
+	}
+	if _, err := q.head.Write(data); err != nil {
+		return fmt.Errorf("写入磁盘事件队列失败: %w", err)
+	}
+	if err := q.head.Sync(); err != nil {
+		return fmt.Errorf("写入磁盘事件队列失败: %w", err)
+	}
+	seg.bytes += int64(len(data))
+	q.size += int64(len(data))
+	q.unread += len(events)
+	q.updateMetrics()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// writable 返回可以写入 n 字节的分段，当前分段已满时结束它并创建新的分段
+func (q *DiskQueue) writable(n int64) (*diskSegment, error) {
+	if q.head != nil {
+		seg := q.segments[len(q.segments)-1]
+		if seg.bytes == 0 || seg.bytes+n <= q.segmentBytes {
+			return seg, nil
+		}
+		q.head.Close()
+		q.head = nil
+	}
+
+	// 重启前的分段可能以写了一半的行结尾，新的事件总是写入新的分段
+	id := q.readSeg
+	if len(q.segments) > 0 {
+		id = q.segments[len(q.segments)-1].id + 1
+	}
+	f, err := os.OpenFile(q.path(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	q.head = f
+	seg := &diskSegment{id: id}
+	q.segments = append(q.segments, seg)
+	return seg, nil
+}
+
+// Next 从读取位置读取最多 max 个事件，队列为空时等待新事件入队，ctx 结束或队列关闭时返回错误。
+// 读取位置在 Commit 之前不保存，进程在此之间退出时这些事件在下次启动时再次读取
+func (q *DiskQueue) Next(ctx context.Context, max int) ([]collector.LogEvent, error) {
+	for {
+		events, err := q.read(max)
+		if err != nil || len(events) > 0 {
+			return events, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.notify:
+		}
+	}
+}
+
+// read 从读取位置读取最多 max 个事件，读到分段末尾时转到下一个分段
+func (q *DiskQueue) read(max int) ([]collector.LogEvent, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, errDiskQueueClosed
+	}
+
+	var events []collector.LogEvent
+	for len(events) < max {
+		i := sort.Search(len(q.segments), func(i int) bool { return q.segments[i].id >= q.readSeg })
+		if i == len(q.segments) {
+			break
+		}
+		if q.segments[i].id != q.readSeg {
+			q.advance(q.segments[i].id)
+		}
+		if q.readFile == nil {
+			f, err := os.Open(q.path(q.readSeg))
+			if err != nil {
+				return events, fmt.Errorf("读取磁盘事件队列失败: %w", err)
+			}
+			q.readFile = f
+		}
+		if _, err := q.readFile.Seek(q.readOff, io.SeekStart); err != nil {
+			return events, fmt.Errorf("读取磁盘事件队列失败: %w", err)
+		}
+
+		reader := bufio.NewReader(q.readFile)
+		for len(events) < max {
+			line, err := reader.ReadBytes('\n')
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return events, fmt.Errorf("读取磁盘事件队列失败: %w", err)
+			}
+			q.readOff += int64(len(line))
+			q.unread--
+			var event collector.LogEvent
+			if err := json.Unmarshal(line, &event); err != nil {
+				continue
+			}
+			events = append(events, event)
+		}
+		if len(events) >= max {
+			break
+		}
+		// 读到末尾：正在写入的分段等待新事件，之前的分段（可能以写了一半的行结尾）转到下一个分段
+		if i == len(q.segments)-1 {
+			break
+		}
+		q.advance(q.segments[i+1].id)
+	}
+	q.updateMetrics()
+	return events, nil
+}
+
+// advance 将读取位置移到分段 id 的开头
+func (q *DiskQueue) advance(id uint64) {
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+	}
+	q.readSeg, q.readOff = id, 0
+}
+
+// Commit 保存当前的读取位置并删除已经读完的分段，之前读取的事件在重启后不再读取
+func (q *DiskQueue) Commit() error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errDiskQueueClosed
+	}
+	for len(q.segments) > 0 && q.segments[0].id < q.readSeg {
+		q.removeFirst()
+	}
+	q.updateMetrics()
+
+	cursor := filepath.Join(q.dir, "cursor")
+	tmp := cursor + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", q.readSeg, q.readOff)), 0644); err != nil {
+		return fmt.Errorf("保存磁盘事件队列读取位置失败: %w", err)
+	}
+	if err := os.Rename(tmp, cursor); err != nil {
+		return fmt.Errorf("保存磁盘事件队列读取位置失败: %w", err)
+	}
+	return nil
+}
+
+// removeFirst 删除最早的分段
+func (q *DiskQueue) removeFirst() {
+	seg := q.segments[0]
+	os.Remove(q.path(seg.id))
+	q.size -= seg.bytes
+	q.segments = q.segments[1:]
+}
+
+// Len 返回尚未读取的事件数
+func (q *DiskQueue) Len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.unread
+}
+
+// Close 关闭磁盘队列，未读取的事件保留在磁盘上，下次启动时继续处理
+func (q *DiskQueue) Close() error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	if q.readFile != nil {
+		q.readFile.Close()
+	}
+	if q.head != nil {
+		err := q.head.Close()
+		q.head = nil
+		if err != nil {
+			return fmt.Errorf("关闭磁盘事件队列失败: %w", err)
+		}
+	}
+	return nil
+}
+
+func (q *DiskQueue) updateMetrics() {
+	metrics.DiskQueueEvents.Set(float64(q.unread))
+	metrics.DiskQueueBytes.Set(float64(q.size))
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 每个事件除日志内容外的结构体和其他字段的估算字节数
+const eventOverhead = 512
+
+// 额度用完时检查是否已释放的间隔
+const flowPollInterval = 100 * time.Millisecond
+
+// 额度所剩无几时每个文件单次至少读取的字节数，避免逐字节地读取
+const minReadBytes = 64 << 10
+
+// Flow 端到端流量控制：事件从分发到写入存储、发出告警的整个处理过程中按大小占用额度，
+// AI分析或存储写入任一环节变慢时额度不再释放，采集端随之减少每轮读取的字节数直至暂停，
+// 内存中待处理的事件总量不超过上限
+type Flow struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewFlow 创建流量控制，limit 为内存中待处理事件的字节数上限，<=0 时只统计不限制
+func NewFlow(limit int64) *Flow {
+	return &Flow{limit: limit}
+}
+
+// EventCost 估算事件占用的内存字节数
+func EventCost(event *collector.LogEvent) int64 {
+	cost := int64(eventOverhead + 2*len(event.RawText)) // RawLines 与 RawText 内容相同
+	for _, line := range event.ContextLines {
+		cost += int64(len(line))
+	}
+	return cost
+}
+
+// Acquire 为新分发的事件占用额度；已分发的事件不能退回，额度可能暂时超过上限，之后采集端暂停直到释放
+func (f *Flow) Acquire(events []collector.LogEvent) {
+	var cost int64
+	for i := range events {
+		cost += EventCost(&events[i])
+	}
+	metrics.FlowInflightBytes.Set(float64(f.used.Add(cost)))
+}
+
+// Release 释放处理完的事件占用的额度，cost 为事件被工作协程取走时的 EventCost
+func (f *Flow) Release(cost int64) {
+	metrics.FlowInflightBytes.Set(float64(f.used.Add(-cost)))
+}
+
+// Exhausted 判断额度是否已用完
+func (f *Flow) Exhausted() bool {
+	return f.limit > 0 && f.used.Load() >= f.limit
+}
+
+// ReadBytes 返回按剩余额度每个文件本轮最多读取的字节数，不超过 maxBytes（0表示不限制）；不限制额度时返回 maxBytes
+func (f *Flow) ReadBytes(files int, maxBytes int64) int64 {
+	if f.limit <= 0 || files == 0 {
+		return maxBytes
+	}
+	n := (f.limit - f.used.Load()) / int64(files)
+	if n < minReadBytes {
+		n = minReadBytes
+	}
+	if maxBytes > 0 && n > maxBytes {
+		n = maxBytes
+	}
+	return n
+}
+
+// Wait 阻塞到额度未用完或 ctx 取消，等待时间计入 stage 环节的限流时间
+func (f *Flow) Wait(ctx context.Context, stage string) error {
+	if !f.Exhausted() {
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		metrics.FlowThrottledSeconds.WithLabelValues(stage).Add(time.Since(start).Seconds())
+	}()
+	ticker := time.NewTicker(flowPollInterval)
+	defer ticker.Stop()
+	for f.Exhausted() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
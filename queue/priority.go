@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"time"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// item 队列中的一个事件
+type item struct {
+	event    *collector.LogEvent
+	enqueued time.Time
+	key      int64 // 排序键，越小越先处理
+	seq      uint64
+}
+
+type itemHeap []*item
+
+func (h itemHeap) Len() int { return len(h) }
+func (h itemHeap) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key < h[j].key
+	}
+	return h[i].seq < h[j].seq
+}
+func (h itemHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *itemHeap) Push(x interface{}) { *h = append(*h, x.(*item)) }
+func (h *itemHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
+
+// PriorityQueue 位于AI分析之前的事件优先级队列
+// 积压时按严重性优先处理，等待时间每增加一个 aging 周期相当于严重性加1，避免低严重性事件一直得不到处理
+type PriorityQueue struct {
+	capacity int
+	aging    time.Duration
+	items    itemHeap
+	seq      uint64
+}
+
+// NewPriorityQueue 创建优先级队列，capacity 为最多缓存的事件数，aging<=0 时按到达顺序处理
+func NewPriorityQueue(capacity int, aging time.Duration) *PriorityQueue {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	return &PriorityQueue{capacity: capacity, aging: aging}
+}
+
+// key 计算排序键：有效优先级 = 严重性 + 已等待时间/aging，
+// 所有事件以相同速度老化，因此按 入队时间 - 严重性×aging 排序即可，无需随时间重新排序
+func (q *PriorityQueue) key(event *collector.LogEvent, enqueued time.Time) int64 {
+	return enqueued.UnixNano() - int64(event.SeverityScore)*int64(q.aging)
+}
+
+// Run 从 in 接收事件，按优先级转发到 out，直到 in 关闭且队列清空或 ctx 结束
+// out 应为无缓冲通道，使事件在工作协程空闲时才出队，从而保证出队顺序；队列满时暂停接收，对采集端形成背压
+func (q *PriorityQueue) Run(ctx context.Context, in <-chan *collector.LogEvent, out chan<- *collector.LogEvent) {
+	defer close(out)
+	for {
+		recv := in
+		if len(q.items) >= q.capacity {
+			recv = nil
+		}
+		var send chan<- *collector.LogEvent
+		var next *item
+		if len(q.items) > 0 {
+			send = out
+			next = q.items[0]
+		} else if in == nil {
+			return
+		}
+
+		var nextEvent *collector.LogEvent
+		if next != nil {
+			nextEvent = next.event
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-recv:
+			if !ok {
+				// 采集端已关闭，处理完剩余事件后退出
+				in = nil
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			now := time.Now()
+			q.seq++
+			heap.Push(&q.items, &item{event: event, enqueued: now, key: q.key(event, now), seq: q.seq})
+			metrics.EventQueueLength.WithLabelValues(metrics.SeverityBucket(event.SeverityScore)).Inc()
+		case send <- nextEvent:
+			heap.Pop(&q.items)
+			severity := metrics.SeverityBucket(next.event.SeverityScore)
+			metrics.EventQueueLength.WithLabelValues(severity).Dec()
+			metrics.EventQueueWaitDuration.WithLabelValues(severity).Observe(time.Since(next.enqueued).Seconds())
+		}
+	}
+}
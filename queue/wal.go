@@ -0,0 +1,409 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/metrics"
+)
+
+// 写前日志单个分段文件的最大字节数，超过后写入新的分段
+const walSegmentBytes = 8 << 20
+
+// 写前日志写满时的处理策略
+const (
+	WALBlock      = "block"       // 暂停采集，等待已投递的事件释放空间
+	WALDropOldest = "drop_oldest" // 删除最早的分段，其中尚未投递的事件不再重放
+	WALDropNewest = "drop_newest" // 新事件不再持久化，仍然正常处理，但进程崩溃时会丢失
+)
+
+// ErrWALFull 写前日志已满，策略为 drop_newest 时新事件不再持久化
+var ErrWALFull = errors.New("写前日志已满")
+
+// errWALClosed 写前日志已关闭
+var errWALClosed = errors.New("写前日志已关闭")
+
+// walSegment 写前日志的一个分段：事件按行写入 wal-<首个序号>.log，确认的序号按行写入同名的 .ack 文件，
+// 分段写满且其中的事件全部确认后删除
+type walSegment struct {
+	first   uint64   // 分段中第一个事件的序号
+	last    uint64   // 分段中最后一个事件的序号
+	file    *os.File // 正在写入的分段不为nil，写满或重启后加载的分段为nil
+	acks    *os.File
+	bytes   int64
+	records int // 分段中的事件数
+	acked   int // 已确认的事件数
+}
+
+// WAL 位于采集和处理之间的写前日志：采集到的事件先持久化再分发，所有存储后端写入完成后确认，
+// 未确认的事件在下次启动时重放，进程崩溃时事件至少投递一次
+type WAL struct {
+	dir          string
+	maxBytes     int64
+	policy       string
+	segmentBytes int64
+
+	mu       sync.Mutex
+	cond     *sync.Cond // 分段删除或关闭时通知等待空间的写入
+	segments []*walSegment
+	nextSeq  uint64
+	size     int64 // 事件分段占用的磁盘字节数
+	pending  int   // 尚未确认的事件数
+	replay   []collector.LogEvent
+	closed   bool
+}
+
+// OpenWAL 打开 dir 中的写前日志并加载上次未确认的事件，maxBytes<=0 表示不限制大小
+func OpenWAL(dir string, maxBytes int64, policy string) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建写前日志目录失败: %w", err)
+	}
+	w := &WAL{dir: dir, maxBytes: maxBytes, policy: policy, segmentBytes: walSegmentBytes, nextSeq: 1}
+	// 分段过大时写满前无法释放空间，保证队列上限内至少能容纳4个分段
+	if maxBytes > 0 && maxBytes/4 < w.segmentBytes {
+		w.segmentBytes = maxBytes/4 + 1
+	}
+	w.cond = sync.NewCond(&w.mu)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	if err != nil {
+		return nil, fmt.Errorf("读取写前日志失败: %w", err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := w.load(path); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	w.updateMetrics()
+	return w, nil
+}
+
+// load 加载一个分段，未确认的事件加入重放列表，全部已确认的分段直接删除
+func (w *WAL) load(path string) error {
+	first, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "wal-"), ".log"), 10, 64)
+	if err != nil {
+		return nil
+	}
+	ackPath := strings.TrimSuffix(path, ".log") + ".ack"
+	acked, err := readAcks(ackPath)
+	if err != nil {
+		return fmt.Errorf("读取写前日志失败: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取写前日志失败: %w", err)
+	}
+	defer f.Close()
+	seg := &walSegment{first: first}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		seg.bytes += int64(len(line))
+		if err == io.EOF {
+			// 崩溃时写了一半的最后一行，对应的事件未分发，忽略
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取写前日志失败: %w", err)
+		}
+		var event collector.LogEvent
+		if err := json.Unmarshal(line, &event); err != nil || event.Seq == 0 {
+			continue
+		}
+		seg.records++
+		if event.Seq > seg.last {
+			seg.last = event.Seq
+		}
+		if acked[event.Seq] {
+			seg.acked++
+		} else {
+			w.replay = append(w.replay, event)
+		}
+	}
+	if seg.last >= w.nextSeq {
+		w.nextSeq = seg.last + 1
+	}
+
+	if seg.acked >= seg.records {
+		os.Remove(path)
+		os.Remove(ackPath)
+		return nil
+	}
+	if seg.acks, err = os.OpenFile(ackPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return fmt.Errorf("打开写前日志确认文件失败: %w", err)
+	}
+	w.segments = append(w.segments, seg)
+	w.size += seg.bytes
+	w.pending += seg.records - seg.acked
+	return nil
+}
+
+// readAcks 读取分段中已确认的序号
+func readAcks(path string) (map[uint64]bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	acked := make(map[uint64]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if seq, err := strconv.ParseUint(line, 10, 64); err == nil {
+			acked[seq] = true
+		}
+	}
+	return acked, nil
+}
+
+// Replay 返回上次停止时未确认的事件（按序号排列），只在启动后调用一次
+func (w *WAL) Replay() []collector.LogEvent {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	events := w.replay
+	w.replay = nil
+	sort.Slice(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+	metrics.WALReplayedCount.Add(float64(len(events)))
+	return events
+}
+
+// Append 持久化事件并为其分配序号，写入 events[i].Seq。
+// 写前日志已满时按策略等待空间、丢弃最早的分段或返回 ErrWALFull；返回错误时未分配序号的事件没有持久化，
+// 调用方仍可分发这些事件，但它们不会被重放
+func (w *WAL) Append(ctx context.Context, events []collector.LogEvent) error {
+	if w == nil || len(events) == 0 {
+		return nil
+	}
+	var need int64
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("编码写前日志失败: %w", err)
+		}
+		need += int64(len(data)) + 21 // 序号和换行符
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errWALClosed
+	}
+	if err := w.reserve(ctx, need); err != nil {
+		metrics.WALDroppedCount.WithLabelValues("full").Add(float64(len(events)))
+		return err
+	}
+
+	var seg *walSegment
+	for i := range events {
+		events[i].Seq = w.nextSeq
+		line, err := json.Marshal(events[i])
+		if err == nil {
+			seg, err = w.writable(int64(len(line)) + 1)
+		}
+		if err == nil {
+			_, err = seg.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			for j := i; j < len(events); j++ {
+				events[j].Seq = 0
+			}
+			metrics.WALDroppedCount.WithLabelValues("error").Add(float64(len(events) - i))
+			w.updateMetrics()
+			return fmt.Errorf("写入写前日志失败: %w", err)
+		}
+		seg.bytes += int64(len(line)) + 1
+		seg.records++
+		seg.last = events[i].Seq
+		w.size += int64(len(line)) + 1
+		w.pending++
+		w.nextSeq++
+	}
+	w.updateMetrics()
+	if err := seg.file.Sync(); err != nil {
+		return fmt.Errorf("写入写前日志失败: %w", err)
+	}
+	return nil
+}
+
+// reserve 在写入 need 字节前按策略为写前日志腾出空间
+func (w *WAL) reserve(ctx context.Context, need int64) error {
+	if w.maxBytes <= 0 {
+		return nil
+	}
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		w.cond.Broadcast()
+		w.mu.Unlock()
+	})
+	defer stop()
+
+	logged := false
+	for w.size+need > w.maxBytes && len(w.segments) > 0 {
+		if w.closed {
+			return errWALClosed
+		}
+		switch w.policy {
+		case WALDropOldest:
+			seg := w.segments[0]
+			w.sealHead()
+			if len(w.segments) > 0 && w.segments[0] == seg {
+				dropped := seg.records - seg.acked
+				w.remove(0)
+				metrics.WALDroppedCount.WithLabelValues("full").Add(float64(dropped))
+				log.Printf("⚠️ 写前日志已满，丢弃最早分段中 %d 个未投递的事件", dropped)
+			}
+		case WALDropNewest:
+			return ErrWALFull
+		default:
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !logged {
+				log.Printf("⚠️ 写前日志已满（%d 个事件未投递），暂停采集等待事件处理完成", w.pending)
+				logged = true
+			}
+			// 正在写入的分段只有结束后才能删除，等待前先结束它
+			if head := w.segments[len(w.segments)-1]; head.file != nil {
+				w.sealHead()
+				continue
+			}
+			w.cond.Wait()
+		}
+	}
+	return nil
+}
+
+// writable 返回可以写入 n 字节的分段，当前分段已满时结束它并创建新的分段
+func (w *WAL) writable(n int64) (*walSegment, error) {
+	if len(w.segments) > 0 {
+		head := w.segments[len(w.segments)-1]
+		if head.file != nil && (head.records == 0 || head.bytes+n <= w.segmentBytes) {
+			return head, nil
+		}
+	}
+	w.sealHead()
+
+	base := filepath.Join(w.dir, fmt.Sprintf("wal-%020d", w.nextSeq))
+	f, err := os.OpenFile(base+".log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	acks, err := os.OpenFile(base+".ack", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		f.Close()
+		os.Remove(base + ".log")
+		return nil, err
+	}
+	// 写入第一个事件前 last 取上一个序号，保持各分段的 last 递增
+	seg := &walSegment{first: w.nextSeq, last: w.nextSeq - 1, file: f, acks: acks}
+	w.segments = append(w.segments, seg)
+	return seg, nil
+}
+
+// sealHead 结束正在写入的分段，其中的事件已全部确认时直接删除
+func (w *WAL) sealHead() {
+	if len(w.segments) == 0 {
+		return
+	}
+	i := len(w.segments) - 1
+	head := w.segments[i]
+	if head.file == nil {
+		return
+	}
+	head.file.Sync()
+	head.file.Close()
+	head.file = nil
+	if head.acked >= head.records {
+		w.remove(i)
+	}
+}
+
+// remove 删除第 i 个分段的文件，其中未确认的事件不再重放
+func (w *WAL) remove(i int) {
+	seg := w.segments[i]
+	base := filepath.Join(w.dir, fmt.Sprintf("wal-%020d", seg.first))
+	if seg.file != nil {
+		seg.file.Close()
+	}
+	seg.acks.Close()
+	os.Remove(base + ".log")
+	os.Remove(base + ".ack")
+	w.size -= seg.bytes
+	w.pending -= seg.records - seg.acked
+	w.segments = append(w.segments[:i], w.segments[i+1:]...)
+	w.cond.Broadcast()
+	w.updateMetrics()
+}
+
+// Ack 确认事件已投递到所有存储后端，之后不再重放；seq 为0（未持久化）时忽略
+func (w *WAL) Ack(seq uint64) {
+	if w == nil || seq == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	i := sort.Search(len(w.segments), func(i int) bool { return w.segments[i].last >= seq })
+	if i == len(w.segments) || w.segments[i].first > seq {
+		// 所在分段已因写前日志写满被丢弃
+		return
+	}
+	seg := w.segments[i]
+	// 确认记录写入失败只会导致重启后重复投递，不影响正确性
+	fmt.Fprintf(seg.acks, "%d\n", seq)
+	seg.acked++
+	w.pending--
+	if seg.file == nil && seg.acked >= seg.records {
+		w.remove(i)
+		return
+	}
+	w.updateMetrics()
+}
+
+// Close 关闭写前日志，未确认的事件保留在磁盘上，下次启动时重放
+func (w *WAL) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	w.cond.Broadcast()
+	var err error
+	for _, seg := range w.segments {
+		if seg.file != nil {
+			if syncErr := seg.file.Sync(); err == nil {
+				err = syncErr
+			}
+			seg.file.Close()
+			seg.file = nil
+		}
+		seg.acks.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("关闭写前日志失败: %w", err)
+	}
+	return nil
+}
+
+func (w *WAL) updateMetrics() {
+	metrics.WALPendingEvents.Set(float64(w.pending))
+	metrics.WALBytes.Set(float64(w.size))
+}
@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log-ai-analyzer/metrics"
+)
+
+// 还没有处理完的事件时按每个事件1秒估算处理耗时
+const defaultEventLatency = time.Second
+
+// 平均处理耗时的平滑系数，越大越偏向最近处理的事件
+const latencySmoothing = 0.2
+
+// WorkerPool 按积压的事件数和事件处理耗时在 min 和 max 之间伸缩工作协程数：
+// 积压的事件按当前的处理耗时在一个检查间隔内处理不完时扩容，空闲时逐步缩容
+type WorkerPool struct {
+	min, max int
+	interval time.Duration
+	backlog  *Backlog
+	start    func(id int, stop <-chan struct{})
+
+	busy    atomic.Int64  // 正在处理事件的工作协程数
+	latency atomic.Uint64 // 平均每个事件的处理耗时（纳秒，float64 位模式），0表示还没有样本
+
+	mu     sync.Mutex
+	stops  []chan struct{} // 运行中的工作协程的停止通道，缩容时停止最后启动的
+	nextID int
+}
+
+// NewWorkerPool 创建工作池，Run 启动后才运行工作协程；start 在新的协程中运行一个工作协程，
+// stop 关闭后工作协程处理完当前事件即退出。minWorkers 无效或不小于 maxWorkers 时工作协程数固定为 maxWorkers
+func NewWorkerPool(minWorkers, maxWorkers int, interval time.Duration, backlog *Backlog, start func(id int, stop <-chan struct{})) *WorkerPool {
+	if minWorkers <= 0 || minWorkers > maxWorkers {
+		minWorkers = maxWorkers
+	}
+	return &WorkerPool{min: minWorkers, max: maxWorkers, interval: interval, backlog: backlog, start: start}
+}
+
+// Size 返回当前的工作协程数
+func (p *WorkerPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.stops)
+}
+
+// Begin 记录工作协程开始处理一个事件，返回开始时间
+func (p *WorkerPool) Begin() time.Time {
+	metrics.WorkerPoolBusy.Set(float64(p.busy.Add(1)))
+	return time.Now()
+}
+
+// Done 记录工作协程处理完一个事件，更新平均处理耗时
+func (p *WorkerPool) Done(start time.Time) {
+	metrics.WorkerPoolBusy.Set(float64(p.busy.Add(-1)))
+	sample := float64(time.Since(start))
+	for {
+		old := p.latency.Load()
+		avg := sample
+		if old != 0 {
+			avg = latencySmoothing*sample + (1-latencySmoothing)*math.Float64frombits(old)
+		}
+		if p.latency.CompareAndSwap(old, math.Float64bits(avg)) {
+			return
+		}
+	}
+}
+
+// Run 启动最小数量的工作协程，之后每隔检查间隔按积压调整工作协程数，直到 ctx 取消；工作协程数固定时启动后即返回
+func (p *WorkerPool) Run(ctx context.Context) {
+	p.resize(p.min)
+	if p.min >= p.max {
+		return
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			size := p.Size()
+			if target := p.target(size); target != size {
+				log.Printf("调整工作池: %d -> %d 个工作协程（积压 %d 个事件，平均处理耗时 %v）",
+					size, target, p.backlog.Len(), p.avgLatency().Round(time.Millisecond))
+				p.resize(target)
+			}
+		}
+	}
+}
+
+// target 计算需要的工作协程数：正在处理的加上在一个检查间隔内处理完积压事件所需的；
+// 需要的更多时立即扩容，更少时每次缩减一半的差值，避免积压波动时反复伸缩
+func (p *WorkerPool) target(size int) int {
+	queued := p.backlog.Len()
+	needed := int(p.busy.Load())
+	if queued > 0 {
+		needed += int(math.Ceil(float64(queued) * float64(p.avgLatency()) / float64(p.interval)))
+	}
+	target := size
+	switch {
+	case needed > size:
+		target = needed
+	case needed < size:
+		target = size - max((size-needed)/2, 1)
+	}
+	return min(max(target, p.min), p.max)
+}
+
+// avgLatency 返回平均每个事件的处理耗时
+func (p *WorkerPool) avgLatency() time.Duration {
+	if bits := p.latency.Load(); bits != 0 {
+		return time.Duration(math.Float64frombits(bits))
+	}
+	return defaultEventLatency
+}
+
+// resize 启动或停止工作协程，使工作协程数为 n
+func (p *WorkerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.stops) < n {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		go p.start(p.nextID, stop)
+		p.nextID++
+	}
+	for len(p.stops) > n {
+		close(p.stops[len(p.stops)-1])
+		p.stops = p.stops[:len(p.stops)-1]
+	}
+	metrics.WorkerPoolSize.Set(float64(len(p.stops)))
+}
@@ -0,0 +1,108 @@
+package remoteconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Consul 阻塞查询的最长等待时间
+const consulWait = "5m"
+
+// consulBackend 通过 Consul KV HTTP 接口读取配置，用阻塞查询监听变化
+type consulBackend struct {
+	url    string
+	prefix string
+	token  string
+	client *http.Client
+}
+
+// consulPair Consul KV 接口返回的键值
+type consulPair struct {
+	Key   string `json:"Key"`
+	Value string `json:"Value"` // base64 编码
+}
+
+// fetch 读取前缀下的所有键值
+func (c *consulBackend) fetch(ctx context.Context) (Snapshot, uint64, error) {
+	resp, err := c.get(ctx, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	index := consulIndex(resp)
+	snap := make(Snapshot)
+	if resp.StatusCode == http.StatusNotFound {
+		return snap, index, nil
+	}
+	var pairs []consulPair
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, 0, fmt.Errorf("解析 Consul 响应失败: %w", err)
+	}
+	for _, p := range pairs {
+		key := strings.TrimPrefix(p.Key, c.prefix)
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(p.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("解析 Consul 键 %s 失败: %w", p.Key, err)
+		}
+		snap[key] = string(value)
+	}
+	return snap, index, nil
+}
+
+// wait 发起阻塞查询，前缀下的键值变化或等待超时后返回
+func (c *consulBackend) wait(ctx context.Context, index uint64) error {
+	// 没有版本号时无法阻塞查询，间隔一段时间后再读取
+	if index == 0 {
+		sleep(ctx, retryInterval)
+		return nil
+	}
+	resp, err := c.get(ctx, index)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
+// get 请求前缀下的所有键值，index>0 时为阻塞查询
+func (c *consulBackend) get(ctx context.Context, index uint64) (*http.Response, error) {
+	query := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", consulWait)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/v1/kv/"+c.prefix+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Consul 请求失败: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 Consul 失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("Consul 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// consulIndex 读取响应头中的 X-Consul-Index
+func consulIndex(resp *http.Response) uint64 {
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return index
+}
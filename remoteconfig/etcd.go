@@ -0,0 +1,89 @@
+package remoteconfig
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"log-ai-analyzer/etcd"
+)
+
+// etcdBackend 通过 etcd v3 的 HTTP/JSON 网关读取配置，用 watch 接口监听变化
+type etcdBackend struct {
+	client *etcd.Client
+	prefix string
+}
+
+// etcdRangeResponse etcd range 接口的响应，int64 以字符串返回，bytes 以 base64 编码
+type etcdRangeResponse struct {
+	Header struct {
+		Revision string `json:"revision"`
+	} `json:"header"`
+	Kvs []etcd.KV `json:"kvs"`
+}
+
+// fetch 读取前缀下的所有键值及当前的 revision
+func (e *etcdBackend) fetch(ctx context.Context) (Snapshot, uint64, error) {
+	body := map[string]string{
+		"key":       etcd.Encode(e.prefix),
+		"range_end": etcd.Encode(etcd.PrefixEnd(e.prefix)),
+	}
+	var r etcdRangeResponse
+	if err := e.client.Do(ctx, "/v3/kv/range", body, &r); err != nil {
+		return nil, 0, err
+	}
+	snap := make(Snapshot, len(r.Kvs))
+	for _, kv := range r.Kvs {
+		key, err := etcd.Decode(kv.Key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("解析 etcd 键失败: %w", err)
+		}
+		value, err := etcd.Decode(kv.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("解析 etcd 键 %s 失败: %w", key, err)
+		}
+		snap[strings.TrimPrefix(key, e.prefix)] = value
+	}
+	revision, _ := strconv.ParseUint(r.Header.Revision, 10, 64)
+	return snap, revision, nil
+}
+
+// wait 从 revision 之后开始监听前缀，收到第一个变更事件后返回
+func (e *etcdBackend) wait(ctx context.Context, revision uint64) error {
+	body := map[string]any{
+		"create_request": map[string]string{
+			"key":            etcd.Encode(e.prefix),
+			"range_end":      etcd.Encode(etcd.PrefixEnd(e.prefix)),
+			"start_revision": strconv.FormatUint(revision+1, 10),
+		},
+	}
+	stream, err := e.client.Stream(ctx, "/v3/watch", body)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	// 响应为逐行的 JSON 流，第一条为创建确认，之后每条包含一批变更事件
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var msg struct {
+			Result struct {
+				Events []json.RawMessage `json:"events"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			return fmt.Errorf("解析 etcd watch 响应失败: %w", err)
+		}
+		if len(msg.Result.Events) > 0 {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取 etcd watch 响应失败: %w", err)
+	}
+	return fmt.Errorf("etcd watch 连接已关闭")
+}
@@ -0,0 +1,212 @@
+// Package remoteconfig 从 etcd 或 Consul 读取并监听集中管理的配置，
+// 使规则调整（新增关键词、抑制规则等）在几秒内下发到所有采集节点
+package remoteconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/etcd"
+)
+
+// 键的分类：env/ 下为配置项（键名为环境变量名），files/ 下为规则文件（键名为文件名）
+const (
+	EnvPrefix  = "env/"
+	FilePrefix = "files/"
+)
+
+// 监听失败后重试的间隔
+const retryInterval = 5 * time.Second
+
+// Options 集中配置源的连接参数
+type Options struct {
+	Kind   string // etcd 或 consul
+	URL    string // etcd 为 http://host:2379，Consul 为 http://host:8500
+	Prefix string // 键的前缀，如 logai/ 或 logai/prod/
+	Token  string // Consul ACL Token 或 etcd 认证令牌
+	Dir    string // files/ 下的规则文件同步到的本地目录
+}
+
+// Snapshot 前缀下的所有键值，键已去掉前缀
+type Snapshot map[string]string
+
+// Env 返回 env/ 下的配置项
+func (s Snapshot) Env() map[string]string {
+	return s.under(EnvPrefix)
+}
+
+// Files 返回 files/ 下的规则文件
+func (s Snapshot) Files() map[string]string {
+	return s.under(FilePrefix)
+}
+
+// under 返回指定分类下的键值，键去掉分类前缀
+func (s Snapshot) under(prefix string) map[string]string {
+	result := make(map[string]string)
+	for k, v := range s {
+		if name, ok := strings.CutPrefix(k, prefix); ok && name != "" {
+			result[name] = v
+		}
+	}
+	return result
+}
+
+// backend 配置中心的读取接口
+type backend interface {
+	// fetch 读取前缀下的所有键值及当前版本号
+	fetch(ctx context.Context) (Snapshot, uint64, error)
+	// wait 阻塞直到前缀下的键值在 index 之后发生变化，或等待超时
+	wait(ctx context.Context, index uint64) error
+}
+
+// Source 集中配置源
+type Source struct {
+	opts    Options
+	backend backend
+}
+
+// New 创建集中配置源，Kind 为空时返回nil表示不使用集中配置
+func New(opts Options) (*Source, error) {
+	if opts.Kind == "" {
+		return nil, nil
+	}
+	if opts.URL == "" {
+		return nil, fmt.Errorf("使用集中配置时必须配置地址")
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	client := &http.Client{}
+	var b backend
+	switch opts.Kind {
+	case "etcd":
+		b = &etcdBackend{client: etcd.NewClient(opts.URL, func() string { return opts.Token }, 0), prefix: opts.Prefix}
+	case "consul":
+		b = &consulBackend{url: opts.URL, prefix: opts.Prefix, token: opts.Token, client: client}
+	default:
+		return nil, fmt.Errorf("不支持的集中配置源: %s", opts.Kind)
+	}
+	return &Source{opts: opts, backend: b}, nil
+}
+
+// Load 读取一次集中配置，并将其中的规则文件同步到本地目录
+func (s *Source) Load(ctx context.Context) (Snapshot, error) {
+	snap, _, err := s.backend.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.syncFiles(snap.Files()); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Watch 监听集中配置的变化，直到 ctx 取消：规则文件变化时写入本地目录，
+// 每次有键值变化时以变化的规则文件名和配置项名调用 onChange；连接失败时每隔几秒重试
+func (s *Source) Watch(ctx context.Context, onChange func(files, env []string)) {
+	if s == nil {
+		return
+	}
+	var last Snapshot
+	var index uint64
+	for ctx.Err() == nil {
+		snap, idx, err := s.backend.fetch(ctx)
+		if err != nil {
+			log.Printf("读取集中配置失败: %v", err)
+			sleep(ctx, retryInterval)
+			continue
+		}
+		files, err := s.syncFiles(snap.Files())
+		if err != nil {
+			log.Printf("同步集中配置的规则文件失败: %v", err)
+		}
+		var env []string
+		if last != nil {
+			env = changedKeys(last.Env(), snap.Env())
+		}
+		if len(files) > 0 || len(env) > 0 {
+			onChange(files, env)
+		}
+		last, index = snap, idx
+
+		if err := s.backend.wait(ctx, index); err != nil && ctx.Err() == nil {
+			log.Printf("监听集中配置失败: %v", err)
+			sleep(ctx, retryInterval)
+		}
+	}
+}
+
+// syncFiles 将规则文件写入本地目录，返回内容有变化的文件名。
+// 先写临时文件再替换，读取方不会读到不完整的文件；集中配置中删除的文件不在本地删除
+func (s *Source) syncFiles(files map[string]string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(s.opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建集中配置目录失败: %w", err)
+	}
+	var changed []string
+	for name, content := range files {
+		if filepath.Base(name) != name || name == "." || name == ".." {
+			log.Printf("忽略集中配置中的规则文件 %s: 文件名不能包含路径", name)
+			continue
+		}
+		path := filepath.Join(s.opts.Dir, name)
+		if old, err := os.ReadFile(path); err == nil && string(old) == content {
+			continue
+		}
+		if err := writeFile(path, content); err != nil {
+			return changed, err
+		}
+		changed = append(changed, name)
+	}
+	return changed, nil
+}
+
+// writeFile 先写临时文件再替换
+func writeFile(path, content string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// changedKeys 返回新增、删除或值有变化的键
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for k, v := range after {
+		if old, ok := before[k]; !ok || old != v {
+			keys = append(keys, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// sleep 等待 d 或 ctx 取消
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,245 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/alert"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/feature"
+)
+
+// 与上一周期对比时统计的模板数量，需大于报告中列出的数量以便识别新增问题
+const prevTopN = 200
+
+// Generator 汇总周期内的事件，由AI撰写汇总报告并投递
+type Generator struct {
+	cfg *config.Config
+	es  *esclient.ESClient
+}
+
+// NewGenerator 创建汇总报告生成器
+func NewGenerator(cfg *config.Config, es *esclient.ESClient) *Generator {
+	return &Generator{cfg: cfg, es: es}
+}
+
+// Generate 生成 [from, to) 的汇总报告，通过企业微信和邮件投递，并写入ES报告索引
+func (g *Generator) Generate(ctx context.Context, from, to time.Time) (*esclient.Report, error) {
+	stats, err := g.es.PeriodStats(ctx, from, to, g.cfg.ReportTopN)
+	if err != nil {
+		return nil, err
+	}
+	// 上一周期的统计只用于趋势对比，失败时不影响报告生成
+	prev, err := g.es.PeriodStats(ctx, from.Add(-to.Sub(from)), from, prevTopN)
+	if err != nil {
+		log.Printf("统计上一周期事件失败，报告中不包含趋势对比: %v", err)
+		prev = nil
+	}
+
+	data := formatStats(stats, prev)
+	summary, err := ai.SummarizeReport(g.cfg, data)
+	if err != nil {
+		log.Printf("AI生成汇总报告失败，使用统计数据代替: %v", err)
+	}
+	if summary == "" {
+		summary = "（AI汇总不可用，以下为统计数据）\n\n" + data
+	}
+	noise := g.noiseProposals(ctx, from, to)
+	summary += noise.section
+
+	report := &esclient.Report{
+		Timestamp:      time.Now(),
+		From:           from,
+		To:             to,
+		Summary:        summary,
+		Stats:          stats,
+		NoiseProposals: noise.candidates,
+	}
+	g.deliver(report)
+	if err := g.es.IndexReport(ctx, g.cfg.ReportIndex, *report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// noiseResult 噪声统计的结果和报告中的说明
+type noiseResult struct {
+	candidates []esclient.NoiseCandidate
+	section    string
+}
+
+// noiseProposals 统计周期内的噪声模板，附在报告末尾供运维人员审核；apply 模式下同时写入抑制规则文件。
+// 统计失败时报告中不包含噪声建议
+func (g *Generator) noiseProposals(ctx context.Context, from, to time.Time) noiseResult {
+	if g.cfg.NoiseSuppression == "off" {
+		return noiseResult{}
+	}
+	var incidentIndex string
+	if g.cfg.IncidentWindow > 0 {
+		incidentIndex = g.cfg.ESIncidentIndex
+	}
+	candidates, err := g.es.NoiseCandidates(ctx, from, to, incidentIndex, int64(g.cfg.NoiseMinEvents))
+	if err != nil {
+		log.Printf("统计噪声模板失败，报告中不包含抑制建议: %v", err)
+		return noiseResult{}
+	}
+	if len(candidates) == 0 {
+		return noiseResult{}
+	}
+
+	title := "建议抑制的噪声模板（持续出现，但从未被评价或处理，也未出现在严重故障中）"
+	if g.cfg.NoiseSuppression == "apply" && feature.Enabled(feature.AutoSuppression) {
+		rules := make([]alert.SuppressionRule, 0, len(candidates))
+		for _, c := range candidates {
+			rules = append(rules, alert.SuppressionRule{
+				TemplateID: c.TemplateID,
+				Sample:     firstLine(c.Sample),
+				Reason:     fmt.Sprintf("%s ~ %s 出现 %d 次，%d/%d 小时有事件", from.Format("01-02 15:04"), to.Format("01-02 15:04"), c.Count, c.ActiveHours, c.Hours),
+				CreatedAt:  time.Now(),
+			})
+		}
+		added, err := alert.AddSuppressions(g.cfg.NoiseSuppressionFile, rules)
+		if err != nil {
+			log.Printf("写入抑制规则失败: %v", err)
+		} else {
+			title = fmt.Sprintf("已自动抑制的噪声模板（新增 %d 个，从 %s 中删除可恢复告警）", added, g.cfg.NoiseSuppressionFile)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n\n#### 🔇 %s\n", title)
+	for i, c := range candidates {
+		fmt.Fprintf(&b, "%d. `%s` 出现 %d 次，%d/%d 小时有事件，最高严重性 %d\n   示例: %s\n",
+			i+1, c.TemplateID, c.Count, c.ActiveHours, c.Hours, c.MaxSeverity, firstLine(c.Sample))
+	}
+	return noiseResult{candidates: candidates, section: b.String()}
+}
+
+// deliver 通过已配置的渠道发送报告，单个渠道失败不影响其他渠道
+func (g *Generator) deliver(report *esclient.Report) {
+	title := fmt.Sprintf("日志异常汇总报告（%s ~ %s）", report.From.Format("01-02 15:04"), report.To.Format("01-02 15:04"))
+
+	if g.cfg.EnableAlert && g.cfg.WeChatWebhook != "" {
+		content := fmt.Sprintf("### 📋 %s\n%s", title, ai.Localize(g.cfg, report.Summary, "wechat"))
+		err := alert.Deliver("wechat", func() error {
+			return alert.SendWeChatMarkdown(g.cfg.Secret("AI_WECHAT_WEBHOOK"), content)
+		})
+		if err != nil {
+			log.Printf("汇总报告发送到企业微信失败: %v", err)
+		}
+	}
+	if len(g.cfg.ReportEmailTo) > 0 {
+		smtpCfg := alert.SMTPConfig{
+			Host:     g.cfg.SMTPHost,
+			Port:     g.cfg.SMTPPort,
+			User:     g.cfg.SMTPUser,
+			Password: g.cfg.Secret("SMTP_PASSWORD"),
+			From:     g.cfg.SMTPFrom,
+		}
+		body := ai.Localize(g.cfg, report.Summary, "email") + "\n\n----\n统计数据:\n\n" + formatStats(report.Stats, nil)
+		err := alert.Deliver("email", func() error {
+			return alert.SendEmail(smtpCfg, g.cfg.ReportEmailTo, title, body)
+		})
+		if err != nil {
+			log.Printf("汇总报告邮件发送失败: %v", err)
+		}
+	}
+}
+
+// formatStats 将周期统计格式化为提示词中的统计数据，prev 非nil时附带与上一周期的对比
+func formatStats(stats, prev *esclient.PeriodStats) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "统计周期: %s ~ %s\n", stats.From.Format("2006-01-02 15:04"), stats.To.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "事件总数: %d，高严重性（>=8）事件: %d\n", stats.Total, stats.HighSeverity)
+
+	prevCounts := make(map[string]int64)
+	if prev != nil {
+		fmt.Fprintf(&b, "上一周期事件总数: %d，高严重性事件: %d\n", prev.Total, prev.HighSeverity)
+		for _, t := range prev.TopTemplates {
+			prevCounts[t.TemplateID] = t.Count
+		}
+	}
+
+	if len(stats.TopTemplates) > 0 {
+		b.WriteString("\n主要问题（按出现次数）:\n")
+		for i, t := range stats.TopTemplates {
+			fmt.Fprintf(&b, "%d. 出现 %d 次，涉及 %d 台主机，最高严重性 %d", i+1, t.Count, t.Hosts, t.MaxSeverity)
+			if prev != nil {
+				b.WriteString("，" + trend(t.Count, prevCounts[t.TemplateID]))
+			}
+			fmt.Fprintf(&b, "\n   示例: %s\n", firstLine(t.Sample))
+		}
+	}
+
+	if len(stats.TopHosts) > 0 {
+		b.WriteString("\n事件最多的主机:\n")
+		for _, h := range stats.TopHosts {
+			fmt.Fprintf(&b, "- %s: %d\n", h.Key, h.Count)
+		}
+	}
+
+	if len(stats.Hourly) > 0 {
+		b.WriteString("\n按小时分布:\n")
+		for _, h := range stats.Hourly {
+			fmt.Fprintf(&b, "- %s: %d\n", h.Key, h.Count)
+		}
+	}
+	return b.String()
+}
+
+// trend 描述与上一周期相比的变化
+func trend(count, prevCount int64) string {
+	switch {
+	case prevCount == 0:
+		return "本周期新出现"
+	case count > prevCount:
+		return fmt.Sprintf("较上一周期增加 %d%%", (count-prevCount)*100/prevCount)
+	case count < prevCount:
+		return fmt.Sprintf("较上一周期减少 %d%%", (prevCount-count)*100/prevCount)
+	default:
+		return "与上一周期持平"
+	}
+}
+
+func firstLine(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	return line
+}
+
+// Scheduler 按时间表定期生成汇总报告
+type Scheduler struct {
+	schedule  Schedule
+	generator *Generator
+}
+
+// NewScheduler 创建汇总报告调度器
+func NewScheduler(schedule Schedule, generator *Generator) *Scheduler {
+	return &Scheduler{schedule: schedule, generator: generator}
+}
+
+// Run 阻塞运行直到 ctx 取消
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		next := s.schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		from := s.schedule.Prev(next)
+		log.Printf("开始生成汇总报告: %s ~ %s", from.Format("2006-01-02 15:04"), next.Format("2006-01-02 15:04"))
+		genCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+		if _, err := s.generator.Generate(genCtx, from, next); err != nil {
+			log.Printf("生成汇总报告失败: %v", err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schedule 每天生成汇总报告的时间点（距零点的分钟数，升序）
+// 每次报告覆盖从上一个时间点到本次时间点的区间，只配置一个时间点时即为日报
+type Schedule []int
+
+// ParseSchedule 解析形如 "08:00,20:00" 的报告时间表
+func ParseSchedule(spec string) (Schedule, error) {
+	var s Schedule
+	seen := make(map[int]bool)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		t, err := time.Parse("15:04", item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的报告时间 %q，格式应为 HH:MM", item)
+		}
+		minute := t.Hour()*60 + t.Minute()
+		if !seen[minute] {
+			seen[minute] = true
+			s = append(s, minute)
+		}
+	}
+	if len(s) == 0 {
+		return nil, fmt.Errorf("报告时间表为空")
+	}
+	sort.Ints(s)
+	return s, nil
+}
+
+// at 返回 day 当天第 minute 分钟对应的时间
+func at(day time.Time, minute int) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, minute/60, minute%60, 0, 0, day.Location())
+}
+
+// Next 返回 t 之后的下一个报告时间
+func (s Schedule) Next(t time.Time) time.Time {
+	for _, minute := range s {
+		if next := at(t, minute); next.After(t) {
+			return next
+		}
+	}
+	return at(t.AddDate(0, 0, 1), s[0])
+}
+
+// Prev 返回 t 之前的上一个报告时间，即本次报告的起始时间
+func (s Schedule) Prev(t time.Time) time.Time {
+	for i := len(s) - 1; i >= 0; i-- {
+		if prev := at(t, s[i]); prev.Before(t) {
+			return prev
+		}
+	}
+	return at(t.AddDate(0, 0, -1), s[len(s)-1])
+}
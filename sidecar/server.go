@@ -0,0 +1,120 @@
+// Package sidecar AI分析 sidecar / 中心服务
+// 多个 agent 通过 gRPC 将AI调用委托给该服务，由服务端持有API Key并统一执行限流、预算和故障切换
+package sidecar
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/metrics"
+	"log-ai-analyzer/sidecar/sidecarpb"
+)
+
+// Server AnalyzeService 的服务端实现，使用本进程配置的AI后端链完成请求
+type Server struct {
+	sidecarpb.UnimplementedAnalyzeServiceServer
+	cfg *config.Config
+}
+
+// NewServer 创建 AnalyzeService 服务端
+func NewServer(cfg *config.Config) *Server {
+	return &Server{cfg: cfg}
+}
+
+// Complete 使用服务端的AI后端链完成一次请求
+// agent 已完成提示词注入防护，并会自行校验回复，服务端只负责调用、限流和预算
+func (s *Server) Complete(ctx context.Context, req *sidecarpb.CompleteRequest) (*sidecarpb.CompleteResponse, error) {
+	if req.GetContent() == "" {
+		return nil, status.Error(codes.InvalidArgument, "content 不能为空")
+	}
+
+	res, provider, err := ai.Complete(s.cfg, req.GetSystemPrompt(), req.GetContent())
+	if err != nil {
+		log.Printf("sidecar AI调用失败 [agent: %s]: %v", req.GetAgent(), err)
+		metrics.SidecarRequestCount.WithLabelValues(req.GetAgent(), "error").Inc()
+		return nil, ai.ToGRPCError(err)
+	}
+	metrics.SidecarRequestCount.WithLabelValues(req.GetAgent(), "ok").Inc()
+	return &sidecarpb.CompleteResponse{
+		Content:          res.Content,
+		PromptTokens:     int32(res.PromptTokens),
+		CompletionTokens: int32(res.CompletionTokens),
+		Provider:         provider.Name(),
+		Model:            provider.ModelName(),
+	}, nil
+}
+
+// Ping 任意一个AI后端可用即视为可用
+func (s *Server) Ping(ctx context.Context, req *sidecarpb.PingRequest) (*sidecarpb.PingResponse, error) {
+	providers, err := ai.NewProviders(s.cfg)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	var errs []error
+	for _, p := range providers {
+		if err := p.Ping(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s(%s): %w", p.Name(), p.ModelName(), err))
+			continue
+		}
+		return &sidecarpb.PingResponse{}, nil
+	}
+	return nil, status.Errorf(codes.Unavailable, "%s: %v", ai.ErrAIUnavailable, errors.Join(errs...))
+}
+
+// authInterceptor 校验 agent 携带的访问令牌，每次调用时读取当前的令牌，密钥轮换后无需重启
+func authInterceptor(token func() string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var got string
+		if values := md.Get("authorization"); len(values) > 0 {
+			got = strings.TrimPrefix(values[0], "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token())) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "访问令牌无效")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Serve 在配置的地址上启动 AnalyzeService，ctx 结束时优雅停止
+// 配置了 SIDECAR_TOKEN 时要求 agent 携带令牌，配置了证书时启用TLS
+func Serve(ctx context.Context, cfg *config.Config) error {
+	var opts []grpc.ServerOption
+	if cfg.Secret("SIDECAR_TOKEN") != "" {
+		opts = append(opts, grpc.UnaryInterceptor(authInterceptor(func() string { return cfg.Secret("SIDECAR_TOKEN") })))
+	}
+	if cfg.SidecarTLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.SidecarTLSCertFile, cfg.SidecarTLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("加载sidecar TLS证书失败: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", cfg.SidecarListen)
+	if err != nil {
+		return fmt.Errorf("监听sidecar地址失败: %w", err)
+	}
+	server := grpc.NewServer(opts...)
+	sidecarpb.RegisterAnalyzeServiceServer(server, NewServer(cfg))
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	log.Printf("✅ AI sidecar 服务已启动, 地址: %s", cfg.SidecarListen)
+	return server.Serve(lis)
+}
@@ -0,0 +1,170 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sidecar/sidecarpb/analyze.proto
+
+// logai 内部的AI分析协议：agent 将AI调用委托给 sidecar 或中心服务，
+// 由服务端持有API Key并统一执行限流和预算控制
+
+package sidecarpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	AnalyzeService_Complete_FullMethodName = "/logai.sidecar.v1.AnalyzeService/Complete"
+	AnalyzeService_Ping_FullMethodName     = "/logai.sidecar.v1.AnalyzeService/Ping"
+)
+
+// AnalyzeServiceClient is the client API for AnalyzeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// AnalyzeService AI分析服务
+type AnalyzeServiceClient interface {
+	// Complete 发送系统提示词和日志内容，返回模型的完整回复
+	Complete(ctx context.Context, in *CompleteRequest, opts ...grpc.CallOption) (*CompleteResponse, error)
+	// Ping 探测服务及其AI后端是否可用，不消耗token
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+}
+
+type analyzeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAnalyzeServiceClient(cc grpc.ClientConnInterface) AnalyzeServiceClient {
+	return &analyzeServiceClient{cc}
+}
+
+func (c *analyzeServiceClient) Complete(ctx context.Context, in *CompleteRequest, opts ...grpc.CallOption) (*CompleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompleteResponse)
+	err := c.cc.Invoke(ctx, AnalyzeService_Complete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analyzeServiceClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, AnalyzeService_Ping_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AnalyzeServiceServer is the server API for AnalyzeService service.
+// All implementations must embed UnimplementedAnalyzeServiceServer
+// for forward compatibility.
+//
+// AnalyzeService AI分析服务
+type AnalyzeServiceServer interface {
+	// Complete 发送系统提示词和日志内容，返回模型的完整回复
+	Complete(context.Context, *CompleteRequest) (*CompleteResponse, error)
+	// Ping 探测服务及其AI后端是否可用，不消耗token
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	mustEmbedUnimplementedAnalyzeServiceServer()
+}
+
+// UnimplementedAnalyzeServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAnalyzeServiceServer struct{}
+
+func (UnimplementedAnalyzeServiceServer) Complete(context.Context, *CompleteRequest) (*CompleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Complete not implemented")
+}
+func (UnimplementedAnalyzeServiceServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
+func (UnimplementedAnalyzeServiceServer) mustEmbedUnimplementedAnalyzeServiceServer() {}
+func (UnimplementedAnalyzeServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeAnalyzeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AnalyzeServiceServer will
+// result in compilation errors.
+type UnsafeAnalyzeServiceServer interface {
+	mustEmbedUnimplementedAnalyzeServiceServer()
+}
+
+func RegisterAnalyzeServiceServer(s grpc.ServiceRegistrar, srv AnalyzeServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAnalyzeServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AnalyzeService_ServiceDesc, srv)
+}
+
+func _AnalyzeService_Complete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyzeServiceServer).Complete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalyzeService_Complete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyzeServiceServer).Complete(ctx, req.(*CompleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AnalyzeService_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyzeServiceServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AnalyzeService_Ping_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyzeServiceServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AnalyzeService_ServiceDesc is the grpc.ServiceDesc for AnalyzeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AnalyzeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "logai.sidecar.v1.AnalyzeService",
+	HandlerType: (*AnalyzeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Complete",
+			Handler:    _AnalyzeService_Complete_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _AnalyzeService_Ping_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sidecar/sidecarpb/analyze.proto",
+}
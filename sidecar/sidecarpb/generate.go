@@ -0,0 +1,4 @@
+// Package sidecarpb AI分析 sidecar 协议的 gRPC 生成代码
+package sidecarpb
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative sidecar/sidecarpb/analyze.proto
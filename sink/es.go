@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"log-ai-analyzer/esclient"
+)
+
+// ES 存储方式
+const (
+	StoreEvents = "events" // 每个事件一个文档
+	StoreAlerts = "alerts" // 每个合并告警一个聚合文档
+	StoreBoth   = "both"
+)
+
+// ESSink 写入Elasticsearch，按存储方式决定是否写入事件文档和告警聚合文档
+type ESSink struct {
+	client        *esclient.ESClient
+	alertIndex    string
+	incidentIndex string
+	store         string
+}
+
+// NewESSink 创建ES存储后端，store 为 events、alerts 或 both，incidentIndex 为空时不保存故障
+func NewESSink(client *esclient.ESClient, alertIndex, incidentIndex, store string) *ESSink {
+	return &ESSink{client: client, alertIndex: alertIndex, incidentIndex: incidentIndex, store: store}
+}
+
+// Name 返回后端名称
+func (s *ESSink) Name() string {
+	return "elasticsearch"
+}
+
+// WriteEvent 写入事件文档，只存储告警聚合文档时跳过
+func (s *ESSink) WriteEvent(event esclient.LogEvent) error {
+	if s.store == StoreAlerts {
+		return nil
+	}
+	return s.client.IndexLog(event)
+}
+
+// WriteAlert 写入告警聚合文档，只存储事件文档时跳过
+func (s *ESSink) WriteAlert(doc esclient.AlertDoc) error {
+	if s.store == StoreEvents {
+		return nil
+	}
+	return s.client.UpsertAlert(s.alertIndex, doc)
+}
+
+// WriteIncident 写入故障文档
+func (s *ESSink) WriteIncident(doc esclient.IncidentDoc) error {
+	if s.incidentIndex == "" {
+		return nil
+	}
+	return s.client.UpsertIncident(s.incidentIndex, doc)
+}
+
+// Flush 立即提交批量写入队列中积累的文档
+func (s *ESSink) Flush() {
+	s.client.Flush()
+}
+
+// Close 提交批量写入队列中剩余的文档
+func (s *ESSink) Close() error {
+	return s.client.Close()
+}
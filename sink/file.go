@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"log-ai-analyzer/esclient"
+)
+
+// File 将处理后的事件以NDJSON（每行一个JSON）写入文件或标准输出，
+// 便于通过管道交给其他工具处理、在无法访问外部服务的环境中留存结果，以及不依赖ES的集成测试
+type File struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // 标准输出不关闭
+	alerts bool
+}
+
+// NewFile 打开输出文件（追加写入），path 为 "-" 时写入标准输出；alerts 为 true 时同时写入合并告警
+func NewFile(path string, alerts bool) (*File, error) {
+	if path == "-" {
+		return &File{w: os.Stdout, alerts: alerts}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开输出文件失败: %w", err)
+	}
+	return &File{w: f, closer: f, alerts: alerts}, nil
+}
+
+// writeLine 写入一行JSON，整行一次写入，并发写入时各行不会交错
+func (f *File) writeLine(doc interface{}) error {
+	line, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("编码输出记录失败: %w", err)
+	}
+	line = append(line, '\n')
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.w.Write(line); err != nil {
+		return fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	return nil
+}
+
+// Name 返回后端名称
+func (f *File) Name() string {
+	return "file"
+}
+
+// WriteEvent 写入事件
+func (f *File) WriteEvent(event esclient.LogEvent) error {
+	return f.writeLine(event)
+}
+
+// WriteAlert 写入合并告警的最新状态，未启用时跳过；告警记录带有 alert_key 字段，可与事件区分
+func (f *File) WriteAlert(doc esclient.AlertDoc) error {
+	if !f.alerts {
+		return nil
+	}
+	return f.writeLine(doc)
+}
+
+// Close 关闭输出文件
+func (f *File) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
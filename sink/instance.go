@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"log-ai-analyzer/esclient"
+)
+
+// instanceSink 在写入的事件和告警上标注分析器实例和静态标签，
+// 多个实例写入同一个存储后端时可按实例区分数据来源
+type instanceSink struct {
+	Sink
+	id     string
+	labels map[string]string
+}
+
+// instanceIncidentSink 可以保存故障记录的后端加上实例标注
+type instanceIncidentSink struct {
+	*instanceSink
+	incidents IncidentWriter
+}
+
+// WithInstance 为后端加上实例标注，实例标识和标签都为空时原样返回；
+// 被包装的后端可以保存故障时，返回值同样实现 IncidentWriter
+func WithInstance(s Sink, id string, labels map[string]string) Sink {
+	if id == "" && len(labels) == 0 {
+		return s
+	}
+	wrapped := &instanceSink{Sink: s, id: id, labels: labels}
+	if w, ok := s.(IncidentWriter); ok {
+		return &instanceIncidentSink{instanceSink: wrapped, incidents: w}
+	}
+	return wrapped
+}
+
+// WriteEvent 标注实例后写入事件
+func (i *instanceSink) WriteEvent(event esclient.LogEvent) error {
+	event.Instance, event.Labels = i.id, i.labels
+	return i.Sink.WriteEvent(event)
+}
+
+// WriteAlert 标注实例后写入告警
+func (i *instanceSink) WriteAlert(doc esclient.AlertDoc) error {
+	doc.Instance, doc.Labels = i.id, i.labels
+	return i.Sink.WriteAlert(doc)
+}
+
+// WriteIncident 标注实例后写入故障
+func (i *instanceIncidentSink) WriteIncident(doc esclient.IncidentDoc) error {
+	doc.Instance, doc.Labels = i.id, i.labels
+	return i.incidents.WriteIncident(doc)
+}
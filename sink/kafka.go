@@ -0,0 +1,177 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/metrics"
+)
+
+// KafkaOptions Kafka输出配置
+type KafkaOptions struct {
+	Brokers       []string
+	Topic         string // 事件主题
+	AlertTopic    string // 合并告警主题，为空时不发布告警
+	TLS           bool
+	SASLMechanism string // plain、scram-sha-256、scram-sha-512，为空表示不认证
+	Username      string
+	Password      func() string // 每次建立连接认证时读取，密钥轮换后新的连接使用新密码
+}
+
+// Kafka 将处理后的事件（已脱敏、包含AI分析）发布到Kafka，供下游 SIEM、数据湖消费
+// 消息以主机名为键，同一主机的事件进入同一分区并保持顺序
+type Kafka struct {
+	events *kafka.Writer
+	alerts *kafka.Writer // 未配置告警主题时为nil
+}
+
+// NewKafka 创建Kafka输出，消息异步批量发送，发送失败记录日志和指标
+func NewKafka(opts KafkaOptions) (*Kafka, error) {
+	if len(opts.Brokers) == 0 || opts.Topic == "" {
+		return nil, fmt.Errorf("Kafka输出需要配置 broker 和主题")
+	}
+	transport := &kafka.Transport{}
+	if opts.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if opts.SASLMechanism != "" {
+		mechanism, err := saslMechanism(opts)
+		if err != nil {
+			return nil, err
+		}
+		transport.SASL = rotatingMechanism{name: mechanism.Name(), create: func() (sasl.Mechanism, error) { return saslMechanism(opts) }}
+	}
+
+	k := &Kafka{events: newKafkaWriter(opts.Brokers, opts.Topic, transport)}
+	if opts.AlertTopic != "" {
+		k.alerts = newKafkaWriter(opts.Brokers, opts.AlertTopic, transport)
+	}
+	return k, nil
+}
+
+// saslMechanism 根据配置和当前的密码创建SASL认证方式
+func saslMechanism(opts KafkaOptions) (sasl.Mechanism, error) {
+	password := ""
+	if opts.Password != nil {
+		password = opts.Password()
+	}
+	switch strings.ToLower(opts.SASLMechanism) {
+	case "plain":
+		return plain.Mechanism{Username: opts.Username, Password: password}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, opts.Username, password)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, opts.Username, password)
+	default:
+		return nil, fmt.Errorf("不支持的Kafka SASL认证方式: %s", opts.SASLMechanism)
+	}
+}
+
+// rotatingMechanism 每次建立连接认证时按当前的密码重新创建SASL认证方式
+type rotatingMechanism struct {
+	name   string
+	create func() (sasl.Mechanism, error)
+}
+
+func (m rotatingMechanism) Name() string {
+	return m.name
+}
+
+func (m rotatingMechanism) Start(ctx context.Context) (sasl.StateMachine, []byte, error) {
+	mechanism, err := m.create()
+	if err != nil {
+		return nil, nil, err
+	}
+	return mechanism.Start(ctx)
+}
+
+// newKafkaWriter 创建异步写入某个主题的生产者
+func newKafkaWriter(brokers []string, topic string, transport *kafka.Transport) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		Transport:    transport,
+		RequiredAcks: kafka.RequireAll,
+		Async:        true,
+		BatchTimeout: 100 * time.Millisecond,
+		Completion: func(messages []kafka.Message, err error) {
+			if err != nil {
+				log.Printf("发布 %d 条消息到Kafka主题 %s 失败: %v", len(messages), topic, err)
+				metrics.StorageWriteErrorCount.WithLabelValues("kafka", "publish").Add(float64(len(messages)))
+				metrics.DropEvents(metrics.DropSinkError, countKind(messages, "event"))
+			}
+		},
+	}
+}
+
+// countKind 返回 type 头为 kind 的消息数
+func countKind(messages []kafka.Message, kind string) int {
+	n := 0
+	for _, m := range messages {
+		for _, h := range m.Headers {
+			if h.Key == "type" && string(h.Value) == kind {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// publish 将文档编码为JSON发布，type 头标识消息类型
+func publish(w *kafka.Writer, key, kind string, doc interface{}) error {
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("编码Kafka消息失败: %w", err)
+	}
+	// 异步模式下只在消息入队时返回错误，发送结果由 Completion 处理
+	err = w.WriteMessages(context.Background(), kafka.Message{
+		Key:     []byte(key),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(kind)}},
+	})
+	if err != nil {
+		return fmt.Errorf("发布Kafka消息失败: %w", err)
+	}
+	return nil
+}
+
+// Name 返回后端名称
+func (k *Kafka) Name() string {
+	return "kafka"
+}
+
+// WriteEvent 发布事件
+func (k *Kafka) WriteEvent(event esclient.LogEvent) error {
+	return publish(k.events, event.Host, "event", event)
+}
+
+// WriteAlert 发布合并告警的最新状态，未配置告警主题时跳过
+func (k *Kafka) WriteAlert(doc esclient.AlertDoc) error {
+	if k.alerts == nil {
+		return nil
+	}
+	return publish(k.alerts, doc.Key, "alert", doc)
+}
+
+// Close 发送缓存中剩余的消息并关闭生产者
+func (k *Kafka) Close() error {
+	err := k.events.Close()
+	if k.alerts != nil {
+		if alertErr := k.alerts.Close(); err == nil {
+			err = alertErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"log-ai-analyzer/esclient"
+)
+
+// 单次写入的超时时间
+const postgresWriteTimeout = 10 * time.Second
+
+// 迁移期间持有的 advisory lock，避免多个实例同时迁移
+const migrationLockID = 0x6c6f6761
+
+// migrations 按版本顺序排列的表结构变更，已发布的迁移不能修改，只能追加
+var migrations = []string{
+	// 1: 事件表和告警表，字段和AI分析以JSONB保存
+	`CREATE TABLE events (
+		event_id       TEXT PRIMARY KEY,
+		ts             TIMESTAMPTZ NOT NULL,
+		host           TEXT NOT NULL,
+		template_id    TEXT NOT NULL DEFAULT '',
+		severity_score INTEGER NOT NULL,
+		content        TEXT NOT NULL,
+		ai_result      JSONB,
+		fields         JSONB NOT NULL DEFAULT '{}'
+	);
+	CREATE INDEX events_ts_idx ON events (ts DESC);
+	CREATE INDEX events_host_ts_idx ON events (host, ts DESC);
+	CREATE INDEX events_template_idx ON events (template_id);
+	CREATE TABLE alerts (
+		alert_key      TEXT PRIMARY KEY,
+		host           TEXT NOT NULL,
+		status         TEXT NOT NULL,
+		count          INTEGER NOT NULL,
+		severity_score INTEGER NOT NULL,
+		first_seen     TIMESTAMPTZ NOT NULL,
+		last_seen      TIMESTAMPTZ NOT NULL,
+		content        TEXT NOT NULL,
+		ai_result      JSONB,
+		fields         JSONB NOT NULL DEFAULT '{}',
+		updated_at     TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX alerts_status_last_seen_idx ON alerts (status, last_seen DESC);`,
+}
+
+// Postgres 写入PostgreSQL，适合不想部署Elasticsearch的小规模场景
+type Postgres struct {
+	db *sql.DB
+}
+
+// dsnConnector 每次建立新连接时读取当前的 DSN，密钥轮换后新的连接使用新密码，已有连接不受影响
+type dsnConnector struct {
+	dsn func() string
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := pq.NewConnector(c.dsn())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// NewPostgres 连接PostgreSQL并执行尚未应用的表结构迁移，dsn 返回当前的连接串
+func NewPostgres(dsn func() string, maxConns int) (*Postgres, error) {
+	if _, err := pq.NewConnector(dsn()); err != nil {
+		return nil, fmt.Errorf("连接PostgreSQL失败: %w", err)
+	}
+	db := sql.OpenDB(dsnConnector{dsn: dsn})
+	if maxConns > 0 {
+		db.SetMaxOpenConns(maxConns)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("连接PostgreSQL失败: %w", err)
+	}
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Postgres{db: db}, nil
+}
+
+// migrate 在同一个事务中应用尚未执行的迁移并记录版本
+func migrate(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("PostgreSQL表结构迁移失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("PostgreSQL表结构迁移失败: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("PostgreSQL表结构迁移失败: %w", err)
+	}
+	var current int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("PostgreSQL表结构迁移失败: %w", err)
+	}
+	for version := current + 1; version <= len(migrations); version++ {
+		if _, err := tx.ExecContext(ctx, migrations[version-1]); err != nil {
+			return fmt.Errorf("PostgreSQL表结构迁移 %d 失败: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			return fmt.Errorf("PostgreSQL表结构迁移 %d 失败: %w", version, err)
+		}
+		log.Printf("✅ PostgreSQL表结构已迁移到版本 %d", version)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("PostgreSQL表结构迁移失败: %w", err)
+	}
+	return nil
+}
+
+// aiResultJSON AI分析结果的JSONB表示，为空时存为NULL
+func aiResultJSON(result string) interface{} {
+	if result == "" {
+		return nil
+	}
+	data, _ := json.Marshal(map[string]string{"text": result})
+	return string(data)
+}
+
+// Name 返回后端名称
+func (p *Postgres) Name() string {
+	return "postgres"
+}
+
+// WriteEvent 写入事件，同一事件ID重复写入时更新为最新内容
+func (p *Postgres) WriteEvent(event esclient.LogEvent) error {
+	fields, err := json.Marshal(map[string]interface{}{
+		"tags":          event.Tags,
+		"fingerprint":   event.Fingerprint,
+		"feedback":      event.Feedback,
+		"operator_note": event.OperatorNote,
+		"instance":      event.Instance,
+		"labels":        event.Labels,
+	})
+	if err != nil {
+		return fmt.Errorf("编码事件字段失败: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresWriteTimeout)
+	defer cancel()
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO events (event_id, ts, host, template_id, severity_score, content, ai_result, fields)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id) DO UPDATE SET
+			ai_result = EXCLUDED.ai_result,
+			fields    = events.fields || EXCLUDED.fields`,
+		event.EventID, event.Timestamp, event.Host, event.TemplateID, event.SeverityScore,
+		event.Content, aiResultJSON(event.AiResult), string(fields))
+	if err != nil {
+		return fmt.Errorf("写入PostgreSQL事件失败: %w", err)
+	}
+	return nil
+}
+
+// WriteAlert 写入或更新告警记录，乱序到达的旧状态不会覆盖更新的状态
+func (p *Postgres) WriteAlert(doc esclient.AlertDoc) error {
+	fields, err := json.Marshal(map[string]interface{}{
+		"file_path":     doc.FilePath,
+		"template_id":   doc.TemplateID,
+		"fingerprint":   doc.Fingerprint,
+		"last_event_id": doc.LastEventID,
+		"ticket_id":     doc.TicketID,
+		"instance":      doc.Instance,
+		"labels":        doc.Labels,
+	})
+	if err != nil {
+		return fmt.Errorf("编码告警字段失败: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), postgresWriteTimeout)
+	defer cancel()
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO alerts (alert_key, host, status, count, severity_score, first_seen, last_seen, content, ai_result, fields, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (alert_key) DO UPDATE SET
+			host           = EXCLUDED.host,
+			status         = EXCLUDED.status,
+			count          = EXCLUDED.count,
+			severity_score = EXCLUDED.severity_score,
+			first_seen     = EXCLUDED.first_seen,
+			last_seen      = EXCLUDED.last_seen,
+			content        = EXCLUDED.content,
+			ai_result      = EXCLUDED.ai_result,
+			fields         = EXCLUDED.fields,
+			updated_at     = EXCLUDED.updated_at
+		WHERE alerts.updated_at <= EXCLUDED.updated_at`,
+		doc.Key, doc.Host, doc.Status, doc.Count, doc.Severity, doc.FirstSeen, doc.LastSeen,
+		doc.Content, aiResultJSON(doc.AiResult), string(fields), time.Now())
+	if err != nil {
+		return fmt.Errorf("写入PostgreSQL告警失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭数据库连接
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
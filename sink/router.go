@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+
+	"log-ai-analyzer/esclient"
+)
+
+// Router 按租户将事件和告警写入不同的后端（如各租户独立的ES集群），没有单独配置的租户写入默认后端
+type Router struct {
+	fallback Sink
+	tenants  map[string]Sink
+}
+
+// NewRouter 创建按租户路由的后端，tenants 为租户到后端的映射
+func NewRouter(fallback Sink, tenants map[string]Sink) *Router {
+	return &Router{fallback: fallback, tenants: tenants}
+}
+
+// route 返回租户对应的后端
+func (r *Router) route(tenant string) Sink {
+	if s, ok := r.tenants[tenant]; ok {
+		return s
+	}
+	return r.fallback
+}
+
+// Name 返回后端名称
+func (r *Router) Name() string {
+	return r.fallback.Name()
+}
+
+// WriteEvent 将事件写入所属租户的后端
+func (r *Router) WriteEvent(event esclient.LogEvent) error {
+	return r.route(event.Tenant).WriteEvent(event)
+}
+
+// WriteAlert 将告警写入所属租户的后端
+func (r *Router) WriteAlert(doc esclient.AlertDoc) error {
+	return r.route(doc.Tenant).WriteAlert(doc)
+}
+
+// WriteIncident 将故障写入所属租户的后端，后端不支持保存故障时跳过
+func (r *Router) WriteIncident(doc esclient.IncidentDoc) error {
+	if w, ok := r.route(doc.Tenant).(IncidentWriter); ok {
+		return w.WriteIncident(doc)
+	}
+	return nil
+}
+
+// Flush 立即提交默认后端和所有租户的后端缓存的数据
+func (r *Router) Flush() {
+	if f, ok := r.fallback.(Flusher); ok {
+		f.Flush()
+	}
+	for _, s := range r.tenants {
+		if f, ok := s.(Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// Close 关闭默认后端和所有租户的后端
+func (r *Router) Close() error {
+	errs := []error{r.fallback.Close()}
+	for tenant, s := range r.tenants {
+		if err := s.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("租户 %s: %w", tenant, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"errors"
+	"fmt"
+
+	"log-ai-analyzer/diag"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/metrics"
+)
+
+// Sink 事件和告警的存储后端
+// 事件和告警沿用ES文档的结构，其他后端按各自的方式映射
+type Sink interface {
+	Name() string
+	// WriteEvent 写入一个事件
+	WriteEvent(event esclient.LogEvent) error
+	// WriteAlert 写入或更新合并告警的聚合记录，同一告警键只保留一条
+	WriteAlert(doc esclient.AlertDoc) error
+	// Close 提交缓存的数据并释放连接
+	Close() error
+}
+
+// IncidentWriter 可以保存故障记录的后端，目前只有ES
+type IncidentWriter interface {
+	// WriteIncident 写入或更新故障记录，同一故障只保留一条
+	WriteIncident(doc esclient.IncidentDoc) error
+}
+
+// Flusher 缓存待写入数据的后端，可立即提交缓存的数据
+type Flusher interface {
+	// Flush 立即提交缓存的数据，不等待刷新间隔
+	Flush()
+}
+
+// Multi 同时写入多个存储后端，任一后端失败时返回错误，其余后端照常写入
+type Multi []Sink
+
+// Name 返回后端名称
+func (m Multi) Name() string {
+	return "multi"
+}
+
+// WriteEvent 将事件写入所有后端
+func (m Multi) WriteEvent(event esclient.LogEvent) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.WriteEvent(event); err != nil {
+			metrics.StorageWriteErrorCount.WithLabelValues(s.Name(), "event").Inc()
+			diag.Recordf("sink:"+s.Name(), "事件写入失败: %v", err)
+			metrics.DropEvents(metrics.DropSinkError, 1)
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteAlert 将告警写入所有后端
+func (m Multi) WriteAlert(doc esclient.AlertDoc) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.WriteAlert(doc); err != nil {
+			metrics.StorageWriteErrorCount.WithLabelValues(s.Name(), "alert").Inc()
+			diag.Recordf("sink:"+s.Name(), "告警写入失败: %v", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteIncident 将故障写入所有支持保存故障的后端
+func (m Multi) WriteIncident(doc esclient.IncidentDoc) error {
+	var errs []error
+	for _, s := range m {
+		w, ok := s.(IncidentWriter)
+		if !ok {
+			continue
+		}
+		if err := w.WriteIncident(doc); err != nil {
+			metrics.StorageWriteErrorCount.WithLabelValues(s.Name(), "incident").Inc()
+			diag.Recordf("sink:"+s.Name(), "故障写入失败: %v", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭所有后端
+func (m Multi) Close() error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush 立即提交所有后端缓存的数据
+func (m Multi) Flush() {
+	for _, s := range m {
+		if f, ok := s.(Flusher); ok {
+			f.Flush()
+		}
+	}
+}
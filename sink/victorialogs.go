@@ -0,0 +1,160 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/metrics"
+)
+
+// VictoriaLogs 批量写入的参数
+const (
+	vlogsBatchSize     = 1000
+	vlogsFlushInterval = time.Second
+	vlogsQueueSize     = 10000
+	vlogsTimeout       = 30 * time.Second
+)
+
+// VictoriaLogsOptions VictoriaLogs 输出配置
+type VictoriaLogsOptions struct {
+	URL       string // 服务地址，如 http://victorialogs:9428，可在地址中携带 Basic 认证信息
+	AccountID string // 多租户时的租户ID
+	ProjectID string
+}
+
+// VictoriaLogs 通过 JSON line 接口将事件写入 VictoriaLogs，以主机名为日志流，日志内容为消息字段
+// 事件在后台按条数或刷新间隔批量提交，队列满时写入方等待
+type VictoriaLogs struct {
+	endpoint string
+	opts     VictoriaLogsOptions
+	client   *http.Client
+
+	lines chan []byte
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewVictoriaLogs 创建 VictoriaLogs 输出并启动后台提交
+func NewVictoriaLogs(opts VictoriaLogsOptions) (*VictoriaLogs, error) {
+	u, err := url.Parse(strings.TrimSuffix(opts.URL, "/") + "/insert/jsonline")
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("VictoriaLogs 地址无效: %s", opts.URL)
+	}
+	q := u.Query()
+	q.Set("_stream_fields", "host")
+	q.Set("_msg_field", "content")
+	q.Set("_time_field", "@timestamp")
+	u.RawQuery = q.Encode()
+
+	v := &VictoriaLogs{
+		endpoint: u.String(),
+		opts:     opts,
+		client:   &http.Client{Timeout: vlogsTimeout},
+		lines:    make(chan []byte, vlogsQueueSize),
+		done:     make(chan struct{}),
+	}
+	go v.run()
+	return v, nil
+}
+
+// run 积累事件并按条数或刷新间隔提交，lines 关闭后提交剩余事件
+func (v *VictoriaLogs) run() {
+	defer close(v.done)
+	ticker := time.NewTicker(vlogsFlushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	count := 0
+	flush := func() {
+		if count == 0 {
+			return
+		}
+		if err := v.push(batch.Bytes()); err != nil {
+			log.Printf("写入 VictoriaLogs 失败，丢弃 %d 个事件: %v", count, err)
+			metrics.StorageWriteErrorCount.WithLabelValues(v.Name(), "publish").Add(float64(count))
+			metrics.DropEvents(metrics.DropSinkError, count)
+		}
+		batch.Reset()
+		count = 0
+	}
+	for {
+		select {
+		case line, ok := <-v.lines:
+			if !ok {
+				flush()
+				return
+			}
+			batch.Write(line)
+			if count++; count >= vlogsBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// push 提交一批 JSON line
+func (v *VictoriaLogs) push(body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), vlogsTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/stream+json")
+	if v.opts.AccountID != "" {
+		req.Header.Set("AccountID", v.opts.AccountID)
+	}
+	if v.opts.ProjectID != "" {
+		req.Header.Set("ProjectID", v.opts.ProjectID)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("VictoriaLogs 返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Name 返回后端名称
+func (v *VictoriaLogs) Name() string {
+	return "victorialogs"
+}
+
+// WriteEvent 将事件加入提交队列
+func (v *VictoriaLogs) WriteEvent(event esclient.LogEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("编码 VictoriaLogs 日志失败: %w", err)
+	}
+	v.lines <- append(line, '\n')
+	return nil
+}
+
+// WriteAlert VictoriaLogs 只保存事件，合并告警不写入
+func (v *VictoriaLogs) WriteAlert(esclient.AlertDoc) error {
+	return nil
+}
+
+// Close 提交队列中剩余的事件
+func (v *VictoriaLogs) Close() error {
+	v.once.Do(func() { close(v.lines) })
+	<-v.done
+	return nil
+}
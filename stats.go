@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"log-ai-analyzer/analyzer"
+)
+
+// 默认返回的高频事件数
+const defaultStatsTop = 10
+
+// statsHandler 提供 GET /api/stats?top=10 接口，返回最近一小时的严重性分布和出现最多的事件
+func statsHandler(stats *analyzer.EventStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		top := defaultStatsTop
+		if v := r.URL.Query().Get("top"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid top", http.StatusBadRequest)
+				return
+			}
+			top = n
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats.GetEventStatistics(top))
+	}
+}
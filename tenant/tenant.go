@@ -0,0 +1,132 @@
+// Package tenant 根据主机、日志文件、标签和内容识别事件所属的租户、服务和团队，
+// 存储时按租户写入不同的索引或集群，各团队只能看到自己的数据，保留期也可以按租户设置
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"log-ai-analyzer/collector"
+)
+
+// Rule 租户识别规则，所有配置的条件都满足时事件归属该租户，没有条件的规则匹配所有事件
+type Rule struct {
+	Tenant  string   `json:"tenant"`
+	Service string   `json:"service,omitempty"`
+	Team    string   `json:"team,omitempty"`
+	Hosts   []string `json:"hosts,omitempty"`   // 主机名通配符，任一匹配
+	Files   []string `json:"files,omitempty"`   // 日志文件路径通配符，任一匹配
+	Tags    []string `json:"tags,omitempty"`    // 事件标签，任一匹配
+	Pattern string   `json:"pattern,omitempty"` // 日志内容正则（不区分大小写）
+
+	re *regexp.Regexp
+}
+
+// Settings 租户的存储设置
+type Settings struct {
+	RetentionDays int      `json:"retention_days,omitempty"` // 事件索引保留天数，0表示使用 ES_RETENTION_DAYS
+	ESNodes       []string `json:"es_nodes,omitempty"`       // 写入独立的ES集群，为空时写入默认集群
+}
+
+// Config 租户配置文件：按顺序匹配规则，第一条命中的规则决定事件的租户，都不命中时使用 Default
+type Config struct {
+	Default string              `json:"default,omitempty"`
+	Rules   []Rule              `json:"rules"`
+	Tenants map[string]Settings `json:"tenants,omitempty"`
+}
+
+// Load 加载JSON格式的租户配置文件
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取租户配置失败: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("解析租户配置失败: %w", err)
+	}
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Tenant == "" {
+			return nil, fmt.Errorf("租户规则 %d 缺少 tenant", i+1)
+		}
+		for _, glob := range append(append([]string{}, r.Hosts...), r.Files...) {
+			if _, err := filepath.Match(glob, ""); err != nil {
+				return nil, fmt.Errorf("租户 %s 的通配符 %q 无效: %w", r.Tenant, glob, err)
+			}
+		}
+		if r.Pattern != "" {
+			if r.re, err = regexp.Compile("(?i)" + r.Pattern); err != nil {
+				return nil, fmt.Errorf("租户 %s 的 pattern 无效: %w", r.Tenant, err)
+			}
+		}
+	}
+	return &c, nil
+}
+
+// matches 判断事件是否满足规则的所有条件
+func (r *Rule) matches(event *collector.LogEvent) bool {
+	if len(r.Hosts) > 0 && !matchAny(r.Hosts, event.Host) {
+		return false
+	}
+	if len(r.Files) > 0 && !matchAny(r.Files, event.FilePath) {
+		return false
+	}
+	if len(r.Tags) > 0 && !hasAny(r.Tags, event.Tags) {
+		return false
+	}
+	return r.re == nil || r.re.MatchString(event.RawText)
+}
+
+// matchAny 判断 s 是否匹配任一通配符
+func matchAny(globs []string, s string) bool {
+	for _, glob := range globs {
+		if ok, _ := filepath.Match(glob, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAny 判断 tags 中是否包含 want 中的任一标签
+func hasAny(want, tags []string) bool {
+	for _, w := range want {
+		for _, tag := range tags {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Resolve 识别事件的租户、服务和团队并写入事件，c 为nil时不处理
+func (c *Config) Resolve(event *collector.LogEvent) {
+	if c == nil {
+		return
+	}
+	for i := range c.Rules {
+		if r := &c.Rules[i]; r.matches(event) {
+			event.Tenant, event.Service, event.Team = r.Tenant, r.Service, r.Team
+			return
+		}
+	}
+	event.Tenant = c.Default
+}
+
+// Retention 返回单独设置了保留天数的租户
+func (c *Config) Retention() map[string]int {
+	if c == nil {
+		return nil
+	}
+	days := make(map[string]int)
+	for name, s := range c.Tenants {
+		if s.RetentionDays > 0 {
+			days[name] = s.RetentionDays
+		}
+	}
+	return days
+}
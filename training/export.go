@@ -0,0 +1,113 @@
+// Package training 导出用于微调本地模型的训练数据
+package training
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/ai"
+	"log-ai-analyzer/collector"
+	"log-ai-analyzer/config"
+	"log-ai-analyzer/esclient"
+	"log-ai-analyzer/processor"
+)
+
+// Message 对话格式训练样本中的一条消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Example 一条训练样本，每行一个 JSON，兼容常见的对话格式微调工具
+type Example struct {
+	Messages []Message `json:"messages"`
+}
+
+// Stats 导出结果统计
+type Stats struct {
+	Helpful   int // 使用被评价为有帮助的AI分析作为答案
+	Corrected int // 使用运维人员的更正说明作为答案
+	Skipped   int // 没有可用答案的事件
+}
+
+// Total 导出的样本总数
+func (s Stats) Total() int {
+	return s.Helpful + s.Corrected
+}
+
+// Exporter 将运维人员认可或更正过的分析结果与事件内容配对导出
+type Exporter struct {
+	cfg *config.Config
+	es  *esclient.ESClient
+}
+
+// NewExporter 创建训练数据导出器
+func NewExporter(cfg *config.Config, es *esclient.ESClient) *Exporter {
+	return &Exporter{cfg: cfg, es: es}
+}
+
+// Export 将 since 之后已标注的事件以 JSONL 格式写入 w
+func (e *Exporter) Export(ctx context.Context, w io.Writer, since time.Time) (Stats, error) {
+	var stats Stats
+	enc := json.NewEncoder(w)
+	err := e.es.ScanLabeled(ctx, since, func(event esclient.LogEvent) error {
+		answer, corrected := label(event)
+		if answer == "" {
+			stats.Skipped++
+			return nil
+		}
+		example, err := e.example(event, answer)
+		if err != nil {
+			stats.Skipped++
+			return nil
+		}
+		if err := enc.Encode(example); err != nil {
+			return fmt.Errorf("写入训练数据失败: %w", err)
+		}
+		if corrected {
+			stats.Corrected++
+		} else {
+			stats.Helpful++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// label 返回事件的标注答案：被评价为有帮助的AI分析直接作为答案，否则使用运维人员的处理记录（更正说明）
+// 没有处理记录的其他事件、降级结果（规则摘要等）不作为训练样本
+func label(event esclient.LogEvent) (answer string, corrected bool) {
+	result := strings.TrimSpace(event.AiResult)
+	if event.Feedback == esclient.FeedbackHelpful && result != "" && !strings.HasPrefix(result, "（") {
+		return result, false
+	}
+	if note := strings.TrimSpace(event.OperatorNote); note != "" {
+		return note, true
+	}
+	return "", false
+}
+
+// example 使用与在线分析相同的提示词模板生成训练样本，事件内容和答案再次脱敏
+func (e *Exporter) example(event esclient.LogEvent, answer string) (Example, error) {
+	system, user, err := ai.TrainingPrompts(e.cfg, collector.LogEvent{
+		RawText:       processor.MaskSensitiveInfo(event.Content),
+		Timestamp:     event.Timestamp.Format(time.RFC3339),
+		Host:          event.Host,
+		Tags:          event.Tags,
+		SeverityScore: event.SeverityScore,
+		EventID:       event.EventID,
+		TemplateID:    event.TemplateID,
+	})
+	if err != nil {
+		return Example{}, err
+	}
+	return Example{Messages: []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: user},
+		{Role: "assistant", Content: processor.MaskSensitiveInfo(answer)},
+	}}, nil
+}
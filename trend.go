@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"log-ai-analyzer/esclient"
+)
+
+// 默认统计的时间窗口：最近1小时、1天和1周
+const defaultTrendWindows = "1h,24h,168h"
+
+// 最多统计的时间窗口数
+const maxTrendWindows = 10
+
+// parseTrendWindows 解析逗号分隔的时间窗口，如 1h,24h,168h
+func parseTrendWindows(s string) ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, err := time.ParseDuration(part)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("无效的时间窗口: %s", part)
+		}
+		windows = append(windows, w)
+	}
+	if len(windows) == 0 || len(windows) > maxTrendWindows {
+		return nil, fmt.Errorf("时间窗口数量应为1到%d个", maxTrendWindows)
+	}
+	return windows, nil
+}
+
+// trendHandler 提供 GET /api/trend?template=ID&tag=标签&windows=1h,24h,168h 接口，
+// 返回日志模板或标签在各时间窗口内的出现次数及一周前同一时间段的次数
+func trendHandler(esClient *esclient.ESClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := esclient.TrendQuery{
+			TemplateID: r.URL.Query().Get("template"),
+			Tag:        r.URL.Query().Get("tag"),
+		}
+		if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+			query.Tenants = []string{tenant}
+		}
+		if query.TemplateID == "" && query.Tag == "" {
+			http.Error(w, "template or tag is required", http.StatusBadRequest)
+			return
+		}
+		spec := r.URL.Query().Get("windows")
+		if spec == "" {
+			spec = defaultTrendWindows
+		}
+		windows, err := parseTrendWindows(spec)
+		if err != nil {
+			http.Error(w, "invalid windows", http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+		trend, err := esClient.OccurrenceTrend(ctx, query, windows, time.Now())
+		if err != nil {
+			log.Printf("查询出现次数失败: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(trend)
+	}
+}
+
+// formatTrend 以文本形式输出出现次数趋势
+func formatTrend(trend *esclient.OccurrenceTrend) string {
+	var b strings.Builder
+	subject := trend.TemplateID
+	if trend.Tag != "" {
+		if subject != "" {
+			subject += " "
+		}
+		subject += "标签:" + trend.Tag
+	}
+	fmt.Fprintf(&b, "%s\n", subject)
+	if trend.FirstSeen == nil {
+		b.WriteString("索引中没有相关事件\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "首次出现: %s  最近出现: %s\n",
+		trend.FirstSeen.Local().Format("2006-01-02 15:04:05"), trend.LastSeen.Local().Format("2006-01-02 15:04:05"))
+	if trend.New {
+		b.WriteString("⚠️ 一周内首次出现\n")
+	}
+	for _, w := range trend.Windows {
+		change := "一周前未出现"
+		if w.ChangePercent != nil {
+			change = fmt.Sprintf("%+.0f%%", *w.ChangePercent)
+		}
+		fmt.Fprintf(&b, "最近 %-8s %8d 次  一周前同期 %8d 次  %s\n", w.Window, w.Count, w.LastWeek, change)
+	}
+	return b.String()
+}